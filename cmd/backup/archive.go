@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+)
+
+// manifest 备份清单，与备份文件一同保存，恢复前用于校验
+type manifest struct {
+	Store     string    `json:"store"` // mysql 或 leveldb
+	CreatedAt time.Time `json:"created_at"`
+	Since     int64     `json:"since"` // 增量备份的起始消息时间戳，0表示全量
+	Until     int64     `json:"until"` // 备份时刻，作为下一次增量备份的起点
+	Entries   int64     `json:"entries"`
+	SHA256    string    `json:"sha256"` // 备份文件的校验和
+}
+
+// entry 备份文件中的一行，MySQL备份使用Table和Row，LevelDB备份使用Key和Value
+type entry struct {
+	Table string          `json:"t,omitempty"`
+	Row   json.RawMessage `json:"r,omitempty"`
+	Key   []byte          `json:"k,omitempty"`
+	Value []byte          `json:"v,omitempty"`
+}
+
+// archiveWriter 写入gzip压缩的JSON行备份文件，同时计算文件校验和
+type archiveWriter struct {
+	file    *os.File
+	hash    hash.Hash
+	gz      *gzip.Writer
+	enc     *json.Encoder
+	entries int64
+}
+
+// createArchive 创建备份文件
+func createArchive(path string) (*archiveWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	h := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(file, h))
+	return &archiveWriter{
+		file: file,
+		hash: h,
+		gz:   gz,
+		enc:  json.NewEncoder(gz),
+	}, nil
+}
+
+// write 写入一行
+func (w *archiveWriter) write(e *entry) error {
+	w.entries++
+	return w.enc.Encode(e)
+}
+
+// close 结束写入并返回文件校验和
+func (w *archiveWriter) close() (string, error) {
+	if err := w.gz.Close(); err != nil {
+		w.file.Close()
+		return "", fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := w.file.Close(); err != nil {
+		return "", fmt.Errorf("failed to close archive: %w", err)
+	}
+	return hex.EncodeToString(w.hash.Sum(nil)), nil
+}
+
+// verifyArchive 校验备份文件是否与清单一致
+func verifyArchive(path string, m *manifest) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != m.SHA256 {
+		return fmt.Errorf("checksum mismatch: archive %s, manifest %s", sum, m.SHA256)
+	}
+	return nil
+}
+
+// readArchive 逐行读取备份文件
+func readArchive(path string, fn func(e *entry) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(gz)
+	for {
+		var e entry
+		if err := dec.Decode(&e); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to decode archive: %w", err)
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+	}
+}
+
+// writeManifest 写入清单文件
+func writeManifest(path string, m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// readManifest 读取清单文件
+func readManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &m, nil
+}
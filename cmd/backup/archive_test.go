@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchive_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.jsonl.gz")
+
+	w, err := createArchive(path)
+	assert.NoError(t, err)
+	assert.NoError(t, w.write(&entry{Key: []byte("msg:1"), Value: []byte(`{"id":"1"}`)}))
+	assert.NoError(t, w.write(&entry{Table: "messages", Row: []byte(`{"id":"2"}`)}))
+	sum, err := w.close()
+	assert.NoError(t, err)
+
+	m := &manifest{SHA256: sum, Entries: w.entries}
+	assert.NoError(t, verifyArchive(path, m))
+
+	var entries []*entry
+	assert.NoError(t, readArchive(path, func(e *entry) error {
+		entries = append(entries, e)
+		return nil
+	}))
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "msg:1", string(entries[0].Key))
+	assert.Equal(t, "messages", entries[1].Table)
+
+	// 备份文件被修改后校验失败
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.Write([]byte("x"))
+	f.Close()
+	assert.Error(t, verifyArchive(path, m))
+}
+
+func TestParseS3URL(t *testing.T) {
+	bucket, key, ok := parseS3URL("s3://backups/im/full.jsonl.gz")
+	assert.True(t, ok)
+	assert.Equal(t, "backups", bucket)
+	assert.Equal(t, "im/full.jsonl.gz", key)
+
+	_, _, ok = parseS3URL("./full.jsonl.gz")
+	assert.False(t, ok)
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/s3"
+)
+
+// 备份与恢复消息存储
+//
+//	go run ./cmd/backup backup -target ./backups/full.jsonl.gz
+//	go run ./cmd/backup backup -target s3://bucket/im/inc.jsonl.gz -base ./backups/full.jsonl.gz.manifest.json
+//	go run ./cmd/backup restore -target ./backups/full.jsonl.gz
+//
+// 每个备份文件旁会生成 <target>.manifest.json 清单，记录校验和与增量起点。
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "backup" && os.Args[1] != "restore") {
+		fmt.Fprintln(os.Stderr, "Usage: backup <backup|restore> -target <path|s3://bucket/key> [flags]")
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	var (
+		configPath = flags.String("config", "config.yaml", "server config file")
+		target     = flags.String("target", "", "backup file path or s3://bucket/key")
+		since      = flags.String("since", "", "incremental backup: only messages at or after this time (unix seconds or RFC3339)")
+		base       = flags.String("base", "", "incremental backup: manifest of the previous backup, continues from its end")
+	)
+	flags.Parse(os.Args[2:])
+
+	if *target == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	if command == "backup" {
+		sinceTS, err := resolveSince(*since, *base)
+		if err != nil {
+			log.Fatalf("Invalid incremental start: %v", err)
+		}
+		if err := runBackup(cfg, *target, sinceTS); err != nil {
+			log.Fatalf("Backup failed: %v", err)
+		}
+	} else {
+		if err := runRestore(cfg, *target); err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+	}
+}
+
+// resolveSince 解析增量备份起点，base清单优先
+func resolveSince(since, base string) (int64, error) {
+	if base != "" {
+		path, cleanup, err := fetch(base)
+		if err != nil {
+			return 0, err
+		}
+		defer cleanup()
+
+		m, err := readManifest(path)
+		if err != nil {
+			return 0, err
+		}
+		return m.Until, nil
+	}
+	if since == "" {
+		return 0, nil
+	}
+	if ts, err := strconv.ParseInt(since, 10, 64); err == nil {
+		return ts, nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", since)
+	}
+	return t.Unix(), nil
+}
+
+// runBackup 备份当前配置的存储
+func runBackup(cfg *config.Config, target string, since int64) error {
+	bucket, key, isS3 := parseS3URL(target)
+	archivePath := target
+	if isS3 {
+		dir, err := os.MkdirTemp("", "im-backup")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		archivePath = filepath.Join(dir, "backup.jsonl.gz")
+	}
+
+	m := &manifest{
+		Store:     storeType(cfg),
+		CreatedAt: time.Now(),
+		Since:     since,
+		Until:     time.Now().Unix(),
+	}
+
+	w, err := createArchive(archivePath)
+	if err != nil {
+		return err
+	}
+	if m.Store == "leveldb" {
+		err = backupLevelDB(cfg, w, since)
+	} else {
+		err = backupMySQL(cfg, w, since)
+	}
+	sum, closeErr := w.close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	m.SHA256 = sum
+	m.Entries = w.entries
+
+	manifestPath := archivePath + ".manifest.json"
+	if err := writeManifest(manifestPath, m); err != nil {
+		return err
+	}
+
+	if isS3 {
+		client, err := s3.NewFromEnv()
+		if err != nil {
+			return err
+		}
+		if err := client.UploadFile(bucket, key, archivePath); err != nil {
+			return err
+		}
+		if err := client.UploadFile(bucket, key+".manifest.json", manifestPath); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Backed up %d %s entries to %s (sha256 %s)", m.Entries, m.Store, target, m.SHA256)
+	return nil
+}
+
+// backupLevelDB 在快照上导出LevelDB，增量备份只导出指定时间之后的消息
+func backupLevelDB(cfg *config.Config, w *archiveWriter, since int64) error {
+	db, err := store.NewLevelDBStore(cfg.Store.LevelDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.SnapshotEach(func(key, value []byte) error {
+		if since > 0 {
+			var message model.Message
+			if err := json.Unmarshal(value, &message); err == nil && message.Timestamp < since {
+				return nil
+			}
+		}
+		// 快照迭代器会复用缓冲区，需要复制后再写入
+		return w.write(&entry{
+			Key:   append([]byte(nil), key...),
+			Value: append([]byte(nil), value...),
+		})
+	})
+}
+
+// backupMySQL 在一致性读事务中导出IM相关表
+func backupMySQL(cfg *config.Config, w *archiveWriter, since int64) error {
+	db, err := store.NewMySQLStore(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.ExportTables(since, func(table string, row interface{}) error {
+		data, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		return w.write(&entry{Table: table, Row: data})
+	})
+}
+
+// runRestore 校验备份后写入当前配置的存储
+func runRestore(cfg *config.Config, target string) error {
+	manifestPath, cleanupManifest, err := fetch(target + ".manifest.json")
+	if err != nil {
+		return err
+	}
+	defer cleanupManifest()
+
+	m, err := readManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	if m.Store != storeType(cfg) {
+		return fmt.Errorf("backup is for %s store, configured store is %s", m.Store, storeType(cfg))
+	}
+
+	archivePath, cleanupArchive, err := fetch(target)
+	if err != nil {
+		return err
+	}
+	defer cleanupArchive()
+
+	if err := verifyArchive(archivePath, m); err != nil {
+		return err
+	}
+
+	var restored int64
+	if m.Store == "leveldb" {
+		restored, err = restoreLevelDB(cfg, archivePath)
+	} else {
+		restored, err = restoreMySQL(cfg, archivePath)
+	}
+	if err != nil {
+		return err
+	}
+	if restored != m.Entries {
+		return fmt.Errorf("restored %d entries, manifest lists %d", restored, m.Entries)
+	}
+
+	log.Printf("Restored %d %s entries from %s", restored, m.Store, target)
+	return nil
+}
+
+// restoreLevelDB 按批写入LevelDB键值
+func restoreLevelDB(cfg *config.Config, archivePath string) (int64, error) {
+	db, err := store.NewLevelDBStore(cfg.Store.LevelDBPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var (
+		restored     int64
+		keys, values [][]byte
+	)
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		if err := db.WriteBatch(keys, values); err != nil {
+			return fmt.Errorf("failed to write leveldb batch: %w", err)
+		}
+		restored += int64(len(keys))
+		keys, values = keys[:0], values[:0]
+		return nil
+	}
+
+	err = readArchive(archivePath, func(e *entry) error {
+		keys = append(keys, e.Key)
+		values = append(values, e.Value)
+		if len(keys) >= restoreBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return restored, err
+	}
+	return restored, flush()
+}
+
+// restoreMySQL 按表分批写入MySQL，备份中的表已按恢复顺序排列
+func restoreMySQL(cfg *config.Config, archivePath string) (int64, error) {
+	db, err := store.NewMySQLStore(&cfg.Database)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var (
+		restored int64
+		table    string
+		rows     []json.RawMessage
+	)
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := db.RestoreRows(table, rows); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", table, err)
+		}
+		restored += int64(len(rows))
+		rows = rows[:0]
+		return nil
+	}
+
+	err = readArchive(archivePath, func(e *entry) error {
+		if e.Table != table {
+			if err := flush(); err != nil {
+				return err
+			}
+			table = e.Table
+		}
+		rows = append(rows, e.Row)
+		if len(rows) >= restoreBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return restored, err
+	}
+	return restored, flush()
+}
+
+// restoreBatchSize 恢复时每批写入的条数
+const restoreBatchSize = 500
+
+// fetch 获取本地可读的文件路径，S3地址会先下载到临时目录
+func fetch(target string) (string, func(), error) {
+	bucket, key, isS3 := parseS3URL(target)
+	if !isS3 {
+		return target, func() {}, nil
+	}
+
+	client, err := s3.NewFromEnv()
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := os.MkdirTemp("", "im-restore")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	path := filepath.Join(dir, filepath.Base(key))
+	if err := client.DownloadFile(bucket, key, path); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return path, cleanup, nil
+}
+
+// storeType 获取配置的存储类型
+func storeType(cfg *config.Config) string {
+	if cfg.Store.Type == "leveldb" {
+		return "leveldb"
+	}
+	return "mysql"
+}
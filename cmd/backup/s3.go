@@ -0,0 +1,13 @@
+package main
+
+import "strings"
+
+// parseS3URL 解析 s3://bucket/key 格式的地址
+func parseS3URL(target string) (bucket, key string, ok bool) {
+	rest, found := strings.CutPrefix(target, "s3://")
+	if !found {
+		return "", "", false
+	}
+	bucket, key, _ = strings.Cut(rest, "/")
+	return bucket, key, bucket != "" && key != ""
+}
@@ -41,7 +41,7 @@ func NewClient(serverURL, userID string) (*Client, error) {
 
 func (c *Client) Login() error {
 	loginMsg := model.WebSocketMessage{
-		Type: "login",
+		Type: model.FrameLogin,
 		Data: map[string]interface{}{
 			"user_id":  c.userID,
 			"token":    "test_token",
@@ -60,7 +60,7 @@ func (c *Client) Login() error {
 
 func (c *Client) SendMessage(receiverID, content string) error {
 	msg := model.WebSocketMessage{
-		Type: "send_message",
+		Type: model.FrameSendMessage,
 		Data: model.SendMessageRequest{
 			ReceiverID: receiverID,
 			Type:       model.MessageTypeText,
@@ -79,7 +79,7 @@ func (c *Client) SendMessage(receiverID, content string) error {
 
 func (c *Client) SendHeartbeat() error {
 	heartbeat := model.WebSocketMessage{
-		Type: "heartbeat",
+		Type: model.FrameHeartbeat,
 		Data: model.HeartbeatRequest{
 			UserID: c.userID,
 		},
@@ -96,7 +96,7 @@ func (c *Client) SendHeartbeat() error {
 
 func (c *Client) SyncOfflineMessages() error {
 	syncMsg := model.WebSocketMessage{
-		Type: "sync_offline",
+		Type: model.FrameSyncOffline,
 		Data: model.SyncOfflineRequest{
 			LastMessageID: "",
 			Limit:         50,
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/model"
+)
+
+// parseCSV 解析通用CSV导出
+// 首行为列名，列顺序不限：timestamp（RFC3339或Unix秒）、sender、content必填，
+// receiver与group二选一，type为空时视为文本消息。
+// group列相同的消息归入同一群组，群成员为该群消息中出现过的所有发送者，群主为第一条消息的发送者
+func parseCSV(path, userPrefix string) (*export, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	return readCSV(f, userPrefix)
+}
+
+// readCSV 从reader解析通用CSV导出
+func readCSV(r io.Reader, userPrefix string) (*export, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"timestamp", "sender", "content"} {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("csv missing required column %q", name)
+		}
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := index[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	exp := &export{}
+	groups := make(map[string]*conversation)
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv line %d: %w", line, err)
+		}
+
+		ts, err := parseCSVTime(field(row, "timestamp"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		rec := &record{
+			Sender:  userPrefix + field(row, "sender"),
+			Type:    model.MessageType(field(row, "type")),
+			Content: field(row, "content"),
+			Time:    ts,
+		}
+		if rec.Type == "" {
+			rec.Type = model.MessageTypeText
+		}
+
+		if group := field(row, "group"); group != "" {
+			rec.Conversation = group
+			conv, exists := groups[group]
+			if !exists {
+				conv = &conversation{SourceID: group, Name: group, OwnerID: rec.Sender, Created: ts}
+				groups[group] = conv
+				exp.Conversations = append(exp.Conversations, conv)
+			}
+			if !containsString(conv.Members, rec.Sender) {
+				conv.Members = append(conv.Members, rec.Sender)
+			}
+		} else if receiver := field(row, "receiver"); receiver != "" {
+			rec.Receiver = userPrefix + receiver
+		} else {
+			return nil, fmt.Errorf("line %d: either receiver or group is required", line)
+		}
+
+		exp.Records = append(exp.Records, rec)
+	}
+
+	exp.sortRecords()
+	return exp, nil
+}
+
+// parseCSVTime 解析RFC3339或Unix秒格式的时间
+func parseCSVTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+	}
+	return t, nil
+}
+
+// containsString 判断切片是否包含字符串
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestParseSlackTS(t *testing.T) {
+	ts, err := parseSlackTS("1355517523.000005")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1355517523, 5000), ts)
+
+	_, err = parseSlackTS("bad")
+	assert.Error(t, err)
+}
+
+func TestReadCSV(t *testing.T) {
+	data := `timestamp,sender,receiver,group,content
+1640995300,bob,,team,second
+2022-01-01T00:00:00Z,alice,,team,first
+1640995400,alice,bob,,hi bob
+`
+	exp, err := readCSV(strings.NewReader(data), "ext_")
+	assert.NoError(t, err)
+
+	assert.Len(t, exp.Conversations, 1)
+	assert.Equal(t, []string{"ext_bob", "ext_alice"}, exp.Conversations[0].Members)
+
+	assert.Len(t, exp.Records, 3)
+	assert.Equal(t, "first", exp.Records[0].Content)
+	assert.Equal(t, "team", exp.Records[0].Conversation)
+	assert.Equal(t, model.MessageTypeText, exp.Records[0].Type)
+	assert.Equal(t, "ext_bob", exp.Records[2].Receiver)
+}
+
+func TestReadCSV_MissingTarget(t *testing.T) {
+	_, err := readCSV(strings.NewReader("timestamp,sender,content\n1640995300,bob,hello\n"), "")
+	assert.Error(t, err)
+}
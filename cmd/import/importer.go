@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+)
+
+// messageBackend 消息存储后端
+type messageBackend interface {
+	SaveMessage(*model.Message) error
+}
+
+// importer 将解析后的导出数据写入存储
+type importer struct {
+	backend    messageBackend
+	mysqlStore *store.MySQLStore // 为nil时不能创建群组，群消息被跳过
+	cp         *checkpoint
+	dryRun     bool
+	messageIDs idgen.Generator
+	groupIDs   idgen.Generator // 群组和群成员ID
+}
+
+// summary 导入结果统计
+type summary struct {
+	Groups   int
+	Messages int
+	Skipped  int
+	Users    int
+	From, To time.Time
+}
+
+// run 先创建群组再按时间顺序导入消息，每条写入后保存进度
+func (im *importer) run(exp *export) (*summary, error) {
+	sum := &summary{}
+	users := make(map[string]bool)
+
+	for _, conv := range exp.Conversations {
+		if _, done := im.cp.Groups[conv.SourceID]; done {
+			continue
+		}
+		sum.Groups++
+		if im.dryRun {
+			continue
+		}
+		if im.mysqlStore == nil {
+			log.Printf("Skipping group %s: group import requires the mysql store", conv.Name)
+			continue
+		}
+
+		groupID, err := im.createGroup(conv)
+		if err != nil {
+			return sum, err
+		}
+		im.cp.Groups[conv.SourceID] = groupID
+		if err := im.cp.save(); err != nil {
+			return sum, err
+		}
+	}
+
+	for i := im.cp.Imported; i < len(exp.Records); i++ {
+		rec := exp.Records[i]
+		users[rec.Sender] = true
+		if sum.From.IsZero() {
+			sum.From = rec.Time
+		}
+		sum.To = rec.Time
+
+		message := &model.Message{
+			SenderID:   rec.Sender,
+			ReceiverID: rec.Receiver,
+			Type:       rec.Type,
+			Content:    rec.Content,
+			Status:     model.MessageStatusRead,
+			Timestamp:  rec.Time.Unix(),
+			CreatedAt:  rec.Time,
+			UpdatedAt:  rec.Time,
+		}
+		if rec.Conversation != "" {
+			groupID, ok := im.cp.Groups[rec.Conversation]
+			if !ok && !im.dryRun {
+				sum.Skipped++
+				im.cp.Imported = i + 1
+				continue
+			}
+			message.GroupID = groupID
+		}
+
+		sum.Messages++
+		if im.dryRun {
+			continue
+		}
+
+		id, err := im.messageIDs.NextID()
+		if err != nil {
+			return sum, fmt.Errorf("failed to generate message ID: %w", err)
+		}
+		message.ID = id
+
+		if err := im.backend.SaveMessage(message); err != nil {
+			return sum, fmt.Errorf("failed to save message %d: %w", i, err)
+		}
+		im.cp.Imported = i + 1
+		if err := im.cp.save(); err != nil {
+			return sum, err
+		}
+	}
+
+	sum.Users = len(users)
+	return sum, nil
+}
+
+// createGroup 创建群组及成员，返回群组ID
+func (im *importer) createGroup(conv *conversation) (string, error) {
+	groupID, err := im.groupIDs.NextID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate group ID: %w", err)
+	}
+
+	members := conv.Members
+	if conv.OwnerID != "" && !containsString(members, conv.OwnerID) {
+		members = append(members, conv.OwnerID)
+	}
+
+	group := &model.Group{
+		ID:        groupID,
+		Name:      conv.Name,
+		OwnerID:   conv.OwnerID,
+		Mode:      model.GroupModeNormal,
+		Members:   members,
+		Settings:  model.GroupSettings{PostPolicy: model.PostPolicyAll},
+		CreatedAt: conv.Created,
+		UpdatedAt: conv.Created,
+	}
+	if err := im.mysqlStore.CreateGroup(group); err != nil {
+		return "", fmt.Errorf("failed to create group %s: %w", conv.Name, err)
+	}
+
+	for _, userID := range members {
+		memberID, err := im.groupIDs.NextID()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate member ID: %w", err)
+		}
+		member := &model.GroupMember{
+			ID:       memberID,
+			GroupID:  groupID,
+			UserID:   userID,
+			Role:     "member",
+			JoinedAt: conv.Created,
+		}
+		if userID == conv.OwnerID {
+			member.Role = "owner"
+		}
+		if err := im.mysqlStore.AddGroupMember(member); err != nil {
+			return "", fmt.Errorf("failed to add member to group %s: %w", conv.Name, err)
+		}
+	}
+	return groupID, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+)
+
+// 从其他聊天系统的导出数据导入历史消息
+//
+//	go run ./cmd/import -format slack -input ./slack-export
+//	go run ./cmd/import -format csv -input messages.csv -dry-run
+//
+// 导入进度写入 -state 文件，中断后使用相同参数重新执行即可从断点继续。
+func main() {
+	var (
+		configPath = flag.String("config", "config.yaml", "server config file")
+		format     = flag.String("format", "", "export format: slack or csv")
+		input      = flag.String("input", "", "export directory (slack) or file (csv)")
+		statePath  = flag.String("state", "", "checkpoint file, defaults to <input>.import-state.json")
+		dryRun     = flag.Bool("dry-run", false, "parse and report without writing")
+		userPrefix = flag.String("user-prefix", "", "prefix added to source user IDs")
+		machineID  = flag.Uint("machine-id", 255, "snowflake machine ID, must differ from running servers")
+	)
+	flag.Parse()
+
+	if *input == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *statePath == "" {
+		*statePath = *input + ".import-state.json"
+	}
+
+	var (
+		exp *export
+		err error
+	)
+	switch *format {
+	case "slack":
+		exp, err = parseSlack(*input, *userPrefix)
+	case "csv":
+		exp, err = parseCSV(*input, *userPrefix)
+	default:
+		log.Fatalf("Unsupported format %q, expected slack or csv", *format)
+	}
+	if err != nil {
+		log.Fatalf("Failed to parse export: %v", err)
+	}
+
+	cp, err := loadCheckpoint(*statePath)
+	if err != nil {
+		log.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if cp.Imported > 0 {
+		log.Printf("Resuming after %d of %d messages", cp.Imported, len(exp.Records))
+	}
+
+	im := &importer{cp: cp, dryRun: *dryRun}
+	if !*dryRun {
+		cfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		if err := idgen.Init(cfg.ID.Strategy, uint16(*machineID)); err != nil {
+			log.Fatalf("Failed to initialize ID generator: %v", err)
+		}
+		im.messageIDs = newIDGenerator(cfg.ID, config.IDComponentMessage, uint16(*machineID))
+		im.groupIDs = newIDGenerator(cfg.ID, config.IDComponentGroup, uint16(*machineID))
+
+		if cfg.Store.Type == "leveldb" {
+			leveldbStore, err := store.NewLevelDBStore(cfg.Store.LevelDBPath)
+			if err != nil {
+				log.Fatalf("Failed to open LevelDB store: %v", err)
+			}
+			defer leveldbStore.Close()
+			im.backend = leveldbStore
+		} else {
+			mysqlStore, err := store.NewMySQLStore(&cfg.Database)
+			if err != nil {
+				log.Fatalf("Failed to open MySQL store: %v", err)
+			}
+			defer mysqlStore.Close()
+			im.backend = mysqlStore
+			im.mysqlStore = mysqlStore
+		}
+	}
+
+	sum, err := im.run(exp)
+	if sum != nil {
+		mode := "Imported"
+		if *dryRun {
+			mode = "Would import"
+		}
+		fmt.Printf("%s %d groups, %d messages from %d users", mode, sum.Groups, sum.Messages, sum.Users)
+		if sum.Messages > 0 {
+			fmt.Printf(" (%s - %s)", sum.From.Format("2006-01-02"), sum.To.Format("2006-01-02"))
+		}
+		fmt.Println()
+		if sum.Skipped > 0 {
+			fmt.Printf("Skipped %d group messages without a target group\n", sum.Skipped)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+}
+
+// newIDGenerator 创建组件的ID生成器，使用命令行指定的机器ID，未单独配置的组件使用全局生成器
+func newIDGenerator(cfg config.IDConfig, component string, machineID uint16) idgen.Generator {
+	spec, ok := cfg.Override(component)
+	if !ok {
+		return idgen.Default()
+	}
+	generator, err := idgen.New(spec.Strategy, machineID)
+	if err != nil {
+		log.Fatalf("Failed to initialize %s ID generator: %v", component, err)
+	}
+	return generator
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/model"
+)
+
+// slackChannel Slack导出中的会话（channels/groups/mpims/dms）
+type slackChannel struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Creator string   `json:"creator"`
+	Created int64    `json:"created"`
+	Members []string `json:"members"`
+}
+
+// slackMessage Slack导出中的消息
+type slackMessage struct {
+	Type    string `json:"type"`
+	Subtype string `json:"subtype"`
+	User    string `json:"user"`
+	Text    string `json:"text"`
+	TS      string `json:"ts"`
+	Files   []struct {
+		Name       string `json:"name"`
+		URLPrivate string `json:"url_private"`
+	} `json:"files"`
+}
+
+// slackImportedSubtypes 需要导入的消息子类型，其余（入群、改名等）为系统事件，跳过
+var slackImportedSubtypes = map[string]bool{
+	"":                 true,
+	"bot_message":      true,
+	"file_share":       true,
+	"me_message":       true,
+	"thread_broadcast": true,
+}
+
+// parseSlack 解析Slack工作区导出目录
+// 公开频道、私有频道和多人私聊导入为群组，一对一私聊导入为私聊消息
+func parseSlack(dir, userPrefix string) (*export, error) {
+	exp := &export{}
+
+	for _, file := range []string{"channels.json", "groups.json", "mpims.json"} {
+		channels, err := readSlackChannels(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range channels {
+			conv := &conversation{
+				SourceID: ch.ID,
+				Name:     ch.Name,
+				OwnerID:  userPrefix + ch.Creator,
+				Created:  time.Unix(ch.Created, 0),
+			}
+			for _, member := range ch.Members {
+				conv.Members = append(conv.Members, userPrefix+member)
+			}
+			exp.Conversations = append(exp.Conversations, conv)
+
+			messages, err := readSlackHistory(dir, ch)
+			if err != nil {
+				return nil, err
+			}
+			for _, msg := range messages {
+				if rec := slackRecord(msg, userPrefix); rec != nil {
+					rec.Conversation = ch.ID
+					exp.Records = append(exp.Records, rec)
+				}
+			}
+		}
+	}
+
+	dms, err := readSlackChannels(filepath.Join(dir, "dms.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, dm := range dms {
+		if len(dm.Members) != 2 {
+			continue
+		}
+		messages, err := readSlackHistory(dir, dm)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range messages {
+			rec := slackRecord(msg, userPrefix)
+			if rec == nil {
+				continue
+			}
+			rec.Receiver = userPrefix + dm.Members[0]
+			if rec.Receiver == rec.Sender {
+				rec.Receiver = userPrefix + dm.Members[1]
+			}
+			exp.Records = append(exp.Records, rec)
+		}
+	}
+
+	exp.sortRecords()
+	return exp, nil
+}
+
+// readSlackChannels 读取会话列表，文件不存在时返回空（导出不一定包含所有类型）
+func readSlackChannels(path string) ([]*slackChannel, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var channels []*slackChannel
+	if err := json.Unmarshal(data, &channels); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return channels, nil
+}
+
+// readSlackHistory 读取会话目录下按天分割的消息文件，目录以会话名或ID命名
+func readSlackHistory(dir string, ch *slackChannel) ([]*slackMessage, error) {
+	var files []string
+	for _, name := range []string{ch.Name, ch.ID} {
+		if name == "" {
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, name, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) > 0 {
+			files = matches
+			break
+		}
+	}
+	sort.Strings(files)
+
+	var messages []*slackMessage
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		var day []*slackMessage
+		if err := json.Unmarshal(data, &day); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+		}
+		messages = append(messages, day...)
+	}
+	return messages, nil
+}
+
+// slackRecord 转换Slack消息，系统事件和无法识别发送者的消息返回nil
+func slackRecord(msg *slackMessage, userPrefix string) *record {
+	if msg.Type != "message" || !slackImportedSubtypes[msg.Subtype] || msg.User == "" {
+		return nil
+	}
+
+	ts, err := parseSlackTS(msg.TS)
+	if err != nil {
+		return nil
+	}
+
+	rec := &record{
+		Sender:  userPrefix + msg.User,
+		Type:    model.MessageTypeText,
+		Content: msg.Text,
+		Time:    ts,
+	}
+	if rec.Content == "" && len(msg.Files) > 0 {
+		rec.Type = model.MessageTypeFile
+		rec.Content = msg.Files[0].URLPrivate
+		if rec.Content == "" {
+			rec.Content = msg.Files[0].Name
+		}
+	}
+	if rec.Content == "" {
+		return nil
+	}
+	return rec
+}
+
+// parseSlackTS 解析Slack时间戳，格式为"秒.微秒"
+func parseSlackTS(ts string) (time.Time, error) {
+	sec, frac, _ := strings.Cut(ts, ".")
+	seconds, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid slack timestamp %q", ts)
+	}
+
+	var micros int64
+	if frac != "" {
+		frac = (frac + "000000")[:6]
+		if micros, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("invalid slack timestamp %q", ts)
+		}
+	}
+	return time.Unix(seconds, micros*1000), nil
+}
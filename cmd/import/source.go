@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/user/im/internal/model"
+)
+
+// conversation 导出数据中的群会话
+type conversation struct {
+	SourceID string
+	Name     string
+	OwnerID  string
+	Members  []string
+	Created  time.Time
+}
+
+// record 导出数据中的一条消息
+type record struct {
+	Sender       string
+	Receiver     string // 私聊接收者，群消息为空
+	Conversation string // 群会话的源标识，私聊为空
+	Type         model.MessageType
+	Content      string
+	Time         time.Time
+}
+
+// export 解析后的导出数据，消息按时间排序
+type export struct {
+	Conversations []*conversation
+	Records       []*record
+}
+
+// sortRecords 按时间稳定排序，保证多次解析同一导出得到相同顺序，断点续传依赖该顺序
+func (e *export) sortRecords() {
+	sort.SliceStable(e.Records, func(i, j int) bool {
+		return e.Records[i].Time.Before(e.Records[j].Time)
+	})
+}
+
+// checkpoint 导入进度，用于中断后继续导入
+type checkpoint struct {
+	Imported int               `json:"imported"` // 已导入的消息数
+	Groups   map[string]string `json:"groups"`   // 源会话标识 -> 群组ID
+	path     string
+}
+
+// loadCheckpoint 读取导入进度，文件不存在时从头开始
+func loadCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{Groups: make(map[string]string), path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	if cp.Groups == nil {
+		cp.Groups = make(map[string]string)
+	}
+	return cp, nil
+}
+
+// save 原子写入导入进度
+func (cp *checkpoint) save() error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return os.Rename(tmp, cp.path)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+// messageStore 可迁移消息的存储
+type messageStore interface {
+	ScanMessages(afterID string, limit int) ([]*model.Message, error)
+	SaveMessages(messages []*model.Message) error
+	GetMessage(messageID string) (*model.Message, error)
+	CountMessages() (int64, error)
+	Close() error
+}
+
+// groupStore 可迁移群组的存储
+type groupStore interface {
+	ScanGroups(afterID string, limit int) ([]*model.Group, error)
+	GetGroupMembers(groupID string) ([]*model.GroupMember, error)
+	SaveGroup(group *model.Group, members []*model.GroupMember) error
+}
+
+// 在存储后端之间迁移数据
+//
+//	go run ./cmd/migrate-store -from leveldb -to mysql
+//	go run ./cmd/migrate-store -from leveldb:/data/old -to leveldb:/data/new -verify 500
+//
+// 后端格式为 mysql 或 leveldb[:path]，mysql 使用配置文件中的数据库，leveldb 默认使用 store.leveldb_path。
+// 写入均为覆盖写，中断后重新执行（或用 -after 从上次输出的进度继续）不会产生重复数据。
+func main() {
+	var (
+		configPath = flag.String("config", "config.yaml", "server config file")
+		from       = flag.String("from", "", "source store: mysql or leveldb[:path]")
+		to         = flag.String("to", "", "destination store: mysql or leveldb[:path]")
+		batchSize  = flag.Int("batch", 500, "messages per batch")
+		after      = flag.String("after", "", "resume after this message ID")
+		verify     = flag.Int("verify", 100, "number of sampled messages to verify after copying, 0 to skip")
+	)
+	flag.Parse()
+
+	if *from == "" || *to == "" || *from == *to {
+		fmt.Fprintln(os.Stderr, "Usage: migrate-store -from <store> -to <store> [flags]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	src, err := openStore(cfg, *from)
+	if err != nil {
+		log.Fatalf("Failed to open source store: %v", err)
+	}
+	defer src.Close()
+
+	dst, err := openStore(cfg, *to)
+	if err != nil {
+		log.Fatalf("Failed to open destination store: %v", err)
+	}
+	defer dst.Close()
+
+	if err := migrateGroups(src, dst, *batchSize); err != nil {
+		log.Fatalf("Group migration failed: %v", err)
+	}
+
+	sample, err := migrateMessages(src, dst, *after, *batchSize, *verify)
+	if err != nil {
+		log.Fatalf("Message migration failed: %v", err)
+	}
+
+	if mismatches := verifySample(src, dst, sample); mismatches > 0 {
+		log.Fatalf("Verification failed: %d of %d sampled messages differ", mismatches, len(sample))
+	}
+	if len(sample) > 0 {
+		log.Printf("Verified %d sampled messages", len(sample))
+	}
+}
+
+// openStore 按 mysql 或 leveldb[:path] 打开存储
+func openStore(cfg *config.Config, spec string) (messageStore, error) {
+	kind, path, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "mysql":
+		return store.NewMySQLStore(&cfg.Database)
+	case "leveldb":
+		if path == "" {
+			path = cfg.Store.LevelDBPath
+		}
+		return store.NewLevelDBStore(path)
+	default:
+		return nil, fmt.Errorf("unknown store %q", spec)
+	}
+}
+
+// migrateGroups 迁移群组及成员，任一端不支持群组时跳过
+func migrateGroups(src, dst messageStore, batchSize int) error {
+	srcGroups, srcOK := src.(groupStore)
+	dstGroups, dstOK := dst.(groupStore)
+	if !srcOK {
+		return nil
+	}
+	if !dstOK {
+		log.Printf("Destination store does not support groups, skipping group migration")
+		return nil
+	}
+
+	var (
+		afterID string
+		copied  int
+	)
+	for {
+		groups, err := srcGroups.ScanGroups(afterID, batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to read groups: %w", err)
+		}
+		if len(groups) == 0 {
+			break
+		}
+
+		for _, group := range groups {
+			members, err := srcGroups.GetGroupMembers(group.ID)
+			if err != nil {
+				return fmt.Errorf("failed to read members of group %s: %w", group.ID, err)
+			}
+			if err := dstGroups.SaveGroup(group, members); err != nil {
+				return fmt.Errorf("failed to write group %s: %w", group.ID, err)
+			}
+		}
+		copied += len(groups)
+		afterID = groups[len(groups)-1].ID
+		log.Printf("Copied %d groups", copied)
+	}
+	return nil
+}
+
+// migrateMessages 按消息ID顺序分批复制消息，同时用蓄水池抽样记录待校验的消息ID
+func migrateMessages(src, dst messageStore, afterID string, batchSize, sampleSize int) ([]string, error) {
+	total, err := src.CountMessages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count source messages: %w", err)
+	}
+
+	var (
+		copied int64
+		sample []string
+		rng    = rand.New(rand.NewSource(time.Now().UnixNano()))
+		start  = time.Now()
+	)
+	for {
+		messages, err := src.ScanMessages(afterID, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read messages after %q: %w", afterID, err)
+		}
+		if len(messages) == 0 {
+			break
+		}
+		if err := dst.SaveMessages(messages); err != nil {
+			return nil, fmt.Errorf("failed to write messages after %q: %w", afterID, err)
+		}
+
+		for _, message := range messages {
+			copied++
+			if len(sample) < sampleSize {
+				sample = append(sample, message.ID)
+			} else if j := rng.Int63n(copied); j < int64(sampleSize) {
+				sample[j] = message.ID
+			}
+		}
+		afterID = messages[len(messages)-1].ID
+
+		rate := float64(copied) / time.Since(start).Seconds()
+		log.Printf("Copied %d/%d messages (%.0f/s), last ID %s", copied, total, rate, afterID)
+	}
+	return sample, nil
+}
+
+// verifySample 比对抽样消息在两端是否一致，返回不一致的数量
+func verifySample(src, dst messageStore, sample []string) int {
+	mismatches := 0
+	for _, id := range sample {
+		want, err := src.GetMessage(id)
+		if err != nil {
+			log.Printf("Failed to read source message %s: %v", id, err)
+			mismatches++
+			continue
+		}
+		got, err := dst.GetMessage(id)
+		if err != nil {
+			log.Printf("Message %s missing in destination: %v", id, err)
+			mismatches++
+			continue
+		}
+		if !sameMessage(want, got) {
+			log.Printf("Message %s differs between source and destination", id)
+			mismatches++
+		}
+	}
+	return mismatches
+}
+
+// sameMessage 比较消息内容，忽略存储层维护的创建和更新时间
+func sameMessage(a, b *model.Message) bool {
+	return a.ID == b.ID &&
+		a.SenderID == b.SenderID &&
+		a.ReceiverID == b.ReceiverID &&
+		a.GroupID == b.GroupID &&
+		a.Type == b.Type &&
+		a.Content == b.Content &&
+		a.Status == b.Status &&
+		a.Timestamp == b.Timestamp
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/user/im/internal/model"
+)
+
+// 根据帧注册表生成WebSocket帧协议文档
+//
+//	go run ./cmd/protodoc -o docs/api/protocol.md
+//
+// 通常经由 internal/model 中的 go:generate 调用：go generate ./internal/model
+func main() {
+	output := flag.String("o", "", "output file, stdout if empty")
+	flag.Parse()
+
+	var buf bytes.Buffer
+	if err := model.WriteProtocolDoc(&buf); err != nil {
+		log.Fatalf("Failed to render protocol doc: %v", err)
+	}
+	if *output == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), 0o644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *output, err)
+	}
+}
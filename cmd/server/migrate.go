@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// runMigrate 执行数据库迁移子命令：up（默认）执行所有未执行的迁移，down回滚最后一次迁移，status查看未执行的迁移
+func runMigrate(cfg *config.Config, args []string) error {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	mysqlStore, err := store.OpenMySQLStore(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer mysqlStore.Close()
+
+	switch action {
+	case "up":
+		if err := mysqlStore.Migrate(); err != nil {
+			return err
+		}
+		logger.Info("Database schema is up to date")
+	case "down":
+		if err := mysqlStore.RollbackLast(); err != nil {
+			return err
+		}
+		logger.Info("Rolled back last migration")
+	case "status":
+		pending, err := mysqlStore.PendingMigrations()
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Println("Database schema is up to date")
+		} else {
+			fmt.Printf("%d pending migrations:\n  %s\n", len(pending), strings.Join(pending, "\n  "))
+		}
+	default:
+		return fmt.Errorf("unknown migrate action %q, expected up, down or status", action)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 字段命名转换
+// 只转换对象的字段名，以用户ID、节点ID、配置名等数据为键的对象保留原有的键，只转换其中的值；
+// 客户端原样存储的数据不转换
+
+// dataKeyFields 值为以数据为键的对象的字段
+var dataKeyFields = map[string]bool{
+	"features":   true,
+	"flags":      true,
+	"health":     true,
+	"kafka_lag":  true,
+	"params":     true,
+	"probes":     true,
+	"transports": true,
+}
+
+// rawFields 值为客户端原样存储的数据的字段，如用户设置的值
+var rawFields = map[string]bool{
+	"values": true,
+}
+
+// convertKeys 按rename转换value中所有对象的字段名
+func convertKeys(value interface{}, rename func(string) string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			snake := toSnake(key)
+			switch {
+			case rawFields[snake]:
+			case dataKeyFields[snake]:
+				child = convertValues(child, rename)
+			default:
+				child = convertKeys(child, rename)
+			}
+			converted[rename(key)] = child
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, child := range v {
+			converted[i] = convertKeys(child, rename)
+		}
+		return converted
+	default:
+		return value
+	}
+}
+
+// convertValues 保留以数据为键的对象的键，转换其中的值
+func convertValues(value interface{}, rename func(string) string) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return convertKeys(value, rename)
+	}
+	converted := make(map[string]interface{}, len(m))
+	for key, child := range m {
+		converted[key] = convertKeys(child, rename)
+	}
+	return converted
+}
+
+// toCamel 把snake命名转换为camel命名，如message_id转换为messageId；只转换由小写字母、数字和下划线组成的字段名
+func toCamel(name string) string {
+	if !strings.Contains(name, "_") || strings.IndexFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_')
+	}) >= 0 {
+		return name
+	}
+
+	// 只合并下划线后的小写字母，其他下划线保留，转换回snake命名时不丢失
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '_' && i > 0 && i+1 < len(name) && name[i+1] >= 'a' && name[i+1] <= 'z' {
+			i++
+			b.WriteByte(name[i] - 'a' + 'A')
+			continue
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// toSnake 把camel命名转换为snake命名，如messageId、messageID都转换为message_id；已是snake命名的字段名不变
+func toSnake(name string) string {
+	if strings.IndexFunc(name, unicode.IsUpper) < 0 || strings.IndexFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) >= 0 {
+		return name
+	}
+
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// 大写字母开始一个新单词，连续的大写字母视为一个缩写，如userID、HTTPServer
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
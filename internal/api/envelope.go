@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/user/im/internal/service"
+)
+
+// v2的错误码，处理函数返回的业务错误沿用service中的错误码
+const (
+	ErrCodeInvalidRequest     = service.ErrCodeInvalidRequest
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeInternal           = "internal"
+)
+
+// Envelope v2的响应信封，成功时返回data，列表接口同时返回pagination，失败时只返回error
+type Envelope struct {
+	Data       interface{} `json:"data,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+	Error      *Error      `json:"error,omitempty"`
+}
+
+// Pagination 分页信息，游标分页返回next_cursor，偏移分页返回total
+type Pagination struct {
+	NextCursor interface{} `json:"next_cursor,omitempty"`
+	HasMore    bool        `json:"has_more"`
+	Total      interface{} `json:"total,omitempty"`
+}
+
+// Error v2的错误
+type Error struct {
+	Code       string                 `json:"code"`
+	Message    string                 `json:"message"`
+	RetryAfter interface{}            `json:"retry_after,omitempty"` // 限流时客户端重试前等待的秒数
+	Details    map[string]interface{} `json:"details,omitempty"`     // v1错误响应中的其他字段
+}
+
+// statusErrorCodes 处理函数未返回错误码时按HTTP状态码推导
+var statusErrorCodes = map[int]string{
+	400: service.ErrCodeInvalidRequest,
+	401: service.ErrCodeUnauthenticated,
+	403: service.ErrCodeForbidden,
+	404: service.ErrCodeNotFound,
+	409: service.ErrCodeConflict,
+	429: service.ErrCodeRateLimited,
+}
+
+// convertResponse 把处理函数返回的v1响应转换为v2信封，camel为true时同时转换字段命名
+func convertResponse(status int, body []byte, camel bool) ([]byte, error) {
+	value, err := decodeJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	envelope := toEnvelope(status, value)
+	if !camel {
+		return json.Marshal(envelope)
+	}
+
+	// 先按snake命名编码信封，再统一转换
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	if value, err = decodeJSON(data); err != nil {
+		return nil, err
+	}
+	return json.Marshal(convertKeys(value, toCamel))
+}
+
+// toEnvelope 按状态码把v1响应放入信封
+// 成功响应去掉success字段，带has_more或next_cursor的列表响应把分页字段移入pagination，其余字段作为data；
+// 失败响应的error和code作为错误信息，其余字段放入details
+func toEnvelope(status int, value interface{}) *Envelope {
+	body, ok := value.(map[string]interface{})
+	if !ok {
+		if status >= 400 {
+			return &Envelope{Error: &Error{Code: statusErrorCode(status), Details: map[string]interface{}{"body": value}}}
+		}
+		return &Envelope{Data: value}
+	}
+
+	if status >= 400 {
+		e := &Error{Code: statusErrorCode(status)}
+		if code, ok := body["code"].(string); ok && code != "" {
+			e.Code = code
+		}
+		if message, ok := body["error"].(string); ok {
+			e.Message = message
+		}
+		e.RetryAfter = body["retry_after"]
+		for key, v := range body {
+			switch key {
+			case "error", "code", "retry_after":
+				continue
+			}
+			if e.Details == nil {
+				e.Details = make(map[string]interface{})
+			}
+			e.Details[key] = v
+		}
+		return &Envelope{Error: e}
+	}
+
+	envelope := &Envelope{}
+	_, hasMore := body["has_more"]
+	_, hasCursor := body["next_cursor"]
+	if hasMore || hasCursor {
+		page := &Pagination{NextCursor: body["next_cursor"], Total: body["total"]}
+		if more, ok := body["has_more"].(bool); ok {
+			page.HasMore = more
+		} else if cursor, ok := body["next_cursor"].(string); ok {
+			page.HasMore = cursor != ""
+		}
+		if cursor, ok := page.NextCursor.(string); ok && cursor == "" {
+			page.NextCursor = nil
+		}
+		envelope.Pagination = page
+		delete(body, "has_more")
+		delete(body, "next_cursor")
+		delete(body, "total")
+	}
+	delete(body, "success")
+	envelope.Data = body
+	return envelope
+}
+
+// statusErrorCode HTTP状态码对应的错误码
+func statusErrorCode(status int) string {
+	if code, ok := statusErrorCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return ErrCodeInternal
+	}
+	return service.ErrCodeInvalidRequest
+}
+
+// decodeJSON 解析JSON，数字保持原样，避免int64的ID和时间戳丢失精度
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
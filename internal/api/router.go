@@ -0,0 +1,252 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+	"github.com/user/im/pkg/logger"
+	"github.com/user/im/pkg/realip"
+)
+
+// userIDHeader 用户请求标识当前用户的请求头
+const userIDHeader = "X-User-ID"
+
+// TokenParser 校验IM令牌
+type TokenParser interface {
+	Parse(token string) (*model.TokenClaims, error)
+}
+
+// RequestCounter 按客户端累加窗口内的请求数，返回当前计数和窗口剩余时间
+type RequestCounter interface {
+	IncrHTTPRequests(client string, window time.Duration) (int64, time.Duration, error)
+}
+
+// RouterOptions 中间件的依赖，为nil时对应的中间件不启用
+type RouterOptions struct {
+	Tokens  TokenParser
+	Counter RequestCounter
+	// RealIP 按可信代理解析客户端IP，nil时使用对端地址
+	RealIP *realip.Resolver
+}
+
+// Router HTTP路由，全局中间件作用于所有请求，认证和限流只作用于APIGroup创建的用户API分组
+type Router struct {
+	*gin.Engine
+	api []gin.HandlerFunc
+}
+
+// NewRouter 按配置设置gin运行模式并组装中间件，关闭的中间件不注册
+// 顺序为 recovery、real_ip、metrics、access_log、cors，用户API分组中为 auth、rate_limit
+func NewRouter(cfg config.HTTPConfig, opts RouterOptions) *Router {
+	if cfg.Mode != "" {
+		gin.SetMode(cfg.Mode)
+	}
+	r := &Router{Engine: gin.New()}
+	// gin默认信任所有代理，客户端IP统一由real_ip中间件按可信代理解析
+	r.SetTrustedProxies(nil)
+
+	if cfg.MiddlewareEnabled(config.MiddlewareRecovery) {
+		r.Use(recovery())
+	}
+	if opts.RealIP != nil {
+		r.Use(realIP(opts.RealIP))
+	}
+	if cfg.MiddlewareEnabled(config.MiddlewareMetrics) {
+		r.Use(requestMetrics())
+	}
+	if cfg.MiddlewareEnabled(config.MiddlewareAccessLog) {
+		r.Use(accessLog())
+	}
+	if cfg.MiddlewareEnabled(config.MiddlewareCORS) && len(cfg.CORS.AllowedOrigins) > 0 {
+		r.Use(cors(cfg.CORS))
+	}
+
+	if cfg.MiddlewareEnabled(config.MiddlewareAuth) && opts.Tokens != nil {
+		r.api = append(r.api, userAuth(opts.Tokens, cfg.RequireToken))
+	}
+	if cfg.MiddlewareEnabled(config.MiddlewareRateLimit) && opts.Counter != nil && cfg.RateLimit.Requests > 0 {
+		r.api = append(r.api, rateLimit(opts.Counter, cfg.RateLimit))
+	}
+	return r
+}
+
+// APIGroup 创建用户API分组，handlers（如版本协商）在认证和限流之前执行，拒绝的响应同样按版本转换
+func (r *Router) APIGroup(path string, handlers ...gin.HandlerFunc) *gin.RouterGroup {
+	return r.Group(path, append(append([]gin.HandlerFunc(nil), handlers...), r.api...)...)
+}
+
+// recovery 处理函数panic时记录日志并返回500，客户端断开导致的写失败不返回响应
+func recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err interface{}) {
+		logger.Error("Panic recovered in HTTP handler",
+			logger.String("method", c.Request.Method),
+			logger.String("path", c.Request.URL.Path),
+			logger.Any("panic", err))
+		c.AbortWithStatusJSON(500, gin.H{"error": "Internal server error"})
+	})
+}
+
+// realIP 解析客户端IP并保存到请求上下文，后续的中间件和WebSocket握手直接使用
+func realIP(resolver *realip.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(realip.NewContext(c.Request.Context(), resolver.ClientIP(c.Request)))
+		c.Next()
+	}
+}
+
+// clientIP 请求的客户端IP，未启用real_ip中间件时为对端地址
+func clientIP(c *gin.Context) string {
+	if ip, ok := realip.FromContext(c.Request.Context()); ok {
+		return ip
+	}
+	return realip.PeerIP(c.Request)
+}
+
+// routeLabel 指标和日志中的路由，使用路由模板避免路径参数导致标签过多
+func routeLabel(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+// requestMetrics 统计请求数和处理耗时
+func requestMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := routeLabel(c)
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPRequestSeconds.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// accessLog 请求完成后记录访问日志，服务端错误以Warn级别记录
+func accessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		log := logger.Info
+		if status >= 500 {
+			log = logger.Warn
+		}
+		log("HTTP request",
+			logger.String("method", c.Request.Method),
+			logger.String("path", c.Request.URL.Path),
+			logger.String("route", routeLabel(c)),
+			logger.Int("status", status),
+			logger.Int("size", c.Writer.Size()),
+			logger.Int64("duration_ms", time.Since(start).Milliseconds()),
+			logger.String("client_ip", clientIP(c)),
+			logger.String("user_id", c.GetHeader(userIDHeader)))
+	}
+}
+
+// cors 为允许的来源添加跨域响应头，预检请求直接返回204
+func cors(cfg config.CORSConfig) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.FormatInt(int64(cfg.MaxAge/time.Second), 10)
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+		if !allowAll && !allowed[origin] {
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Access-Control-Expose-Headers", "Retry-After, "+VersionHeader)
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			c.Header("Access-Control-Allow-Headers", headers)
+			c.Header("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// userAuth 校验带X-User-ID的用户请求携带的IM令牌，令牌用户必须与X-User-ID一致
+// 未带X-User-ID的请求（登录、API密钥调用等）由各自的处理函数认证；未开启requireToken时允许不带令牌
+func userAuth(tokens TokenParser, requireToken bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader(userIDHeader)
+		if userID == "" {
+			c.Next()
+			return
+		}
+
+		auth := c.GetHeader("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			if requireToken {
+				c.AbortWithStatusJSON(401, gin.H{"error": "Token required"})
+				return
+			}
+			c.Next()
+			return
+		}
+		claims, err := tokens.Parse(strings.TrimPrefix(auth, "Bearer "))
+		if err != nil {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid token"})
+			return
+		}
+		if claims.Subject != userID {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Token does not match user"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimit 按用户限制窗口内的请求数，未带X-User-ID时按客户端IP；Redis不可用时放行
+func rateLimit(counter RequestCounter, cfg config.HTTPRateLimitConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := "ip:" + clientIP(c)
+		if userID := c.GetHeader(userIDHeader); userID != "" {
+			client = "user:" + userID
+		}
+
+		count, remaining, err := counter.IncrHTTPRequests(client, cfg.Window)
+		if err != nil {
+			logger.Warn("Failed to check http rate limit", logger.String("client", client), logger.ErrorField(err))
+			c.Next()
+			return
+		}
+		if count > int64(cfg.Requests) {
+			metrics.HTTPRateLimited.Inc()
+			retryAfter := int64((remaining + time.Second - 1) / time.Second)
+			c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+			c.AbortWithStatusJSON(429, gin.H{
+				"error":       "Too many requests",
+				"code":        service.ErrCodeRateLimited,
+				"retry_after": retryAfter,
+			})
+			return
+		}
+		c.Next()
+	}
+}
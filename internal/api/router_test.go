@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/realip"
+)
+
+type fakeTokens struct{}
+
+func (fakeTokens) Parse(token string) (*model.TokenClaims, error) {
+	if token != "valid-u1" {
+		return nil, errors.New("invalid token")
+	}
+	return &model.TokenClaims{Subject: "u1"}, nil
+}
+
+type fakeCounter struct {
+	counts map[string]int64
+}
+
+func (f *fakeCounter) IncrHTTPRequests(client string, window time.Duration) (int64, time.Duration, error) {
+	f.counts[client]++
+	return f.counts[client], 30 * time.Second, nil
+}
+
+func newMiddlewareTestRouter(cfg config.HTTPConfig, counter *fakeCounter) *Router {
+	router := NewRouter(cfg, RouterOptions{Tokens: fakeTokens{}, Counter: counter})
+	router.APIGroup("/api").GET("/ping", func(c *gin.Context) {
+		c.JSON(200, gin.H{"success": true})
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return router
+}
+
+func TestRouterAuth(t *testing.T) {
+	cfg := config.HTTPConfig{Mode: gin.TestMode, RequireToken: true}
+	router := newMiddlewareTestRouter(cfg, &fakeCounter{counts: map[string]int64{}})
+
+	w, _ := serve(router.Engine, "GET", "/api/ping", "", nil)
+	assert.Equal(t, 200, w.Code)
+	w, body := serve(router.Engine, "GET", "/api/ping", "", map[string]string{"X-User-ID": "u1"})
+	assert.Equal(t, 401, w.Code)
+	assert.Equal(t, "Token required", body["error"])
+	w, _ = serve(router.Engine, "GET", "/api/ping", "", map[string]string{"X-User-ID": "u2", "Authorization": "Bearer valid-u1"})
+	assert.Equal(t, 403, w.Code)
+	w, _ = serve(router.Engine, "GET", "/api/ping", "", map[string]string{"X-User-ID": "u1", "Authorization": "Bearer valid-u1"})
+	assert.Equal(t, 200, w.Code)
+
+	cfg.DisabledMiddlewares = []string{config.MiddlewareAuth}
+	router = newMiddlewareTestRouter(cfg, &fakeCounter{counts: map[string]int64{}})
+	w, _ = serve(router.Engine, "GET", "/api/ping", "", map[string]string{"X-User-ID": "u1"})
+	assert.Equal(t, 200, w.Code)
+}
+
+func TestRouterRateLimit(t *testing.T) {
+	cfg := config.HTTPConfig{Mode: gin.TestMode, RateLimit: config.HTTPRateLimitConfig{Requests: 2, Window: time.Minute}}
+	counter := &fakeCounter{counts: map[string]int64{}}
+	router := newMiddlewareTestRouter(cfg, counter)
+
+	header := map[string]string{"X-User-ID": "u1"}
+	for i := 0; i < 2; i++ {
+		w, _ := serve(router.Engine, "GET", "/api/ping", "", header)
+		assert.Equal(t, 200, w.Code)
+	}
+	w, body := serve(router.Engine, "GET", "/api/ping", "", header)
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Equal(t, "rate_limited", body["code"])
+	assert.Equal(t, int64(3), counter.counts["user:u1"])
+
+	// 全局路由不限流
+	w, _ = serve(router.Engine, "GET", "/panic", "", header)
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, int64(3), counter.counts["user:u1"])
+}
+
+func TestRouterRealIP(t *testing.T) {
+	cfg := config.HTTPConfig{Mode: gin.TestMode, RateLimit: config.HTTPRateLimitConfig{Requests: 10, Window: time.Minute}}
+	forwarded := map[string]string{"X-Forwarded-For": "198.51.100.7"}
+
+	// httptest请求的对端地址为192.0.2.1
+	resolver, err := realip.New([]string{"192.0.2.0/24"})
+	assert.NoError(t, err)
+	counter := &fakeCounter{counts: map[string]int64{}}
+	router := NewRouter(cfg, RouterOptions{Counter: counter, RealIP: resolver})
+	router.APIGroup("/api").GET("/ping", func(c *gin.Context) {
+		c.JSON(200, gin.H{"client_ip": clientIP(c)})
+	})
+	w, body := serve(router.Engine, "GET", "/api/ping", "", forwarded)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "198.51.100.7", body["client_ip"])
+	assert.Equal(t, int64(1), counter.counts["ip:198.51.100.7"])
+
+	// 对端不是可信代理时忽略X-Forwarded-For
+	resolver, err = realip.New([]string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+	counter = &fakeCounter{counts: map[string]int64{}}
+	router = NewRouter(cfg, RouterOptions{Counter: counter, RealIP: resolver})
+	router.APIGroup("/api").GET("/ping", func(c *gin.Context) {
+		c.JSON(200, gin.H{"client_ip": clientIP(c)})
+	})
+	_, body = serve(router.Engine, "GET", "/api/ping", "", forwarded)
+	assert.Equal(t, "192.0.2.1", body["client_ip"])
+	assert.Equal(t, int64(1), counter.counts["ip:192.0.2.1"])
+}
+
+func TestRouterCORS(t *testing.T) {
+	cfg := config.HTTPConfig{Mode: gin.TestMode, CORS: config.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedHeaders: []string{"Content-Type", "X-User-ID"},
+		MaxAge:         10 * time.Minute,
+	}}
+	router := newMiddlewareTestRouter(cfg, &fakeCounter{counts: map[string]int64{}})
+
+	w, _ := serve(router.Engine, "OPTIONS", "/api/ping", "", map[string]string{
+		"Origin":                        "https://app.example.com",
+		"Access-Control-Request-Method": "GET",
+	})
+	assert.Equal(t, 204, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Content-Type, X-User-ID", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	w, _ = serve(router.Engine, "GET", "/api/ping", "", map[string]string{"Origin": "https://evil.example.com"})
+	assert.Equal(t, 200, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
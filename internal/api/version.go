@@ -0,0 +1,231 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/config"
+)
+
+// Version API版本
+// 各版本共用同一组处理函数，处理函数按v1格式读写JSON，由版本协商层转换其他版本的请求和响应；
+// 之后不兼容的DTO变更在这里为新版本增加转换，已有版本的客户端不受影响
+type Version int
+
+const (
+	V1 Version = 1 // 原有格式
+	V2 Version = 2 // 统一的data/pagination/error信封，字段命名可配置
+)
+
+// Latest 最新的API版本
+const Latest = V2
+
+// VersionHeader 请求指定版本、响应返回实际使用的版本的请求头
+const VersionHeader = "X-API-Version"
+
+// versionKey 当前请求使用的版本在gin.Context中的键
+const versionKey = "api_version"
+
+// mediaTypePrefix 在Accept中指定版本的媒体类型前缀，如application/vnd.im.v2+json
+const mediaTypePrefix = "application/vnd.im.v"
+
+// String 版本号，如v2
+func (v Version) String() string {
+	return "v" + strconv.Itoa(int(v))
+}
+
+// Supported 是否为支持的版本
+func (v Version) Supported() bool {
+	return v >= V1 && v <= Latest
+}
+
+// ParseVersion 解析版本号，接受2和v2两种写法
+func ParseVersion(raw string) (Version, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(raw)), "v"))
+	if err != nil || !Version(n).Supported() {
+		return 0, fmt.Errorf("unsupported api version: %s", raw)
+	}
+	return Version(n), nil
+}
+
+// VersionOf 当前请求使用的版本，未经过版本协商的请求视为v1
+func VersionOf(c *gin.Context) Version {
+	if v, ok := c.Get(versionKey); ok {
+		return v.(Version)
+	}
+	return V1
+}
+
+// Negotiator API版本协商
+type Negotiator struct {
+	defaultVersion Version
+	fieldCase      string
+}
+
+// NewNegotiator 创建版本协商层，默认版本不受支持时返回错误
+func NewNegotiator(cfg config.APIConfig) (*Negotiator, error) {
+	v := Version(cfg.DefaultVersion)
+	if !v.Supported() {
+		return nil, fmt.Errorf("unsupported api default version: %d", cfg.DefaultVersion)
+	}
+	return &Negotiator{defaultVersion: v, fieldCase: cfg.FieldCase}, nil
+}
+
+// Pin 路径中带版本号的路由组（/api/v1、/api/v2）使用固定的版本，忽略请求头
+func (n *Negotiator) Pin(v Version) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		n.serve(c, v)
+	}
+}
+
+// Negotiate 未带版本号的路由组（/api）按X-API-Version请求头、Accept中的版本依次选择，都没有时使用默认版本；
+// 请求的版本不受支持时返回406
+func (n *Negotiator) Negotiate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, err := n.requestedVersion(c.Request)
+		if err != nil {
+			c.Header(VersionHeader, Latest.String())
+			writeJSON(c.Writer, http.StatusNotAcceptable, &Envelope{
+				Error: &Error{Code: ErrCodeUnsupportedVersion, Message: err.Error()},
+			})
+			c.Abort()
+			return
+		}
+		n.serve(c, v)
+	}
+}
+
+// requestedVersion 请求中指定的版本
+func (n *Negotiator) requestedVersion(req *http.Request) (Version, error) {
+	if raw := req.Header.Get(VersionHeader); raw != "" {
+		return ParseVersion(raw)
+	}
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(accept, ";", 2)[0])
+		if strings.HasPrefix(mediaType, mediaTypePrefix) {
+			return ParseVersion(strings.TrimSuffix(strings.TrimPrefix(mediaType, mediaTypePrefix), "+json"))
+		}
+	}
+	return n.defaultVersion, nil
+}
+
+// serve 以版本v执行后续处理函数，v1原样返回，其他版本缓存响应并转换
+func (n *Negotiator) serve(c *gin.Context, v Version) {
+	c.Set(versionKey, v)
+	c.Header(VersionHeader, v.String())
+	if v == V1 {
+		c.Next()
+		return
+	}
+
+	camel := n.fieldCase == config.FieldCaseCamel
+	if camel {
+		if err := convertRequestBody(c.Request); err != nil {
+			writeJSON(c.Writer, http.StatusBadRequest, &Envelope{
+				Error: &Error{Code: ErrCodeInvalidRequest, Message: err.Error()},
+			})
+			c.Abort()
+			return
+		}
+	}
+
+	w := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+	c.Writer = w
+	c.Next()
+	c.Writer = w.ResponseWriter
+
+	body := w.body.Bytes()
+	if len(body) > 0 && isJSON(w.Header().Get("Content-Type")) {
+		if converted, err := convertResponse(w.status, body, camel); err == nil {
+			body = converted
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	if len(body) > 0 {
+		w.ResponseWriter.Write(body)
+	}
+}
+
+// convertRequestBody 把camel命名的JSON请求体转换为处理函数使用的snake命名，无法解析的请求体由处理函数返回错误
+func convertRequestBody(req *http.Request) error {
+	if req.Body == nil || !isJSON(req.Header.Get("Content-Type")) {
+		return nil
+	}
+	raw, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	if value, err := decodeJSON(raw); err == nil {
+		if converted, err := json.Marshal(convertKeys(value, toSnake)); err == nil {
+			raw = converted
+		}
+	}
+	req.Body = io.NopCloser(bytes.NewReader(raw))
+	req.ContentLength = int64(len(raw))
+	return nil
+}
+
+// isJSON 内容类型是否为JSON
+func isJSON(contentType string) bool {
+	return strings.HasPrefix(strings.TrimSpace(contentType), "application/json")
+}
+
+// writeJSON 直接写出JSON响应
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	data, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// bufferedWriter 缓存处理函数写入的状态码和响应体，由版本协商层转换后写出
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status  int
+	body    bytes.Buffer
+	written bool
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// Flush 响应在转换后一次写出，忽略处理函数的刷新
+func (w *bufferedWriter) Flush() {}
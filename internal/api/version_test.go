@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+)
+
+func newTestRouter(t *testing.T, cfg config.APIConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	negotiator, err := NewNegotiator(cfg)
+	assert.NoError(t, err)
+
+	router := gin.New()
+	register := func(group *gin.RouterGroup) {
+		group.GET("/messages", func(c *gin.Context) {
+			c.JSON(200, gin.H{
+				"messages":    []gin.H{{"message_id": "m1", "sender_id": "u1"}},
+				"next_cursor": "m1",
+				"has_more":    true,
+			})
+		})
+		group.POST("/messages", func(c *gin.Context) {
+			var req struct {
+				ReceiverID string `json:"receiver_id"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil || req.ReceiverID == "" {
+				c.JSON(400, gin.H{"error": "receiver_id required"})
+				return
+			}
+			c.JSON(200, gin.H{"success": true, "message_id": "m2", "receiver_id": req.ReceiverID})
+		})
+		group.GET("/settings", func(c *gin.Context) {
+			c.JSON(200, gin.H{"settings": gin.H{"updated_at": 1, "values": gin.H{"font_size": 14}}})
+		})
+		group.GET("/limited", func(c *gin.Context) {
+			c.JSON(429, gin.H{"error": "slow down", "code": "rate_limited", "retry_after": 5})
+		})
+		group.GET("/version", func(c *gin.Context) {
+			c.String(200, VersionOf(c).String())
+		})
+	}
+	register(router.Group("/api/v1", negotiator.Pin(V1)))
+	register(router.Group("/api/v2", negotiator.Pin(V2)))
+	register(router.Group("/api", negotiator.Negotiate()))
+	return router
+}
+
+func serve(router *gin.Engine, method, path, body string, header map[string]string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var decoded map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &decoded)
+	return w, decoded
+}
+
+func TestNegotiator_V1Unchanged(t *testing.T) {
+	router := newTestRouter(t, config.APIConfig{DefaultVersion: 1, FieldCase: config.FieldCaseCamel})
+
+	w, body := serve(router, "GET", "/api/v1/messages", "", nil)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "v1", w.Header().Get(VersionHeader))
+	assert.Equal(t, "m1", body["next_cursor"])
+	assert.Equal(t, true, body["has_more"])
+	assert.Nil(t, body["data"])
+
+	w, body = serve(router, "GET", "/api/v1/limited", "", nil)
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "slow down", body["error"])
+}
+
+func TestNegotiator_V2Envelope(t *testing.T) {
+	router := newTestRouter(t, config.APIConfig{DefaultVersion: 1, FieldCase: config.FieldCaseSnake})
+
+	w, body := serve(router, "GET", "/api/v2/messages", "", nil)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "v2", w.Header().Get(VersionHeader))
+	assert.Equal(t, map[string]interface{}{"next_cursor": "m1", "has_more": true}, body["pagination"])
+	data := body["data"].(map[string]interface{})
+	assert.Len(t, data["messages"], 1)
+	assert.Nil(t, body["error"])
+
+	// success字段由状态码表示
+	_, body = serve(router, "POST", "/api/v2/messages", `{"receiver_id":"u2"}`, nil)
+	assert.Equal(t, map[string]interface{}{"message_id": "m2", "receiver_id": "u2"}, body["data"])
+
+	w, body = serve(router, "GET", "/api/v2/limited", "", nil)
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, map[string]interface{}{"code": "rate_limited", "message": "slow down", "retry_after": float64(5)}, body["error"])
+	assert.Nil(t, body["data"])
+
+	// 没有错误码时按状态码推导
+	_, body = serve(router, "POST", "/api/v2/messages", `{}`, nil)
+	assert.Equal(t, "invalid_request", body["error"].(map[string]interface{})["code"])
+
+	// 非JSON响应原样返回
+	w, _ = serve(router, "GET", "/api/v2/version", "", nil)
+	assert.Equal(t, "v2", w.Body.String())
+}
+
+func TestNegotiator_CamelCase(t *testing.T) {
+	router := newTestRouter(t, config.APIConfig{DefaultVersion: 1, FieldCase: config.FieldCaseCamel})
+
+	_, body := serve(router, "GET", "/api/v2/messages", "", nil)
+	assert.Equal(t, map[string]interface{}{"nextCursor": "m1", "hasMore": true}, body["pagination"])
+	message := body["data"].(map[string]interface{})["messages"].([]interface{})[0]
+	assert.Equal(t, map[string]interface{}{"messageId": "m1", "senderId": "u1"}, message)
+
+	// 请求体的camel字段转换为处理函数使用的snake字段
+	_, body = serve(router, "POST", "/api/v2/messages", `{"receiverId":"u2"}`, nil)
+	assert.Equal(t, map[string]interface{}{"messageId": "m2", "receiverId": "u2"}, body["data"])
+
+	// 客户端存储的设置值不转换
+	_, body = serve(router, "GET", "/api/v2/settings", "", nil)
+	settings := body["data"].(map[string]interface{})["settings"]
+	assert.Equal(t, map[string]interface{}{"updatedAt": float64(1), "values": map[string]interface{}{"font_size": float64(14)}}, settings)
+
+	_, body = serve(router, "GET", "/api/v2/limited", "", nil)
+	assert.Equal(t, float64(5), body["error"].(map[string]interface{})["retryAfter"])
+}
+
+func TestNegotiator_Negotiate(t *testing.T) {
+	router := newTestRouter(t, config.APIConfig{DefaultVersion: 1, FieldCase: config.FieldCaseSnake})
+
+	w, _ := serve(router, "GET", "/api/version", "", nil)
+	assert.Equal(t, "v1", w.Body.String())
+
+	w, _ = serve(router, "GET", "/api/version", "", map[string]string{VersionHeader: "2"})
+	assert.Equal(t, "v2", w.Body.String())
+
+	w, _ = serve(router, "GET", "/api/version", "", map[string]string{"Accept": "text/html, application/vnd.im.v2+json;q=0.9"})
+	assert.Equal(t, "v2", w.Body.String())
+
+	// 路径中的版本号优先
+	w, _ = serve(router, "GET", "/api/v1/version", "", map[string]string{VersionHeader: "2"})
+	assert.Equal(t, "v1", w.Body.String())
+
+	w, body := serve(router, "GET", "/api/version", "", map[string]string{VersionHeader: "v9"})
+	assert.Equal(t, 406, w.Code)
+	assert.Equal(t, ErrCodeUnsupportedVersion, body["error"].(map[string]interface{})["code"])
+
+	_, err := NewNegotiator(config.APIConfig{DefaultVersion: 3})
+	assert.Error(t, err)
+}
+
+func TestFieldCase(t *testing.T) {
+	assert.Equal(t, "messageId", toCamel("message_id"))
+	assert.Equal(t, "nextCursor", toCamel("next_cursor"))
+	assert.Equal(t, "status", toCamel("status"))
+	assert.Equal(t, "User_1", toCamel("User_1"))
+	assert.Equal(t, "ttl_2x", toCamel("ttl_2x"))
+
+	assert.Equal(t, "message_id", toSnake("messageId"))
+	assert.Equal(t, "message_id", toSnake("messageID"))
+	assert.Equal(t, "http_server", toSnake("HTTPServer"))
+	assert.Equal(t, "message_id", toSnake("message_id"))
+	assert.Equal(t, "status", toSnake("status"))
+}
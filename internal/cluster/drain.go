@@ -0,0 +1,234 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+	"github.com/user/im/pkg/websocket"
+)
+
+// Drainer 滚动发布时排空本节点的连接
+// 开始后在注册中心把本节点标记为排空，新用户不再分配到本节点；按批向已连接的客户端推送重连提示，
+// 指向用户在其余节点中的归属节点，使连接在几分钟内逐步迁走而不是在关闭时同时重连；
+// 连接数降到阈值以下或超时后关闭Done通道，由调用方关闭服务
+type Drainer struct {
+	cfg      config.DrainConfig
+	nodeID   string
+	registry Registry
+	router   *Router
+	manager  *websocket.Manager
+	now      func() time.Time
+
+	mu        sync.Mutex
+	startedAt time.Time
+	batch     int
+	hinted    map[string]bool // 已推送重连提示的会话ID
+	finished  bool
+	done      chan struct{}
+}
+
+// NewDrainer 创建连接排空器，router需基于同一个注册中心，排空中的节点不参与分配
+func NewDrainer(cfg config.DrainConfig, nodeID string, registry Registry, router *Router, manager *websocket.Manager) *Drainer {
+	return &Drainer{
+		cfg:      cfg,
+		nodeID:   nodeID,
+		registry: registry,
+		router:   router,
+		manager:  manager,
+		now:      time.Now,
+		hinted:   make(map[string]bool),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start 开始排空，ctx取消时停止推送，已经开始时返回false
+func (d *Drainer) Start(ctx context.Context) bool {
+	if !d.begin() {
+		return false
+	}
+	go d.run(ctx)
+	return true
+}
+
+// begin 记录开始时间和每批推送数，并在注册中心标记本节点正在排空
+func (d *Drainer) begin() bool {
+	d.mu.Lock()
+	if !d.startedAt.IsZero() {
+		d.mu.Unlock()
+		return false
+	}
+	d.startedAt = d.now()
+	d.batch = drainBatchSize(d.manager.GetConnectionCount(), d.cfg)
+	d.mu.Unlock()
+
+	// 标记失败时仍在本地排空：连接照常迁走，只是其他节点可能继续把新用户分配过来
+	if err := d.registry.SetDraining(true); err != nil {
+		logger.Error("Failed to mark node as draining", logger.ErrorField(err))
+	}
+	logger.Info("Draining node connections",
+		logger.Int("connections", d.manager.GetConnectionCount()),
+		logger.Int("batch", d.batch),
+		logger.Int("threshold", d.cfg.Threshold))
+	return true
+}
+
+// Draining 是否已开始排空
+func (d *Drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return !d.startedAt.IsZero()
+}
+
+// Done 排空结束时关闭
+func (d *Drainer) Done() <-chan struct{} {
+	return d.done
+}
+
+// Status 获取排空进度
+func (d *Drainer) Status() model.DrainStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := model.DrainStatus{
+		NodeID:      d.nodeID,
+		Draining:    !d.startedAt.IsZero(),
+		Done:        d.finished,
+		Connections: d.manager.GetConnectionCount(),
+		Hinted:      len(d.hinted),
+		Threshold:   d.cfg.Threshold,
+	}
+	if status.Draining {
+		startedAt, deadline := d.startedAt, d.startedAt.Add(d.cfg.Timeout)
+		status.StartedAt, status.Deadline = &startedAt, &deadline
+	}
+	return status
+}
+
+// run 每隔一个批次间隔推送一批重连提示，直到排空结束
+func (d *Drainer) run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.BatchInterval)
+	defer ticker.Stop()
+
+	for !d.step() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// step 检查是否可以结束排空，否则推送一批重连提示，结束时返回true
+func (d *Drainer) step() bool {
+	connections := d.manager.GetConnectionCount()
+	d.mu.Lock()
+	expired := !d.now().Before(d.startedAt.Add(d.cfg.Timeout))
+	d.mu.Unlock()
+
+	if connections <= d.cfg.Threshold || expired {
+		d.finish(connections, expired)
+		return true
+	}
+	d.hintBatch()
+	return false
+}
+
+// finish 结束排空并通知调用方
+func (d *Drainer) finish(connections int, expired bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.finished {
+		return
+	}
+	d.finished = true
+	close(d.done)
+	logger.Info("Node drained",
+		logger.Int("connections", connections),
+		logger.Int("hinted", len(d.hinted)),
+		logger.Bool("timed_out", expired))
+}
+
+// hintBatch 向尚未提示过的会话推送一批重连提示，每个会话只提示一次；
+// 没有其他可接入的节点时不推送，等待节点恢复或超时
+func (d *Drainer) hintBatch() {
+	d.mu.Lock()
+	remaining := d.batch
+	d.mu.Unlock()
+
+	within := int64(math.Ceil(d.cfg.BatchInterval.Seconds()))
+	unrouted := 0
+	d.manager.ForEachUser(func(userID string, s websocket.Session) {
+		if remaining <= 0 || d.isHinted(s.ID()) {
+			return
+		}
+		target := d.router.HomeNode(userID)
+		if target == nil || target.ID == d.nodeID {
+			unrouted++
+			return
+		}
+
+		data, err := json.Marshal(model.WebSocketMessage{
+			Type: model.FrameReconnect,
+			Data: model.ReconnectHint{
+				ReconnectTo: d.reconnectURL(target),
+				NodeID:      target.ID,
+				Reason:      model.ReconnectReasonDrain,
+				Within:      within,
+			},
+			Timestamp: d.now().Unix(),
+		})
+		if err != nil {
+			return
+		}
+		if err := d.manager.Deliver(s, data); err != nil {
+			logger.Warn("Failed to send reconnect hint",
+				logger.String("user_id", userID), logger.ErrorField(err))
+			return
+		}
+		d.markHinted(s.ID())
+		metrics.DrainReconnectHints.Inc()
+		remaining--
+	})
+	if unrouted > 0 {
+		logger.Warn("No healthy node to hand over connections", logger.Int("sessions", unrouted))
+	}
+}
+
+// reconnectURL 节点的WebSocket接入地址
+func (d *Drainer) reconnectURL(node *Node) string {
+	return fmt.Sprintf("%s://%s/ws", d.cfg.Scheme, node.Address)
+}
+
+// isHinted 会话是否已推送过重连提示
+func (d *Drainer) isHinted(sessionID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.hinted[sessionID]
+}
+
+// markHinted 记录已推送重连提示的会话
+func (d *Drainer) markHinted(sessionID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hinted[sessionID] = true
+}
+
+// drainBatchSize 每批推送的会话数，使开始排空时的连接在spread内推送完
+func drainBatchSize(connections int, cfg config.DrainConfig) int {
+	batches := math.Ceil(float64(cfg.Spread) / float64(cfg.BatchInterval))
+	if batches < 1 {
+		batches = 1
+	}
+	size := int(math.Ceil(float64(connections) / batches))
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/websocket"
+)
+
+// testRegistry 固定节点列表的注册中心
+type testRegistry struct {
+	nodes []*Node
+}
+
+func (r *testRegistry) Start() error { return nil }
+func (r *testRegistry) Stop() error  { return nil }
+func (r *testRegistry) Nodes() []*Node {
+	return r.nodes
+}
+func (r *testRegistry) Node(id string) (*Node, bool) {
+	for _, node := range r.nodes {
+		if node.ID == id {
+			return node, true
+		}
+	}
+	return nil, false
+}
+func (r *testRegistry) SetDraining(draining bool) error {
+	r.nodes[0].Draining = draining
+	return nil
+}
+
+// testSession 记录下发帧的会话
+type testSession struct {
+	id     string
+	userID string
+	frames []model.WebSocketMessage
+	closed bool
+}
+
+func (s *testSession) ID() string                               { return s.id }
+func (s *testSession) UserID() string                           { return s.userID }
+func (s *testSession) SetUserID(userID string)                  { s.userID = userID }
+func (s *testSession) Transport() string                        { return websocket.TransportWebSocket }
+func (s *testSession) RemoteIP() string                         { return "" }
+func (s *testSession) Handshake() model.Handshake               { return model.Handshake{} }
+func (s *testSession) Capabilities() model.ClientCapabilities   { return model.ClientCapabilities{} }
+func (s *testSession) SetCapabilities(model.ClientCapabilities) {}
+func (s *testSession) Close()                                   { s.closed = true }
+func (s *testSession) SendMessage(data []byte) error {
+	var frame model.WebSocketMessage
+	json.Unmarshal(data, &frame)
+	s.frames = append(s.frames, frame)
+	return nil
+}
+
+func newTestDrainer(cfg config.DrainConfig, users ...string) (*Drainer, *websocket.Manager, *testRegistry, map[string]*testSession) {
+	registry := &testRegistry{nodes: []*Node{
+		{ID: "n1", Address: "10.0.0.1:8080"},
+		{ID: "n2", Address: "10.0.0.2:8080"},
+	}}
+	manager := websocket.NewManager()
+	sessions := make(map[string]*testSession, len(users))
+	for _, userID := range users {
+		s := &testSession{id: "s-" + userID}
+		manager.Register(s)
+		manager.BindUser(userID, s)
+		sessions[userID] = s
+	}
+	return NewDrainer(cfg, "n1", registry, NewRouter(registry, 10), manager), manager, registry, sessions
+}
+
+func TestDrainBatchSize(t *testing.T) {
+	cfg := config.DrainConfig{Spread: time.Minute, BatchInterval: 5 * time.Second}
+	assert.Equal(t, 84, drainBatchSize(1000, cfg))
+	assert.Equal(t, 1, drainBatchSize(3, cfg))
+	assert.Equal(t, 1, drainBatchSize(0, cfg))
+	assert.Equal(t, 10, drainBatchSize(10, config.DrainConfig{Spread: time.Second, BatchInterval: 5 * time.Second}))
+}
+
+func TestDrainer_HintsInBatches(t *testing.T) {
+	cfg := config.DrainConfig{Spread: 2 * time.Second, BatchInterval: 1500 * time.Millisecond, Timeout: time.Minute, Scheme: "wss"}
+	d, manager, registry, sessions := newTestDrainer(cfg, "u1", "u2", "u3")
+
+	assert.True(t, d.begin())
+	assert.False(t, d.begin())
+	assert.True(t, registry.nodes[0].Draining)
+	assert.True(t, d.Draining())
+
+	// 3个连接分2批推送
+	assert.False(t, d.step())
+	assert.Equal(t, 2, d.Status().Hinted)
+	assert.False(t, d.step())
+	assert.Equal(t, 3, d.Status().Hinted)
+	// 每个会话只提示一次
+	assert.False(t, d.step())
+	for _, s := range sessions {
+		if assert.Len(t, s.frames, 1) {
+			assert.Equal(t, model.FrameReconnect, s.frames[0].Type)
+			hint, _ := s.frames[0].Data.(map[string]interface{})
+			assert.Equal(t, "wss://10.0.0.2:8080/ws", hint["reconnect_to"])
+			assert.Equal(t, "n2", hint["node_id"])
+			assert.Equal(t, model.ReconnectReasonDrain, hint["reason"])
+			assert.Equal(t, float64(2), hint["within"])
+		}
+	}
+
+	// 连接数降到阈值后结束
+	for _, s := range sessions {
+		manager.Unregister(s)
+	}
+	assert.True(t, d.step())
+	select {
+	case <-d.Done():
+	default:
+		t.Fatal("drainer should be done")
+	}
+	assert.True(t, d.Status().Done)
+}
+
+func TestDrainer_NoHealthyNode(t *testing.T) {
+	now := time.Unix(1704067200, 0)
+	d, _, registry, sessions := newTestDrainer(config.DrainConfig{Spread: time.Second, BatchInterval: time.Second, Timeout: time.Minute, Scheme: "ws"}, "u1")
+	d.now = func() time.Time { return now }
+	registry.nodes[1].Draining = true
+
+	assert.True(t, d.begin())
+	assert.False(t, d.step())
+	assert.Empty(t, sessions["u1"].frames)
+
+	// 超时后不论剩余连接数都结束
+	now = now.Add(time.Minute)
+	assert.True(t, d.step())
+	assert.Equal(t, 1, d.Status().Connections)
+	assert.True(t, d.Status().Done)
+}
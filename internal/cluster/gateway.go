@@ -0,0 +1,330 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+	"github.com/user/im/pkg/logger"
+	"github.com/user/im/pkg/websocket"
+)
+
+// BusinessFrames 网关需要转发给业务节点处理的帧类型
+var BusinessFrames = []model.FrameType{
+	model.FrameSendMessage, model.FrameAck, model.FrameSyncGap, model.FrameRSVP, model.FrameVote, model.FrameClosePoll,
+}
+
+// PushTopic 获取网关节点的推送主题
+func PushTopic(prefix, gatewayID string) string {
+	return fmt.Sprintf("%s.%s", prefix, gatewayID)
+}
+
+// Gateway 接入网关
+// 负责终结客户端连接、维护 userID -> 网关 的路由，并在本节点与业务节点之间转发帧
+type Gateway struct {
+	nodeID        string
+	manager       *websocket.Manager
+	redisStore    *store.RedisStore
+	kafkaStore    *store.KafkaStore
+	upstreamTopic string
+	pushTopic     string
+	routeTTL      time.Duration
+	dedup         *store.Deduplicator
+}
+
+// NewGateway 创建接入网关，dedupTTL为推送去重记录的保留时长
+func NewGateway(nodeID string, manager *websocket.Manager, redisStore *store.RedisStore, kafkaStore *store.KafkaStore, upstreamTopic, pushPrefix string, routeTTL, dedupTTL time.Duration) *Gateway {
+	return &Gateway{
+		nodeID:        nodeID,
+		manager:       manager,
+		redisStore:    redisStore,
+		kafkaStore:    kafkaStore,
+		upstreamTopic: upstreamTopic,
+		pushTopic:     PushTopic(pushPrefix, nodeID),
+		routeTTL:      routeTTL,
+		dedup:         store.NewDeduplicator(redisStore, "gateway_push:"+nodeID, dedupTTL),
+	}
+}
+
+// Start 注册路由维护回调和业务帧转发，并开始消费本节点的推送主题
+func (g *Gateway) Start() {
+	g.manager.OnBind(func(userID string, s websocket.Session) {
+		if err := g.redisStore.SetUserGateway(userID, g.nodeID, g.routeTTL); err != nil {
+			logger.Error("Failed to register user gateway",
+				logger.String("user_id", userID), logger.ErrorField(err))
+		}
+	})
+	g.manager.OnUnbind(func(userID string, s websocket.Session) {
+		if err := g.redisStore.RemoveUserGateway(userID, g.nodeID); err != nil {
+			logger.Error("Failed to remove user gateway",
+				logger.String("user_id", userID), logger.ErrorField(err))
+		}
+	})
+
+	// 心跳时刷新路由过期时间，保证长连接用户的路由不失效
+	g.manager.HandleFrame(model.FrameHeartbeat, func(s websocket.Session, frame *model.WebSocketMessage) {
+		if userID := s.UserID(); userID != "" {
+			g.redisStore.SetUserGateway(userID, g.nodeID, g.routeTTL)
+		}
+		g.manager.Reply(s, model.FrameHeartbeat, model.HeartbeatResponse{
+			Timestamp: time.Now().Unix(),
+		})
+	})
+
+	for _, frameType := range BusinessFrames {
+		g.manager.HandleFrame(frameType, g.forward)
+	}
+
+	go func() {
+		if err := g.kafkaStore.Consume(g.pushTopic, g.nodeID, g.handlePush); err != nil {
+			logger.Error("Failed to consume gateway push", logger.ErrorField(err))
+		}
+	}()
+}
+
+// forward 将客户端帧转发给业务节点，按用户ID分区保证单用户有序
+func (g *Gateway) forward(s websocket.Session, frame *model.WebSocketMessage) {
+	userID := s.UserID()
+	if userID == "" {
+		g.manager.ReplyError(s, "Login required")
+		return
+	}
+
+	err := g.kafkaStore.Publish(g.upstreamTopic, userID, &model.GatewayFrame{
+		GatewayID: g.nodeID,
+		SessionID: s.ID(),
+		UserID:    userID,
+		Frame:     *frame,
+	})
+	if err != nil {
+		logger.Error("Failed to forward frame", logger.String("type", string(frame.Type)), logger.ErrorField(err))
+		g.manager.ReplyError(s, "Service unavailable")
+	}
+}
+
+// handlePush 处理业务节点下发的推送
+func (g *Gateway) handlePush(value []byte) error {
+	var push model.GatewayPush
+	if err := json.Unmarshal(value, &push); err != nil {
+		return fmt.Errorf("failed to decode gateway push: %w", err)
+	}
+	// 网关重启后Kafka会重投未提交的推送，已推送过的跳过
+	if !g.dedup.Claim(push.ID) {
+		return nil
+	}
+
+	if push.SessionID != "" {
+		// 回复只发给发出上行帧的会话，用户重新登录后不会发给新会话
+		if s, exists := g.manager.GetSession(push.SessionID); exists {
+			g.deliverPush(s, &push)
+		}
+		return nil
+	}
+	for _, userID := range push.UserIDs {
+		if s, exists := g.manager.GetUserSession(userID); exists {
+			g.deliverPush(s, &push)
+		}
+	}
+	return nil
+}
+
+// deliverPush 向会话下发推送的数据，需要时断开会话
+func (g *Gateway) deliverPush(s websocket.Session, push *model.GatewayPush) {
+	if len(push.Data) > 0 {
+		g.manager.Deliver(s, push.Data)
+	}
+	if push.Close {
+		s.Close()
+	}
+}
+
+// newPushID 生成推送ID，生成失败时为空，该推送不参与去重
+func newPushID() string {
+	id, err := idgen.GenerateIDString()
+	if err != nil {
+		logger.Warn("Failed to generate push ID", logger.ErrorField(err))
+	}
+	return id
+}
+
+// Relay 业务节点侧的消息下发实现
+// 通过Redis中的路由找到用户所在网关，并将推送写入对应网关的主题
+type Relay struct {
+	redisStore *store.RedisStore
+	kafkaStore *store.KafkaStore
+	registry   Registry
+	pushPrefix string
+}
+
+// NewRelay 创建网关转发器，registry为nil时不校验网关节点是否存活
+func NewRelay(redisStore *store.RedisStore, kafkaStore *store.KafkaStore, registry Registry, pushPrefix string) *Relay {
+	return &Relay{
+		redisStore: redisStore,
+		kafkaStore: kafkaStore,
+		registry:   registry,
+		pushPrefix: pushPrefix,
+	}
+}
+
+// resolveGateway 校验网关节点是否仍在集群中，已下线网关上的路由视为离线
+func (r *Relay) resolveGateway(gatewayID string) bool {
+	if gatewayID == "" {
+		return false
+	}
+	if r.registry == nil {
+		return true
+	}
+	_, exists := r.registry.Node(gatewayID)
+	return exists
+}
+
+// IsOnline 判断用户是否连接在任一网关
+func (r *Relay) IsOnline(userID string) bool {
+	gatewayID, err := r.redisStore.GetUserGateway(userID)
+	return err == nil && r.resolveGateway(gatewayID)
+}
+
+// SendToUser 发送消息给用户
+func (r *Relay) SendToUser(userID string, message interface{}) error {
+	gatewayID, err := r.redisStore.GetUserGateway(userID)
+	if err != nil || !r.resolveGateway(gatewayID) {
+		return fmt.Errorf("user %s not connected", userID)
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	return r.kafkaStore.Publish(PushTopic(r.pushPrefix, gatewayID), userID, &model.GatewayPush{
+		ID:      newPushID(),
+		UserIDs: []string{userID},
+		Data:    data,
+	})
+}
+
+// DisconnectUser 通知用户所在网关推送最后一帧后断开会话
+func (r *Relay) DisconnectUser(userID string, message interface{}) error {
+	gatewayID, err := r.redisStore.GetUserGateway(userID)
+	if err != nil || !r.resolveGateway(gatewayID) {
+		return nil
+	}
+
+	push := &model.GatewayPush{
+		ID:      newPushID(),
+		UserIDs: []string{userID},
+		Close:   true,
+	}
+	if message != nil {
+		if push.Data, err = json.Marshal(message); err != nil {
+			return err
+		}
+	}
+	return r.kafkaStore.Publish(PushTopic(r.pushPrefix, gatewayID), userID, push)
+}
+
+// BroadcastToGroup 广播消息给群组，按网关合并推送
+func (r *Relay) BroadcastToGroup(userIDs []string, message interface{}) {
+	routes, err := r.redisStore.GetUserGateways(userIDs)
+	if err != nil {
+		logger.Error("Failed to resolve user gateways", logger.ErrorField(err))
+		return
+	}
+	if len(routes) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		logger.Error("Failed to marshal message", logger.ErrorField(err))
+		return
+	}
+
+	byGateway := make(map[string][]string)
+	for userID, gatewayID := range routes {
+		if r.resolveGateway(gatewayID) {
+			byGateway[gatewayID] = append(byGateway[gatewayID], userID)
+		}
+	}
+
+	for gatewayID, users := range byGateway {
+		if err := r.kafkaStore.Publish(PushTopic(r.pushPrefix, gatewayID), gatewayID, &model.GatewayPush{
+			ID:      newPushID(),
+			UserIDs: users,
+			Data:    data,
+		}); err != nil {
+			logger.Error("Failed to push to gateway",
+				logger.String("gateway_id", gatewayID), logger.ErrorField(err))
+		}
+	}
+}
+
+// FrameProcessor 业务帧处理接口
+type FrameProcessor interface {
+	HandleFrame(userID string, frame *model.WebSocketMessage) *model.WebSocketMessage
+}
+
+// PushPublisher 写入网关推送主题，由KafkaStore实现
+type PushPublisher interface {
+	Publish(topic, key string, value interface{}) error
+}
+
+// Worker 业务节点
+// 消费网关转发的客户端帧，交给业务层处理后经网关回复客户端
+type Worker struct {
+	kafkaStore    *store.KafkaStore
+	publisher     PushPublisher
+	relay         *Relay
+	processor     FrameProcessor
+	upstreamTopic string
+	groupID       string
+}
+
+// NewWorker 创建业务节点
+func NewWorker(kafkaStore *store.KafkaStore, relay *Relay, processor FrameProcessor, upstreamTopic, groupID string) *Worker {
+	return &Worker{
+		kafkaStore:    kafkaStore,
+		publisher:     kafkaStore,
+		relay:         relay,
+		processor:     processor,
+		upstreamTopic: upstreamTopic,
+		groupID:       groupID,
+	}
+}
+
+// Start 开始消费上行帧
+func (w *Worker) Start() {
+	go func() {
+		if err := w.kafkaStore.Consume(w.upstreamTopic, w.groupID, w.handleUpstream); err != nil {
+			logger.Error("Failed to consume gateway upstream", logger.ErrorField(err))
+		}
+	}()
+}
+
+// handleUpstream 处理网关转发的帧，回复经网关发回发出该帧的会话
+func (w *Worker) handleUpstream(value []byte) error {
+	var frame model.GatewayFrame
+	if err := json.Unmarshal(value, &frame); err != nil {
+		return fmt.Errorf("failed to decode gateway frame: %w", err)
+	}
+
+	reply := w.processor.HandleFrame(frame.UserID, &frame.Frame)
+	if reply == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+
+	return w.publisher.Publish(PushTopic(w.relay.pushPrefix, frame.GatewayID), frame.UserID, &model.GatewayPush{
+		ID:        newPushID(),
+		UserIDs:   []string{frame.UserID},
+		SessionID: frame.SessionID,
+		Data:      data,
+	})
+}
@@ -0,0 +1,138 @@
+package cluster
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/websocket"
+)
+
+func TestPushTopic(t *testing.T) {
+	assert.Equal(t, "im.push.gw-1", PushTopic("im.push", "gw-1"))
+}
+
+func TestGateway_ForwardRequiresLogin(t *testing.T) {
+	manager := websocket.NewManager()
+	g := NewGateway("gw-1", manager, nil, nil, "im.upstream", "im.push", time.Minute, time.Minute)
+	s := &testSession{id: "s1"}
+
+	// 未登录的会话直接回复错误，不转发给业务节点
+	g.forward(s, &model.WebSocketMessage{Type: model.FrameSendMessage})
+	if assert.Len(t, s.frames, 1) {
+		assert.Equal(t, model.FrameError, s.frames[0].Type)
+		assert.Equal(t, "Login required", s.frames[0].Data.(map[string]interface{})["error"])
+	}
+}
+
+func TestRelay_ResolveGateway(t *testing.T) {
+	registry := &testRegistry{nodes: []*Node{{ID: "gw-1"}}}
+	relay := NewRelay(nil, nil, registry, "im.push")
+	assert.True(t, relay.resolveGateway("gw-1"))
+	// 已下线网关上的路由视为离线
+	assert.False(t, relay.resolveGateway("gw-2"))
+	assert.False(t, relay.resolveGateway(""))
+
+	// 未配置注册中心时不校验网关是否存活
+	assert.True(t, NewRelay(nil, nil, nil, "im.push").resolveGateway("gw-2"))
+}
+
+func TestGateway_HandlePushClosesSession(t *testing.T) {
+	manager := websocket.NewManager()
+	g := NewGateway("gw-1", manager, nil, nil, "im.upstream", "im.push", time.Minute, time.Minute)
+	banned := &testSession{id: "s1"}
+	other := &testSession{id: "s2"}
+	manager.Register(banned)
+	manager.BindUser("u1", banned)
+	manager.Register(other)
+	manager.BindUser("u2", other)
+
+	// 断开推送先下发最后一帧再关闭会话，只影响指定用户
+	push, _ := json.Marshal(&model.GatewayPush{
+		UserIDs: []string{"u1", "u3"},
+		Data:    []byte(`{"type":"error","data":{"code":"BANNED"}}`),
+		Close:   true,
+	})
+	assert.NoError(t, g.handlePush(push))
+	assert.True(t, banned.closed)
+	if assert.Len(t, banned.frames, 1) {
+		assert.Equal(t, model.FrameError, banned.frames[0].Type)
+	}
+	assert.False(t, other.closed)
+
+	// 没有数据时直接断开
+	push, _ = json.Marshal(&model.GatewayPush{UserIDs: []string{"u2"}, Close: true})
+	assert.NoError(t, g.handlePush(push))
+	assert.True(t, other.closed)
+	assert.Empty(t, other.frames)
+}
+
+// echoProcessor 把上行帧原样作为回复
+type echoProcessor struct{}
+
+func (echoProcessor) HandleFrame(userID string, frame *model.WebSocketMessage) *model.WebSocketMessage {
+	return &model.WebSocketMessage{Type: frame.Type, Data: userID}
+}
+
+// pushRecorder 记录写入推送主题的推送
+type pushRecorder struct {
+	topics []string
+	pushes []*model.GatewayPush
+}
+
+func (p *pushRecorder) Publish(topic, key string, value interface{}) error {
+	p.topics = append(p.topics, topic)
+	p.pushes = append(p.pushes, value.(*model.GatewayPush))
+	return nil
+}
+
+func TestWorker_RepliesToOriginatingSession(t *testing.T) {
+	manager := websocket.NewManager()
+	g := NewGateway("gw-1", manager, nil, nil, "im.upstream", "im.push", time.Minute, time.Minute)
+	first := &testSession{id: "s1"}
+	second := &testSession{id: "s2"}
+	manager.Register(first)
+	manager.BindUser("u1", first)
+	manager.Register(second)
+	manager.BindUser("u1", second)
+
+	recorder := &pushRecorder{}
+	w := NewWorker(nil, NewRelay(nil, nil, nil, "im.push"), echoProcessor{}, "im.upstream", "workers")
+	w.publisher = recorder
+
+	// 两个会话属于同一用户，回复只发给发出上行帧的会话
+	for _, sessionID := range []string{"s1", "s2"} {
+		frame, _ := json.Marshal(&model.GatewayFrame{
+			GatewayID: "gw-1", SessionID: sessionID, UserID: "u1",
+			Frame: model.WebSocketMessage{Type: model.FrameAck},
+		})
+		assert.NoError(t, w.handleUpstream(frame))
+	}
+	if !assert.Len(t, recorder.pushes, 2) {
+		return
+	}
+	assert.Equal(t, []string{"im.push.gw-1", "im.push.gw-1"}, recorder.topics)
+	assert.Equal(t, "s1", recorder.pushes[0].SessionID)
+	assert.Equal(t, "s2", recorder.pushes[1].SessionID)
+
+	recorder.pushes[0].ID = ""
+	push, _ := json.Marshal(recorder.pushes[0])
+	assert.NoError(t, g.handlePush(push))
+	assert.Len(t, first.frames, 1)
+	assert.Empty(t, second.frames)
+
+	recorder.pushes[1].ID = ""
+	push, _ = json.Marshal(recorder.pushes[1])
+	assert.NoError(t, g.handlePush(push))
+	assert.Len(t, first.frames, 1)
+	assert.Len(t, second.frames, 1)
+
+	// 会话已断开时丢弃回复，不回退到用户当前的会话
+	manager.Unregister(first)
+	push, _ = json.Marshal(&model.GatewayPush{UserIDs: []string{"u1"}, SessionID: "s1", Data: []byte(`{"type":"ack"}`)})
+	assert.NoError(t, g.handlePush(push))
+	assert.Len(t, first.frames, 1)
+	assert.Len(t, second.frames, 1)
+}
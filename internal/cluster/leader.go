@@ -0,0 +1,297 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/user/im/pkg/logger"
+)
+
+// Locker 分布式锁接口
+type Locker interface {
+	AcquireLock(key, owner string, ttl time.Duration) (int64, bool, error)
+	RenewLock(key, owner string, ttl time.Duration) (bool, error)
+	ReleaseLock(key, owner string) error
+	GetLockOwner(key string) (string, error)
+}
+
+// Elector 基于分布式锁的主节点选举
+// 持有锁的节点为主节点，每次当选都会获得递增的fencing token，
+// 任务在写入外部系统时可携带token，防止旧主节点在失联后继续写入
+type Elector struct {
+	locker Locker
+	key    string
+	nodeID string
+	ttl    time.Duration
+
+	mu       sync.RWMutex
+	leader   bool
+	fence    int64
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+	onChange []func(leader bool)
+}
+
+// NewElector 创建选举器
+func NewElector(locker Locker, key, nodeID string, ttl time.Duration) *Elector {
+	return &Elector{
+		locker: locker,
+		key:    key,
+		nodeID: nodeID,
+		ttl:    ttl,
+		done:   make(chan struct{}),
+	}
+}
+
+// OnChange 注册主节点身份变化回调
+func (e *Elector) OnChange(fn func(leader bool)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onChange = append(e.onChange, fn)
+}
+
+// Start 开始参与选举
+func (e *Elector) Start() {
+	go e.loop()
+}
+
+// Stop 退出选举，主节点会主动释放锁以便其他节点尽快接管，可重复调用
+func (e *Elector) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.done)
+		e.setLeader(false, 0)
+		if err := e.locker.ReleaseLock(e.key, e.nodeID); err != nil {
+			logger.Warn("Failed to release leader lock", logger.ErrorField(err))
+		}
+	})
+}
+
+// IsLeader 当前节点是否为主节点
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Leadership 获取主节点任期上下文和fencing token，非主节点返回false
+// 失去主节点身份时上下文会被取消
+func (e *Elector) Leadership() (context.Context, int64, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if !e.leader {
+		return nil, 0, false
+	}
+	return e.ctx, e.fence, true
+}
+
+// Leader 获取当前主节点ID
+func (e *Elector) Leader() string {
+	owner, err := e.locker.GetLockOwner(e.key)
+	if err != nil {
+		return ""
+	}
+	return owner
+}
+
+// loop 选举循环：主节点定期续期，其他节点定期尝试抢占
+func (e *Elector) loop() {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	e.tick()
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			e.tick()
+		}
+	}
+}
+
+// tick 执行一轮选举
+func (e *Elector) tick() {
+	if e.IsLeader() {
+		ok, err := e.locker.RenewLock(e.key, e.nodeID, e.ttl)
+		if err != nil || !ok {
+			logger.Warn("Lost leadership", logger.String("node_id", e.nodeID))
+			e.setLeader(false, 0)
+		}
+		return
+	}
+
+	fence, ok, err := e.locker.AcquireLock(e.key, e.nodeID, e.ttl)
+	if err != nil {
+		logger.Warn("Failed to acquire leader lock", logger.ErrorField(err))
+		return
+	}
+	if ok {
+		logger.Info("Became leader",
+			logger.String("node_id", e.nodeID),
+			logger.Int64("fence", fence))
+		e.setLeader(true, fence)
+	}
+}
+
+// setLeader 切换主节点身份
+func (e *Elector) setLeader(leader bool, fence int64) {
+	e.mu.Lock()
+	if e.leader == leader {
+		e.mu.Unlock()
+		return
+	}
+
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+	e.leader = leader
+	e.fence = fence
+	if leader {
+		e.ctx, e.cancel = context.WithCancel(context.Background())
+	}
+	callbacks := e.onChange
+	e.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(leader)
+	}
+}
+
+// JobFunc 后台任务函数，ctx在失去主节点身份时取消，fence为当选时的fencing token
+type JobFunc func(ctx context.Context, fence int64) error
+
+// JobStatus 后台任务状态
+type JobStatus struct {
+	Name      string        `json:"name"`
+	Interval  time.Duration `json:"interval"`
+	LastRun   time.Time     `json:"last_run"`
+	LastError string        `json:"last_error,omitempty"`
+	Runs      int64         `json:"runs"`
+}
+
+// job 已注册的后台任务
+type job struct {
+	name     string
+	interval time.Duration
+	fn       JobFunc
+	status   JobStatus
+	running  bool
+}
+
+// Coordinator 集群级后台任务协调器
+// 所有节点注册相同的任务，但只有主节点会执行，主节点宕机后由新主节点接管
+type Coordinator struct {
+	elector  *Elector
+	jobs     map[string]*job
+	mu       sync.Mutex
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCoordinator 创建任务协调器
+func NewCoordinator(elector *Elector) *Coordinator {
+	return &Coordinator{
+		elector: elector,
+		jobs:    make(map[string]*job),
+		done:    make(chan struct{}),
+	}
+}
+
+// Register 注册后台任务，需在Start之前调用
+func (c *Coordinator) Register(name string, interval time.Duration, fn JobFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jobs[name] = &job{
+		name:     name,
+		interval: interval,
+		fn:       fn,
+		status:   JobStatus{Name: name, Interval: interval},
+	}
+}
+
+// Start 启动选举和所有任务的调度
+func (c *Coordinator) Start() {
+	c.elector.Start()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, j := range c.jobs {
+		go c.schedule(j)
+	}
+}
+
+// Stop 停止任务调度并退出选举，可重复调用
+func (c *Coordinator) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.done)
+		c.elector.Stop()
+	})
+}
+
+// Elector 获取选举器
+func (c *Coordinator) Elector() *Elector {
+	return c.elector
+}
+
+// Jobs 获取所有任务状态
+func (c *Coordinator) Jobs() []JobStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(c.jobs))
+	for _, j := range c.jobs {
+		statuses = append(statuses, j.status)
+	}
+	return statuses
+}
+
+// schedule 按间隔调度任务，仅主节点执行
+func (c *Coordinator) schedule(j *job) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.run(j)
+		}
+	}
+}
+
+// run 执行一次任务，上一次执行未结束时跳过
+func (c *Coordinator) run(j *job) {
+	ctx, fence, ok := c.elector.Leadership()
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	if j.running {
+		c.mu.Unlock()
+		return
+	}
+	j.running = true
+	c.mu.Unlock()
+
+	err := j.fn(ctx, fence)
+
+	c.mu.Lock()
+	j.running = false
+	j.status.LastRun = time.Now()
+	j.status.Runs++
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		logger.Error("Background job failed", logger.String("job", j.name), logger.ErrorField(err))
+	}
+}
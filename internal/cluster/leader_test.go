@@ -0,0 +1,135 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryLocker 内存中的锁，加锁时递增fencing token，续期和释放检查持有者
+type memoryLocker struct {
+	mu     sync.Mutex
+	owners map[string]string
+	fence  int64
+}
+
+func newMemoryLocker() *memoryLocker {
+	return &memoryLocker{owners: make(map[string]string)}
+}
+
+func (l *memoryLocker) AcquireLock(key, owner string, ttl time.Duration) (int64, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, held := l.owners[key]; held {
+		return 0, false, nil
+	}
+	l.owners[key] = owner
+	l.fence++
+	return l.fence, true, nil
+}
+
+func (l *memoryLocker) RenewLock(key, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.owners[key] == owner, nil
+}
+
+func (l *memoryLocker) ReleaseLock(key, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.owners[key] == owner {
+		delete(l.owners, key)
+	}
+	return nil
+}
+
+func (l *memoryLocker) GetLockOwner(key string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.owners[key], nil
+}
+
+// expire 模拟锁过期
+func (l *memoryLocker) expire(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.owners, key)
+}
+
+func TestElector_AcquireAndLoseLeadership(t *testing.T) {
+	locker := newMemoryLocker()
+	a := NewElector(locker, "leader", "node-a", time.Minute)
+	b := NewElector(locker, "leader", "node-b", time.Minute)
+	var changes []bool
+	a.OnChange(func(leader bool) { changes = append(changes, leader) })
+
+	a.tick()
+	b.tick()
+	assert.True(t, a.IsLeader())
+	assert.False(t, b.IsLeader())
+	assert.Equal(t, "node-a", b.Leader())
+	ctx, fence, ok := a.Leadership()
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), fence)
+
+	// 锁过期后续期失败，任期上下文取消，其他节点接管并获得更大的token
+	locker.expire("leader")
+	b.tick()
+	a.tick()
+	assert.False(t, a.IsLeader())
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	_, _, ok = a.Leadership()
+	assert.False(t, ok)
+	_, fence, ok = b.Leadership()
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), fence)
+	assert.Equal(t, []bool{true, false}, changes)
+
+	// 主节点退出时释放锁，重复调用不会panic
+	b.Stop()
+	b.Stop()
+	assert.False(t, b.IsLeader())
+	assert.Empty(t, b.Leader())
+}
+
+func TestCoordinator_RunOnlyOnLeaderAndSkipsRunningJob(t *testing.T) {
+	locker := newMemoryLocker()
+	elector := NewElector(locker, "leader", "node-a", time.Minute)
+	c := NewCoordinator(elector)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var runs int
+	c.Register("reconcile", time.Hour, func(ctx context.Context, fence int64) error {
+		runs++
+		close(started)
+		<-release
+		return nil
+	})
+	j := c.jobs["reconcile"]
+
+	// 非主节点不执行
+	c.run(j)
+	assert.Zero(t, runs)
+
+	elector.tick()
+	done := make(chan struct{})
+	go func() {
+		c.run(j)
+		close(done)
+	}()
+	<-started
+
+	// 上一次执行未结束时跳过
+	c.run(j)
+	close(release)
+	<-done
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, int64(1), c.Jobs()[0].Runs)
+
+	c.Stop()
+	c.Stop()
+}
@@ -0,0 +1,305 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/pkg/logger"
+)
+
+// Node 集群节点信息
+type Node struct {
+	ID       string `json:"id"`
+	Address  string `json:"address"`
+	Mode     string `json:"mode"`
+	Capacity int    `json:"capacity"`
+	Draining bool   `json:"draining,omitempty"` // 正在排空连接，不再分配新用户
+}
+
+// Registry 节点注册中心
+type Registry interface {
+	// Start 注册本节点并维持租约
+	Start() error
+	// Stop 注销本节点
+	Stop() error
+	// Nodes 获取当前存活的节点列表
+	Nodes() []*Node
+	// Node 获取指定节点
+	Node(id string) (*Node, bool)
+	// SetDraining 标记本节点是否正在排空连接
+	SetDraining(draining bool) error
+}
+
+// NewRegistry 根据配置创建注册中心，未配置时只包含本节点
+func NewRegistry(cfg *config.RegistryConfig, self *Node) (Registry, error) {
+	switch cfg.Type {
+	case "":
+		return NewLocalRegistry(self), nil
+	case "consul":
+		return NewConsulRegistry(cfg, self), nil
+	default:
+		return nil, fmt.Errorf("unsupported registry type: %s", cfg.Type)
+	}
+}
+
+// LocalRegistry 单节点注册中心
+type LocalRegistry struct {
+	self *Node
+	mu   sync.RWMutex
+}
+
+// NewLocalRegistry 创建单节点注册中心
+func NewLocalRegistry(self *Node) *LocalRegistry {
+	return &LocalRegistry{self: self}
+}
+
+// Start 无需注册
+func (r *LocalRegistry) Start() error {
+	return nil
+}
+
+// Stop 无需注销
+func (r *LocalRegistry) Stop() error {
+	return nil
+}
+
+// Nodes 获取节点列表
+func (r *LocalRegistry) Nodes() []*Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return []*Node{r.self}
+}
+
+// Node 获取指定节点
+func (r *LocalRegistry) Node(id string) (*Node, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if id == r.self.ID {
+		return r.self, true
+	}
+	return nil, false
+}
+
+// SetDraining 标记本节点是否正在排空连接，节点信息整体替换，调用方持有的旧节点不受影响
+func (r *LocalRegistry) SetDraining(draining bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	self := *r.self
+	self.Draining = draining
+	r.self = &self
+	return nil
+}
+
+// ConsulRegistry 基于Consul的注册中心
+// 使用TTL健康检查作为租约，节点宕机后租约过期自动从列表中摘除
+type ConsulRegistry struct {
+	cfg    *config.RegistryConfig
+	self   *Node
+	client *http.Client
+	nodes  map[string]*Node
+	mu     sync.RWMutex
+	done   chan struct{}
+}
+
+// NewConsulRegistry 创建Consul注册中心
+func NewConsulRegistry(cfg *config.RegistryConfig, self *Node) *ConsulRegistry {
+	return &ConsulRegistry{
+		cfg:    cfg,
+		self:   self,
+		client: &http.Client{Timeout: 5 * time.Second},
+		nodes:  map[string]*Node{self.ID: self},
+		done:   make(chan struct{}),
+	}
+}
+
+// consulService Consul服务注册请求
+type consulService struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Address string            `json:"Address"`
+	Meta    map[string]string `json:"Meta"`
+	Check   consulCheck       `json:"Check"`
+}
+
+// consulCheck Consul健康检查
+type consulCheck struct {
+	TTL                            string `json:"TTL"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+// consulHealthEntry Consul健康服务查询结果
+type consulHealthEntry struct {
+	Service struct {
+		ID      string            `json:"ID"`
+		Address string            `json:"Address"`
+		Meta    map[string]string `json:"Meta"`
+	} `json:"Service"`
+}
+
+// Start 注册本节点，并启动租约续期和节点列表刷新
+func (r *ConsulRegistry) Start() error {
+	if err := r.register(); err != nil {
+		return err
+	}
+	if err := r.refresh(); err != nil {
+		logger.Warn("Failed to load cluster nodes", logger.ErrorField(err))
+	}
+
+	go r.keepalive()
+	return nil
+}
+
+// Stop 注销本节点
+func (r *ConsulRegistry) Stop() error {
+	close(r.done)
+	return r.do(http.MethodPut, "/v1/agent/service/deregister/"+r.selfNode().ID, nil, nil)
+}
+
+// Nodes 获取节点列表
+func (r *ConsulRegistry) Nodes() []*Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	nodes := make([]*Node, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// Node 获取指定节点
+func (r *ConsulRegistry) Node(id string) (*Node, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	node, exists := r.nodes[id]
+	return node, exists
+}
+
+// SetDraining 标记本节点是否正在排空连接并重新注册，其他节点在下次刷新节点列表时看到
+func (r *ConsulRegistry) SetDraining(draining bool) error {
+	r.mu.Lock()
+	self := *r.self
+	self.Draining = draining
+	r.self = &self
+	r.nodes[self.ID] = r.self
+	r.mu.Unlock()
+	return r.register()
+}
+
+// selfNode 获取本节点信息
+func (r *ConsulRegistry) selfNode() *Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.self
+}
+
+// register 注册服务
+func (r *ConsulRegistry) register() error {
+	self := r.selfNode()
+	return r.do(http.MethodPut, "/v1/agent/service/register", &consulService{
+		ID:      self.ID,
+		Name:    r.cfg.ServiceName,
+		Address: self.Address,
+		Meta: map[string]string{
+			"mode":     self.Mode,
+			"capacity": strconv.Itoa(self.Capacity),
+			"draining": strconv.FormatBool(self.Draining),
+		},
+		Check: consulCheck{
+			TTL:                            r.cfg.TTL.String(),
+			DeregisterCriticalServiceAfter: (r.cfg.TTL * 10).String(),
+		},
+	}, nil)
+}
+
+// keepalive 定期续约并刷新节点列表
+func (r *ConsulRegistry) keepalive() {
+	ticker := time.NewTicker(r.cfg.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			if err := r.do(http.MethodPut, "/v1/agent/check/pass/service:"+r.selfNode().ID, nil, nil); err != nil {
+				logger.Warn("Failed to renew registry lease, re-registering", logger.ErrorField(err))
+				if err := r.register(); err != nil {
+					logger.Error("Failed to register node", logger.ErrorField(err))
+				}
+			}
+			if err := r.refresh(); err != nil {
+				logger.Warn("Failed to refresh cluster nodes", logger.ErrorField(err))
+			}
+		}
+	}
+}
+
+// refresh 从Consul拉取健康的节点列表
+func (r *ConsulRegistry) refresh() error {
+	var entries []consulHealthEntry
+	if err := r.do(http.MethodGet, "/v1/health/service/"+r.cfg.ServiceName+"?passing=true", nil, &entries); err != nil {
+		return err
+	}
+
+	nodes := make(map[string]*Node, len(entries))
+	for _, entry := range entries {
+		capacity, _ := strconv.Atoi(entry.Service.Meta["capacity"])
+		nodes[entry.Service.ID] = &Node{
+			ID:       entry.Service.ID,
+			Address:  entry.Service.Address,
+			Mode:     entry.Service.Meta["mode"],
+			Capacity: capacity,
+			Draining: entry.Service.Meta["draining"] == "true",
+		}
+	}
+
+	r.mu.Lock()
+	// 本节点始终可见，避免注册中心短暂不可用时把自己摘除
+	nodes[r.self.ID] = r.self
+	r.nodes = nodes
+	r.mu.Unlock()
+	return nil
+}
+
+// do 调用Consul HTTP API
+func (r *ConsulRegistry) do(method, path string, body interface{}, out interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, r.cfg.Address+path, reader)
+	if err != nil {
+		return err
+	}
+	if r.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", r.cfg.Token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("consul returned status %d for %s", resp.StatusCode, path)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
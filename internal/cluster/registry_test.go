@@ -0,0 +1,106 @@
+package cluster
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+)
+
+// fakeConsul 记录注册请求并返回固定健康节点列表的Consul
+type fakeConsul struct {
+	mu         sync.Mutex
+	registered []consulService
+	requests   []string
+	healthy    []consulHealthEntry
+}
+
+func (c *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("X-Consul-Token") != "secret" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch r.URL.Path {
+	case "/v1/agent/service/register":
+		var service consulService
+		json.NewDecoder(r.Body).Decode(&service)
+		c.registered = append(c.registered, service)
+	case "/v1/health/service/im":
+		json.NewEncoder(w).Encode(c.healthy)
+	}
+}
+
+func healthEntry(id, address string, meta map[string]string) consulHealthEntry {
+	var entry consulHealthEntry
+	entry.Service.ID = id
+	entry.Service.Address = address
+	entry.Service.Meta = meta
+	return entry
+}
+
+func TestNewRegistry(t *testing.T) {
+	self := &Node{ID: "n1"}
+	registry, err := NewRegistry(&config.RegistryConfig{}, self)
+	assert.NoError(t, err)
+	node, ok := registry.Node("n1")
+	assert.True(t, ok)
+	assert.Equal(t, self, node)
+	assert.Equal(t, []*Node{self}, registry.Nodes())
+
+	_, err = NewRegistry(&config.RegistryConfig{Type: "etcd"}, self)
+	assert.EqualError(t, err, "unsupported registry type: etcd")
+}
+
+func TestConsulRegistry_RegisterAndRefresh(t *testing.T) {
+	consul := &fakeConsul{healthy: []consulHealthEntry{
+		healthEntry("n2", "10.0.0.2:8080", map[string]string{"mode": "gateway", "capacity": "5000", "draining": "true"}),
+	}}
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	cfg := &config.RegistryConfig{Type: "consul", Address: server.URL, Token: "secret", ServiceName: "im", TTL: time.Hour}
+	r := NewConsulRegistry(cfg, &Node{ID: "n1", Address: "10.0.0.1:8080", Mode: "standalone", Capacity: 1000})
+	assert.NoError(t, r.Start())
+
+	if assert.Len(t, consul.registered, 1) {
+		service := consul.registered[0]
+		assert.Equal(t, "n1", service.ID)
+		assert.Equal(t, "1000", service.Meta["capacity"])
+		assert.Equal(t, "1h0m0s", service.Check.TTL)
+		assert.Equal(t, "10h0m0s", service.Check.DeregisterCriticalServiceAfter)
+	}
+
+	// 本节点不在健康列表中时仍然可见
+	assert.Len(t, r.Nodes(), 2)
+	node, ok := r.Node("n2")
+	assert.True(t, ok)
+	assert.Equal(t, &Node{ID: "n2", Address: "10.0.0.2:8080", Mode: "gateway", Capacity: 5000, Draining: true}, node)
+	_, ok = r.Node("n1")
+	assert.True(t, ok)
+
+	// 排空标记随重新注册上报
+	assert.NoError(t, r.SetDraining(true))
+	self, _ := r.Node("n1")
+	assert.True(t, self.Draining)
+	assert.Equal(t, "true", consul.registered[len(consul.registered)-1].Meta["draining"])
+
+	assert.NoError(t, r.Stop())
+	assert.Contains(t, consul.requests, "PUT /v1/agent/service/deregister/n1")
+}
+
+func TestConsulRegistry_StartFailsWhenRegisterRejected(t *testing.T) {
+	server := httptest.NewServer(&fakeConsul{})
+	defer server.Close()
+
+	cfg := &config.RegistryConfig{Type: "consul", Address: server.URL, ServiceName: "im", TTL: time.Hour}
+	r := NewConsulRegistry(cfg, &Node{ID: "n1"})
+	assert.EqualError(t, r.Start(), "consul returned status 403 for /v1/agent/service/register")
+}
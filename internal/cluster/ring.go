@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/user/im/internal/config"
+)
+
+// Ring 一致性哈希环
+type Ring struct {
+	replicas int
+	hashes   []uint32
+	owners   map[uint32]*Node
+}
+
+// NewRing 创建一致性哈希环，replicas为每个节点的虚拟节点数
+func NewRing(replicas int, nodes []*Node) *Ring {
+	if replicas <= 0 {
+		replicas = 100
+	}
+
+	r := &Ring{
+		replicas: replicas,
+		owners:   make(map[uint32]*Node, len(nodes)*replicas),
+	}
+	for _, node := range nodes {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(node.ID + "#" + strconv.Itoa(i)))
+			if _, exists := r.owners[h]; exists {
+				continue
+			}
+			r.owners[h] = node
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// Get 获取key归属的节点，环为空时返回nil
+func (r *Ring) Get(key string) *Node {
+	if len(r.hashes) == 0 {
+		return nil
+	}
+
+	h := crc32.ChecksumIEEE([]byte(key))
+	idx := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if idx == len(r.hashes) {
+		idx = 0
+	}
+	return r.owners[r.hashes[idx]]
+}
+
+// Router 用户到接入节点的路由
+// 基于注册中心中可接入连接的节点构建哈希环，节点变化时自动重建
+type Router struct {
+	registry Registry
+	replicas int
+	ring     *Ring
+	version  string
+	mu       sync.Mutex
+}
+
+// NewRouter 创建用户路由
+func NewRouter(registry Registry, replicas int) *Router {
+	return &Router{
+		registry: registry,
+		replicas: replicas,
+	}
+}
+
+// HomeNode 获取用户的归属节点
+func (r *Router) HomeNode(userID string) *Node {
+	return r.currentRing().Get(userID)
+}
+
+// currentRing 获取与当前节点列表一致的哈希环
+func (r *Router) currentRing() *Ring {
+	nodes := make([]*Node, 0)
+	for _, node := range r.registry.Nodes() {
+		// 业务节点不接入客户端连接，排空中的节点不再接收新用户，都不参与分配
+		if node.Mode != config.ModeWorker && !node.Draining {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID + "@" + node.Address
+	}
+	version := strings.Join(ids, ",")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ring == nil || r.version != version {
+		r.ring = NewRing(r.replicas, nodes)
+		r.version = version
+	}
+	return r.ring
+}
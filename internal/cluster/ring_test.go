@@ -0,0 +1,61 @@
+package cluster
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing_Empty(t *testing.T) {
+	ring := NewRing(10, nil)
+	assert.Nil(t, ring.Get("user1"))
+}
+
+func TestRing_Stable(t *testing.T) {
+	nodes := []*Node{{ID: "n1"}, {ID: "n2"}, {ID: "n3"}}
+	a := NewRing(100, nodes)
+	b := NewRing(100, []*Node{nodes[2], nodes[0], nodes[1]})
+
+	for i := 0; i < 1000; i++ {
+		key := "user" + strconv.Itoa(i)
+		assert.Equal(t, a.Get(key).ID, b.Get(key).ID)
+	}
+}
+
+func TestRing_MinimalMovement(t *testing.T) {
+	nodes := []*Node{{ID: "n1"}, {ID: "n2"}, {ID: "n3"}}
+	before := NewRing(100, nodes)
+	after := NewRing(100, append(nodes, &Node{ID: "n4"}))
+
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := "user" + strconv.Itoa(i)
+		from, to := before.Get(key), after.Get(key)
+		if from.ID != to.ID {
+			// 新增节点只会从已有节点接管key
+			assert.Equal(t, "n4", to.ID)
+			moved++
+		}
+	}
+	// 理想情况下约1/4的key迁移
+	assert.Greater(t, moved, 1000)
+	assert.Less(t, moved, 4000)
+}
+
+func TestRouter_SkipsWorkers(t *testing.T) {
+	registry := NewLocalRegistry(&Node{ID: "worker-1", Mode: "worker"})
+	router := NewRouter(registry, 10)
+	assert.Nil(t, router.HomeNode("user1"))
+}
+
+func TestRouter_SkipsDraining(t *testing.T) {
+	registry := &testRegistry{nodes: []*Node{{ID: "n1"}, {ID: "n2"}}}
+	router := NewRouter(registry, 10)
+	assert.NotNil(t, router.HomeNode("user1"))
+
+	registry.SetDraining(true)
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, "n2", router.HomeNode("user"+strconv.Itoa(i)).ID)
+	}
+}
@@ -2,26 +2,99 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"os"
 	"time"
 
 	"github.com/spf13/viper"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/idgen"
+	"github.com/user/im/pkg/realip"
 )
 
 // StoreConfig 存储配置
 type StoreConfig struct {
-	Type        string `mapstructure:"type"`
-	LevelDBPath string `mapstructure:"leveldb_path"`
+	Type          string        `mapstructure:"type"`
+	LevelDBPath   string        `mapstructure:"leveldb_path"`
+	SlowThreshold time.Duration `mapstructure:"slow_threshold"` // LevelDB操作超过该耗时时记录慢操作日志
 }
 
 // Config 应用配置
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	Kafka    KafkaConfig    `mapstructure:"kafka"`
-	Log      LogConfig      `mapstructure:"log"`
-	Monitor  MonitorConfig  `mapstructure:"monitor"`
-	Store    StoreConfig    `mapstructure:"store"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Redis     RedisConfig     `mapstructure:"redis"`
+	Kafka     KafkaConfig     `mapstructure:"kafka"`
+	Log       LogConfig       `mapstructure:"log"`
+	Monitor   MonitorConfig   `mapstructure:"monitor"`
+	Store     StoreConfig     `mapstructure:"store"`
+	Cluster   ClusterConfig   `mapstructure:"cluster"`
+	Admin     AdminConfig     `mapstructure:"admin"`
+	Presence  PresenceConfig  `mapstructure:"presence"`
+	Group     GroupConfig     `mapstructure:"group"`
+	Spam      SpamConfig      `mapstructure:"spam"`
+	Draft     DraftConfig     `mapstructure:"draft"`
+	Settings  SettingsConfig  `mapstructure:"settings"`
+	Preview   PreviewConfig   `mapstructure:"preview"`
+	Voice     VoiceConfig     `mapstructure:"voice"`
+	I18n      I18nConfig      `mapstructure:"i18n"`
+	APIKey    APIKeyConfig    `mapstructure:"api_key"`
+	Analytics AnalyticsConfig `mapstructure:"analytics"`
+	Stats     StatsConfig     `mapstructure:"stats"`
+	Client    ClientConfig    `mapstructure:"client"`
+	Flags     FlagsConfig     `mapstructure:"feature_flags"`
+	Quota     QuotaConfig     `mapstructure:"quota"`
+	Challenge ChallengeConfig `mapstructure:"challenge"`
+	TwoFactor TwoFactorConfig `mapstructure:"two_factor"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	Org       OrgConfig       `mapstructure:"org"`
+	Stickers  StickersConfig  `mapstructure:"stickers"`
+	Upload    UploadConfig    `mapstructure:"upload"`
+	Media     MediaConfig     `mapstructure:"media"`
+	// MediaStorage 媒体存储统计和生命周期
+	MediaStorage MediaStorageConfig `mapstructure:"media_storage"`
+	// WordFilter 敏感词过滤
+	WordFilter WordFilterConfig `mapstructure:"word_filter"`
+	// LinkSafety 链接安全检查
+	LinkSafety LinkSafetyConfig `mapstructure:"link_safety"`
+	// DeliverySLO 投递耗时分阶段统计和SLO报告
+	DeliverySLO DeliverySLOConfig `mapstructure:"delivery_slo"`
+	// Canary 合成探测，接入节点定期在内置的探测用户之间发送消息
+	Canary CanaryConfig `mapstructure:"canary"`
+	// Spool Kafka或Redis不可用时的本地暂存
+	Spool SpoolConfig `mapstructure:"spool"`
+	// Recovery 重新投递长时间未送达的消息
+	Recovery RecoveryConfig `mapstructure:"recovery"`
+	// ID 消息等实体的ID生成策略
+	ID IDConfig `mapstructure:"id"`
+	// Lock 跨节点的分布式锁
+	Lock LockConfig `mapstructure:"lock"`
+	// MessageCache 消息查询缓存
+	MessageCache MessageCacheConfig `mapstructure:"message_cache"`
+	// API HTTP API的版本协商和响应格式
+	API APIConfig `mapstructure:"api"`
+	// Official 公众号
+	Official OfficialConfig `mapstructure:"official"`
+	// Guest 只读访客令牌
+	Guest GuestConfig `mapstructure:"guest"`
+	// GroupEvents 群活动消息
+	GroupEvents GroupEventConfig `mapstructure:"group_events"`
+	// Polls 群投票消息
+	Polls PollConfig `mapstructure:"polls"`
+	// Bandwidth 连接流量统计和限速
+	Bandwidth BandwidthConfig `mapstructure:"bandwidth"`
+	// HTTP gin运行模式和中间件
+	HTTP HTTPConfig `mapstructure:"http"`
+	// Components 组件开关和健康检查
+	Components ComponentsConfig `mapstructure:"components"`
+	// Federation 与其他部署互通的跨域联邦
+	Federation FederationConfig `mapstructure:"federation"`
+	// Admission 重连风暴时的握手准入控制
+	Admission AdmissionConfig `mapstructure:"admission"`
+	// Notification 新消息推送提示中的通知内容
+	Notification NotificationConfig `mapstructure:"notification"`
+	// GraphQL 面向Web客户端的GraphQL查询接口
+	GraphQL GraphQLConfig `mapstructure:"graphql"`
 }
 
 // ServerConfig 服务器配置
@@ -33,6 +106,12 @@ type ServerConfig struct {
 	MaxConnections    int           `mapstructure:"max_connections"`
 	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
 	MaxMessageSize    int64         `mapstructure:"max_message_size"`
+	AllowedOrigins    []string      `mapstructure:"allowed_origins"` // WebSocket允许的浏览器来源，为空时允许所有来源
+	Protocols         []string      `mapstructure:"protocols"`       // 启用的WebSocket帧协议版本，按偏好排列，为空时启用全部
+	AckWindow         int           `mapstructure:"ack_window"`      // 每个连接最多未确认的新消息数，0表示不限制
+	AckQueue          int           `mapstructure:"ack_queue"`       // 窗口已满时每个连接在服务端排队的最多消息数
+	// FrameLimits 按帧类型覆盖上行负载的大小上限（字节），未配置的类型使用帧注册表中的默认值
+	FrameLimits map[string]int `mapstructure:"frame_limits"`
 }
 
 // DatabaseConfig 数据库配置
@@ -46,15 +125,50 @@ type DatabaseConfig struct {
 	Charset  string `mapstructure:"charset"`
 	MaxIdle  int    `mapstructure:"max_idle"`
 	MaxOpen  int    `mapstructure:"max_open"`
+	// SlowThreshold SQL超过该耗时时记录慢查询日志
+	SlowThreshold time.Duration `mapstructure:"slow_threshold"`
+	// LogLevel SQL日志级别：silent、error、warn（慢查询）或info（全部SQL），经由应用日志输出
+	LogLevel string `mapstructure:"log_level"`
+	// LogParams 日志中的SQL是否带参数值，默认以占位符代替
+	LogParams bool `mapstructure:"log_params"`
 }
 
+// SQL日志级别
+const (
+	DatabaseLogSilent = "silent"
+	DatabaseLogError  = "error"
+	DatabaseLogWarn   = "warn"
+	DatabaseLogInfo   = "info"
+)
+
 // RedisConfig Redis配置
 type RedisConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	Password string `mapstructure:"password"`
-	Database int    `mapstructure:"database"`
-	PoolSize int    `mapstructure:"pool_size"`
+	Host     string    `mapstructure:"host"`
+	Port     int       `mapstructure:"port"`
+	Password string    `mapstructure:"password"`
+	Database int       `mapstructure:"database"`
+	PoolSize int       `mapstructure:"pool_size"`
+	Username string    `mapstructure:"username"` // Redis 6 ACL用户名，为空时使用default用户
+	TLS      TLSConfig `mapstructure:"tls"`
+	// SlowThreshold 命令或管道超过该耗时时记录慢操作日志
+	SlowThreshold time.Duration `mapstructure:"slow_threshold"`
+}
+
+// TLSConfig 客户端TLS配置，证书文件更新后在下次建立连接时自动重新加载
+type TLSConfig struct {
+	Enabled            bool   `mapstructure:"enabled"`
+	CAFile             string `mapstructure:"ca_file"`   // 校验服务端证书的CA，为空时使用系统根证书
+	CertFile           string `mapstructure:"cert_file"` // 双向TLS的客户端证书，与key_file同时设置
+	KeyFile            string `mapstructure:"key_file"`
+	ServerName         string `mapstructure:"server_name"`          // 覆盖校验证书时使用的主机名
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // 不校验服务端证书，仅用于测试
+}
+
+// KafkaSASLConfig Kafka SASL认证配置
+type KafkaSASLConfig struct {
+	Mechanism string `mapstructure:"mechanism"` // plain、scram-sha-256 或 scram-sha-512，为空时不认证
+	Username  string `mapstructure:"username"`
+	Password  string `mapstructure:"password"`
 }
 
 // KafkaConfig Kafka配置
@@ -65,7 +179,52 @@ type KafkaConfig struct {
 		MessageQueue string `mapstructure:"message_queue"`
 		GroupChat    string `mapstructure:"group_chat"`
 		OfflineMsg   string `mapstructure:"offline_msg"`
+		// GatewayUpstream 网关转发给业务节点的客户端帧
+		GatewayUpstream string `mapstructure:"gateway_upstream"`
+		// GatewayPush 业务节点下发给网关的推送前缀，实际主题为 <prefix>.<node_id>
+		GatewayPush string `mapstructure:"gateway_push"`
+		// Moderation 反垃圾等内容治理事件
+		Moderation string `mapstructure:"moderation"`
+		// LinkPreview 待抓取链接预览的消息
+		LinkPreview string `mapstructure:"link_preview"`
+		// MediaProcessing 待提取语音时长和波形的消息
+		MediaProcessing string `mapstructure:"media_processing"`
+		// Events 对外发布的规范事件流，为空时不发布
+		Events string `mapstructure:"events"`
 	} `mapstructure:"topics"`
+	Provision KafkaProvisionConfig `mapstructure:"provision"`
+	// DedupTTL 消费端记录已处理消息ID的时长，需覆盖消费者重启后可能重投的范围
+	DedupTTL time.Duration       `mapstructure:"dedup_ttl"`
+	Consumer KafkaConsumerConfig `mapstructure:"consumer"`
+	TLS      TLSConfig           `mapstructure:"tls"`
+	SASL     KafkaSASLConfig     `mapstructure:"sasl"`
+}
+
+// KafkaConsumerConfig 消费者并发配置
+type KafkaConsumerConfig struct {
+	Workers   int                          `mapstructure:"workers"`    // 每个主题的worker数，同一会话的消息由同一worker按序处理
+	QueueSize int                          `mapstructure:"queue_size"` // 每个worker的缓冲记录数，满时暂停读取
+	Overrides map[string]KafkaConsumerSpec `mapstructure:"overrides"`  // 按主题名覆盖
+}
+
+// KafkaConsumerSpec 单个主题的消费并发，0表示使用默认值
+type KafkaConsumerSpec struct {
+	Workers   int `mapstructure:"workers"`
+	QueueSize int `mapstructure:"queue_size"`
+}
+
+// KafkaProvisionConfig 启动时自动创建主题的配置
+type KafkaProvisionConfig struct {
+	Enabled           bool                      `mapstructure:"enabled"`            // 启动时创建缺失的主题，已存在的主题不做修改
+	Partitions        int                       `mapstructure:"partitions"`         // 默认分区数
+	ReplicationFactor int                       `mapstructure:"replication_factor"` // 默认副本数
+	Overrides         map[string]KafkaTopicSpec `mapstructure:"overrides"`          // 按主题名覆盖分区数和副本数
+}
+
+// KafkaTopicSpec 单个主题的分区数和副本数，0表示使用默认值
+type KafkaTopicSpec struct {
+	Partitions        int `mapstructure:"partitions"`
+	ReplicationFactor int `mapstructure:"replication_factor"`
 }
 
 // LogConfig 日志配置
@@ -81,9 +240,579 @@ type LogConfig struct {
 
 // MonitorConfig 监控配置
 type MonitorConfig struct {
-	Enabled bool   `mapstructure:"enabled"`
-	Port    int    `mapstructure:"port"`
-	Path    string `mapstructure:"path"`
+	Enabled     bool   `mapstructure:"enabled"`
+	Port        int    `mapstructure:"port"`
+	Path        string `mapstructure:"path"`
+	Diagnostics bool   `mapstructure:"diagnostics"` // 在监控端口上开放pprof和expvar，需要管理员令牌
+}
+
+// 运行模式
+const (
+	ModeMonolith = "monolith" // 单体模式：接入与业务在同一进程
+	ModeGateway  = "gateway"  // 网关模式：仅负责连接接入
+	ModeWorker   = "worker"   // 业务模式：仅负责业务逻辑
+)
+
+// ClusterConfig 集群配置
+type ClusterConfig struct {
+	Mode          string         `mapstructure:"mode"`
+	NodeID        string         `mapstructure:"node_id"`
+	AdvertiseAddr string         `mapstructure:"advertise_addr"`
+	Capacity      int            `mapstructure:"capacity"`
+	RouteTTL      time.Duration  `mapstructure:"route_ttl"`
+	Registry      RegistryConfig `mapstructure:"registry"`
+	Routing       RoutingConfig  `mapstructure:"routing"`
+	Leader        LeaderConfig   `mapstructure:"leader"`
+	Drain         DrainConfig    `mapstructure:"drain"`
+}
+
+// DrainConfig 滚动发布时排空连接的配置
+type DrainConfig struct {
+	Spread        time.Duration `mapstructure:"spread"`         // 在该时长内分批推送完重连提示
+	BatchInterval time.Duration `mapstructure:"batch_interval"` // 每批推送的间隔
+	Threshold     int           `mapstructure:"threshold"`      // 连接数不超过该值时结束排空，0表示等待全部断开
+	Timeout       time.Duration `mapstructure:"timeout"`        // 最长排空时间，超时后不论剩余连接数都结束
+	Scheme        string        `mapstructure:"scheme"`         // 重连地址的协议: ws / wss
+}
+
+// LeaderConfig 主节点选举配置
+type LeaderConfig struct {
+	Key string        `mapstructure:"key"`
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// RoutingConfig 用户归属节点路由配置
+type RoutingConfig struct {
+	Enabled           bool `mapstructure:"enabled"`
+	VirtualNodes      int  `mapstructure:"virtual_nodes"`
+	RedirectOnUpgrade bool `mapstructure:"redirect_on_upgrade"`
+}
+
+// RegistryConfig 节点注册中心配置
+type RegistryConfig struct {
+	Type        string        `mapstructure:"type"` // 为空表示不启用，可选: consul
+	Address     string        `mapstructure:"address"`
+	Token       string        `mapstructure:"token"`
+	ServiceName string        `mapstructure:"service_name"`
+	TTL         time.Duration `mapstructure:"ttl"`
+}
+
+// PresenceConfig 在线状态配置
+type PresenceConfig struct {
+	Debounce         time.Duration `mapstructure:"debounce"`
+	MaxSubscriptions int           `mapstructure:"max_subscriptions"`
+	MaxWatch         int           `mapstructure:"max_watch"` // 每个连接经subscribe_presence最多关注的用户数
+}
+
+// GroupConfig 群组配置
+type GroupConfig struct {
+	MaxMembers        int           `mapstructure:"max_members"`
+	ChannelMaxMembers int           `mapstructure:"channel_max_members"`
+	FanoutBatchSize   int           `mapstructure:"fanout_batch_size"`
+	CacheTTL          time.Duration `mapstructure:"cache_ttl"`          // Redis中成员缓存的过期时间
+	ReconcileInterval time.Duration `mapstructure:"reconcile_interval"` // 主节点对比成员缓存与数据库的间隔
+	ReconcileBatch    int           `mapstructure:"reconcile_batch"`    // 对账时每批遍历的群组数
+	// 群组未设置回执粒度时的默认值：none、aggregate或full；超大群不能使用full，避免每条消息按成员保存回执
+	Receipts        model.ReceiptMode `mapstructure:"receipts"`
+	ChannelReceipts model.ReceiptMode `mapstructure:"channel_receipts"`
+	ReceiptTTL      time.Duration     `mapstructure:"receipt_ttl"` // aggregate粒度的人数在Redis中的保留时间
+}
+
+// SpamConfig 垃圾消息检测配置，阈值为统计窗口内的计数，0表示不启用该规则
+type SpamConfig struct {
+	Enabled            bool          `mapstructure:"enabled"`
+	Window             time.Duration `mapstructure:"window"`
+	DuplicateThreshold int           `mapstructure:"duplicate_threshold"`
+	MassDMThreshold    int           `mapstructure:"mass_dm_threshold"`
+	URLThreshold       int           `mapstructure:"url_threshold"`
+	Throttle           time.Duration `mapstructure:"throttle"`
+}
+
+// WordFilterConfig 敏感词过滤配置
+// 词库目录下每种语言一个子目录，每行一个词，#开头为注释；*.strict.txt只在严格级别使用，其余*.txt为标准词库
+type WordFilterConfig struct {
+	Enabled   bool     `mapstructure:"enabled"`
+	Dir       string   `mapstructure:"dir"`
+	Languages []string `mapstructure:"languages"` // 加载的语言子目录，为空时加载全部
+	Action    string   `mapstructure:"action"`    // mask把敏感词替换为*，reject拒绝发送
+}
+
+// 敏感词过滤动作
+const (
+	WordFilterMask   = "mask"   // 把敏感词替换为*后发送
+	WordFilterReject = "reject" // 拒绝发送
+)
+
+// LinkSafetyConfig 链接安全检查配置，文本消息中的链接按域名黑名单和可选的外部信誉服务检查
+type LinkSafetyConfig struct {
+	Enabled           bool          `mapstructure:"enabled"`
+	Blocklist         []string      `mapstructure:"blocklist"`          // 屏蔽的域名，同时屏蔽其子域名
+	BlocklistFiles    []string      `mapstructure:"blocklist_files"`    // 域名黑名单文件，每行一个域名，#开头为注释
+	ReputationURL     string        `mapstructure:"reputation_url"`     // 外部信誉服务地址，为空时只检查黑名单
+	ReputationTimeout time.Duration `mapstructure:"reputation_timeout"` // 单次查询超时，超时或失败时放行且不缓存
+	CacheTTL          time.Duration `mapstructure:"cache_ttl"`          // 信誉查询结果在Redis中的缓存时长
+	Action            string        `mapstructure:"action"`             // rewrite把链接替换为replacement，reject拒绝发送
+	Replacement       string        `mapstructure:"replacement"`
+}
+
+// 链接安全检查动作
+const (
+	LinkSafetyRewrite = "rewrite" // 把链接替换为replacement后发送
+	LinkSafetyReject  = "reject"  // 拒绝发送
+)
+
+// DraftConfig 草稿配置
+type DraftConfig struct {
+	MaxSize int           `mapstructure:"max_size"` // 草稿内容最大字节数
+	TTL     time.Duration `mapstructure:"ttl"`      // 草稿最后一次更新后的保留时长
+}
+
+// SettingsConfig 用户偏好设置同步配置
+type SettingsConfig struct {
+	MaxKeys int `mapstructure:"max_keys"` // 每个用户最多的设置项数
+	MaxSize int `mapstructure:"max_size"` // 全部设置项序列化后的最大字节数
+}
+
+// PreviewConfig 链接预览抓取配置
+type PreviewConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Timeout     time.Duration `mapstructure:"timeout"`       // 单次抓取超时
+	MaxBodySize int64         `mapstructure:"max_body_size"` // 读取的页面最大字节数
+	CacheTTL    time.Duration `mapstructure:"cache_ttl"`     // 预览结果缓存时长，抓取失败的结果缓存其十分之一
+	UserAgent   string        `mapstructure:"user_agent"`
+}
+
+// VoiceConfig 语音消息处理配置，发送后从媒体服务下载音频，提取时长和波形
+type VoiceConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	MediaHosts   []string      `mapstructure:"media_hosts"`   // 允许下载音频的媒体服务主机，默认取client.media_upload_url和upload.s3.public_url的主机
+	Timeout      time.Duration `mapstructure:"timeout"`       // 单次下载超时
+	MaxFileSize  int64         `mapstructure:"max_file_size"` // 下载的音频最大字节数，超过时不处理
+	WaveformBars int           `mapstructure:"waveform_bars"` // 波形降采样后的点数
+}
+
+// I18nConfig 系统消息多语言配置
+type I18nConfig struct {
+	DefaultLanguage string `mapstructure:"default_language"` // 用户未设置偏好语言时使用
+	LocalesDir      string `mapstructure:"locales_dir"`      // 额外语言包目录，<语言>.json 覆盖或新增内置语言
+}
+
+// APIKeyConfig 服务间调用API密钥配置
+type APIKeyConfig struct {
+	DefaultRateLimit int           `mapstructure:"default_rate_limit"` // 创建密钥未指定限额时每分钟最多发送的消息数
+	CacheTTL         time.Duration `mapstructure:"cache_ttl"`          // 已验证密钥的缓存时长
+}
+
+// AnalyticsConfig 运营统计配置，需要MySQL
+type AnalyticsConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Interval  time.Duration `mapstructure:"interval"`  // 主节点采样连接峰值并汇总到统计表的间隔
+	Retention time.Duration `mapstructure:"retention"` // Redis中按日计数的保留时长，需超过一天加汇总间隔
+}
+
+// DeliverySLOConfig 投递耗时统计配置，采样的消息在发送、存储、入队、推送和客户端确认时记录时间
+type DeliverySLOConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	SampleRate float64       `mapstructure:"sample_rate"` // 记录阶段耗时的消息比例，0-1
+	Target     time.Duration `mapstructure:"target"`      // 从发送到客户端确认的目标耗时
+	Objective  float64       `mapstructure:"objective"`   // 目标耗时内完成投递的比例目标，如0.99
+	TraceTTL   time.Duration `mapstructure:"trace_ttl"`   // 推送后等待客户端确认的时长，超时的确认不计入
+	Retention  time.Duration `mapstructure:"retention"`   // Redis中按日统计的保留时长，决定SLO报告可查询的范围
+}
+
+// CanaryConfig 合成探测配置，每个接入节点登录一个探测用户，定期给自己和其他接入节点的探测用户发送消息
+type CanaryConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	Interval         time.Duration `mapstructure:"interval"`          // 探测间隔
+	Timeout          time.Duration `mapstructure:"timeout"`           // 等待探测消息送达的时长
+	FailureThreshold int           `mapstructure:"failure_threshold"` // 本节点探测连续失败该次数后/readyz返回未就绪
+	UserPrefix       string        `mapstructure:"user_prefix"`       // 探测用户ID前缀，后接节点ID，客户端不能以该前缀登录
+}
+
+// SpoolConfig 本地暂存配置，Kafka或Redis不可用时离线消息和群聊消息的投递事件写入本地磁盘，恢复后按顺序重放
+type SpoolConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Path           string        `mapstructure:"path"`            // 暂存区LevelDB目录，每个节点独立
+	ReplayInterval time.Duration `mapstructure:"replay_interval"` // 重放间隔
+	MaxEntries     int           `mapstructure:"max_entries"`     // 最多暂存的事件数，超过后发送失败
+}
+
+// RecoveryConfig 投递恢复配置，启动时和定期扫描超过阈值仍为已发送状态的私聊消息并重新投递，需要MySQL
+type RecoveryConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Threshold time.Duration `mapstructure:"threshold"`  // 发送后超过该时长仍未送达的消息视为投递中断
+	MaxAge    time.Duration `mapstructure:"max_age"`    // 只扫描该时长内发送的消息，同一条消息在此期间最多重新投递一次
+	Interval  time.Duration `mapstructure:"interval"`   // 主节点定期扫描的间隔
+	BatchSize int           `mapstructure:"batch_size"` // 每次从MySQL读取的消息数
+}
+
+// ID生成策略可单独配置的组件
+const (
+	IDComponentMessage = "message" // 消息ID，包括群系统消息
+	IDComponentGroup   = "group"   // 群组和群成员ID
+)
+
+// IDConfig ID生成配置，切换策略只影响之后生成的ID，已有ID保持不变
+type IDConfig struct {
+	Strategy  string            `mapstructure:"strategy"`   // snowflake、ulid或ksuid
+	MachineID uint16            `mapstructure:"machine_id"` // snowflake的机器ID，多个节点不能重复
+	Overrides map[string]IDSpec `mapstructure:"overrides"`  // 按组件单独配置，各自使用独立的ID空间
+}
+
+// IDSpec 单个组件的ID生成策略，未设置的部分使用全局配置
+type IDSpec struct {
+	Strategy  string `mapstructure:"strategy"`
+	MachineID uint16 `mapstructure:"machine_id"`
+}
+
+// LockConfig 分布式锁配置，同一群组的成员变更和计数回填在节点间串行执行
+type LockConfig struct {
+	TTL  time.Duration `mapstructure:"ttl"`  // 锁的过期时间，持有期间每三分之一个ttl自动续期
+	Wait time.Duration `mapstructure:"wait"` // 获取锁的最长等待时间，超时后请求返回conflict
+}
+
+// MessageCacheConfig 消息查询缓存配置，Redis缓存未命中的并发查询合并为一次存储读取
+type MessageCacheConfig struct {
+	NegativeTTL  time.Duration `mapstructure:"negative_ttl"`  // 不存在的消息ID在Redis中的负缓存时长
+	LocalEnabled bool          `mapstructure:"local_enabled"` // 在Redis之前使用节点本地的LRU缓存热点消息
+	LocalSize    int           `mapstructure:"local_size"`    // 本地缓存的消息数
+	LocalTTL     time.Duration `mapstructure:"local_ttl"`     // 本地缓存的过期时间，消息变更时通过发布订阅通知各节点提前删除
+}
+
+// APIConfig HTTP API配置，各版本共用同一组处理函数，由版本协商层转换v2的请求和响应格式
+type APIConfig struct {
+	DefaultVersion int    `mapstructure:"default_version"` // 未带版本号的/api请求在没有X-API-Version请求头或Accept版本时使用的版本
+	FieldCase      string `mapstructure:"field_case"`      // v2请求和响应的JSON字段命名：snake或camel，v1始终为snake
+}
+
+// API JSON字段命名
+const (
+	FieldCaseSnake = "snake" // message_id
+	FieldCaseCamel = "camel" // messageId
+)
+
+// OfficialConfig 公众号配置，公众号通过管理接口保存在Redis中
+type OfficialConfig struct {
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"` // 定期从Redis重新加载，兜底错过的变更通知
+	MaxAccounts     int           `mapstructure:"max_accounts"`     // 公众号总数上限
+	MaxMenuItems    int           `mapstructure:"max_menu_items"`   // 菜单和每个子菜单的菜单项数上限
+	BroadcastBatch  int           `mapstructure:"broadcast_batch"`  // 群发时每批读取的关注者数
+}
+
+// GuestConfig 只读访客令牌配置，令牌保存在Redis中，读取历史消息需要MySQL
+type GuestConfig struct {
+	DefaultTTL        time.Duration `mapstructure:"default_ttl"`          // 签发时未指定有效期时的有效期
+	MaxTTL            time.Duration `mapstructure:"max_ttl"`              // 有效期上限
+	MaxTokensPerGroup int           `mapstructure:"max_tokens_per_group"` // 单个群组同时有效的令牌数上限
+	PollInterval      time.Duration `mapstructure:"poll_interval"`        // SSE连接查询新消息的间隔
+}
+
+// GroupEventConfig 群活动消息配置，答复和提醒保存在Redis中
+type GroupEventConfig struct {
+	DefaultRemindBefore time.Duration `mapstructure:"default_remind_before"` // 活动未指定提醒时间时开始前多久提醒
+	ReminderInterval    time.Duration `mapstructure:"reminder_interval"`     // 主节点检查到期提醒的间隔
+}
+
+// PollConfig 群投票消息配置，投票结果保存在Redis中
+type PollConfig struct {
+	MaxOptions    int           `mapstructure:"max_options"`    // 单个投票的选项数上限
+	CloseInterval time.Duration `mapstructure:"close_interval"` // 主节点检查到期投票的间隔
+}
+
+// BandwidthConfig 连接流量统计和限速配置，用户流量按分钟累加到Redis，滚动窗口内的合计跨节点可见
+type BandwidthConfig struct {
+	FlushInterval           time.Duration `mapstructure:"flush_interval"`             // 各节点把本地连接流量累加到Redis的间隔
+	Window                  time.Duration `mapstructure:"window"`                     // 用户流量的滚动窗口，按分钟取整
+	MaxBytesPerSecond       int64         `mapstructure:"max_bytes_per_second"`       // 每个连接的默认下行限速，0表示不限制
+	UserWindowLimit         int64         `mapstructure:"user_window_limit"`          // 用户在窗口内收发字节合计上限，超出后限速，0表示不限制
+	ThrottledBytesPerSecond int64         `mapstructure:"throttled_bytes_per_second"` // 超出上限的用户的下行限速
+}
+
+// AdmissionConfig 握手准入控制配置，节点重启后大量客户端同时重连时按令牌桶限制每个节点接受WebSocket握手的速率
+type AdmissionConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	Rate            float64       `mapstructure:"rate"`             // 每秒接受的握手数
+	Burst           int           `mapstructure:"burst"`            // 令牌桶容量，默认等于rate
+	PriorityReserve float64       `mapstructure:"priority_reserve"` // 令牌桶中只供有待投递紧急消息的用户使用的比例
+	MinRetryAfter   time.Duration `mapstructure:"min_retry_after"`  // 被拒绝的客户端最短等待多久重连
+	MaxRetryAfter   time.Duration `mapstructure:"max_retry_after"`  // 被拒绝的客户端最长等待多久重连
+}
+
+// NotificationConfig 新消息推送提示中的通知内容和活跃群消息摘要配置
+// 启用后推送提示附带会话合并键、按消息类型和接收者语言渲染的标题和正文，私聊消息还附带未读总数作为角标
+type NotificationConfig struct {
+	Enabled       bool `mapstructure:"enabled"`
+	MaxBodyLength int  `mapstructure:"max_body_length"` // 文本消息正文截取的最大字符数
+	// DigestWindow 群消息摘要窗口，群在窗口内的消息数超过DigestThreshold后，不在线的成员在每个窗口内只收到一条摘要提示，0为不合并
+	DigestWindow    time.Duration `mapstructure:"digest_window"`
+	DigestThreshold int           `mapstructure:"digest_threshold"`
+}
+
+// GraphQLConfig GraphQL查询接口配置，接口只读，与用户API共用认证和限流
+type GraphQLConfig struct {
+	Enabled       bool `mapstructure:"enabled"`
+	MaxDepth      int  `mapstructure:"max_depth"`      // 选择集的最大嵌套深度
+	MaxFields     int  `mapstructure:"max_fields"`     // 单次查询的最多字段数
+	MaxComplexity int  `mapstructure:"max_complexity"` // 单次查询在所有结果对象上解析的最多字段数，列表中的每个对象单独计数
+}
+
+// HTTPConfig gin运行模式和HTTP中间件配置
+type HTTPConfig struct {
+	Mode                string              `mapstructure:"mode"`                 // gin运行模式：release、debug或test，默认release
+	DisabledMiddlewares []string            `mapstructure:"disabled_middlewares"` // 关闭的中间件：recovery、access_log、auth、rate_limit、cors、metrics
+	RequireToken        bool                `mapstructure:"require_token"`        // 带X-User-ID的用户请求必须携带与之一致的IM令牌
+	RateLimit           HTTPRateLimitConfig `mapstructure:"rate_limit"`
+	CORS                CORSConfig          `mapstructure:"cors"`
+	// TrustedProxies 可信代理（负载均衡、反向代理）的CIDR或IP，HTTP请求和WebSocket握手只采信来自这些地址的X-Forwarded-For
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// HTTPRateLimitConfig 用户API的限流配置，按用户（未带X-User-ID时按客户端IP）在Redis中计数，各节点共享
+type HTTPRateLimitConfig struct {
+	Requests int           `mapstructure:"requests"` // 每个窗口内的最多请求数，0表示不限制
+	Window   time.Duration `mapstructure:"window"`
+}
+
+// CORSConfig 浏览器跨域访问配置
+type CORSConfig struct {
+	AllowedOrigins []string      `mapstructure:"allowed_origins"` // 允许的来源，*表示任意来源，为空时不允许跨域
+	AllowedHeaders []string      `mapstructure:"allowed_headers"` // 预检请求允许的请求头
+	MaxAge         time.Duration `mapstructure:"max_age"`         // 预检结果的缓存时长
+}
+
+// HTTP中间件名称
+const (
+	MiddlewareRecovery  = "recovery"
+	MiddlewareAccessLog = "access_log"
+	MiddlewareAuth      = "auth"
+	MiddlewareRateLimit = "rate_limit"
+	MiddlewareCORS      = "cors"
+	MiddlewareMetrics   = "metrics"
+)
+
+// MiddlewareEnabled 中间件是否启用
+func (c HTTPConfig) MiddlewareEnabled(name string) bool {
+	for _, disabled := range c.DisabledMiddlewares {
+		if disabled == name {
+			return false
+		}
+	}
+	return true
+}
+
+// ComponentsConfig 服务器组件配置，组件名称见 /admin/v1/components
+type ComponentsConfig struct {
+	Disabled       []string      `mapstructure:"disabled"`        // 不启动的后台组件，如worker、jobs、kafka_consumers；存储不能关闭
+	HealthInterval time.Duration `mapstructure:"health_interval"` // 组件健康检查间隔
+}
+
+// FederationConfig 跨域联邦配置，用户地址为 user@domain，发给其他域的消息经签名后投递到对方的收件接口
+type FederationConfig struct {
+	Enabled       bool                   `mapstructure:"enabled"`
+	Domain        string                 `mapstructure:"domain"`         // 本部署的域名
+	Endpoint      string                 `mapstructure:"endpoint"`       // 本部署对外的联邦接口地址，发布在发现文档中
+	KeyID         string                 `mapstructure:"key_id"`         // 签名密钥标识，轮换密钥时修改
+	PrivateKey    string                 `mapstructure:"private_key"`    // Ed25519私钥种子（32字节），base64编码
+	InboundTopic  string                 `mapstructure:"inbound_topic"`  // Kafka传输的接收主题，为空时只接收HTTP
+	Discovery     bool                   `mapstructure:"discovery"`      // 未配置的域通过 https://<domain>/.well-known/im-federation 发现，默认关闭
+	DiscoveryTTL  time.Duration          `mapstructure:"discovery_ttl"`  // 发现结果的缓存时长
+	Timeout       time.Duration          `mapstructure:"timeout"`        // 发送和发现请求的超时时间
+	MaxClockSkew  time.Duration          `mapstructure:"max_clock_skew"` // 接收时允许的发送时间偏差，超出的事件视为重放
+	FlushInterval time.Duration          `mapstructure:"flush_interval"` // 主节点投递发件队列的间隔
+	BatchSize     int                    `mapstructure:"batch_size"`     // 每个远程域每次最多投递的事件数
+	Retry         FederationRetryConfig  `mapstructure:"retry"`
+	Peers         []FederationPeerConfig `mapstructure:"peers"`
+}
+
+// FederationRetryConfig 投递失败后的重试，同一远程域的事件按顺序投递，队首失败时整个队列退避
+type FederationRetryConfig struct {
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+	MaxAttempts    int           `mapstructure:"max_attempts"` // 单个事件的最多投递次数，超出后转入死信队列
+}
+
+// FederationPeerConfig 静态配置的远程域，优先于发现
+type FederationPeerConfig struct {
+	Domain    string `mapstructure:"domain"`
+	Endpoint  string `mapstructure:"endpoint"`
+	Transport string `mapstructure:"transport"` // http（默认）或kafka，kafka要求双方共用Kafka集群或已配置镜像
+	Topic     string `mapstructure:"topic"`     // kafka传输的目标主题，即对方的inbound_topic
+	KeyID     string `mapstructure:"key_id"`
+	PublicKey string `mapstructure:"public_key"` // 对方的Ed25519公钥，base64编码
+}
+
+// StatsConfig 运行统计配置
+type StatsConfig struct {
+	Interval time.Duration `mapstructure:"interval"` // 计算发送速率并上报节点快照的间隔
+}
+
+// ClientConfig 下发给客户端的配置，功能开关可通过管理接口在Redis中覆盖
+type ClientConfig struct {
+	HeartbeatInterval time.Duration   `mapstructure:"heartbeat_interval"` // 默认取server.heartbeat_interval
+	MaxMessageSize    int64           `mapstructure:"max_message_size"`   // 默认取server.max_message_size
+	MediaUploadURL    string          `mapstructure:"media_upload_url"`
+	Features          map[string]bool `mapstructure:"features"`
+	RefreshInterval   time.Duration   `mapstructure:"refresh_interval"` // 定期从Redis重新加载，兜底错过的变更通知
+}
+
+// FlagsConfig 功能开关配置，开关可通过管理接口在Redis中整体覆盖
+type FlagsConfig struct {
+	RefreshInterval time.Duration         `mapstructure:"refresh_interval"` // 定期从Redis重新加载，兜底错过的变更通知
+	Flags           map[string]FlagConfig `mapstructure:"flags"`
+}
+
+// FlagConfig 单个功能开关，白名单用户总是开启，其余用户按百分比灰度
+type FlagConfig struct {
+	Enabled    bool     `mapstructure:"enabled"`
+	Percentage int      `mapstructure:"percentage"` // 0-100
+	Allowlist  []string `mapstructure:"allowlist"`
+}
+
+// QuotaConfig 配额配置，计数保存在Redis中，定期持久化到MySQL
+type QuotaConfig struct {
+	Enabled         bool              `mapstructure:"enabled"`
+	PersistInterval time.Duration     `mapstructure:"persist_interval"`
+	TenantSeparator string            `mapstructure:"tenant_separator"` // 用户ID中租户前缀的分隔符，为空时不按租户统计
+	User            QuotaLimitsConfig `mapstructure:"user"`
+	Tenant          QuotaLimitsConfig `mapstructure:"tenant"`
+}
+
+// QuotaLimitsConfig 默认配额，0表示不限制
+type QuotaLimitsConfig struct {
+	MessagesPerDay int64 `mapstructure:"messages_per_day"`
+	MediaBytes     int64 `mapstructure:"media_bytes"`
+	MaxGroups      int64 `mapstructure:"max_groups"`
+	MaxGroupSize   int64 `mapstructure:"max_group_size"`
+}
+
+// ChallengeConfig 可疑登录的验证配置
+type ChallengeConfig struct {
+	Enabled          bool           `mapstructure:"enabled"`
+	MinSignals       int            `mapstructure:"min_signals"`       // 同时出现的风险信号数达到该值时要求验证
+	FailureThreshold int64          `mapstructure:"failure_threshold"` // 窗口内验证失败次数达到该值时视为风险信号
+	FailureWindow    time.Duration  `mapstructure:"failure_window"`
+	TTL              time.Duration  `mapstructure:"ttl"`          // 验证的有效期
+	MaxAttempts      int64          `mapstructure:"max_attempts"` // 单次验证允许的最多尝试次数
+	HistoryTTL       time.Duration  `mapstructure:"history_ttl"`  // 登录设备和网段记录的保留时长
+	DeviceTrustTTL   time.Duration  `mapstructure:"device_trust_ttl"`
+	Verifier         VerifierConfig `mapstructure:"verifier"`
+}
+
+// VerifierConfig 外部验证服务配置，服务端把客户端提交的答案转发给验证服务校验
+type VerifierConfig struct {
+	Method  string            `mapstructure:"method"` // 下发给客户端的验证方式，如captcha、otp
+	URL     string            `mapstructure:"url"`
+	Timeout time.Duration     `mapstructure:"timeout"`
+	Params  map[string]string `mapstructure:"params"` // 下发给客户端的参数，如验证码站点key
+}
+
+// TwoFactorConfig 两步验证配置
+type TwoFactorConfig struct {
+	Enabled          bool     `mapstructure:"enabled"`
+	Issuer           string   `mapstructure:"issuer"`            // 验证器应用中显示的服务名
+	Skew             int      `mapstructure:"skew"`              // 允许的时钟偏差（时间步数）
+	RecoveryCodes    int      `mapstructure:"recovery_codes"`    // 每次生成的恢复码数量
+	RequiredAccounts []string `mapstructure:"required_accounts"` // 必须开启两步验证的账号，如管理员和服务账号
+}
+
+// AuthConfig 认证配置，IM令牌为HS256签名的JWT
+type AuthConfig struct {
+	JWTSecret    string        `mapstructure:"jwt_secret"`    // IM令牌签名密钥，为空时不签发令牌
+	Issuer       string        `mapstructure:"issuer"`        // IM令牌的iss
+	TokenTTL     time.Duration `mapstructure:"token_ttl"`     // IM令牌有效期
+	RequireToken bool          `mapstructure:"require_token"` // WebSocket登录必须携带有效的IM令牌，且令牌用户与user_id一致
+	OIDC         OIDCConfig    `mapstructure:"oidc"`
+}
+
+// OIDCConfig 外部身份提供方（OIDC）配置
+type OIDCConfig struct {
+	Enabled      bool              `mapstructure:"enabled"`
+	Issuer       string            `mapstructure:"issuer"` // 身份提供方地址，从 <issuer>/.well-known/openid-configuration 发现端点
+	ClientID     string            `mapstructure:"client_id"`
+	ClientSecret string            `mapstructure:"client_secret"` // 用授权码换取ID令牌时使用
+	UserIDClaim  string            `mapstructure:"user_id_claim"` // 作为IM用户ID的声明
+	NameClaim    string            `mapstructure:"name_claim"`
+	RolesClaim   string            `mapstructure:"roles_claim"`  // 组或角色声明，支持点号分隔的嵌套路径，如 realm_access.roles
+	RoleMapping  map[string]string `mapstructure:"role_mapping"` // 身份提供方的组或角色到IM角色的映射，键不区分大小写
+	Timeout      time.Duration     `mapstructure:"timeout"`
+	JWKSRefresh  time.Duration     `mapstructure:"jwks_refresh"` // 签名公钥的刷新间隔
+}
+
+// OrgConfig 组织架构配置
+type OrgConfig struct {
+	DepartmentGroups      bool   `mapstructure:"department_groups"`      // 为每个部门自动创建并维护部门群
+	IncludeSubdepartments bool   `mapstructure:"include_subdepartments"` // 部门群包含子部门的成员
+	GroupOwner            string `mapstructure:"group_owner"`            // 部门没有负责人时的群主，通常是系统账号
+}
+
+// StickersConfig 表情包配置，表情包通过管理接口保存在Redis中
+type StickersConfig struct {
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"` // 定期从Redis重新加载，兜底错过的变更通知
+	MaxPacks        int           `mapstructure:"max_packs"`        // 表情包总数上限
+	MaxStickers     int           `mapstructure:"max_stickers"`     // 单个表情包的表情数上限
+}
+
+// UploadConfig 大文件分片上传配置，分片经S3分段上传直接在对象存储中合并
+type UploadConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	ChunkSize       int64         `mapstructure:"chunk_size"`       // 分片大小，除最后一片外每片必须等于该大小，不小于S3要求的5MB
+	MaxFileSize     int64         `mapstructure:"max_file_size"`    // 单个文件最大字节数
+	SessionTTL      time.Duration `mapstructure:"session_ttl"`      // 最后一次上传分片后会话的保留时长，过期后清理已上传的分片
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"` // 主节点清理过期会话的间隔
+	S3              S3Config      `mapstructure:"s3"`
+	Scan            ScanConfig    `mapstructure:"scan"`
+	Image           ImageConfig   `mapstructure:"image"`
+}
+
+// ImageConfig 上传图片处理配置，去除EXIF/GPS等元数据，尺寸或质量超限的图片重新压缩
+type ImageConfig struct {
+	Enabled         bool     `mapstructure:"enabled"`          // 默认是否处理JPEG和PNG图片
+	EnabledTenants  []string `mapstructure:"enabled_tenants"`  // enabled为false时仍处理的租户，租户划分沿用quota.tenant_separator
+	DisabledTenants []string `mapstructure:"disabled_tenants"` // enabled为true时不处理的租户
+	MaxDimension    int      `mapstructure:"max_dimension"`    // 长边超过该像素数的图片等比缩小
+	Quality         int      `mapstructure:"quality"`          // 重新压缩JPEG的质量，估算质量更高的JPEG也会重新压缩
+	MaxPixels       int64    `mapstructure:"max_pixels"`       // 超过该像素数的图片不解码，只去除元数据
+	MaxFileSize     int64    `mapstructure:"max_file_size"`    // 需要处理的图片最大字节数，处理时整个文件读入内存
+}
+
+// ScanConfig 上传文件扫描配置，文件在扫描通过前保存在隔离区，不能通过文件地址访问
+type ScanConfig struct {
+	Type    string        `mapstructure:"type"`    // clamav或http，为空时不扫描
+	Address string        `mapstructure:"address"` // clamd地址，host:port或unix套接字路径
+	URL     string        `mapstructure:"url"`     // 外部扫描服务地址
+	Timeout time.Duration `mapstructure:"timeout"` // 单个文件的扫描超时
+}
+
+// S3Config 对象存储配置，凭证为空时读取 AWS_ACCESS_KEY_ID 和 AWS_SECRET_ACCESS_KEY
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"` // 兼容S3的对象存储地址，为空时使用AWS按区域的地址
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	Prefix    string `mapstructure:"prefix"` // 对象键前缀
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	PublicURL string `mapstructure:"public_url"` // 上传完成后返回给客户端的文件地址前缀，为空时使用对象存储的路径风格地址
+}
+
+// MediaConfig 媒体访问控制配置，上传的文件只能通过签名的下载地址访问，需要启用upload
+type MediaConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	SigningKey string        `mapstructure:"signing_key"` // 下载地址的HMAC签名密钥
+	URLTTL     time.Duration `mapstructure:"url_ttl"`     // 签名下载地址的有效期
+	BaseURL    string        `mapstructure:"base_url"`    // 下载地址前缀，为空时返回相对路径 /media/...
+}
+
+// MediaStorageConfig 媒体存储统计和生命周期配置，只统计分片上传的文件，需要启用upload
+type MediaStorageConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	GroupMediaBytes int64         `mapstructure:"group_media_bytes"` // 单个群组中消息引用的媒体总字节数上限，0为不限制
+	DeleteAfter     time.Duration `mapstructure:"delete_after"`      // 引用文件的消息全部删除后文件的保留时长
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`  // 主节点删除到期文件的间隔
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	Token string `mapstructure:"token"`
 }
 
 // LoadConfig 加载配置
@@ -101,9 +830,674 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	if config.Server.AckWindow > 0 && config.Server.AckQueue <= 0 {
+		config.Server.AckQueue = 1000
+	}
+	for frameType, limit := range config.Server.FrameLimits {
+		if spec, ok := model.LookupFrame(model.FrameType(frameType)); !ok || spec.Request == nil {
+			return nil, fmt.Errorf("invalid frame limit: %s is not an upstream frame type", frameType)
+		}
+		if limit <= 0 {
+			return nil, fmt.Errorf("invalid frame limit for %s: must be positive", frameType)
+		}
+	}
+	if config.Database.SlowThreshold == 0 {
+		config.Database.SlowThreshold = 200 * time.Millisecond
+	}
+	switch config.Database.LogLevel {
+	case "":
+		config.Database.LogLevel = DatabaseLogWarn
+	case DatabaseLogSilent, DatabaseLogError, DatabaseLogWarn, DatabaseLogInfo:
+	default:
+		return nil, fmt.Errorf("invalid database log level: %s", config.Database.LogLevel)
+	}
+	if config.Redis.SlowThreshold == 0 {
+		config.Redis.SlowThreshold = 20 * time.Millisecond
+	}
+	if config.Store.SlowThreshold == 0 {
+		config.Store.SlowThreshold = 50 * time.Millisecond
+	}
+	if config.Cluster.Mode == "" {
+		config.Cluster.Mode = ModeMonolith
+	}
+	switch config.Cluster.Mode {
+	case ModeMonolith, ModeGateway, ModeWorker:
+	default:
+		return nil, fmt.Errorf("invalid cluster mode: %s", config.Cluster.Mode)
+	}
+	if config.Cluster.NodeID == "" {
+		hostname, _ := os.Hostname()
+		config.Cluster.NodeID = hostname
+	}
+	if config.Cluster.RouteTTL <= 0 {
+		config.Cluster.RouteTTL = 30 * time.Minute
+	}
+	if config.Cluster.AdvertiseAddr == "" {
+		config.Cluster.AdvertiseAddr = fmt.Sprintf("%s:%d", config.Cluster.NodeID, config.Server.Port)
+	}
+	if config.Cluster.Capacity <= 0 {
+		config.Cluster.Capacity = config.Server.MaxConnections
+	}
+	if config.Cluster.Registry.ServiceName == "" {
+		config.Cluster.Registry.ServiceName = "im-server"
+	}
+	if config.Group.MaxMembers <= 0 {
+		config.Group.MaxMembers = 500
+	}
+	if config.Group.ChannelMaxMembers <= 0 {
+		config.Group.ChannelMaxMembers = 100000
+	}
+	if config.Group.FanoutBatchSize <= 0 {
+		config.Group.FanoutBatchSize = 1000
+	}
+	if config.Group.CacheTTL <= 0 {
+		config.Group.CacheTTL = 24 * time.Hour
+	}
+	if config.Group.ReconcileInterval <= 0 {
+		config.Group.ReconcileInterval = 10 * time.Minute
+	}
+	if config.Group.ReconcileBatch <= 0 {
+		config.Group.ReconcileBatch = 200
+	}
+	switch config.Group.Receipts {
+	case "":
+		config.Group.Receipts = model.ReceiptsFull
+	case model.ReceiptsNone, model.ReceiptsAggregate, model.ReceiptsFull:
+	default:
+		return nil, fmt.Errorf("invalid group receipts: %s", config.Group.Receipts)
+	}
+	switch config.Group.ChannelReceipts {
+	case "":
+		config.Group.ChannelReceipts = model.ReceiptsAggregate
+	case model.ReceiptsNone, model.ReceiptsAggregate:
+	default:
+		return nil, fmt.Errorf("invalid group channel_receipts: %s, channels support none or aggregate", config.Group.ChannelReceipts)
+	}
+	if config.Group.ReceiptTTL <= 0 {
+		config.Group.ReceiptTTL = 7 * 24 * time.Hour
+	}
+	if config.Presence.Debounce <= 0 {
+		config.Presence.Debounce = 5 * time.Second
+	}
+	if config.Presence.MaxWatch <= 0 {
+		config.Presence.MaxWatch = 200
+	}
+	if config.Cluster.Leader.Key == "" {
+		config.Cluster.Leader.Key = "cluster:leader"
+	}
+	if config.Cluster.Leader.TTL <= 0 {
+		config.Cluster.Leader.TTL = 15 * time.Second
+	}
+	if config.Cluster.Registry.TTL <= 0 {
+		config.Cluster.Registry.TTL = 15 * time.Second
+	}
+	if config.Cluster.Drain.Spread <= 0 {
+		config.Cluster.Drain.Spread = 3 * time.Minute
+	}
+	if config.Cluster.Drain.BatchInterval <= 0 {
+		config.Cluster.Drain.BatchInterval = 5 * time.Second
+	}
+	if config.Cluster.Drain.Timeout <= 0 {
+		config.Cluster.Drain.Timeout = 10 * time.Minute
+	}
+	if config.Cluster.Drain.Timeout < config.Cluster.Drain.Spread {
+		return nil, fmt.Errorf("cluster drain timeout must not be shorter than spread")
+	}
+	if config.Cluster.Drain.Threshold < 0 {
+		return nil, fmt.Errorf("invalid cluster drain threshold: %d", config.Cluster.Drain.Threshold)
+	}
+	if config.Cluster.Drain.Scheme == "" {
+		config.Cluster.Drain.Scheme = "ws"
+	}
+	if config.Cluster.Drain.Scheme != "ws" && config.Cluster.Drain.Scheme != "wss" {
+		return nil, fmt.Errorf("invalid cluster drain scheme: %s", config.Cluster.Drain.Scheme)
+	}
+	if config.Spam.Window <= 0 {
+		config.Spam.Window = time.Minute
+	}
+	if config.Spam.Throttle <= 0 {
+		config.Spam.Throttle = 5 * time.Minute
+	}
+	if config.Draft.MaxSize <= 0 {
+		config.Draft.MaxSize = 4096
+	}
+	if config.Draft.TTL <= 0 {
+		config.Draft.TTL = 7 * 24 * time.Hour
+	}
+	if config.WordFilter.Dir == "" {
+		config.WordFilter.Dir = "wordlists"
+	}
+	if config.WordFilter.Action == "" {
+		config.WordFilter.Action = WordFilterMask
+	}
+	switch config.WordFilter.Action {
+	case WordFilterMask, WordFilterReject:
+	default:
+		return nil, fmt.Errorf("invalid word filter action: %s", config.WordFilter.Action)
+	}
+	if config.LinkSafety.ReputationTimeout <= 0 {
+		config.LinkSafety.ReputationTimeout = 2 * time.Second
+	}
+	if config.LinkSafety.CacheTTL <= 0 {
+		config.LinkSafety.CacheTTL = 24 * time.Hour
+	}
+	if config.LinkSafety.Action == "" {
+		config.LinkSafety.Action = LinkSafetyRewrite
+	}
+	switch config.LinkSafety.Action {
+	case LinkSafetyRewrite, LinkSafetyReject:
+	default:
+		return nil, fmt.Errorf("invalid link safety action: %s", config.LinkSafety.Action)
+	}
+	if config.LinkSafety.Replacement == "" {
+		config.LinkSafety.Replacement = "[link removed]"
+	}
+	if config.DeliverySLO.SampleRate <= 0 || config.DeliverySLO.SampleRate > 1 {
+		config.DeliverySLO.SampleRate = 1
+	}
+	if config.DeliverySLO.Target <= 0 {
+		config.DeliverySLO.Target = 500 * time.Millisecond
+	}
+	if config.DeliverySLO.Objective <= 0 || config.DeliverySLO.Objective >= 1 {
+		config.DeliverySLO.Objective = 0.99
+	}
+	if config.DeliverySLO.TraceTTL <= 0 {
+		config.DeliverySLO.TraceTTL = 10 * time.Minute
+	}
+	if config.DeliverySLO.Retention <= 0 {
+		config.DeliverySLO.Retention = 30 * 24 * time.Hour
+	}
+	if config.Canary.Interval <= 0 {
+		config.Canary.Interval = 30 * time.Second
+	}
+	if config.Canary.Timeout <= 0 {
+		config.Canary.Timeout = 5 * time.Second
+	}
+	if config.Canary.FailureThreshold <= 0 {
+		config.Canary.FailureThreshold = 3
+	}
+	if config.Canary.UserPrefix == "" {
+		config.Canary.UserPrefix = "__canary__"
+	}
+	if config.Spool.Path == "" {
+		config.Spool.Path = "./data/spool"
+	}
+	if config.Spool.ReplayInterval <= 0 {
+		config.Spool.ReplayInterval = 5 * time.Second
+	}
+	if config.Spool.MaxEntries <= 0 {
+		config.Spool.MaxEntries = 100000
+	}
+	if config.Recovery.Threshold <= 0 {
+		config.Recovery.Threshold = 2 * time.Minute
+	}
+	if config.Recovery.MaxAge <= config.Recovery.Threshold {
+		config.Recovery.MaxAge = 24 * time.Hour
+	}
+	if config.Recovery.Interval <= 0 {
+		config.Recovery.Interval = 5 * time.Minute
+	}
+	if config.Recovery.BatchSize <= 0 {
+		config.Recovery.BatchSize = 500
+	}
+	if config.ID.Strategy == "" {
+		config.ID.Strategy = idgen.StrategySnowflake
+	}
+	if !idgen.Valid(config.ID.Strategy) {
+		return nil, fmt.Errorf("invalid id strategy: %s", config.ID.Strategy)
+	}
+	if config.ID.MachineID == 0 {
+		config.ID.MachineID = 1
+	}
+	for component, spec := range config.ID.Overrides {
+		switch component {
+		case IDComponentMessage, IDComponentGroup:
+		default:
+			return nil, fmt.Errorf("invalid id component: %s", component)
+		}
+		if spec.Strategy == "" {
+			spec.Strategy = config.ID.Strategy
+		}
+		if !idgen.Valid(spec.Strategy) {
+			return nil, fmt.Errorf("invalid id strategy for %s: %s", component, spec.Strategy)
+		}
+		if spec.MachineID == 0 {
+			spec.MachineID = config.ID.MachineID
+		}
+		config.ID.Overrides[component] = spec
+	}
+	if config.Lock.TTL <= 0 {
+		config.Lock.TTL = 10 * time.Second
+	}
+	if config.Lock.Wait <= 0 {
+		config.Lock.Wait = 3 * time.Second
+	}
+	if config.MessageCache.NegativeTTL <= 0 {
+		config.MessageCache.NegativeTTL = 30 * time.Second
+	}
+	if config.MessageCache.LocalSize <= 0 {
+		config.MessageCache.LocalSize = 10000
+	}
+	if config.MessageCache.LocalTTL <= 0 {
+		config.MessageCache.LocalTTL = 10 * time.Second
+	}
+	if config.API.DefaultVersion == 0 {
+		config.API.DefaultVersion = 1
+	}
+	switch config.API.FieldCase {
+	case "":
+		config.API.FieldCase = FieldCaseSnake
+	case FieldCaseSnake, FieldCaseCamel:
+	default:
+		return nil, fmt.Errorf("invalid api field case: %s", config.API.FieldCase)
+	}
+	if config.Official.RefreshInterval <= 0 {
+		config.Official.RefreshInterval = time.Minute
+	}
+	if config.Official.MaxAccounts <= 0 {
+		config.Official.MaxAccounts = 100
+	}
+	if config.Official.MaxMenuItems <= 0 {
+		config.Official.MaxMenuItems = 5
+	}
+	if config.Official.BroadcastBatch <= 0 {
+		config.Official.BroadcastBatch = 500
+	}
+	if config.Guest.DefaultTTL <= 0 {
+		config.Guest.DefaultTTL = 24 * time.Hour
+	}
+	if config.Guest.MaxTTL <= 0 {
+		config.Guest.MaxTTL = 30 * 24 * time.Hour
+	}
+	if config.Guest.DefaultTTL > config.Guest.MaxTTL {
+		return nil, fmt.Errorf("invalid guest default ttl: %s exceeds max ttl %s", config.Guest.DefaultTTL, config.Guest.MaxTTL)
+	}
+	if config.Guest.MaxTokensPerGroup <= 0 {
+		config.Guest.MaxTokensPerGroup = 20
+	}
+	if config.Guest.PollInterval <= 0 {
+		config.Guest.PollInterval = 2 * time.Second
+	}
+	if config.GroupEvents.DefaultRemindBefore <= 0 {
+		config.GroupEvents.DefaultRemindBefore = 15 * time.Minute
+	}
+	if config.GroupEvents.ReminderInterval <= 0 {
+		config.GroupEvents.ReminderInterval = time.Minute
+	}
+	if config.Polls.MaxOptions <= 0 {
+		config.Polls.MaxOptions = 10
+	}
+	if config.Polls.CloseInterval <= 0 {
+		config.Polls.CloseInterval = time.Minute
+	}
+	if config.Bandwidth.FlushInterval <= 0 {
+		config.Bandwidth.FlushInterval = 10 * time.Second
+	}
+	if config.Bandwidth.Window < time.Minute {
+		config.Bandwidth.Window = 5 * time.Minute
+	}
+	if config.Bandwidth.ThrottledBytesPerSecond <= 0 {
+		config.Bandwidth.ThrottledBytesPerSecond = 16 * 1024
+	}
+	if config.Admission.Rate <= 0 {
+		config.Admission.Rate = 500
+	}
+	if config.Admission.Burst <= 0 {
+		config.Admission.Burst = int(config.Admission.Rate) + 1
+	}
+	if config.Admission.PriorityReserve < 0 || config.Admission.PriorityReserve >= 1 {
+		return nil, fmt.Errorf("admission.priority_reserve must be in [0, 1)")
+	}
+	if config.Admission.MinRetryAfter <= 0 {
+		config.Admission.MinRetryAfter = time.Second
+	}
+	if config.Admission.MaxRetryAfter <= 0 {
+		config.Admission.MaxRetryAfter = time.Minute
+	}
+	if config.Admission.MaxRetryAfter < config.Admission.MinRetryAfter {
+		return nil, fmt.Errorf("admission.max_retry_after must not be less than admission.min_retry_after")
+	}
+	if config.Notification.MaxBodyLength <= 0 {
+		config.Notification.MaxBodyLength = 100
+	}
+	if config.Notification.DigestThreshold <= 0 {
+		config.Notification.DigestThreshold = 20
+	}
+	if config.GraphQL.MaxDepth <= 0 {
+		config.GraphQL.MaxDepth = 6
+	}
+	if config.GraphQL.MaxFields <= 0 {
+		config.GraphQL.MaxFields = 200
+	}
+	if config.GraphQL.MaxComplexity <= 0 {
+		config.GraphQL.MaxComplexity = 2000
+	}
+	switch config.HTTP.Mode {
+	case "":
+		config.HTTP.Mode = "release"
+	case "release", "debug", "test":
+	default:
+		return nil, fmt.Errorf("invalid http mode: %s", config.HTTP.Mode)
+	}
+	for _, name := range config.HTTP.DisabledMiddlewares {
+		switch name {
+		case MiddlewareRecovery, MiddlewareAccessLog, MiddlewareAuth, MiddlewareRateLimit, MiddlewareCORS, MiddlewareMetrics:
+		default:
+			return nil, fmt.Errorf("invalid http middleware: %s", name)
+		}
+	}
+	if _, err := realip.New(config.HTTP.TrustedProxies); err != nil {
+		return nil, err
+	}
+	if config.HTTP.RateLimit.Window <= 0 {
+		config.HTTP.RateLimit.Window = time.Minute
+	}
+	if len(config.HTTP.CORS.AllowedHeaders) == 0 {
+		config.HTTP.CORS.AllowedHeaders = []string{"Authorization", "Content-Type", "X-User-ID", "X-API-Version"}
+	}
+	if config.HTTP.CORS.MaxAge <= 0 {
+		config.HTTP.CORS.MaxAge = 10 * time.Minute
+	}
+	if config.Components.HealthInterval <= 0 {
+		config.Components.HealthInterval = 15 * time.Second
+	}
+	if config.Federation.DiscoveryTTL <= 0 {
+		config.Federation.DiscoveryTTL = time.Hour
+	}
+	if config.Federation.Timeout <= 0 {
+		config.Federation.Timeout = 10 * time.Second
+	}
+	if config.Federation.MaxClockSkew <= 0 {
+		config.Federation.MaxClockSkew = 5 * time.Minute
+	}
+	if config.Federation.FlushInterval <= 0 {
+		config.Federation.FlushInterval = time.Second
+	}
+	if config.Federation.BatchSize <= 0 {
+		config.Federation.BatchSize = 100
+	}
+	if config.Federation.Retry.InitialBackoff <= 0 {
+		config.Federation.Retry.InitialBackoff = time.Second
+	}
+	if config.Federation.Retry.MaxBackoff <= 0 {
+		config.Federation.Retry.MaxBackoff = 10 * time.Minute
+	}
+	if config.Federation.Retry.MaxAttempts <= 0 {
+		config.Federation.Retry.MaxAttempts = 50
+	}
+	if config.Federation.Enabled {
+		if config.Federation.Domain == "" || config.Federation.PrivateKey == "" {
+			return nil, fmt.Errorf("federation requires domain and private_key")
+		}
+		if config.Federation.KeyID == "" {
+			config.Federation.KeyID = "default"
+		}
+		for i, peer := range config.Federation.Peers {
+			switch peer.Transport {
+			case "":
+				config.Federation.Peers[i].Transport = model.FederationTransportHTTP
+			case model.FederationTransportHTTP:
+			case model.FederationTransportKafka:
+				if peer.Topic == "" {
+					return nil, fmt.Errorf("federation peer %s uses kafka transport without topic", peer.Domain)
+				}
+			default:
+				return nil, fmt.Errorf("invalid federation transport for %s: %s", peer.Domain, peer.Transport)
+			}
+			if peer.Domain == "" || peer.PublicKey == "" {
+				return nil, fmt.Errorf("federation peer requires domain and public_key")
+			}
+		}
+	}
+	if config.Settings.MaxKeys <= 0 {
+		config.Settings.MaxKeys = 200
+	}
+	if config.Settings.MaxSize <= 0 {
+		config.Settings.MaxSize = 64 * 1024
+	}
+	if config.Preview.Timeout <= 0 {
+		config.Preview.Timeout = 5 * time.Second
+	}
+	if config.Preview.MaxBodySize <= 0 {
+		config.Preview.MaxBodySize = 1 << 20
+	}
+	if config.Preview.CacheTTL <= 0 {
+		config.Preview.CacheTTL = 24 * time.Hour
+	}
+	if config.Preview.UserAgent == "" {
+		config.Preview.UserAgent = "im-link-preview/1.0"
+	}
+	if config.Voice.Timeout <= 0 {
+		config.Voice.Timeout = 10 * time.Second
+	}
+	if config.Voice.MaxFileSize <= 0 {
+		config.Voice.MaxFileSize = 10 << 20
+	}
+	if config.Voice.WaveformBars <= 0 {
+		config.Voice.WaveformBars = 64
+	}
+	if len(config.Voice.MediaHosts) == 0 {
+		for _, raw := range []string{config.Client.MediaUploadURL, config.Upload.S3.PublicURL} {
+			if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+				config.Voice.MediaHosts = append(config.Voice.MediaHosts, u.Hostname())
+			}
+		}
+	}
+	if config.I18n.DefaultLanguage == "" {
+		config.I18n.DefaultLanguage = "zh-CN"
+	}
+	if config.APIKey.DefaultRateLimit <= 0 {
+		config.APIKey.DefaultRateLimit = 60
+	}
+	if config.APIKey.CacheTTL <= 0 {
+		config.APIKey.CacheTTL = time.Minute
+	}
+	if config.Kafka.Provision.Partitions <= 0 {
+		config.Kafka.Provision.Partitions = 12
+	}
+	if config.Kafka.Provision.ReplicationFactor <= 0 {
+		config.Kafka.Provision.ReplicationFactor = 1
+	}
+	if config.Analytics.Interval <= 0 {
+		config.Analytics.Interval = time.Minute
+	}
+	if config.Analytics.Retention <= 0 {
+		config.Analytics.Retention = 72 * time.Hour
+	}
+	if config.Client.HeartbeatInterval <= 0 {
+		config.Client.HeartbeatInterval = config.Server.HeartbeatInterval
+	}
+	if config.Client.MaxMessageSize <= 0 {
+		config.Client.MaxMessageSize = config.Server.MaxMessageSize
+	}
+	if config.Client.RefreshInterval <= 0 {
+		config.Client.RefreshInterval = time.Minute
+	}
+	if config.Flags.RefreshInterval <= 0 {
+		config.Flags.RefreshInterval = time.Minute
+	}
+	if config.Stickers.RefreshInterval <= 0 {
+		config.Stickers.RefreshInterval = time.Minute
+	}
+	if config.Stickers.MaxPacks <= 0 {
+		config.Stickers.MaxPacks = 200
+	}
+	if config.Stickers.MaxStickers <= 0 {
+		config.Stickers.MaxStickers = 120
+	}
+	if config.Upload.ChunkSize < 5<<20 {
+		config.Upload.ChunkSize = 8 << 20
+	}
+	if config.Upload.MaxFileSize <= 0 {
+		config.Upload.MaxFileSize = 4 << 30
+	}
+	if config.Upload.SessionTTL <= 0 {
+		config.Upload.SessionTTL = 24 * time.Hour
+	}
+	if config.Upload.CleanupInterval <= 0 {
+		config.Upload.CleanupInterval = 10 * time.Minute
+	}
+	if config.Upload.Scan.Timeout <= 0 {
+		config.Upload.Scan.Timeout = 2 * time.Minute
+	}
+	if config.Upload.Image.MaxDimension <= 0 {
+		config.Upload.Image.MaxDimension = 2048
+	}
+	if config.Upload.Image.Quality <= 0 || config.Upload.Image.Quality > 100 {
+		config.Upload.Image.Quality = 85
+	}
+	if config.Upload.Image.MaxPixels <= 0 {
+		config.Upload.Image.MaxPixels = 50000000
+	}
+	if config.Upload.Image.MaxFileSize <= 0 {
+		config.Upload.Image.MaxFileSize = 50 << 20
+	}
+	if config.Upload.S3.AccessKey == "" {
+		config.Upload.S3.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if config.Upload.S3.SecretKey == "" {
+		config.Upload.S3.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if config.Media.URLTTL <= 0 {
+		config.Media.URLTTL = 10 * time.Minute
+	}
+	if config.MediaStorage.DeleteAfter <= 0 {
+		config.MediaStorage.DeleteAfter = 30 * 24 * time.Hour
+	}
+	if config.MediaStorage.CleanupInterval <= 0 {
+		config.MediaStorage.CleanupInterval = time.Hour
+	}
+	if config.Quota.PersistInterval <= 0 {
+		config.Quota.PersistInterval = time.Minute
+	}
+	if config.Challenge.MinSignals <= 0 {
+		config.Challenge.MinSignals = 2
+	}
+	if config.Challenge.FailureThreshold <= 0 {
+		config.Challenge.FailureThreshold = 5
+	}
+	if config.Challenge.FailureWindow <= 0 {
+		config.Challenge.FailureWindow = 15 * time.Minute
+	}
+	if config.Challenge.TTL <= 0 {
+		config.Challenge.TTL = 5 * time.Minute
+	}
+	if config.Challenge.MaxAttempts <= 0 {
+		config.Challenge.MaxAttempts = 5
+	}
+	if config.Challenge.HistoryTTL <= 0 {
+		config.Challenge.HistoryTTL = 90 * 24 * time.Hour
+	}
+	if config.Challenge.DeviceTrustTTL <= 0 {
+		config.Challenge.DeviceTrustTTL = 30 * 24 * time.Hour
+	}
+	if config.Challenge.Verifier.Method == "" {
+		config.Challenge.Verifier.Method = "captcha"
+	}
+	if config.Challenge.Verifier.Timeout <= 0 {
+		config.Challenge.Verifier.Timeout = 5 * time.Second
+	}
+	if config.TwoFactor.Issuer == "" {
+		config.TwoFactor.Issuer = "IM"
+	}
+	if config.TwoFactor.Skew <= 0 {
+		config.TwoFactor.Skew = 1
+	}
+	if config.TwoFactor.RecoveryCodes <= 0 {
+		config.TwoFactor.RecoveryCodes = 10
+	}
+	if config.Auth.Issuer == "" {
+		config.Auth.Issuer = "im"
+	}
+	if config.Auth.TokenTTL <= 0 {
+		config.Auth.TokenTTL = 24 * time.Hour
+	}
+	if config.Auth.OIDC.UserIDClaim == "" {
+		config.Auth.OIDC.UserIDClaim = "sub"
+	}
+	if config.Auth.OIDC.NameClaim == "" {
+		config.Auth.OIDC.NameClaim = "name"
+	}
+	if config.Auth.OIDC.RolesClaim == "" {
+		config.Auth.OIDC.RolesClaim = "groups"
+	}
+	if config.Auth.OIDC.Timeout <= 0 {
+		config.Auth.OIDC.Timeout = 10 * time.Second
+	}
+	if config.Auth.OIDC.JWKSRefresh <= 0 {
+		config.Auth.OIDC.JWKSRefresh = time.Hour
+	}
+	if config.Stats.Interval <= 0 {
+		config.Stats.Interval = 15 * time.Second
+	}
+	if config.Kafka.DedupTTL <= 0 {
+		config.Kafka.DedupTTL = time.Hour
+	}
+	if config.Kafka.Consumer.Workers <= 0 {
+		config.Kafka.Consumer.Workers = 1
+	}
+	if config.Kafka.Consumer.QueueSize <= 0 {
+		config.Kafka.Consumer.QueueSize = 64
+	}
+
 	return &config, nil
 }
 
+// TopicNames 获取配置的固定主题，网关推送主题按节点生成，不在其中
+func (c *KafkaConfig) TopicNames() []string {
+	candidates := []string{
+		c.Topics.MessageQueue,
+		c.Topics.GroupChat,
+		c.Topics.OfflineMsg,
+		c.Topics.GatewayUpstream,
+		c.Topics.Moderation,
+		c.Topics.LinkPreview,
+		c.Topics.MediaProcessing,
+		c.Topics.Events,
+	}
+
+	seen := make(map[string]bool, len(candidates))
+	topics := make([]string, 0, len(candidates))
+	for _, topic := range candidates {
+		if topic == "" || seen[topic] {
+			continue
+		}
+		seen[topic] = true
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// TopicSpec 获取主题的分区数和副本数，未覆盖的部分使用默认值
+func (c *KafkaConfig) TopicSpec(topic string) KafkaTopicSpec {
+	spec := c.Provision.Overrides[topic]
+	if spec.Partitions <= 0 {
+		spec.Partitions = c.Provision.Partitions
+	}
+	if spec.ReplicationFactor <= 0 {
+		spec.ReplicationFactor = c.Provision.ReplicationFactor
+	}
+	return spec
+}
+
+// ConsumerSpec 获取主题的消费并发，未覆盖的部分使用默认值
+func (c *KafkaConfig) ConsumerSpec(topic string) KafkaConsumerSpec {
+	spec := c.Consumer.Overrides[topic]
+	if spec.Workers <= 0 {
+		spec.Workers = c.Consumer.Workers
+	}
+	if spec.QueueSize <= 0 {
+		spec.QueueSize = c.Consumer.QueueSize
+	}
+	return spec
+}
+
+// Override 获取组件单独配置的ID生成策略，未单独配置时返回false，组件使用全局生成器
+func (c *IDConfig) Override(component string) (IDSpec, bool) {
+	spec, ok := c.Overrides[component]
+	return spec, ok
+}
+
 // GetDSN 获取数据库连接字符串
 func (c *DatabaseConfig) GetDSN() string {
 	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local",
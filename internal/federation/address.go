@@ -0,0 +1,28 @@
+package federation
+
+import "strings"
+
+// SplitAddress 拆分 user@domain 形式的用户地址，不带域名时ok为false
+func SplitAddress(address string) (user, domain string, ok bool) {
+	i := strings.LastIndexByte(address, '@')
+	if i <= 0 || i == len(address)-1 {
+		return address, "", false
+	}
+	return address[:i], strings.ToLower(address[i+1:]), true
+}
+
+// Qualify 为本域用户加上域名，已带域名的地址原样返回
+func Qualify(userID, domain string) string {
+	if _, _, ok := SplitAddress(userID); ok {
+		return userID
+	}
+	return userID + "@" + domain
+}
+
+// Normalize 把地址中的域名转为小写，不带域名的ID原样返回
+func Normalize(address string) string {
+	if user, domain, ok := SplitAddress(address); ok {
+		return user + "@" + domain
+	}
+	return address
+}
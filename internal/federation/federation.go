@@ -0,0 +1,421 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// dedupTTL 接收端去重记录的保留时长，覆盖发送方的整个重试周期
+const dedupTTL = 24 * time.Hour
+
+var (
+	// ErrMalformedEvent 事件无法解析
+	ErrMalformedEvent = errors.New("malformed federation event")
+	// ErrMisdirected 事件的接收方不是本域
+	ErrMisdirected = errors.New("federation event is not addressed to this domain")
+	// ErrStaleEvent 事件发送时间超出允许的时钟偏差，视为重放
+	ErrStaleEvent = errors.New("federation event is outside the allowed clock skew")
+)
+
+// Handler 处理远程域发来的事件，返回错误时释放去重记录，允许对方重投
+type Handler func(event *model.FederationEvent) error
+
+// queuedEvent 发件队列中的事件，签名在每次发送时生成
+type queuedEvent struct {
+	Event    *model.FederationEvent `json:"event"`
+	Attempts int                    `json:"attempts"`
+}
+
+// domainBackoff 远程域的投递退避状态，只在主节点内存中维护，切主后从头开始
+type domainBackoff struct {
+	failures int
+	nextAt   time.Time
+	lastErr  string
+}
+
+// Service 跨域联邦服务，负责事件的签名、按远程域排队投递和接收校验
+type Service struct {
+	cfg        config.FederationConfig
+	redisStore *store.RedisStore
+	kafkaStore *store.KafkaStore
+	signer     *Signer
+	resolver   *Resolver
+	transports map[string]Transport
+	discovered Transport
+	dedup      *store.Deduplicator
+	handler    Handler
+
+	mu      sync.Mutex
+	backoff map[string]*domainBackoff
+}
+
+// New 创建联邦服务
+func New(cfg config.FederationConfig, redisStore *store.RedisStore, kafkaStore *store.KafkaStore) (*Service, error) {
+	cfg.Domain = strings.ToLower(cfg.Domain)
+	signer, err := NewSigner(cfg.KeyID, cfg.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: cfg.Timeout}
+	public := PublicClient(cfg.Timeout)
+	return &Service{
+		cfg:        cfg,
+		redisStore: redisStore,
+		kafkaStore: kafkaStore,
+		signer:     signer,
+		resolver:   NewResolver(cfg.Peers, cfg.Discovery, cfg.DiscoveryTTL, public),
+		transports: map[string]Transport{
+			model.FederationTransportHTTP:  &httpTransport{client: client},
+			model.FederationTransportKafka: &kafkaTransport{kafkaStore: kafkaStore},
+		},
+		discovered: &httpTransport{client: public},
+		dedup:      store.NewDeduplicator(redisStore, "federation", dedupTTL),
+		backoff:    make(map[string]*domainBackoff),
+	}, nil
+}
+
+// SetHandler 设置接收事件的处理函数
+func (s *Service) SetHandler(handler Handler) {
+	s.handler = handler
+}
+
+// Domain 本部署的域名
+func (s *Service) Domain() string {
+	return s.cfg.Domain
+}
+
+// IsRemote 用户或群组地址是否属于其他域，不带域名的ID属于本域
+func (s *Service) IsRemote(address string) bool {
+	_, domain, ok := SplitAddress(address)
+	return ok && !strings.EqualFold(domain, s.cfg.Domain)
+}
+
+// LocalID 把本域的用户或群组地址转换为本地ID，地址属于其他域时ok为false
+func (s *Service) LocalID(address string) (string, bool) {
+	user, domain, ok := SplitAddress(address)
+	if !ok {
+		return address, true
+	}
+	if !strings.EqualFold(domain, s.cfg.Domain) {
+		return "", false
+	}
+	return user, true
+}
+
+// RelayMessage 把发给远程域用户的私聊消息加入该域的发件队列，发送者ID带上本域域名
+func (s *Service) RelayMessage(message *model.Message) error {
+	_, domain, ok := SplitAddress(message.ReceiverID)
+	if !ok {
+		return fmt.Errorf("receiver %s is not a federated address", message.ReceiverID)
+	}
+	relayed := *message
+	relayed.SenderID = Qualify(message.SenderID, s.cfg.Domain)
+	relayed.Trace = nil
+	return s.Enqueue(domain, model.FederationEventMessage, message.ID, &relayed)
+}
+
+// RelayGroupMessage 把联邦群组的群消息加入远程域的发件队列，群组和发送者ID带上所在域的域名
+func (s *Service) RelayGroupMessage(domain string, message *model.Message) error {
+	relayed := *message
+	relayed.SenderID = Qualify(message.SenderID, s.cfg.Domain)
+	relayed.GroupID = Qualify(message.GroupID, s.cfg.Domain)
+	relayed.Trace = nil
+	return s.Enqueue(domain, model.FederationEventGroupMessage, message.ID, &relayed)
+}
+
+// RelayMembership 把本域用户在联邦群组中的成员变更发给群组的主域
+func (s *Service) RelayMembership(domain string, membership *model.FederatedMembership) error {
+	id := fmt.Sprintf("member:%s:%s:%d", membership.GroupID, membership.UserID, membership.Version)
+	return s.Enqueue(domain, model.FederationEventGroupMember, id, membership)
+}
+
+// Enqueue 把事件加入远程域的发件队列，id在本域内唯一，接收方据此去重
+func (s *Service) Enqueue(domain string, eventType model.FederationEventType, id string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode federation payload: %w", err)
+	}
+	queued, err := json.Marshal(queuedEvent{Event: &model.FederationEvent{
+		ID:          id,
+		Type:        eventType,
+		Origin:      s.cfg.Domain,
+		Destination: strings.ToLower(domain),
+		Timestamp:   time.Now().Unix(),
+		Payload:     data,
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to encode federation event: %w", err)
+	}
+	if err := s.redisStore.EnqueueFederationEvent(strings.ToLower(domain), queued); err != nil {
+		return fmt.Errorf("failed to enqueue federation event: %w", err)
+	}
+	return nil
+}
+
+// Flush 投递各远程域的发件队列，由主节点定期执行
+// 不同远程域并发投递，同一远程域按顺序投递，队首失败时整个队列退避，避免乱序
+func (s *Service) Flush(ctx context.Context, fence int64) error {
+	domains, err := s.redisStore.GetFederationDomains()
+	if err != nil {
+		return fmt.Errorf("failed to get federation domains: %w", err)
+	}
+
+	now := time.Now()
+	var wg sync.WaitGroup
+	for _, domain := range domains {
+		s.mu.Lock()
+		b := s.backoff[domain]
+		s.mu.Unlock()
+		if b != nil && now.Before(b.nextAt) {
+			continue
+		}
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			s.flushDomain(ctx, domain)
+		}(domain)
+	}
+	wg.Wait()
+	return nil
+}
+
+// flushDomain 按顺序投递一个远程域的事件，遇到可重试的失败时停止
+func (s *Service) flushDomain(ctx context.Context, domain string) {
+	items, err := s.redisStore.PeekFederationEvents(domain, int64(s.cfg.BatchSize))
+	if err != nil {
+		logger.Warn("Failed to read federation outbox", logger.String("domain", domain), logger.ErrorField(err))
+		return
+	}
+	defer s.recordDepth(domain)
+
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return
+		}
+		var queued queuedEvent
+		if err := json.Unmarshal([]byte(item), &queued); err != nil || queued.Event == nil {
+			s.deadLetter(domain, []byte(item), ErrMalformedEvent)
+			continue
+		}
+
+		err := s.send(ctx, domain, queued.Event)
+		if err == nil {
+			if err := s.redisStore.AckFederationEvents(domain, 1); err != nil {
+				logger.Warn("Failed to ack federation event", logger.String("domain", domain), logger.ErrorField(err))
+				return
+			}
+			metrics.FederationEvents.WithLabelValues("outbound", "sent").Inc()
+			s.succeed(domain)
+			continue
+		}
+
+		queued.Attempts++
+		data, _ := json.Marshal(queued)
+		if IsPermanent(err) || queued.Attempts >= s.cfg.Retry.MaxAttempts {
+			s.deadLetter(domain, data, err)
+			continue
+		}
+		if err := s.redisStore.UpdateFederationHead(domain, data); err != nil {
+			logger.Warn("Failed to record federation attempt", logger.String("domain", domain), logger.ErrorField(err))
+		}
+		metrics.FederationEvents.WithLabelValues("outbound", "retry").Inc()
+		s.fail(domain, err)
+		return
+	}
+}
+
+// send 解析远程域，签名后按其传输方式发送，每次发送更新发送时间
+func (s *Service) send(ctx context.Context, domain string, event *model.FederationEvent) error {
+	peer, err := s.resolver.Resolve(ctx, domain)
+	if err != nil {
+		// 未开启发现时未配置的域不会变为可达
+		if errors.Is(err, ErrUnknownDomain) && !s.cfg.Discovery {
+			return &PermanentError{Err: err}
+		}
+		return err
+	}
+	transport, ok := s.transports[peer.Transport]
+	if !ok {
+		transport = s.transports[model.FederationTransportHTTP]
+	}
+	if !s.resolver.IsStatic(domain) {
+		// 发现的域的接口地址由对方提供，只连接公网地址
+		transport = s.discovered
+	}
+
+	event.SentAt = time.Now().Unix()
+	signed, err := s.signer.Sign(event)
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	return transport.Send(ctx, peer, signed)
+}
+
+// deadLetter 把队首事件移入死信队列
+func (s *Service) deadLetter(domain string, data []byte, cause error) {
+	if err := s.redisStore.DeadLetterFederationHead(domain, data); err != nil {
+		logger.Warn("Failed to dead-letter federation event", logger.String("domain", domain), logger.ErrorField(err))
+		return
+	}
+	metrics.FederationEvents.WithLabelValues("outbound", "dead").Inc()
+	logger.Warn("Federation event dead-lettered", logger.String("domain", domain), logger.ErrorField(cause))
+}
+
+// succeed 投递成功后清除远程域的退避状态
+func (s *Service) succeed(domain string) {
+	s.mu.Lock()
+	delete(s.backoff, domain)
+	s.mu.Unlock()
+}
+
+// fail 记录远程域的投递失败，退避时间每次翻倍，不超过最大退避
+func (s *Service) fail(domain string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.backoff[domain]
+	if b == nil {
+		b = &domainBackoff{}
+		s.backoff[domain] = b
+	}
+	b.failures++
+	b.lastErr = err.Error()
+	delay := s.cfg.Retry.InitialBackoff
+	for i := 1; i < b.failures && delay < s.cfg.Retry.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.cfg.Retry.MaxBackoff {
+		delay = s.cfg.Retry.MaxBackoff
+	}
+	b.nextAt = time.Now().Add(delay)
+	logger.Warn("Federation delivery failed", logger.String("domain", domain), logger.Int("failures", b.failures), logger.ErrorField(err))
+}
+
+// recordDepth 上报远程域的发件队列长度
+func (s *Service) recordDepth(domain string) {
+	pending, _, err := s.redisStore.CountFederationEvents(domain)
+	if err == nil {
+		metrics.FederationOutboxDepth.WithLabelValues(domain).Set(float64(pending))
+	}
+}
+
+// Receive 校验远程域发来的事件并交给处理函数，同一发送方的重复事件只处理一次
+func (s *Service) Receive(ctx context.Context, signed *model.SignedFederationEvent) error {
+	err := s.receive(ctx, signed)
+	result := "accepted"
+	if err != nil {
+		result = "rejected"
+	}
+	metrics.FederationEvents.WithLabelValues("inbound", result).Inc()
+	return err
+}
+
+func (s *Service) receive(ctx context.Context, signed *model.SignedFederationEvent) error {
+	// 先读出发送方域名以找到校验签名的公钥，签名通过前不信任其他字段
+	var header struct {
+		Origin string `json:"origin"`
+	}
+	if err := json.Unmarshal(signed.Event, &header); err != nil || header.Origin == "" {
+		return ErrMalformedEvent
+	}
+	peer, err := s.resolver.Resolve(ctx, header.Origin)
+	if err != nil {
+		return err
+	}
+	event, err := Verify(signed, peer)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(event.Destination, s.cfg.Domain) {
+		return fmt.Errorf("%w: %s", ErrMisdirected, event.Destination)
+	}
+	skew := time.Since(time.Unix(event.SentAt, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > s.cfg.MaxClockSkew {
+		return ErrStaleEvent
+	}
+	if event.ID == "" || event.Type == "" {
+		return ErrMalformedEvent
+	}
+	if s.handler == nil {
+		return errors.New("federation handler is not configured")
+	}
+
+	key := strings.ToLower(event.Origin) + ":" + event.ID
+	if !s.dedup.Claim(key) {
+		return nil
+	}
+	if err := s.handler(event); err != nil {
+		s.dedup.Release(key)
+		return err
+	}
+	return nil
+}
+
+// Consume 消费Kafka传输的接收主题，阻塞直到消费者退出
+func (s *Service) Consume(groupID string) error {
+	if s.kafkaStore == nil || s.cfg.InboundTopic == "" {
+		return nil
+	}
+	return s.kafkaStore.Consume(s.cfg.InboundTopic, groupID, func(value []byte) error {
+		var signed model.SignedFederationEvent
+		if err := json.Unmarshal(value, &signed); err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+		defer cancel()
+		return s.Receive(ctx, &signed)
+	})
+}
+
+// Discovery 本域的发现文档，发布在 /.well-known/im-federation
+func (s *Service) Discovery() *model.FederationPeer {
+	return &model.FederationPeer{
+		Domain:    s.cfg.Domain,
+		Endpoint:  s.cfg.Endpoint,
+		KeyID:     s.signer.KeyID(),
+		PublicKey: s.signer.PublicKey(),
+	}
+}
+
+// Queues 获取各远程域的发件队列状态，退避状态只在当前主节点上可见
+func (s *Service) Queues() ([]model.FederationQueueStatus, error) {
+	domains, err := s.redisStore.GetFederationDomains()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get federation domains: %w", err)
+	}
+	sort.Strings(domains)
+
+	queues := make([]model.FederationQueueStatus, 0, len(domains))
+	for _, domain := range domains {
+		pending, dead, err := s.redisStore.CountFederationEvents(domain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count federation events: %w", err)
+		}
+		status := model.FederationQueueStatus{Domain: domain, Pending: pending, DeadLetters: dead}
+		s.mu.Lock()
+		if b := s.backoff[domain]; b != nil {
+			status.Failures = b.failures
+			status.NextAttempt = b.nextAt.Unix()
+			status.LastError = b.lastErr
+		}
+		s.mu.Unlock()
+		queues = append(queues, status)
+	}
+	return queues, nil
+}
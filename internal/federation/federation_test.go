@@ -0,0 +1,107 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func TestAddress(t *testing.T) {
+	user, domain, ok := SplitAddress("alice@A.Example")
+	assert.True(t, ok)
+	assert.Equal(t, "alice", user)
+	assert.Equal(t, "a.example", domain)
+
+	for _, address := range []string{"alice", "@a.example", "alice@"} {
+		_, _, ok := SplitAddress(address)
+		assert.False(t, ok, address)
+	}
+
+	assert.Equal(t, "alice@a.example", Qualify("alice", "a.example"))
+	assert.Equal(t, "bob@b.example", Qualify("bob@b.example", "a.example"))
+	assert.Equal(t, "g1@a.example", Normalize("g1@A.Example"))
+	assert.Equal(t, "g1", Normalize("g1"))
+}
+
+func newTestService(t *testing.T, remote *Signer) *Service {
+	s, err := New(config.FederationConfig{
+		Domain:       "b.example",
+		KeyID:        "key-b",
+		PrivateKey:   testSeed(9),
+		MaxClockSkew: time.Minute,
+		Retry:        config.FederationRetryConfig{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second},
+		Peers: []config.FederationPeerConfig{
+			{Domain: "a.example", Transport: model.FederationTransportHTTP, KeyID: remote.KeyID(), PublicKey: remote.PublicKey()},
+		},
+	}, nil, nil)
+	assert.NoError(t, err)
+	return s
+}
+
+func TestServiceAddresses(t *testing.T) {
+	remote, _ := NewSigner("key-a", testSeed(1))
+	s := newTestService(t, remote)
+
+	assert.True(t, s.IsRemote("alice@a.example"))
+	assert.False(t, s.IsRemote("bob@B.example"))
+	assert.False(t, s.IsRemote("bob"))
+
+	user, ok := s.LocalID("bob@b.example")
+	assert.True(t, ok)
+	assert.Equal(t, "bob", user)
+	_, ok = s.LocalID("alice@a.example")
+	assert.False(t, ok)
+}
+
+func TestServiceReceiveRejects(t *testing.T) {
+	remote, _ := NewSigner("key-a", testSeed(1))
+	s := newTestService(t, remote)
+	s.SetHandler(func(event *model.FederationEvent) error {
+		t.Fatalf("unexpected event %s", event.ID)
+		return nil
+	})
+
+	sign := func(signer *Signer, event model.FederationEvent) *model.SignedFederationEvent {
+		signed, err := signer.Sign(&event)
+		assert.NoError(t, err)
+		return signed
+	}
+	valid := model.FederationEvent{ID: "m1", Type: model.FederationEventMessage, Origin: "a.example", Destination: "b.example", SentAt: time.Now().Unix()}
+
+	misdirected := valid
+	misdirected.Destination = "c.example"
+	stale := valid
+	stale.SentAt = time.Now().Add(-time.Hour).Unix()
+	unknown := valid
+	unknown.Origin = "c.example"
+	forger, _ := NewSigner("key-a", testSeed(2))
+
+	ctx := context.Background()
+	assert.ErrorIs(t, s.Receive(ctx, sign(remote, misdirected)), ErrMisdirected)
+	assert.ErrorIs(t, s.Receive(ctx, sign(remote, stale)), ErrStaleEvent)
+	assert.ErrorIs(t, s.Receive(ctx, sign(remote, unknown)), ErrUnknownDomain)
+	assert.ErrorIs(t, s.Receive(ctx, sign(forger, valid)), ErrInvalidSignature)
+	assert.ErrorIs(t, s.Receive(ctx, &model.SignedFederationEvent{Event: json.RawMessage(`{}`)}), ErrMalformedEvent)
+}
+
+func TestServiceBackoff(t *testing.T) {
+	remote, _ := NewSigner("key-a", testSeed(1))
+	s := newTestService(t, remote)
+
+	var delays []time.Duration
+	for i := 0; i < 5; i++ {
+		before := time.Now()
+		s.fail("a.example", assert.AnError)
+		delays = append(delays, s.backoff["a.example"].nextAt.Sub(before).Round(time.Second))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, delays)
+	assert.Equal(t, 5, s.backoff["a.example"].failures)
+
+	s.succeed("a.example")
+	assert.Nil(t, s.backoff["a.example"])
+}
@@ -0,0 +1,182 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/netguard"
+)
+
+// DiscoveryPath 各部署发布联邦接入信息的地址
+const DiscoveryPath = "/.well-known/im-federation"
+
+const (
+	// maxDiscoverySize 发现文档的最大字节数
+	maxDiscoverySize = 64 << 10
+	// discoveryRetryInterval 同一个域两次发现之间的最短间隔，发现失败的结果在此期间内缓存
+	discoveryRetryInterval = time.Minute
+	// maxCachedPeers 发现结果缓存的条目数超过该值时清理过期的条目
+	maxCachedPeers = 10000
+)
+
+// ErrUnknownDomain 远程域未配置且无法发现
+var ErrUnknownDomain = errors.New("unknown federation domain")
+
+// cachedPeer 缓存的发现结果，err不为空时为失败或正在进行的发现
+type cachedPeer struct {
+	peer      *model.FederationPeer
+	err       error
+	expiresAt time.Time
+}
+
+// Resolver 把远程域解析为接入信息，静态配置优先，未配置的域按发现文档解析并缓存
+// 接收事件时在校验签名前按发送方域名解析，发现请求只连接公网地址，同一个域每分钟最多发现一次
+type Resolver struct {
+	static    map[string]*model.FederationPeer
+	discovery bool
+	ttl       time.Duration
+	client    *http.Client
+	scheme    string
+
+	mu    sync.Mutex
+	cache map[string]cachedPeer
+}
+
+// NewResolver 创建远程域解析器，discovery为false时只解析静态配置的域；client用于发现请求，应使用PublicClient
+func NewResolver(peers []config.FederationPeerConfig, discovery bool, ttl time.Duration, client *http.Client) *Resolver {
+	r := &Resolver{
+		static:    make(map[string]*model.FederationPeer, len(peers)),
+		discovery: discovery,
+		ttl:       ttl,
+		client:    client,
+		scheme:    "https",
+		cache:     make(map[string]cachedPeer),
+	}
+	for _, p := range peers {
+		domain := strings.ToLower(p.Domain)
+		r.static[domain] = &model.FederationPeer{
+			Domain:    domain,
+			Endpoint:  strings.TrimRight(p.Endpoint, "/"),
+			Transport: p.Transport,
+			Topic:     p.Topic,
+			KeyID:     p.KeyID,
+			PublicKey: p.PublicKey,
+		}
+	}
+	return r
+}
+
+// Resolve 获取远程域的接入信息
+func (r *Resolver) Resolve(ctx context.Context, domain string) (*model.FederationPeer, error) {
+	domain = strings.ToLower(domain)
+	if peer, ok := r.static[domain]; ok {
+		return peer, nil
+	}
+	if !r.discovery {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDomain, domain)
+	}
+
+	r.mu.Lock()
+	cached, ok := r.cache[domain]
+	if ok && time.Now().Before(cached.expiresAt) {
+		r.mu.Unlock()
+		return cached.peer, cached.err
+	}
+	// 发现完成前同一个域的其他请求直接失败，不重复发起发现
+	r.store(domain, cachedPeer{err: fmt.Errorf("%w: discovery of %s in progress", ErrUnknownDomain, domain)}, discoveryRetryInterval)
+	r.mu.Unlock()
+
+	peer, err := r.discover(ctx, domain)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.store(domain, cachedPeer{err: err}, discoveryRetryInterval)
+		return nil, err
+	}
+	r.store(domain, cachedPeer{peer: peer}, r.ttl)
+	return peer, nil
+}
+
+// IsStatic 远程域是否为静态配置的域
+func (r *Resolver) IsStatic(domain string) bool {
+	_, ok := r.static[strings.ToLower(domain)]
+	return ok
+}
+
+// store 缓存发现结果，调用方需持有锁；条目过多时先清理过期的条目
+func (r *Resolver) store(domain string, entry cachedPeer, ttl time.Duration) {
+	now := time.Now()
+	if len(r.cache) >= maxCachedPeers {
+		for d, cached := range r.cache {
+			if !now.Before(cached.expiresAt) {
+				delete(r.cache, d)
+			}
+		}
+	}
+	entry.expiresAt = now.Add(ttl)
+	r.cache[domain] = entry
+}
+
+// discover 读取远程域的发现文档，文档中的域名必须与请求的域名一致
+func (r *Resolver) discover(ctx context.Context, domain string) (*model.FederationPeer, error) {
+	// 域名只能是主机名，不能借用户信息或路径改变请求的目标
+	u, err := url.Parse(r.scheme + "://" + domain + DiscoveryPath)
+	if err != nil || u.User != nil || u.Host != domain || u.Path != DiscoveryPath {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDomain, domain)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDomain, domain)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover %s: %w", domain, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: discovery of %s returned %d", ErrUnknownDomain, domain, resp.StatusCode)
+	}
+
+	var peer model.FederationPeer
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDiscoverySize)).Decode(&peer); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery document of %s: %w", domain, err)
+	}
+	if !strings.EqualFold(peer.Domain, domain) || peer.Endpoint == "" || peer.PublicKey == "" {
+		return nil, fmt.Errorf("%w: invalid discovery document of %s", ErrUnknownDomain, domain)
+	}
+	peer.Domain = domain
+	peer.Endpoint = strings.TrimRight(peer.Endpoint, "/")
+	// 发现的域只使用HTTP传输，Kafka主题需要双方事先约定；接口地址由对方提供，发送时同样只连接公网地址
+	peer.Transport, peer.Topic = model.FederationTransportHTTP, ""
+	return &peer, nil
+}
+
+// PublicClient 只连接公网地址80和443端口的HTTP客户端，用于发现请求和向发现的域发送事件，不使用代理
+func PublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: netguard.Control,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+	}
+}
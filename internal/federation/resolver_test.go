@@ -0,0 +1,127 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func TestResolverStatic(t *testing.T) {
+	r := NewResolver([]config.FederationPeerConfig{
+		{Domain: "B.Example", Endpoint: "https://b.example/federation/v1/", Transport: model.FederationTransportHTTP, PublicKey: "pk"},
+	}, false, time.Hour, http.DefaultClient)
+
+	peer, err := r.Resolve(context.Background(), "b.example")
+	assert.NoError(t, err)
+	assert.Equal(t, "b.example", peer.Domain)
+	assert.Equal(t, "https://b.example/federation/v1", peer.Endpoint)
+
+	_, err = r.Resolve(context.Background(), "c.example")
+	assert.ErrorIs(t, err, ErrUnknownDomain)
+}
+
+func TestResolverDiscovery(t *testing.T) {
+	requests := 0
+	var domain string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		assert.Equal(t, DiscoveryPath, req.URL.Path)
+		json.NewEncoder(w).Encode(model.FederationPeer{
+			Domain:    domain,
+			Endpoint:  "https://remote/federation/v1",
+			Transport: model.FederationTransportKafka,
+			Topic:     "inbox",
+			KeyID:     "key-1",
+			PublicKey: "pk",
+		})
+	}))
+	defer srv.Close()
+	domain = strings.TrimPrefix(srv.URL, "http://")
+
+	r := NewResolver(nil, true, time.Hour, srv.Client())
+	r.scheme = "http"
+
+	peer, err := r.Resolve(context.Background(), domain)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://remote/federation/v1", peer.Endpoint)
+	// 发现的域只使用HTTP传输
+	assert.Equal(t, model.FederationTransportHTTP, peer.Transport)
+	assert.Empty(t, peer.Topic)
+
+	// 缓存期内不再请求
+	_, err = r.Resolve(context.Background(), domain)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+
+	// 文档中的域名与请求的域名不一致
+	domain = "other.example"
+	r = NewResolver(nil, true, time.Hour, srv.Client())
+	r.scheme = "http"
+	_, err = r.Resolve(context.Background(), strings.TrimPrefix(srv.URL, "http://"))
+	assert.ErrorIs(t, err, ErrUnknownDomain)
+}
+
+func TestResolverDiscoveryRefusesPrivateAddresses(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	r := NewResolver(nil, true, time.Hour, PublicClient(time.Second))
+	r.scheme = "http"
+	for _, domain := range []string{
+		strings.TrimPrefix(srv.URL, "http://"),
+		"127.0.0.1",
+		"localhost",
+		"10.0.0.1",
+		"169.254.169.254",
+		"[fd00::1]",
+	} {
+		_, err := r.Resolve(context.Background(), domain)
+		assert.Error(t, err, domain)
+	}
+	assert.Zero(t, requests)
+
+	// 域名不能借用户信息或路径改变请求的目标
+	for _, domain := range []string{"b.example@127.0.0.1", "b.example/x?", "b.example#"} {
+		_, err := r.Resolve(context.Background(), domain)
+		assert.ErrorIs(t, err, ErrUnknownDomain, domain)
+	}
+}
+
+func TestResolverDiscoveryCachesFailures(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+	domain := strings.TrimPrefix(srv.URL, "http://")
+
+	r := NewResolver(nil, true, time.Hour, srv.Client())
+	r.scheme = "http"
+
+	// 失败的发现在重试间隔内直接返回缓存的错误
+	for i := 0; i < 3; i++ {
+		_, err := r.Resolve(context.Background(), domain)
+		assert.ErrorIs(t, err, ErrUnknownDomain)
+	}
+	assert.Equal(t, 1, requests)
+
+	// 重试间隔过后重新发现
+	r.mu.Lock()
+	r.cache[domain] = cachedPeer{err: ErrUnknownDomain, expiresAt: time.Now().Add(-time.Second)}
+	r.mu.Unlock()
+	_, err := r.Resolve(context.Background(), domain)
+	assert.ErrorIs(t, err, ErrUnknownDomain)
+	assert.Equal(t, 2, requests)
+}
@@ -0,0 +1,73 @@
+package federation
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/user/im/internal/model"
+)
+
+// ErrInvalidSignature 事件签名无效或签名密钥与远程域不符
+var ErrInvalidSignature = errors.New("invalid federation signature")
+
+// Signer 使用本域的Ed25519私钥为事件签名
+type Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+// NewSigner 从base64编码的32字节私钥种子创建签名器
+func NewSigner(keyID, seed string) (*Signer, error) {
+	raw, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil || len(raw) != ed25519.SeedSize {
+		return nil, fmt.Errorf("federation private key must be a base64 encoded %d-byte ed25519 seed", ed25519.SeedSize)
+	}
+	return &Signer{keyID: keyID, key: ed25519.NewKeyFromSeed(raw)}, nil
+}
+
+// KeyID 签名密钥标识
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// PublicKey base64编码的公钥，发布在发现文档中
+func (s *Signer) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// Sign 序列化事件并签名，签名覆盖序列化后的原始字节
+func (s *Signer) Sign(event *model.FederationEvent) (*model.SignedFederationEvent, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode federation event: %w", err)
+	}
+	return &model.SignedFederationEvent{
+		Event:     data,
+		KeyID:     s.keyID,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, data)),
+	}, nil
+}
+
+// Verify 用远程域的公钥校验签名并解析事件
+func Verify(signed *model.SignedFederationEvent, peer *model.FederationPeer) (*model.FederationEvent, error) {
+	if peer.KeyID != "" && signed.KeyID != peer.KeyID {
+		return nil, fmt.Errorf("%w: unknown key %s for %s", ErrInvalidSignature, signed.KeyID, peer.Domain)
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(peer.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: malformed public key for %s", ErrInvalidSignature, peer.Domain)
+	}
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil || !ed25519.Verify(publicKey, signed.Event, signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event model.FederationEvent
+	if err := json.Unmarshal(signed.Event, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode federation event: %w", err)
+	}
+	return &event, nil
+}
@@ -0,0 +1,54 @@
+package federation
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func testSeed(b byte) string {
+	seed := make([]byte, 32)
+	for i := range seed {
+		seed[i] = b
+	}
+	return base64.StdEncoding.EncodeToString(seed)
+}
+
+func TestSignVerify(t *testing.T) {
+	signer, err := NewSigner("key-1", testSeed(1))
+	assert.NoError(t, err)
+	peer := &model.FederationPeer{Domain: "a.example", KeyID: "key-1", PublicKey: signer.PublicKey()}
+
+	event := &model.FederationEvent{ID: "m1", Type: model.FederationEventMessage, Origin: "a.example", Destination: "b.example", SentAt: 100}
+	signed, err := signer.Sign(event)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-1", signed.KeyID)
+
+	verified, err := Verify(signed, peer)
+	assert.NoError(t, err)
+	assert.Equal(t, event.ID, verified.ID)
+	assert.Equal(t, event.Destination, verified.Destination)
+
+	// 篡改事件内容
+	tampered := *signed
+	tampered.Event = []byte(`{"id":"m1","type":"message","origin":"a.example","destination":"c.example"}`)
+	_, err = Verify(&tampered, peer)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// 其他密钥签名
+	other, _ := NewSigner("key-1", testSeed(2))
+	forged, _ := other.Sign(event)
+	_, err = Verify(forged, peer)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// 密钥标识不符
+	rotated, _ := NewSigner("key-2", testSeed(1))
+	signed, _ = rotated.Sign(event)
+	_, err = Verify(signed, peer)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = NewSigner("key-1", "c2hvcnQ=")
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+// InboxPath 接收联邦事件的接口，相对于联邦接口地址
+const InboxPath = "/inbox"
+
+// PermanentError 远程域明确拒绝的事件，重试不会成功，直接转入死信队列
+type PermanentError struct {
+	Err error
+}
+
+// Error 实现error接口
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap 返回原始错误
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent 错误是否不可重试
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// Transport 把签名后的事件发往远程域
+type Transport interface {
+	Send(ctx context.Context, peer *model.FederationPeer, event *model.SignedFederationEvent) error
+}
+
+// httpTransport 以POST请求发往远程域的收件接口
+type httpTransport struct {
+	client *http.Client
+}
+
+// Send 发送事件，2xx表示对方已接收，4xx（429除外）表示对方拒绝，其余错误可重试
+func (t *httpTransport) Send(ctx context.Context, peer *model.FederationPeer, event *model.SignedFederationEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("failed to encode federation event: %w", err)}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, peer.Endpoint+InboxPath, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("invalid federation endpoint for %s: %w", peer.Domain, err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send federation event to %s: %w", peer.Domain, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s rejected federation event: %d %s", peer.Domain, resp.StatusCode, bytes.TrimSpace(detail))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return &PermanentError{Err: err}
+	}
+	return err
+}
+
+// kafkaTransport 写入远程域的接收主题，要求双方共用Kafka集群或已配置主题镜像
+type kafkaTransport struct {
+	kafkaStore *store.KafkaStore
+}
+
+// Send 按事件发送方写入主题，同一发送方的事件保持顺序
+func (t *kafkaTransport) Send(ctx context.Context, peer *model.FederationPeer, event *model.SignedFederationEvent) error {
+	if t.kafkaStore == nil {
+		return &PermanentError{Err: fmt.Errorf("kafka transport for %s requires kafka", peer.Domain)}
+	}
+	if err := t.kafkaStore.Publish(peer.Topic, event.KeyID, event); err != nil {
+		return fmt.Errorf("failed to publish federation event to %s: %w", peer.Domain, err)
+	}
+	return nil
+}
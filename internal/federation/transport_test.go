@@ -0,0 +1,43 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestHTTPTransport(t *testing.T) {
+	status := http.StatusAccepted
+	var received model.SignedFederationEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, InboxPath, req.URL.Path)
+		json.NewDecoder(req.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	transport := &httpTransport{client: srv.Client()}
+	peer := &model.FederationPeer{Domain: "b.example", Endpoint: srv.URL}
+	event := &model.SignedFederationEvent{Event: json.RawMessage(`{"id":"m1"}`), KeyID: "key-1", Signature: "sig"}
+
+	assert.NoError(t, transport.Send(context.Background(), peer, event))
+	assert.Equal(t, "sig", received.Signature)
+
+	// 对方拒绝的事件不再重试
+	status = http.StatusForbidden
+	err := transport.Send(context.Background(), peer, event)
+	assert.Error(t, err)
+	assert.True(t, IsPermanent(err))
+
+	// 限流和服务端错误可重试
+	for _, status = range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		err = transport.Send(context.Background(), peer, event)
+		assert.Error(t, err)
+		assert.False(t, IsPermanent(err))
+	}
+}
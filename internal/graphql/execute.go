@@ -0,0 +1,443 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Request GraphQL请求体
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response 查询结果，请求无法解析、变量不合法或超出限制时没有data，只有errors
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error 查询错误，字段解析失败时path为该字段在结果中的路径，字段的值为null
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Limits 查询的上限，0为不限制
+// MaxDepth、MaxFields在执行前按查询文本检查；MaxComplexity为执行时在所有对象上解析的字段总数，
+// 列表中的每个对象都单独计数，超出时停止执行，避免嵌套的列表字段逐层放大存储访问
+type Limits struct {
+	MaxDepth      int
+	MaxFields     int
+	MaxComplexity int
+}
+
+// Field 对象的关联字段，只在查询选择了该字段时解析
+// 解析结果可以是标量、*Object、[]*Object或nil
+type Field struct {
+	Args    []string // 接受的参数名
+	Resolve func(args Args) (interface{}, error)
+}
+
+// Object 查询结果中的对象类型
+// value按JSON序列化后的字段作为标量字段，字段名与REST接口一致，嵌套的JSON对象作为整体返回；
+// fields为按需解析的关联字段，与value的字段同名时覆盖
+type Object struct {
+	typeName string
+	value    interface{}
+	fields   map[string]Field
+	scalars  map[string]interface{}
+}
+
+// NewObject 创建对象，value可以为nil
+func NewObject(typeName string, value interface{}, fields map[string]Field) *Object {
+	return &Object{typeName: typeName, value: value, fields: fields}
+}
+
+// resolve 解析对象上的字段
+func (o *Object) resolve(name string, args Args) (interface{}, error) {
+	if name == "__typename" {
+		return o.typeName, nil
+	}
+	if f, ok := o.fields[name]; ok {
+		for arg := range args {
+			if !contains(f.Args, arg) {
+				return nil, fmt.Errorf("unknown argument %q on field %q of type %q", arg, name, o.typeName)
+			}
+		}
+		return f.Resolve(args)
+	}
+
+	if o.scalars == nil && o.value != nil {
+		data, err := json.Marshal(o.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", o.typeName, err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&o.scalars); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", o.typeName, err)
+		}
+	}
+	value, ok := o.scalars[name]
+	if !ok && !declaresField(reflect.TypeOf(o.value), name) {
+		return nil, fmt.Errorf("cannot query field %q on type %q", name, o.typeName)
+	}
+	if len(args) > 0 {
+		return nil, fmt.Errorf("field %q of type %q does not accept arguments", name, o.typeName)
+	}
+	return value, nil
+}
+
+// Execute 解析并执行查询，root为查询的根对象
+// 请求体中有多个操作时按operationName选择；字段解析失败不影响其他字段，错误按路径记录在errors中
+func Execute(req Request, root *Object, limits Limits) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return failed(err)
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return failed(err)
+	}
+	variables, err := op.coerceVariables(req.Variables)
+	if err != nil {
+		return failed(err)
+	}
+	if err := validate(op.selections, 1, limits, new(int)); err != nil {
+		return failed(err)
+	}
+
+	e := &executor{declared: make(map[string]bool, len(op.variables)), variables: variables, maxComplexity: limits.MaxComplexity}
+	for _, definition := range op.variables {
+		e.declared[definition.name] = true
+	}
+	data := e.selectionSet(root, op.selections, nil)
+	if e.exceeded {
+		return failed(fmt.Errorf("query exceeds the maximum complexity of %d", limits.MaxComplexity))
+	}
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func failed(err error) *Response {
+	return &Response{Errors: []*Error{{Message: err.Error()}}}
+}
+
+// operation 按名称选择要执行的操作
+func (d *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document contains multiple operations")
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// coerceVariables 按声明取变量值，未提供时使用默认值，非空变量必须提供
+func (op *operation) coerceVariables(values map[string]interface{}) (map[string]interface{}, error) {
+	variables := make(map[string]interface{}, len(op.variables))
+	for _, definition := range op.variables {
+		value, provided := values[definition.name]
+		if !provided && definition.hasDefault {
+			value, provided = definition.defaultValue, true
+		}
+		if definition.nonNull && (!provided || value == nil) {
+			return nil, fmt.Errorf("variable \"$%s\" of non-null type must not be null", definition.name)
+		}
+		if provided {
+			variables[definition.name] = value
+		}
+	}
+	return variables, nil
+}
+
+// validate 检查嵌套深度、字段总数和同一选择集中重复的响应键
+func validate(selections []*field, depth int, limits Limits, count *int) error {
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		return fmt.Errorf("query exceeds the maximum depth of %d", limits.MaxDepth)
+	}
+	keys := make(map[string]bool, len(selections))
+	for _, f := range selections {
+		if keys[f.responseKey()] {
+			return fmt.Errorf("field %q is selected more than once", f.responseKey())
+		}
+		keys[f.responseKey()] = true
+
+		*count++
+		if limits.MaxFields > 0 && *count > limits.MaxFields {
+			return fmt.Errorf("query exceeds the maximum of %d fields", limits.MaxFields)
+		}
+		if err := validate(f.selections, depth+1, limits, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executor 执行一次查询，收集字段错误，resolved为已解析的字段数
+type executor struct {
+	declared      map[string]bool
+	variables     map[string]interface{}
+	errors        []*Error
+	maxComplexity int
+	resolved      int
+	exceeded      bool
+}
+
+func (e *executor) selectionSet(obj *Object, selections []*field, path []interface{}) *result {
+	r := &result{values: make(map[string]interface{}, len(selections))}
+	for _, f := range selections {
+		e.resolved++
+		if e.maxComplexity > 0 && e.resolved > e.maxComplexity {
+			e.exceeded = true
+		}
+		if e.exceeded {
+			return r
+		}
+		key := f.responseKey()
+		fieldPath := append(append([]interface{}(nil), path...), key)
+		r.keys = append(r.keys, key)
+
+		value, err := e.field(obj, f, fieldPath)
+		if err != nil {
+			e.errors = append(e.errors, &Error{Message: err.Error(), Path: fieldPath})
+			value = nil
+		}
+		r.values[key] = value
+	}
+	return r
+}
+
+func (e *executor) field(obj *Object, f *field, path []interface{}) (interface{}, error) {
+	args, err := e.arguments(f.arguments)
+	if err != nil {
+		return nil, err
+	}
+	value, err := obj.resolve(f.name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := value.(type) {
+	case *Object:
+		if v == nil {
+			return nil, nil
+		}
+		if len(f.selections) == 0 {
+			return nil, fmt.Errorf("field %q of type %q must have a selection of subfields", f.name, v.typeName)
+		}
+		return e.selectionSet(v, f.selections, path), nil
+	case []*Object:
+		if len(f.selections) == 0 {
+			return nil, fmt.Errorf("field %q must have a selection of subfields", f.name)
+		}
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			if item != nil {
+				list[i] = e.selectionSet(item, f.selections, append(append([]interface{}(nil), path...), i))
+			}
+		}
+		return list, nil
+	}
+	if len(f.selections) > 0 {
+		return nil, fmt.Errorf("field %q is a scalar and must not have a selection of subfields", f.name)
+	}
+	return value, nil
+}
+
+// arguments 把参数中引用的变量替换为变量值，未提供的可空变量视为未传该参数
+func (e *executor) arguments(arguments map[string]interface{}) (Args, error) {
+	args := make(Args, len(arguments))
+	for name, value := range arguments {
+		resolved, provided, err := e.value(value)
+		if err != nil {
+			return nil, err
+		}
+		if provided {
+			args[name] = resolved
+		}
+	}
+	return args, nil
+}
+
+func (e *executor) value(value interface{}) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case variable:
+		if !e.declared[string(v)] {
+			return nil, false, fmt.Errorf("variable \"$%s\" is not defined", v)
+		}
+		resolved, ok := e.variables[string(v)]
+		return resolved, ok, nil
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			resolved, _, err := e.value(item)
+			if err != nil {
+				return nil, false, err
+			}
+			list = append(list, resolved)
+		}
+		return list, true, nil
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved, provided, err := e.value(item)
+			if err != nil {
+				return nil, false, err
+			}
+			if provided {
+				object[key] = resolved
+			}
+		}
+		return object, true, nil
+	}
+	return value, true, nil
+}
+
+// result 按选择顺序序列化字段的对象
+type result struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (r *result) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Args 字段参数，变量已替换为请求中的值
+type Args map[string]interface{}
+
+// String 字符串参数，未传或为null时返回空字符串
+func (a Args) String(name string) (string, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("%s must be a string", name)
+}
+
+// Int 整数参数，未传或为null时返回def，超出[min, max]时返回错误
+func (a Args) Int(name string, def, min, max int) (int, error) {
+	var value float64
+	switch v := a[name].(type) {
+	case nil:
+		return def, nil
+	case int64:
+		value = float64(v)
+	case float64:
+		value = v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("%s must be an integer between %d and %d", name, min, max)
+		}
+		value = f
+	default:
+		return 0, fmt.Errorf("%s must be an integer between %d and %d", name, min, max)
+	}
+	if value != math.Trunc(value) || value < float64(min) || value > float64(max) {
+		return 0, fmt.Errorf("%s must be an integer between %d and %d", name, min, max)
+	}
+	return int(value), nil
+}
+
+// Bool 可选的布尔参数，未传或为null时返回nil
+func (a Args) Bool(name string) (*bool, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		return &v, nil
+	}
+	return nil, fmt.Errorf("%s must be true or false", name)
+}
+
+// Strings 字符串列表参数，单个字符串视为只有一项的列表
+func (a Args) Strings(name string) ([]string, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a list of strings", name)
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("%s must be a list of strings", name)
+}
+
+// declaresField 结构体是否声明了该JSON字段，omitempty的字段为空时不在序列化结果中，查询时返回null
+func declaresField(t reflect.Type, name string) bool {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch {
+		case tag == "-" || (!f.IsExported() && !f.Anonymous):
+		case tag == "" && f.Anonymous:
+			if declaresField(f.Type, name) {
+				return true
+			}
+		case tag == name || (tag == "" && f.Name == name):
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
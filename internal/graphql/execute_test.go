@@ -0,0 +1,212 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Seq  int64  `json:"seq"`
+}
+
+// testRoot users按limit返回用户，每个用户的friends为另一个用户，broken总是失败
+func testRoot() *Object {
+	users := []*testUser{{ID: "u1", Name: "Alice", Seq: 9007199254740993}, {ID: "u2", Name: "Bob"}}
+	var user func(i int) *Object
+	user = func(i int) *Object {
+		return NewObject("User", users[i], map[string]Field{
+			"friend": {Resolve: func(Args) (interface{}, error) { return user(1 - i), nil }},
+		})
+	}
+	return NewObject("Query", nil, map[string]Field{
+		"users": {Args: []string{"limit", "name"}, Resolve: func(args Args) (interface{}, error) {
+			limit, err := args.Int("limit", 2, 1, 2)
+			if err != nil {
+				return nil, err
+			}
+			name, err := args.String("name")
+			if err != nil {
+				return nil, err
+			}
+			var objects []*Object
+			for i := 0; i < limit; i++ {
+				if name == "" || users[i].Name == name {
+					objects = append(objects, user(i))
+				}
+			}
+			return objects, nil
+		}},
+		"broken":  {Resolve: func(Args) (interface{}, error) { return nil, errors.New("unavailable") }},
+		"nothing": {Resolve: func(Args) (interface{}, error) { return (*Object)(nil), nil }},
+	})
+}
+
+func execute(t *testing.T, req Request, limits Limits) string {
+	data, err := json.Marshal(Execute(req, testRoot(), limits))
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestExecute_SelectionOrderAliasesAndNesting(t *testing.T) {
+	got := execute(t, Request{Query: `
+		# 注释和逗号被忽略
+		query Users {
+			first: users(limit: 1) { name, id __typename friend { name } }
+			users { id }
+		}`}, Limits{})
+	assert.JSONEq(t, `{"data":{"first":[{"name":"Alice","id":"u1","__typename":"User","friend":{"name":"Bob"}}],"users":[{"id":"u1"},{"id":"u2"}]}}`, got)
+	// 字段按选择顺序输出，整数不丢失精度
+	assert.Contains(t, got, `{"name":"Alice","id":"u1",`)
+	assert.Contains(t, execute(t, Request{Query: `{ users(limit: 1) { seq } }`}, Limits{}), `9007199254740993`)
+}
+
+func TestExecute_Variables(t *testing.T) {
+	query := `query ($limit: Int = 1, $name: String) { users(limit: $limit, name: $name) { id } }`
+	assert.JSONEq(t, `{"data":{"users":[{"id":"u1"}]}}`, execute(t, Request{Query: query}, Limits{}))
+	assert.JSONEq(t, `{"data":{"users":[{"id":"u2"}]}}`,
+		execute(t, Request{Query: query, Variables: map[string]interface{}{"limit": float64(2), "name": "Bob"}}, Limits{}))
+
+	got := execute(t, Request{Query: `query ($name: String!) { users(name: $name) { id } }`}, Limits{})
+	assert.JSONEq(t, `{"errors":[{"message":"variable \"$name\" of non-null type must not be null"}]}`, got)
+
+	got = execute(t, Request{Query: `{ users(name: $name) { id } }`}, Limits{})
+	assert.JSONEq(t, `{"data":{"users":null},"errors":[{"message":"variable \"$name\" is not defined","path":["users"]}]}`, got)
+}
+
+func TestExecute_FieldErrorsArePartial(t *testing.T) {
+	got := execute(t, Request{Query: `{ broken nothing { id } users(limit: 1) { id email } }`}, Limits{})
+	assert.JSONEq(t, `{
+		"data":{"broken":null,"nothing":null,"users":[{"id":"u1","email":null}]},
+		"errors":[
+			{"message":"unavailable","path":["broken"]},
+			{"message":"cannot query field \"email\" on type \"User\"","path":["users",0,"email"]}
+		]}`, got)
+
+	for query, message := range map[string]string{
+		`{ users(limit: 3) { id } }`:       "limit must be an integer between 1 and 2",
+		`{ users(page: 1) { id } }`:        `unknown argument "page" on field "users" of type "Query"`,
+		`{ users }`:                        `field "users" must have a selection of subfields`,
+		`{ users(limit: 1) { id { x } } }`: `field "id" is a scalar and must not have a selection of subfields`,
+	} {
+		var resp struct{ Errors []*Error }
+		assert.NoError(t, json.Unmarshal([]byte(execute(t, Request{Query: query}, Limits{})), &resp))
+		if assert.Len(t, resp.Errors, 1, query) {
+			assert.Equal(t, message, resp.Errors[0].Message, query)
+		}
+	}
+}
+
+func TestExecute_MaxComplexity(t *testing.T) {
+	// 列表中的每个对象单独计数：users 1 + 2个用户 ×（id、friend、friend.id）3
+	query := `{ users { id friend { id } } }`
+	assert.JSONEq(t, `{"data":{"users":[{"id":"u1","friend":{"id":"u2"}},{"id":"u2","friend":{"id":"u1"}}]}}`,
+		execute(t, Request{Query: query}, Limits{MaxFields: 4, MaxComplexity: 7}))
+
+	// 超出时停止执行，不返回部分结果
+	got := execute(t, Request{Query: query}, Limits{MaxFields: 4, MaxComplexity: 6})
+	assert.JSONEq(t, `{"errors":[{"message":"query exceeds the maximum complexity of 6"}]}`, got)
+
+	// 不支持内省查询
+	got = execute(t, Request{Query: `{ __schema { types { name } } }`}, Limits{})
+	assert.JSONEq(t, `{"data":{"__schema":null},"errors":[{"message":"cannot query field \"__schema\" on type \"Query\"","path":["__schema"]}]}`, got)
+}
+
+func TestExecute_RejectedDocuments(t *testing.T) {
+	for query, message := range map[string]string{
+		`{ users { id `:                                     "expected name, found end of document",
+		`mutation { users { id } }`:                         "mutation is not supported",
+		`{ users { ...UserFields } }`:                       "fragments are not supported",
+		`{ users @include(if: true) { id } }`:               "directives are not supported",
+		`{ users { id id } }`:                               `field "id" is selected more than once`,
+		`{ users { friend { friend { id } } } }`:            "query exceeds the maximum depth of 3",
+		`query A { users { id } } query B { users { id } }`: "operationName is required when the document contains multiple operations",
+	} {
+		var resp Response
+		assert.NoError(t, json.Unmarshal([]byte(execute(t, Request{Query: query}, Limits{MaxDepth: 3})), &resp))
+		assert.Nil(t, resp.Data, query)
+		if assert.Len(t, resp.Errors, 1, query) {
+			assert.Equal(t, message, resp.Errors[0].Message, query)
+		}
+	}
+
+	assert.Contains(t, execute(t, Request{Query: `{ users { id name seq } }`}, Limits{MaxFields: 3}), "query exceeds the maximum of 3 fields")
+	assert.JSONEq(t, `{"data":{"users":[{"id":"u1"}]}}`,
+		execute(t, Request{Query: `query A { users { id } } query B { users(limit: 1) { id } }`, OperationName: "B"}, Limits{}))
+}
+
+func TestParse_Values(t *testing.T) {
+	doc, err := parse(`{ f(s: "a\"中\n", i: -12, x: 1.5e2, b: false, n: null, e: DESC, l: [1 "2"], o: {k: $v}) { id } }`)
+	assert.NoError(t, err)
+	args := doc.operations[0].selections[0].arguments
+	assert.Equal(t, "a\"中\n", args["s"])
+	assert.Equal(t, int64(-12), args["i"])
+	assert.Equal(t, 150.0, args["x"])
+	assert.Equal(t, false, args["b"])
+	assert.Nil(t, args["n"])
+	assert.Equal(t, "DESC", args["e"])
+	assert.Equal(t, []interface{}{int64(1), "2"}, args["l"])
+	assert.Equal(t, map[string]interface{}{"k": variable("v")}, args["o"])
+
+	_, err = parse(`{ f(s: "unterminated) }`)
+	assert.Error(t, err)
+	_, err = parse(`query ($v: Int = $w) { f }`)
+	assert.Error(t, err)
+}
+
+func TestArgs(t *testing.T) {
+	args := Args{"n": json.Number("5"), "f": 2.5, "b": true, "s": "x", "l": []interface{}{"a", "b"}}
+
+	n, err := args.Int("n", 0, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	n, err = args.Int("missing", 7, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, n)
+	_, err = args.Int("f", 0, 1, 10)
+	assert.Error(t, err)
+
+	b, err := args.Bool("b")
+	assert.NoError(t, err)
+	assert.True(t, *b)
+	b, err = args.Bool("missing")
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+	_, err = args.Bool("s")
+	assert.Error(t, err)
+
+	l, err := args.Strings("l")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, l)
+	l, err = args.Strings("s")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"x"}, l)
+	_, err = args.String("n")
+	assert.Error(t, err)
+}
+
+func TestObject_OmittedFieldsAreNull(t *testing.T) {
+	type embedded struct {
+		Note string `json:"note,omitempty"`
+	}
+	type value struct {
+		embedded
+		Tag    string `json:"tag,omitempty"`
+		Secret string `json:"-"`
+	}
+	obj := NewObject("Value", &value{}, nil)
+
+	for _, name := range []string{"tag", "note"} {
+		v, err := obj.resolve(name, nil)
+		assert.NoError(t, err)
+		assert.Nil(t, v)
+	}
+	_, err := obj.resolve("Secret", nil)
+	assert.Error(t, err)
+	_, err = obj.resolve("missing", nil)
+	assert.Error(t, err)
+}
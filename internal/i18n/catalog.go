@@ -0,0 +1,183 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLanguage 未配置时的默认语言
+const DefaultLanguage = "zh-CN"
+
+//go:embed locales/*.json
+var builtinLocales embed.FS
+
+// Catalog 多语言消息目录，每种语言一个 键->模板 映射，模板中的 {name} 会被参数替换
+type Catalog struct {
+	defaultLanguage string
+	languages       []string
+	matcher         language.Matcher
+	messages        map[string]map[string]string
+}
+
+// NewCatalog 加载内置语言包，dir不为空时再加载目录下的 <语言>.json 覆盖或新增语言
+func NewCatalog(defaultLanguage, dir string) (*Catalog, error) {
+	if defaultLanguage == "" {
+		defaultLanguage = DefaultLanguage
+	}
+	defaultLanguage, err := Canonicalize(defaultLanguage)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(map[string]map[string]string)
+	entries, err := builtinLocales.ReadDir("locales")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read builtin locales: %w", err)
+	}
+	for _, entry := range entries {
+		data, err := builtinLocales.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read builtin locale %s: %w", entry.Name(), err)
+		}
+		if err := mergeLocale(messages, entry.Name(), data); err != nil {
+			return nil, err
+		}
+	}
+
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list locales in %s: %w", dir, err)
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read locale %s: %w", file, err)
+			}
+			if err := mergeLocale(messages, filepath.Base(file), data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, ok := messages[defaultLanguage]; !ok {
+		return nil, fmt.Errorf("no locale for default language %s", defaultLanguage)
+	}
+
+	// 默认语言放在首位，匹配失败时选中它
+	languages := []string{defaultLanguage}
+	for lang := range messages {
+		if lang != defaultLanguage {
+			languages = append(languages, lang)
+		}
+	}
+	sort.Strings(languages[1:])
+
+	tags := make([]language.Tag, 0, len(languages))
+	for _, lang := range languages {
+		tags = append(tags, language.MustParse(lang))
+	}
+
+	return &Catalog{
+		defaultLanguage: defaultLanguage,
+		languages:       languages,
+		matcher:         language.NewMatcher(tags),
+		messages:        messages,
+	}, nil
+}
+
+// mergeLocale 解析语言包文件并合并到目录中，文件名为语言标签
+func mergeLocale(messages map[string]map[string]string, name string, data []byte) error {
+	lang, err := Canonicalize(strings.TrimSuffix(name, filepath.Ext(name)))
+	if err != nil {
+		return fmt.Errorf("invalid locale file name %s: %w", name, err)
+	}
+
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse locale %s: %w", name, err)
+	}
+	if messages[lang] == nil {
+		messages[lang] = make(map[string]string, len(entries))
+	}
+	for key, tmpl := range entries {
+		messages[lang][key] = tmpl
+	}
+	return nil
+}
+
+// Canonicalize 校验并规范化BCP 47语言标签，如 zh-cn -> zh-CN
+func Canonicalize(lang string) (string, error) {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return "", fmt.Errorf("invalid language tag %q: %w", lang, err)
+	}
+	return tag.String(), nil
+}
+
+// DefaultLanguage 获取默认语言
+func (c *Catalog) DefaultLanguage() string {
+	return c.defaultLanguage
+}
+
+// Languages 获取支持的语言，默认语言在首位
+func (c *Catalog) Languages() []string {
+	return c.languages
+}
+
+// Match 为用户偏好的语言选择最接近的已支持语言，如 zh -> zh-CN、en-GB -> en，无法匹配时返回默认语言
+func (c *Catalog) Match(lang string) string {
+	if lang == "" {
+		return c.defaultLanguage
+	}
+	if _, ok := c.messages[lang]; ok {
+		return lang
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return c.defaultLanguage
+	}
+	_, index, confidence := c.matcher.Match(tag)
+	if confidence == language.No {
+		return c.defaultLanguage
+	}
+	return c.languages[index]
+}
+
+// Has 判断默认语言中是否有该键，其他语言缺少的键在渲染时回退到默认语言
+func (c *Catalog) Has(key string) bool {
+	_, ok := c.messages[c.defaultLanguage][key]
+	return ok
+}
+
+// Render 按语言渲染消息，语言中缺少该键时回退到默认语言，仍缺少时返回键本身
+func (c *Catalog) Render(lang, key string, params map[string]string) string {
+	tmpl, ok := c.messages[c.Match(lang)][key]
+	if !ok {
+		if tmpl, ok = c.messages[c.defaultLanguage][key]; !ok {
+			return key
+		}
+	}
+
+	pairs := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+// Builtin 只包含内置语言包、以zh-CN为默认语言的目录，用于未配置多语言的场景
+func Builtin() *Catalog {
+	catalog, err := NewCatalog(DefaultLanguage, "")
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
@@ -0,0 +1,55 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog_MatchAndRender(t *testing.T) {
+	catalog, err := NewCatalog("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "zh-CN", catalog.DefaultLanguage())
+
+	assert.Equal(t, "en", catalog.Match("en-GB"))
+	assert.Equal(t, "zh-CN", catalog.Match("zh"))
+	assert.Equal(t, "zh-CN", catalog.Match("fr"))
+	assert.Equal(t, "zh-CN", catalog.Match("not a tag"))
+
+	params := map[string]string{"user": "u1"}
+	assert.Equal(t, "u1 joined the group", catalog.Render("en-US", "member_joined", params))
+	assert.Equal(t, "u1 加入了群聊", catalog.Render("", "member_joined", params))
+	assert.Equal(t, "unknown_event", catalog.Render("en", "unknown_event", params))
+}
+
+func TestCatalog_LocalesDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{"member_joined": "{user} a rejoint le groupe"}`), 0o644))
+
+	catalog, err := NewCatalog("en", dir)
+	assert.NoError(t, err)
+	assert.Contains(t, catalog.Languages(), "fr")
+	assert.Equal(t, "u1 a rejoint le groupe", catalog.Render("fr-CA", "member_joined", map[string]string{"user": "u1"}))
+	// 缺少的键回退到默认语言
+	assert.Equal(t, "u1 left the group", catalog.Render("fr", "member_left", map[string]string{"user": "u1"}))
+
+	_, err = NewCatalog("de", dir)
+	assert.Error(t, err)
+}
+
+func TestCanonicalize(t *testing.T) {
+	lang, err := Canonicalize("zh-cn")
+	assert.NoError(t, err)
+	assert.Equal(t, "zh-CN", lang)
+
+	_, err = Canonicalize("???")
+	assert.Error(t, err)
+}
+
+func TestCatalog_Has(t *testing.T) {
+	catalog := Builtin()
+	assert.True(t, catalog.Has("push_body_text"))
+	assert.False(t, catalog.Has("push_body_location"))
+}
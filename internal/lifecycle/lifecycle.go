@@ -0,0 +1,242 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/pkg/logger"
+)
+
+// State 组件状态
+type State string
+
+const (
+	StateStopped  State = "stopped"  // 尚未启动或已停止
+	StateRunning  State = "running"  // 已启动
+	StateDisabled State = "disabled" // 被配置关闭，不启动
+	StateFailed   State = "failed"   // 启动失败
+)
+
+// Component 由容器管理启动和停止的组件
+// Start为nil表示组件在创建时已就绪（如已打开的存储），不能被关闭，只在停止时调用Stop
+type Component struct {
+	Name   string
+	Start  func() error
+	Stop   func() error
+	Health func() error // 运行中的组件的健康检查，nil表示运行中即健康
+}
+
+// Status 组件的状态和最近一次健康检查结果
+type Status struct {
+	Name      string `json:"name"`
+	State     State  `json:"state"`
+	Healthy   bool   `json:"healthy"`
+	Error     string `json:"error,omitempty"`
+	CheckedAt int64  `json:"checked_at,omitempty"`
+}
+
+// entry 容器中的组件
+type entry struct {
+	Component
+	state     State
+	err       error
+	checkedAt time.Time
+}
+
+// Lifecycle 组件容器，按添加顺序启动，按相反的顺序停止
+type Lifecycle struct {
+	mu         sync.Mutex
+	components []*entry
+	disabled   map[string]bool
+}
+
+// New 创建组件容器，disabled中的组件不启动
+func New(disabled []string) *Lifecycle {
+	l := &Lifecycle{disabled: make(map[string]bool, len(disabled))}
+	for _, name := range disabled {
+		l.disabled[name] = true
+	}
+	return l
+}
+
+// Append 添加组件，同名组件只保留第一个
+func (l *Lifecycle) Append(c Component) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.components {
+		if e.Name == c.Name {
+			logger.Warn("Duplicate component ignored", logger.String("component", c.Name))
+			return
+		}
+	}
+	e := &entry{Component: c, state: StateStopped}
+	if c.Start == nil {
+		e.state = StateRunning
+	}
+	l.components = append(l.components, e)
+}
+
+// Start 按添加顺序启动组件，跳过被关闭的组件；某个组件启动失败时停止启动后续组件并返回错误，
+// 已启动的组件需调用Stop停止
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.warnUnknownDisabled()
+	for _, e := range l.components {
+		if e.state != StateStopped {
+			continue
+		}
+		if l.disabled[e.Name] {
+			e.state = StateDisabled
+			logger.Info("Component disabled", logger.String("component", e.Name))
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start := time.Now()
+		if err := e.Start(); err != nil {
+			e.state, e.err = StateFailed, err
+			metrics.ComponentUp.WithLabelValues(e.Name).Set(0)
+			return fmt.Errorf("failed to start %s: %w", e.Name, err)
+		}
+		e.state = StateRunning
+		metrics.ComponentUp.WithLabelValues(e.Name).Set(1)
+		logger.Debug("Component started",
+			logger.String("component", e.Name),
+			logger.Int64("duration_ms", time.Since(start).Milliseconds()))
+	}
+	return nil
+}
+
+// warnUnknownDisabled 关闭的组件在当前模式下不存在或不能关闭时记录警告，可能是名称拼写错误
+func (l *Lifecycle) warnUnknownDisabled() {
+	for name := range l.disabled {
+		var found *entry
+		for _, e := range l.components {
+			if e.Name == name {
+				found = e
+				break
+			}
+		}
+		switch {
+		case found == nil:
+			logger.Warn("Disabled component is not registered", logger.String("component", name))
+		case found.Start == nil:
+			logger.Warn("Component cannot be disabled", logger.String("component", name))
+		}
+	}
+}
+
+// Stop 按与添加相反的顺序停止运行中的组件，返回第一个错误
+func (l *Lifecycle) Stop() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var firstErr error
+	for i := len(l.components) - 1; i >= 0; i-- {
+		e := l.components[i]
+		if e.state != StateRunning {
+			continue
+		}
+		e.state = StateStopped
+		metrics.ComponentUp.WithLabelValues(e.Name).Set(0)
+		if e.Stop == nil {
+			continue
+		}
+		if err := e.Stop(); err != nil {
+			logger.Error("Failed to stop component", logger.String("component", e.Name), logger.ErrorField(err))
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to stop %s: %w", e.Name, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// CheckHealth 对运行中的组件执行健康检查并记录结果
+// 检查在锁外执行，慢的检查不阻塞状态查询
+func (l *Lifecycle) CheckHealth() {
+	l.mu.Lock()
+	var running []*entry
+	for _, e := range l.components {
+		if e.state == StateRunning && e.Health != nil {
+			running = append(running, e)
+		}
+	}
+	l.mu.Unlock()
+
+	for _, e := range running {
+		err := e.Health()
+		l.mu.Lock()
+		e.err, e.checkedAt = err, time.Now()
+		up := 1.0
+		if err != nil || e.state != StateRunning {
+			up = 0
+		}
+		metrics.ComponentUp.WithLabelValues(e.Name).Set(up)
+		l.mu.Unlock()
+		if err != nil {
+			logger.Warn("Component unhealthy", logger.String("component", e.Name), logger.ErrorField(err))
+		}
+	}
+}
+
+// Watch 定期执行健康检查，直到ctx取消
+func (l *Lifecycle) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	l.CheckHealth()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.CheckHealth()
+		}
+	}
+}
+
+// Status 返回所有组件的状态，按名称排序
+func (l *Lifecycle) Status() []Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	statuses := make([]Status, 0, len(l.components))
+	for _, e := range l.components {
+		status := Status{Name: e.Name, State: e.state}
+		status.Healthy = e.healthy()
+		if e.err != nil {
+			status.Error = e.err.Error()
+		}
+		if !e.checkedAt.IsZero() {
+			status.CheckedAt = e.checkedAt.Unix()
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Healthy 是否没有启动失败或健康检查失败的组件，被关闭的组件不影响结果
+func (l *Lifecycle) Healthy() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.components {
+		if e.state != StateDisabled && !e.healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// healthy 组件运行中且最近一次健康检查通过
+func (e *entry) healthy() bool {
+	return e.state == StateRunning && e.err == nil
+}
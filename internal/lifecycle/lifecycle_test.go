@@ -0,0 +1,79 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func record(events *[]string, event string) func() error {
+	return func() error {
+		*events = append(*events, event)
+		return nil
+	}
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	var events []string
+	l := New([]string{"jobs", "store"})
+	l.Append(Component{Name: "store", Stop: record(&events, "close store")})
+	l.Append(Component{Name: "registry", Start: record(&events, "start registry"), Stop: record(&events, "stop registry")})
+	l.Append(Component{Name: "jobs", Start: record(&events, "start jobs"), Stop: record(&events, "stop jobs")})
+	l.Append(Component{Name: "heartbeat", Start: record(&events, "start heartbeat")})
+
+	assert.NoError(t, l.Start(context.Background()))
+	assert.True(t, l.Healthy())
+	states := map[string]State{}
+	for _, status := range l.Status() {
+		states[status.Name] = status.State
+	}
+	// 存储在创建时已打开，不能关闭
+	assert.Equal(t, map[string]State{
+		"store": StateRunning, "registry": StateRunning, "jobs": StateDisabled, "heartbeat": StateRunning,
+	}, states)
+
+	assert.NoError(t, l.Stop())
+	assert.Equal(t, []string{"start registry", "start heartbeat", "stop registry", "close store"}, events)
+	// 重复停止不再执行
+	assert.NoError(t, l.Stop())
+	assert.Len(t, events, 4)
+}
+
+func TestLifecycleStartFailure(t *testing.T) {
+	var events []string
+	l := New(nil)
+	l.Append(Component{Name: "a", Start: record(&events, "start a"), Stop: record(&events, "stop a")})
+	l.Append(Component{Name: "b", Start: func() error { return errors.New("boom") }, Stop: record(&events, "stop b")})
+	l.Append(Component{Name: "c", Start: record(&events, "start c")})
+
+	assert.EqualError(t, l.Start(context.Background()), "failed to start b: boom")
+	assert.False(t, l.Healthy())
+	// 未启动成功的组件不停止
+	assert.NoError(t, l.Stop())
+	assert.Equal(t, []string{"start a", "stop a"}, events)
+}
+
+func TestLifecycleHealth(t *testing.T) {
+	var healthErr error
+	l := New(nil)
+	l.Append(Component{Name: "redis", Health: func() error { return healthErr }})
+	l.Append(Component{Name: "jobs", Start: func() error { return nil }})
+	assert.NoError(t, l.Start(context.Background()))
+
+	healthErr = errors.New("connection refused")
+	l.CheckHealth()
+	assert.False(t, l.Healthy())
+	statuses := l.Status()
+	assert.Equal(t, "jobs", statuses[0].Name)
+	assert.True(t, statuses[0].Healthy)
+	assert.Equal(t, "redis", statuses[1].Name)
+	assert.False(t, statuses[1].Healthy)
+	assert.Equal(t, "connection refused", statuses[1].Error)
+	assert.NotZero(t, statuses[1].CheckedAt)
+
+	healthErr = nil
+	l.CheckHealth()
+	assert.True(t, l.Healthy())
+}
@@ -0,0 +1,254 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace 指标命名空间
+const namespace = "im"
+
+var (
+	// SpamDetections 垃圾消息规则命中次数
+	SpamDetections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spam_detections_total",
+		Help:      "Number of spam rule hits, by rule.",
+	}, []string{"rule"})
+
+	// SpamThrottledMessages 因发送限制被拒绝的消息数
+	SpamThrottledMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spam_throttled_messages_total",
+		Help:      "Number of messages rejected while the sender was throttled.",
+	})
+
+	// WordFilterHits 命中敏感词的消息数，按过滤级别和动作统计
+	WordFilterHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "word_filter_hits_total",
+		Help:      "Number of messages containing blocked words, by level and action.",
+	}, []string{"level", "action"})
+
+	// UnsafeLinks 消息中被判定为不安全的链接数，按判定来源和动作统计
+	UnsafeLinks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "unsafe_links_total",
+		Help:      "Number of unsafe links found in messages, by verdict source and action.",
+	}, []string{"source", "action"})
+
+	// MessagesSent 发送成功的消息数，按优先级统计
+	MessagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Number of messages sent, by priority.",
+	}, []string{"priority"})
+
+	// MessageDeliveries 私聊消息的投递次数，按投递方式（local、relay、offline）统计
+	MessageDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "message_deliveries_total",
+		Help:      "Number of private message deliveries, by route: local, relay or offline.",
+	}, []string{"route"})
+
+	// DuplicatesSuppressed 消费端去重跳过的重复消息数，按消费者统计
+	DuplicatesSuppressed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "duplicates_suppressed_total",
+		Help:      "Number of redelivered Kafka records skipped by consumer-side dedup, by consumer.",
+	}, []string{"consumer"})
+
+	// KafkaConsumerLag 消费者在各分区上落后的记录数
+	KafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kafka_consumer_lag",
+		Help:      "Number of records behind the partition high watermark, by topic and partition.",
+	}, []string{"topic", "partition"})
+
+	// ClientSessions 本节点已登录会话数，按客户端平台和版本统计
+	ClientSessions = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "client_sessions",
+		Help:      "Number of logged-in sessions on this node, by client platform and app version.",
+	}, []string{"platform", "app_version"})
+
+	// DeliveryStageSeconds 采样消息各投递阶段的耗时
+	DeliveryStageSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "delivery_stage_seconds",
+		Help:      "Latency of sampled message deliveries, by stage: store, queue, push, ack and total.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"stage"})
+
+	// DeliverySLO 采样消息从发送到客户端确认是否在目标耗时内，按结果统计
+	DeliverySLO = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "delivery_slo_total",
+		Help:      "Number of sampled deliveries acknowledged by the client, by whether the latency target was met.",
+	}, []string{"result"})
+
+	// CanaryProbes 合成探测次数，按目标节点和结果统计
+	CanaryProbes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "canary_probes_total",
+		Help:      "Number of synthetic canary probes sent from this node, by target node and result: ok, timeout or error.",
+	}, []string{"target", "result"})
+
+	// CanaryLatencySeconds 合成探测消息从发送到送达目标节点探测用户的耗时
+	CanaryLatencySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "canary_latency_seconds",
+		Help:      "End-to-end latency of delivered synthetic canary probes, by target node.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"target"})
+
+	// CanaryHealthy 本节点给自己的探测是否正常，连续失败达到阈值时为0
+	CanaryHealthy = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "canary_healthy",
+		Help:      "Whether this node's own canary probes are succeeding (1) or have failed repeatedly (0).",
+	})
+
+	// SpoolEntries 本地暂存区中等待重放的事件数
+	SpoolEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "spool_entries",
+		Help:      "Number of delivery events parked in the local spool waiting for replay.",
+	})
+
+	// SpooledEvents 因Kafka或Redis不可用写入本地暂存区的事件数，按事件类型统计
+	SpooledEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spooled_events_total",
+		Help:      "Number of delivery events parked in the local spool, by kind.",
+	}, []string{"kind"})
+
+	// SpoolReplayed 从本地暂存区重放成功的事件数，按事件类型统计
+	SpoolReplayed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spool_replayed_total",
+		Help:      "Number of spooled delivery events replayed after dependencies recovered, by kind.",
+	}, []string{"kind"})
+
+	// RecoveredMessages 投递恢复扫描重新投递的消息数
+	RecoveredMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "recovered_messages_total",
+		Help:      "Number of stale sent messages re-enqueued by the delivery recovery sweep.",
+	})
+
+	// GroupCacheDrift 对账发现的群组缓存与数据库不一致次数
+	GroupCacheDrift = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "group_cache_drift_total",
+		Help:      "Number of group cache entries found diverged from MySQL during reconciliation, by field.",
+	}, []string{"field"})
+
+	// GroupCacheInvalidations 写入失败后删除的群组缓存数
+	GroupCacheInvalidations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "group_cache_invalidations_total",
+		Help:      "Number of group caches invalidated after a failed write-through.",
+	})
+
+	// MessageLookups 按消息ID查询的结果来源
+	MessageLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "message_lookups_total",
+		Help:      "Number of message lookups by ID, by source: local, redis, shared, store, negative or missing.",
+	}, []string{"source"})
+
+	// OfficialBroadcastMessages 公众号群发给关注者的消息
+	OfficialBroadcastMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "official_broadcast_messages_total",
+		Help:      "Number of official account broadcast messages, by result: sent or failed.",
+	}, []string{"result"})
+
+	// WebSocketBytes 本节点已登录连接收发的字节数，按方向统计
+	WebSocketBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_bytes_total",
+		Help:      "Number of frame payload bytes received and sent on logged-in connections on this node, by direction: in or out.",
+	}, []string{"direction"})
+
+	// ThrottledSessions 本节点因超出流量上限被限速的会话数
+	ThrottledSessions = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "throttled_sessions",
+		Help:      "Number of sessions on this node whose downstream bandwidth is throttled for exceeding the user traffic limit.",
+	})
+
+	// WebSocketAdmissions 本节点握手准入判断次数，按结果统计
+	WebSocketAdmissions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_admissions_total",
+		Help:      "Number of WebSocket handshake admission decisions on this node, by result: accepted, priority or rejected.",
+	}, []string{"result"})
+
+	// DrainReconnectHints 本节点排空时推送的重连提示数
+	DrainReconnectHints = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "drain_reconnect_hints_total",
+		Help:      "Number of reconnect hints pushed to clients while this node drains its connections.",
+	})
+
+	// StoreOperationSeconds 存储操作的耗时，MySQL按操作类型和表、Redis按命令、LevelDB按方法统计
+	StoreOperationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_operation_seconds",
+		Help:      "Latency of store operations, by store (mysql, redis or leveldb) and operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 13),
+	}, []string{"store", "operation"})
+
+	// HTTPRequests HTTP请求数，按方法、路由模板和状态码统计，未匹配的路由为unmatched
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestSeconds HTTP请求的处理耗时，按方法和路由模板统计
+	HTTPRequestSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_seconds",
+		Help:      "Latency of HTTP requests, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// HTTPRateLimited 因限流被拒绝的HTTP请求数
+	HTTPRateLimited = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_rate_limited_total",
+		Help:      "Number of HTTP requests rejected by the rate limiter.",
+	})
+
+	// ComponentUp 服务器组件是否运行且健康
+	ComponentUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "component_up",
+		Help:      "Whether a server component is running and healthy (1) or not (0).",
+	}, []string{"component"})
+
+	// KafkaProcessingSeconds 单条Kafka记录的处理耗时
+	KafkaProcessingSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_processing_seconds",
+		Help:      "Time spent handling a single Kafka record, by topic.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	// FederationEvents 跨域联邦事件数，按方向（outbound/inbound）和结果统计
+	FederationEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "federation_events_total",
+		Help:      "Number of federation events sent to or received from remote domains, by direction and result.",
+	}, []string{"direction", "result"})
+
+	// FederationOutboxDepth 发往各远程域的待投递事件数
+	FederationOutboxDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "federation_outbox_depth",
+		Help:      "Number of federation events waiting in the outbox of a remote domain.",
+	}, []string{"domain"})
+)
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// AnalyticsDayLayout 统计日期格式，按UTC自然日统计
+const AnalyticsDayLayout = "2006-01-02"
+
+// DailyStats 每日汇总统计
+type DailyStats struct {
+	Day                  string    `json:"day" gorm:"primaryKey;type:varchar(10)"`
+	Messages             int64     `json:"messages"`                // 用户发送的消息数，不含系统消息
+	ActiveUsers          int64     `json:"active_users"`            // 当日连接或发送过消息的用户数（HyperLogLog估算）
+	PeakConnections      int64     `json:"peak_connections"`        // 全集群同时在线连接数的峰值（按采样间隔）
+	Deliveries           int64     `json:"deliveries"`              // 记录了投递回执的消息数
+	AvgDeliveryLatencyMs int64     `json:"avg_delivery_latency_ms"` // 发送到投递回执的平均耗时
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// GroupDailyStats 群组每日活跃度
+type GroupDailyStats struct {
+	Day      string `json:"day" gorm:"primaryKey;type:varchar(10)"`
+	GroupID  string `json:"group_id" gorm:"primaryKey;type:varchar(64)"`
+	Messages int64  `json:"messages"`
+}
+
+// GroupActivity 群组在统计区间内的消息数
+type GroupActivity struct {
+	GroupID  string `json:"group_id"`
+	Messages int64  `json:"messages"`
+}
+
+// AnalyticsReport 统计区间的汇总报告
+type AnalyticsReport struct {
+	From   string           `json:"from"`
+	To     string           `json:"to"`
+	Days   []*DailyStats    `json:"days"`
+	Groups []*GroupActivity `json:"groups"` // 区间内消息最多的群组
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// APIKeyScopeAll 允许向任意会话发送的API密钥范围
+const APIKeyScopeAll = "*"
+
+// APIKey 服务间调用的API密钥，后端系统以绑定的系统账号身份发送消息
+type APIKey struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:varchar(64)"`
+	Name          string    `json:"name" gorm:"type:varchar(100)"`
+	SenderID      string    `json:"sender_id" gorm:"type:varchar(64);index"` // 消息以该账号的身份发送
+	Prefix        string    `json:"prefix" gorm:"type:varchar(16)"`          // 密钥明文前缀，便于识别
+	KeyHash       string    `json:"-" gorm:"type:varchar(64);uniqueIndex"`   // 密钥的SHA-256摘要，明文只在创建时返回一次
+	Conversations []string  `json:"conversations" gorm:"type:json;serializer:json"`
+	RateLimit     int       `json:"rate_limit"`                            // 每分钟最多发送的消息数
+	RevokedAt     int64     `json:"revoked_at,omitempty" gorm:"default:0"` // 吊销时间（Unix秒），非0时密钥不可用
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// IsRevoked 判断密钥是否已吊销
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt > 0
+}
+
+// Allows 判断密钥是否允许向会话发送消息
+// 范围可以是具体会话ID、按类型的通配 private:* 和 group:*，或 * 表示全部
+func (k *APIKey) Allows(conversationID string) bool {
+	conversationType, _, err := ParseConversationID(conversationID)
+	if err != nil {
+		return false
+	}
+	for _, scope := range k.Conversations {
+		if scope == APIKeyScopeAll || scope == conversationID || scope == ConversationID(conversationType, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAPIKeyScope 校验API密钥范围的格式
+func ValidateAPIKeyScope(scope string) error {
+	if scope == APIKeyScopeAll {
+		return nil
+	}
+	if _, _, err := ParseConversationID(scope); err != nil {
+		return fmt.Errorf("invalid scope %q: %w", scope, err)
+	}
+	return nil
+}
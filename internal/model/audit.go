@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// 审计动作，除会话登录外都是管理操作
+const (
+	AuditActionInspectOfflineQueue   = "offline_queue.inspect"
+	AuditActionRedeliverMessage      = "offline_queue.redeliver"
+	AuditActionClearOfflineQueue     = "offline_queue.clear"
+	AuditActionSetClientFeature      = "client_config.set_feature"
+	AuditActionResetClientFeature    = "client_config.reset_feature"
+	AuditActionSetFeatureFlag        = "feature_flag.set"
+	AuditActionResetFeatureFlag      = "feature_flag.reset"
+	AuditActionSetQuota              = "quota.set"
+	AuditActionResetQuota            = "quota.reset"
+	AuditActionResetTwoFactor        = "two_factor.reset"
+	AuditActionSyncOrg               = "org.sync"
+	AuditActionSyncDeptGroups        = "org.sync_groups"
+	AuditActionSetStickerPack        = "sticker_pack.set"
+	AuditActionDeleteStickerPack     = "sticker_pack.delete"
+	AuditActionScanUpload            = "upload.scan"
+	AuditActionResolveReport         = "report.resolve"
+	AuditActionReloadWordFilter      = "word_filter.reload"
+	AuditActionSetOfficialAccount    = "official_account.set"
+	AuditActionDeleteOfficialAccount = "official_account.delete"
+	AuditActionOfficialBroadcast     = "official_account.broadcast"
+	AuditActionCreateGuestToken      = "guest_token.create"
+	AuditActionRevokeGuestToken      = "guest_token.revoke"
+	AuditActionSetMessageTemplate    = "message_template.set"
+	AuditActionDeleteMessageTemplate = "message_template.delete"
+	AuditActionDisconnectUser        = "user.disconnect"
+	AuditActionAssignAdminRole       = "admin_role.assign"
+	AuditActionRevokeAdminRole       = "admin_role.revoke"
+	AuditActionPermissionDenied      = "admin.permission_denied" // 管理人员请求了角色不允许的接口
+	AuditActionDrainNode             = "node.drain"
+	AuditActionSessionLogin          = "session.login" // 用户登录，执行者为登录的用户
+)
+
+// AuditLog 管理操作审计记录
+type AuditLog struct {
+	ID        string            `json:"id" gorm:"primaryKey;type:varchar(64)"`
+	Actor     string            `json:"actor" gorm:"type:varchar(64);index"`          // 执行操作的管理人员，来自IM令牌或 X-Admin-Actor
+	Role      string            `json:"role,omitempty" gorm:"type:varchar(64);index"` // 操作人当时的管理角色，多个时以逗号分隔
+	Action    string            `json:"action" gorm:"type:varchar(64);index"`
+	Target    string            `json:"target" gorm:"type:varchar(140);index"` // 操作对象，如用户ID
+	Details   map[string]string `json:"details,omitempty" gorm:"type:json;serializer:json"`
+	CreatedAt time.Time         `json:"created_at" gorm:"index"`
+}
+
+// OfflineQueueReport 用户待投递离线消息的快照
+type OfflineQueueReport struct {
+	UserID    string     `json:"user_id"`
+	Online    bool       `json:"online"`
+	Urgent    []*Message `json:"urgent"`    // Redis中的紧急离线队列
+	Queued    []*Message `json:"queued"`    // Redis中的普通离线队列
+	Corrupted int        `json:"corrupted"` // Redis队列中无法解析的条目数
+	Stored    []*Message `json:"stored"`    // 存储后端中尚未投递的消息
+}
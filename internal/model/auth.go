@@ -0,0 +1,49 @@
+package model
+
+// IM角色，由身份提供方的组或角色映射而来，管理角色也可以通过管理接口分配，各角色的权限见AdminRolePermissions
+const (
+	RoleAdmin      = "admin"      // 管理接口全部权限，与superadmin相同
+	RoleAuditor    = "auditor"    // 管理接口只读，包括审计记录
+	RoleSupport    = "support"    // 客服：查看用户会话和离线队列，强制下线，重置两步验证
+	RoleModerator  = "moderator"  // 内容审核：处罚用户、处理举报、删除消息
+	RoleOperator   = "operator"   // 运维：集群状态、配置和配额
+	RoleSuperAdmin = "superadmin" // 全部权限，包括分配管理角色
+)
+
+// TokenClaims IM令牌中的声明
+type TokenClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"` // IM用户ID
+	Roles     []string `json:"roles,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// HasRole 判断令牌是否带有角色
+func (c *TokenClaims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthToken 登录成功后签发的IM令牌
+type AuthToken struct {
+	AccessToken string   `json:"access_token"`
+	TokenType   string   `json:"token_type"`
+	ExpiresIn   int64    `json:"expires_in"` // 秒
+	UserID      string   `json:"user_id"`
+	Roles       []string `json:"roles,omitempty"`
+	Provisioned bool     `json:"provisioned,omitempty"` // 本次登录新建了用户资料
+}
+
+// OIDCLoginRequest 用身份提供方的令牌换取IM令牌，提供id_token或授权码之一
+type OIDCLoginRequest struct {
+	IDToken       string `json:"id_token"`
+	Code          string `json:"code"`
+	RedirectURI   string `json:"redirect_uri"`
+	CodeVerifier  string `json:"code_verifier"`   // 授权码流程使用PKCE时提供
+	TwoFactorCode string `json:"two_factor_code"` // 用户开启了两步验证时必填
+}
@@ -0,0 +1,22 @@
+package model
+
+// ConnectionTraffic 用户当前连接的流量，由连接所在节点定期更新
+type ConnectionTraffic struct {
+	ConnectionID string `json:"connection_id"`
+	NodeID       string `json:"node_id"`
+	BytesIn      int64  `json:"bytes_in"`
+	BytesOut     int64  `json:"bytes_out"`
+	RateLimit    int64  `json:"rate_limit,omitempty"` // 当前的下行限速（字节/秒）
+	Throttled    bool   `json:"throttled"`            // 是否因超出流量上限被限速
+	UpdatedAt    int64  `json:"updated_at"`
+}
+
+// UserBandwidth 用户在滚动窗口内收发的字节数
+type UserBandwidth struct {
+	UserID     string             `json:"user_id"`
+	Window     int64              `json:"window"` // 窗口长度（秒）
+	BytesIn    int64              `json:"bytes_in"`
+	BytesOut   int64              `json:"bytes_out"`
+	Limit      int64              `json:"limit,omitempty"` // 窗口内收发字节合计上限
+	Connection *ConnectionTraffic `json:"connection,omitempty"`
+}
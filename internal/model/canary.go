@@ -0,0 +1,17 @@
+package model
+
+// CanaryProbe 最近一次合成探测的结果
+type CanaryProbe struct {
+	Target    string `json:"target"`
+	Result    string `json:"result"` // ok, timeout, error
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	At        int64  `json:"at"`
+}
+
+// CanaryStatus 本节点的合成探测状态
+type CanaryStatus struct {
+	Healthy             bool                    `json:"healthy"`
+	ConsecutiveFailures int                     `json:"consecutive_failures"` // 本节点给自己的探测连续失败次数
+	Probes              map[string]*CanaryProbe `json:"probes"`               // 按目标节点的最近一次探测
+}
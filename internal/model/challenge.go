@@ -0,0 +1,37 @@
+package model
+
+// 登录风险信号
+const (
+	LoginSignalNewDevice = "new_device"      // 用户已有登录记录，但从未在该设备上登录
+	LoginSignalFailures  = "recent_failures" // 近期验证失败次数过多
+	LoginSignalUnusualIP = "unusual_ip"      // 登录IP不在用户近期使用的网段内
+	LoginSignalTwoFactor = "two_factor"      // 用户开启了两步验证，每次登录都需要验证
+)
+
+// ChallengeMethodTOTP 两步验证的验证方式，答案为验证器应用生成的动态码或恢复码
+const ChallengeMethodTOTP = "totp"
+
+// LoginChallenge 登录需要额外验证时下发的challenge_required帧
+type LoginChallenge struct {
+	ChallengeID string            `json:"challenge_id"`
+	Method      string            `json:"method"`           // 验证方式，如captcha、otp
+	Signals     []string          `json:"signals"`          // 触发验证的风险信号
+	ExpiresIn   int64             `json:"expires_in"`       // 秒
+	Params      map[string]string `json:"params,omitempty"` // 验证方式需要的参数，如验证码站点key
+}
+
+// VerifyChallengeRequest 客户端提交验证结果
+type VerifyChallengeRequest struct {
+	ChallengeID string `json:"challenge_id"`
+	Answer      string `json:"answer"`
+}
+
+// PendingChallenge 等待验证的登录，验证通过后以原登录请求完成登录
+type PendingChallenge struct {
+	ChallengeID string       `json:"challenge_id"`
+	Method      string       `json:"method"`
+	SessionID   string       `json:"session_id"` // 只能在发起登录的会话上完成验证
+	IP          string       `json:"ip"`
+	Signals     []string     `json:"signals"`
+	Login       LoginRequest `json:"login"`
+}
@@ -0,0 +1,48 @@
+package model
+
+// 客户端可选功能
+const (
+	ClientFeatureReactions = "reactions"
+	ClientFeatureE2EE      = "e2ee"
+	ClientFeatureAckWindow = "ack_window"
+)
+
+// ClientCapabilities 客户端登录时上报的能力，未上报的旧客户端所有可选功能均视为不支持
+type ClientCapabilities struct {
+	SupportsReactions bool   `json:"supports_reactions"`
+	SupportsE2EE      bool   `json:"supports_e2ee"`
+	SupportsAckWindow bool   `json:"supports_ack_window,omitempty"` // 客户端逐条确认新消息，服务端按确认窗口下发
+	MaxPayload        int    `json:"max_payload,omitempty"`         // 客户端能处理的最大帧字节数，0表示不限制
+	AppVersion        string `json:"app_version,omitempty"`
+	Platform          string `json:"platform,omitempty"`
+}
+
+// Supports 判断客户端是否支持可选功能
+func (c ClientCapabilities) Supports(feature string) bool {
+	switch feature {
+	case ClientFeatureReactions:
+		return c.SupportsReactions
+	case ClientFeatureE2EE:
+		return c.SupportsE2EE
+	case ClientFeatureAckWindow:
+		return c.SupportsAckWindow
+	default:
+		return false
+	}
+}
+
+// AcceptsPayload 判断客户端能否处理指定大小的帧
+func (c ClientCapabilities) AcceptsPayload(size int) bool {
+	return c.MaxPayload <= 0 || size <= c.MaxPayload
+}
+
+// ClientConfig 服务端下发的客户端配置，登录后和变更时推送
+type ClientConfig struct {
+	Version           string               `json:"version"`            // 配置内容摘要，客户端据此判断是否变化
+	HeartbeatInterval int64                `json:"heartbeat_interval"` // 秒
+	MaxMessageSize    int64                `json:"max_message_size"`
+	MediaUploadURL    string               `json:"media_upload_url,omitempty"`
+	Features          map[string]bool      `json:"features"`
+	Flags             map[string]bool      `json:"flags,omitempty"`         // 按用户计算的功能开关
+	StickerPacks      []StickerPackSummary `json:"sticker_packs,omitempty"` // 用户可用的表情包
+}
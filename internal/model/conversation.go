@@ -0,0 +1,75 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConversationType 会话类型
+type ConversationType string
+
+const (
+	ConversationTypePrivate ConversationType = "private"
+	ConversationTypeGroup   ConversationType = "group"
+)
+
+// ConversationID 从用户视角标识一个会话：私聊为 private:<对方用户ID>，群聊为 group:<群组ID>
+func ConversationID(conversationType ConversationType, targetID string) string {
+	return string(conversationType) + ":" + targetID
+}
+
+// ParseConversationID 解析会话ID，返回会话类型和对方用户ID或群组ID
+func ParseConversationID(id string) (ConversationType, string, error) {
+	prefix, targetID, ok := strings.Cut(id, ":")
+	if !ok || targetID == "" {
+		return "", "", fmt.Errorf("invalid conversation id: %s", id)
+	}
+	switch t := ConversationType(prefix); t {
+	case ConversationTypePrivate, ConversationTypeGroup:
+		return t, targetID, nil
+	default:
+		return "", "", fmt.Errorf("invalid conversation type: %s", prefix)
+	}
+}
+
+// UserConversationSettings 用户对某个会话的个人设置
+type UserConversationSettings struct {
+	UserID         string    `json:"user_id" gorm:"primaryKey;type:varchar(64)"`
+	ConversationID string    `json:"conversation_id" gorm:"primaryKey;type:varchar(140)"`
+	Archived       bool      `json:"archived" gorm:"default:false"`
+	Pinned         bool      `json:"pinned" gorm:"default:false"`
+	PinnedAt       int64     `json:"pinned_at" gorm:"default:0"` // 置顶时间（Unix秒），多个置顶会话按此倒序
+	Labels         []string  `json:"labels" gorm:"type:json;serializer:json"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Conversation 会话列表项
+type Conversation struct {
+	ID           string           `json:"id"`
+	Type         ConversationType `json:"type"`
+	TargetID     string           `json:"target_id"`
+	LastActiveAt int64            `json:"last_active_at"`
+	Archived     bool             `json:"archived"`
+	Pinned       bool             `json:"pinned"`
+	PinnedAt     int64            `json:"pinned_at,omitempty"`
+	Official     bool             `json:"official,omitempty"` // 所有用户置顶的公众号会话，用户不能取消置顶
+	Labels       []string         `json:"labels"`
+}
+
+// HasLabel 判断会话是否带有指定标签
+func (c *Conversation) HasLabel(label string) bool {
+	for _, l := range c.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// Draft 用户在会话中未发送的草稿，在用户的多个设备间同步
+type Draft struct {
+	ConversationID string `json:"conversation_id"`
+	Content        string `json:"content"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// ReconnectHint 节点排空时下发的重连提示，客户端在within秒内随机选择时间连接到reconnect_to
+type ReconnectHint struct {
+	ReconnectTo string `json:"reconnect_to"` // 新节点的WebSocket地址
+	NodeID      string `json:"node_id"`      // 新节点ID
+	Reason      string `json:"reason"`
+	Within      int64  `json:"within"` // 秒
+}
+
+// ReconnectReasonDrain 节点滚动发布排空连接
+const ReconnectReasonDrain = "drain"
+
+// DrainStatus 节点排空进度
+type DrainStatus struct {
+	NodeID      string     `json:"node_id"`
+	Draining    bool       `json:"draining"`
+	Done        bool       `json:"done"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	Connections int        `json:"connections"` // 当前连接数
+	Hinted      int        `json:"hinted"`      // 已推送重连提示的会话数
+	Threshold   int        `json:"threshold"`
+	Deadline    *time.Time `json:"deadline,omitempty"` // 超过该时间不论剩余连接数都结束排空
+}
@@ -0,0 +1,89 @@
+package model
+
+// EventSchemaVersion 规范事件的结构版本，只新增字段时不变，修改或删除字段时递增
+const EventSchemaVersion = 1
+
+// EventType 规范事件类型
+type EventType string
+
+const (
+	EventMessageCreated      EventType = "message.created"
+	EventMessageDelivered    EventType = "message.delivered"
+	EventMessageRead         EventType = "message.read"
+	EventGroupMemberJoined   EventType = "group.member_joined"
+	EventGroupMemberLeft     EventType = "group.member_left"
+	EventUserPresenceChanged EventType = "user.presence_changed"
+	EventReportCreated       EventType = "report.created"
+)
+
+// Event 发布到事件主题的规范事件，供分析和下游系统消费
+type Event struct {
+	Version    int         `json:"version"`
+	ID         string      `json:"id"`
+	Type       EventType   `json:"type"`
+	OccurredAt int64       `json:"occurred_at"` // Unix毫秒
+	Data       interface{} `json:"data"`
+}
+
+// MessageEventData message.created 的数据，不含消息内容
+type MessageEventData struct {
+	MessageID      string          `json:"message_id"`
+	ConversationID string          `json:"conversation_id"` // 群聊为 group:<群组ID>，私聊为 private:<接收者ID>
+	SenderID       string          `json:"sender_id"`
+	ReceiverID     string          `json:"receiver_id,omitempty"`
+	GroupID        string          `json:"group_id,omitempty"`
+	Type           MessageType     `json:"type"`
+	Priority       MessagePriority `json:"priority"`
+	Timestamp      int64           `json:"timestamp"`
+}
+
+// ReceiptEventData message.delivered 和 message.read 的数据
+type ReceiptEventData struct {
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user_id"` // 确认的接收者
+	SenderID  string `json:"sender_id"`
+	GroupID   string `json:"group_id,omitempty"`
+}
+
+// MemberEventData 群成员变动事件的数据
+type MemberEventData struct {
+	GroupID string `json:"group_id"`
+	UserID  string `json:"user_id"`
+}
+
+// PresenceEventData user.presence_changed 的数据
+type PresenceEventData struct {
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// ReportEventData report.created 的数据，供审核人员消费，不含被举报的消息内容
+type ReportEventData struct {
+	ReportID     string       `json:"report_id"`
+	ReporterID   string       `json:"reporter_id"`
+	TargetType   ReportTarget `json:"target_type"`
+	TargetUserID string       `json:"target_user_id"`
+	MessageID    string       `json:"message_id,omitempty"`
+	Reason       ReportReason `json:"reason"`
+}
+
+// NewMessageEventData 从消息构造事件数据
+func NewMessageEventData(message *Message) MessageEventData {
+	data := MessageEventData{
+		MessageID:      message.ID,
+		ConversationID: ConversationID(ConversationTypePrivate, message.ReceiverID),
+		SenderID:       message.SenderID,
+		ReceiverID:     message.ReceiverID,
+		GroupID:        message.GroupID,
+		Type:           message.Type,
+		Priority:       message.Priority,
+		Timestamp:      message.Timestamp,
+	}
+	if message.IsGroupMessage() {
+		data.ConversationID = ConversationID(ConversationTypeGroup, message.GroupID)
+	}
+	if data.Priority == "" {
+		data.Priority = MessagePriorityNormal
+	}
+	return data
+}
@@ -0,0 +1,26 @@
+package model
+
+// 服务端按用户灰度的功能
+const (
+	FeatureLinkPreview = "link_preview"
+	FeatureReactions   = "reactions"
+)
+
+// FeatureFlagFramePrefix 控制上行帧是否可用的开关名称前缀，如frame.sync_gap
+const FeatureFlagFramePrefix = "frame."
+
+// 功能开关来源
+const (
+	FeatureFlagSourceConfig   = "config"
+	FeatureFlagSourceOverride = "override"
+)
+
+// FeatureFlag 功能开关，白名单用户总是开启，其余用户在开启时按百分比灰度
+type FeatureFlag struct {
+	Name       string   `json:"name"`
+	Enabled    bool     `json:"enabled"`
+	Percentage int      `json:"percentage"`
+	Allowlist  []string `json:"allowlist,omitempty"`
+	Source     string   `json:"source,omitempty"`
+	UpdatedAt  int64    `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,67 @@
+package model
+
+import "encoding/json"
+
+// FederationEventType 跨域联邦事件类型
+type FederationEventType string
+
+const (
+	// FederationEventMessage 发给远程域用户的私聊消息，负载为Message
+	FederationEventMessage FederationEventType = "message"
+	// FederationEventGroupMember 联邦群组的成员变更，由成员所在的域发给群组的主域，负载为FederatedMembership
+	FederationEventGroupMember FederationEventType = "group_member"
+	// FederationEventGroupMessage 联邦群组的群消息，成员所在的域发给主域，主域再转发给其他有成员的域，负载为Message
+	FederationEventGroupMessage FederationEventType = "group_message"
+)
+
+// FederationEvent 在两个部署之间传递的事件
+type FederationEvent struct {
+	ID          string              `json:"id"` // 发送方生成，接收方按发送方域名和ID去重
+	Type        FederationEventType `json:"type"`
+	Origin      string              `json:"origin"`      // 发送方域名
+	Destination string              `json:"destination"` // 接收方域名
+	Timestamp   int64               `json:"timestamp"`   // 事件产生时间
+	SentAt      int64               `json:"sent_at"`     // 本次发送时间，每次重试时更新，接收方据此拒绝重放
+	Payload     json.RawMessage     `json:"payload"`
+}
+
+// FederatedMembership 联邦群组的成员变更，只接受成员所在域发出的变更
+type FederatedMembership struct {
+	GroupID string `json:"group_id"` // 带主域名的群组地址 group@home
+	UserID  string `json:"user_id"`  // 带域名的用户地址
+	Joined  bool   `json:"joined"`
+	Version int64  `json:"version"` // 成员所在域分配的递增版本，接收方只应用比已记录版本新的变更
+}
+
+// SignedFederationEvent 带签名的联邦事件，HTTP和Kafka传输使用相同格式
+type SignedFederationEvent struct {
+	Event     json.RawMessage `json:"event"` // FederationEvent的JSON，签名覆盖原始字节
+	KeyID     string          `json:"key_id"`
+	Signature string          `json:"signature"` // Ed25519签名，base64编码
+}
+
+// 联邦传输方式
+const (
+	FederationTransportHTTP  = "http"
+	FederationTransportKafka = "kafka"
+)
+
+// FederationPeer 远程域的接入信息，来自静态配置或远程域的发现文档
+type FederationPeer struct {
+	Domain    string `json:"domain"`
+	Endpoint  string `json:"endpoint"` // 联邦接口地址，事件发往 {endpoint}/inbox
+	Transport string `json:"transport,omitempty"`
+	Topic     string `json:"topic,omitempty"` // Kafka传输时的目标主题
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"` // Ed25519公钥，base64编码
+}
+
+// FederationQueueStatus 发往某个远程域的发件队列状态
+type FederationQueueStatus struct {
+	Domain      string `json:"domain"`
+	Pending     int64  `json:"pending"`
+	DeadLetters int64  `json:"dead_letters"`
+	Failures    int    `json:"failures,omitempty"`     // 连续投递失败次数
+	NextAttempt int64  `json:"next_attempt,omitempty"` // 退避结束时间
+	LastError   string `json:"last_error,omitempty"`
+}
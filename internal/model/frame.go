@@ -0,0 +1,455 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+//go:generate go run ../../cmd/protodoc -o ../../docs/api/protocol.md
+
+// ProtocolVersion 帧类型和负载格式的版本，与帧编码（im.v1.json、im.v2.proto）无关
+// 删除帧类型或不兼容地修改负载时递增，新增帧类型和可选字段不递增
+const ProtocolVersion = 1
+
+// FrameType WebSocket帧类型
+type FrameType string
+
+// 上行帧类型，部分帧的响应使用同一类型
+const (
+	FrameLogin             FrameType = "login"
+	FrameHeartbeat         FrameType = "heartbeat"
+	FrameTimeSync          FrameType = "time_sync"
+	FrameSendMessage       FrameType = "send_message"
+	FrameAck               FrameType = "ack"
+	FrameSyncOffline       FrameType = "sync_offline"
+	FrameSyncGap           FrameType = "sync_gap"
+	FrameJoinGroup         FrameType = "join_group"
+	FrameLeaveGroup        FrameType = "leave_group"
+	FrameRSVP              FrameType = "rsvp"
+	FrameVote              FrameType = "vote"
+	FrameClosePoll         FrameType = "close_poll"
+	FrameVerifyChallenge   FrameType = "verify_challenge"
+	FrameSubscribePresence FrameType = "subscribe_presence"
+)
+
+// 下行帧类型
+const (
+	FrameError             FrameType = "error"
+	FrameNewMessage        FrameType = "new_message"
+	FrameNewGroupMessage   FrameType = "new_group_message"
+	FrameGroupDigest       FrameType = "group_digest"
+	FrameMessageDeleted    FrameType = "message_deleted"
+	FrameMessageEnriched   FrameType = "message_enriched"
+	FrameDraftUpdated      FrameType = "draft_updated"
+	FrameMessageRequest    FrameType = "message_request"
+	FrameSettingsUpdated   FrameType = "settings_updated"
+	FrameEventUpdated      FrameType = "event_updated"
+	FrameEventReminder     FrameType = "event_reminder"
+	FramePollUpdated       FrameType = "poll_updated"
+	FramePresence          FrameType = "presence"
+	FrameClientConfig      FrameType = "client_config"
+	FrameChallengeRequired FrameType = "challenge_required"
+	FrameReconnect         FrameType = "reconnect"
+)
+
+// ErrUnknownFrame 帧类型未注册或不是上行帧
+var ErrUnknownFrame = errors.New("unknown frame type")
+
+// FrameSpec 帧类型的负载结构和校验规则
+type FrameSpec struct {
+	Type        FrameType
+	Description string
+	// Request 创建上行负载，返回指向负载结构的指针；nil表示客户端不能发送该帧
+	Request func() interface{}
+	// Validate 校验解码后的上行负载，返回不合法的字段，nil表示不校验
+	Validate func(payload interface{}) []FieldError
+	// MaxSize 上行负载的默认大小上限（字节），在解码前检查，0表示只受连接的最大消息大小限制
+	MaxSize int
+	// Response 下行负载的零值，用于生成文档；Downstream为true且Response为nil表示负载没有固定结构
+	Response   interface{}
+	Downstream bool
+	// Connection 连接级帧，不受功能开关控制，在接入节点本地处理
+	Connection bool
+}
+
+// Decode 将帧中的数据解码为上行负载结构并校验，data为json.RawMessage时直接使用原始字节
+// 解码前先检查负载大小，超出上限或校验失败时返回*FrameValidationError
+func (spec FrameSpec) Decode(data interface{}) (interface{}, error) {
+	if spec.Request == nil {
+		return nil, ErrUnknownFrame
+	}
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return nil, fmt.Errorf("failed to encode frame data: %w", err)
+		}
+	}
+	if limit := spec.MaxPayloadSize(); limit > 0 && len(raw) > limit {
+		return nil, &FrameValidationError{Type: spec.Type, Fields: []FieldError{
+			{Field: "data", Reason: fmt.Sprintf("must be at most %d bytes", limit)},
+		}}
+	}
+	payload := spec.Request()
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, payload); err != nil {
+			return nil, decodeError(spec.Type, err)
+		}
+	}
+	if spec.Validate != nil {
+		if fields := spec.Validate(payload); len(fields) > 0 {
+			return nil, &FrameValidationError{Type: spec.Type, Fields: fields}
+		}
+	}
+	return payload, nil
+}
+
+// frameSpecs 已注册的帧类型，顺序即协议文档中的顺序
+var frameSpecs = []FrameSpec{
+	{
+		Type:        FrameLogin,
+		Description: "登录，登录成功后回复同类型的帧；需要额外验证时回复 challenge_required",
+		Request:     func() interface{} { return &LoginRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			req := payload.(*LoginRequest)
+			var v fieldValidator
+			v.id("user_id", req.UserID)
+			v.maxLen("token", req.Token, maxFrameTokenLength)
+			v.maxLen("platform", req.Platform, maxFrameLabelLength)
+			v.maxLen("device_id", req.DeviceID, maxFrameLabelLength)
+			v.maxLen("device_token", req.DeviceToken, maxFrameTokenLength)
+			return v.errs
+		},
+		MaxSize:    16 << 10,
+		Response:   LoginResponse{},
+		Downstream: true,
+		Connection: true,
+	},
+	{
+		Type:        FrameHeartbeat,
+		Description: "心跳，回复服务器时间",
+		Request:     func() interface{} { return &HeartbeatRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			var v fieldValidator
+			v.maxLen("user_id", payload.(*HeartbeatRequest).UserID, maxFrameIDLength)
+			return v.errs
+		},
+		MaxSize:    256,
+		Response:   HeartbeatResponse{},
+		Downstream: true,
+		Connection: true,
+	},
+	{
+		Type:        FrameTimeSync,
+		Description: "时间同步，回复服务端收发时间（毫秒），由接入节点本地处理",
+		Request:     func() interface{} { return &TimeSyncRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			var v fieldValidator
+			v.nonNegative("client_time", payload.(*TimeSyncRequest).ClientTime)
+			return v.errs
+		},
+		MaxSize:    256,
+		Response:   TimeSyncResponse{},
+		Downstream: true,
+		Connection: true,
+	},
+	{
+		Type:        FrameSendMessage,
+		Description: "发送私聊、群聊或话题回复消息",
+		Request:     func() interface{} { return &SendMessageRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			req := payload.(*SendMessageRequest)
+			var v fieldValidator
+			if req.ReceiverID == "" && req.GroupID == "" && req.ThreadID == "" {
+				v.add("receiver_id", "receiver_id, group_id or thread_id is required")
+			}
+			v.maxLen("receiver_id", req.ReceiverID, maxFrameIDLength)
+			v.maxLen("group_id", req.GroupID, maxFrameIDLength)
+			v.maxLen("thread_id", req.ThreadID, maxFrameIDLength)
+			if req.Type == "" {
+				v.add("type", "is required")
+			}
+			v.oneOf("type", string(req.Type), userMessageTypes...)
+			v.oneOf("priority", req.Priority, string(MessagePriorityNormal), string(MessagePriorityHigh), string(MessagePriorityUrgent))
+			return v.errs
+		},
+		Response:   SendMessageResponse{},
+		Downstream: true,
+	},
+	{
+		Type:        FrameAck,
+		Description: "确认消息已送达或已读，同时释放流控窗口",
+		Request:     func() interface{} { return &AckRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			req := payload.(*AckRequest)
+			var v fieldValidator
+			v.id("message_id", req.MessageID)
+			if req.Status == "" {
+				v.add("status", "is required")
+			}
+			v.oneOf("status", req.Status, string(MessageStatusDelivered), string(MessageStatusRead))
+			return v.errs
+		},
+		MaxSize: 512,
+	},
+	{
+		Type:        FrameSyncOffline,
+		Description: "同步离线消息",
+		Request:     func() interface{} { return &SyncOfflineRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			req := payload.(*SyncOfflineRequest)
+			var v fieldValidator
+			v.maxLen("last_message_id", req.LastMessageID, maxFrameIDLength)
+			v.nonNegative("limit", int64(req.Limit))
+			return v.errs
+		},
+		MaxSize:    512,
+		Response:   SyncOfflineResponse{},
+		Downstream: true,
+	},
+	{
+		Type:        FrameSyncGap,
+		Description: "按会话序号补齐缺失的消息",
+		Request:     func() interface{} { return &SyncGapRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			req := payload.(*SyncGapRequest)
+			var v fieldValidator
+			v.required("conversation_id", req.ConversationID, maxFrameConversationIDLength)
+			v.nonNegative("last_seq", req.LastSeq)
+			v.nonNegative("limit", int64(req.Limit))
+			return v.errs
+		},
+		MaxSize:    512,
+		Response:   SyncGapResponse{},
+		Downstream: true,
+	},
+	{
+		Type:        FrameJoinGroup,
+		Description: "加入群聊",
+		Request:     func() interface{} { return &JoinGroupRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			var v fieldValidator
+			v.id("group_id", payload.(*JoinGroupRequest).GroupID)
+			return v.errs
+		},
+		MaxSize: 256,
+	},
+	{
+		Type:        FrameLeaveGroup,
+		Description: "离开群聊",
+		Request:     func() interface{} { return &LeaveGroupRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			var v fieldValidator
+			v.id("group_id", payload.(*LeaveGroupRequest).GroupID)
+			return v.errs
+		},
+		MaxSize: 256,
+	},
+	{
+		Type:        FrameRSVP,
+		Description: "答复群活动，回复最新的答复统计",
+		Request:     func() interface{} { return &RSVPRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			req := payload.(*RSVPRequest)
+			var v fieldValidator
+			v.id("message_id", req.MessageID)
+			if req.Response == "" {
+				v.add("response", "is required")
+			}
+			v.oneOf("response", req.Response, string(RSVPGoing), string(RSVPMaybe), string(RSVPDeclined))
+			return v.errs
+		},
+		MaxSize:    512,
+		Response:   GroupEventUpdatedEvent{},
+		Downstream: true,
+	},
+	{
+		Type:        FrameVote,
+		Description: "投票，回复最新的计票结果",
+		Request:     func() interface{} { return &VoteRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			req := payload.(*VoteRequest)
+			var v fieldValidator
+			v.id("message_id", req.MessageID)
+			for i, option := range req.Options {
+				v.nonNegative(fmt.Sprintf("options[%d]", i), int64(option))
+			}
+			return v.errs
+		},
+		MaxSize:    4 << 10,
+		Response:   PollUpdatedEvent{},
+		Downstream: true,
+	},
+	{
+		Type:        FrameClosePoll,
+		Description: "发起人提前结束投票，回复最终的计票结果",
+		Request:     func() interface{} { return &ClosePollRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			var v fieldValidator
+			v.id("message_id", payload.(*ClosePollRequest).MessageID)
+			return v.errs
+		},
+		MaxSize:    256,
+		Response:   PollUpdatedEvent{},
+		Downstream: true,
+	},
+	{
+		Type:        FrameVerifyChallenge,
+		Description: "提交登录验证的结果，验证通过后回复 login",
+		Request:     func() interface{} { return &VerifyChallengeRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			req := payload.(*VerifyChallengeRequest)
+			var v fieldValidator
+			v.id("challenge_id", req.ChallengeID)
+			v.maxLen("answer", req.Answer, maxFrameAnswerLength)
+			return v.errs
+		},
+		MaxSize: 8 << 10,
+	},
+	{
+		Type:        FrameSubscribePresence,
+		Description: "设置连接的在线状态关注列表，替换之前的列表，回复关注用户的当前状态；之后的状态变化以 presence 推送",
+		Request:     func() interface{} { return &PresenceWatchRequest{} },
+		Validate: func(payload interface{}) []FieldError {
+			var v fieldValidator
+			for i, userID := range payload.(*PresenceWatchRequest).UserIDs {
+				v.id(fmt.Sprintf("user_ids[%d]", i), userID)
+			}
+			return v.errs
+		},
+		MaxSize:    64 << 10,
+		Response:   PresenceWatchResponse{},
+		Downstream: true,
+	},
+	{
+		Type:        FrameError,
+		Description: "错误，负载包含 error，业务错误另有 code 和 retry_after",
+		Downstream:  true,
+	},
+	{
+		Type:        FrameNewMessage,
+		Description: "新私聊消息推送",
+		Response:    Message{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameNewGroupMessage,
+		Description: "新群聊消息推送",
+		Response:    Message{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameGroupDigest,
+		Description: "活跃群在摘要窗口内的新消息汇总，经推送提示通道发给不在线的成员，代替逐条的提示",
+		Response:    GroupDigest{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameMessageDeleted,
+		Description: "消息被删除或撤回",
+		Response:    MessageDeletedEvent{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameMessageEnriched,
+		Description: "链接预览或语音处理结果",
+		Response:    MessageEnrichedEvent{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameDraftUpdated,
+		Description: "会话草稿在其他设备上更新",
+		Response:    Draft{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameMessageRequest,
+		Description: "陌生人发来的消息请求",
+		Response:    MessageRequest{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameSettingsUpdated,
+		Description: "偏好设置在其他设备上更新",
+		Response:    UserSettings{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameEventUpdated,
+		Description: "群活动答复统计变化",
+		Response:    GroupEventUpdatedEvent{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameEventReminder,
+		Description: "群活动开始前的提醒",
+		Response:    GroupEventReminder{},
+		Downstream:  true,
+	},
+	{
+		Type:        FramePollUpdated,
+		Description: "投票结果变化",
+		Response:    PollUpdatedEvent{},
+		Downstream:  true,
+	},
+	{
+		Type:        FramePresence,
+		Description: "订阅的用户在线状态变化",
+		Response:    PresenceEvent{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameClientConfig,
+		Description: "客户端配置，登录后和变更时下发",
+		Response:    ClientConfig{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameChallengeRequired,
+		Description: "登录需要额外验证，客户端完成后发送 verify_challenge",
+		Response:    LoginChallenge{},
+		Downstream:  true,
+	},
+	{
+		Type:        FrameReconnect,
+		Description: "节点即将下线，客户端在 within 秒内随机选择时间重连到 reconnect_to",
+		Response:    ReconnectHint{},
+		Downstream:  true,
+	},
+}
+
+// userMessageTypes 用户可以发送的消息类型，系统消息只能由服务端发送
+var userMessageTypes = []string{
+	string(MessageTypeText), string(MessageTypeImage), string(MessageTypeFile), string(MessageTypeVoice), string(MessageTypeVideo),
+	string(MessageTypeSticker), string(MessageTypeMenu), string(MessageTypeEvent), string(MessageTypePoll),
+}
+
+// frameIndex 按类型索引的帧注册表
+var frameIndex = func() map[FrameType]FrameSpec {
+	index := make(map[FrameType]FrameSpec, len(frameSpecs))
+	for _, spec := range frameSpecs {
+		index[spec.Type] = spec
+	}
+	return index
+}()
+
+// LookupFrame 查找帧类型的注册信息
+func LookupFrame(frameType FrameType) (FrameSpec, bool) {
+	spec, ok := frameIndex[frameType]
+	return spec, ok
+}
+
+// FrameSpecs 返回所有注册的帧类型
+func FrameSpecs() []FrameSpec {
+	return append([]FrameSpec(nil), frameSpecs...)
+}
+
+// DecodeFrame 按帧类型解码并校验上行负载，返回指向负载结构的指针
+func DecodeFrame(frame *WebSocketMessage) (interface{}, error) {
+	spec, ok := LookupFrame(frame.Type)
+	if !ok {
+		return nil, ErrUnknownFrame
+	}
+	return spec.Decode(frame.Data)
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameRegistry(t *testing.T) {
+	seen := map[FrameType]bool{}
+	for _, spec := range FrameSpecs() {
+		assert.False(t, seen[spec.Type], "duplicate frame type %s", spec.Type)
+		seen[spec.Type] = true
+		assert.NotEmpty(t, spec.Description, spec.Type)
+		assert.True(t, spec.Request != nil || spec.Downstream, "frame %s has no direction", spec.Type)
+	}
+
+	payload, err := DecodeFrame(&WebSocketMessage{Type: FrameAck, Data: map[string]interface{}{"message_id": "m1", "status": "read"}})
+	assert.NoError(t, err)
+	assert.Equal(t, &AckRequest{MessageID: "m1", Status: "read"}, payload)
+
+	_, err = DecodeFrame(&WebSocketMessage{Type: FrameLogin, Data: map[string]interface{}{"token": "t"}})
+	assert.EqualError(t, err, "invalid login data: user_id is required")
+	_, err = DecodeFrame(&WebSocketMessage{Type: FrameSyncGap, Data: "not an object"})
+	assert.Error(t, err)
+	// 下行帧和未注册的帧不能解码
+	_, err = DecodeFrame(&WebSocketMessage{Type: FrameNewMessage})
+	assert.ErrorIs(t, err, ErrUnknownFrame)
+	_, err = DecodeFrame(&WebSocketMessage{Type: "unknown"})
+	assert.ErrorIs(t, err, ErrUnknownFrame)
+}
+
+func TestFrameValidation(t *testing.T) {
+	spec, _ := LookupFrame(FrameSendMessage)
+	_, err := spec.Decode(json.RawMessage(`{"receiver_id":"u2","type":"system","priority":"low"}`))
+	var verr *FrameValidationError
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, []string{"type", "priority"}, []string{verr.Fields[0].Field, verr.Fields[1].Field})
+
+	// 类型不符的字段定位到字段名
+	_, err = spec.Decode(json.RawMessage(`{"receiver_id":42,"type":"text"}`))
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, []FieldError{{Field: "receiver_id", Reason: "must be string"}}, verr.Fields)
+
+	data := InvalidFrameError(FrameSendMessage, err)
+	assert.Equal(t, "Invalid send_message data", data["error"])
+	assert.Equal(t, "invalid_request", data["code"])
+	assert.Equal(t, verr.Fields, data["fields"])
+}
+
+func TestFrameSizeLimit(t *testing.T) {
+	defer SetFrameSizeLimits(nil)
+	spec, _ := LookupFrame(FrameJoinGroup)
+	oversized := json.RawMessage(`{"group_id":"` + strings.Repeat("g", spec.MaxSize) + `"}`)
+	_, err := spec.Decode(oversized)
+	assert.EqualError(t, err, fmt.Sprintf("invalid join_group data: data must be at most %d bytes", spec.MaxSize))
+
+	SetFrameSizeLimits(map[FrameType]int{FrameJoinGroup: 20})
+	_, err = spec.Decode(json.RawMessage(`{"group_id":"group-1234567890"}`))
+	assert.EqualError(t, err, "invalid join_group data: data must be at most 20 bytes")
+	payload, err := spec.Decode(json.RawMessage(`{"group_id":"g1"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &JoinGroupRequest{GroupID: "g1"}, payload)
+}
+
+func TestMaxFramePayloadSize(t *testing.T) {
+	defer SetFrameSizeLimits(nil)
+	login, _ := LookupFrame(FrameLogin)
+	assert.GreaterOrEqual(t, MaxFramePayloadSize(), login.MaxSize)
+
+	SetFrameSizeLimits(map[FrameType]int{FrameJoinGroup: 1 << 20})
+	assert.Equal(t, 1<<20, MaxFramePayloadSize())
+}
+
+func TestProtocolDocUpToDate(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteProtocolDoc(&buf))
+	current, err := os.ReadFile("../../docs/api/protocol.md")
+	assert.NoError(t, err)
+	assert.Equal(t, buf.String(), string(current), "run go generate ./internal/model")
+}
@@ -0,0 +1,162 @@
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// WriteProtocolDoc 根据帧注册表生成WebSocket帧协议的Markdown文档
+func WriteProtocolDoc(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "# WebSocket 帧协议")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "> 本文件由 `go generate ./internal/model` 根据 `internal/model/frame.go` 中的帧注册表生成，请勿手工修改。")
+	fmt.Fprintln(b)
+	fmt.Fprintf(b, "协议版本：`%d`，登录响应的 `protocol_version` 为服务端的协议版本。", ProtocolVersion)
+	fmt.Fprintln(b, "删除帧类型或不兼容地修改负载时递增，新增帧类型和可选字段不递增。")
+	fmt.Fprintln(b, "帧的信封格式和编码见 [API文档](README.md#websocket-api)。")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "上行负载超出大小上限、字段类型不符或校验失败时回复 `error` 帧，`code` 为 `invalid_request`，")
+	fmt.Fprintln(b, "`fields` 列出不合法的字段和原因，格式见 [API文档](README.md#消息类型)。")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "| 帧类型 | 上行 | 下行 | 说明 |")
+	fmt.Fprintln(b, "|--------|------|------|------|")
+	for _, spec := range frameSpecs {
+		fmt.Fprintf(b, "| [`%s`](#%s) | %s | %s | %s |\n", spec.Type, spec.Type,
+			mark(spec.Request != nil), mark(spec.Downstream), spec.Description)
+	}
+
+	for _, spec := range frameSpecs {
+		fmt.Fprintln(b)
+		fmt.Fprintf(b, "## %s\n\n", spec.Type)
+		fmt.Fprintln(b, spec.Description+"。")
+		if spec.Connection {
+			fmt.Fprintln(b)
+			fmt.Fprintln(b, "连接级帧，由接入节点本地处理，不受功能开关控制。")
+		}
+		if spec.Request != nil {
+			fmt.Fprintln(b)
+			if spec.MaxSize > 0 {
+				fmt.Fprintf(b, "上行负载默认不超过 %d 字节，可通过 `server.frame_limits` 覆盖。\n", spec.MaxSize)
+			} else {
+				fmt.Fprintln(b, "上行负载只受 `server.max_message_size` 限制，可通过 `server.frame_limits` 设置上限。")
+			}
+			writePayloadDoc(b, "上行负载", reflect.TypeOf(spec.Request()))
+		}
+		if spec.Response != nil {
+			writePayloadDoc(b, "下行负载", reflect.TypeOf(spec.Response))
+		}
+	}
+	return b.Flush()
+}
+
+// mark 帧方向表格中的标记
+func mark(ok bool) string {
+	if ok {
+		return "✓"
+	}
+	return ""
+}
+
+// docField 负载结构中的一个JSON字段
+type docField struct {
+	name     string
+	typ      string
+	optional bool
+}
+
+// writePayloadDoc 输出负载结构的字段表
+func writePayloadDoc(b *bufio.Writer, title string, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	fmt.Fprintln(b)
+	fmt.Fprintf(b, "**%s** `%s`\n\n", title, t.Name())
+	fields := payloadFields(t)
+	if len(fields) == 0 {
+		fmt.Fprintln(b, "无字段。")
+		return
+	}
+	fmt.Fprintln(b, "| 字段 | 类型 | 可省略 |")
+	fmt.Fprintln(b, "|------|------|--------|")
+	for _, f := range fields {
+		fmt.Fprintf(b, "| `%s` | %s | %s |\n", f.name, f.typ, mark(f.optional))
+	}
+}
+
+// payloadFields 按encoding/json的规则列出结构体的字段，匿名嵌入的结构体字段展开
+func payloadFields(t reflect.Type) []docField {
+	var fields []docField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields = append(fields, payloadFields(embedded)...)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, docField{
+			name:     name,
+			typ:      jsonTypeName(field.Type),
+			optional: strings.Contains(opts, "omitempty"),
+		})
+	}
+	return fields
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// jsonTypeName 字段在JSON中的类型，结构体使用Go类型名
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return "string（RFC 3339）"
+	case t == rawMessageType:
+		return "any"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string（base64）"
+		}
+		return "array<" + jsonTypeName(t.Elem()) + ">"
+	case reflect.Map:
+		return "object<" + jsonTypeName(t.Elem()) + ">"
+	case reflect.Struct:
+		return "`" + t.Name() + "`"
+	default:
+		return "any"
+	}
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 上行负载的字段长度上限（字符）
+const (
+	maxFrameIDLength             = 64 // 用户、消息、群组等ID，与数据库列宽一致
+	maxFrameConversationIDLength = 128
+	maxFrameTokenLength          = 4096
+	maxFrameLabelLength          = 64 // 平台、设备标识等
+	maxFrameAnswerLength         = 4096
+)
+
+// frameErrorCodeInvalid 负载校验失败时错误帧的错误码，与service.ErrCodeInvalidRequest一致
+const frameErrorCodeInvalid = "invalid_request"
+
+// FieldError 上行负载中不合法的字段
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// FrameValidationError 上行负载校验失败，Fields列出全部不合法的字段
+type FrameValidationError struct {
+	Type   FrameType
+	Fields []FieldError
+}
+
+// Error 实现error接口
+func (e *FrameValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		reasons = append(reasons, f.Field+" "+f.Reason)
+	}
+	return fmt.Sprintf("invalid %s data: %s", e.Type, strings.Join(reasons, "; "))
+}
+
+// InvalidFrameError 上行负载解码或校验失败时错误帧的负载，校验失败时附带字段级的原因
+func InvalidFrameError(frameType FrameType, err error) map[string]interface{} {
+	data := map[string]interface{}{
+		"error": "Invalid " + string(frameType) + " data",
+		"code":  frameErrorCodeInvalid,
+	}
+	var verr *FrameValidationError
+	if errors.As(err, &verr) {
+		data["fields"] = verr.Fields
+	}
+	return data
+}
+
+// frameSizeLimits 按配置覆盖的上行负载大小上限
+var frameSizeLimits struct {
+	mu     sync.RWMutex
+	limits map[FrameType]int
+}
+
+// SetFrameSizeLimits 覆盖帧类型的负载大小上限（字节），未覆盖的类型使用注册表中的默认值，在进程启动时调用
+func SetFrameSizeLimits(limits map[FrameType]int) {
+	frameSizeLimits.mu.Lock()
+	defer frameSizeLimits.mu.Unlock()
+	frameSizeLimits.limits = limits
+}
+
+// MaxPayloadSize 帧类型的负载大小上限（字节），0表示只受连接的最大消息大小限制
+func (spec FrameSpec) MaxPayloadSize() int {
+	frameSizeLimits.mu.RLock()
+	defer frameSizeLimits.mu.RUnlock()
+	if limit, ok := frameSizeLimits.limits[spec.Type]; ok {
+		return limit
+	}
+	return spec.MaxSize
+}
+
+// MaxFramePayloadSize 所有帧类型中最大的负载大小上限（字节），连接未配置最大消息大小时据此设置读取上限
+func MaxFramePayloadSize() int {
+	largest := 0
+	for _, spec := range frameSpecs {
+		if size := spec.MaxPayloadSize(); size > largest {
+			largest = size
+		}
+	}
+	return largest
+}
+
+// decodeError 把JSON解码错误转换为字段级的校验错误，无法定位字段时原样返回
+func decodeError(frameType FrameType, err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &FrameValidationError{Type: frameType, Fields: []FieldError{
+			{Field: typeErr.Field, Reason: "must be " + jsonTypeName(typeErr.Type)},
+		}}
+	}
+	return fmt.Errorf("failed to decode %s data: %w", frameType, err)
+}
+
+// fieldValidator 收集上行负载中不合法的字段
+type fieldValidator struct {
+	errs []FieldError
+}
+
+// add 记录不合法的字段
+func (v *fieldValidator) add(field, reason string) {
+	v.errs = append(v.errs, FieldError{Field: field, Reason: reason})
+}
+
+// maxLen 字段不超过n个字符
+func (v *fieldValidator) maxLen(field, value string, n int) {
+	if utf8.RuneCountInString(value) > n {
+		v.add(field, fmt.Sprintf("must be at most %d characters", n))
+	}
+}
+
+// required 字段不为空且不超过n个字符
+func (v *fieldValidator) required(field, value string, n int) {
+	if value == "" {
+		v.add(field, "is required")
+		return
+	}
+	v.maxLen(field, value, n)
+}
+
+// id 必填的ID字段
+func (v *fieldValidator) id(field, value string) {
+	v.required(field, value, maxFrameIDLength)
+}
+
+// oneOf 字段为空或是允许的值之一
+func (v *fieldValidator) oneOf(field, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(field, "must be one of: "+strings.Join(allowed, ", "))
+}
+
+// nonNegative 数值字段不小于0
+func (v *fieldValidator) nonNegative(field string, value int64) {
+	if value < 0 {
+		v.add(field, "must not be negative")
+	}
+}
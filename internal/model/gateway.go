@@ -0,0 +1,22 @@
+package model
+
+import (
+	"encoding/json"
+)
+
+// GatewayFrame 网关转发给业务节点的客户端上行帧
+type GatewayFrame struct {
+	GatewayID string           `json:"gateway_id"`
+	SessionID string           `json:"session_id"`
+	UserID    string           `json:"user_id"`
+	Frame     WebSocketMessage `json:"frame"`
+}
+
+// GatewayPush 业务节点下发给网关的推送
+type GatewayPush struct {
+	ID        string          `json:"id,omitempty"` // 推送ID，网关据此去重
+	UserIDs   []string        `json:"user_ids"`
+	SessionID string          `json:"session_id,omitempty"` // 不为空时只推送给该会话，会话已断开时丢弃
+	Data      json.RawMessage `json:"data,omitempty"`       // 为空时只断开会话
+	Close     bool            `json:"close,omitempty"`      // 推送后断开用户会话
+}
@@ -0,0 +1,60 @@
+package model
+
+import "fmt"
+
+// GroupEventPayload 群活动消息的内容，成员通过rsvp帧报名，开始前按RemindBefore推送提醒
+type GroupEventPayload struct {
+	Title        string `json:"title"`
+	Location     string `json:"location,omitempty"`
+	StartsAt     int64  `json:"starts_at"`               // 开始时间（Unix秒）
+	EndsAt       int64  `json:"ends_at,omitempty"`       // 结束时间（Unix秒），可选
+	RemindBefore int64  `json:"remind_before,omitempty"` // 开始前多少秒提醒，0时使用服务端默认值，-1表示不提醒
+}
+
+// RSVPResponse 成员对群活动的答复
+type RSVPResponse string
+
+const (
+	RSVPGoing    RSVPResponse = "going"
+	RSVPMaybe    RSVPResponse = "maybe"
+	RSVPDeclined RSVPResponse = "declined"
+)
+
+// ParseRSVPResponse 解析活动答复
+func ParseRSVPResponse(s string) (RSVPResponse, error) {
+	switch r := RSVPResponse(s); r {
+	case RSVPGoing, RSVPMaybe, RSVPDeclined:
+		return r, nil
+	default:
+		return "", fmt.Errorf("invalid rsvp response: %s", s)
+	}
+}
+
+// RSVPCounts 群活动各答复的人数，读取消息时附加在活动消息上
+type RSVPCounts struct {
+	Going    int64 `json:"going"`
+	Maybe    int64 `json:"maybe"`
+	Declined int64 `json:"declined"`
+}
+
+// RSVPRequest rsvp帧的请求
+type RSVPRequest struct {
+	MessageID string `json:"message_id"`
+	Response  string `json:"response"`
+}
+
+// GroupEventUpdatedEvent 活动答复变化时推送给群成员的事件
+type GroupEventUpdatedEvent struct {
+	MessageID string     `json:"message_id"`
+	GroupID   string     `json:"group_id"`
+	RSVP      RSVPCounts `json:"rsvp"`
+}
+
+// GroupEventReminder 活动开始前推送给未拒绝的成员的提醒
+type GroupEventReminder struct {
+	MessageID string `json:"message_id"`
+	GroupID   string `json:"group_id"`
+	Title     string `json:"title"`
+	Location  string `json:"location,omitempty"`
+	StartsAt  int64  `json:"starts_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+// GuestToken 只读访客令牌，持有者可以在有效期内读取指定群组的历史消息和新消息，不能发送
+// 用于把会话分享给工单系统等外部人员，由管理接口签发和吊销
+type GuestToken struct {
+	ID        string `json:"id"`
+	GroupID   string `json:"group_id"`
+	Note      string `json:"note,omitempty"` // 签发原因，如工单号
+	CreatedBy string `json:"created_by"`     // 签发令牌的管理员
+	CreatedAt int64  `json:"created_at"`     // Unix秒
+	ExpiresAt int64  `json:"expires_at"`     // Unix秒，过期后令牌自动失效
+}
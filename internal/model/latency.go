@@ -0,0 +1,53 @@
+package model
+
+// DeliveryStage 投递阶段，每个阶段的耗时为该阶段时间与上一阶段时间之差
+type DeliveryStage string
+
+const (
+	// DeliveryStageStore 从接受发送请求到持久化
+	DeliveryStageStore DeliveryStage = "store"
+	// DeliveryStageQueue 从持久化到写入Kafka，只有经Kafka投递的离线消息和超大群消息有该阶段
+	DeliveryStageQueue DeliveryStage = "queue"
+	// DeliveryStagePush 从持久化或入队到推送给接收者的会话
+	DeliveryStagePush DeliveryStage = "push"
+	// DeliveryStageAck 从推送到客户端确认
+	DeliveryStageAck DeliveryStage = "ack"
+	// DeliveryStageTotal 从接受发送请求到客户端确认
+	DeliveryStageTotal DeliveryStage = "total"
+)
+
+// DeliveryStages 报告中的阶段顺序
+var DeliveryStages = []DeliveryStage{DeliveryStageStore, DeliveryStageQueue, DeliveryStagePush, DeliveryStageAck, DeliveryStageTotal}
+
+// LatencyBucketsMs 阶段耗时分桶的上界（毫秒），与Prometheus直方图的默认分桶一致，超过最后一个上界的计入inf
+var LatencyBucketsMs = []int64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// DeliveryTrace 采样消息在投递过程中各阶段的时间（Unix毫秒），未经过的阶段为0
+type DeliveryTrace struct {
+	SentAt   int64 `json:"sent_at"`
+	StoredAt int64 `json:"stored_at"`
+	QueuedAt int64 `json:"queued_at,omitempty"`
+	PushedAt int64 `json:"pushed_at,omitempty"`
+}
+
+// StageLatency 某阶段的耗时分布，分位数为所在分桶的上界，超过最大分桶时为-1
+type StageLatency struct {
+	Stage DeliveryStage `json:"stage"`
+	Count int64         `json:"count"`
+	P50Ms int64         `json:"p50_ms"`
+	P90Ms int64         `json:"p90_ms"`
+	P99Ms int64         `json:"p99_ms"`
+}
+
+// DeliverySLOReport 统计区间的投递耗时SLO报告
+type DeliverySLOReport struct {
+	From         string          `json:"from"`
+	To           string          `json:"to"`
+	TargetMs     int64           `json:"target_ms"`
+	Objective    float64         `json:"objective"`
+	Deliveries   int64           `json:"deliveries"`    // 采样并收到客户端确认的投递数
+	WithinTarget int64           `json:"within_target"` // 其中在目标耗时内完成的投递数
+	Compliance   float64         `json:"compliance"`    // 目标耗时内完成的比例，没有投递时为1
+	Met          bool            `json:"met"`
+	Stages       []*StageLatency `json:"stages"`
+}
@@ -0,0 +1,30 @@
+package model
+
+// MediaObject 分片上传完成的媒体文件，按引用它的未删除消息计数
+type MediaObject struct {
+	Key       string `json:"key"`
+	OwnerID   string `json:"owner_id"`
+	Size      int64  `json:"size"`
+	Refs      int64  `json:"refs"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// MediaConsumer 媒体存储用量，用户按上传的文件统计，群组按群内消息引用的文件统计
+type MediaConsumer struct {
+	ID    string `json:"id"`
+	Bytes int64  `json:"bytes"`
+}
+
+// MediaItem 会话媒体列表中的一条媒体消息
+// 文件名和类型从文件地址推断；大小只对登记过的分片上传文件提供
+type MediaItem struct {
+	MessageID   string      `json:"message_id"`
+	SenderID    string      `json:"sender_id"`
+	Type        MessageType `json:"type"`
+	URL         string      `json:"url"`
+	Filename    string      `json:"filename,omitempty"`
+	ContentType string      `json:"content_type,omitempty"`
+	Size        int64       `json:"size,omitempty"`
+	Seq         int64       `json:"seq"`
+	Timestamp   int64       `json:"timestamp"`
+}
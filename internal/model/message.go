@@ -1,6 +1,8 @@
 package model
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -14,8 +16,42 @@ const (
 	MessageTypeVoice  MessageType = "voice"
 	MessageTypeVideo  MessageType = "video"
 	MessageTypeSystem MessageType = "system"
+	// MessageTypeSticker 表情包消息，内容为StickerPayload的JSON
+	MessageTypeSticker MessageType = "sticker"
+	// MessageTypeMenu 菜单消息，内容为MenuPayload的JSON，只有公众号可以在私聊中发送
+	MessageTypeMenu MessageType = "menu"
+	// MessageTypeEvent 群活动消息，内容为GroupEventPayload的JSON，只能在群聊中发送
+	MessageTypeEvent MessageType = "event"
+	// MessageTypePoll 投票消息，内容为PollPayload的JSON，只能在群聊中发送
+	MessageTypePoll MessageType = "poll"
 )
 
+// IsMedia 判断是否为媒体消息
+func (t MessageType) IsMedia() bool {
+	switch t {
+	case MessageTypeImage, MessageTypeFile, MessageTypeVoice, MessageTypeVideo:
+		return true
+	}
+	return false
+}
+
+// ParseMessageTypes 解析逗号分隔的消息类型列表，为空时返回nil
+func ParseMessageTypes(s string) ([]MessageType, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var types []MessageType
+	for _, raw := range strings.Split(s, ",") {
+		switch t := MessageType(strings.TrimSpace(raw)); t {
+		case MessageTypeText, MessageTypeImage, MessageTypeFile, MessageTypeVoice, MessageTypeVideo, MessageTypeSystem, MessageTypeSticker, MessageTypeMenu, MessageTypeEvent, MessageTypePoll:
+			types = append(types, t)
+		default:
+			return nil, fmt.Errorf("invalid message type: %s", raw)
+		}
+	}
+	return types, nil
+}
+
 // MessageStatus 消息状态
 type MessageStatus string
 
@@ -26,18 +62,149 @@ const (
 	MessageStatusFailed    MessageStatus = "failed"
 )
 
+// MessagePriority 消息优先级
+type MessagePriority string
+
+const (
+	MessagePriorityNormal MessagePriority = "normal"
+	MessagePriorityHigh   MessagePriority = "high"
+	// MessagePriorityUrgent 紧急消息不受群内禁言限制，离线时优先投递，推送时提示客户端忽略免打扰
+	MessagePriorityUrgent MessagePriority = "urgent"
+)
+
+// ParseMessagePriority 解析消息优先级，空字符串视为normal
+func ParseMessagePriority(s string) (MessagePriority, error) {
+	switch p := MessagePriority(s); p {
+	case "":
+		return MessagePriorityNormal, nil
+	case MessagePriorityNormal, MessagePriorityHigh, MessagePriorityUrgent:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid message priority: %s", s)
+	}
+}
+
 // Message 消息模型
 type Message struct {
-	ID         string        `json:"id" gorm:"primaryKey;type:varchar(64)"`
-	SenderID   string        `json:"sender_id" gorm:"type:varchar(64);index"`
-	ReceiverID string        `json:"receiver_id" gorm:"type:varchar(64);index"`
-	GroupID    string        `json:"group_id" gorm:"type:varchar(64);index"`
-	Type       MessageType   `json:"type" gorm:"type:varchar(20)"`
-	Content    string        `json:"content" gorm:"type:text"`
-	Status     MessageStatus `json:"status" gorm:"type:varchar(20);default:'sent'"`
-	Timestamp  int64         `json:"timestamp" gorm:"index"`
-	CreatedAt  time.Time     `json:"created_at"`
-	UpdatedAt  time.Time     `json:"updated_at"`
+	ID          string          `json:"id" gorm:"primaryKey;type:varchar(64)"`
+	SenderID    string          `json:"sender_id" gorm:"type:varchar(64);index"`
+	ReceiverID  string          `json:"receiver_id" gorm:"type:varchar(64);index"`
+	GroupID     string          `json:"group_id" gorm:"type:varchar(64);index"`
+	Type        MessageType     `json:"type" gorm:"type:varchar(20)"`
+	Content     string          `json:"content" gorm:"type:text"`
+	Status      MessageStatus   `json:"status" gorm:"type:varchar(20);default:'sent'"`
+	Timestamp   int64           `json:"timestamp" gorm:"index"`
+	Priority    MessagePriority `json:"priority,omitempty" gorm:"type:varchar(10);default:'normal'"`
+	Seq         int64           `json:"seq,omitempty" gorm:"default:0"`                     // 会话内递增的序号，客户端据此发现缺失的消息
+	DeletedAt   int64           `json:"deleted_at,omitempty" gorm:"default:0"`              // 发送者对所有人删除的时间（Unix秒），非0时消息为墓碑
+	Preview     *LinkPreview    `json:"preview,omitempty" gorm:"type:json;serializer:json"` // 异步抓取的链接预览
+	System      *SystemPayload  `json:"system,omitempty" gorm:"type:json;serializer:json"`  // 系统消息的结构化事件，Content为按接收者语言渲染的文本
+	Voice       *VoiceMetadata  `json:"voice,omitempty" gorm:"type:json;serializer:json"`   // 语音消息异步提取的时长和波形
+	ThreadID    string          `json:"thread_id,omitempty" gorm:"type:varchar(64)"`        // 话题回复所属的根消息ID
+	ReplyCount  int64           `json:"reply_count,omitempty" gorm:"default:0"`             // 根消息的话题回复数，不含已对所有人删除的回复
+	LastReplyAt int64           `json:"last_reply_at,omitempty" gorm:"default:0"`           // 根消息最近一条话题回复的时间（Unix秒）
+	Trace       *DeliveryTrace  `json:"trace,omitempty" gorm:"-"`                           // 采样消息投递过程中的阶段时间，不持久化
+	RSVP        *RSVPCounts     `json:"rsvp,omitempty" gorm:"-"`                            // 群活动消息的答复人数，读取时从Redis附加
+	Poll        *PollTally      `json:"poll,omitempty" gorm:"-"`                            // 投票消息的结果，读取时从Redis附加
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// VoiceMetadata 语音消息的时长和波形，客户端无需下载音频即可绘制语音气泡
+type VoiceMetadata struct {
+	DurationMs int64 `json:"duration_ms"`
+	Waveform   []int `json:"waveform,omitempty"` // 降采样后的振幅，0-100；无法解码的格式只有时长
+}
+
+// LinkPreview 消息中链接的OpenGraph预览
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// SystemSenderID 系统消息的发送者ID
+const SystemSenderID = "system"
+
+// SystemEvent 系统消息事件类型，同时作为多语言目录中的模板键
+type SystemEvent string
+
+const (
+	SystemEventGroupCreated    SystemEvent = "group_created"    // 参数: owner, name
+	SystemEventMemberJoined    SystemEvent = "member_joined"    // 参数: user
+	SystemEventMemberLeft      SystemEvent = "member_left"      // 参数: user
+	SystemEventMemberMuted     SystemEvent = "member_muted"     // 参数: operator, user, minutes
+	SystemEventMemberUnmuted   SystemEvent = "member_unmuted"   // 参数: operator, user
+	SystemEventGroupUpgraded   SystemEvent = "group_upgraded"   // 参数: operator
+	SystemEventMessageRecalled SystemEvent = "message_recalled" // 参数: user, message_id
+)
+
+// SystemPayload 系统消息的事件类型及参数，客户端可据此自行渲染
+type SystemPayload struct {
+	Event  SystemEvent       `json:"event"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// IsSystem 判断是否为系统消息
+func (m *Message) IsSystem() bool {
+	return m.System != nil
+}
+
+// IsUrgent 判断是否为紧急消息
+func (m *Message) IsUrgent() bool {
+	return m.Priority == MessagePriorityUrgent
+}
+
+// IsThreadReply 判断是否为话题回复
+func (m *Message) IsThreadReply() bool {
+	return m.ThreadID != ""
+}
+
+// ThreadSummary 根消息的话题回复统计
+type ThreadSummary struct {
+	ReplyCount  int64 `json:"reply_count"`
+	LastReplyAt int64 `json:"last_reply_at"`
+}
+
+// PushOptions 按优先级生成推送提示，普通消息返回nil
+func (m *Message) PushOptions() *PushOptions {
+	switch m.Priority {
+	case MessagePriorityHigh:
+		return &PushOptions{Priority: m.Priority, Sound: PushSoundHigh}
+	case MessagePriorityUrgent:
+		return &PushOptions{Priority: m.Priority, Sound: PushSoundUrgent, BypassMute: true}
+	}
+	return nil
+}
+
+// QuietPushOptions 接收者处于免打扰时段时的推送提示，紧急消息照常提醒，其余消息静音
+func (m *Message) QuietPushOptions() *PushOptions {
+	if m.Priority == MessagePriorityUrgent {
+		return m.PushOptions()
+	}
+	priority := m.Priority
+	if priority == "" {
+		priority = MessagePriorityNormal
+	}
+	return &PushOptions{Priority: priority, Silent: true}
+}
+
+// CollapseKey 从接收者视角标识消息所在会话，用作通知的合并键
+func (m *Message) CollapseKey(userID string) string {
+	if m.IsGroupMessage() {
+		return ConversationID(ConversationTypeGroup, m.GroupID)
+	}
+	if userID == m.SenderID {
+		return ConversationID(ConversationTypePrivate, m.ReceiverID)
+	}
+	return ConversationID(ConversationTypePrivate, m.SenderID)
+}
+
+// IsDeleted 判断消息是否已被发送者对所有人删除
+func (m *Message) IsDeleted() bool {
+	return m.DeletedAt > 0
 }
 
 // IsGroupMessage 判断是否为群聊消息
@@ -50,26 +217,159 @@ func (m *Message) IsPrivateMessage() bool {
 	return m.GroupID == ""
 }
 
+// DeleteScope 消息删除范围
+type DeleteScope string
+
+const (
+	// DeleteScopeMe 仅对自己删除，其他人仍可见
+	DeleteScopeMe DeleteScope = "me"
+	// DeleteScopeEveryone 发送者对所有人删除，消息保留为墓碑
+	DeleteScopeEveryone DeleteScope = "everyone"
+)
+
+// MessageDeletion 用户对自己删除的消息记录
+type MessageDeletion struct {
+	MessageID string    `json:"message_id" gorm:"primaryKey;type:varchar(64)"`
+	UserID    string    `json:"user_id" gorm:"primaryKey;type:varchar(64)"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MessageReceipt 接收者对消息的投递和已读回执
+type MessageReceipt struct {
+	MessageID   string        `json:"message_id" gorm:"primaryKey;type:varchar(64)"`
+	UserID      string        `json:"user_id" gorm:"primaryKey;type:varchar(64)"`
+	Status      MessageStatus `json:"status" gorm:"-"`                         // 由时间戳推导：sent、delivered或read
+	DeliveredAt int64         `json:"delivered_at,omitempty" gorm:"default:0"` // 首次投递时间（Unix秒）
+	ReadAt      int64         `json:"read_at,omitempty" gorm:"default:0"`      // 首次已读时间（Unix秒）
+}
+
+// Apply 合并一次确认，只记录各状态的首次时间，已读同时视为已投递
+func (r *MessageReceipt) Apply(status MessageStatus, at int64) {
+	if r.DeliveredAt == 0 {
+		r.DeliveredAt = at
+	}
+	if status == MessageStatusRead && r.ReadAt == 0 {
+		r.ReadAt = at
+	}
+}
+
+// ResolveStatus 根据时间戳计算回执状态
+func (r *MessageReceipt) ResolveStatus() MessageStatus {
+	switch {
+	case r.ReadAt > 0:
+		r.Status = MessageStatusRead
+	case r.DeliveredAt > 0:
+		r.Status = MessageStatusDelivered
+	default:
+		r.Status = MessageStatusSent
+	}
+	return r.Status
+}
+
+// ReceiptMode 群消息回执的记录粒度
+type ReceiptMode string
+
+const (
+	// ReceiptsNone 不记录回执
+	ReceiptsNone ReceiptMode = "none"
+	// ReceiptsAggregate 只记录已投递和已读人数（近似值），存储与成员数无关
+	ReceiptsAggregate ReceiptMode = "aggregate"
+	// ReceiptsFull 按成员记录回执
+	ReceiptsFull ReceiptMode = "full"
+)
+
+// ReceiptReport 发送者查看的消息投递报告
+type ReceiptReport struct {
+	MessageID string            `json:"message_id"`
+	Mode      ReceiptMode       `json:"mode"`      // none时不统计，aggregate时只有人数
+	Total     int               `json:"total"`     // 接收者总数
+	Delivered int               `json:"delivered"` // 已投递（含已读）的接收者数
+	Read      int               `json:"read"`      // 已读的接收者数
+	Receipts  []*MessageReceipt `json:"receipts"`
+}
+
+// MessageDeletedEvent 消息删除通知
+type MessageDeletedEvent struct {
+	MessageID  string      `json:"message_id"`
+	GroupID    string      `json:"group_id,omitempty"`
+	ReceiverID string      `json:"receiver_id,omitempty"`
+	Scope      DeleteScope `json:"scope"`
+	DeletedBy  string      `json:"deleted_by"`
+	DeletedAt  int64       `json:"deleted_at"`
+}
+
+// MessageEnrichedEvent 消息链接预览抓取或语音处理完成通知
+type MessageEnrichedEvent struct {
+	MessageID  string         `json:"message_id"`
+	GroupID    string         `json:"group_id,omitempty"`
+	ReceiverID string         `json:"receiver_id,omitempty"`
+	Preview    *LinkPreview   `json:"preview,omitempty"`
+	Voice      *VoiceMetadata `json:"voice,omitempty"`
+}
+
+// 推送提示音
+const (
+	PushSoundHigh   = "high"
+	PushSoundUrgent = "urgent"
+)
+
+// PushOptions 消息推送提示，客户端据此选择提醒方式，缺省时按普通消息提醒
+type PushOptions struct {
+	Priority    MessagePriority `json:"priority"`
+	Sound       string          `json:"sound,omitempty"`
+	BypassMute  bool            `json:"bypass_mute,omitempty"`  // 忽略接收者对会话的免打扰设置
+	Silent      bool            `json:"silent,omitempty"`       // 接收者处于免打扰时段，客户端只更新界面不发出提醒
+	CollapseKey string          `json:"collapse_key,omitempty"` // 接收者视角的会话ID，同一会话的通知合并为一条
+	Title       string          `json:"title,omitempty"`        // 按消息类型和接收者语言渲染的通知标题
+	Body        string          `json:"body,omitempty"`         // 按消息类型和接收者语言渲染的通知正文
+	Badge       int64           `json:"badge,omitempty"`        // 接收者所有私聊会话的未读总数
+}
+
 // WebSocketMessage WebSocket消息格式
 type WebSocketMessage struct {
-	Type      string      `json:"type"`
-	Data      interface{} `json:"data"`
-	Timestamp int64       `json:"timestamp"`
-	MessageID string      `json:"message_id,omitempty"`
+	Type      FrameType    `json:"type"`
+	Data      interface{}  `json:"data"`
+	Timestamp int64        `json:"timestamp"`
+	MessageID string       `json:"message_id,omitempty"`
+	Push      *PushOptions `json:"push,omitempty"` // 新消息推送的提醒方式
+}
+
+// NewMessageFrame 构造新消息推送帧，按消息优先级附带推送提示
+func NewMessageFrame(frameType FrameType, message *Message) WebSocketMessage {
+	return WebSocketMessage{
+		Type:      frameType,
+		Data:      message,
+		Timestamp: time.Now().Unix(),
+		MessageID: message.ID,
+		Push:      message.PushOptions(),
+	}
+}
+
+// NewQuietMessageFrame 构造发给处于免打扰时段的接收者的新消息推送帧
+func NewQuietMessageFrame(frameType FrameType, message *Message) WebSocketMessage {
+	frame := NewMessageFrame(frameType, message)
+	frame.Push = message.QuietPushOptions()
+	return frame
 }
 
 // LoginRequest 登录请求
 type LoginRequest struct {
-	UserID   string `json:"user_id"`
-	Token    string `json:"token"`
-	Platform string `json:"platform"`
+	UserID       string              `json:"user_id"`
+	Token        string              `json:"token"`
+	Platform     string              `json:"platform"`
+	Capabilities *ClientCapabilities `json:"capabilities,omitempty"`
+	DeviceID     string              `json:"device_id,omitempty"`    // 客户端生成并持久保存的设备标识
+	DeviceToken  string              `json:"device_token,omitempty"` // 通过登录验证后下发的设备信任令牌
 }
 
 // LoginResponse 登录响应
 type LoginResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	UserID  string `json:"user_id"`
+	Success         bool   `json:"success"`
+	Message         string `json:"message"`
+	UserID          string `json:"user_id"`
+	DeviceToken     string `json:"device_token,omitempty"` // 通过登录验证后下发，之后该设备登录时免验证
+	AckWindow       int    `json:"ack_window,omitempty"`   // 最多未确认的新消息数，客户端声明supports_ack_window且服务端启用时下发
+	ProtocolVersion int    `json:"protocol_version"`       // 服务端的帧协议版本
 }
 
 // SendMessageRequest 发送消息请求
@@ -78,13 +378,17 @@ type SendMessageRequest struct {
 	GroupID    string      `json:"group_id,omitempty"`
 	Type       MessageType `json:"type"`
 	Content    string      `json:"content"`
+	Priority   string      `json:"priority,omitempty"`
+	ThreadID   string      `json:"thread_id,omitempty"` // 回复话题时为根消息ID，会话由根消息决定
 }
 
 // SendMessageResponse 发送消息响应
 type SendMessageResponse struct {
-	Success   bool     `json:"success"`
-	MessageID string   `json:"message_id"`
-	Message   *Message `json:"message"`
+	Success         bool     `json:"success"`
+	MessageID       string   `json:"message_id"`
+	Message         *Message `json:"message"`
+	ClientTimestamp int64    `json:"client_timestamp,omitempty"` // 请求帧中客户端填写的时间戳，原样返回
+	ServerTimestamp int64    `json:"server_timestamp"`           // 服务端接收时间（Unix秒），即消息的timestamp，排序以此为准
 }
 
 // AckRequest 消息确认请求
@@ -99,6 +403,21 @@ type SyncOfflineRequest struct {
 	Limit         int    `json:"limit"`
 }
 
+// SyncGapRequest 补齐会话中缺失消息的请求
+type SyncGapRequest struct {
+	ConversationID string `json:"conversation_id"` // 请求者视角的会话ID
+	LastSeq        int64  `json:"last_seq"`        // 客户端连续收到的最后一个序号
+	Limit          int    `json:"limit"`
+}
+
+// SyncGapResponse 补齐缺失消息的响应
+type SyncGapResponse struct {
+	ConversationID string     `json:"conversation_id"`
+	Messages       []*Message `json:"messages"`
+	LastSeq        int64      `json:"last_seq"` // 本次已覆盖到的序号，请求者已删除的消息不返回但计入覆盖范围
+	HasMore        bool       `json:"has_more"`
+}
+
 // SyncOfflineResponse 同步离线消息响应
 type SyncOfflineResponse struct {
 	Messages []*Message `json:"messages"`
@@ -115,6 +434,18 @@ type HeartbeatResponse struct {
 	Timestamp int64 `json:"timestamp"`
 }
 
+// TimeSyncRequest 时间同步请求
+type TimeSyncRequest struct {
+	ClientTime int64 `json:"client_time"` // 客户端发送时间（Unix毫秒）
+}
+
+// TimeSyncResponse 时间同步响应，客户端结合收到响应的时间估算时钟偏差和往返时延
+type TimeSyncResponse struct {
+	ClientTime  int64 `json:"client_time"`  // 请求中的客户端发送时间，原样返回
+	ReceiveTime int64 `json:"receive_time"` // 服务端收到请求的时间（Unix毫秒）
+	SendTime    int64 `json:"send_time"`    // 服务端发出响应的时间（Unix毫秒）
+}
+
 // JoinGroupRequest 加入群聊请求
 type JoinGroupRequest struct {
 	GroupID string `json:"group_id"`
@@ -140,22 +471,118 @@ type UserStatus struct {
 	ConnID   string    `json:"conn_id"`
 }
 
+// GroupMode 群组模式
+type GroupMode string
+
+const (
+	// GroupModeNormal 普通群，消息直接广播给在线成员
+	GroupModeNormal GroupMode = "normal"
+	// GroupModeChannel 超大群（频道），消息只经Kafka扇出，成员按读游标拉取
+	GroupModeChannel GroupMode = "channel"
+)
+
 // Group 群组模型
 type Group struct {
-	ID          string    `json:"id" gorm:"primaryKey;type:varchar(64)"`
-	Name        string    `json:"name" gorm:"type:varchar(100)"`
-	Description string    `json:"description" gorm:"type:text"`
-	OwnerID     string    `json:"owner_id" gorm:"type:varchar(64)"`
-	Members     []string  `json:"members" gorm:"type:json"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          string        `json:"id" gorm:"primaryKey;type:varchar(64)"`
+	Name        string        `json:"name" gorm:"type:varchar(100)"`
+	Description string        `json:"description" gorm:"type:text"`
+	OwnerID     string        `json:"owner_id" gorm:"type:varchar(64)"`
+	Mode        GroupMode     `json:"mode" gorm:"type:varchar(20);default:'normal'"`
+	Members     []string      `json:"members" gorm:"type:json;serializer:json"`
+	MemberCount int64         `json:"member_count" gorm:"-"`
+	Settings    GroupSettings `json:"settings" gorm:"embedded;embeddedPrefix:settings_"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// PostPolicy 群组发言权限
+type PostPolicy string
+
+const (
+	// PostPolicyAll 所有成员可发言
+	PostPolicyAll PostPolicy = "all"
+	// PostPolicyAdmins 仅群主和管理员可发言（只读频道）
+	PostPolicyAdmins PostPolicy = "admins"
+)
+
+// WordFilterLevel 敏感词过滤级别
+type WordFilterLevel string
+
+const (
+	// WordFilterStandard 使用标准词库
+	WordFilterStandard WordFilterLevel = "standard"
+	// WordFilterStrict 同时使用标准词库和严格词库
+	WordFilterStrict WordFilterLevel = "strict"
+)
+
+// GroupSettings 群组发言设置，群主和管理员不受慢速模式和内容限制约束，敏感词过滤对所有成员生效
+type GroupSettings struct {
+	PostPolicy PostPolicy      `json:"post_policy" gorm:"type:varchar(20);default:'all'"`
+	SlowMode   int             `json:"slow_mode" gorm:"default:0"` // 成员两次发言的最小间隔（秒），0表示关闭
+	BlockLinks bool            `json:"block_links" gorm:"default:false"`
+	BlockMedia bool            `json:"block_media" gorm:"default:false"`
+	WordFilter WordFilterLevel `json:"word_filter" gorm:"type:varchar(20);default:''"` // 为空时超大群使用严格级别，其他群组使用标准级别
+	Receipts   ReceiptMode     `json:"receipts" gorm:"type:varchar(20);default:''"`    // 为空时按服务端配置，超大群不能使用full
+}
+
+// WordFilterLevel 群组生效的敏感词过滤级别
+func (g *Group) WordFilterLevel() WordFilterLevel {
+	if g.Settings.WordFilter != "" {
+		return g.Settings.WordFilter
+	}
+	if g.IsChannel() {
+		return WordFilterStrict
+	}
+	return WordFilterStandard
+}
+
+// IsChannel 判断是否为超大群
+func (g *Group) IsChannel() bool {
+	return g.Mode == GroupModeChannel
 }
 
 // GroupMember 群组成员
 type GroupMember struct {
-	ID       string    `json:"id" gorm:"primaryKey;type:varchar(64)"`
-	GroupID  string    `json:"group_id" gorm:"type:varchar(64);index"`
-	UserID   string    `json:"user_id" gorm:"type:varchar(64);index"`
-	Role     string    `json:"role" gorm:"type:varchar(20)"` // owner, admin, member
-	JoinedAt time.Time `json:"joined_at"`
+	ID         string    `json:"id" gorm:"primaryKey;type:varchar(64)"`
+	GroupID    string    `json:"group_id" gorm:"type:varchar(64);index"`
+	UserID     string    `json:"user_id" gorm:"type:varchar(64);index"`
+	Role       string    `json:"role" gorm:"type:varchar(20)"` // owner, admin, member
+	Nickname   string    `json:"nickname" gorm:"type:varchar(100)"`
+	MutedUntil int64     `json:"muted_until" gorm:"default:0"` // 禁言截止时间（Unix秒），0表示未禁言
+	JoinedAt   time.Time `json:"joined_at"`
+}
+
+// IsAdmin 判断成员是否为群主或管理员
+func (m *GroupMember) IsAdmin() bool {
+	return m.Role == "owner" || m.Role == "admin"
+}
+
+// IsMuted 判断成员当前是否被禁言
+func (m *GroupMember) IsMuted(now time.Time) bool {
+	return m.MutedUntil > now.Unix()
+}
+
+// SanctionType 用户全局处罚类型
+type SanctionType string
+
+const (
+	// SanctionMute 全局禁言，不能发送任何消息
+	SanctionMute SanctionType = "mute"
+	// SanctionBan 封禁，不能发送消息且会被断开连接
+	SanctionBan SanctionType = "ban"
+)
+
+// UserSanction 管理员对用户的全局处罚
+type UserSanction struct {
+	ID        string       `json:"id" gorm:"primaryKey;type:varchar(64)"`
+	UserID    string       `json:"user_id" gorm:"type:varchar(64);uniqueIndex:idx_user_sanction"`
+	Type      SanctionType `json:"type" gorm:"type:varchar(20);uniqueIndex:idx_user_sanction"`
+	Reason    string       `json:"reason" gorm:"type:varchar(255)"`
+	Until     int64        `json:"until" gorm:"index"` // 截止时间（Unix秒），0表示永久
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// IsActive 判断处罚当前是否生效
+func (s *UserSanction) IsActive(now time.Time) bool {
+	return s.Until == 0 || s.Until > now.Unix()
 }
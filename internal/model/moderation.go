@@ -0,0 +1,19 @@
+package model
+
+// ModerationEvent 内容治理事件，发布到治理主题供审核系统消费
+type ModerationEvent struct {
+	Type      string `json:"type"` // spam, unsafe_link
+	Rule      string `json:"rule"`
+	UserID    string `json:"user_id"`
+	Count     int64  `json:"count"`
+	Until     int64  `json:"until"` // 发送限制截止时间（Unix秒）
+	Sample    string `json:"sample,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// LinkVerdict 链接安全检查结果
+type LinkVerdict struct {
+	Safe     bool   `json:"safe"`
+	Source   string `json:"source"`             // blocklist或reputation
+	Category string `json:"category,omitempty"` // 信誉服务给出的类别，如phishing、malware
+}
@@ -0,0 +1,46 @@
+package model
+
+import "fmt"
+
+// DigestMode 活跃群新消息的摘要方式
+type DigestMode string
+
+const (
+	// DigestAuto 群在窗口内的消息数超过阈值后改为摘要，默认方式
+	DigestAuto DigestMode = "auto"
+	// DigestAlways 所有群的普通消息都按窗口合并为摘要
+	DigestAlways DigestMode = "always"
+	// DigestOff 逐条推送
+	DigestOff DigestMode = "off"
+)
+
+// ParseDigestMode 解析摘要方式，空字符串视为auto
+func ParseDigestMode(s string) (DigestMode, error) {
+	switch m := DigestMode(s); m {
+	case "":
+		return DigestAuto, nil
+	case DigestAuto, DigestAlways, DigestOff:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid digest mode %q, must be auto, always or off", s)
+}
+
+// NotificationPreferences 用户的通知偏好
+type NotificationPreferences struct {
+	GroupDigest DigestMode `json:"group_digest"`
+	UpdatedAt   int64      `json:"updated_at"`
+}
+
+// DefaultNotificationPreferences 未设置时的通知偏好
+func DefaultNotificationPreferences() *NotificationPreferences {
+	return &NotificationPreferences{GroupDigest: DigestAuto}
+}
+
+// GroupDigest 摘要窗口内用户未逐条收到的群消息汇总
+type GroupDigest struct {
+	GroupID        string   `json:"group_id"`
+	Count          int      `json:"count"`            // 窗口内合并的消息数
+	FirstMessageID string   `json:"first_message_id"` // 客户端从这条消息开始拉取历史
+	LastMessage    *Message `json:"last_message"`
+	Since          int64    `json:"since"` // 第一条合并消息的时间（Unix秒）
+}
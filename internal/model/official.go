@@ -0,0 +1,34 @@
+package model
+
+// OfficialAccount 公众号，以普通用户ID收发私聊消息，由管理接口维护
+// 用户关注后公众号可以向其群发消息；Pinned的公众号在所有用户的会话列表中固定置顶，用户不能取消
+type OfficialAccount struct {
+	ID          string     `json:"id"` // 公众号的用户ID
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	AvatarURL   string     `json:"avatar_url,omitempty"`
+	Pinned      bool       `json:"pinned"`
+	Menu        []MenuItem `json:"menu,omitempty"` // 会话底部的常驻菜单
+	UpdatedAt   int64      `json:"updated_at"`
+}
+
+// MenuItem 菜单项，设置了url的菜单项点击后打开链接，设置了key的菜单项点击后客户端把key作为文本消息发给公众号；
+// 带子菜单的菜单项只用于展开
+type MenuItem struct {
+	Label string     `json:"label"`
+	Key   string     `json:"key,omitempty"`
+	URL   string     `json:"url,omitempty"`
+	Items []MenuItem `json:"items,omitempty"` // 子菜单，只支持一级
+}
+
+// MenuPayload 菜单消息的内容，文本下方显示一组菜单项
+type MenuPayload struct {
+	Text  string     `json:"text"`
+	Items []MenuItem `json:"items"`
+}
+
+// OfficialAccountView 用户看到的公众号，附带关注状态
+type OfficialAccountView struct {
+	*OfficialAccount
+	Following bool `json:"following"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Department 组织架构中的部门，ID沿用企业目录中的部门ID
+type Department struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:varchar(64)"`
+	ParentID  string    `json:"parent_id" gorm:"type:varchar(64);index"` // 根部门为空
+	Name      string    `json:"name" gorm:"type:varchar(100)"`
+	ManagerID string    `json:"manager_id,omitempty" gorm:"type:varchar(64)"`
+	SortOrder int       `json:"sort_order" gorm:"default:0"`                // 同级部门按该值升序排列
+	GroupID   string    `json:"group_id,omitempty" gorm:"type:varchar(64)"` // 自动维护的部门群，由服务端写入，同步目录时忽略
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DepartmentMember 部门成员，一个用户可以属于多个部门
+type DepartmentMember struct {
+	DepartmentID string `json:"department_id" gorm:"primaryKey;type:varchar(64)"`
+	UserID       string `json:"user_id" gorm:"primaryKey;type:varchar(64);index"`
+	Title        string `json:"title,omitempty" gorm:"type:varchar(100)"` // 职务
+}
+
+// DepartmentNode 通讯录树中的部门
+type DepartmentNode struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	ManagerID   string            `json:"manager_id,omitempty"`
+	GroupID     string            `json:"group_id,omitempty"`
+	MemberCount int               `json:"member_count"` // 直属成员数，不含子部门
+	Children    []*DepartmentNode `json:"children,omitempty"`
+}
+
+// OrgSnapshot 企业目录推送的完整组织架构，同步时整体替换
+type OrgSnapshot struct {
+	Departments []*Department       `json:"departments"`
+	Members     []*DepartmentMember `json:"members"`
+}
+
+// OrgSyncResult 组织架构同步结果
+type OrgSyncResult struct {
+	Departments    int      `json:"departments"`
+	Members        int      `json:"members"`
+	Removed        int      `json:"removed_departments"` // 目录中已不存在而删除的部门
+	GroupsCreated  int      `json:"groups_created"`
+	MembersAdded   int      `json:"members_added"`    // 加入部门群的成员数
+	MembersRemoved int      `json:"members_removed"`  // 移出部门群的成员数
+	Errors         []string `json:"errors,omitempty"` // 部门群同步中的错误，不影响目录本身的同步
+}
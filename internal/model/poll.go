@@ -0,0 +1,46 @@
+package model
+
+// PollPayload 投票消息的内容，成员通过vote帧投票，发起者可以通过close_poll帧提前结束
+type PollPayload struct {
+	Question  string   `json:"question"`
+	Options   []string `json:"options"`
+	Multiple  bool     `json:"multiple,omitempty"`  // 是否允许多选
+	Anonymous bool     `json:"anonymous,omitempty"` // 匿名投票不公开投票人
+	ClosesAt  int64    `json:"closes_at,omitempty"` // 截止时间（Unix秒），0表示由发起者结束
+}
+
+// PollTally 投票结果，读取消息时附加在投票消息上
+type PollTally struct {
+	Counts   []int64 `json:"counts"` // 各选项的票数，与Options一一对应
+	Voters   int64   `json:"voters"` // 投票人数
+	Closed   bool    `json:"closed"`
+	ClosedAt int64   `json:"closed_at,omitempty"`
+}
+
+// PollDetail 投票详情，实名投票附带每个成员的选择
+type PollDetail struct {
+	MessageID string           `json:"message_id"`
+	Tally     *PollTally       `json:"tally"`
+	MyVote    []int            `json:"my_vote,omitempty"` // 请求者选择的选项序号
+	Votes     map[string][]int `json:"votes,omitempty"`   // 用户ID到选项序号，匿名投票不返回
+}
+
+// VoteRequest vote帧的请求，options为选项序号，重复投票以最后一次为准
+type VoteRequest struct {
+	MessageID string `json:"message_id"`
+	Options   []int  `json:"options"`
+}
+
+// ClosePollRequest close_poll帧的请求
+type ClosePollRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// PollUpdatedEvent 票数变化或投票结束时推送给群成员的事件，实名投票附带本次投票的成员和选择
+type PollUpdatedEvent struct {
+	MessageID string    `json:"message_id"`
+	GroupID   string    `json:"group_id"`
+	Tally     PollTally `json:"tally"`
+	UserID    string    `json:"user_id,omitempty"`
+	Options   []int     `json:"options,omitempty"`
+}
@@ -0,0 +1,29 @@
+package model
+
+// 在线状态
+const (
+	PresenceOnline  = "online"
+	PresenceOffline = "offline"
+)
+
+// PresenceEvent 在线状态变化事件
+type PresenceEvent struct {
+	UserID    string `json:"user_id"`
+	Status    string `json:"status"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// PresenceSubscribeRequest 订阅在线状态请求
+type PresenceSubscribeRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// PresenceWatchRequest 设置连接的在线状态关注列表，替换之前的列表，为空时取消全部关注
+type PresenceWatchRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// PresenceWatchResponse 关注列表中用户的当前状态
+type PresenceWatchResponse struct {
+	Presence []*PresenceEvent `json:"presence"`
+}
@@ -0,0 +1,57 @@
+package model
+
+import "fmt"
+
+// PrivacyLevel 隐私设置的可见范围
+type PrivacyLevel string
+
+const (
+	PrivacyEveryone PrivacyLevel = "everyone"
+	// PrivacyContacts 只允许联系人，即自己发过私聊消息或接受过其消息请求的用户
+	PrivacyContacts PrivacyLevel = "contacts"
+	PrivacyNobody   PrivacyLevel = "nobody"
+)
+
+// ParsePrivacyLevel 解析隐私设置，空字符串视为everyone
+func ParsePrivacyLevel(s string) (PrivacyLevel, error) {
+	switch p := PrivacyLevel(s); p {
+	case "":
+		return PrivacyEveryone, nil
+	case PrivacyEveryone, PrivacyContacts, PrivacyNobody:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid privacy level %q, must be everyone, contacts or nobody", s)
+}
+
+// Allows 判断该设置是否允许对方，isContact为对方是否为联系人
+func (p PrivacyLevel) Allows(isContact bool) bool {
+	switch p {
+	case PrivacyNobody:
+		return false
+	case PrivacyContacts:
+		return isContact
+	}
+	return true
+}
+
+// PrivacySettings 用户的隐私设置
+type PrivacySettings struct {
+	// WhoCanMessage 谁可以发私聊消息，为everyone时非联系人的消息进入消息请求，接受后才投递
+	WhoCanMessage PrivacyLevel `json:"who_can_message"`
+	// WhoCanAddToGroups 谁可以在创建群组时把自己加入群组
+	WhoCanAddToGroups PrivacyLevel `json:"who_can_add_to_groups"`
+	UpdatedAt         int64        `json:"updated_at"`
+}
+
+// DefaultPrivacySettings 未设置时的隐私设置
+func DefaultPrivacySettings() *PrivacySettings {
+	return &PrivacySettings{WhoCanMessage: PrivacyEveryone, WhoCanAddToGroups: PrivacyEveryone}
+}
+
+// MessageRequest 非联系人发来的待接受私聊消息
+type MessageRequest struct {
+	SenderID      string   `json:"sender_id"`
+	MessageCount  int64    `json:"message_count"`
+	LatestMessage *Message `json:"latest_message,omitempty"`
+	UpdatedAt     int64    `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivacyLevel(t *testing.T) {
+	level, err := ParsePrivacyLevel("")
+	assert.NoError(t, err)
+	assert.Equal(t, PrivacyEveryone, level)
+	_, err = ParsePrivacyLevel("friends")
+	assert.Error(t, err)
+
+	assert.True(t, PrivacyEveryone.Allows(false))
+	assert.True(t, PrivacyContacts.Allows(true))
+	assert.False(t, PrivacyContacts.Allows(false))
+	assert.False(t, PrivacyNobody.Allows(true))
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// UserProfile 用户资料
+type UserProfile struct {
+	UserID      string    `json:"user_id" gorm:"primaryKey;type:varchar(64)"`
+	Language    string    `json:"language" gorm:"type:varchar(35)"`                // BCP 47语言标签，为空时使用服务端默认语言
+	DisplayName string    `json:"display_name,omitempty" gorm:"type:varchar(100)"` // 身份提供方登录时同步
+	Email       string    `json:"email,omitempty" gorm:"type:varchar(255)"`        // 身份提供方登录时同步
+	UpdatedAt   time.Time `json:"updated_at"`
+}
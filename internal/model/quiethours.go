@@ -0,0 +1,112 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxQuietWindows 每个用户最多的免打扰时段数
+const MaxQuietWindows = 7
+
+// QuietHours 用户的免打扰计划，处于任一时段内时新消息推送帧的提醒被静音，紧急消息除外
+type QuietHours struct {
+	Enabled   bool          `json:"enabled"`
+	Timezone  string        `json:"timezone"` // IANA时区名，为空时按UTC计算
+	Windows   []QuietWindow `json:"windows"`
+	UpdatedAt int64         `json:"updated_at"`
+}
+
+// QuietWindow 免打扰时段，开始晚于结束时跨越午夜
+type QuietWindow struct {
+	Start string `json:"start"`          // 开始时间 HH:MM
+	End   string `json:"end"`            // 结束时间 HH:MM，不含
+	Days  []int  `json:"days,omitempty"` // 时段开始所在的星期，0为周日，为空表示每天
+}
+
+// Validate 校验时区和时段
+func (q *QuietHours) Validate() error {
+	if _, err := time.LoadLocation(q.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", q.Timezone)
+	}
+	if len(q.Windows) > MaxQuietWindows {
+		return fmt.Errorf("at most %d quiet windows are allowed", MaxQuietWindows)
+	}
+	for _, w := range q.Windows {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return err
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return err
+		}
+		if start == end {
+			return fmt.Errorf("quiet window %s-%s is empty", w.Start, w.End)
+		}
+		for _, day := range w.Days {
+			if day < 0 || day > 6 {
+				return fmt.Errorf("invalid day %d, must be 0-6", day)
+			}
+		}
+	}
+	return nil
+}
+
+// Active 判断t时刻是否处于免打扰时段，按计划的时区计算
+func (q *QuietHours) Active(t time.Time) bool {
+	if q == nil || !q.Enabled {
+		return false
+	}
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return false
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	today := int(local.Weekday())
+	yesterday := (today + 6) % 7
+
+	for _, w := range q.Windows {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			continue
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			continue
+		}
+		if start < end {
+			if minute >= start && minute < end && w.onDay(today) {
+				return true
+			}
+			continue
+		}
+		// 跨越午夜的时段：午夜前属于当天开始的时段，午夜后属于前一天开始的时段
+		if (minute >= start && w.onDay(today)) || (minute < end && w.onDay(yesterday)) {
+			return true
+		}
+	}
+	return false
+}
+
+// onDay 时段是否在给定星期开始
+func (w QuietWindow) onDay(day int) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock 解析HH:MM，返回从午夜起的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
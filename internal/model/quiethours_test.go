@@ -0,0 +1,66 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietHours_Active(t *testing.T) {
+	q := &QuietHours{
+		Enabled:  true,
+		Timezone: "Asia/Shanghai",
+		Windows:  []QuietWindow{{Start: "22:00", End: "07:00", Days: []int{5}}},
+	}
+	assert.NoError(t, q.Validate())
+
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	// 2024-01-05为周五
+	assert.True(t, q.Active(time.Date(2024, 1, 5, 23, 0, 0, 0, shanghai)))
+	assert.True(t, q.Active(time.Date(2024, 1, 6, 6, 59, 0, 0, shanghai)))
+	assert.False(t, q.Active(time.Date(2024, 1, 6, 7, 0, 0, 0, shanghai)))
+	assert.False(t, q.Active(time.Date(2024, 1, 6, 23, 0, 0, 0, shanghai)))
+	// 按计划的时区计算
+	assert.True(t, q.Active(time.Date(2024, 1, 5, 15, 30, 0, 0, time.UTC)))
+
+	q.Enabled = false
+	assert.False(t, q.Active(time.Date(2024, 1, 5, 23, 0, 0, 0, shanghai)))
+}
+
+func TestQuietHours_Validate(t *testing.T) {
+	assert.Error(t, (&QuietHours{Timezone: "Mars/Olympus"}).Validate())
+	assert.Error(t, (&QuietHours{Windows: []QuietWindow{{Start: "9:00pm", End: "07:00"}}}).Validate())
+	assert.Error(t, (&QuietHours{Windows: []QuietWindow{{Start: "07:00", End: "07:00"}}}).Validate())
+	assert.Error(t, (&QuietHours{Windows: []QuietWindow{{Start: "12:00", End: "13:00", Days: []int{7}}}}).Validate())
+	assert.NoError(t, (&QuietHours{Windows: []QuietWindow{{Start: "12:00", End: "13:00"}}}).Validate())
+}
+
+func TestMessage_QuietPushOptions(t *testing.T) {
+	push := (&Message{}).QuietPushOptions()
+	assert.True(t, push.Silent)
+	assert.Equal(t, MessagePriorityNormal, push.Priority)
+
+	push = (&Message{Priority: MessagePriorityUrgent}).QuietPushOptions()
+	assert.False(t, push.Silent)
+	assert.True(t, push.BypassMute)
+}
+
+func TestMessage_CollapseKey(t *testing.T) {
+	private := &Message{SenderID: "u1", ReceiverID: "u2"}
+	assert.Equal(t, "private:u1", private.CollapseKey("u2"))
+	// 发给发送者其他设备的同步帧归入与对方的会话
+	assert.Equal(t, "private:u2", private.CollapseKey("u1"))
+	assert.Equal(t, "group:g1", (&Message{SenderID: "u1", GroupID: "g1"}).CollapseKey(""))
+}
+
+func TestParseDigestMode(t *testing.T) {
+	mode, err := ParseDigestMode("")
+	assert.NoError(t, err)
+	assert.Equal(t, DigestAuto, mode)
+	mode, err = ParseDigestMode("off")
+	assert.NoError(t, err)
+	assert.Equal(t, DigestOff, mode)
+	_, err = ParseDigestMode("hourly")
+	assert.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package model
+
+import "time"
+
+// 配额类型
+const (
+	QuotaMessagesPerDay = "messages_per_day"
+	QuotaMediaBytes     = "media_bytes"
+	QuotaMaxGroups      = "max_groups"
+	QuotaMaxGroupSize   = "max_group_size"
+)
+
+// 配额主体类型
+const (
+	QuotaSubjectUser   = "user"
+	QuotaSubjectTenant = "tenant"
+	// QuotaSubjectGroup 群组，只用于媒体存储配额
+	QuotaSubjectGroup = "group"
+)
+
+// QuotaSubject 配额主体标识，如user:alice、tenant:acme
+func QuotaSubject(kind, id string) string {
+	return kind + ":" + id
+}
+
+// QuotaLimits 配额上限，0表示不限制
+type QuotaLimits struct {
+	MessagesPerDay int64 `json:"messages_per_day"`
+	MediaBytes     int64 `json:"media_bytes"`
+	MaxGroups      int64 `json:"max_groups"`
+	MaxGroupSize   int64 `json:"max_group_size"`
+}
+
+// QuotaOverrides 管理接口为单个主体设置的配额，未设置的字段使用配置文件中的默认值
+type QuotaOverrides struct {
+	MessagesPerDay *int64 `json:"messages_per_day,omitempty"`
+	MediaBytes     *int64 `json:"media_bytes,omitempty"`
+	MaxGroups      *int64 `json:"max_groups,omitempty"`
+	MaxGroupSize   *int64 `json:"max_group_size,omitempty"`
+}
+
+// IsEmpty 判断是否没有任何覆盖
+func (o QuotaOverrides) IsEmpty() bool {
+	return o.MessagesPerDay == nil && o.MediaBytes == nil && o.MaxGroups == nil && o.MaxGroupSize == nil
+}
+
+// Apply 在默认配额上应用覆盖
+func (o QuotaOverrides) Apply(defaults QuotaLimits) QuotaLimits {
+	limits := defaults
+	if o.MessagesPerDay != nil {
+		limits.MessagesPerDay = *o.MessagesPerDay
+	}
+	if o.MediaBytes != nil {
+		limits.MediaBytes = *o.MediaBytes
+	}
+	if o.MaxGroups != nil {
+		limits.MaxGroups = *o.MaxGroups
+	}
+	if o.MaxGroupSize != nil {
+		limits.MaxGroupSize = *o.MaxGroupSize
+	}
+	return limits
+}
+
+// QuotaUsage 配额用量，计数保存在Redis中，定期持久化到MySQL，Redis丢失计数时从MySQL恢复
+type QuotaUsage struct {
+	Subject     string         `json:"subject" gorm:"primaryKey;type:varchar(100)"`
+	Day         string         `json:"day" gorm:"type:varchar(10)"` // Messages所属的UTC日期
+	Messages    int64          `json:"messages"`
+	MediaBytes  int64          `json:"media_bytes"`
+	OwnedGroups int64          `json:"owned_groups"` // 作为群主创建的群组数
+	Overrides   QuotaOverrides `json:"overrides" gorm:"type:json;serializer:json"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// QuotaReport 主体的配额和用量
+type QuotaReport struct {
+	Subject   string         `json:"subject"`
+	Limits    QuotaLimits    `json:"limits"`
+	Overrides QuotaOverrides `json:"overrides"`
+	Usage     *QuotaUsage    `json:"usage"`
+}
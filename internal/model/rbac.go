@@ -0,0 +1,86 @@
+package model
+
+import "sort"
+
+// AdminPermission 管理接口权限，每个管理接口要求其中一项
+type AdminPermission string
+
+const (
+	AdminPermClusterView      AdminPermission = "cluster.view"      // 节点、组件、后台任务、联邦队列、存储用量和运营统计
+	AdminPermClusterManage    AdminPermission = "cluster.manage"    // 排空节点连接
+	AdminPermUsersView        AdminPermission = "users.view"        // 用户的会话、客户端、流量和离线队列
+	AdminPermUsersDisconnect  AdminPermission = "users.disconnect"  // 强制断开用户的全部连接
+	AdminPermUsersManage      AdminPermission = "users.manage"      // 重投或清空离线队列，重置两步验证
+	AdminPermModerationView   AdminPermission = "moderation.view"   // 用户处罚、举报和访客令牌
+	AdminPermModerationManage AdminPermission = "moderation.manage" // 处罚用户、处理举报、签发和吊销访客令牌
+	AdminPermMessagesDelete   AdminPermission = "messages.delete"   // 物理删除消息
+	AdminPermConfigView       AdminPermission = "config.view"       // 客户端配置、功能开关、表情包、公众号、敏感词、模板、配额和API密钥
+	AdminPermConfigManage     AdminPermission = "config.manage"     // 修改上述配置、公众号群发和企业目录同步
+	AdminPermAuditView        AdminPermission = "audit.view"        // 审计记录
+	AdminPermRolesManage      AdminPermission = "roles.manage"      // 查看和分配管理角色
+)
+
+// AllAdminPermissions 全部管理权限
+var AllAdminPermissions = []AdminPermission{
+	AdminPermClusterView, AdminPermClusterManage, AdminPermUsersView, AdminPermUsersDisconnect, AdminPermUsersManage,
+	AdminPermModerationView, AdminPermModerationManage, AdminPermMessagesDelete,
+	AdminPermConfigView, AdminPermConfigManage, AdminPermAuditView, AdminPermRolesManage,
+}
+
+// AdminRolePermissions 各管理角色的权限
+var AdminRolePermissions = map[string][]AdminPermission{
+	RoleSupport: {
+		AdminPermUsersView, AdminPermUsersDisconnect, AdminPermUsersManage, AdminPermModerationView,
+	},
+	RoleModerator: {
+		AdminPermUsersView, AdminPermUsersDisconnect, AdminPermModerationView, AdminPermModerationManage, AdminPermMessagesDelete,
+	},
+	RoleOperator: {
+		AdminPermClusterView, AdminPermClusterManage, AdminPermUsersView, AdminPermConfigView, AdminPermConfigManage,
+	},
+	RoleAuditor: {
+		AdminPermClusterView, AdminPermUsersView, AdminPermModerationView, AdminPermConfigView, AdminPermAuditView,
+	},
+	RoleSuperAdmin: AllAdminPermissions,
+	RoleAdmin:      AllAdminPermissions,
+}
+
+// IsAdminRole 是否为管理角色
+func IsAdminRole(role string) bool {
+	_, ok := AdminRolePermissions[role]
+	return ok
+}
+
+// AdminRoles 筛选出管理角色，去重并排序
+func AdminRoles(roles []string) []string {
+	seen := make(map[string]bool, len(roles))
+	admin := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if IsAdminRole(role) && !seen[role] {
+			seen[role] = true
+			admin = append(admin, role)
+		}
+	}
+	sort.Strings(admin)
+	return admin
+}
+
+// AdminRolesAllow 角色中是否有一个带有该权限
+func AdminRolesAllow(roles []string, permission AdminPermission) bool {
+	for _, role := range roles {
+		for _, p := range AdminRolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AdminRoleAssignment 通过管理接口分配给用户的管理角色，与令牌中的角色合并生效
+type AdminRoleAssignment struct {
+	UserID    string   `json:"user_id"`
+	Roles     []string `json:"roles"`
+	UpdatedBy string   `json:"updated_by"`
+	UpdatedAt int64    `json:"updated_at"`
+}
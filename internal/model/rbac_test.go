@@ -0,0 +1,39 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminRolesAllow(t *testing.T) {
+	assert.True(t, AdminRolesAllow([]string{RoleSupport}, AdminPermUsersView))
+	assert.True(t, AdminRolesAllow([]string{RoleSupport}, AdminPermUsersDisconnect))
+	assert.False(t, AdminRolesAllow([]string{RoleSupport}, AdminPermMessagesDelete))
+	assert.True(t, AdminRolesAllow([]string{RoleModerator}, AdminPermMessagesDelete))
+	assert.False(t, AdminRolesAllow([]string{RoleModerator}, AdminPermConfigManage))
+	assert.True(t, AdminRolesAllow([]string{RoleOperator}, AdminPermConfigManage))
+	assert.False(t, AdminRolesAllow([]string{RoleOperator}, AdminPermAuditView))
+	assert.False(t, AdminRolesAllow([]string{RoleAuditor}, AdminPermUsersDisconnect))
+	assert.True(t, AdminRolesAllow([]string{RoleOperator}, AdminPermClusterManage))
+	assert.False(t, AdminRolesAllow([]string{RoleAuditor}, AdminPermClusterManage))
+	assert.True(t, AdminRolesAllow([]string{RoleSupport, RoleOperator}, AdminPermClusterView))
+	assert.False(t, AdminRolesAllow([]string{"member"}, AdminPermUsersView))
+	assert.False(t, AdminRolesAllow(nil, AdminPermUsersView))
+
+	for _, permission := range AllAdminPermissions {
+		assert.True(t, AdminRolesAllow([]string{RoleSuperAdmin}, permission))
+		assert.True(t, AdminRolesAllow([]string{RoleAdmin}, permission))
+	}
+	// 只有超级管理员能分配角色
+	for role := range AdminRolePermissions {
+		if role != RoleSuperAdmin && role != RoleAdmin {
+			assert.False(t, AdminRolesAllow([]string{role}, AdminPermRolesManage), role)
+		}
+	}
+}
+
+func TestAdminRoles(t *testing.T) {
+	assert.Equal(t, []string{RoleModerator, RoleSupport}, AdminRoles([]string{"member", RoleSupport, RoleModerator, RoleSupport}))
+	assert.Empty(t, AdminRoles([]string{"member"}))
+}
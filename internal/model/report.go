@@ -0,0 +1,70 @@
+package model
+
+import "time"
+
+// ReportTarget 举报对象类型
+type ReportTarget string
+
+const (
+	ReportTargetMessage ReportTarget = "message"
+	ReportTargetUser    ReportTarget = "user"
+)
+
+// ReportReason 举报原因
+type ReportReason string
+
+const (
+	ReportReasonSpam       ReportReason = "spam"
+	ReportReasonHarassment ReportReason = "harassment"
+	ReportReasonHate       ReportReason = "hate"
+	ReportReasonViolence   ReportReason = "violence"
+	ReportReasonSexual     ReportReason = "sexual"
+	ReportReasonOther      ReportReason = "other"
+)
+
+// ValidReportReasons 支持的举报原因
+var ValidReportReasons = map[ReportReason]bool{
+	ReportReasonSpam:       true,
+	ReportReasonHarassment: true,
+	ReportReasonHate:       true,
+	ReportReasonViolence:   true,
+	ReportReasonSexual:     true,
+	ReportReasonOther:      true,
+}
+
+// ReportStatus 举报处理状态
+type ReportStatus string
+
+const (
+	ReportStatusOpen      ReportStatus = "open"
+	ReportStatusResolved  ReportStatus = "resolved"
+	ReportStatusDismissed ReportStatus = "dismissed"
+)
+
+// ReportAction 管理员处理举报时采取的措施
+type ReportAction string
+
+const (
+	ReportActionDismiss       ReportAction = "dismiss"
+	ReportActionDeleteMessage ReportAction = "delete_message"
+	ReportActionMute          ReportAction = "mute"
+	ReportActionBan           ReportAction = "ban"
+)
+
+// Report 用户对消息或用户的举报，举报消息时保存提交时的消息副本，之后消息被编辑或删除不影响处理
+type Report struct {
+	ID           string       `json:"id" gorm:"primaryKey;type:varchar(64);index:idx_reports_status_id,priority:2"`
+	ReporterID   string       `json:"reporter_id" gorm:"type:varchar(64);index"`
+	TargetType   ReportTarget `json:"target_type" gorm:"type:varchar(16)"`
+	TargetUserID string       `json:"target_user_id" gorm:"type:varchar(64);index"` // 被举报的用户，举报消息时为消息发送者
+	MessageID    string       `json:"message_id,omitempty" gorm:"type:varchar(64);index"`
+	Reason       ReportReason `json:"reason" gorm:"type:varchar(32)"`
+	Comment      string       `json:"comment,omitempty" gorm:"type:varchar(1000)"`
+	Snapshot     *Message     `json:"snapshot,omitempty" gorm:"type:json;serializer:json"`
+	Status       ReportStatus `json:"status" gorm:"type:varchar(16);index:idx_reports_status_id,priority:1"`
+	Action       ReportAction `json:"action,omitempty" gorm:"type:varchar(32)"`
+	ResolvedBy   string       `json:"resolved_by,omitempty" gorm:"type:varchar(64)"`
+	ResolvedAt   int64        `json:"resolved_at,omitempty"`
+	Note         string       `json:"note,omitempty" gorm:"type:varchar(1000)"` // 管理员的处理说明
+	CreatedAt    time.Time    `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package model
+
+// GeoLocation 客户端IP对应的地理位置，字段为空表示未知
+type GeoLocation struct {
+	Country string `json:"country,omitempty"` // ISO 3166-1 两位国家代码
+	Region  string `json:"region,omitempty"`
+	City    string `json:"city,omitempty"`
+}
+
+// Handshake 客户端建立连接时的握手信息，用于安全审查
+type Handshake struct {
+	RemoteIP    string       `json:"remote_ip"` // 经可信代理解析后的客户端IP
+	UserAgent   string       `json:"user_agent,omitempty"`
+	Geo         *GeoLocation `json:"geo,omitempty"` // 未配置地理位置查询或查询不到时为空
+	ConnectedAt int64        `json:"connected_at"`
+}
+
+// SessionRecord 已登录会话的记录，保存在Redis中，按用户列出
+type SessionRecord struct {
+	SessionID  string `json:"session_id"`
+	UserID     string `json:"user_id"`
+	NodeID     string `json:"node_id"` // 会话所在的接入节点
+	Transport  string `json:"transport"`
+	Platform   string `json:"platform,omitempty"`
+	AppVersion string `json:"app_version,omitempty"`
+	Handshake
+	LoginAt int64 `json:"login_at"`
+}
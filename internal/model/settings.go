@@ -0,0 +1,11 @@
+package model
+
+import "encoding/json"
+
+// UserSettings 用户偏好设置，由客户端定义键和值（主题、通知、隐私等），在用户的多个设备间同步
+// Version从1开始，每次更新加一，客户端据此检测并发修改
+type UserSettings struct {
+	Values    map[string]json.RawMessage `json:"values"`
+	Version   int64                      `json:"version"`
+	UpdatedAt int64                      `json:"updated_at"`
+}
@@ -0,0 +1,21 @@
+package model
+
+// SpoolKind 本地暂存事件的类型，对应一次写入Kafka或Redis的操作
+type SpoolKind string
+
+const (
+	// SpoolOfflineMessage 写入离线消息主题
+	SpoolOfflineMessage SpoolKind = "offline_message"
+	// SpoolGroupMessage 写入群聊消息主题
+	SpoolGroupMessage SpoolKind = "group_message"
+	// SpoolOfflineQueue 写入接收者在Redis中的离线消息队列
+	SpoolOfflineQueue SpoolKind = "offline_queue"
+)
+
+// SpoolEntry 依赖不可用时暂存在本地的投递事件
+type SpoolEntry struct {
+	Kind      SpoolKind `json:"kind"`
+	UserID    string    `json:"user_id,omitempty"` // 离线消息队列所属用户
+	Message   *Message  `json:"message"`
+	SpooledAt int64     `json:"spooled_at"`
+}
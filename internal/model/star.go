@@ -0,0 +1,16 @@
+package model
+
+// UserStarredMessage 用户收藏的消息，消息被物理删除时一并删除
+type UserStarredMessage struct {
+	UserID         string `json:"user_id" gorm:"primaryKey;type:varchar(64);index:idx_starred_user_time,priority:1"`
+	MessageID      string `json:"message_id" gorm:"primaryKey;type:varchar(64);index"`
+	ConversationID string `json:"conversation_id" gorm:"type:varchar(140)"`
+	StarredAt      int64  `json:"starred_at" gorm:"index:idx_starred_user_time,priority:2"` // 收藏时间（Unix秒）
+}
+
+// StarredMessage 收藏列表中的一条消息
+type StarredMessage struct {
+	ConversationID string   `json:"conversation_id"`
+	StarredAt      int64    `json:"starred_at"`
+	Message        *Message `json:"message"`
+}
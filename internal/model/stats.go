@@ -0,0 +1,38 @@
+package model
+
+// 依赖健康状态
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+// HealthStatus 依赖的健康检查结果
+type HealthStatus struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// NodeStats 单个节点的运行统计快照
+type NodeStats struct {
+	NodeID              string                   `json:"node_id"`
+	Mode                string                   `json:"mode"`
+	Connections         int                      `json:"connections"`
+	OnlineUsers         int                      `json:"online_users"`
+	Transports          map[string]int           `json:"transports"`
+	MessagesPerSec      float64                  `json:"messages_per_sec"`      // 最近一个采样周期内的发送速率
+	Deliveries          int64                    `json:"deliveries"`            // 启动以来的推送次数
+	DeliveryFailures    int64                    `json:"delivery_failures"`     // 启动以来推送失败的次数
+	DeliverySuccessRate float64                  `json:"delivery_success_rate"` // 没有推送时为1
+	OfflineQueued       int64                    `json:"offline_queued"`        // 全部用户离线队列中的消息数
+	KafkaLag            map[string]int64         `json:"kafka_lag"`             // 按主题汇总的消费延迟
+	Health              map[string]*HealthStatus `json:"health"`
+	Timestamp           int64                    `json:"timestamp"`
+}
+
+// ClusterStats 按注册中心汇总的集群统计
+type ClusterStats struct {
+	Nodes   []*NodeStats `json:"nodes"`
+	Missing []string     `json:"missing"` // 已注册但没有上报快照的节点
+	Totals  *NodeStats   `json:"totals"`
+}
@@ -0,0 +1,58 @@
+package model
+
+// StickerPackKind 表情包类型
+type StickerPackKind string
+
+const (
+	// StickerPackKindSticker 表情包，作为单独的消息发送
+	StickerPackKindSticker StickerPackKind = "sticker"
+	// StickerPackKindEmoji 自定义表情，客户端可按短代码插入
+	StickerPackKindEmoji StickerPackKind = "emoji"
+)
+
+// Sticker 表情包中的单个表情，图片由媒体服务托管
+type Sticker struct {
+	ID        string `json:"id"`
+	Shortcode string `json:"shortcode,omitempty"` // 自定义表情的短代码，如 party_parrot
+	Emoji     string `json:"emoji,omitempty"`     // 对应的Unicode表情，作为无法显示图片时的替代文本
+	FileURL   string `json:"file_url"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+}
+
+// StickerPack 表情包，Tenant为空时所有用户可用，否则只对该租户的用户可用
+type StickerPack struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Kind      StickerPackKind `json:"kind"`
+	Tenant    string          `json:"tenant,omitempty"`
+	CoverURL  string          `json:"cover_url,omitempty"`
+	Stickers  []Sticker       `json:"stickers"`
+	UpdatedAt int64           `json:"updated_at"`
+}
+
+// Sticker 按ID查找表情
+func (p *StickerPack) Sticker(id string) (*Sticker, bool) {
+	for i := range p.Stickers {
+		if p.Stickers[i].ID == id {
+			return &p.Stickers[i], true
+		}
+	}
+	return nil, false
+}
+
+// StickerPackSummary 随客户端配置下发的表情包摘要，客户端按更新时间判断是否需要重新拉取
+type StickerPackSummary struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Kind      StickerPackKind `json:"kind"`
+	CoverURL  string          `json:"cover_url,omitempty"`
+	Count     int             `json:"count"`
+	UpdatedAt int64           `json:"updated_at"`
+}
+
+// StickerPayload 表情包消息的内容
+type StickerPayload struct {
+	PackID    string `json:"pack_id"`
+	StickerID string `json:"sticker_id"`
+}
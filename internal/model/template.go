@@ -0,0 +1,31 @@
+package model
+
+// MessageTemplate 服务消息模板，后端系统按模板ID和变量发送结构一致的通知
+// Content中的 {{name}} 占位符在发送时替换为变量值；menu类型的模板用于带快捷回复按钮的通知
+type MessageTemplate struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"name"`
+	Type      MessageType        `json:"type"`
+	Content   string             `json:"content"`
+	Variables []TemplateVariable `json:"variables,omitempty"`
+	RateLimit int                `json:"rate_limit,omitempty"` // 所有密钥合计每分钟最多发送的消息数，0表示不限制
+	UpdatedAt int64              `json:"updated_at"`
+}
+
+// TemplateVariable 模板变量，未传入的可选变量使用Default
+type TemplateVariable struct {
+	Name      string `json:"name"`
+	Required  bool   `json:"required,omitempty"`
+	Default   string `json:"default,omitempty"`
+	MaxLength int    `json:"max_length,omitempty"` // 变量值的最大长度（字符），0表示使用默认上限
+}
+
+// Variable 按名称查找模板变量
+func (t *MessageTemplate) Variable(name string) (*TemplateVariable, bool) {
+	for i := range t.Variables {
+		if t.Variables[i].Name == name {
+			return &t.Variables[i], true
+		}
+	}
+	return nil, false
+}
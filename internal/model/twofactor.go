@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// TwoFactor 用户的两步验证（TOTP）设置
+type TwoFactor struct {
+	UserID        string    `json:"user_id" gorm:"primaryKey;type:varchar(64)"`
+	Secret        string    `json:"-" gorm:"type:varchar(64)"`          // Base32编码的共享密钥
+	Enabled       bool      `json:"enabled" gorm:"default:false"`       // 绑定后首个动态码校验通过才开启
+	RecoveryCodes []string  `json:"-" gorm:"type:json;serializer:json"` // 恢复码的SHA-256摘要，使用后删除
+	LastStep      int64     `json:"-" gorm:"default:0"`                 // 最近一次通过校验的时间步，同一动态码不能重复使用
+	EnabledAt     int64     `json:"enabled_at,omitempty" gorm:"default:0"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TwoFactorEnrollment 绑定验证器应用时返回的共享密钥，只在绑定时返回一次
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"` // otpauth地址，客户端生成二维码供验证器应用扫描
+}
+
+// TwoFactorStatus 用户的两步验证状态
+type TwoFactorStatus struct {
+	UserID            string `json:"user_id"`
+	Enabled           bool   `json:"enabled"`
+	Required          bool   `json:"required"` // 账号必须开启两步验证，未开启时不能登录或签发API密钥
+	RecoveryCodesLeft int    `json:"recovery_codes_left"`
+	EnabledAt         int64  `json:"enabled_at,omitempty"`
+}
@@ -0,0 +1,84 @@
+package model
+
+// UploadSession 大文件分片上传会话，保存在Redis中，任意节点都可以继续上传
+// 分片按顺序上传，第N片对应对象存储分段上传的第N段
+type UploadSession struct {
+	ID           string       `json:"id"`
+	UserID       string       `json:"user_id"`
+	Filename     string       `json:"filename"`
+	ContentType  string       `json:"content_type,omitempty"`
+	Size         int64        `json:"size"`
+	Offset       int64        `json:"offset"` // 已接收的字节数
+	ChunkSize    int64        `json:"chunk_size"`
+	ObjectKey    string       `json:"object_key"`
+	StagingKey   string       `json:"staging_key"`             // 分段上传的目标，需要扫描或处理图片时位于隔离区，完成后写入ObjectKey
+	StorageID    string       `json:"storage_id"`              // 对象存储的分段上传ID
+	Assembled    bool         `json:"assembled"`               // 分段已合并，等待扫描或处理
+	ProcessImage bool         `json:"process_image,omitempty"` // 完成时去除图片元数据并按需重新压缩
+	Parts        []UploadPart `json:"parts,omitempty"`
+	CreatedAt    int64        `json:"created_at"`
+	ExpiresAt    int64        `json:"expires_at"`
+}
+
+// UploadPart 已上传的分片
+type UploadPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// UploadStatus 返回给客户端的上传进度，客户端从offset继续上传
+type UploadStatus struct {
+	UploadID    string `json:"upload_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"`
+	Offset      int64  `json:"offset"`
+	ChunkSize   int64  `json:"chunk_size"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// UploadedFile 上传完成的文件，url可作为文件、图片、语音、视频消息的内容
+type UploadedFile struct {
+	URL         string      `json:"url"`
+	Filename    string      `json:"filename"`
+	ContentType string      `json:"content_type,omitempty"`
+	Size        int64       `json:"size"`
+	Scan        *ScanResult `json:"scan,omitempty"`
+}
+
+// ScanStatus 文件扫描结果
+type ScanStatus string
+
+const (
+	// ScanStatusClean 未发现威胁
+	ScanStatusClean ScanStatus = "clean"
+	// ScanStatusInfected 发现威胁，文件已删除
+	ScanStatusInfected ScanStatus = "infected"
+)
+
+// ScanResult 文件扫描结果，同时作为对象存储中文件的元数据保存
+type ScanResult struct {
+	Status    ScanStatus `json:"status"`
+	Engine    string     `json:"engine"`
+	Threat    string     `json:"threat,omitempty"`
+	ScannedAt int64      `json:"scanned_at"`
+}
+
+// Status 会话的上传进度
+func (s *UploadSession) Status() *UploadStatus {
+	return &UploadStatus{
+		UploadID:    s.ID,
+		Filename:    s.Filename,
+		ContentType: s.ContentType,
+		Size:        s.Size,
+		Offset:      s.Offset,
+		ChunkSize:   s.ChunkSize,
+		ExpiresAt:   s.ExpiresAt,
+	}
+}
+
+// SignedMediaURL 签名的媒体下载地址
+type SignedMediaURL struct {
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"`
+}
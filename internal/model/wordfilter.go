@@ -0,0 +1,15 @@
+package model
+
+// WordPack 一种语言一个级别的词库
+type WordPack struct {
+	Language string          `json:"language"`
+	Level    WordFilterLevel `json:"level"`
+	Files    []string        `json:"files"`
+	Words    int             `json:"words"`
+}
+
+// WordFilterStatus 当前加载的敏感词库
+type WordFilterStatus struct {
+	Packs    []WordPack `json:"packs"`
+	LoadedAt int64      `json:"loaded_at"`
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+// AdminRoleService 管理角色分配，分配的角色与IM令牌中的角色合并后决定管理接口的权限
+type AdminRoleService struct {
+	redisStore *store.RedisStore
+}
+
+// NewAdminRoleService 创建管理角色服务
+func NewAdminRoleService(redisStore *store.RedisStore) *AdminRoleService {
+	return &AdminRoleService{redisStore: redisStore}
+}
+
+// EffectiveRoles 合并令牌中的角色和分配的角色，只保留管理角色
+func (a *AdminRoleService) EffectiveRoles(userID string, tokenRoles []string) ([]string, error) {
+	assignment, ok, err := a.redisStore.GetAdminRoles(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin roles: %w", err)
+	}
+	roles := append([]string(nil), tokenRoles...)
+	if ok {
+		roles = append(roles, assignment.Roles...)
+	}
+	return model.AdminRoles(roles), nil
+}
+
+// Assign 为用户分配管理角色，整体替换已有的分配
+func (a *AdminRoleService) Assign(userID string, roles []string, actor string) (*model.AdminRoleAssignment, error) {
+	if err := validateAdminRoles(userID, roles); err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	assignment := &model.AdminRoleAssignment{
+		UserID:    userID,
+		Roles:     model.AdminRoles(roles),
+		UpdatedBy: actor,
+		UpdatedAt: time.Now().Unix(),
+	}
+	if err := a.redisStore.SetAdminRoles(assignment); err != nil {
+		return nil, fmt.Errorf("failed to save admin roles: %w", err)
+	}
+	return assignment, nil
+}
+
+// Revoke 撤销用户的全部分配角色，令牌中的角色不受影响
+func (a *AdminRoleService) Revoke(userID string) error {
+	removed, err := a.redisStore.DeleteAdminRoles(userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete admin roles: %w", err)
+	}
+	if !removed {
+		return newServiceError(ErrCodeNotFound, "user %s has no assigned admin roles", userID)
+	}
+	return nil
+}
+
+// List 获取全部管理角色分配，按用户ID排序
+func (a *AdminRoleService) List() ([]*model.AdminRoleAssignment, error) {
+	assignments, err := a.redisStore.GetAllAdminRoles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list admin roles: %w", err)
+	}
+	sort.Slice(assignments, func(i, j int) bool { return assignments[i].UserID < assignments[j].UserID })
+	return assignments, nil
+}
+
+// validateAdminRoles 校验分配的角色，至少一个且都是已知的管理角色
+func validateAdminRoles(userID string, roles []string) error {
+	if userID == "" {
+		return fmt.Errorf("user_id is required")
+	}
+	if len(roles) == 0 {
+		return fmt.Errorf("at least one role is required")
+	}
+	for _, role := range roles {
+		if !model.IsAdminRole(role) {
+			return fmt.Errorf("unknown admin role: %s", role)
+		}
+	}
+	return nil
+}
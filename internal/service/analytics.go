@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+const (
+	// connectionReportMaxAge 节点连接数上报的有效期，心跳检测每30秒上报一次
+	connectionReportMaxAge = 2 * time.Minute
+	// maxAnalyticsDays 单次查询的最大天数
+	maxAnalyticsDays = 366
+)
+
+// AnalyticsService 运营统计，各节点在Redis中按日累加计数，主节点定期汇总到MySQL的统计表
+// 未启用统计时为nil，记录方法都是空操作
+type AnalyticsService struct {
+	mysqlStore *store.MySQLStore
+	redisStore *store.RedisStore
+	cfg        config.AnalyticsConfig
+}
+
+// NewAnalyticsService 创建统计服务
+func NewAnalyticsService(mysqlStore *store.MySQLStore, redisStore *store.RedisStore, cfg config.AnalyticsConfig) *AnalyticsService {
+	return &AnalyticsService{
+		mysqlStore: mysqlStore,
+		redisStore: redisStore,
+		cfg:        cfg,
+	}
+}
+
+// analyticsDay 时间所在的统计日
+func analyticsDay(t time.Time) string {
+	return t.UTC().Format(model.AnalyticsDayLayout)
+}
+
+// RecordMessage 记录用户发送的消息，发送者计为当日活跃用户
+func (a *AnalyticsService) RecordMessage(message *model.Message) {
+	if a == nil || message.IsSystem() {
+		return
+	}
+	day := analyticsDay(time.Unix(message.Timestamp, 0))
+	if err := a.redisStore.IncrMessageStats(day, message.GroupID, a.cfg.Retention); err != nil {
+		logger.Warn("Failed to record message stats", logger.ErrorField(err))
+	}
+	a.RecordActive(message.SenderID)
+}
+
+// RecordActive 记录当日活跃用户
+func (a *AnalyticsService) RecordActive(userID string) {
+	if a == nil {
+		return
+	}
+	if err := a.redisStore.AddActiveUser(analyticsDay(time.Now()), userID, a.cfg.Retention); err != nil {
+		logger.Warn("Failed to record active user", logger.String("user_id", userID), logger.ErrorField(err))
+	}
+}
+
+// RecordDelivery 记录投递回执及消息从发送到投递的耗时
+func (a *AnalyticsService) RecordDelivery(message *model.Message, at time.Time) {
+	if a == nil {
+		return
+	}
+	latency := at.Sub(time.Unix(message.Timestamp, 0))
+	if latency < 0 {
+		latency = 0
+	}
+	if err := a.redisStore.IncrDeliveryStats(analyticsDay(at), latency, a.cfg.Retention); err != nil {
+		logger.Warn("Failed to record delivery stats", logger.ErrorField(err))
+	}
+}
+
+// ReportConnections 上报本节点的当前连接数，供主节点采样峰值
+func (a *AnalyticsService) ReportConnections(nodeID string, count int) {
+	if a == nil {
+		return
+	}
+	if err := a.redisStore.ReportConnections(nodeID, count); err != nil {
+		logger.Warn("Failed to report connections", logger.ErrorField(err))
+	}
+}
+
+// Rollup 采样全集群连接数更新当日峰值，并把今天和昨天的计数汇总到统计表
+// 作为主节点后台任务运行，昨天的数据在跨日后仍会再汇总一次，补上零点前后的计数
+func (a *AnalyticsService) Rollup(ctx context.Context, fence int64) error {
+	now := time.Now()
+	today := analyticsDay(now)
+
+	connections, err := a.redisStore.SumConnections(connectionReportMaxAge)
+	if err != nil {
+		return fmt.Errorf("failed to sum connections: %w", err)
+	}
+	if err := a.redisStore.UpdatePeakConnections(today, connections, a.cfg.Retention); err != nil {
+		return fmt.Errorf("failed to update peak connections: %w", err)
+	}
+
+	for _, day := range []string{analyticsDay(now.Add(-24 * time.Hour)), today} {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		counters, err := a.redisStore.GetDailyCounters(day)
+		if err != nil {
+			return fmt.Errorf("failed to get counters for %s: %w", day, err)
+		}
+		stats, groups := buildDailyStats(day, counters, now)
+		if err := a.mysqlStore.SaveDailyStats(stats, groups); err != nil {
+			return fmt.Errorf("failed to save daily stats for %s: %w", day, err)
+		}
+	}
+	return nil
+}
+
+// buildDailyStats 由计数构造统计表记录
+func buildDailyStats(day string, counters *store.DailyCounters, now time.Time) (*model.DailyStats, []*model.GroupDailyStats) {
+	stats := &model.DailyStats{
+		Day:             day,
+		Messages:        counters.Messages,
+		ActiveUsers:     counters.ActiveUsers,
+		PeakConnections: counters.PeakConnections,
+		Deliveries:      counters.Deliveries,
+		UpdatedAt:       now,
+	}
+	if counters.Deliveries > 0 {
+		stats.AvgDeliveryLatencyMs = counters.LatencyMs / counters.Deliveries
+	}
+
+	groups := make([]*model.GroupDailyStats, 0, len(counters.Groups))
+	for groupID, messages := range counters.Groups {
+		groups = append(groups, &model.GroupDailyStats{Day: day, GroupID: groupID, Messages: messages})
+	}
+	return stats, groups
+}
+
+// Report 获取日期区间内的每日统计和最活跃的群组，日期为UTC的 YYYY-MM-DD，包含首尾两天
+func (a *AnalyticsService) Report(from, to string, groupLimit int) (*model.AnalyticsReport, error) {
+	fromDay, err := time.Parse(model.AnalyticsDayLayout, from)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "from must be a date in YYYY-MM-DD format")
+	}
+	toDay, err := time.Parse(model.AnalyticsDayLayout, to)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "to must be a date in YYYY-MM-DD format")
+	}
+	if toDay.Before(fromDay) {
+		return nil, newServiceError(ErrCodeInvalidRequest, "to must not be before from")
+	}
+	if toDay.Sub(fromDay) >= maxAnalyticsDays*24*time.Hour {
+		return nil, newServiceError(ErrCodeInvalidRequest, "date range must not exceed %d days", maxAnalyticsDays)
+	}
+
+	days, err := a.mysqlStore.ListDailyStats(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daily stats: %w", err)
+	}
+	groups, err := a.mysqlStore.TopGroupActivity(from, to, groupLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group activity: %w", err)
+	}
+	return &model.AnalyticsReport{From: from, To: to, Days: days, Groups: groups}, nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/store"
+)
+
+func TestBuildDailyStats(t *testing.T) {
+	now := time.Now()
+	stats, groups := buildDailyStats("2024-01-01", &store.DailyCounters{
+		Messages:        10,
+		ActiveUsers:     3,
+		PeakConnections: 5,
+		Deliveries:      4,
+		LatencyMs:       1000,
+		Groups:          map[string]int64{"g1": 7},
+	}, now)
+
+	assert.Equal(t, "2024-01-01", stats.Day)
+	assert.Equal(t, int64(10), stats.Messages)
+	assert.Equal(t, int64(250), stats.AvgDeliveryLatencyMs)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, int64(7), groups[0].Messages)
+
+	// 没有投递时平均耗时为0
+	stats, _ = buildDailyStats("2024-01-02", &store.DailyCounters{}, now)
+	assert.Zero(t, stats.AvgDeliveryLatencyMs)
+}
+
+func TestAnalyticsReport_ValidatesRange(t *testing.T) {
+	a := &AnalyticsService{}
+	for _, tc := range []struct{ from, to string }{
+		{"", "2024-01-01"},
+		{"2024-01-01", "01/02/2024"},
+		{"2024-01-02", "2024-01-01"},
+		{"2022-12-31", "2024-01-01"},
+	} {
+		_, err := a.Report(tc.from, tc.to, 10)
+		assert.Equal(t, ErrCodeInvalidRequest, errorCode(err), "%s..%s", tc.from, tc.to)
+	}
+}
+
+func TestAnalytics_NilIsNoop(t *testing.T) {
+	var a *AnalyticsService
+	a.RecordActive("u1")
+	a.ReportConnections("node-1", 10)
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+	"gorm.io/gorm"
+)
+
+const (
+	// apiKeyPrefix API密钥明文前缀
+	apiKeyPrefix = "imk_"
+	// apiKeyBytes API密钥随机部分的字节数
+	apiKeyBytes = 24
+	// apiKeyDisplayLength 保存用于识别的明文前缀长度
+	apiKeyDisplayLength = 12
+	// apiKeyRateWindow API密钥限流窗口
+	apiKeyRateWindow = time.Minute
+	// maxAPIKeyScopes 单个密钥最多允许的会话范围数
+	maxAPIKeyScopes = 100
+)
+
+// ErrInvalidAPIKey API密钥不存在或已吊销
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// APIKeyService 服务间调用的API密钥管理，后端系统凭密钥以绑定的系统账号发送消息
+type APIKeyService struct {
+	mysqlStore     *store.MySQLStore
+	redisStore     *store.RedisStore
+	messageService *MessageService
+	cfg            config.APIKeyConfig
+}
+
+// NewAPIKeyService 创建API密钥服务
+func NewAPIKeyService(mysqlStore *store.MySQLStore, redisStore *store.RedisStore, messageService *MessageService, cfg config.APIKeyConfig) *APIKeyService {
+	return &APIKeyService{
+		mysqlStore:     mysqlStore,
+		redisStore:     redisStore,
+		messageService: messageService,
+		cfg:            cfg,
+	}
+}
+
+// CreateKey 签发API密钥，返回密钥记录和只出现这一次的明文密钥
+func (a *APIKeyService) CreateKey(name, senderID string, conversations []string, rateLimit int) (*model.APIKey, string, error) {
+	if senderID == "" {
+		return nil, "", newServiceError(ErrCodeInvalidRequest, "sender_id is required")
+	}
+	if len(conversations) == 0 || len(conversations) > maxAPIKeyScopes {
+		return nil, "", newServiceError(ErrCodeInvalidRequest, "conversations must contain 1 to %d scopes", maxAPIKeyScopes)
+	}
+	for _, scope := range conversations {
+		if err := model.ValidateAPIKeyScope(scope); err != nil {
+			return nil, "", newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+		}
+	}
+	if rateLimit < 0 {
+		return nil, "", newServiceError(ErrCodeInvalidRequest, "rate_limit must not be negative")
+	}
+	if rateLimit == 0 {
+		rateLimit = a.cfg.DefaultRateLimit
+	}
+
+	id, err := idgen.GenerateIDString()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key ID: %w", err)
+	}
+	raw, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &model.APIKey{
+		ID:            id,
+		Name:          name,
+		SenderID:      senderID,
+		Prefix:        raw[:apiKeyDisplayLength],
+		KeyHash:       hashAPIKey(raw),
+		Conversations: conversations,
+		RateLimit:     rateLimit,
+	}
+	if err := a.mysqlStore.CreateAPIKey(key); err != nil {
+		return nil, "", fmt.Errorf("failed to create api key: %w", err)
+	}
+	return key, raw, nil
+}
+
+// ListKeys 列出所有API密钥
+func (a *APIKeyService) ListKeys() ([]*model.APIKey, error) {
+	keys, err := a.mysqlStore.ListAPIKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeKey 吊销API密钥并立即清除缓存
+func (a *APIKeyService) RevokeKey(id string) error {
+	key, err := a.mysqlStore.GetAPIKey(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return newServiceError(ErrCodeNotFound, "api key %s not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	if err := a.mysqlStore.RevokeAPIKey(id, time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	a.redisStore.DeleteAPIKeyCache(key.KeyHash)
+	return nil
+}
+
+// Authenticate 校验明文密钥，返回有效的密钥记录
+func (a *APIKeyService) Authenticate(raw string) (*model.APIKey, error) {
+	if !strings.HasPrefix(raw, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	hash := hashAPIKey(raw)
+	if key, ok, err := a.redisStore.GetAPIKeyCache(hash); err == nil && ok {
+		return key, nil
+	}
+
+	key, err := a.mysqlStore.GetAPIKeyByHash(hash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	if key.IsRevoked() {
+		return nil, ErrInvalidAPIKey
+	}
+
+	a.redisStore.SetAPIKeyCache(hash, key, a.cfg.CacheTTL)
+	return key, nil
+}
+
+// SendMessage 以密钥绑定的账号向会话发送消息，校验会话范围和每分钟限额，允许紧急优先级
+func (a *APIKeyService) SendMessage(key *model.APIKey, conversationID string, msgType model.MessageType, content string, priority model.MessagePriority) (*model.Message, error) {
+	conversationType, targetID, err := model.ParseConversationID(conversationID)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	if !key.Allows(conversationID) {
+		return nil, newServiceError(ErrCodeForbidden, "api key is not allowed to post to %s", conversationID)
+	}
+
+	count, remaining, err := a.redisStore.IncrAPIKeyUsage(key.ID, apiKeyRateWindow)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check api key rate limit: %w", err)
+	}
+	if count > int64(key.RateLimit) {
+		svcErr := newServiceError(ErrCodeRateLimited, "api key rate limit of %d messages per minute exceeded", key.RateLimit)
+		svcErr.RetryAfter = int64((remaining + time.Second - 1) / time.Second)
+		return nil, svcErr
+	}
+
+	if conversationType == model.ConversationTypeGroup {
+		return a.messageService.SendGroupMessage(key.SenderID, targetID, msgType, content, priority)
+	}
+	return a.messageService.SendPrivateMessage(key.SenderID, targetID, msgType, content, priority)
+}
+
+// generateAPIKey 生成随机的明文密钥
+func generateAPIKey() (string, error) {
+	buf := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// hashAPIKey 计算密钥摘要，数据库中只保存摘要
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestAPIKey_Allows(t *testing.T) {
+	key := &model.APIKey{Conversations: []string{"group:g1", "private:*"}}
+	assert.True(t, key.Allows("group:g1"))
+	assert.False(t, key.Allows("group:g2"))
+	assert.True(t, key.Allows("private:u1"))
+	assert.False(t, key.Allows("invalid"))
+
+	key.Conversations = []string{model.APIKeyScopeAll}
+	assert.True(t, key.Allows("group:g2"))
+
+	assert.NoError(t, model.ValidateAPIKeyScope("group:*"))
+	assert.Error(t, model.ValidateAPIKeyScope("channel:*"))
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	raw, err := generateAPIKey()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, apiKeyPrefix))
+	assert.Len(t, raw, len(apiKeyPrefix)+apiKeyBytes*2)
+
+	other, err := generateAPIKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, raw, other)
+	assert.Len(t, hashAPIKey(raw), 64)
+	assert.NotEqual(t, hashAPIKey(raw), hashAPIKey(other))
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+	"github.com/user/im/pkg/logger"
+)
+
+const (
+	// defaultAuditLimit 审计记录默认查询条数
+	defaultAuditLimit = 50
+	// maxAuditLimit 审计记录单次最多查询条数
+	maxAuditLimit = 500
+)
+
+// AuditService 管理操作审计，记录总是写入日志，有MySQL时同时持久化
+type AuditService struct {
+	mysqlStore *store.MySQLStore
+}
+
+// NewAuditService 创建审计服务，mysqlStore可以为nil
+func NewAuditService(mysqlStore *store.MySQLStore) *AuditService {
+	return &AuditService{mysqlStore: mysqlStore}
+}
+
+// Record 记录一次管理操作
+func (a *AuditService) Record(actor, action, target string, details map[string]string) error {
+	return a.RecordAs(actor, "", action, target, details)
+}
+
+// RecordAs 记录一次管理操作及操作人当时的管理角色
+func (a *AuditService) RecordAs(actor, role, action, target string, details map[string]string) error {
+	logger.Info("Admin action",
+		logger.String("actor", actor),
+		logger.String("role", role),
+		logger.String("action", action),
+		logger.String("target", target),
+		logger.Any("details", details),
+	)
+	if a.mysqlStore == nil {
+		return nil
+	}
+
+	id, err := idgen.GenerateIDString()
+	if err != nil {
+		return fmt.Errorf("failed to generate audit log ID: %w", err)
+	}
+	entry := &model.AuditLog{
+		ID:        id,
+		Actor:     actor,
+		Role:      role,
+		Action:    action,
+		Target:    target,
+		Details:   details,
+		CreatedAt: time.Now(),
+	}
+	if err := a.mysqlStore.SaveAuditLog(entry); err != nil {
+		return fmt.Errorf("failed to save audit log: %w", err)
+	}
+	return nil
+}
+
+// List 按时间倒序查询审计记录
+func (a *AuditService) List(filter store.AuditFilter) ([]*model.AuditLog, error) {
+	if a.mysqlStore == nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "audit log storage requires mysql")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+
+	entries, err := a.mysqlStore.ListAuditLogs(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+	return entries, nil
+}
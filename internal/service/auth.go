@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// maxUserIDLength IM用户ID的最大长度，与存储层的列宽一致
+const maxUserIDLength = 64
+
+// AuthService 身份提供方登录，校验身份提供方的令牌后签发IM令牌
+// 首次登录时创建用户资料，之后每次登录同步名称和邮箱；组或角色声明按配置映射为IM角色
+type AuthService struct {
+	oidc      *OIDCProvider
+	tokens    *TokenService
+	profiles  *ProfileService
+	twoFactor *TwoFactorService
+	cfg       config.OIDCConfig
+}
+
+// NewAuthService 创建登录服务，twoFactor可以为nil
+func NewAuthService(oidc *OIDCProvider, tokens *TokenService, profiles *ProfileService, twoFactor *TwoFactorService, cfg config.OIDCConfig) *AuthService {
+	return &AuthService{
+		oidc:      oidc,
+		tokens:    tokens,
+		profiles:  profiles,
+		twoFactor: twoFactor,
+		cfg:       cfg,
+	}
+}
+
+// OIDCConfig 客户端发起授权码流程需要的参数
+func (a *AuthService) OIDCConfig() (map[string]string, error) {
+	endpoint, err := a.oidc.AuthorizationEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"issuer":                 a.oidc.Issuer(),
+		"client_id":              a.oidc.ClientID(),
+		"authorization_endpoint": endpoint,
+	}, nil
+}
+
+// LoginOIDC 用身份提供方的ID令牌或授权码换取IM令牌
+func (a *AuthService) LoginOIDC(req *model.OIDCLoginRequest) (*model.AuthToken, error) {
+	idToken := req.IDToken
+	if idToken == "" {
+		if req.Code == "" || req.RedirectURI == "" {
+			return nil, newServiceError(ErrCodeInvalidRequest, "id_token or code with redirect_uri is required")
+		}
+		var err error
+		idToken, err = a.oidc.Exchange(req.Code, req.RedirectURI, req.CodeVerifier)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	claims, err := a.oidc.Verify(idToken)
+	if errors.Is(err, ErrInvalidToken) {
+		return nil, newServiceError(ErrCodeUnauthenticated, "invalid identity token")
+	}
+	if err != nil {
+		return nil, err
+	}
+	userID := claimString(claims, a.cfg.UserIDClaim)
+	if userID == "" || len(userID) > maxUserIDLength {
+		return nil, newServiceError(ErrCodeUnauthenticated, "identity token has no valid %s claim", a.cfg.UserIDClaim)
+	}
+
+	// IM令牌同样受两步验证约束
+	if err := a.twoFactor.Authorize(userID, req.TwoFactorCode); err != nil {
+		return nil, err
+	}
+
+	provisioned, err := a.profiles.Provision(userID, claimString(claims, a.cfg.NameClaim), claimString(claims, "email"), claimString(claims, "locale"))
+	if err != nil {
+		return nil, err
+	}
+	roles := mapOIDCRoles(claimStrings(claims, a.cfg.RolesClaim), a.cfg.RoleMapping)
+	token, err := a.tokens.Issue(userID, roles)
+	if err != nil {
+		return nil, err
+	}
+	token.Provisioned = provisioned
+
+	logger.Info("OIDC login",
+		logger.String("user_id", userID),
+		logger.Any("roles", roles),
+		logger.Bool("provisioned", provisioned))
+	return token, nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+	"github.com/user/im/pkg/websocket"
+)
+
+// BandwidthService 连接流量统计
+// 接入节点定期把本地已登录连接新增的收发字节数按分钟累加到Redis，用户在滚动窗口内的合计跨节点可见；
+// 配置了用户流量上限时，超出上限的用户的连接在写协程中限速，窗口内合计回落到上限以下后恢复默认限速
+type BandwidthService struct {
+	redisStore *store.RedisStore
+	manager    *websocket.Manager
+	cfg        config.BandwidthConfig
+	nodeID     string
+
+	// 只在flush中读写，不需要加锁
+	reported  map[string]websocket.Traffic // sessionID -> 已累加到Redis的流量
+	throttled map[string]bool              // sessionID -> 是否已限速
+}
+
+// NewBandwidthService 创建流量统计服务，manager为nil时只提供查询
+func NewBandwidthService(redisStore *store.RedisStore, manager *websocket.Manager, cfg config.BandwidthConfig, nodeID string) *BandwidthService {
+	return &BandwidthService{
+		redisStore: redisStore,
+		manager:    manager,
+		cfg:        cfg,
+		nodeID:     nodeID,
+		reported:   make(map[string]websocket.Traffic),
+		throttled:  make(map[string]bool),
+	}
+}
+
+// Start 按flush_interval定期累加本地连接的流量
+func (b *BandwidthService) Start() {
+	go func() {
+		ticker := time.NewTicker(b.cfg.FlushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			b.flush(time.Now())
+		}
+	}()
+}
+
+// flush 累加各连接上次以来新增的流量，并按用户窗口内的合计调整限速
+// 累加失败的流量保留到下次；已关闭的会话随之移出记录
+func (b *BandwidthService) flush(now time.Time) {
+	reported := make(map[string]websocket.Traffic, len(b.reported))
+	throttled := make(map[string]bool, len(b.throttled))
+	b.manager.ForEachUser(func(userID string, s websocket.Session) {
+		metered, ok := s.(websocket.Metered)
+		if !ok {
+			return
+		}
+		current := metered.Traffic()
+		last := b.reported[s.ID()]
+		deltaIn, deltaOut := current.BytesIn-last.BytesIn, current.BytesOut-last.BytesOut
+		wasThrottled := b.throttled[s.ID()]
+		reported[s.ID()], throttled[s.ID()] = last, wasThrottled
+		// 空闲且未限速的连接无需更新，超出上限的连接需要在窗口滚动后恢复
+		if deltaIn == 0 && deltaOut == 0 && !wasThrottled {
+			return
+		}
+
+		in, out, err := b.redisStore.AddUserTraffic(userID, deltaIn, deltaOut, now, b.cfg.Window)
+		if err != nil {
+			logger.Warn("Failed to record user traffic", logger.String("user_id", userID), logger.ErrorField(err))
+			return
+		}
+		reported[s.ID()] = current
+		metrics.WebSocketBytes.WithLabelValues("in").Add(float64(deltaIn))
+		metrics.WebSocketBytes.WithLabelValues("out").Add(float64(deltaOut))
+
+		over := b.cfg.UserWindowLimit > 0 && in+out > b.cfg.UserWindowLimit
+		if over != wasThrottled {
+			if over {
+				metered.SetRateLimit(b.cfg.ThrottledBytesPerSecond)
+				logger.Warn("Throttling connection over traffic limit",
+					logger.String("user_id", userID),
+					logger.String("connection_id", s.ID()),
+					logger.Int64("window_bytes", in+out))
+			} else {
+				metered.SetRateLimit(b.cfg.MaxBytesPerSecond)
+			}
+			throttled[s.ID()] = over
+			current.RateLimit = metered.Traffic().RateLimit
+		}
+
+		traffic := &model.ConnectionTraffic{
+			ConnectionID: s.ID(),
+			NodeID:       b.nodeID,
+			BytesIn:      current.BytesIn,
+			BytesOut:     current.BytesOut,
+			RateLimit:    current.RateLimit,
+			Throttled:    over,
+			UpdatedAt:    now.Unix(),
+		}
+		if err := b.redisStore.SetConnectionTraffic(userID, traffic, b.cfg.Window); err != nil {
+			logger.Warn("Failed to record connection traffic", logger.String("user_id", userID), logger.ErrorField(err))
+		}
+	})
+
+	count := 0
+	for _, t := range throttled {
+		if t {
+			count++
+		}
+	}
+	metrics.ThrottledSessions.Set(float64(count))
+	b.reported, b.throttled = reported, throttled
+}
+
+// Get 获取用户在窗口内的流量和最近一次连接的流量
+// 连接流量由连接所在节点定期更新，用户下线后保留到窗口结束
+func (b *BandwidthService) Get(userID string) (*model.UserBandwidth, error) {
+	in, out, err := b.redisStore.GetUserTraffic(userID, time.Now(), b.cfg.Window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user traffic: %w", err)
+	}
+	connection, err := b.redisStore.GetConnectionTraffic(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection traffic: %w", err)
+	}
+	return &model.UserBandwidth{
+		UserID:     userID,
+		Window:     int64(b.cfg.Window / time.Second),
+		BytesIn:    in,
+		BytesOut:   out,
+		Limit:      b.cfg.UserWindowLimit,
+		Connection: connection,
+	}, nil
+}
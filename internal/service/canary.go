@@ -0,0 +1,415 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+	"github.com/user/im/pkg/logger"
+	"github.com/user/im/pkg/websocket"
+)
+
+// 合成探测结果
+const (
+	CanaryResultOK      = "ok"
+	CanaryResultTimeout = "timeout"
+	CanaryResultError   = "error"
+)
+
+// canaryContentPrefix 探测消息内容前缀，后接探测ID
+const canaryContentPrefix = "canary probe "
+
+// canaryTransport 探测会话的传输协议名称
+const canaryTransport = "canary"
+
+// Canary 合成探测
+// 每个接入节点在本地登录一个探测用户，定期经正常的发送路径给自己和轮流选出的一个其他接入节点的探测用户发送私聊消息；
+// 目标探测用户收到后确认消息，并经Redis频道通知发起探测的节点，据此统计送达结果和端到端耗时。
+// 只有给自己的探测失败才计入本节点的就绪状态，其他节点的探测结果只导出为监控指标
+type Canary struct {
+	manager    *websocket.Manager
+	redisStore *store.RedisStore
+	cfg        config.CanaryConfig
+	nodeID     string
+	peers      func() []string
+
+	mu       sync.Mutex
+	session  *canarySession
+	pending  *canaryPending
+	failures int
+	probes   map[string]*model.CanaryProbe
+	next     int
+}
+
+// canaryPending 等待送达的探测
+type canaryPending struct {
+	id   string
+	done chan error
+}
+
+// NewCanary 创建合成探测，peers返回其他接入节点的ID，为nil时只探测本节点
+func NewCanary(manager *websocket.Manager, redisStore *store.RedisStore, cfg config.CanaryConfig, nodeID string, peers func() []string) *Canary {
+	metrics.CanaryHealthy.Set(1)
+	return &Canary{
+		manager:    manager,
+		redisStore: redisStore,
+		cfg:        cfg,
+		nodeID:     nodeID,
+		peers:      peers,
+		probes:     make(map[string]*model.CanaryProbe),
+	}
+}
+
+// AuthorizeLogin 拒绝客户端以探测用户登录，否则会顶替探测会话
+func (c *Canary) AuthorizeLogin(req *model.LoginRequest) error {
+	if c.isCanaryUser(req.UserID) {
+		return newServiceError(ErrCodeForbidden, "user ID prefix %s is reserved", c.cfg.UserPrefix)
+	}
+	return nil
+}
+
+// isCanaryUser 用户ID是否为探测用户
+func (c *Canary) isCanaryUser(userID string) bool {
+	return strings.HasPrefix(userID, c.cfg.UserPrefix)
+}
+
+// userID 节点的探测用户ID
+func (c *Canary) userID(nodeID string) string {
+	return c.cfg.UserPrefix + nodeID
+}
+
+// Start 订阅本节点的送达通知并开始定期探测，需在会话绑定回调都注册后调用
+func (c *Canary) Start() {
+	pubsub := c.redisStore.Subscribe(store.CanaryChannel(c.nodeID))
+	go func() {
+		defer pubsub.Close()
+		for msg := range pubsub.Channel() {
+			var probeID string
+			if err := json.Unmarshal([]byte(msg.Payload), &probeID); err != nil {
+				continue
+			}
+			c.complete(probeID, nil)
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(c.cfg.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			c.round()
+		}
+	}()
+}
+
+// Status 探测状态，未启用时为nil
+func (c *Canary) Status() *model.CanaryStatus {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	probes := make(map[string]*model.CanaryProbe, len(c.probes))
+	for target, probe := range c.probes {
+		copied := *probe
+		probes[target] = &copied
+	}
+	return &model.CanaryStatus{
+		Healthy:             c.failures < c.cfg.FailureThreshold,
+		ConsecutiveFailures: c.failures,
+		Probes:              probes,
+	}
+}
+
+// Healthy 本节点给自己的探测是否未连续失败达到阈值，未启用时为true
+func (c *Canary) Healthy() bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failures < c.cfg.FailureThreshold
+}
+
+// round 探测本节点和轮到的其他节点，并清理已下线节点的结果
+func (c *Canary) round() {
+	var peers []string
+	if c.peers != nil {
+		for _, peer := range c.peers() {
+			if peer != c.nodeID {
+				peers = append(peers, peer)
+			}
+		}
+	}
+
+	c.probe(c.nodeID)
+	if len(peers) > 0 {
+		c.next = (c.next + 1) % len(peers)
+		c.probe(peers[c.next])
+	}
+
+	alive := map[string]bool{c.nodeID: true}
+	for _, peer := range peers {
+		alive[peer] = true
+	}
+	c.mu.Lock()
+	for target := range c.probes {
+		if !alive[target] {
+			delete(c.probes, target)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// probe 给目标节点的探测用户发送一条消息并等待送达
+func (c *Canary) probe(target string) {
+	start := time.Now()
+	pending, err := c.send(target)
+	if err != nil {
+		c.record(target, CanaryResultError, 0, err)
+		return
+	}
+
+	result := CanaryResultOK
+	select {
+	case err = <-pending.done:
+		if err != nil {
+			result = CanaryResultError
+		}
+	case <-time.After(c.cfg.Timeout):
+		result, err = CanaryResultTimeout, errors.New("probe was not delivered in time")
+	}
+
+	c.mu.Lock()
+	if c.pending == pending {
+		c.pending = nil
+	}
+	c.mu.Unlock()
+	c.record(target, result, time.Since(start), err)
+}
+
+// send 登记等待送达的探测，并以探测用户的会话上行发送消息帧
+func (c *Canary) send(target string) (*canaryPending, error) {
+	probeID, err := idgen.GenerateIDString()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate probe ID: %w", err)
+	}
+	session := c.ensureSession()
+
+	// 探测用户之间互为联系人，私聊不进入消息请求
+	receiverID := c.userID(target)
+	if target != c.nodeID {
+		if err := c.redisStore.AddContact(receiverID, session.UserID()); err != nil {
+			return nil, fmt.Errorf("failed to add canary contact: %w", err)
+		}
+	}
+	frame, err := json.Marshal(model.WebSocketMessage{
+		Type: model.FrameSendMessage,
+		Data: model.SendMessageRequest{
+			ReceiverID: receiverID,
+			Type:       model.MessageTypeText,
+			Content:    canaryContentPrefix + probeID,
+		},
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 心跳刷新网关模式下探测用户的路由
+	c.manager.Dispatch(session, []byte(`{"type":"heartbeat"}`))
+
+	// 单体模式下发送和推送都在Dispatch中同步完成，需先登记
+	pending := &canaryPending{id: probeID, done: make(chan error, 1)}
+	c.mu.Lock()
+	c.pending = pending
+	c.mu.Unlock()
+	c.manager.Dispatch(session, frame)
+	return pending, nil
+}
+
+// ensureSession 返回本节点探测用户的会话，会话被关闭后重新登录
+func (c *Canary) ensureSession() *canarySession {
+	c.mu.Lock()
+	session := c.session
+	if session != nil && !session.closed.Load() {
+		c.mu.Unlock()
+		return session
+	}
+	session = &canarySession{id: fmt.Sprintf("canary-%s-%d", c.nodeID, time.Now().UnixNano()), canary: c}
+	c.session = session
+	c.mu.Unlock()
+
+	// 探测会话不经过登录校验，直接绑定用户
+	c.manager.Register(session)
+	c.manager.BindUser(c.userID(c.nodeID), session)
+	return session
+}
+
+// complete 结束等待中的探测，probeID为空时结束当前的探测
+func (c *Canary) complete(probeID string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil || (probeID != "" && c.pending.id != probeID) {
+		return
+	}
+	select {
+	case c.pending.done <- err:
+	default:
+	}
+}
+
+// record 记录探测结果，给本节点的探测同时更新就绪状态
+func (c *Canary) record(target, result string, latency time.Duration, err error) {
+	metrics.CanaryProbes.WithLabelValues(target, result).Inc()
+	probe := &model.CanaryProbe{Target: target, Result: result, At: time.Now().Unix()}
+	if result == CanaryResultOK {
+		metrics.CanaryLatencySeconds.WithLabelValues(target).Observe(latency.Seconds())
+		probe.LatencyMs = latency.Milliseconds()
+	} else {
+		probe.Error = err.Error()
+		logger.Warn("Canary probe failed",
+			logger.String("target", target),
+			logger.String("result", result),
+			logger.ErrorField(err))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.probes[target] = probe
+	if target != c.nodeID {
+		return
+	}
+	if result == CanaryResultOK {
+		c.failures = 0
+	} else {
+		c.failures++
+	}
+	healthy := c.failures < c.cfg.FailureThreshold
+	if healthy {
+		metrics.CanaryHealthy.Set(1)
+	} else {
+		metrics.CanaryHealthy.Set(0)
+	}
+	if c.failures == c.cfg.FailureThreshold {
+		logger.Error("Canary probes failed repeatedly, marking node not ready", logger.Int("failures", c.failures))
+	}
+}
+
+// handleFrame 处理推送给探测用户的帧：确认探测消息并通知发起探测的节点，错误帧结束当前的探测
+func (c *Canary) handleFrame(session *canarySession, data []byte) {
+	var frame struct {
+		Type model.FrameType `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return
+	}
+
+	switch frame.Type {
+	case model.FrameNewMessage:
+		var message model.Message
+		if err := json.Unmarshal(frame.Data, &message); err != nil {
+			return
+		}
+		sourceNode, probeID, ok := c.parseProbe(&message)
+		if !ok {
+			return
+		}
+		ack, _ := json.Marshal(model.WebSocketMessage{
+			Type: model.FrameAck,
+			Data: model.AckRequest{MessageID: message.ID, Status: string(model.MessageStatusDelivered)},
+		})
+		c.manager.Dispatch(session, ack)
+		if err := c.redisStore.PublishMessage(store.CanaryChannel(sourceNode), probeID); err != nil {
+			logger.Warn("Failed to publish canary delivery", logger.String("source", sourceNode), logger.ErrorField(err))
+		}
+	case model.FrameError:
+		var reply struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(frame.Data, &reply)
+		c.complete("", fmt.Errorf("probe rejected: %s", reply.Error))
+	}
+}
+
+// parseProbe 解析探测消息的发起节点和探测ID，不是其他探测用户发来的探测消息时返回false
+func (c *Canary) parseProbe(message *model.Message) (string, string, bool) {
+	if !c.isCanaryUser(message.SenderID) || !strings.HasPrefix(message.Content, canaryContentPrefix) {
+		return "", "", false
+	}
+	sourceNode := strings.TrimPrefix(message.SenderID, c.cfg.UserPrefix)
+	probeID := strings.TrimPrefix(message.Content, canaryContentPrefix)
+	if sourceNode == "" || probeID == "" {
+		return "", "", false
+	}
+	return sourceNode, probeID, true
+}
+
+// canarySession 探测用户的进程内会话，推送给它的帧由Canary处理
+type canarySession struct {
+	id     string
+	canary *Canary
+	closed atomic.Bool
+
+	mu     sync.RWMutex
+	userID string
+	caps   model.ClientCapabilities
+}
+
+func (s *canarySession) ID() string { return s.id }
+
+func (s *canarySession) UserID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.userID
+}
+
+func (s *canarySession) SetUserID(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userID = userID
+}
+
+func (s *canarySession) Transport() string { return canaryTransport }
+
+func (s *canarySession) RemoteIP() string { return "" }
+
+func (s *canarySession) Handshake() model.Handshake { return model.Handshake{} }
+
+func (s *canarySession) Capabilities() model.ClientCapabilities {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.caps
+}
+
+func (s *canarySession) SetCapabilities(caps model.ClientCapabilities) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.caps = caps
+}
+
+// SendMessage 异步处理推送的帧，避免在推送路径上访问Redis
+func (s *canarySession) SendMessage(data []byte) error {
+	if s.closed.Load() {
+		return errors.New("canary session closed")
+	}
+	go s.canary.handleFrame(s, data)
+	return nil
+}
+
+// Close 关闭会话，BindUser持有管理器的锁时会调用，异步注销
+func (s *canarySession) Close() {
+	if s.closed.CompareAndSwap(false, true) {
+		go s.canary.manager.Unregister(s)
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func newTestCanary() *Canary {
+	return NewCanary(nil, nil, config.CanaryConfig{FailureThreshold: 2, UserPrefix: "__canary__"}, "n1", nil)
+}
+
+func TestCanary_RecordFailureThreshold(t *testing.T) {
+	c := newTestCanary()
+	assert.True(t, c.Healthy())
+
+	// 其他节点的探测失败不影响本节点的就绪状态
+	c.record("n2", CanaryResultTimeout, 0, errors.New("timeout"))
+	c.record("n1", CanaryResultError, 0, errors.New("rejected"))
+	assert.True(t, c.Healthy())
+	c.record("n1", CanaryResultTimeout, 0, errors.New("timeout"))
+	assert.False(t, c.Healthy())
+	assert.Equal(t, 2, c.Status().ConsecutiveFailures)
+
+	c.record("n1", CanaryResultOK, 30*time.Millisecond, nil)
+	status := c.Status()
+	assert.True(t, status.Healthy)
+	assert.Equal(t, int64(30), status.Probes["n1"].LatencyMs)
+	assert.Equal(t, CanaryResultTimeout, status.Probes["n2"].Result)
+
+	var disabled *Canary
+	assert.True(t, disabled.Healthy())
+	assert.Nil(t, disabled.Status())
+}
+
+func TestCanary_CompleteMatchesProbe(t *testing.T) {
+	c := newTestCanary()
+	pending := &canaryPending{id: "p1", done: make(chan error, 1)}
+	c.pending = pending
+
+	c.complete("p0", nil)
+	assert.Len(t, pending.done, 0)
+	c.complete("p1", nil)
+	assert.NoError(t, <-pending.done)
+
+	// 错误帧结束当前的探测
+	c.complete("", errors.New("rejected"))
+	assert.Error(t, <-pending.done)
+}
+
+func TestCanary_ParseProbe(t *testing.T) {
+	c := newTestCanary()
+	source, probeID, ok := c.parseProbe(&model.Message{SenderID: "__canary__n2", Content: canaryContentPrefix + "123"})
+	assert.True(t, ok)
+	assert.Equal(t, "n2", source)
+	assert.Equal(t, "123", probeID)
+
+	_, _, ok = c.parseProbe(&model.Message{SenderID: "u1", Content: canaryContentPrefix + "123"})
+	assert.False(t, ok)
+	_, _, ok = c.parseProbe(&model.Message{SenderID: "__canary__n2", Content: "hello"})
+	assert.False(t, ok)
+
+	assert.Equal(t, ErrCodeForbidden, errorCode(c.AuthorizeLogin(&model.LoginRequest{UserID: "__canary__n1"})))
+	assert.NoError(t, c.AuthorizeLogin(&model.LoginRequest{UserID: "u1"}))
+}
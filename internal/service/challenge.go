@@ -0,0 +1,298 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+	"github.com/user/im/pkg/logger"
+)
+
+// deviceTokenBytes 设备信任令牌的随机字节数
+const deviceTokenBytes = 32
+
+// Verifier 登录验证服务，部署方据此接入验证码、短信或一次性密码
+type Verifier interface {
+	// Method 验证方式，下发给客户端，如captcha、otp
+	Method() string
+	// Issue 为新的验证做准备（如发送短信），返回下发给客户端的参数
+	Issue(userID, challengeID string) (map[string]string, error)
+	// Verify 校验客户端提交的答案
+	Verify(userID, challengeID, answer string) (bool, error)
+}
+
+// ChallengeService 可疑登录验证
+// 登录时按风险信号评估，信号数达到阈值时下发challenge_required，客户端完成验证后才绑定会话；
+// 通过验证的设备获得信任令牌，令牌有效期内该设备登录不再评估风险。
+// 开启两步验证的用户每次登录都要验证动态码，设备信任令牌不能代替
+type ChallengeService struct {
+	redisStore *store.RedisStore
+	cfg        config.ChallengeConfig
+	verifier   Verifier
+	twoFactor  *TwoFactorService
+}
+
+// NewChallengeService 创建登录验证服务，verifier为nil时不评估登录风险
+func NewChallengeService(redisStore *store.RedisStore, cfg config.ChallengeConfig, verifier Verifier) *ChallengeService {
+	return &ChallengeService{
+		redisStore: redisStore,
+		cfg:        cfg,
+		verifier:   verifier,
+	}
+}
+
+// SetTwoFactor 设置两步验证，已开启两步验证的用户登录时要求动态码
+func (c *ChallengeService) SetTwoFactor(twoFactor *TwoFactorService) {
+	c.twoFactor = twoFactor
+}
+
+// Evaluate 评估登录风险，需要验证时返回下发给客户端的验证，返回nil时登录可以直接完成
+// 两步验证相关的失败都返回业务错误，调用方不能因此放行
+func (c *ChallengeService) Evaluate(sessionID string, req *model.LoginRequest, ip string) (*model.LoginChallenge, error) {
+	if c.twoFactor != nil {
+		challenge, err := c.twoFactorChallenge(sessionID, req, ip)
+		if challenge != nil || err != nil {
+			return challenge, err
+		}
+	}
+	if c.verifier == nil {
+		return nil, nil
+	}
+
+	network := ipNetwork(ip)
+	if req.DeviceID != "" && req.DeviceToken != "" {
+		trusted, err := c.isTrusted(req.UserID, req.DeviceID, req.DeviceToken)
+		if err != nil {
+			return nil, err
+		}
+		if trusted {
+			c.recordSuccess(req.UserID, req.DeviceID, network)
+			return nil, nil
+		}
+	}
+
+	history, err := c.redisStore.GetLoginHistory(req.UserID, req.DeviceID, network)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login history: %w", err)
+	}
+	signals := loginSignals(history, network, c.cfg.FailureThreshold)
+	if len(signals) < c.cfg.MinSignals {
+		c.recordSuccess(req.UserID, req.DeviceID, network)
+		return nil, nil
+	}
+
+	challengeID, err := idgen.GenerateIDString()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate challenge ID: %w", err)
+	}
+	params, err := c.verifier.Issue(req.UserID, challengeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue challenge: %w", err)
+	}
+	return c.issue(challengeID, c.verifier.Method(), sessionID, req, ip, signals, params)
+}
+
+// twoFactorChallenge 已开启两步验证的用户下发动态码验证，必须开启但未开启的账号拒绝登录
+// 两步验证状态未知或验证无法下发时同样拒绝登录，不能因为存储故障绕过
+func (c *ChallengeService) twoFactorChallenge(sessionID string, req *model.LoginRequest, ip string) (*model.LoginChallenge, error) {
+	enabled, err := c.twoFactor.IsEnabled(req.UserID)
+	if err != nil {
+		return nil, twoFactorUnavailable(req.UserID, err)
+	}
+	if !enabled {
+		if c.twoFactor.Required(req.UserID) {
+			return nil, newServiceError(ErrCodeTwoFactorRequired, "two-factor authentication must be enabled for this account")
+		}
+		return nil, nil
+	}
+
+	challengeID, err := idgen.GenerateIDString()
+	if err != nil {
+		return nil, twoFactorUnavailable(req.UserID, err)
+	}
+	challenge, err := c.issue(challengeID, model.ChallengeMethodTOTP, sessionID, req, ip, []string{model.LoginSignalTwoFactor}, nil)
+	if err != nil {
+		return nil, twoFactorUnavailable(req.UserID, err)
+	}
+	return challenge, nil
+}
+
+// twoFactorUnavailable 记录两步验证的内部错误，返回拒绝登录的业务错误
+func twoFactorUnavailable(userID string, err error) error {
+	logger.Error("Failed to require two-factor verification", logger.String("user_id", userID), logger.ErrorField(err))
+	return newServiceError(ErrCodeTwoFactorRequired, "two-factor verification unavailable, try again later")
+}
+
+// issue 保存等待验证的登录并生成下发给客户端的验证
+func (c *ChallengeService) issue(challengeID, method, sessionID string, req *model.LoginRequest, ip string, signals []string, params map[string]string) (*model.LoginChallenge, error) {
+	// 登录令牌和旧的设备令牌不需要保存
+	login := *req
+	login.Token = ""
+	login.DeviceToken = ""
+	pending := &model.PendingChallenge{
+		ChallengeID: challengeID,
+		Method:      method,
+		SessionID:   sessionID,
+		IP:          ip,
+		Signals:     signals,
+		Login:       login,
+	}
+	if err := c.redisStore.SaveChallenge(pending, c.cfg.TTL); err != nil {
+		return nil, fmt.Errorf("failed to save challenge: %w", err)
+	}
+
+	logger.Info("Login challenge required",
+		logger.String("user_id", req.UserID),
+		logger.String("ip", ip),
+		logger.String("method", method),
+		logger.Any("signals", signals))
+	return &model.LoginChallenge{
+		ChallengeID: challengeID,
+		Method:      method,
+		Signals:     signals,
+		ExpiresIn:   int64(c.cfg.TTL / time.Second),
+		Params:      params,
+	}, nil
+}
+
+// Verify 校验客户端提交的答案，通过时返回原登录请求和新的设备信任令牌（未上报设备时为空）
+func (c *ChallengeService) Verify(sessionID string, req *model.VerifyChallengeRequest) (*model.LoginRequest, string, error) {
+	pending, attempts, exists, err := c.redisStore.TakeChallengeAttempt(req.ChallengeID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get challenge: %w", err)
+	}
+	if !exists || pending.SessionID != sessionID {
+		return nil, "", newServiceError(ErrCodeNotFound, "challenge not found or expired")
+	}
+	if attempts > c.cfg.MaxAttempts {
+		c.redisStore.DeleteChallenge(req.ChallengeID)
+		return nil, "", newServiceError(ErrCodeChallengeFailed, "too many attempts, login again")
+	}
+
+	userID := pending.Login.UserID
+	ok, err := c.checkAnswer(pending, req.Answer)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to verify challenge: %w", err)
+	}
+	if !ok {
+		c.RecordFailure(userID)
+		return nil, "", newServiceError(ErrCodeChallengeFailed, "verification failed")
+	}
+	c.redisStore.DeleteChallenge(req.ChallengeID)
+
+	var deviceToken string
+	if deviceID := pending.Login.DeviceID; deviceID != "" {
+		deviceToken, err = c.trustDevice(userID, deviceID)
+		if err != nil {
+			logger.Warn("Failed to trust device", logger.String("user_id", userID), logger.ErrorField(err))
+		}
+	}
+	c.recordSuccess(userID, pending.Login.DeviceID, ipNetwork(pending.IP))
+
+	login := pending.Login
+	return &login, deviceToken, nil
+}
+
+// checkAnswer 按验证方式校验答案，两步验证由本服务校验，其余转给外部验证服务
+func (c *ChallengeService) checkAnswer(pending *model.PendingChallenge, answer string) (bool, error) {
+	userID := pending.Login.UserID
+	if pending.Method == model.ChallengeMethodTOTP {
+		return c.twoFactor.Check(userID, answer)
+	}
+	if c.verifier == nil {
+		return false, nil
+	}
+	return c.verifier.Verify(userID, pending.ChallengeID, answer)
+}
+
+// VerifyFrame 处理客户端提交的verify_challenge帧
+func (c *ChallengeService) VerifyFrame(sessionID string, frame *model.WebSocketMessage) (*model.LoginRequest, string, error) {
+	payload, err := model.DecodeFrame(frame)
+	if err != nil {
+		var verr *model.FrameValidationError
+		if errors.As(err, &verr) {
+			return nil, "", newServiceError(ErrCodeInvalidRequest, "%s", verr.Error())
+		}
+		return nil, "", newServiceError(ErrCodeInvalidRequest, "invalid verify_challenge data")
+	}
+	return c.Verify(sessionID, payload.(*model.VerifyChallengeRequest))
+}
+
+// RecordFailure 记录一次登录验证失败，作为后续登录的风险信号
+func (c *ChallengeService) RecordFailure(userID string) {
+	if _, err := c.redisStore.IncrLoginFailures(userID, c.cfg.FailureWindow); err != nil {
+		logger.Warn("Failed to record login failure", logger.String("user_id", userID), logger.ErrorField(err))
+	}
+}
+
+// recordSuccess 记录成功登录的设备和网段
+func (c *ChallengeService) recordSuccess(userID, deviceID, network string) {
+	if err := c.redisStore.RecordLoginSuccess(userID, deviceID, network, c.cfg.HistoryTTL); err != nil {
+		logger.Warn("Failed to record login", logger.String("user_id", userID), logger.ErrorField(err))
+	}
+}
+
+// isTrusted 校验设备信任令牌
+func (c *ChallengeService) isTrusted(userID, deviceID, token string) (bool, error) {
+	hash, exists, err := c.redisStore.GetTrustedDevice(userID, deviceID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get trusted device: %w", err)
+	}
+	return exists && subtle.ConstantTimeCompare([]byte(hash), []byte(hashDeviceToken(token))) == 1, nil
+}
+
+// trustDevice 为设备签发信任令牌，服务端只保存摘要
+func (c *ChallengeService) trustDevice(userID, deviceID string) (string, error) {
+	buf := make([]byte, deviceTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate device token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	if err := c.redisStore.SetTrustedDevice(userID, deviceID, hashDeviceToken(token), c.cfg.DeviceTrustTTL); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// hashDeviceToken 计算设备信任令牌的摘要
+func hashDeviceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// loginSignals 根据登录记录计算风险信号
+// 新设备和异常IP只对已有登录记录的用户生效，避免新用户首次登录就被要求验证
+func loginSignals(history *store.LoginHistory, network string, failureThreshold int64) []string {
+	signals := []string{}
+	if history.Devices > 0 && !history.KnownDevice {
+		signals = append(signals, model.LoginSignalNewDevice)
+	}
+	if history.Networks > 0 && network != "" && !history.KnownIP {
+		signals = append(signals, model.LoginSignalUnusualIP)
+	}
+	if history.Failures >= failureThreshold {
+		signals = append(signals, model.LoginSignalFailures)
+	}
+	return signals
+}
+
+// ipNetwork IP所在的网段，IPv4取/24，IPv6取/48，无法解析时返回空
+func ipNetwork(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
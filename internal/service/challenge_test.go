@@ -0,0 +1,27 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+func TestLoginSignals(t *testing.T) {
+	// 新用户首次登录没有任何记录，不要求验证
+	assert.Empty(t, loginSignals(&store.LoginHistory{}, "10.0.0.0/24", 5))
+
+	history := &store.LoginHistory{Devices: 2, Networks: 1, Failures: 5}
+	assert.Equal(t, []string{model.LoginSignalNewDevice, model.LoginSignalUnusualIP, model.LoginSignalFailures},
+		loginSignals(history, "10.0.0.0/24", 5))
+
+	history = &store.LoginHistory{Devices: 2, Networks: 1, KnownDevice: true, KnownIP: true, Failures: 4}
+	assert.Empty(t, loginSignals(history, "10.0.0.0/24", 5))
+}
+
+func TestIPNetwork(t *testing.T) {
+	assert.Equal(t, "203.0.113.0/24", ipNetwork("203.0.113.42"))
+	assert.Equal(t, "2001:db8:1::/48", ipNetwork("2001:db8:1:2::1"))
+	assert.Equal(t, "", ipNetwork("not-an-ip"))
+}
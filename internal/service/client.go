@@ -0,0 +1,44 @@
+package service
+
+import (
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// clientCapabilitiesTTL 客户端能力记录的保留时长，每次登录刷新
+const clientCapabilitiesTTL = 7 * 24 * time.Hour
+
+// ClientService 客户端能力登记，登录时写入Redis，业务节点据此为不同版本的客户端调整下发内容
+type ClientService struct {
+	redisStore *store.RedisStore
+}
+
+// NewClientService 创建客户端能力服务
+func NewClientService(redisStore *store.RedisStore) *ClientService {
+	return &ClientService{redisStore: redisStore}
+}
+
+// Bind 用户登录后登记客户端能力
+// 下线时不删除记录，避免覆盖用户在其他节点上的新会话
+func (c *ClientService) Bind(userID string, caps model.ClientCapabilities) {
+	if err := c.redisStore.SetClientCapabilities(userID, caps, clientCapabilitiesTTL); err != nil {
+		logger.Warn("Failed to save client capabilities", logger.String("user_id", userID), logger.ErrorField(err))
+	}
+}
+
+// Get 获取用户最近一次登录的客户端能力
+func (c *ClientService) Get(userID string) (model.ClientCapabilities, bool, error) {
+	return c.redisStore.GetClientCapabilities(userID)
+}
+
+// Supports 判断用户的客户端是否支持可选功能，没有记录或查询失败时按旧客户端处理
+func (c *ClientService) Supports(userID, feature string) bool {
+	caps, ok, err := c.Get(userID)
+	if err != nil || !ok {
+		return false
+	}
+	return caps.Supports(feature)
+}
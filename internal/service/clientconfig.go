@@ -0,0 +1,182 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// featureNamePattern 功能开关名称，配置文件中的名称会被转为小写，这里同样只允许小写
+var featureNamePattern = regexp.MustCompile(`^[a-z0-9_.-]{1,64}$`)
+
+// ClientConfigService 客户端配置下发
+// 配置来自配置文件，功能开关可在Redis中覆盖；覆盖变更后经Redis频道通知所有节点重新加载并推送给本地会话
+type ClientConfigService struct {
+	redisStore *store.RedisStore
+	cfg        config.ClientConfig
+	flags      *FeatureFlagService
+	stickers   *StickerService
+
+	mu       sync.RWMutex
+	current  *model.ClientConfig
+	onChange []func(*model.ClientConfig)
+}
+
+// NewClientConfigService 创建客户端配置服务，启动前使用配置文件中的默认值
+func NewClientConfigService(redisStore *store.RedisStore, cfg config.ClientConfig) *ClientConfigService {
+	return &ClientConfigService{
+		redisStore: redisStore,
+		cfg:        cfg,
+		current:    buildClientConfig(cfg, nil),
+	}
+}
+
+// OnChange 注册配置变化回调，需在Start前调用
+func (c *ClientConfigService) OnChange(fn func(*model.ClientConfig)) {
+	c.onChange = append(c.onChange, fn)
+}
+
+// SetFeatureFlags 设置功能开关，下发给用户的配置附带按用户计算的开关状态
+func (c *ClientConfigService) SetFeatureFlags(flags *FeatureFlagService) {
+	c.flags = flags
+}
+
+// SetStickers 设置表情包，下发给用户的配置附带用户可用的表情包
+func (c *ClientConfigService) SetStickers(stickers *StickerService) {
+	c.stickers = stickers
+}
+
+// ForUser 下发给用户的客户端配置，版本号同时覆盖用户的开关状态和可用表情包
+func (c *ClientConfigService) ForUser(userID string) *model.ClientConfig {
+	current := c.Current()
+	if c.flags == nil && c.stickers == nil {
+		return current
+	}
+	result := *current
+	result.Flags = c.flags.Evaluate(userID)
+	result.StickerPacks = c.stickers.Summaries(userID)
+	result.Version = clientConfigVersion(&result)
+	return &result
+}
+
+// Current 当前的客户端配置
+func (c *ClientConfigService) Current() *model.ClientConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.current
+}
+
+// Start 加载Redis中的覆盖，之后在收到变更通知或定期刷新时重新加载
+func (c *ClientConfigService) Start() {
+	c.reload()
+
+	go func() {
+		pubsub := c.redisStore.Subscribe(store.ClientConfigChannel)
+		defer pubsub.Close()
+		for range pubsub.Channel() {
+			c.reload()
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(c.cfg.RefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			c.reload()
+		}
+	}()
+}
+
+// reload 重新加载功能开关覆盖，配置变化时通知回调
+func (c *ClientConfigService) reload() {
+	overrides, err := c.redisStore.GetClientFeatures()
+	if err != nil {
+		logger.Warn("Failed to load client feature overrides", logger.ErrorField(err))
+		return
+	}
+	next := buildClientConfig(c.cfg, overrides)
+
+	c.mu.Lock()
+	changed := next.Version != c.current.Version
+	if changed {
+		c.current = next
+	}
+	c.mu.Unlock()
+
+	if changed {
+		logger.Info("Client config changed", logger.String("version", next.Version))
+		for _, fn := range c.onChange {
+			fn(next)
+		}
+	}
+}
+
+// SetFeature 覆盖功能开关并通知所有节点
+func (c *ClientConfigService) SetFeature(name string, enabled bool) error {
+	if !featureNamePattern.MatchString(name) {
+		return newServiceError(ErrCodeInvalidRequest, "invalid feature name: %s", name)
+	}
+	if err := c.redisStore.SetClientFeature(name, enabled); err != nil {
+		return fmt.Errorf("failed to set client feature: %w", err)
+	}
+	c.notify()
+	return nil
+}
+
+// ResetFeature 删除功能开关覆盖并通知所有节点
+func (c *ClientConfigService) ResetFeature(name string) error {
+	if !featureNamePattern.MatchString(name) {
+		return newServiceError(ErrCodeInvalidRequest, "invalid feature name: %s", name)
+	}
+	if err := c.redisStore.DeleteClientFeature(name); err != nil {
+		return fmt.Errorf("failed to reset client feature: %w", err)
+	}
+	c.notify()
+	return nil
+}
+
+// notify 通知所有节点重新加载，通知失败时本节点立即生效，其他节点等待定期刷新
+func (c *ClientConfigService) notify() {
+	if err := c.redisStore.PublishMessage(store.ClientConfigChannel, time.Now().Unix()); err != nil {
+		logger.Warn("Failed to publish client config change", logger.ErrorField(err))
+		c.reload()
+	}
+}
+
+// buildClientConfig 合并配置文件和功能开关覆盖，按内容生成版本号
+func buildClientConfig(cfg config.ClientConfig, overrides map[string]bool) *model.ClientConfig {
+	features := make(map[string]bool, len(cfg.Features)+len(overrides))
+	for name, enabled := range cfg.Features {
+		features[name] = enabled
+	}
+	for name, enabled := range overrides {
+		features[name] = enabled
+	}
+
+	result := &model.ClientConfig{
+		HeartbeatInterval: int64(cfg.HeartbeatInterval / time.Second),
+		MaxMessageSize:    cfg.MaxMessageSize,
+		MediaUploadURL:    cfg.MediaUploadURL,
+		Features:          features,
+	}
+	result.Version = clientConfigVersion(result)
+	return result
+}
+
+// clientConfigVersion 按配置内容生成版本号，map按键排序序列化，相同内容得到相同版本号
+func clientConfigVersion(cfg *model.ClientConfig) string {
+	unversioned := *cfg
+	unversioned.Version = ""
+	data, _ := json.Marshal(&unversioned)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
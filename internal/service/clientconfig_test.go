@@ -0,0 +1,28 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+)
+
+func TestBuildClientConfig(t *testing.T) {
+	cfg := config.ClientConfig{
+		HeartbeatInterval: 30 * time.Second,
+		MaxMessageSize:    1024,
+		Features:          map[string]bool{"reactions": false, "e2ee": true},
+	}
+
+	base := buildClientConfig(cfg, nil)
+	assert.Equal(t, int64(30), base.HeartbeatInterval)
+	assert.Equal(t, base.Version, buildClientConfig(cfg, map[string]bool{}).Version)
+
+	overridden := buildClientConfig(cfg, map[string]bool{"reactions": true})
+	assert.True(t, overridden.Features["reactions"])
+	assert.True(t, overridden.Features["e2ee"])
+	assert.NotEqual(t, base.Version, overridden.Version)
+	// 覆盖不修改配置文件中的默认值
+	assert.False(t, cfg.Features["reactions"])
+}
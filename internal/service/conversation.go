@@ -0,0 +1,287 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+const (
+	// maxConversationLabels 每个会话最多的自定义标签数
+	maxConversationLabels = 10
+	// maxConversationLabelLength 单个标签的最大长度（字符）
+	maxConversationLabelLength = 32
+)
+
+// ConversationService 会话列表服务，管理用户对会话的归档、置顶和标签
+type ConversationService struct {
+	mysqlStore *store.MySQLStore
+	redisStore *store.RedisStore
+	officials  *OfficialAccountService
+}
+
+// NewConversationService 创建会话列表服务
+func NewConversationService(mysqlStore *store.MySQLStore, redisStore *store.RedisStore) *ConversationService {
+	return &ConversationService{
+		mysqlStore: mysqlStore,
+		redisStore: redisStore,
+	}
+}
+
+// SetOfficialAccounts 设置公众号服务，置顶的公众号出现在所有用户的会话列表最前面
+func (c *ConversationService) SetOfficialAccounts(officials *OfficialAccountService) {
+	c.officials = officials
+}
+
+// ConversationFilter 会话列表查询条件，指针字段为nil表示不过滤
+type ConversationFilter struct {
+	Archived *bool
+	Pinned   *bool
+	Label    string
+	Offset   int
+	Limit    int
+}
+
+// ConversationSettingsUpdate 会话设置的部分更新，字段为nil表示保持不变
+type ConversationSettingsUpdate struct {
+	Archived *bool
+	Pinned   *bool
+	Labels   *[]string
+}
+
+// ListConversations 获取用户的会话列表，置顶会话在前，其余按最后活跃时间倒序，返回当前页和过滤后的总数
+func (c *ConversationService) ListConversations(userID string, filter ConversationFilter) ([]*model.Conversation, int, error) {
+	recent, err := c.redisStore.GetRecentConversations(userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get recent conversations: %w", err)
+	}
+	groupIDs, err := c.mysqlStore.GetUserGroupIDs(userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get user groups: %w", err)
+	}
+	groupActive, err := c.redisStore.GetGroupLastActive(groupIDs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get group activity: %w", err)
+	}
+	settings, err := c.loadSettings(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var pinnedAccounts []string
+	for _, accountID := range c.officials.PinnedAccounts() {
+		if accountID != userID {
+			pinnedAccounts = append(pinnedAccounts, accountID)
+		}
+	}
+
+	conversations := filterConversations(mergeConversations(recent, groupIDs, groupActive, settings, pinnedAccounts), filter)
+	total := len(conversations)
+	if filter.Offset >= total {
+		return []*model.Conversation{}, total, nil
+	}
+	end := total
+	if filter.Limit > 0 && filter.Offset+filter.Limit < total {
+		end = filter.Offset + filter.Limit
+	}
+	return conversations[filter.Offset:end], total, nil
+}
+
+// UpdateSettings 更新用户对会话的个人设置
+func (c *ConversationService) UpdateSettings(userID, conversationID string, update ConversationSettingsUpdate) (*model.UserConversationSettings, error) {
+	conversationType, targetID, err := model.ParseConversationID(conversationID)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	switch conversationType {
+	case model.ConversationTypePrivate:
+		if targetID == userID {
+			return nil, newServiceError(ErrCodeInvalidRequest, "cannot set up a conversation with yourself")
+		}
+		if update.Pinned != nil && !*update.Pinned && c.officials.IsPinned(targetID) {
+			return nil, newServiceError(ErrCodeInvalidRequest, "official account %s is pinned for all users", targetID)
+		}
+	case model.ConversationTypeGroup:
+		isMember, err := c.mysqlStore.IsGroupMember(targetID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check group membership: %w", err)
+		}
+		if !isMember {
+			return nil, newServiceError(ErrCodeNotMember, "user %s is not a member of group %s", userID, targetID)
+		}
+	}
+
+	all, err := c.loadSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	settings := &model.UserConversationSettings{UserID: userID, ConversationID: conversationID}
+	if existing, ok := all[conversationID]; ok {
+		settings = existing
+	}
+
+	if update.Archived != nil {
+		settings.Archived = *update.Archived
+	}
+	if update.Pinned != nil && *update.Pinned != settings.Pinned {
+		settings.Pinned = *update.Pinned
+		settings.PinnedAt = 0
+		if settings.Pinned {
+			settings.PinnedAt = time.Now().Unix()
+		}
+	}
+	if update.Labels != nil {
+		labels, err := normalizeLabels(*update.Labels)
+		if err != nil {
+			return nil, err
+		}
+		settings.Labels = labels
+	}
+	settings.UpdatedAt = time.Now()
+
+	if err := c.mysqlStore.SaveConversationSettings(settings); err != nil {
+		return nil, fmt.Errorf("failed to save conversation settings: %w", err)
+	}
+	c.redisStore.DeleteConversationSettingsCache(userID)
+	return settings, nil
+}
+
+// loadSettings 获取用户的全部会话设置，优先读取Redis缓存
+func (c *ConversationService) loadSettings(userID string) (map[string]*model.UserConversationSettings, error) {
+	list, ok, err := c.redisStore.GetConversationSettingsCache(userID)
+	if err != nil || !ok {
+		list, err = c.mysqlStore.GetConversationSettings(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get conversation settings: %w", err)
+		}
+		c.redisStore.SetConversationSettingsCache(userID, list)
+	}
+
+	settings := make(map[string]*model.UserConversationSettings, len(list))
+	for _, s := range list {
+		settings[s.ConversationID] = s
+	}
+	return settings, nil
+}
+
+// mergeConversations 合并最近私聊、已加入群组、有个人设置的会话和置顶的公众号，已退出群组的设置被忽略
+// 置顶的公众号按pinnedAccounts的顺序排在用户置顶的会话之前
+func mergeConversations(recent map[string]int64, groupIDs []string, groupActive map[string]int64, settings map[string]*model.UserConversationSettings, pinnedAccounts []string) []*model.Conversation {
+	active := make(map[string]int64, len(recent)+len(groupIDs)+len(pinnedAccounts))
+	for id, ts := range recent {
+		active[id] = ts
+	}
+	for _, groupID := range groupIDs {
+		active[model.ConversationID(model.ConversationTypeGroup, groupID)] = groupActive[groupID]
+	}
+	officialRank := make(map[string]int, len(pinnedAccounts))
+	for i, accountID := range pinnedAccounts {
+		id := model.ConversationID(model.ConversationTypePrivate, accountID)
+		officialRank[id] = i
+		if _, ok := active[id]; !ok {
+			active[id] = 0
+		}
+	}
+	// 置顶或打标签但已滑出最近列表的私聊仍保留
+	for id := range settings {
+		if _, ok := active[id]; ok {
+			continue
+		}
+		if t, _, err := model.ParseConversationID(id); err == nil && t == model.ConversationTypePrivate {
+			active[id] = 0
+		}
+	}
+
+	conversations := make([]*model.Conversation, 0, len(active))
+	for id, ts := range active {
+		conversationType, targetID, err := model.ParseConversationID(id)
+		if err != nil {
+			continue
+		}
+		conv := &model.Conversation{
+			ID:           id,
+			Type:         conversationType,
+			TargetID:     targetID,
+			LastActiveAt: ts,
+			Labels:       []string{},
+		}
+		if s, ok := settings[id]; ok {
+			conv.Archived = s.Archived
+			conv.Pinned = s.Pinned
+			conv.PinnedAt = s.PinnedAt
+			if s.Labels != nil {
+				conv.Labels = s.Labels
+			}
+		}
+		if _, ok := officialRank[id]; ok {
+			conv.Pinned = true
+			conv.Official = true
+		}
+		conversations = append(conversations, conv)
+	}
+
+	sort.Slice(conversations, func(i, j int) bool {
+		a, b := conversations[i], conversations[j]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		if a.Official != b.Official {
+			return a.Official
+		}
+		if a.Official {
+			return officialRank[a.ID] < officialRank[b.ID]
+		}
+		if a.Pinned && a.PinnedAt != b.PinnedAt {
+			return a.PinnedAt > b.PinnedAt
+		}
+		if a.LastActiveAt != b.LastActiveAt {
+			return a.LastActiveAt > b.LastActiveAt
+		}
+		return a.ID < b.ID
+	})
+	return conversations
+}
+
+// filterConversations 按归档、置顶状态和标签过滤会话
+func filterConversations(conversations []*model.Conversation, filter ConversationFilter) []*model.Conversation {
+	filtered := conversations[:0]
+	for _, conv := range conversations {
+		if filter.Archived != nil && conv.Archived != *filter.Archived {
+			continue
+		}
+		if filter.Pinned != nil && conv.Pinned != *filter.Pinned {
+			continue
+		}
+		if filter.Label != "" && !conv.HasLabel(filter.Label) {
+			continue
+		}
+		filtered = append(filtered, conv)
+	}
+	return filtered
+}
+
+// normalizeLabels 去除标签首尾空白并去重，校验数量和长度
+func normalizeLabels(labels []string) ([]string, error) {
+	seen := make(map[string]bool, len(labels))
+	normalized := make([]string, 0, len(labels))
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" || seen[label] {
+			continue
+		}
+		if utf8.RuneCountInString(label) > maxConversationLabelLength {
+			return nil, newServiceError(ErrCodeInvalidRequest, "label exceeds %d characters", maxConversationLabelLength)
+		}
+		seen[label] = true
+		normalized = append(normalized, label)
+	}
+	if len(normalized) > maxConversationLabels {
+		return nil, newServiceError(ErrCodeInvalidRequest, "at most %d labels per conversation", maxConversationLabels)
+	}
+	return normalized, nil
+}
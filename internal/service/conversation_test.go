@@ -0,0 +1,77 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func conversationIDs(conversations []*model.Conversation) []string {
+	ids := make([]string, 0, len(conversations))
+	for _, c := range conversations {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestMergeConversations(t *testing.T) {
+	recent := map[string]int64{"private:u2": 100, "private:u3": 300}
+	groupIDs := []string{"g1"}
+	groupActive := map[string]int64{"g1": 200}
+	settings := map[string]*model.UserConversationSettings{
+		"private:u3": {ConversationID: "private:u3", Archived: true, Labels: []string{"work"}},
+		"private:u4": {ConversationID: "private:u4", Pinned: true, PinnedAt: 10},
+		"group:g9":   {ConversationID: "group:g9", Pinned: true, PinnedAt: 20}, // 已退出的群
+	}
+
+	conversations := mergeConversations(recent, groupIDs, groupActive, settings, nil)
+	assert.Equal(t, []string{"private:u4", "private:u3", "group:g1", "private:u2"}, conversationIDs(conversations))
+
+	archived := true
+	filtered := filterConversations(mergeConversations(recent, groupIDs, groupActive, settings, nil), ConversationFilter{Archived: &archived})
+	assert.Equal(t, []string{"private:u3"}, conversationIDs(filtered))
+
+	filtered = filterConversations(mergeConversations(recent, groupIDs, groupActive, settings, nil), ConversationFilter{Label: "work"})
+	assert.Equal(t, []string{"private:u3"}, conversationIDs(filtered))
+
+	pinned := false
+	filtered = filterConversations(mergeConversations(recent, groupIDs, groupActive, settings, nil), ConversationFilter{Pinned: &pinned})
+	assert.Equal(t, []string{"private:u3", "group:g1", "private:u2"}, conversationIDs(filtered))
+
+	// 置顶的公众号排在用户置顶的会话之前，没有往来的公众号也出现
+	conversations = mergeConversations(recent, groupIDs, groupActive, settings, []string{"news", "u2"})
+	assert.Equal(t, []string{"private:news", "private:u2", "private:u4", "private:u3", "group:g1"}, conversationIDs(conversations))
+	assert.True(t, conversations[0].Pinned)
+	assert.True(t, conversations[0].Official)
+	assert.False(t, conversations[2].Official)
+}
+
+func TestNormalizeLabels(t *testing.T) {
+	labels, err := normalizeLabels([]string{" work ", "work", "", "家人"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"work", "家人"}, labels)
+
+	_, err = normalizeLabels([]string{strings.Repeat("a", maxConversationLabelLength+1)})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	many := make([]string, 0, maxConversationLabels+1)
+	for i := 0; i <= maxConversationLabels; i++ {
+		many = append(many, strings.Repeat("x", i+1))
+	}
+	_, err = normalizeLabels(many)
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
+
+func TestParseConversationID(t *testing.T) {
+	conversationType, targetID, err := model.ParseConversationID("group:g1")
+	assert.NoError(t, err)
+	assert.Equal(t, model.ConversationTypeGroup, conversationType)
+	assert.Equal(t, "g1", targetID)
+
+	_, _, err = model.ParseConversationID("private:")
+	assert.Error(t, err)
+	_, _, err = model.ParseConversationID("channel:c1")
+	assert.Error(t, err)
+}
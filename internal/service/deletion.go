@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// DeleteMessage 删除消息，scope为me时仅对自己隐藏，为everyone时由发送者将消息改为墓碑
+func (s *MessageService) DeleteMessage(userID, messageID string, scope model.DeleteScope) error {
+	message, err := s.storeBackend.GetMessage(messageID)
+	if err != nil {
+		return newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+
+	ok, err := s.canAccessMessage(userID, message)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+
+	now := time.Now().Unix()
+	event := model.MessageDeletedEvent{
+		MessageID: messageID,
+		Scope:     scope,
+		DeletedBy: userID,
+		DeletedAt: now,
+	}
+
+	switch scope {
+	case model.DeleteScopeMe:
+		if err := s.storeBackend.MarkMessageDeleted(userID, messageID); err != nil {
+			return fmt.Errorf("failed to mark message deleted: %w", err)
+		}
+		s.removeStars(userID, messageID)
+		// 同步给自己的其他设备
+		s.deliverer.SendToUser(userID, deletedFrame(event))
+		return nil
+	case model.DeleteScopeEveryone:
+		if message.SenderID != userID {
+			return newServiceError(ErrCodeForbidden, "only the sender can delete a message for everyone")
+		}
+		if message.IsDeleted() {
+			return nil
+		}
+		if err := s.storeBackend.TombstoneMessage(messageID, now); err != nil {
+			return fmt.Errorf("failed to tombstone message: %w", err)
+		}
+		s.lookup.invalidate(messageID)
+		if message.IsThreadReply() {
+			s.updateThreadSummary(message.ThreadID, -1, 0)
+		}
+		s.mediaStorage.Release(message)
+		s.removeStars("", messageID)
+		event.GroupID = message.GroupID
+		event.ReceiverID = message.ReceiverID
+		if err := s.notifyParticipants(message, deletedFrame(event)); err != nil {
+			return err
+		}
+		if message.IsGroupMessage() {
+			s.publishSystemMessage(message.GroupID, model.SystemEventMessageRecalled, map[string]string{"user": userID, "message_id": messageID})
+		}
+		return nil
+	default:
+		return newServiceError(ErrCodeInvalidRequest, "invalid delete scope: %s", scope)
+	}
+}
+
+// PurgeMessage 物理删除消息，用于管理员清理和数据保留策略
+func (s *MessageService) PurgeMessage(messageID string) error {
+	// 物理删除前读取消息，未删除的话题回复需要从根消息的回复数中减去，媒体消息减少文件的引用
+	message, _ := s.storeBackend.GetMessage(messageID)
+	if err := s.storeBackend.PurgeMessage(messageID); err != nil {
+		return fmt.Errorf("failed to purge message: %w", err)
+	}
+	s.lookup.invalidate(messageID)
+	if message != nil && !message.IsDeleted() {
+		if message.IsThreadReply() {
+			s.updateThreadSummary(message.ThreadID, -1, 0)
+		}
+		s.mediaStorage.Release(message)
+	}
+	return nil
+}
+
+// removeStars 删除消息的收藏，userID为空时删除所有用户的收藏；物理删除时由存储在同一事务中删除
+func (s *MessageService) removeStars(userID, messageID string) {
+	if s.mysqlStore == nil {
+		return
+	}
+	var err error
+	if userID != "" {
+		err = s.mysqlStore.UnstarMessage(userID, messageID)
+	} else {
+		err = s.mysqlStore.DeleteMessageStars(messageID)
+	}
+	if err != nil {
+		logger.Warn("Failed to remove message stars", logger.String("message_id", messageID), logger.ErrorField(err))
+	}
+}
+
+// canAccessMessage 判断用户是否为消息的参与者
+func (s *MessageService) canAccessMessage(userID string, message *model.Message) (bool, error) {
+	if message.IsPrivateMessage() {
+		return message.SenderID == userID || message.ReceiverID == userID, nil
+	}
+	if s.mysqlStore == nil {
+		return message.SenderID == userID, nil
+	}
+	isMember, err := s.mysqlStore.IsGroupMember(message.GroupID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check group membership: %w", err)
+	}
+	return isMember, nil
+}
+
+// applyDeletions 按请求者过滤消息：自己删除的消息去掉，对所有人删除的消息替换为墓碑，根消息的回复统计以存储为准
+func (s *MessageService) applyDeletions(userID string, messages []*model.Message) ([]*model.Message, error) {
+	if len(messages) == 0 {
+		return messages, nil
+	}
+
+	ids := make([]string, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.ID)
+	}
+	deleted, err := s.storeBackend.GetDeletedMessageIDs(userID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deleted messages: %w", err)
+	}
+	// 离线列表和缓存中可能是删除前的副本，以存储中的墓碑为准
+	tombstones, err := s.storeBackend.GetTombstones(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tombstones: %w", err)
+	}
+
+	filtered := make([]*model.Message, 0, len(messages))
+	for _, m := range messages {
+		if deleted[m.ID] {
+			continue
+		}
+		if deletedAt, ok := tombstones[m.ID]; ok && !m.IsDeleted() {
+			tombstone := *m
+			tombstone.Content = ""
+			tombstone.DeletedAt = deletedAt
+			m = &tombstone
+		}
+		filtered = append(filtered, m)
+	}
+
+	filtered, err = s.applyThreadSummaries(filtered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get thread summaries: %w", err)
+	}
+	return s.applyPollTallies(s.applyGroupEventRSVPs(filtered)), nil
+}
+
+// deletedFrame 构造消息删除通知帧
+func deletedFrame(event model.MessageDeletedEvent) model.WebSocketMessage {
+	return model.WebSocketMessage{
+		Type:      model.FrameMessageDeleted,
+		Data:      event,
+		Timestamp: time.Now().Unix(),
+		MessageID: event.MessageID,
+	}
+}
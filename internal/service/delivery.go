@@ -0,0 +1,210 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// DeliveryRoute 消息到达接收者的方式
+type DeliveryRoute string
+
+const (
+	DeliveryLocal   DeliveryRoute = "local"   // 接收者连接在本节点，直接推送到会话
+	DeliveryRelay   DeliveryRoute = "relay"   // 接收者连接在其他接入节点，经网关推送主题转发
+	DeliveryOffline DeliveryRoute = "offline" // 接收者不在线，交给离线策略
+)
+
+// 内置离线策略名称
+const (
+	OfflineQueue = "queue" // 写入离线消息主题和Redis离线队列，接收者上线后同步
+	OfflinePush  = "push"  // 外部推送通道（如APNs、FCM），接入后以该名称注册
+)
+
+// OfflineStrategy 接收者不在线时对私聊消息的处理
+type OfflineStrategy func(userID string, message *model.Message) error
+
+// OfflineHint 外部推送通道（如APNs、FCM）向不在线的用户发送推送提示，frame为附带push的推送帧
+// 群消息不进入离线队列，不在线的成员只通过推送提示得知新消息，上线后从历史记录同步
+type OfflineHint func(userID string, frame model.WebSocketMessage) error
+
+// onlineStrategy 在线投递方式
+type onlineStrategy struct {
+	route     DeliveryRoute
+	deliverer Deliverer
+}
+
+// offlineStrategy 带名称的离线策略
+type offlineStrategy struct {
+	name string
+	fn   OfflineStrategy
+}
+
+// DeliveryRouter 按接收者当前状态选择投递方式
+// 依次判断各在线方式能否送达接收者，都不能送达时按注册顺序执行离线策略；
+// 同时实现Deliverer，群推送和其他下发按同样的顺序选择在线方式
+type DeliveryRouter struct {
+	online  []onlineStrategy
+	offline []offlineStrategy
+	hint    OfflineHint
+}
+
+// NewDeliveryRouter 创建投递路由，未添加在线方式时所有接收者都视为离线
+func NewDeliveryRouter() *DeliveryRouter {
+	return &DeliveryRouter{}
+}
+
+// Online 添加在线投递方式，按添加顺序判断
+func (r *DeliveryRouter) Online(route DeliveryRoute, deliverer Deliverer) *DeliveryRouter {
+	r.online = append(r.online, onlineStrategy{route: route, deliverer: deliverer})
+	return r
+}
+
+// Offline 添加离线策略，同名策略原位替换，fn为nil时移除；应在开始投递前注册
+func (r *DeliveryRouter) Offline(name string, fn OfflineStrategy) {
+	for i, strategy := range r.offline {
+		if strategy.name != name {
+			continue
+		}
+		if fn == nil {
+			r.offline = append(r.offline[:i:i], r.offline[i+1:]...)
+		} else {
+			r.offline[i].fn = fn
+		}
+		return
+	}
+	if fn != nil {
+		r.offline = append(r.offline, offlineStrategy{name: name, fn: fn})
+	}
+}
+
+// SetOfflineHint 设置向不在线的群成员发送推送提示的通道，fn为nil时移除；应在开始投递前设置
+func (r *DeliveryRouter) SetOfflineHint(fn OfflineHint) {
+	r.hint = fn
+}
+
+// Hints 是否设置了推送提示通道
+func (r *DeliveryRouter) Hints() bool {
+	return r.hint != nil
+}
+
+// Hint 经推送提示通道通知不在线的用户，未设置通道时不做处理
+func (r *DeliveryRouter) Hint(userID string, frame model.WebSocketMessage) error {
+	if r.hint == nil {
+		return nil
+	}
+	return r.hint(userID, frame)
+}
+
+// Wrap 返回在线方式经wrap包装的副本，如统计推送结果；副本不带离线策略
+func (r *DeliveryRouter) Wrap(wrap func(Deliverer) Deliverer) *DeliveryRouter {
+	wrapped := NewDeliveryRouter()
+	for _, strategy := range r.online {
+		wrapped.Online(strategy.route, wrap(strategy.deliverer))
+	}
+	return wrapped
+}
+
+// Resolve 判断接收者当前的投递方式
+func (r *DeliveryRouter) Resolve(userID string) DeliveryRoute {
+	_, route := r.resolve(userID)
+	return route
+}
+
+// resolve 找到第一个能送达接收者的在线方式
+func (r *DeliveryRouter) resolve(userID string) (Deliverer, DeliveryRoute) {
+	for _, strategy := range r.online {
+		if strategy.deliverer.IsOnline(userID) {
+			return strategy.deliverer, strategy.route
+		}
+	}
+	return nil, DeliveryOffline
+}
+
+// Push 接收者在线时推送一帧并返回使用的方式，不在线时返回DeliveryOffline，不执行离线策略
+func (r *DeliveryRouter) Push(userID string, frame interface{}) (DeliveryRoute, error) {
+	deliverer, route := r.resolve(userID)
+	if deliverer == nil {
+		return DeliveryOffline, nil
+	}
+	if err := deliverer.SendToUser(userID, frame); err != nil {
+		return route, err
+	}
+	metrics.MessageDeliveries.WithLabelValues(string(route)).Inc()
+	return route, nil
+}
+
+// Deliver 投递一条私聊消息：接收者在线时推送frame，不在线或推送失败时依次执行离线策略
+func (r *DeliveryRouter) Deliver(userID string, message *model.Message, frame interface{}) (DeliveryRoute, error) {
+	route, err := r.Push(userID, frame)
+	if err == nil && route != DeliveryOffline {
+		return route, nil
+	}
+	if err != nil {
+		// 判断在线后连接已断开，按离线处理，接收者重连后从离线队列同步
+		logger.Warn("Failed to push message, falling back to offline delivery",
+			logger.String("user_id", userID), logger.String("route", string(route)), logger.ErrorField(err))
+	}
+
+	metrics.MessageDeliveries.WithLabelValues(string(DeliveryOffline)).Inc()
+	for _, strategy := range r.offline {
+		if err := strategy.fn(userID, message); err != nil {
+			return DeliveryOffline, err
+		}
+	}
+	return DeliveryOffline, nil
+}
+
+// IsOnline 判断用户能否经任一在线方式送达
+func (r *DeliveryRouter) IsOnline(userID string) bool {
+	return r.Resolve(userID) != DeliveryOffline
+}
+
+// SendToUser 经第一个能送达的在线方式发送，都不能送达时交给最后一个在线方式返回其错误
+func (r *DeliveryRouter) SendToUser(userID string, message interface{}) error {
+	deliverer, _ := r.resolve(userID)
+	if deliverer == nil {
+		if len(r.online) == 0 {
+			return fmt.Errorf("user %s not connected", userID)
+		}
+		deliverer = r.online[len(r.online)-1].deliverer
+	}
+	return deliverer.SendToUser(userID, message)
+}
+
+// BroadcastToGroup 按在线方式的顺序划分接收者，每种方式只推送给它能送达的用户，其余交给最后一个方式
+func (r *DeliveryRouter) BroadcastToGroup(userIDs []string, message interface{}) {
+	remaining := userIDs
+	for i, strategy := range r.online {
+		if len(remaining) == 0 {
+			return
+		}
+		if i == len(r.online)-1 {
+			strategy.deliverer.BroadcastToGroup(remaining, message)
+			return
+		}
+		var reachable, rest []string
+		for _, userID := range remaining {
+			if strategy.deliverer.IsOnline(userID) {
+				reachable = append(reachable, userID)
+			} else {
+				rest = append(rest, userID)
+			}
+		}
+		if len(reachable) > 0 {
+			strategy.deliverer.BroadcastToGroup(reachable, message)
+		}
+		remaining = rest
+	}
+}
+
+// DisconnectUser 经用户所在的在线方式断开会话，用户不在线时不做处理
+func (r *DeliveryRouter) DisconnectUser(userID string, message interface{}) error {
+	deliverer, _ := r.resolve(userID)
+	if deliverer == nil {
+		return nil
+	}
+	return deliverer.DisconnectUser(userID, message)
+}
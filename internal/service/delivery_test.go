@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+// testDeliverer 记录推送的在线方式
+type testDeliverer struct {
+	name    string
+	online  map[string]bool
+	fail    error
+	history *[]string
+}
+
+func newTestDeliverer(name string, history *[]string, users ...string) *testDeliverer {
+	d := &testDeliverer{online: make(map[string]bool), history: history, name: name}
+	for _, userID := range users {
+		d.online[userID] = true
+	}
+	return d
+}
+
+func (d *testDeliverer) IsOnline(userID string) bool { return d.online[userID] }
+func (d *testDeliverer) SendToUser(userID string, message interface{}) error {
+	if d.fail != nil {
+		return d.fail
+	}
+	if !d.online[userID] {
+		return errors.New("user not connected")
+	}
+	*d.history = append(*d.history, d.name+":"+userID)
+	return nil
+}
+func (d *testDeliverer) BroadcastToGroup(userIDs []string, message interface{}) {
+	for _, userID := range userIDs {
+		*d.history = append(*d.history, d.name+":"+userID)
+	}
+}
+func (d *testDeliverer) DisconnectUser(userID string, message interface{}) error {
+	*d.history = append(*d.history, d.name+":close:"+userID)
+	return nil
+}
+
+func TestDeliveryRouter_Routes(t *testing.T) {
+	var history []string
+	local := newTestDeliverer("local", &history, "alice")
+	relay := newTestDeliverer("relay", &history, "alice", "bob")
+	router := NewDeliveryRouter().Online(DeliveryLocal, local).Online(DeliveryRelay, relay)
+	var queued []string
+	router.Offline(OfflineQueue, func(userID string, message *model.Message) error {
+		queued = append(queued, userID+":"+message.ID)
+		return nil
+	})
+
+	assert.Equal(t, DeliveryLocal, router.Resolve("alice"))
+	assert.Equal(t, DeliveryRelay, router.Resolve("bob"))
+	assert.Equal(t, DeliveryOffline, router.Resolve("carol"))
+
+	for _, userID := range []string{"alice", "bob", "carol"} {
+		_, err := router.Deliver(userID, &model.Message{ID: "m-" + userID}, "frame")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"local:alice", "relay:bob"}, history)
+	assert.Equal(t, []string{"carol:m-carol"}, queued)
+
+	// Push不执行离线策略
+	route, err := router.Push("carol", "frame")
+	assert.NoError(t, err)
+	assert.Equal(t, DeliveryOffline, route)
+	assert.Len(t, queued, 1)
+
+	// 群推送每个用户只经一种方式
+	history = nil
+	router.BroadcastToGroup([]string{"alice", "bob", "carol"}, "frame")
+	assert.Equal(t, []string{"local:alice", "relay:bob", "relay:carol"}, history)
+
+	history = nil
+	assert.NoError(t, router.DisconnectUser("bob", nil))
+	assert.NoError(t, router.DisconnectUser("carol", nil))
+	assert.Equal(t, []string{"relay:close:bob"}, history)
+}
+
+func TestDeliveryRouter_PushFailureFallsBackOffline(t *testing.T) {
+	var history []string
+	local := newTestDeliverer("local", &history, "alice")
+	local.fail = errors.New("connection closed")
+	router := NewDeliveryRouter().Online(DeliveryLocal, local)
+	var queued int
+	router.Offline(OfflineQueue, func(userID string, message *model.Message) error {
+		queued++
+		return nil
+	})
+	router.Offline(OfflinePush, func(userID string, message *model.Message) error {
+		return errors.New("push unavailable")
+	})
+
+	route, err := router.Deliver("alice", &model.Message{ID: "m1"}, "frame")
+	assert.EqualError(t, err, "push unavailable")
+	assert.Equal(t, DeliveryOffline, route)
+	assert.Equal(t, 1, queued)
+
+	// 移除策略后不再执行
+	router.Offline(OfflinePush, nil)
+	_, err = router.Deliver("alice", &model.Message{ID: "m2"}, "frame")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, queued)
+}
+
+func TestDeliveryRouter_Wrap(t *testing.T) {
+	var history []string
+	local := newTestDeliverer("local", &history, "alice")
+	router := NewDeliveryRouter().Online(DeliveryLocal, local)
+	router.Offline(OfflineQueue, func(userID string, message *model.Message) error { return nil })
+
+	stats := &StatsService{}
+	wrapped := router.Wrap(stats.Deliverer)
+	assert.NoError(t, wrapped.SendToUser("alice", "frame"))
+	assert.Error(t, wrapped.SendToUser("bob", "frame"))
+	assert.Equal(t, int64(2), stats.deliveries.Load())
+	assert.Equal(t, int64(1), stats.failures.Load())
+	assert.Empty(t, wrapped.offline)
+
+	assert.Error(t, NewDeliveryRouter().SendToUser("alice", "frame"))
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// digestKey 摘要按接收者和群区分
+type digestKey struct {
+	userID  string
+	groupID string
+}
+
+// groupDigests 按接收者和群缓冲摘要窗口内不在线成员未逐条提示的群消息，每个窗口由一个定时器统一发出
+// 缓冲在广播消息的节点内存中，同一群的消息由多个节点广播时每个节点各自合并；
+// 节点退出时未发出的摘要丢失，消息本身仍可从历史记录拉取
+type groupDigests struct {
+	mu      sync.Mutex
+	window  time.Duration
+	pending map[digestKey]*model.GroupDigest
+	busy    func(message *model.Message) bool
+	flush   func(userID string, digest *model.GroupDigest)
+}
+
+// newGroupDigests 创建摘要缓冲，busy判断群是否处于活跃状态，flush在窗口结束时发出摘要
+func newGroupDigests(window time.Duration, busy func(message *model.Message) bool, flush func(userID string, digest *model.GroupDigest)) *groupDigests {
+	return &groupDigests{
+		window:  window,
+		pending: make(map[digestKey]*model.GroupDigest),
+		busy:    busy,
+		flush:   flush,
+	}
+}
+
+// add 把消息计入用户在该群的摘要
+func (d *groupDigests) add(userID string, message *model.Message) {
+	key := digestKey{userID: userID, groupID: message.GroupID}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	digest, exists := d.pending[key]
+	if !exists {
+		digest = &model.GroupDigest{
+			GroupID:        message.GroupID,
+			FirstMessageID: message.ID,
+			Since:          message.Timestamp,
+		}
+		d.pending[key] = digest
+	}
+	digest.Count++
+	digest.LastMessage = message
+}
+
+// release 发出当前窗口内的所有摘要，下一条消息开始新的窗口
+func (d *groupDigests) release() {
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = make(map[digestKey]*model.GroupDigest)
+	d.mu.Unlock()
+
+	for key, digest := range pending {
+		d.flush(key.userID, digest)
+	}
+}
+
+// StartGroupDigests 每个摘要窗口发出一次缓冲的摘要，未配置摘要窗口时不启动
+func (s *MessageService) StartGroupDigests() {
+	if s.digests == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.digests.window)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.digests.release()
+		}
+	}()
+}
+
+// hintOffline 经推送提示通道通知不在线的成员，在线成员已收到推送帧，不受摘要影响
+// 按偏好合并为摘要的成员在每个窗口内只收到一条摘要提示，其余成员逐条收到提示；未设置推送提示通道时不做处理
+func (s *MessageService) hintOffline(userIDs []string, message *model.Message) {
+	if s.delivery == nil || !s.delivery.Hints() {
+		return
+	}
+	var offline []string
+	for _, userID := range userIDs {
+		if !s.delivery.IsOnline(userID) {
+			offline = append(offline, userID)
+		}
+	}
+
+	immediate := s.digestRecipients(offline, message)
+	if len(immediate) == 0 {
+		return
+	}
+	quiet := s.quietUsers(immediate, message)
+	for lang, ids := range s.groupByLanguage(immediate) {
+		localized := message
+		if message.IsSystem() {
+			localized = s.localize(message, lang)
+		}
+		for _, userID := range ids {
+			frame := groupMessageFrame(localized)
+			if quiet[userID] {
+				frame = quietGroupMessageFrame(localized)
+			}
+			if err := s.delivery.Hint(userID, s.notify(frame, localized, "", lang, 0)); err != nil {
+				logger.Warn("Failed to send push hint", logger.String("user_id", userID), logger.String("message_id", message.ID), logger.ErrorField(err))
+			}
+		}
+	}
+}
+
+// digestRecipients 把群消息计入按偏好合并为摘要的成员的摘要中，返回仍逐条提示的成员
+// 紧急消息和系统消息总是逐条提示
+func (s *MessageService) digestRecipients(userIDs []string, message *model.Message) []string {
+	if s.digests == nil || len(userIDs) == 0 || message.IsUrgent() || message.IsSystem() {
+		return userIDs
+	}
+
+	var modes map[string]model.DigestMode
+	if s.profiles != nil {
+		var err error
+		if modes, err = s.profiles.DigestModes(userIDs); err != nil {
+			logger.Warn("Failed to get digest modes", logger.String("message_id", message.ID), logger.ErrorField(err))
+		}
+	}
+	immediate, digested := splitDigestRecipients(userIDs, modes, s.digests.busy(message))
+	for _, userID := range digested {
+		s.digests.add(userID, message)
+	}
+	return immediate
+}
+
+// splitDigestRecipients 按摘要方式把接收者分为逐条推送和合并为摘要两组，busy为群是否处于活跃状态
+func splitDigestRecipients(userIDs []string, modes map[string]model.DigestMode, busy bool) ([]string, []string) {
+	var immediate, digested []string
+	for _, userID := range userIDs {
+		switch modes[userID] {
+		case model.DigestOff:
+		case model.DigestAlways:
+			digested = append(digested, userID)
+			continue
+		default:
+			if busy {
+				digested = append(digested, userID)
+				continue
+			}
+		}
+		immediate = append(immediate, userID)
+	}
+	return immediate, digested
+}
+
+// busyGroup 群在当前摘要窗口内的消息数是否超过阈值，计数失败时按不活跃处理
+func (s *MessageService) busyGroup(message *model.Message) bool {
+	n, err := s.redisStore.AddGroupTraffic(message.GroupID, message.ID, s.notifyCfg.DigestWindow)
+	if err != nil {
+		logger.Warn("Failed to count group traffic", logger.String("group_id", message.GroupID), logger.ErrorField(err))
+		return false
+	}
+	return n > int64(s.notifyCfg.DigestThreshold)
+}
+
+// sendDigest 向仍不在线的用户发送摘要提示，用户已上线时不再提示，新消息从历史记录同步
+func (s *MessageService) sendDigest(userID string, digest *model.GroupDigest) {
+	if s.delivery.IsOnline(userID) {
+		return
+	}
+	if err := s.delivery.Hint(userID, s.digestFrame(userID, digest)); err != nil {
+		logger.Warn("Failed to send digest push hint", logger.String("user_id", userID), logger.String("group_id", digest.GroupID), logger.ErrorField(err))
+	}
+}
+
+// digestFrame 构造摘要提示帧，接收者处于免打扰时段时静音，启用通知内容时附带按接收者语言渲染的标题和正文
+func (s *MessageService) digestFrame(userID string, digest *model.GroupDigest) model.WebSocketMessage {
+	frame := model.WebSocketMessage{
+		Type:      model.FrameGroupDigest,
+		Data:      digest,
+		Timestamp: time.Now().Unix(),
+	}
+	if s.quietUsers([]string{userID}, digest.LastMessage)[userID] {
+		frame.Push = &model.PushOptions{Priority: model.MessagePriorityNormal, Silent: true}
+	}
+	if !s.notifyCfg.Enabled {
+		return frame
+	}
+
+	push := model.PushOptions{Priority: model.MessagePriorityNormal}
+	if frame.Push != nil {
+		push = *frame.Push
+	}
+	lang := s.userLanguage(userID)
+	params := map[string]string{"group": digest.GroupID, "count": strconv.Itoa(digest.Count)}
+	push.CollapseKey = model.ConversationID(model.ConversationTypeGroup, digest.GroupID)
+	push.Title = s.catalog.Render(lang, "push_title_digest", params)
+	push.Body = s.catalog.Render(lang, "push_body_digest", params)
+	frame.Push = &push
+	return frame
+}
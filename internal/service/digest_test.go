@@ -0,0 +1,133 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestSplitDigestRecipients(t *testing.T) {
+	modes := map[string]model.DigestMode{"always": model.DigestAlways, "off": model.DigestOff}
+	users := []string{"auto", "always", "off"}
+
+	// 群不活跃时只有always的成员合并
+	immediate, digested := splitDigestRecipients(users, modes, false)
+	assert.Equal(t, []string{"auto", "off"}, immediate)
+	assert.Equal(t, []string{"always"}, digested)
+
+	// 群活跃时未设置偏好的成员也合并，off的成员仍逐条提示
+	immediate, digested = splitDigestRecipients(users, modes, true)
+	assert.Equal(t, []string{"off"}, immediate)
+	assert.Equal(t, []string{"auto", "always"}, digested)
+}
+
+func TestGroupDigests_FlushOncePerWindow(t *testing.T) {
+	flushed := make(map[string]*model.GroupDigest)
+	d := newGroupDigests(time.Minute, nil, func(userID string, digest *model.GroupDigest) {
+		flushed[userID+":"+digest.GroupID] = digest
+	})
+
+	for i, id := range []string{"m1", "m2", "m3"} {
+		d.add("u1", &model.Message{ID: id, GroupID: "g1", Timestamp: int64(100 + i)})
+	}
+	d.add("u2", &model.Message{ID: "m3", GroupID: "g1"})
+	d.add("u1", &model.Message{ID: "m9", GroupID: "g2"})
+
+	// 一次窗口结束发出所有缓冲的摘要
+	d.release()
+	assert.Len(t, flushed, 3)
+	digest := flushed["u1:g1"]
+	assert.Equal(t, 3, digest.Count)
+	assert.Equal(t, "m1", digest.FirstMessageID)
+	assert.Equal(t, "m3", digest.LastMessage.ID)
+	assert.Equal(t, int64(100), digest.Since)
+	assert.Equal(t, 1, flushed["u2:g1"].Count)
+	assert.Equal(t, 1, flushed["u1:g2"].Count)
+
+	// 窗口结束后的消息开始新的窗口，空窗口不发出摘要
+	d.add("u1", &model.Message{ID: "m4", GroupID: "g1"})
+	flushed = make(map[string]*model.GroupDigest)
+	d.release()
+	assert.Equal(t, 1, flushed["u1:g1"].Count)
+	assert.Equal(t, "m4", flushed["u1:g1"].FirstMessageID)
+	flushed = make(map[string]*model.GroupDigest)
+	d.release()
+	assert.Empty(t, flushed)
+}
+
+func TestBroadcastGroupMessage_DigestOnlyOfflineHints(t *testing.T) {
+	var history, hints []string
+	local := newTestDeliverer("local", &history, "online")
+	router := NewDeliveryRouter().Online(DeliveryLocal, local)
+	router.SetOfflineHint(func(userID string, frame model.WebSocketMessage) error {
+		hints = append(hints, userID+":"+string(frame.Type))
+		return nil
+	})
+	s := newNotifyingService(false)
+	s.deliverer, s.delivery = local, router
+	s.digests = newGroupDigests(time.Minute, func(*model.Message) bool { return true }, s.sendDigest)
+
+	members := []string{"online", "offline"}
+	for _, id := range []string{"m1", "m2", "m3"} {
+		s.broadcastGroupMessage(members, &model.Message{ID: id, GroupID: "g1", Type: model.MessageTypeText})
+	}
+
+	// 群活跃时在线成员仍逐条收到推送帧，不在线的成员不逐条提示
+	assert.Equal(t, []string{"local:online", "local:offline", "local:online", "local:offline", "local:online", "local:offline"}, history)
+	assert.Empty(t, hints)
+
+	// 窗口结束时不在线的成员只收到一条摘要提示
+	s.digests.release()
+	assert.Equal(t, []string{"offline:" + string(model.FrameGroupDigest)}, hints)
+
+	// 紧急消息不合并，逐条提示不在线的成员
+	hints = nil
+	s.broadcastGroupMessage(members, &model.Message{ID: "m4", GroupID: "g1", Type: model.MessageTypeText, Priority: model.MessagePriorityUrgent})
+	assert.Equal(t, []string{"offline:" + string(model.FrameNewGroupMessage)}, hints)
+
+	// 窗口结束前已上线的成员不再收到摘要提示
+	hints = nil
+	s.broadcastGroupMessage(members, &model.Message{ID: "m5", GroupID: "g1", Type: model.MessageTypeText})
+	local.online["offline"] = true
+	s.digests.release()
+	assert.Empty(t, hints)
+}
+
+func TestDigestRecipients_Passthrough(t *testing.T) {
+	users := []string{"u1", "u2"}
+
+	// 未配置摘要窗口
+	s := &MessageService{}
+	assert.Equal(t, users, s.digestRecipients(users, &model.Message{GroupID: "g1"}))
+
+	// 紧急消息和系统消息总是逐条推送，不统计群活跃度
+	s.digests = newGroupDigests(time.Minute, nil, func(string, *model.GroupDigest) {})
+	assert.Equal(t, users, s.digestRecipients(users, &model.Message{GroupID: "g1", Priority: model.MessagePriorityUrgent}))
+	assert.Equal(t, users, s.digestRecipients(users, &model.Message{GroupID: "g1", System: &model.SystemPayload{Event: model.SystemEventGroupUpgraded}}))
+}
+
+func TestDigestFrame(t *testing.T) {
+	digest := &model.GroupDigest{GroupID: "g1", Count: 37, FirstMessageID: "m1", LastMessage: &model.Message{ID: "m37", GroupID: "g1"}}
+
+	frame := newNotifyingService(false).digestFrame("u1", digest)
+	assert.Equal(t, model.FrameGroupDigest, frame.Type)
+	assert.Equal(t, digest, frame.Data)
+	assert.Nil(t, frame.Push)
+	// 摘要帧不受确认窗口限制
+	assert.Empty(t, frame.MessageID)
+
+	frame = newNotifyingService(true).digestFrame("u1", digest)
+	if assert.NotNil(t, frame.Push) {
+		assert.Equal(t, "group:g1", frame.Push.CollapseKey)
+		assert.Equal(t, "g1", frame.Push.Title)
+		assert.Equal(t, "37 条新消息", frame.Push.Body)
+	}
+}
+
+func TestProfileService_UpdateNotificationPreferencesValidation(t *testing.T) {
+	p := &ProfileService{}
+	_, err := p.UpdateNotificationPreferences("u1", "hourly")
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
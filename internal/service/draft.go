@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+// DraftService 草稿服务，草稿只保存在Redis中并在用户的多个设备间同步
+type DraftService struct {
+	redisStore *store.RedisStore
+	deliverer  Deliverer
+	cfg        config.DraftConfig
+}
+
+// NewDraftService 创建草稿服务
+func NewDraftService(redisStore *store.RedisStore, deliverer Deliverer, cfg config.DraftConfig) *DraftService {
+	return &DraftService{
+		redisStore: redisStore,
+		deliverer:  deliverer,
+		cfg:        cfg,
+	}
+}
+
+// GetDraft 获取用户在会话中的草稿，没有草稿时返回空内容
+func (d *DraftService) GetDraft(userID, conversationID string) (*model.Draft, error) {
+	if _, _, err := model.ParseConversationID(conversationID); err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+
+	draft, ok, err := d.redisStore.GetDraft(userID, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+	if !ok {
+		return &model.Draft{ConversationID: conversationID}, nil
+	}
+	return draft, nil
+}
+
+// SaveDraft 保存草稿并通知用户的其他设备，内容为空时删除草稿
+func (d *DraftService) SaveDraft(userID, conversationID, content string) (*model.Draft, error) {
+	if _, _, err := model.ParseConversationID(conversationID); err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	if len(content) > d.cfg.MaxSize {
+		return nil, newServiceError(ErrCodeInvalidRequest, "draft exceeds %d bytes", d.cfg.MaxSize)
+	}
+
+	draft := &model.Draft{
+		ConversationID: conversationID,
+		Content:        content,
+		UpdatedAt:      time.Now().Unix(),
+	}
+	if content == "" {
+		if err := d.redisStore.DeleteDraft(userID, conversationID); err != nil {
+			return nil, fmt.Errorf("failed to delete draft: %w", err)
+		}
+	} else if err := d.redisStore.SetDraft(userID, draft, d.cfg.TTL); err != nil {
+		return nil, fmt.Errorf("failed to save draft: %w", err)
+	}
+
+	// 推送给用户所有在线设备，客户端按updated_at忽略自己发起的更新
+	d.deliverer.SendToUser(userID, model.WebSocketMessage{
+		Type:      model.FrameDraftUpdated,
+		Data:      draft,
+		Timestamp: time.Now().Unix(),
+	})
+	return draft, nil
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+)
+
+func TestDraftService_Validation(t *testing.T) {
+	d := NewDraftService(nil, nil, config.DraftConfig{MaxSize: 8})
+
+	_, err := d.GetDraft("u1", "bogus")
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+	_, err = d.SaveDraft("u1", "bogus", "hi")
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	// 按字节计算长度
+	_, err = d.SaveDraft("u1", "private:u2", strings.Repeat("草", 3))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+	assert.Contains(t, err.Error(), "8 bytes")
+}
@@ -0,0 +1,53 @@
+package service
+
+import "fmt"
+
+// 业务错误码
+const (
+	ErrCodeNotMember             = "not_member"
+	ErrCodeForbidden             = "forbidden"
+	ErrCodePostForbidden         = "post_forbidden"
+	ErrCodeMuted                 = "muted"
+	ErrCodeSlowMode              = "slow_mode"
+	ErrCodeLinkForbidden         = "link_forbidden"
+	ErrCodeMediaForbidden        = "media_forbidden"
+	ErrCodeInvalidRequest        = "invalid_request"
+	ErrCodeBanned                = "banned"
+	ErrCodeUserMuted             = "user_muted"
+	ErrCodeSpamThrottled         = "spam_throttled"
+	ErrCodeNotFound              = "not_found"
+	ErrCodeRateLimited           = "rate_limited"
+	ErrCodeQuotaExceeded         = "quota_exceeded"
+	ErrCodeChallengeFailed       = "challenge_failed"
+	ErrCodeTwoFactorRequired     = "two_factor_required"
+	ErrCodeTwoFactorFailed       = "two_factor_failed"
+	ErrCodeUnauthenticated       = "unauthenticated"
+	ErrCodeConflict              = "conflict"
+	ErrCodeChecksumMismatch      = "checksum_mismatch"
+	ErrCodeFileInfected          = "file_infected"
+	ErrCodePrivacyRestricted     = "privacy_restricted"
+	ErrCodeGroupInviteRestricted = "group_invite_restricted"
+	ErrCodeContentBlocked        = "content_blocked"
+	ErrCodeUnsafeLink            = "unsafe_link"
+	ErrCodeDisconnected          = "disconnected"
+)
+
+// ServiceError 带错误码的业务错误，HTTP和WebSocket层据此返回结构化错误
+type ServiceError struct {
+	Code       string
+	Message    string
+	RetryAfter int64 // 可重试前需等待的秒数，仅限流类错误使用
+}
+
+// Error 实现error接口
+func (e *ServiceError) Error() string {
+	return e.Message
+}
+
+// newServiceError 创建业务错误
+func newServiceError(code, format string, args ...interface{}) *ServiceError {
+	return &ServiceError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
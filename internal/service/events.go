@@ -0,0 +1,101 @@
+package service
+
+import (
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+	"github.com/user/im/pkg/logger"
+)
+
+// EventPublisher 发布规范事件到事件主题，未配置事件主题时为nil，所有方法都是空操作
+// 消息相关事件按会话分区，同一会话内 created、delivered、read 的顺序与发生顺序一致
+type EventPublisher struct {
+	publisher *store.AsyncPublisher
+}
+
+// NewEventPublisher 创建事件发布器，topic为空时返回nil
+func NewEventPublisher(kafkaStore *store.KafkaStore, topic string) *EventPublisher {
+	if topic == "" {
+		return nil
+	}
+	return &EventPublisher{publisher: kafkaStore.NewAsyncPublisher(topic)}
+}
+
+// MessageCreated 发布消息创建事件
+func (e *EventPublisher) MessageCreated(message *model.Message) {
+	e.publish(model.EventMessageCreated, store.PartitionKey(message), model.NewMessageEventData(message))
+}
+
+// ReceiptRecorded 发布消息投递或已读事件
+func (e *EventPublisher) ReceiptRecorded(message *model.Message, userID string, status model.MessageStatus) {
+	eventType := model.EventMessageDelivered
+	if status == model.MessageStatusRead {
+		eventType = model.EventMessageRead
+	}
+	e.publish(eventType, store.PartitionKey(message), model.ReceiptEventData{
+		MessageID: message.ID,
+		UserID:    userID,
+		SenderID:  message.SenderID,
+		GroupID:   message.GroupID,
+	})
+}
+
+// MemberJoined 发布成员入群事件
+func (e *EventPublisher) MemberJoined(groupID, userID string) {
+	e.publish(model.EventGroupMemberJoined, "group:"+groupID, model.MemberEventData{GroupID: groupID, UserID: userID})
+}
+
+// MemberLeft 发布成员退群事件
+func (e *EventPublisher) MemberLeft(groupID, userID string) {
+	e.publish(model.EventGroupMemberLeft, "group:"+groupID, model.MemberEventData{GroupID: groupID, UserID: userID})
+}
+
+// PresenceChanged 发布在线状态变化事件
+func (e *EventPublisher) PresenceChanged(userID, status string) {
+	e.publish(model.EventUserPresenceChanged, "user:"+userID, model.PresenceEventData{UserID: userID, Status: status})
+}
+
+// ReportCreated 发布举报提交事件，按被举报的用户分区
+func (e *EventPublisher) ReportCreated(report *model.Report) {
+	e.publish(model.EventReportCreated, "user:"+report.TargetUserID, model.ReportEventData{
+		ReportID:     report.ID,
+		ReporterID:   report.ReporterID,
+		TargetType:   report.TargetType,
+		TargetUserID: report.TargetUserID,
+		MessageID:    report.MessageID,
+		Reason:       report.Reason,
+	})
+}
+
+// Close 发送剩余事件后关闭
+func (e *EventPublisher) Close() error {
+	if e == nil {
+		return nil
+	}
+	return e.publisher.Close()
+}
+
+// publish 包装并异步发送事件，失败只记录日志
+func (e *EventPublisher) publish(eventType model.EventType, key string, data interface{}) {
+	if e == nil {
+		return
+	}
+	id, err := idgen.GenerateIDString()
+	if err != nil {
+		logger.Warn("Failed to generate event ID", logger.String("type", string(eventType)), logger.ErrorField(err))
+		return
+	}
+
+	event := &model.Event{
+		Version:    model.EventSchemaVersion,
+		ID:         id,
+		Type:       eventType,
+		OccurredAt: time.Now().UnixMilli(),
+		Data:       data,
+	}
+	if err := e.publisher.Publish(key, event); err != nil {
+		logger.Warn("Failed to publish event", logger.String("type", string(eventType)), logger.ErrorField(err))
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// maxFlagAllowlist 单个开关白名单的最大用户数
+const maxFlagAllowlist = 1000
+
+// FeatureFlagService 功能开关
+// 开关定义来自配置文件，可在Redis中整体覆盖；覆盖变更后经Redis频道通知所有节点重新加载
+type FeatureFlagService struct {
+	redisStore *store.RedisStore
+	cfg        config.FlagsConfig
+
+	mu       sync.RWMutex
+	flags    map[string]*model.FeatureFlag
+	onChange []func()
+}
+
+// NewFeatureFlagService 创建功能开关服务，启动前只使用配置文件中的定义
+func NewFeatureFlagService(redisStore *store.RedisStore, cfg config.FlagsConfig) *FeatureFlagService {
+	return &FeatureFlagService{
+		redisStore: redisStore,
+		cfg:        cfg,
+		flags:      mergeFeatureFlags(cfg.Flags, nil),
+	}
+}
+
+// OnChange 注册开关变化回调，需在Start前调用
+func (f *FeatureFlagService) OnChange(fn func()) {
+	f.onChange = append(f.onChange, fn)
+}
+
+// Start 加载Redis中的覆盖，之后在收到变更通知或定期刷新时重新加载
+func (f *FeatureFlagService) Start() {
+	f.reload()
+
+	go func() {
+		pubsub := f.redisStore.Subscribe(store.FeatureFlagsChannel)
+		defer pubsub.Close()
+		for range pubsub.Channel() {
+			f.reload()
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(f.cfg.RefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			f.reload()
+		}
+	}()
+}
+
+// reload 重新加载开关覆盖，开关变化时通知回调
+func (f *FeatureFlagService) reload() {
+	overrides, err := f.redisStore.GetFeatureFlags()
+	if err != nil {
+		logger.Warn("Failed to load feature flag overrides", logger.ErrorField(err))
+		return
+	}
+	next := mergeFeatureFlags(f.cfg.Flags, overrides)
+
+	f.mu.Lock()
+	changed := !sameFeatureFlags(f.flags, next)
+	f.flags = next
+	f.mu.Unlock()
+
+	if changed {
+		logger.Info("Feature flags changed", logger.Int("flags", len(next)))
+		for _, fn := range f.onChange {
+			fn()
+		}
+	}
+}
+
+// Enabled 判断开关对用户是否开启，开关未定义时返回def；nil服务同样返回def
+func (f *FeatureFlagService) Enabled(name, userID string, def bool) bool {
+	if f == nil {
+		return def
+	}
+	f.mu.RLock()
+	flag, exists := f.flags[name]
+	f.mu.RUnlock()
+	if !exists {
+		return def
+	}
+	return flagEnabledFor(flag, userID)
+}
+
+// Evaluate 计算全部开关对用户的状态，随客户端配置下发
+func (f *FeatureFlagService) Evaluate(userID string) map[string]bool {
+	if f == nil {
+		return nil
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	result := make(map[string]bool, len(f.flags))
+	for name, flag := range f.flags {
+		result[name] = flagEnabledFor(flag, userID)
+	}
+	return result
+}
+
+// List 按名称排序的全部开关
+func (f *FeatureFlagService) List() []*model.FeatureFlag {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	flags := make([]*model.FeatureFlag, 0, len(f.flags))
+	for _, flag := range f.flags {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// SetFlag 覆盖开关并通知所有节点
+func (f *FeatureFlagService) SetFlag(flag *model.FeatureFlag) error {
+	if !featureNamePattern.MatchString(flag.Name) {
+		return newServiceError(ErrCodeInvalidRequest, "invalid feature flag name: %s", flag.Name)
+	}
+	if flag.Percentage < 0 || flag.Percentage > 100 {
+		return newServiceError(ErrCodeInvalidRequest, "percentage must be between 0 and 100")
+	}
+	if len(flag.Allowlist) > maxFlagAllowlist {
+		return newServiceError(ErrCodeInvalidRequest, "allowlist exceeds %d users", maxFlagAllowlist)
+	}
+	flag.Source = model.FeatureFlagSourceOverride
+	flag.UpdatedAt = time.Now().Unix()
+	if err := f.redisStore.SetFeatureFlag(flag); err != nil {
+		return fmt.Errorf("failed to set feature flag: %w", err)
+	}
+	f.notify()
+	return nil
+}
+
+// ResetFlag 删除开关覆盖并通知所有节点
+func (f *FeatureFlagService) ResetFlag(name string) error {
+	if !featureNamePattern.MatchString(name) {
+		return newServiceError(ErrCodeInvalidRequest, "invalid feature flag name: %s", name)
+	}
+	if err := f.redisStore.DeleteFeatureFlag(name); err != nil {
+		return fmt.Errorf("failed to reset feature flag: %w", err)
+	}
+	f.notify()
+	return nil
+}
+
+// notify 通知所有节点重新加载，通知失败时本节点立即生效，其他节点等待定期刷新
+func (f *FeatureFlagService) notify() {
+	if err := f.redisStore.PublishMessage(store.FeatureFlagsChannel, time.Now().Unix()); err != nil {
+		logger.Warn("Failed to publish feature flag change", logger.ErrorField(err))
+		f.reload()
+	}
+}
+
+// mergeFeatureFlags 合并配置文件中的定义和Redis中的覆盖，覆盖整体替换同名开关
+func mergeFeatureFlags(defined map[string]config.FlagConfig, overrides map[string]*model.FeatureFlag) map[string]*model.FeatureFlag {
+	flags := make(map[string]*model.FeatureFlag, len(defined)+len(overrides))
+	for name, c := range defined {
+		flags[name] = &model.FeatureFlag{
+			Name:       name,
+			Enabled:    c.Enabled,
+			Percentage: c.Percentage,
+			Allowlist:  c.Allowlist,
+			Source:     model.FeatureFlagSourceConfig,
+		}
+	}
+	for name, flag := range overrides {
+		flags[name] = flag
+	}
+	return flags
+}
+
+// sameFeatureFlags 比较两组开关的定义是否相同
+func sameFeatureFlags(a, b map[string]*model.FeatureFlag) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, x := range a {
+		y, exists := b[name]
+		if !exists || x.Enabled != y.Enabled || x.Percentage != y.Percentage || len(x.Allowlist) != len(y.Allowlist) {
+			return false
+		}
+		for i := range x.Allowlist {
+			if x.Allowlist[i] != y.Allowlist[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// flagEnabledFor 白名单用户总是开启；其余用户在开关开启时按分桶判断
+func flagEnabledFor(flag *model.FeatureFlag, userID string) bool {
+	for _, id := range flag.Allowlist {
+		if id == userID {
+			return true
+		}
+	}
+	if !flag.Enabled || userID == "" {
+		return false
+	}
+	return flagBucket(flag.Name, userID) < flag.Percentage
+}
+
+// flagBucket 用户在开关下的分桶(0-99)
+// 分桶混入开关名称，同一用户在不同开关下落入不同的灰度批次；调大百分比时已开启的用户保持开启
+func flagBucket(name, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{':'})
+	h.Write([]byte(userID))
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func TestFlagEnabledFor(t *testing.T) {
+	flag := &model.FeatureFlag{Name: "reactions", Enabled: false, Percentage: 100, Allowlist: []string{"vip"}}
+	assert.True(t, flagEnabledFor(flag, "vip"))
+	assert.False(t, flagEnabledFor(flag, "u1"))
+
+	flag.Enabled = true
+	assert.True(t, flagEnabledFor(flag, "u1"))
+	assert.False(t, flagEnabledFor(flag, ""))
+
+	flag.Percentage = 0
+	assert.False(t, flagEnabledFor(flag, "u1"))
+	assert.True(t, flagEnabledFor(flag, "vip"))
+}
+
+func TestFlagEnabledFor_PercentageRollout(t *testing.T) {
+	ten := &model.FeatureFlag{Name: "reactions", Enabled: true, Percentage: 10}
+	fifty := &model.FeatureFlag{Name: "reactions", Enabled: true, Percentage: 50}
+
+	enabled := 0
+	for i := 0; i < 10000; i++ {
+		userID := fmt.Sprintf("user%d", i)
+		if flagEnabledFor(ten, userID) {
+			enabled++
+			// 调大百分比时已开启的用户保持开启
+			assert.True(t, flagEnabledFor(fifty, userID))
+		}
+	}
+	assert.InDelta(t, 1000, enabled, 150)
+}
+
+func TestFeatureFlagService_Defaults(t *testing.T) {
+	var nilFlags *FeatureFlagService
+	assert.True(t, nilFlags.Enabled("link_preview", "u1", true))
+
+	flags := NewFeatureFlagService(nil, config.FlagsConfig{Flags: map[string]config.FlagConfig{
+		"frame.sync_gap": {Enabled: false, Allowlist: []string{"u2"}},
+	}})
+	assert.True(t, flags.Enabled("frame.send_message", "u1", true))
+	assert.False(t, flags.Enabled("frame.sync_gap", "u1", true))
+	assert.Equal(t, map[string]bool{"frame.sync_gap": true}, flags.Evaluate("u2"))
+}
+
+func TestMergeFeatureFlags_OverrideReplacesDefinition(t *testing.T) {
+	defined := map[string]config.FlagConfig{"reactions": {Enabled: true, Percentage: 10, Allowlist: []string{"vip"}}}
+	merged := mergeFeatureFlags(defined, map[string]*model.FeatureFlag{
+		"reactions": {Name: "reactions", Enabled: false, Source: model.FeatureFlagSourceOverride},
+	})
+
+	assert.False(t, merged["reactions"].Enabled)
+	assert.Empty(t, merged["reactions"].Allowlist)
+	assert.False(t, sameFeatureFlags(mergeFeatureFlags(defined, nil), merged))
+	assert.True(t, sameFeatureFlags(mergeFeatureFlags(defined, nil), mergeFeatureFlags(defined, nil)))
+}
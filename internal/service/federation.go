@@ -0,0 +1,118 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/federation"
+	"github.com/user/im/internal/model"
+)
+
+// federatedMessageTypes 可以跨域收发的消息类型，贴纸、菜单等引用本域数据的类型不能跨域
+var federatedMessageTypes = map[model.MessageType]bool{
+	model.MessageTypeText:  true,
+	model.MessageTypeImage: true,
+	model.MessageTypeFile:  true,
+	model.MessageTypeVoice: true,
+	model.MessageTypeVideo: true,
+}
+
+// SetFederation 开启跨域联邦，发给 user@domain 的私聊消息转发到对方域
+func (s *MessageService) SetFederation(fed *federation.Service) {
+	s.federation = fed
+	fed.SetHandler(s.HandleFederationEvent)
+}
+
+// resolveReceiver 解析私聊接收者的地址，本域地址去掉域名，remote表示接收者属于其他域
+func (s *MessageService) resolveReceiver(receiverID string) (string, bool) {
+	if s.federation == nil {
+		return receiverID, false
+	}
+	if local, ok := s.federation.LocalID(receiverID); ok {
+		return local, false
+	}
+	return receiverID, true
+}
+
+// HandleFederationEvent 处理远程域发来的事件
+func (s *MessageService) HandleFederationEvent(event *model.FederationEvent) error {
+	switch event.Type {
+	case model.FederationEventMessage:
+		return s.ReceiveFederatedMessage(event)
+	case model.FederationEventGroupMember:
+		return s.ReceiveFederatedMembership(event)
+	case model.FederationEventGroupMessage:
+		return s.ReceiveFederatedGroupMessage(event)
+	default:
+		return newServiceError(ErrCodeInvalidRequest, "unsupported federation event type: %s", event.Type)
+	}
+}
+
+// ReceiveFederatedMessage 保存远程域用户发来的私聊消息并投递给本域接收者
+// 消息在本域分配新ID，按本域的隐私设置、处罚和敏感词规则处理
+func (s *MessageService) ReceiveFederatedMessage(event *model.FederationEvent) error {
+	var remote model.Message
+	if err := json.Unmarshal(event.Payload, &remote); err != nil {
+		return newServiceError(ErrCodeInvalidRequest, "invalid federated message: %v", err)
+	}
+	_, senderDomain, ok := federation.SplitAddress(remote.SenderID)
+	if !ok || !strings.EqualFold(senderDomain, event.Origin) {
+		return newServiceError(ErrCodeInvalidRequest, "sender %s does not belong to %s", remote.SenderID, event.Origin)
+	}
+	receiverID, ok := s.federation.LocalID(remote.ReceiverID)
+	if !ok {
+		return newServiceError(ErrCodeInvalidRequest, "receiver %s does not belong to %s", remote.ReceiverID, s.federation.Domain())
+	}
+	if !federatedMessageTypes[remote.Type] {
+		return newServiceError(ErrCodeInvalidRequest, "%s messages cannot be federated", remote.Type)
+	}
+	priority, err := ParseUserPriority(string(remote.Priority))
+	if err != nil {
+		priority = model.MessagePriorityNormal
+	}
+
+	senderID := remote.SenderID
+	req := &SendRequest{
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+		Type:       remote.Type,
+		Content:    remote.Content,
+		Priority:   priority,
+		Inbound:    true,
+	}
+	if err := s.pipeline.run(StagePrePersist, req); err != nil {
+		return err
+	}
+
+	messageID, err := s.messageIDs.NextID()
+	if err != nil {
+		req.abort()
+		return fmt.Errorf("failed to generate message ID: %w", err)
+	}
+	message := &model.Message{
+		ID:         messageID,
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+		Type:       remote.Type,
+		Content:    req.Content,
+		Status:     model.MessageStatusSent,
+		Timestamp:  time.Now().Unix(),
+		Priority:   priority,
+	}
+	s.assignSeq(message)
+	if err := s.saveMessage(req, message); err != nil {
+		return err
+	}
+	s.events.MessageCreated(message)
+	req.Message = message
+	s.pipeline.runPreDeliver(req)
+	s.redisStore.SetMessageCache(messageID, message)
+
+	if req.Pending {
+		s.queueMessageRequest(message)
+		return nil
+	}
+	return s.deliverPrivateMessage(message)
+}
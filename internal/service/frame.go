@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/user/im/internal/model"
+)
+
+// HandleFrame 处理客户端上行业务帧，返回需要回复给客户端的帧（可能为nil）
+// 单体模式下由本地会话管理器调用，业务节点模式下由网关转发的帧调用
+func (s *MessageService) HandleFrame(userID string, frame *model.WebSocketMessage) *model.WebSocketMessage {
+	if userID == "" {
+		return errorFrame("Login required")
+	}
+
+	payload, err := model.DecodeFrame(frame)
+	if errors.Is(err, model.ErrUnknownFrame) {
+		return errorFrame("Unknown message type")
+	}
+	if err != nil {
+		return &model.WebSocketMessage{
+			Type:      model.FrameError,
+			Data:      model.InvalidFrameError(frame.Type, err),
+			Timestamp: time.Now().Unix(),
+		}
+	}
+
+	switch req := payload.(type) {
+	case *model.SendMessageRequest:
+		priority, err := ParseUserPriority(req.Priority)
+		if err != nil {
+			return ServiceErrorFrame(err)
+		}
+
+		var message *model.Message
+		if req.ThreadID != "" {
+			message, err = s.SendThreadReply(userID, req.ThreadID, req.Type, req.Content, priority)
+		} else if req.GroupID != "" {
+			message, err = s.SendGroupMessage(userID, req.GroupID, req.Type, req.Content, priority)
+		} else {
+			message, err = s.SendPrivateMessage(userID, req.ReceiverID, req.Type, req.Content, priority)
+		}
+		if err != nil {
+			return ServiceErrorFrame(err)
+		}
+
+		return &model.WebSocketMessage{
+			Type: model.FrameSendMessage,
+			Data: model.SendMessageResponse{
+				Success:         true,
+				MessageID:       message.ID,
+				Message:         message,
+				ClientTimestamp: frame.Timestamp,
+				ServerTimestamp: message.Timestamp,
+			},
+			Timestamp: time.Now().Unix(),
+			MessageID: message.ID,
+		}
+	case *model.AckRequest:
+		if err := s.AcknowledgeMessage(userID, req.MessageID, model.MessageStatus(req.Status)); err != nil {
+			return ServiceErrorFrame(err)
+		}
+		return nil
+	case *model.SyncGapRequest:
+		messages, lastSeq, hasMore, err := s.RepairGap(userID, req.ConversationID, req.LastSeq, req.Limit)
+		if err != nil {
+			return ServiceErrorFrame(err)
+		}
+		return &model.WebSocketMessage{
+			Type: model.FrameSyncGap,
+			Data: model.SyncGapResponse{
+				ConversationID: req.ConversationID,
+				Messages:       messages,
+				LastSeq:        lastSeq,
+				HasMore:        hasMore,
+			},
+			Timestamp: time.Now().Unix(),
+		}
+	case *model.RSVPRequest:
+		response, err := model.ParseRSVPResponse(req.Response)
+		if err != nil {
+			return ServiceErrorFrame(newServiceError(ErrCodeInvalidRequest, "%s", err.Error()))
+		}
+		counts, err := s.RSVP(userID, req.MessageID, response)
+		if err != nil {
+			return ServiceErrorFrame(err)
+		}
+		return &model.WebSocketMessage{
+			Type:      model.FrameRSVP,
+			Data:      model.GroupEventUpdatedEvent{MessageID: req.MessageID, RSVP: *counts},
+			Timestamp: time.Now().Unix(),
+			MessageID: req.MessageID,
+		}
+	case *model.VoteRequest:
+		tally, err := s.Vote(userID, req.MessageID, req.Options)
+		if err != nil {
+			return ServiceErrorFrame(err)
+		}
+		return &model.WebSocketMessage{
+			Type:      model.FrameVote,
+			Data:      model.PollUpdatedEvent{MessageID: req.MessageID, Tally: *tally},
+			Timestamp: time.Now().Unix(),
+			MessageID: req.MessageID,
+		}
+	case *model.ClosePollRequest:
+		tally, err := s.ClosePoll(userID, req.MessageID)
+		if err != nil {
+			return ServiceErrorFrame(err)
+		}
+		return &model.WebSocketMessage{
+			Type:      model.FrameClosePoll,
+			Data:      model.PollUpdatedEvent{MessageID: req.MessageID, Tally: *tally},
+			Timestamp: time.Now().Unix(),
+			MessageID: req.MessageID,
+		}
+	default:
+		return errorFrame("Unknown message type")
+	}
+}
+
+// errorFrame 构造错误帧
+func errorFrame(message string) *model.WebSocketMessage {
+	return &model.WebSocketMessage{
+		Type: model.FrameError,
+		Data: map[string]interface{}{
+			"error": message,
+		},
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// ServiceErrorFrame 构造错误帧，业务错误附带错误码和重试时间
+func ServiceErrorFrame(err error) *model.WebSocketMessage {
+	var svcErr *ServiceError
+	if !errors.As(err, &svcErr) {
+		return errorFrame(err.Error())
+	}
+
+	data := map[string]interface{}{
+		"error": svcErr.Message,
+		"code":  svcErr.Code,
+	}
+	if svcErr.RetryAfter > 0 {
+		data["retry_after"] = svcErr.RetryAfter
+	}
+	return &model.WebSocketMessage{
+		Type:      model.FrameError,
+		Data:      data,
+		Timestamp: time.Now().Unix(),
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestHandleFrame_Rejections(t *testing.T) {
+	s := &MessageService{}
+	frame := func(frameType model.FrameType, data string) *model.WebSocketMessage {
+		return &model.WebSocketMessage{Type: frameType, Data: json.RawMessage(data)}
+	}
+
+	reply := s.HandleFrame("", frame(model.FrameSendMessage, `{}`))
+	assert.Equal(t, model.FrameError, reply.Type)
+	assert.Equal(t, "Login required", reply.Data.(map[string]interface{})["error"])
+
+	reply = s.HandleFrame("u1", frame("no_such_frame", `{}`))
+	assert.Equal(t, "Unknown message type", reply.Data.(map[string]interface{})["error"])
+
+	// 负载校验失败时附带字段错误，不调用业务层
+	reply = s.HandleFrame("u1", frame(model.FrameAck, `{"message_id":123}`))
+	assert.Equal(t, model.FrameError, reply.Type)
+	assert.Contains(t, reply.Data.(map[string]interface{}), "fields")
+
+	// 终端用户不能发送紧急消息
+	reply = s.HandleFrame("u1", frame(model.FrameSendMessage, `{"receiver_id":"u2","type":"text","content":"hi","priority":"urgent"}`))
+	assert.Equal(t, ErrCodeInvalidRequest, reply.Data.(map[string]interface{})["code"])
+}
+
+func TestServiceErrorFrame(t *testing.T) {
+	err := newServiceError(ErrCodeMuted, "you are muted in this group")
+	err.RetryAfter = 30
+	data := ServiceErrorFrame(err).Data.(map[string]interface{})
+	assert.Equal(t, ErrCodeMuted, data["code"])
+	assert.Equal(t, int64(30), data["retry_after"])
+
+	data = ServiceErrorFrame(assert.AnError).Data.(map[string]interface{})
+	assert.Equal(t, assert.AnError.Error(), data["error"])
+	assert.NotContains(t, data, "code")
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// 单次补齐的消息数
+const (
+	defaultGapLimit = 50
+	maxGapLimit     = 200
+)
+
+// assignSeq 为即将保存的消息分配会话内序号
+// 计数器丢失时从数据库中的最大序号恢复；分配失败不影响发送，消息序号为0，客户端不据此判断缺失
+func (s *MessageService) assignSeq(message *model.Message) {
+	conversation := store.PartitionKey(message)
+	seq, ok, err := s.redisStore.NextSeq(conversation)
+	if err == nil && !ok {
+		var maxSeq int64
+		if s.mysqlStore != nil {
+			maxSeq, err = s.mysqlStore.GetMaxSeq(message)
+		}
+		if err == nil {
+			err = s.redisStore.InitSeq(conversation, maxSeq)
+		}
+		if err == nil {
+			seq, _, err = s.redisStore.NextSeq(conversation)
+		}
+	}
+	if err != nil {
+		logger.Warn("Failed to assign message seq", logger.String("conversation", conversation), logger.ErrorField(err))
+		return
+	}
+	message.Seq = seq
+}
+
+// RepairGap 返回会话中lastSeq之后的消息，供客户端在网络抖动丢帧后自行补齐，无需重新全量同步
+// 返回过滤删除后的消息、本次覆盖到的序号和是否还有更多
+func (s *MessageService) RepairGap(userID, conversationID string, lastSeq int64, limit int) ([]*model.Message, int64, bool, error) {
+	if s.mysqlStore == nil {
+		return nil, 0, false, newServiceError(ErrCodeInvalidRequest, "gap repair requires the MySQL store")
+	}
+	scope, err := s.conversationScope(userID, conversationID)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if lastSeq < 0 {
+		lastSeq = 0
+	}
+	if limit <= 0 {
+		limit = defaultGapLimit
+	}
+	if limit > maxGapLimit {
+		limit = maxGapLimit
+	}
+
+	messages, err := s.mysqlStore.GetMessagesAfterSeq(scope, lastSeq, limit)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to get messages after seq: %w", err)
+	}
+
+	covered := lastSeq
+	if len(messages) > 0 {
+		covered = messages[len(messages)-1].Seq
+	}
+	hasMore := len(messages) == limit
+	messages, err = s.applyDeletions(userID, messages)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return s.localizeMessages(userID, messages), covered, hasMore, nil
+}
+
+// conversationScope 用一条会话内的样例消息确定查询范围，群聊会话要求请求者是群成员
+func (s *MessageService) conversationScope(userID, conversationID string) (*model.Message, error) {
+	conversationType, targetID, err := model.ParseConversationID(conversationID)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	if conversationType != model.ConversationTypeGroup {
+		return &model.Message{SenderID: userID, ReceiverID: targetID}, nil
+	}
+
+	isMember, err := s.mysqlStore.IsGroupMember(targetID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check group membership: %w", err)
+	}
+	if !isMember {
+		return nil, newServiceError(ErrCodeNotMember, "user %s is not a member of group %s", userID, targetID)
+	}
+	return &model.Message{GroupID: targetID}, nil
+}
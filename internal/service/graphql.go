@@ -0,0 +1,261 @@
+package service
+
+import (
+	"math"
+	"strings"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/graphql"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+// historyArgs 会话消息字段接受的参数，与REST接口的查询参数一致
+var historyArgs = []string{"limit", "after_seq", "before_seq", "sender", "types"}
+
+// GraphQLService 只读的GraphQL查询接口，Web客户端一次请求取回会话及其最后一条消息、群成员及其资料和历史消息，
+// 不必逐个调用REST接口；字段名与REST接口的JSON字段一致，解析器映射到会话、消息和用户资料服务
+type GraphQLService struct {
+	messages      *MessageService
+	conversations *ConversationService
+	profiles      *ProfileService
+	limits        graphql.Limits
+}
+
+// NewGraphQLService 创建GraphQL查询服务
+func NewGraphQLService(messages *MessageService, conversations *ConversationService, profiles *ProfileService, cfg config.GraphQLConfig) *GraphQLService {
+	return &GraphQLService{
+		messages:      messages,
+		conversations: conversations,
+		profiles:      profiles,
+		limits:        graphql.Limits{MaxDepth: cfg.MaxDepth, MaxFields: cfg.MaxFields, MaxComplexity: cfg.MaxComplexity},
+	}
+}
+
+// Execute 以userID的身份执行查询，权限检查与对应的REST接口相同
+func (g *GraphQLService) Execute(userID string, req graphql.Request) *graphql.Response {
+	q := &graphQLQuery{GraphQLService: g, userID: userID, users: make(map[string]*graphql.Object)}
+	return graphql.Execute(req, q.root(), g.limits)
+}
+
+// graphQLQuery 一次查询的解析器，同一用户的资料在一次查询中只读取一次
+type graphQLQuery struct {
+	*GraphQLService
+	userID string
+	users  map[string]*graphql.Object
+}
+
+// publicProfile 其他用户可见的资料，不包含邮箱
+type publicProfile struct {
+	UserID      string `json:"user_id"`
+	DisplayName string `json:"display_name"`
+}
+
+// root 查询的根字段：me、conversations、messages
+func (q *graphQLQuery) root() *graphql.Object {
+	return graphql.NewObject("Query", nil, map[string]graphql.Field{
+		"me": {Resolve: func(graphql.Args) (interface{}, error) {
+			profile, err := q.profiles.GetProfile(q.userID)
+			if err != nil {
+				return nil, err
+			}
+			return graphql.NewObject("Profile", profile, nil), nil
+		}},
+		"conversations": {
+			Args:    []string{"offset", "limit", "archived", "pinned", "label"},
+			Resolve: q.conversationList,
+		},
+		"messages": {
+			Args: append([]string{"conversation_id"}, historyArgs...),
+			Resolve: func(args graphql.Args) (interface{}, error) {
+				conversationID, err := args.String("conversation_id")
+				if err != nil {
+					return nil, err
+				}
+				if conversationID == "" {
+					return nil, newServiceError(ErrCodeInvalidRequest, "conversation_id is required")
+				}
+				return q.history(conversationID, args)
+			},
+		},
+	})
+}
+
+// conversationList 会话列表，嵌套字段按会话逐个解析，单次最多100个会话
+func (q *graphQLQuery) conversationList(args graphql.Args) (interface{}, error) {
+	offset, err := args.Int("offset", 0, 0, 1000000)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := args.Int("limit", 20, 1, 100)
+	if err != nil {
+		return nil, err
+	}
+	filter := ConversationFilter{Offset: offset, Limit: limit}
+	if filter.Label, err = args.String("label"); err != nil {
+		return nil, err
+	}
+	if filter.Archived, err = args.Bool("archived"); err != nil {
+		return nil, err
+	}
+	if filter.Pinned, err = args.Bool("pinned"); err != nil {
+		return nil, err
+	}
+
+	conversations, _, err := q.conversations.ListConversations(q.userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]*graphql.Object, len(conversations))
+	for i, conversation := range conversations {
+		objects[i] = q.conversation(conversation)
+	}
+	return objects, nil
+}
+
+// conversation 会话，私聊会话的对方为user，群聊会话的群组为group
+func (q *graphQLQuery) conversation(conversation *model.Conversation) *graphql.Object {
+	return graphql.NewObject("Conversation", conversation, map[string]graphql.Field{
+		"last_message": {Resolve: func(graphql.Args) (interface{}, error) {
+			return q.lastMessage(conversation.ID)
+		}},
+		"messages": {Args: historyArgs, Resolve: func(args graphql.Args) (interface{}, error) {
+			return q.history(conversation.ID, args)
+		}},
+		"user": {Resolve: func(graphql.Args) (interface{}, error) {
+			if conversation.Type != model.ConversationTypePrivate {
+				return nil, nil
+			}
+			return q.user(conversation.TargetID)
+		}},
+		"group": {Resolve: func(graphql.Args) (interface{}, error) {
+			if conversation.Type != model.ConversationTypeGroup {
+				return nil, nil
+			}
+			return q.group(conversation.TargetID)
+		}},
+	})
+}
+
+// lastMessage 会话中最新的一条消息，已对当前用户删除时为null
+func (q *graphQLQuery) lastMessage(conversationID string) (interface{}, error) {
+	messages, _, _, err := q.messages.ListConversationMessages(q.userID, conversationID, store.MessageFilter{BeforeSeq: math.MaxInt64, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	return q.message(messages[0]), nil
+}
+
+// history 会话消息的一页，与REST接口一样返回messages、next_seq和has_more
+func (q *graphQLQuery) history(conversationID string, args graphql.Args) (interface{}, error) {
+	filter := store.MessageFilter{}
+	var err error
+	if filter.Limit, err = args.Int("limit", defaultHistoryLimit, 1, maxHistoryLimit); err != nil {
+		return nil, err
+	}
+	afterSeq, err := args.Int("after_seq", 0, 0, math.MaxInt)
+	if err != nil {
+		return nil, err
+	}
+	beforeSeq, err := args.Int("before_seq", 0, 0, math.MaxInt)
+	if err != nil {
+		return nil, err
+	}
+	filter.AfterSeq, filter.BeforeSeq = int64(afterSeq), int64(beforeSeq)
+	if filter.SenderID, err = args.String("sender"); err != nil {
+		return nil, err
+	}
+	types, err := args.Strings("types")
+	if err != nil {
+		return nil, err
+	}
+	if filter.Types, err = model.ParseMessageTypes(strings.Join(types, ",")); err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+
+	messages, cursor, hasMore, err := q.messages.ListConversationMessages(q.userID, conversationID, filter)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]*graphql.Object, len(messages))
+	for i, message := range messages {
+		objects[i] = q.message(message)
+	}
+	page := map[string]interface{}{"next_seq": cursor, "has_more": hasMore}
+	return graphql.NewObject("MessagePage", page, map[string]graphql.Field{
+		"messages": {Resolve: func(graphql.Args) (interface{}, error) { return objects, nil }},
+	}), nil
+}
+
+// message 消息，sender为发送者的资料
+func (q *graphQLQuery) message(message *model.Message) *graphql.Object {
+	return graphql.NewObject("Message", message, map[string]graphql.Field{
+		"sender": {Resolve: func(graphql.Args) (interface{}, error) {
+			return q.user(message.SenderID)
+		}},
+	})
+}
+
+// group 用户所在会话的群组，members为成员的一页，与REST接口一样返回members、next_cursor和has_more
+func (q *graphQLQuery) group(groupID string) (interface{}, error) {
+	group, err := q.messages.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return graphql.NewObject("Group", group, map[string]graphql.Field{
+		"members": {Args: []string{"cursor", "limit", "role", "nickname"}, Resolve: func(args graphql.Args) (interface{}, error) {
+			return q.members(groupID, args)
+		}},
+	}), nil
+}
+
+// members 群成员的一页，单次最多100个成员，user为成员的资料
+func (q *graphQLQuery) members(groupID string, args graphql.Args) (interface{}, error) {
+	filter := store.MemberFilter{}
+	var err error
+	if filter.Limit, err = args.Int("limit", 20, 1, 100); err != nil {
+		return nil, err
+	}
+	if filter.Cursor, err = args.String("cursor"); err != nil {
+		return nil, err
+	}
+	if filter.Role, err = args.String("role"); err != nil {
+		return nil, err
+	}
+	if filter.Nickname, err = args.String("nickname"); err != nil {
+		return nil, err
+	}
+
+	members, nextCursor, err := q.messages.ListGroupMembers(groupID, filter)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]*graphql.Object, len(members))
+	for i, member := range members {
+		member := member
+		objects[i] = graphql.NewObject("Member", member, map[string]graphql.Field{
+			"user": {Resolve: func(graphql.Args) (interface{}, error) { return q.user(member.UserID) }},
+		})
+	}
+	page := map[string]interface{}{"next_cursor": nextCursor, "has_more": nextCursor != ""}
+	return graphql.NewObject("MemberPage", page, map[string]graphql.Field{
+		"members": {Resolve: func(graphql.Args) (interface{}, error) { return objects, nil }},
+	}), nil
+}
+
+// user 其他用户的公开资料
+func (q *graphQLQuery) user(userID string) (interface{}, error) {
+	if user, ok := q.users[userID]; ok {
+		return user, nil
+	}
+	profile, err := q.profiles.GetProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+	user := graphql.NewObject("User", &publicProfile{UserID: profile.UserID, DisplayName: profile.DisplayName}, nil)
+	q.users[userID] = user
+	return user, nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/graphql"
+	"github.com/user/im/internal/store"
+)
+
+func TestGraphQLService_MessagesValidation(t *testing.T) {
+	// 参数校验在查询数据库之前完成，每个字段的错误单独返回
+	messages := &MessageService{mysqlStore: &store.MySQLStore{}}
+	g := NewGraphQLService(messages, nil, nil, config.GraphQLConfig{MaxDepth: 6, MaxFields: 200})
+	resp := g.Execute("u1", graphql.Request{Query: `{
+		missing: messages { has_more }
+		combined: messages(conversation_id: "private:u2", before_seq: 10, after_seq: 5) { has_more }
+		limit: messages(conversation_id: "private:u2", limit: 500) { has_more }
+		types: messages(conversation_id: "private:u2", types: ["bogus"]) { has_more }
+		channel: messages(conversation_id: "channel:c1") { has_more }
+	}`})
+
+	messagesByPath := make(map[string]string)
+	for _, err := range resp.Errors {
+		messagesByPath[err.Path[0].(string)] = err.Message
+	}
+	assert.Equal(t, "conversation_id is required", messagesByPath["missing"])
+	assert.Equal(t, "before_seq cannot be combined with after_seq or date", messagesByPath["combined"])
+	assert.Equal(t, "limit must be an integer between 1 and 200", messagesByPath["limit"])
+	assert.Contains(t, messagesByPath["types"], "bogus")
+	assert.Contains(t, messagesByPath, "channel")
+}
+
+func TestGraphQLService_Limits(t *testing.T) {
+	g := NewGraphQLService(&MessageService{}, nil, nil, config.GraphQLConfig{MaxDepth: 2, MaxFields: 200})
+	resp := g.Execute("u1", graphql.Request{Query: `{ conversations { last_message { id } } }`})
+	assert.Nil(t, resp.Data)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "query exceeds the maximum depth of 2", resp.Errors[0].Message)
+	}
+}
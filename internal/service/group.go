@@ -0,0 +1,302 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+// SetLocker 设置分布式锁，同一群组的成员变更和计数回填在节点间串行执行；未设置时不加锁
+func (s *MessageService) SetLocker(locker *store.Locker) {
+	s.locker = locker
+}
+
+// withGroupLock 持有群组锁执行fn，fn的参数为写入缓存时携带fencing token的锁，未设置锁时为nil
+func (s *MessageService) withGroupLock(groupID string, fn func(lock *store.Lock) error) error {
+	if s.locker == nil {
+		return fn(nil)
+	}
+	err := s.locker.WithLock("group:"+groupID, fn)
+	if errors.Is(err, store.ErrLockNotAcquired) {
+		return newServiceError(ErrCodeConflict, "group %s is busy, try again later", groupID)
+	}
+	return err
+}
+
+// memberLimit 获取群组模式对应的成员上限
+func (s *MessageService) memberLimit(mode model.GroupMode) int {
+	if mode == model.GroupModeChannel {
+		return s.groupCfg.ChannelMaxMembers
+	}
+	return s.groupCfg.MaxMembers
+}
+
+// ListGroupMembers 按条件分页获取群组成员，返回下一页游标（没有更多时为空）
+func (s *MessageService) ListGroupMembers(groupID string, filter store.MemberFilter) ([]*model.GroupMember, string, error) {
+	members, err := s.mysqlStore.ListGroupMembers(groupID, filter)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get group members: %w", err)
+	}
+
+	var nextCursor string
+	if len(members) == filter.Limit && len(members) > 0 {
+		nextCursor = members[len(members)-1].ID
+	}
+	return members, nextCursor, nil
+}
+
+// GetMemberCount 获取群组成员数，优先读取Redis计数，缺失时持有群组锁从数据库回填
+func (s *MessageService) GetMemberCount(groupID string) (int64, error) {
+	if count, ok, err := s.redisStore.GetGroupMemberCount(groupID); err == nil && ok {
+		return count, nil
+	}
+
+	var count int64
+	err := s.withGroupLock(groupID, func(lock *store.Lock) error {
+		var err error
+		count, err = s.memberCount(groupID, lock)
+		return err
+	})
+	return count, err
+}
+
+// memberCount 持有群组锁时获取成员数，等锁期间其他节点可能已经回填
+func (s *MessageService) memberCount(groupID string, lock *store.Lock) (int64, error) {
+	if count, ok, err := s.redisStore.GetGroupMemberCount(groupID); err == nil && ok {
+		return count, nil
+	}
+
+	count, err := s.mysqlStore.CountGroupMembers(groupID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count group members: %w", err)
+	}
+	s.checkGroupCacheWrite(groupID, s.redisStore.SetGroupMemberCountFenced(groupID, count, lock))
+	return count, nil
+}
+
+// SetMemberNickname 设置群昵称
+func (s *MessageService) SetMemberNickname(groupID, userID, nickname string) error {
+	if len([]rune(nickname)) > 50 {
+		return fmt.Errorf("nickname too long")
+	}
+	if err := s.mysqlStore.UpdateGroupMember(groupID, userID, map[string]interface{}{"nickname": nickname}); err != nil {
+		return fmt.Errorf("failed to update nickname: %w", err)
+	}
+	return nil
+}
+
+// MuteMember 群主或管理员禁言成员，duration为0表示解除禁言
+func (s *MessageService) MuteMember(groupID, operatorID, userID string, duration time.Duration) error {
+	operator, err := s.mysqlStore.GetGroupMember(groupID, operatorID)
+	if err != nil {
+		return fmt.Errorf("user %s is not a member of group %s", operatorID, groupID)
+	}
+	if !operator.IsAdmin() {
+		return fmt.Errorf("only owner or admin can mute members")
+	}
+
+	var mutedUntil int64
+	if duration > 0 {
+		mutedUntil = time.Now().Add(duration).Unix()
+	}
+	if err := s.mysqlStore.UpdateGroupMember(groupID, userID, map[string]interface{}{"muted_until": mutedUntil}); err != nil {
+		return fmt.Errorf("failed to mute member: %w", err)
+	}
+
+	event, params := muteParams(operatorID, userID, duration)
+	s.publishSystemMessage(groupID, event, params)
+	return nil
+}
+
+// UpgradeToChannel 将普通群升级为超大群，仅群主可操作
+func (s *MessageService) UpgradeToChannel(groupID, operatorID string) (*model.Group, error) {
+	group, err := s.mysqlStore.GetGroup(groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	if group.OwnerID != operatorID {
+		return nil, fmt.Errorf("only the owner can upgrade group %s", groupID)
+	}
+	if group.IsChannel() {
+		return group, nil
+	}
+
+	if err := s.mysqlStore.UpdateGroupMode(groupID, model.GroupModeChannel); err != nil {
+		return nil, fmt.Errorf("failed to upgrade group: %w", err)
+	}
+	group.Mode = model.GroupModeChannel
+
+	s.publishSystemMessage(groupID, model.SystemEventGroupUpgraded, map[string]string{"operator": operatorID})
+	return group, nil
+}
+
+// FanoutGroupMessage 由Kafka消费者调用，分批把超大群消息推送给在线成员
+// 普通群的消息已在发送路径上直接广播，这里不再重复推送
+func (s *MessageService) FanoutGroupMessage(message *model.Message) error {
+	group, err := s.mysqlStore.GetGroup(message.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get group: %w", err)
+	}
+	if !group.IsChannel() {
+		return nil
+	}
+
+	filter := store.MemberFilter{Limit: s.groupCfg.FanoutBatchSize}
+	for {
+		members, err := s.mysqlStore.ListGroupMembers(message.GroupID, filter)
+		if err != nil {
+			return fmt.Errorf("failed to get group members: %w", err)
+		}
+
+		userIDs := make([]string, 0, len(members))
+		for _, member := range members {
+			if member.UserID != message.SenderID && !s.isRemoteAddress(member.UserID) {
+				userIDs = append(userIDs, member.UserID)
+			}
+		}
+		s.broadcastGroupMessage(userIDs, message)
+		s.latency.Pushed(message)
+
+		if len(members) < filter.Limit {
+			return nil
+		}
+		filter.Cursor = members[len(members)-1].ID
+	}
+}
+
+// MarkChannelRead 更新超大群成员的读游标
+func (s *MessageService) MarkChannelRead(groupID, userID, messageID string) error {
+	isMember, err := s.mysqlStore.IsGroupMember(groupID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check group membership: %w", err)
+	}
+	if !isMember {
+		return fmt.Errorf("user %s is not a member of group %s", userID, groupID)
+	}
+
+	return s.redisStore.SetChannelCursor(groupID, userID, messageID)
+}
+
+// SyncChannelMessages 从成员的读游标开始拉取超大群消息，返回过滤删除后的消息、游标和是否还有更多
+func (s *MessageService) SyncChannelMessages(groupID, userID string, limit int) ([]*model.Message, string, bool, error) {
+	cursor, err := s.redisStore.GetChannelCursor(groupID, userID)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to get read cursor: %w", err)
+	}
+
+	messages, err := s.mysqlStore.GetGroupMessages(groupID, cursor, limit)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to get group messages: %w", err)
+	}
+
+	hasMore := len(messages) == limit
+	messages, err = s.applyDeletions(userID, messages)
+	if err != nil {
+		return nil, "", false, err
+	}
+	return s.localizeMessages(userID, messages), cursor, hasMore, nil
+}
+
+// maxSlowMode 慢速模式最大间隔（秒）
+const maxSlowMode = 3600
+
+// linkPattern 匹配消息内容中的链接
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+
+// GetGroupSettings 获取群组发言设置
+func (s *MessageService) GetGroupSettings(groupID string) (*model.GroupSettings, error) {
+	group, err := s.mysqlStore.GetGroup(groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	return &group.Settings, nil
+}
+
+// UpdateGroupSettings 群主或管理员更新群组发言设置
+func (s *MessageService) UpdateGroupSettings(groupID, operatorID string, settings model.GroupSettings) error {
+	operator, err := s.mysqlStore.GetGroupMember(groupID, operatorID)
+	if err != nil {
+		return newServiceError(ErrCodeNotMember, "user %s is not a member of group %s", operatorID, groupID)
+	}
+	if !operator.IsAdmin() {
+		return newServiceError(ErrCodeForbidden, "only owner or admin can update group settings")
+	}
+
+	if settings.PostPolicy == "" {
+		settings.PostPolicy = model.PostPolicyAll
+	}
+	if settings.PostPolicy != model.PostPolicyAll && settings.PostPolicy != model.PostPolicyAdmins {
+		return newServiceError(ErrCodeInvalidRequest, "invalid post policy: %s", settings.PostPolicy)
+	}
+	if settings.SlowMode < 0 || settings.SlowMode > maxSlowMode {
+		return newServiceError(ErrCodeInvalidRequest, "slow mode must be between 0 and %d seconds", maxSlowMode)
+	}
+	switch settings.WordFilter {
+	case "", model.WordFilterStandard, model.WordFilterStrict:
+	default:
+		return newServiceError(ErrCodeInvalidRequest, "invalid word filter level: %s", settings.WordFilter)
+	}
+	switch settings.Receipts {
+	case "", model.ReceiptsNone, model.ReceiptsAggregate:
+	case model.ReceiptsFull:
+		group, err := s.mysqlStore.GetGroup(groupID)
+		if err != nil {
+			return fmt.Errorf("failed to get group: %w", err)
+		}
+		if group.IsChannel() {
+			return newServiceError(ErrCodeInvalidRequest, "channels do not support per-member receipts")
+		}
+	default:
+		return newServiceError(ErrCodeInvalidRequest, "invalid receipt mode: %s", settings.Receipts)
+	}
+
+	if err := s.mysqlStore.UpdateGroupSettings(groupID, settings); err != nil {
+		return fmt.Errorf("failed to update group settings: %w", err)
+	}
+	return nil
+}
+
+// checkPostPermission 检查成员是否可以在群内发送该消息
+// 群主和管理员只受禁言约束，普通成员依次检查发言权限、内容限制和慢速模式，紧急消息不受禁言约束
+func (s *MessageService) checkPostPermission(group *model.Group, member *model.GroupMember, msgType model.MessageType, content string, priority model.MessagePriority) error {
+	now := time.Now()
+	if member.IsMuted(now) && priority != model.MessagePriorityUrgent {
+		err := newServiceError(ErrCodeMuted, "you are muted in this group")
+		err.RetryAfter = member.MutedUntil - now.Unix()
+		return err
+	}
+	if member.IsAdmin() {
+		return nil
+	}
+
+	settings := group.Settings
+	if settings.PostPolicy == model.PostPolicyAdmins {
+		return newServiceError(ErrCodePostForbidden, "only owner or admin can post in this group")
+	}
+	if settings.BlockMedia && msgType.IsMedia() {
+		return newServiceError(ErrCodeMediaForbidden, "media messages are not allowed in this group")
+	}
+	if settings.BlockLinks && linkPattern.MatchString(content) {
+		return newServiceError(ErrCodeLinkForbidden, "links are not allowed in this group")
+	}
+
+	// 慢速模式最后检查，避免被拒绝的消息占用发言窗口
+	if settings.SlowMode > 0 {
+		ok, remaining, err := s.redisStore.AcquireSlowMode(group.ID, member.UserID, time.Duration(settings.SlowMode)*time.Second)
+		if err != nil {
+			return fmt.Errorf("failed to check slow mode: %w", err)
+		}
+		if !ok {
+			retryAfter := int64(math.Ceil(remaining.Seconds()))
+			err := newServiceError(ErrCodeSlowMode, "slow mode is enabled, retry in %d seconds", retryAfter)
+			err.RetryAfter = retryAfter
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+func errorCode(err error) string {
+	if svcErr, ok := err.(*ServiceError); ok {
+		return svcErr.Code
+	}
+	return ""
+}
+
+func TestCheckPostPermission(t *testing.T) {
+	s := &MessageService{}
+	member := &model.GroupMember{GroupID: "g1", UserID: "u1", Role: "member"}
+	admin := &model.GroupMember{GroupID: "g1", UserID: "u2", Role: "admin"}
+
+	group := &model.Group{ID: "g1", Settings: model.GroupSettings{PostPolicy: model.PostPolicyAll}}
+	assert.NoError(t, s.checkPostPermission(group, member, model.MessageTypeText, "hello", model.MessagePriorityNormal))
+
+	group.Settings.PostPolicy = model.PostPolicyAdmins
+	assert.Equal(t, ErrCodePostForbidden, errorCode(s.checkPostPermission(group, member, model.MessageTypeText, "hello", model.MessagePriorityNormal)))
+	assert.NoError(t, s.checkPostPermission(group, admin, model.MessageTypeText, "hello", model.MessagePriorityNormal))
+
+	group.Settings = model.GroupSettings{PostPolicy: model.PostPolicyAll, BlockLinks: true, BlockMedia: true}
+	assert.Equal(t, ErrCodeLinkForbidden, errorCode(s.checkPostPermission(group, member, model.MessageTypeText, "see https://example.com", model.MessagePriorityNormal)))
+	assert.Equal(t, ErrCodeMediaForbidden, errorCode(s.checkPostPermission(group, member, model.MessageTypeImage, "img.png", model.MessagePriorityNormal)))
+	assert.NoError(t, s.checkPostPermission(group, member, model.MessageTypeText, "no links here", model.MessagePriorityNormal))
+}
+
+func TestCheckPostPermission_Muted(t *testing.T) {
+	s := &MessageService{}
+	group := &model.Group{ID: "g1"}
+	admin := &model.GroupMember{Role: "admin", MutedUntil: time.Now().Add(time.Minute).Unix()}
+
+	err := s.checkPostPermission(group, admin, model.MessageTypeText, "hello", model.MessagePriorityNormal)
+	assert.Equal(t, ErrCodeMuted, errorCode(err))
+	assert.Greater(t, err.(*ServiceError).RetryAfter, int64(0))
+
+	// 紧急消息不受禁言约束
+	assert.NoError(t, s.checkPostPermission(group, admin, model.MessageTypeText, "hello", model.MessagePriorityUrgent))
+}
+
+func TestParseUserPriority(t *testing.T) {
+	priority, err := ParseUserPriority("")
+	assert.NoError(t, err)
+	assert.Equal(t, model.MessagePriorityNormal, priority)
+
+	priority, err = ParseUserPriority("high")
+	assert.NoError(t, err)
+	assert.Equal(t, model.PushSoundHigh, (&model.Message{Priority: priority}).PushOptions().Sound)
+
+	_, err = ParseUserPriority("urgent")
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+	_, err = ParseUserPriority("critical")
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	assert.True(t, (&model.Message{Priority: model.MessagePriorityUrgent}).PushOptions().BypassMute)
+	assert.Nil(t, (&model.Message{Priority: model.MessagePriorityNormal}).PushOptions())
+}
+
+func TestSameMembers(t *testing.T) {
+	assert.True(t, sameMembers([]string{"u1", "u2"}, []string{"u2", "u1"}))
+	assert.True(t, sameMembers(nil, []string{}))
+	assert.False(t, sameMembers([]string{"u1", "u2"}, []string{"u1", "u3"}))
+	assert.False(t, sameMembers([]string{"u1"}, []string{"u1", "u2"}))
+
+	// 比较时不改变调用方的顺序
+	cached := []string{"u2", "u1"}
+	sameMembers(cached, []string{"u1", "u2"})
+	assert.Equal(t, []string{"u2", "u1"}, cached)
+}
+
+// heldLocks 内存中的锁存储，加锁时递增fencing token
+type heldLocks struct {
+	mu     sync.Mutex
+	owners map[string]string
+	fence  int64
+}
+
+func (h *heldLocks) AcquireLock(key, owner string, ttl time.Duration) (int64, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, held := h.owners[key]; held {
+		return 0, false, nil
+	}
+	h.owners[key] = owner
+	h.fence++
+	return h.fence, true, nil
+}
+
+func (h *heldLocks) RenewLock(key, owner string, ttl time.Duration) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.owners[key] == owner, nil
+}
+
+func (h *heldLocks) ReleaseLock(key, owner string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.owners[key] == owner {
+		delete(h.owners, key)
+	}
+	return nil
+}
+
+func TestWithGroupLock(t *testing.T) {
+	s := &MessageService{}
+	assert.NoError(t, s.withGroupLock("g1", func(lock *store.Lock) error {
+		assert.Nil(t, lock)
+		return nil
+	}))
+
+	s.SetLocker(store.NewLocker(&heldLocks{owners: make(map[string]string)}, "node-1", time.Minute, 0))
+	var tokens []int64
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, s.withGroupLock("g1", func(lock *store.Lock) error {
+			tokens = append(tokens, lock.Token())
+			// 持锁期间同一群组的其他变更被拒绝，其他群组不受影响
+			err := s.withGroupLock("g1", func(*store.Lock) error { return nil })
+			assert.Equal(t, ErrCodeConflict, errorCode(err))
+			return s.withGroupLock("g2", func(*store.Lock) error { return nil })
+		}))
+	}
+	assert.Less(t, tokens[0], tokens[1])
+}
+
+// limitedIDs 生成n个ID后返回错误
+type limitedIDs struct{ n int }
+
+func (g *limitedIDs) NextID() (string, error) {
+	if g.n == 0 {
+		return "", errors.New("clock moved backwards")
+	}
+	g.n--
+	return "id" + strconv.Itoa(g.n), nil
+}
+
+func TestCreateGroup_MemberIDFailureBeforeWrite(t *testing.T) {
+	// 成员ID在写入数据库和扣减配额之前生成，生成失败时不会留下没有成员的群组
+	s := &MessageService{groupIDs: &limitedIDs{n: 2}, groupCfg: config.GroupConfig{MaxMembers: 10}}
+	_, err := s.createGroup("g", "", "u1", []string{"u1", "u2", "u3"}, model.GroupModeNormal)
+	assert.ErrorContains(t, err, "failed to generate group member ID")
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// groupMemberIDs 获取普通群的成员ID，优先读取Redis缓存，未加载时持有群组锁从数据库回填；锁不可用时直接读取数据库
+func (s *MessageService) groupMemberIDs(groupID string) ([]string, error) {
+	if members, _, ok, err := s.redisStore.GetCachedGroupMembers(groupID); err == nil && ok {
+		return members, nil
+	}
+
+	var (
+		members []string
+		loadErr error
+	)
+	if err := s.withGroupLock(groupID, func(lock *store.Lock) error {
+		members, loadErr = s.loadGroupMembers(groupID, lock)
+		return nil
+	}); err != nil {
+		logger.Warn("Failed to lock group for cache fill", logger.String("group_id", groupID), logger.ErrorField(err))
+		return s.storedMemberIDs(groupID)
+	}
+	return members, loadErr
+}
+
+// loadGroupMembers 持有群组锁时从数据库加载成员并写入缓存，等锁期间其他节点可能已经回填
+func (s *MessageService) loadGroupMembers(groupID string, lock *store.Lock) ([]string, error) {
+	if members, _, ok, err := s.redisStore.GetCachedGroupMembers(groupID); err == nil && ok {
+		return members, nil
+	}
+	members, err := s.storedMemberIDs(groupID)
+	if err != nil {
+		return nil, err
+	}
+	s.checkGroupCacheWrite(groupID, s.redisStore.FillGroupMembers(groupID, members, s.groupCfg.CacheTTL, lock))
+	return members, nil
+}
+
+// storedMemberIDs 从数据库读取群组成员ID
+func (s *MessageService) storedMemberIDs(groupID string) ([]string, error) {
+	members, err := s.mysqlStore.GetGroupMembers(groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	userIDs := make([]string, 0, len(members))
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+	}
+	return userIDs, nil
+}
+
+// checkGroupCacheWrite 处理数据库写入后的缓存写入结果，失败或锁已过期时缓存可能与数据库不一致，删除整个缓存等待下次读取时回填
+// 删除也失败时由对账任务修复
+func (s *MessageService) checkGroupCacheWrite(groupID string, err error) {
+	if err == nil {
+		return
+	}
+	logger.Warn("Failed to update group cache, invalidating", logger.String("group_id", groupID), logger.ErrorField(err))
+	if err := s.redisStore.InvalidateGroupCache(groupID); err != nil {
+		logger.Error("Failed to invalidate group cache", logger.String("group_id", groupID), logger.ErrorField(err))
+		return
+	}
+	metrics.GroupCacheInvalidations.Inc()
+}
+
+// ReconcileGroupCache 对比已加载的成员缓存与数据库，不一致时重建缓存，作为主节点的后台任务定期执行
+// 缓存与数据库不同时持有群组锁重新对比，排除对比期间正在进行的成员变更；超大群不缓存成员，发现时删除
+func (s *MessageService) ReconcileGroupCache(ctx context.Context, fence int64) error {
+	checked, drifted := 0, 0
+	var cursor uint64
+	for ctx.Err() == nil {
+		groupIDs, next, err := s.redisStore.ScanCachedGroups(cursor, int64(s.groupCfg.ReconcileBatch))
+		if err != nil {
+			return fmt.Errorf("failed to scan cached groups: %w", err)
+		}
+		for _, groupID := range groupIDs {
+			checked++
+			if s.reconcileGroup(groupID) {
+				drifted++
+			}
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	if drifted > 0 {
+		logger.Warn("Group cache drift repaired", logger.Int("checked", checked), logger.Int("drifted", drifted))
+	}
+	return nil
+}
+
+// reconcileGroup 对账一个群组，返回是否发现并修复了不一致
+func (s *MessageService) reconcileGroup(groupID string) bool {
+	_, version, ok, err := s.redisStore.GetCachedGroupMembers(groupID)
+	if err != nil {
+		logger.Warn("Failed to get cached group members", logger.String("group_id", groupID), logger.ErrorField(err))
+		return false
+	}
+	if !ok {
+		// 缓存已过期或已删除
+		s.redisStore.RemoveCachedGroup(groupID)
+		return false
+	}
+
+	group, err := s.mysqlStore.GetGroup(groupID)
+	if err != nil {
+		logger.Warn("Failed to get group", logger.String("group_id", groupID), logger.ErrorField(err))
+		return false
+	}
+	if group.IsChannel() {
+		if err := s.redisStore.InvalidateGroupCache(groupID); err != nil {
+			logger.Warn("Failed to invalidate group cache", logger.String("group_id", groupID), logger.ErrorField(err))
+		}
+		return false
+	}
+
+	drift, err := s.groupCacheDrift(groupID)
+	if err != nil {
+		logger.Warn("Failed to reconcile group cache", logger.String("group_id", groupID), logger.ErrorField(err))
+		return false
+	}
+	if len(drift) == 0 {
+		return false
+	}
+	// 对比期间版本号变化说明有并发的成员变更，下一轮再检查
+	if current, err := s.redisStore.GetGroupMembersVersion(groupID); err != nil || current != version {
+		return false
+	}
+
+	repaired := false
+	if err := s.withGroupLock(groupID, func(lock *store.Lock) error {
+		drift, err := s.groupCacheDrift(groupID)
+		if err != nil || len(drift) == 0 {
+			return err
+		}
+		stored, err := s.storedMemberIDs(groupID)
+		if err != nil {
+			return err
+		}
+		for _, field := range drift {
+			metrics.GroupCacheDrift.WithLabelValues(field).Inc()
+		}
+		logger.Warn("Group cache diverged from store", logger.String("group_id", groupID), logger.String("fields", strings.Join(drift, ",")))
+		s.checkGroupCacheWrite(groupID, s.redisStore.FillGroupMembers(groupID, stored, s.groupCfg.CacheTTL, lock))
+		repaired = true
+		return nil
+	}); err != nil {
+		logger.Warn("Failed to reconcile group cache", logger.String("group_id", groupID), logger.ErrorField(err))
+	}
+	return repaired
+}
+
+// groupCacheDrift 对比缓存与数据库，返回不一致的字段：members（成员集合）、count（成员数）；缓存未加载时没有不一致
+func (s *MessageService) groupCacheDrift(groupID string) ([]string, error) {
+	cached, _, ok, err := s.redisStore.GetCachedGroupMembers(groupID)
+	if err != nil || !ok {
+		return nil, err
+	}
+	stored, err := s.storedMemberIDs(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []string
+	if !sameMembers(cached, stored) {
+		drift = append(drift, "members")
+	}
+	count, ok, err := s.redisStore.GetGroupMemberCount(groupID)
+	if err != nil {
+		return nil, err
+	}
+	if ok && count != int64(len(stored)) {
+		drift = append(drift, "count")
+	}
+	return drift, nil
+}
+
+// sameMembers 两个成员ID列表是否包含相同的成员
+func sameMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
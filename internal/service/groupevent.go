@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+const (
+	// maxGroupEventTitleLength 活动标题的最大长度（字符）
+	maxGroupEventTitleLength = 100
+	// maxGroupEventLocationLength 活动地点的最大长度（字符）
+	maxGroupEventLocationLength = 200
+	// maxGroupEventAhead 活动开始时间距发送时间的上限
+	maxGroupEventAhead = 366 * 24 * time.Hour
+	// maxGroupEventRemindBefore 提前提醒时间的上限
+	maxGroupEventRemindBefore = 7 * 24 * time.Hour
+	// groupEventRSVPRetention 活动开始后答复记录的保留时长，之后活动消息不再显示人数
+	groupEventRSVPRetention = 30 * 24 * time.Hour
+	// groupEventReminderBatch 每次检查处理的到期提醒数
+	groupEventReminderBatch = 100
+)
+
+// parseGroupEvent 解析并校验群活动消息的内容
+func parseGroupEvent(content string, now time.Time) (*model.GroupEventPayload, error) {
+	var event model.GroupEventPayload
+	if err := json.Unmarshal([]byte(content), &event); err != nil {
+		return nil, fmt.Errorf("event content must be a JSON object with title and starts_at")
+	}
+	event.Title = strings.TrimSpace(event.Title)
+	if event.Title == "" || utf8.RuneCountInString(event.Title) > maxGroupEventTitleLength {
+		return nil, fmt.Errorf("event title must be 1 to %d characters", maxGroupEventTitleLength)
+	}
+	if utf8.RuneCountInString(event.Location) > maxGroupEventLocationLength {
+		return nil, fmt.Errorf("event location must not exceed %d characters", maxGroupEventLocationLength)
+	}
+	if event.StartsAt <= now.Unix() || event.StartsAt > now.Add(maxGroupEventAhead).Unix() {
+		return nil, fmt.Errorf("event starts_at must be in the future and within %d days", int(maxGroupEventAhead/(24*time.Hour)))
+	}
+	if event.EndsAt != 0 && event.EndsAt <= event.StartsAt {
+		return nil, fmt.Errorf("event ends_at must be after starts_at")
+	}
+	if event.RemindBefore < -1 || time.Duration(event.RemindBefore)*time.Second > maxGroupEventRemindBefore {
+		return nil, fmt.Errorf("event remind_before must be -1 or between 0 and %d seconds", int64(maxGroupEventRemindBefore/time.Second))
+	}
+	return &event, nil
+}
+
+// validateGroupEvent 校验发送的群活动消息
+func (s *MessageService) validateGroupEvent(content string) error {
+	if _, err := parseGroupEvent(content, time.Now()); err != nil {
+		return newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	return nil
+}
+
+// scheduleGroupEventReminder 活动消息保存后安排开始前的提醒，提醒时间已过时不提醒
+func (s *MessageService) scheduleGroupEventReminder(message *model.Message) {
+	if message.Type != model.MessageTypeEvent {
+		return
+	}
+	var event model.GroupEventPayload
+	if err := json.Unmarshal([]byte(message.Content), &event); err != nil || event.RemindBefore < 0 {
+		return
+	}
+	remindBefore := time.Duration(event.RemindBefore) * time.Second
+	if remindBefore == 0 {
+		remindBefore = s.groupEventCfg.DefaultRemindBefore
+	}
+	remindAt := time.Unix(event.StartsAt, 0).Add(-remindBefore)
+	if !remindAt.After(time.Now()) {
+		return
+	}
+	if err := s.redisStore.ScheduleGroupEventReminder(message.ID, remindAt); err != nil {
+		logger.Warn("Failed to schedule group event reminder", logger.String("message_id", message.ID), logger.ErrorField(err))
+	}
+}
+
+// RSVP 记录成员对群活动的答复，答复人数变化时推送event_updated给群成员，返回最新人数
+// 活动开始后不能再答复
+func (s *MessageService) RSVP(userID, messageID string, response model.RSVPResponse) (*model.RSVPCounts, error) {
+	message, err := s.lookup.get(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.Type != model.MessageTypeEvent || !message.IsGroupMessage() || message.IsDeleted() {
+		return nil, newServiceError(ErrCodeInvalidRequest, "message %s is not an event", messageID)
+	}
+	ok, err := s.canAccessMessage(userID, message)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, newServiceError(ErrCodeNotMember, "user %s is not a member of group %s", userID, message.GroupID)
+	}
+
+	var event model.GroupEventPayload
+	if err := json.Unmarshal([]byte(message.Content), &event); err != nil {
+		return nil, fmt.Errorf("failed to decode event %s: %w", messageID, err)
+	}
+	startsAt := time.Unix(event.StartsAt, 0)
+	if !startsAt.After(time.Now()) {
+		return nil, newServiceError(ErrCodeInvalidRequest, "event %s has already started", messageID)
+	}
+
+	previous, err := s.redisStore.SetGroupEventRSVP(messageID, userID, response, startsAt.Add(groupEventRSVPRetention))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save rsvp: %w", err)
+	}
+	counts, err := s.redisStore.GetGroupEventCounts([]string{messageID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rsvp counts: %w", err)
+	}
+	if previous != response {
+		frame := model.WebSocketMessage{
+			Type:      model.FrameEventUpdated,
+			Data:      model.GroupEventUpdatedEvent{MessageID: messageID, GroupID: message.GroupID, RSVP: *counts[messageID]},
+			Timestamp: time.Now().Unix(),
+			MessageID: messageID,
+		}
+		if err := s.notifyParticipants(message, frame); err != nil {
+			logger.Warn("Failed to notify rsvp update", logger.String("message_id", messageID), logger.ErrorField(err))
+		}
+	}
+	return counts[messageID], nil
+}
+
+// applyGroupEventRSVPs 为活动消息附加答复人数，获取失败时不附加
+func (s *MessageService) applyGroupEventRSVPs(messages []*model.Message) []*model.Message {
+	var ids []string
+	for _, m := range messages {
+		if m.Type == model.MessageTypeEvent && !m.IsDeleted() {
+			ids = append(ids, m.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return messages
+	}
+
+	counts, err := s.redisStore.GetGroupEventCounts(ids)
+	if err != nil {
+		logger.Warn("Failed to get rsvp counts", logger.ErrorField(err))
+		return messages
+	}
+	for i, m := range messages {
+		if c, ok := counts[m.ID]; ok {
+			updated := *m
+			updated.RSVP = c
+			messages[i] = &updated
+		}
+	}
+	return messages
+}
+
+// SendGroupEventReminders 推送到期的活动提醒，由主节点定期执行
+// 提醒发给除拒绝者外的群成员，活动已删除或已开始时跳过
+func (s *MessageService) SendGroupEventReminders(ctx context.Context, fence int64) error {
+	for ctx.Err() == nil {
+		due, err := s.redisStore.GetDueGroupEventReminders(time.Now(), groupEventReminderBatch)
+		if err != nil {
+			return fmt.Errorf("failed to get due event reminders: %w", err)
+		}
+		for _, messageID := range due {
+			// 移除成功后才推送，主节点切换时不会重复提醒
+			removed, err := s.redisStore.RemoveGroupEventReminder(messageID)
+			if err != nil {
+				return fmt.Errorf("failed to remove event reminder: %w", err)
+			}
+			if removed {
+				s.sendGroupEventReminder(messageID)
+			}
+		}
+		if len(due) < groupEventReminderBatch {
+			return nil
+		}
+	}
+	return nil
+}
+
+// sendGroupEventReminder 向群成员推送一条活动提醒
+func (s *MessageService) sendGroupEventReminder(messageID string) {
+	message, err := s.storeBackend.GetMessage(messageID)
+	if err != nil || message.IsDeleted() {
+		return
+	}
+	var event model.GroupEventPayload
+	if err := json.Unmarshal([]byte(message.Content), &event); err != nil || event.StartsAt <= time.Now().Unix() {
+		return
+	}
+
+	members, err := s.groupMemberIDs(message.GroupID)
+	if err != nil {
+		logger.Warn("Failed to get members for event reminder", logger.String("message_id", messageID), logger.ErrorField(err))
+		return
+	}
+	responses, err := s.redisStore.GetGroupEventRSVPs(messageID)
+	if err != nil {
+		logger.Warn("Failed to get rsvps for event reminder", logger.String("message_id", messageID), logger.ErrorField(err))
+		return
+	}
+	recipients := make([]string, 0, len(members))
+	for _, member := range members {
+		if responses[member] != model.RSVPDeclined {
+			recipients = append(recipients, member)
+		}
+	}
+
+	s.deliverer.BroadcastToGroup(recipients, model.WebSocketMessage{
+		Type: model.FrameEventReminder,
+		Data: model.GroupEventReminder{
+			MessageID: messageID,
+			GroupID:   message.GroupID,
+			Title:     event.Title,
+			Location:  event.Location,
+			StartsAt:  event.StartsAt,
+		},
+		Timestamp: time.Now().Unix(),
+		MessageID: messageID,
+	})
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGroupEvent(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	startsAt := now.Add(time.Hour).Unix()
+
+	event, err := parseGroupEvent(fmt.Sprintf(`{"title":" 周会 ","location":"3楼","starts_at":%d,"remind_before":600}`, startsAt), now)
+	assert.NoError(t, err)
+	assert.Equal(t, "周会", event.Title)
+	assert.Equal(t, startsAt, event.StartsAt)
+	assert.Equal(t, int64(600), event.RemindBefore)
+
+	for _, content := range []string{
+		`not json`,
+		fmt.Sprintf(`{"title":"","starts_at":%d}`, startsAt),
+		fmt.Sprintf(`{"title":"周会","starts_at":%d}`, now.Add(-time.Minute).Unix()),
+		fmt.Sprintf(`{"title":"周会","starts_at":%d}`, now.Add(400*24*time.Hour).Unix()),
+		fmt.Sprintf(`{"title":"周会","starts_at":%d,"ends_at":%d}`, startsAt, startsAt),
+		fmt.Sprintf(`{"title":"周会","starts_at":%d,"remind_before":-2}`, startsAt),
+		fmt.Sprintf(`{"title":"周会","starts_at":%d,"remind_before":%d}`, startsAt, 8*24*3600),
+	} {
+		_, err := parseGroupEvent(content, now)
+		assert.Error(t, err, content)
+	}
+}
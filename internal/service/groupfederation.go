@@ -0,0 +1,295 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/federation"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// 联邦群组：群组由创建它的主域保存，其他域的用户以 user@domain 加入，群组地址为 group@home。
+// 每个域只修改自己用户的成员分片，主域按分片汇总成员；群消息由成员所在的域发给主域，
+// 主域投递给本域成员并转发给其他有成员的域，但不回传给发送者所在的域，非主域收到的群消息不再转发。
+
+// isRemoteAddress 用户或群组是否属于其他域，未开启联邦时所有ID都属于本域
+func (s *MessageService) isRemoteAddress(id string) bool {
+	return s.federation != nil && s.federation.IsRemote(id)
+}
+
+// shardVersion 成员分片中记录的版本的绝对值，符号表示是否在群中
+func shardVersion(stored int64) int64 {
+	if stored < 0 {
+		return -stored
+	}
+	return stored
+}
+
+// changeRemoteMembership 本域用户加入或退出其他域的群组，写入本域的成员分片后通知群组的主域
+func (s *MessageService) changeRemoteMembership(groupID, userID string, joined bool) error {
+	_, home, _ := federation.SplitAddress(groupID)
+	domain := s.federation.Domain()
+	current, err := s.redisStore.GetGroupShardVersion(groupID, domain, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check group membership: %w", err)
+	}
+	if joined && current > 0 {
+		return fmt.Errorf("user %s is already a member of group %s", userID, groupID)
+	}
+	if !joined && current <= 0 {
+		return fmt.Errorf("user %s is not a member of group %s", userID, groupID)
+	}
+
+	// 版本只由本域分配，保证同一成员的变更在主域按发生顺序生效
+	version := time.Now().UnixMicro()
+	if version <= shardVersion(current) {
+		version = shardVersion(current) + 1
+	}
+	stored := version
+	if !joined {
+		stored = -version
+	}
+	if err := s.redisStore.SetGroupShardVersion(groupID, domain, userID, stored); err != nil {
+		return fmt.Errorf("failed to update group membership: %w", err)
+	}
+	return s.federation.RelayMembership(home, &model.FederatedMembership{
+		GroupID: groupID,
+		UserID:  federation.Qualify(userID, domain),
+		Joined:  joined,
+		Version: version,
+	})
+}
+
+// ReceiveFederatedMembership 主域应用远程域发来的成员变更，只接受该域自己用户的变更，重复和过期的变更被忽略
+func (s *MessageService) ReceiveFederatedMembership(event *model.FederationEvent) error {
+	var membership model.FederatedMembership
+	if err := json.Unmarshal(event.Payload, &membership); err != nil {
+		return newServiceError(ErrCodeInvalidRequest, "invalid federated membership: %v", err)
+	}
+	_, userDomain, ok := federation.SplitAddress(membership.UserID)
+	if !ok || !strings.EqualFold(userDomain, event.Origin) {
+		return newServiceError(ErrCodeInvalidRequest, "member %s does not belong to %s", membership.UserID, event.Origin)
+	}
+	groupID, ok := s.federation.LocalID(membership.GroupID)
+	if !ok {
+		return newServiceError(ErrCodeInvalidRequest, "group %s does not belong to %s", membership.GroupID, s.federation.Domain())
+	}
+	if membership.Version <= 0 {
+		return newServiceError(ErrCodeInvalidRequest, "invalid membership version: %d", membership.Version)
+	}
+
+	userID := federation.Normalize(membership.UserID)
+	shard := federation.Qualify(groupID, s.federation.Domain())
+	changed := false
+	if err := s.withGroupLock(groupID, func(lock *store.Lock) error {
+		current, err := s.redisStore.GetGroupShardVersion(shard, userDomain, userID)
+		if err != nil {
+			return fmt.Errorf("failed to check group membership: %w", err)
+		}
+		if shardVersion(current) >= membership.Version {
+			return nil
+		}
+		isMember, err := s.mysqlStore.IsGroupMember(groupID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to check group membership: %w", err)
+		}
+		if membership.Joined && !isMember {
+			if err := s.addMember(groupID, userID, lock); err != nil {
+				return err
+			}
+		} else if !membership.Joined && isMember {
+			if err := s.removeMember(groupID, userID, lock); err != nil {
+				return err
+			}
+		}
+		changed = membership.Joined != isMember
+
+		stored := membership.Version
+		if !membership.Joined {
+			stored = -stored
+		}
+		if err := s.redisStore.SetGroupShardVersion(shard, userDomain, userID, stored); err != nil {
+			return fmt.Errorf("failed to update group membership: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+
+	if membership.Joined {
+		s.events.MemberJoined(groupID, userID)
+		s.publishSystemMessage(groupID, model.SystemEventMemberJoined, map[string]string{"user": userID})
+	} else {
+		s.events.MemberLeft(groupID, userID)
+		s.publishSystemMessage(groupID, model.SystemEventMemberLeft, map[string]string{"user": userID})
+	}
+	return nil
+}
+
+// sendRemoteGroupMessage 本域用户向其他域的群组发送消息，保存后投递给本域的其他成员并转发给群组的主域
+func (s *MessageService) sendRemoteGroupMessage(senderID, groupID string, msgType model.MessageType, content string, priority model.MessagePriority) (*model.Message, error) {
+	sentAt := time.Now()
+	if !federatedMessageTypes[msgType] {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s messages cannot be federated", msgType)
+	}
+	current, err := s.redisStore.GetGroupShardVersion(groupID, s.federation.Domain(), senderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check group membership: %w", err)
+	}
+	if current <= 0 {
+		return nil, newServiceError(ErrCodeNotMember, "user %s is not a member of group %s", senderID, groupID)
+	}
+
+	// 群组设置保存在主域，本域按标准级别过滤，主域再按群组设置过滤
+	req := &SendRequest{
+		SenderID: senderID,
+		GroupID:  groupID,
+		Type:     msgType,
+		Content:  content,
+		Priority: priority,
+	}
+	if err := s.pipeline.run(StagePrePersist, req); err != nil {
+		return nil, err
+	}
+
+	messageID, err := s.messageIDs.NextID()
+	if err != nil {
+		req.abort()
+		return nil, fmt.Errorf("failed to generate message ID: %w", err)
+	}
+	message := &model.Message{
+		ID:        messageID,
+		SenderID:  senderID,
+		GroupID:   groupID,
+		Type:      msgType,
+		Content:   req.Content,
+		Status:    model.MessageStatusSent,
+		Timestamp: time.Now().Unix(),
+		Priority:  priority,
+	}
+	s.assignSeq(message)
+	if err := s.saveMessage(req, message); err != nil {
+		return nil, err
+	}
+	storedAt := time.Now()
+	metrics.MessagesSent.WithLabelValues(string(priority)).Inc()
+	s.events.MessageCreated(message)
+	req.Message = message
+	s.pipeline.runPreDeliver(req)
+	s.redisStore.SetMessageCache(messageID, message)
+	message.Trace = s.latency.Start(sentAt, storedAt)
+
+	if err := s.deliverFederatedGroupMessage(message, senderID); err != nil {
+		return nil, err
+	}
+	_, home, _ := federation.SplitAddress(groupID)
+	if err := s.federation.RelayGroupMessage(home, message); err != nil {
+		return nil, fmt.Errorf("failed to relay group message: %w", err)
+	}
+	return message, nil
+}
+
+// deliverFederatedGroupMessage 把其他域群组的消息推送给本域成员分片中的用户
+func (s *MessageService) deliverFederatedGroupMessage(message *model.Message, exclude string) error {
+	members, err := s.redisStore.GetGroupShardMembers(message.GroupID, s.federation.Domain())
+	if err != nil {
+		return fmt.Errorf("failed to get group members: %w", err)
+	}
+	userIDs := make([]string, 0, len(members))
+	for _, member := range members {
+		if member != exclude {
+			userIDs = append(userIDs, member)
+		}
+	}
+	s.broadcastGroupMessage(userIDs, message)
+	s.latency.Pushed(message)
+	return nil
+}
+
+// relayGroupMessage 主域把群消息转发给其他有成员的域，发送者所在的域已自行投递，不再回传
+func (s *MessageService) relayGroupMessage(message *model.Message) {
+	if s.federation == nil {
+		return
+	}
+	shard := federation.Qualify(message.GroupID, s.federation.Domain())
+	domains, err := s.redisStore.GetGroupShardDomains(shard)
+	if err != nil {
+		logger.Warn("Failed to get federated group domains", logger.String("group_id", message.GroupID), logger.ErrorField(err))
+		return
+	}
+	_, senderDomain, _ := federation.SplitAddress(message.SenderID)
+	for _, domain := range domains {
+		if domain == senderDomain {
+			continue
+		}
+		members, err := s.redisStore.GetGroupShardMembers(shard, domain)
+		if err != nil || len(members) == 0 {
+			continue
+		}
+		if err := s.federation.RelayGroupMessage(domain, message); err != nil {
+			logger.Warn("Failed to relay group message", logger.String("message_id", message.ID), logger.String("domain", domain), logger.ErrorField(err))
+		}
+	}
+}
+
+// ReceiveFederatedGroupMessage 处理远程域发来的群消息
+// 本域是主域时按本地群组的规则发送，发送者必须属于发来事件的域；否则只接受主域转发的消息并投递给本域成员
+func (s *MessageService) ReceiveFederatedGroupMessage(event *model.FederationEvent) error {
+	var remote model.Message
+	if err := json.Unmarshal(event.Payload, &remote); err != nil {
+		return newServiceError(ErrCodeInvalidRequest, "invalid federated message: %v", err)
+	}
+	if !federatedMessageTypes[remote.Type] {
+		return newServiceError(ErrCodeInvalidRequest, "%s messages cannot be federated", remote.Type)
+	}
+	_, senderDomain, ok := federation.SplitAddress(remote.SenderID)
+	if !ok {
+		return newServiceError(ErrCodeInvalidRequest, "sender %s is not a federated address", remote.SenderID)
+	}
+	priority, err := ParseUserPriority(string(remote.Priority))
+	if err != nil {
+		priority = model.MessagePriorityNormal
+	}
+
+	if groupID, ok := s.federation.LocalID(remote.GroupID); ok {
+		if !strings.EqualFold(senderDomain, event.Origin) {
+			return newServiceError(ErrCodeInvalidRequest, "sender %s does not belong to %s", remote.SenderID, event.Origin)
+		}
+		_, err := s.sendGroupMessage(federation.Normalize(remote.SenderID), groupID, "", remote.Type, remote.Content, priority)
+		return err
+	}
+
+	groupID := federation.Normalize(remote.GroupID)
+	if _, home, _ := federation.SplitAddress(groupID); home != strings.ToLower(event.Origin) {
+		return newServiceError(ErrCodeInvalidRequest, "messages of group %s are only accepted from its home domain", groupID)
+	}
+	messageID, err := s.messageIDs.NextID()
+	if err != nil {
+		return fmt.Errorf("failed to generate message ID: %w", err)
+	}
+	message := &model.Message{
+		ID:        messageID,
+		SenderID:  federation.Normalize(remote.SenderID),
+		GroupID:   groupID,
+		Type:      remote.Type,
+		Content:   remote.Content,
+		Status:    model.MessageStatusSent,
+		Timestamp: time.Now().Unix(),
+		Priority:  priority,
+	}
+	s.assignSeq(message)
+	if err := s.storeBackend.SaveMessage(message); err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+	s.events.MessageCreated(message)
+	s.redisStore.SetMessageCache(messageID, message)
+	return s.deliverFederatedGroupMessage(message, "")
+}
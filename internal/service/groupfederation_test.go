@@ -0,0 +1,89 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/federation"
+	"github.com/user/im/internal/model"
+)
+
+func newFederatedTestService(t *testing.T) *MessageService {
+	fed, err := federation.New(config.FederationConfig{
+		Domain:     "B.example",
+		KeyID:      "key-b",
+		PrivateKey: base64.StdEncoding.EncodeToString(make([]byte, 32)),
+	}, nil, nil)
+	assert.NoError(t, err)
+	s := &MessageService{}
+	s.SetFederation(fed)
+	return s
+}
+
+func federationEvent(t *testing.T, eventType model.FederationEventType, origin string, payload interface{}) *model.FederationEvent {
+	data, err := json.Marshal(payload)
+	assert.NoError(t, err)
+	return &model.FederationEvent{ID: "e1", Type: eventType, Origin: origin, Destination: "b.example", Payload: data}
+}
+
+func TestFederatedAddresses(t *testing.T) {
+	s := newFederatedTestService(t)
+	assert.True(t, s.isRemoteAddress("g1@a.example"))
+	assert.False(t, s.isRemoteAddress("g1@b.example"))
+	assert.False(t, s.isRemoteAddress("g1"))
+	assert.False(t, (&MessageService{}).isRemoteAddress("g1@a.example"))
+
+	receiver, remote := s.resolveReceiver("bob@B.Example")
+	assert.False(t, remote)
+	assert.Equal(t, "bob", receiver)
+	_, remote = s.resolveReceiver("alice@a.example")
+	assert.True(t, remote)
+
+	_, err := s.CreateGroup("g", "", "bob", []string{"alice@a.example"}, model.GroupModeNormal)
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
+
+func TestReceiveFederatedMembershipOwnership(t *testing.T) {
+	s := newFederatedTestService(t)
+
+	// 成员分片只能由成员所在的域修改
+	err := s.HandleFederationEvent(federationEvent(t, model.FederationEventGroupMember, "c.example", model.FederatedMembership{
+		GroupID: "g1@b.example", UserID: "alice@a.example", Joined: true, Version: 1,
+	}))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	// 只接受本域群组的成员变更
+	err = s.HandleFederationEvent(federationEvent(t, model.FederationEventGroupMember, "a.example", model.FederatedMembership{
+		GroupID: "g1@c.example", UserID: "alice@a.example", Joined: true, Version: 1,
+	}))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	err = s.HandleFederationEvent(federationEvent(t, model.FederationEventGroupMember, "a.example", model.FederatedMembership{
+		GroupID: "g1@b.example", UserID: "alice@a.example", Joined: true,
+	}))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
+
+func TestReceiveFederatedGroupMessageLoopPrevention(t *testing.T) {
+	s := newFederatedTestService(t)
+
+	// 其他域群组的消息只接受主域转发，防止成员所在的域之间互相转发
+	err := s.HandleFederationEvent(federationEvent(t, model.FederationEventGroupMessage, "c.example", model.Message{
+		SenderID: "carol@c.example", GroupID: "g1@a.example", Type: model.MessageTypeText, Content: "hi",
+	}))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	// 主域收到的消息，发送者必须属于发来事件的域
+	err = s.HandleFederationEvent(federationEvent(t, model.FederationEventGroupMessage, "a.example", model.Message{
+		SenderID: "carol@c.example", GroupID: "g1@b.example", Type: model.MessageTypeText, Content: "hi",
+	}))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	err = s.HandleFederationEvent(federationEvent(t, model.FederationEventGroupMessage, "a.example", model.Message{
+		SenderID: "alice@a.example", GroupID: "g1@b.example", Type: model.MessageTypeSystem, Content: "hi",
+	}))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
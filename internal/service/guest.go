@@ -0,0 +1,201 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+	"gorm.io/gorm"
+)
+
+const (
+	// guestTokenPrefix 访客令牌明文前缀
+	guestTokenPrefix = "igt_"
+	// guestSecretBytes 访客令牌随机部分的字节数
+	guestSecretBytes = 24
+	// maxGuestNoteLength 签发原因的最大长度（字符）
+	maxGuestNoteLength = 200
+)
+
+// ErrInvalidGuestToken 访客令牌格式错误、已过期或已吊销
+var ErrInvalidGuestToken = errors.New("invalid guest token")
+
+// GuestService 只读访客令牌，持有者凭令牌通过REST和SSE读取指定群组的消息
+// 令牌保存在Redis中并随有效期过期，明文格式为 igt_<令牌ID>.<密钥>，Redis中只保存密钥摘要
+type GuestService struct {
+	mysqlStore *store.MySQLStore
+	redisStore *store.RedisStore
+	messages   *MessageService
+	cfg        config.GuestConfig
+}
+
+// NewGuestService 创建访客令牌服务
+func NewGuestService(mysqlStore *store.MySQLStore, redisStore *store.RedisStore, messages *MessageService, cfg config.GuestConfig) *GuestService {
+	return &GuestService{
+		mysqlStore: mysqlStore,
+		redisStore: redisStore,
+		messages:   messages,
+		cfg:        cfg,
+	}
+}
+
+// PollInterval SSE连接查询新消息的间隔
+func (g *GuestService) PollInterval() time.Duration {
+	return g.cfg.PollInterval
+}
+
+// CreateToken 为群组签发访客令牌，ttl为0时使用默认有效期，返回令牌和只出现这一次的明文
+func (g *GuestService) CreateToken(groupID, createdBy, note string, ttl time.Duration) (*model.GuestToken, string, error) {
+	if ttl == 0 {
+		ttl = g.cfg.DefaultTTL
+	}
+	if ttl < time.Minute || ttl > g.cfg.MaxTTL {
+		return nil, "", newServiceError(ErrCodeInvalidRequest, "ttl must be between 1m and %s", g.cfg.MaxTTL)
+	}
+	if utf8.RuneCountInString(note) > maxGuestNoteLength {
+		return nil, "", newServiceError(ErrCodeInvalidRequest, "note must not exceed %d characters", maxGuestNoteLength)
+	}
+
+	if _, err := g.mysqlStore.GetGroup(groupID); errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", newServiceError(ErrCodeNotFound, "group %s not found", groupID)
+	} else if err != nil {
+		return nil, "", fmt.Errorf("failed to get group: %w", err)
+	}
+	existing, err := g.redisStore.ListGuestTokens(groupID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list guest tokens: %w", err)
+	}
+	if len(existing) >= g.cfg.MaxTokensPerGroup {
+		return nil, "", newServiceError(ErrCodeConflict, "group %s already has %d active guest tokens", groupID, len(existing))
+	}
+
+	id, err := idgen.GenerateIDString()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate guest token ID: %w", err)
+	}
+	secret := make([]byte, guestSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate guest token: %w", err)
+	}
+	rawSecret := hex.EncodeToString(secret)
+
+	now := time.Now()
+	token := &model.GuestToken{
+		ID:        id,
+		GroupID:   groupID,
+		Note:      note,
+		CreatedBy: createdBy,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	if err := g.redisStore.SetGuestToken(token, hashGuestSecret(rawSecret)); err != nil {
+		return nil, "", fmt.Errorf("failed to save guest token: %w", err)
+	}
+	return token, guestTokenPrefix + id + "." + rawSecret, nil
+}
+
+// ListTokens 列出群组未过期的访客令牌
+func (g *GuestService) ListTokens(groupID string) ([]*model.GuestToken, error) {
+	tokens, err := g.redisStore.ListGuestTokens(groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guest tokens: %w", err)
+	}
+	if tokens == nil {
+		tokens = []*model.GuestToken{}
+	}
+	return tokens, nil
+}
+
+// RevokeToken 吊销访客令牌，已建立的SSE连接在下次查询时断开；返回被吊销的令牌
+func (g *GuestService) RevokeToken(id string) (*model.GuestToken, error) {
+	token, _, ok, err := g.redisStore.GetGuestToken(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest token: %w", err)
+	}
+	if !ok {
+		return nil, newServiceError(ErrCodeNotFound, "guest token %s not found", id)
+	}
+	if _, err := g.redisStore.DeleteGuestToken(token); err != nil {
+		return nil, fmt.Errorf("failed to revoke guest token: %w", err)
+	}
+	return token, nil
+}
+
+// Authenticate 校验明文令牌，返回有效的令牌
+func (g *GuestService) Authenticate(raw string) (*model.GuestToken, error) {
+	id, secret, ok := parseGuestToken(raw)
+	if !ok {
+		return nil, ErrInvalidGuestToken
+	}
+	token, hash, ok, err := g.redisStore.GetGuestToken(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest token: %w", err)
+	}
+	if !ok || subtle.ConstantTimeCompare([]byte(hash), []byte(hashGuestSecret(secret))) != 1 {
+		return nil, ErrInvalidGuestToken
+	}
+	if token.ExpiresAt <= time.Now().Unix() {
+		return nil, ErrInvalidGuestToken
+	}
+	return token, nil
+}
+
+// ListMessages 查询令牌所属群组的消息
+func (g *GuestService) ListMessages(token *model.GuestToken, filter store.MessageFilter) ([]*model.Message, int64, bool, error) {
+	return g.messages.ListGuestMessages(token.GroupID, filter)
+}
+
+// LatestSeq 令牌所属群组最新消息的序号，SSE连接未指定起点时从这里开始
+func (g *GuestService) LatestSeq(token *model.GuestToken) (int64, error) {
+	seq, err := g.mysqlStore.GetMaxSeq(&model.Message{GroupID: token.GroupID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest seq: %w", err)
+	}
+	return seq, nil
+}
+
+// Poll 查询afterSeq之后的新消息，令牌已吊销或过期时返回ErrInvalidGuestToken
+func (g *GuestService) Poll(token *model.GuestToken, afterSeq int64) ([]*model.Message, int64, error) {
+	if token.ExpiresAt <= time.Now().Unix() {
+		return nil, afterSeq, ErrInvalidGuestToken
+	}
+	if _, _, ok, err := g.redisStore.GetGuestToken(token.ID); err != nil {
+		return nil, afterSeq, fmt.Errorf("failed to get guest token: %w", err)
+	} else if !ok {
+		return nil, afterSeq, ErrInvalidGuestToken
+	}
+
+	messages, cursor, _, err := g.messages.ListGuestMessages(token.GroupID, store.MessageFilter{AfterSeq: afterSeq, Limit: maxHistoryLimit})
+	if err != nil {
+		return nil, afterSeq, err
+	}
+	return messages, cursor, nil
+}
+
+// parseGuestToken 拆分明文令牌为令牌ID和密钥
+func parseGuestToken(raw string) (string, string, bool) {
+	if !strings.HasPrefix(raw, guestTokenPrefix) {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(strings.TrimPrefix(raw, guestTokenPrefix), ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// hashGuestSecret 计算令牌密钥摘要，Redis中只保存摘要
+func hashGuestSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
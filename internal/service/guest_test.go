@@ -0,0 +1,35 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+)
+
+func TestParseGuestToken(t *testing.T) {
+	id, secret, ok := parseGuestToken("igt_123.abc")
+	assert.True(t, ok)
+	assert.Equal(t, "123", id)
+	assert.Equal(t, "abc", secret)
+
+	for _, raw := range []string{"", "imk_123.abc", "igt_123", "igt_.abc", "igt_123."} {
+		_, _, ok := parseGuestToken(raw)
+		assert.False(t, ok, raw)
+	}
+}
+
+func TestGuestService_CreateTokenValidation(t *testing.T) {
+	guests := NewGuestService(nil, nil, nil, config.GuestConfig{DefaultTTL: time.Hour, MaxTTL: 24 * time.Hour, MaxTokensPerGroup: 1})
+
+	_, _, err := guests.CreateToken("g1", "admin", "", 48*time.Hour)
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	_, _, err = guests.CreateToken("g1", "admin", "", time.Second)
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	_, _, err = guests.CreateToken("g1", "admin", strings.Repeat("工", maxGuestNoteLength+1), 0)
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"mime"
+	"net/url"
+	"path"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+// 单次查询会话消息的条数
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// galleryTypes 未指定类型时会话媒体列表包含的消息类型
+var galleryTypes = []model.MessageType{model.MessageTypeImage, model.MessageTypeVideo, model.MessageTypeFile}
+
+// ListConversationMessages 按日期、发送者和类型查询会话中的消息，供客户端跳转到日期和展示媒体相册
+// 返回过滤删除后的消息、继续翻页的序号（正序时作为after_seq，倒序时作为before_seq）和是否还有更多
+func (s *MessageService) ListConversationMessages(userID, conversationID string, filter store.MessageFilter) ([]*model.Message, int64, bool, error) {
+	if s.mysqlStore == nil {
+		return nil, 0, false, newServiceError(ErrCodeInvalidRequest, "conversation history requires the MySQL store")
+	}
+	if filter.BeforeSeq > 0 && (filter.AfterSeq > 0 || filter.Since > 0) {
+		return nil, 0, false, newServiceError(ErrCodeInvalidRequest, "before_seq cannot be combined with after_seq or date")
+	}
+	scope, err := s.conversationScope(userID, conversationID)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return s.listMessages(userID, scope, filter)
+}
+
+// ListGuestMessages 凭访客令牌查询群组中的消息，不检查成员关系，不应用个人删除
+func (s *MessageService) ListGuestMessages(groupID string, filter store.MessageFilter) ([]*model.Message, int64, bool, error) {
+	if s.mysqlStore == nil {
+		return nil, 0, false, newServiceError(ErrCodeInvalidRequest, "conversation history requires the MySQL store")
+	}
+	if filter.BeforeSeq > 0 && (filter.AfterSeq > 0 || filter.Since > 0) {
+		return nil, 0, false, newServiceError(ErrCodeInvalidRequest, "before_seq cannot be combined with after_seq or date")
+	}
+	return s.listMessages("", &model.Message{GroupID: groupID}, filter)
+}
+
+// listMessages 查询scope所在会话的消息，userID为空时只应用对所有人的删除
+func (s *MessageService) listMessages(userID string, scope *model.Message, filter store.MessageFilter) ([]*model.Message, int64, bool, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultHistoryLimit
+	}
+	if filter.Limit > maxHistoryLimit {
+		filter.Limit = maxHistoryLimit
+	}
+
+	messages, err := s.mysqlStore.GetConversationMessages(scope, filter)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to get conversation messages: %w", err)
+	}
+
+	cursor := filter.AfterSeq
+	if filter.BeforeSeq > 0 {
+		cursor = filter.BeforeSeq
+	}
+	if len(messages) > 0 {
+		cursor = messages[len(messages)-1].Seq
+	}
+	hasMore := len(messages) == filter.Limit
+	messages, err = s.applyDeletions(userID, messages)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return s.localizeMessages(userID, messages), cursor, hasMore, nil
+}
+
+// ListConversationMedia 按序号从新到旧列出会话中的媒体消息，mediaType为空时包含图片、视频和文件
+// beforeSeq为0时从最新的消息开始，返回的序号作为下一页的beforeSeq
+func (s *MessageService) ListConversationMedia(userID, conversationID string, mediaType model.MessageType, beforeSeq int64, limit int) ([]*model.MediaItem, int64, bool, error) {
+	types := galleryTypes
+	if mediaType != "" {
+		if !mediaType.IsMedia() {
+			return nil, 0, false, newServiceError(ErrCodeInvalidRequest, "%s is not a media message type", mediaType)
+		}
+		types = []model.MessageType{mediaType}
+	}
+	if beforeSeq <= 0 {
+		beforeSeq = math.MaxInt64
+	}
+
+	messages, cursor, hasMore, err := s.ListConversationMessages(userID, conversationID, store.MessageFilter{
+		Types:     types,
+		BeforeSeq: beforeSeq,
+		Limit:     limit,
+	})
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	sizes := s.mediaStorage.Sizes(messages)
+	items := make([]*model.MediaItem, 0, len(messages))
+	for _, message := range messages {
+		item := mediaItem(message)
+		item.Size = sizes[message.ID]
+		items = append(items, item)
+	}
+	return items, cursor, hasMore, nil
+}
+
+// mediaItem 从媒体消息的文件地址推断文件名和类型
+func mediaItem(message *model.Message) *model.MediaItem {
+	item := &model.MediaItem{
+		MessageID: message.ID,
+		SenderID:  message.SenderID,
+		Type:      message.Type,
+		URL:       message.Content,
+		Seq:       message.Seq,
+		Timestamp: message.Timestamp,
+	}
+	name := message.Content
+	if u, err := url.Parse(message.Content); err == nil {
+		name = u.Path
+	}
+	if name = path.Base(name); name != "." && name != "/" {
+		item.Filename = name
+		item.ContentType = mime.TypeByExtension(path.Ext(name))
+	}
+	return item
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+func TestMediaItem(t *testing.T) {
+	item := mediaItem(&model.Message{
+		ID:      "m1",
+		Type:    model.MessageTypeImage,
+		Content: "https://cdn.example.com/uploads/2024/01/01/abc/my%20photo.png?sig=1",
+		Seq:     7,
+	})
+	assert.Equal(t, "m1", item.MessageID)
+	assert.Equal(t, "my photo.png", item.Filename)
+	assert.Equal(t, "image/png", item.ContentType)
+	assert.Equal(t, int64(7), item.Seq)
+
+	item = mediaItem(&model.Message{Type: model.MessageTypeFile, Content: ""})
+	assert.Empty(t, item.Filename)
+	assert.Empty(t, item.ContentType)
+}
+
+func TestListConversationMessages_Validation(t *testing.T) {
+	_, _, _, err := (&MessageService{}).ListConversationMessages("u1", "private:u2", store.MessageFilter{})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	// 参数校验在查询数据库之前完成
+	s := &MessageService{mysqlStore: &store.MySQLStore{}}
+	_, _, _, err = s.ListConversationMessages("u1", "private:u2", store.MessageFilter{BeforeSeq: 10, AfterSeq: 5})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+	_, _, _, err = s.ListConversationMessages("u1", "private:u2", store.MessageFilter{BeforeSeq: 10, Since: 1700000000})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+	_, _, _, err = s.ListConversationMessages("u1", "channel:c1", store.MessageFilter{})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/user/im/internal/config"
+)
+
+// pngSignature PNG文件头
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// stdLuminanceQuant JPEG标准亮度量化表（ITU T.81 附录K），用于估算压缩质量
+var stdLuminanceQuant = [64]int{
+	16, 11, 10, 16, 24, 40, 51, 61,
+	12, 12, 14, 19, 26, 58, 60, 55,
+	14, 13, 16, 24, 40, 57, 69, 56,
+	14, 17, 22, 29, 51, 87, 80, 62,
+	18, 22, 37, 56, 68, 109, 103, 77,
+	24, 35, 55, 64, 81, 104, 113, 92,
+	49, 64, 78, 87, 103, 121, 120, 101,
+	72, 92, 95, 98, 112, 100, 103, 99,
+}
+
+// ImageProcessor 上传图片处理，保护用户隐私并减少流量
+// 去除EXIF/GPS、XMP、IPTC和文本块等元数据，JPEG只保留方向；长边超过max_dimension或
+// 估算质量高于quality的图片重新压缩，其余图片无损去除元数据
+type ImageProcessor struct {
+	cfg             config.ImageConfig
+	tenants         map[string]bool
+	tenantSeparator string
+}
+
+// NewImageProcessor 创建图片处理器，租户划分沿用quota.tenant_separator
+func NewImageProcessor(cfg config.ImageConfig, tenantSeparator string) *ImageProcessor {
+	tenants := make(map[string]bool, len(cfg.EnabledTenants)+len(cfg.DisabledTenants))
+	for _, tenant := range cfg.EnabledTenants {
+		tenants[tenant] = true
+	}
+	for _, tenant := range cfg.DisabledTenants {
+		tenants[tenant] = false
+	}
+	return &ImageProcessor{
+		cfg:             cfg,
+		tenants:         tenants,
+		tenantSeparator: tenantSeparator,
+	}
+}
+
+// Applies 用户上传的文件是否需要处理，只处理JPEG和PNG，按租户开关覆盖默认配置
+func (p *ImageProcessor) Applies(userID, contentType string) bool {
+	switch strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0])) {
+	case "image/jpeg", "image/jpg", "image/png":
+	default:
+		return false
+	}
+
+	if enabled, ok := p.tenants[tenantOf(userID, p.tenantSeparator)]; ok {
+		return enabled
+	}
+	return p.cfg.Enabled
+}
+
+// MaxFileSize 需要处理的图片最大字节数
+func (p *ImageProcessor) MaxFileSize() int64 {
+	return p.cfg.MaxFileSize
+}
+
+// Process 处理图片，返回处理后的内容和格式对应的Content-Type
+func (p *ImageProcessor) Process(data []byte) ([]byte, string, error) {
+	imgCfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", newServiceError(ErrCodeInvalidRequest, "invalid image: %v", err)
+	}
+
+	switch format {
+	case "jpeg":
+		segments, err := parseJPEGSegments(data)
+		if err != nil {
+			return nil, "", newServiceError(ErrCodeInvalidRequest, "invalid image: %v", err)
+		}
+		orientation := exifOrientation(segments)
+		width, height := fitImageSize(imgCfg.Width, imgCfg.Height, p.cfg.MaxDimension)
+		resize := width != imgCfg.Width || height != imgCfg.Height
+		if !resize && jpegQuality(segments) <= p.cfg.Quality || !p.decodable(imgCfg) {
+			return stripJPEGMetadata(segments, orientation), "image/jpeg", nil
+		}
+
+		img, err := p.decode(data, width, height)
+		if err != nil {
+			return nil, "", err
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: p.cfg.Quality}); err != nil {
+			return nil, "", fmt.Errorf("failed to encode jpeg: %w", err)
+		}
+		encoded, err := parseJPEGSegments(buf.Bytes())
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode jpeg: %w", err)
+		}
+		return stripJPEGMetadata(encoded, orientation), "image/jpeg", nil
+
+	case "png":
+		width, height := fitImageSize(imgCfg.Width, imgCfg.Height, p.cfg.MaxDimension)
+		if (width == imgCfg.Width && height == imgCfg.Height) || !p.decodable(imgCfg) {
+			stripped, err := stripPNGMetadata(data)
+			if err != nil {
+				return nil, "", newServiceError(ErrCodeInvalidRequest, "invalid image: %v", err)
+			}
+			return stripped, "image/png", nil
+		}
+
+		img, err := p.decode(data, width, height)
+		if err != nil {
+			return nil, "", err
+		}
+		var buf bytes.Buffer
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(&buf, img); err != nil {
+			return nil, "", fmt.Errorf("failed to encode png: %w", err)
+		}
+		return buf.Bytes(), "image/png", nil
+
+	default:
+		return nil, "", newServiceError(ErrCodeInvalidRequest, "unsupported image format: %s", format)
+	}
+}
+
+// decodable 图片像素数不超过max_pixels时才解码，防止解压炸弹占用内存
+func (p *ImageProcessor) decodable(imgCfg image.Config) bool {
+	return int64(imgCfg.Width)*int64(imgCfg.Height) <= p.cfg.MaxPixels
+}
+
+// decode 解码图片并缩小到给定尺寸
+func (p *ImageProcessor) decode(data []byte, width, height int) (*image.RGBA, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "invalid image: %v", err)
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+	if width == rgba.Bounds().Dx() && height == rgba.Bounds().Dy() {
+		return rgba, nil
+	}
+	return resizeImage(rgba, width, height), nil
+}
+
+// fitImageSize 长边不超过maxDimension的等比尺寸
+func fitImageSize(width, height, maxDimension int) (int, int) {
+	if width <= maxDimension && height <= maxDimension {
+		return width, height
+	}
+	scale := float64(maxDimension) / float64(width)
+	if height > width {
+		scale = float64(maxDimension) / float64(height)
+	}
+	w := int(math.Round(float64(width) * scale))
+	h := int(math.Round(float64(height) * scale))
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// resizeImage 按区域平均缩小图片，RGBA为预乘透明度，直接平均即可
+func resizeImage(src *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			off := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[off+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// jpegSegment JPEG文件中的一个段
+type jpegSegment struct {
+	marker byte
+	data   []byte // 段内容，不含标记和长度
+	raw    []byte // 段的原始字节，SOS段包含之后的熵编码数据
+}
+
+// parseJPEGSegments 把JPEG拆分为段，EOI之后附加的数据（如MPF中的其他图片）被丢弃
+func parseJPEGSegments(data []byte) ([]jpegSegment, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("missing jpeg SOI marker")
+	}
+
+	segments := []jpegSegment{{marker: 0xD8, raw: data[:2]}}
+	i := 2
+	for i < len(data) {
+		if data[i] != 0xFF {
+			return nil, fmt.Errorf("invalid jpeg marker at offset %d", i)
+		}
+		start := i
+		for i < len(data) && data[i] == 0xFF {
+			i++
+		}
+		if i >= len(data) {
+			break
+		}
+		marker := data[i]
+		i++
+
+		if marker == 0xD9 {
+			return append(segments, jpegSegment{marker: marker, raw: data[start:i]}), nil
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			segments = append(segments, jpegSegment{marker: marker, raw: data[start:i]})
+			continue
+		}
+
+		if i+2 > len(data) {
+			return nil, fmt.Errorf("truncated jpeg segment at offset %d", start)
+		}
+		length := int(binary.BigEndian.Uint16(data[i:]))
+		if length < 2 || i+length > len(data) {
+			return nil, fmt.Errorf("truncated jpeg segment at offset %d", start)
+		}
+		segment := jpegSegment{marker: marker, data: data[i+2 : i+length]}
+		i += length
+		if marker == 0xDA {
+			i = skipJPEGEntropyData(data, i)
+		}
+		segment.raw = data[start:i]
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// skipJPEGEntropyData 跳过SOS之后的熵编码数据，返回下一个标记的位置
+func skipJPEGEntropyData(data []byte, i int) int {
+	for i+1 < len(data) {
+		if data[i] == 0xFF {
+			next := data[i+1]
+			if next != 0x00 && next != 0xFF && (next < 0xD0 || next > 0xD7) {
+				return i
+			}
+		}
+		i++
+	}
+	return len(data)
+}
+
+// stripJPEGMetadata 去除APP和COM段中的元数据，保留JFIF、Adobe颜色变换和ICC色彩配置；
+// 方向不为1时写入只含方向的EXIF，图片仍按原方向显示
+func stripJPEGMetadata(segments []jpegSegment, orientation int) []byte {
+	var out bytes.Buffer
+	for _, s := range segments {
+		if s.marker == 0xD8 {
+			out.Write(s.raw)
+			if orientation > 1 {
+				out.Write(orientationExif(orientation))
+			}
+			continue
+		}
+		switch {
+		case s.marker == 0xE0, s.marker == 0xEE:
+		case s.marker == 0xE2 && bytes.HasPrefix(s.data, []byte("ICC_PROFILE\x00")):
+		case s.marker >= 0xE1 && s.marker <= 0xEF, s.marker == 0xFE:
+			continue
+		}
+		out.Write(s.raw)
+	}
+	return out.Bytes()
+}
+
+// exifOrientation 读取EXIF中的方向，没有EXIF或方向无效时返回1
+func exifOrientation(segments []jpegSegment) int {
+	for _, s := range segments {
+		if s.marker != 0xE1 || !bytes.HasPrefix(s.data, []byte("Exif\x00\x00")) {
+			continue
+		}
+		tiff := s.data[6:]
+		if len(tiff) < 8 {
+			return 1
+		}
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return 1
+		}
+
+		offset := int(order.Uint32(tiff[4:8]))
+		if offset < 8 || offset+2 > len(tiff) {
+			return 1
+		}
+		count := int(order.Uint16(tiff[offset:]))
+		for k := 0; k < count; k++ {
+			entry := offset + 2 + k*12
+			if entry+12 > len(tiff) {
+				break
+			}
+			if order.Uint16(tiff[entry:]) == 0x0112 {
+				if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+					return v
+				}
+				return 1
+			}
+		}
+		return 1
+	}
+	return 1
+}
+
+// orientationExif 只含方向标签的EXIF APP1段
+func orientationExif(orientation int) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xFF, 0xE1})
+	binary.Write(&buf, binary.BigEndian, uint16(2+6+8+2+12+4))
+	buf.WriteString("Exif\x00\x00")
+	buf.WriteString("MM\x00\x2a")
+	binary.Write(&buf, binary.BigEndian, uint32(8))
+	binary.Write(&buf, binary.BigEndian, uint16(1))
+	// 标签0x0112，类型SHORT，数量1，值左对齐
+	binary.Write(&buf, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&buf, binary.BigEndian, uint32(1))
+	binary.Write(&buf, binary.BigEndian, []uint16{uint16(orientation), 0})
+	binary.Write(&buf, binary.BigEndian, uint32(0))
+	return buf.Bytes()
+}
+
+// jpegQuality 按亮度量化表估算JPEG的压缩质量（IJG质量1-100），没有量化表时返回0
+func jpegQuality(segments []jpegSegment) int {
+	for _, s := range segments {
+		if s.marker != 0xDB {
+			continue
+		}
+		for data := s.data; len(data) > 0; {
+			precision, id := data[0]>>4, data[0]&0x0F
+			size := 64
+			if precision != 0 {
+				size = 128
+			}
+			if len(data) < 1+size {
+				break
+			}
+			if id == 0 {
+				table, std := 0, 0
+				for k := 0; k < 64; k++ {
+					if precision != 0 {
+						table += int(binary.BigEndian.Uint16(data[1+k*2:]))
+					} else {
+						table += int(data[1+k])
+					}
+					std += stdLuminanceQuant[k]
+				}
+				// 量化表 = 标准表 * scale / 100，质量低于50时 scale = 5000/q，否则 scale = 200-2q
+				scale := float64(table) * 100 / float64(std)
+				quality := (200 - scale) / 2
+				if scale > 100 {
+					quality = 5000 / scale
+				}
+				return int(math.Round(math.Max(1, math.Min(100, quality))))
+			}
+			data = data[1+size:]
+		}
+	}
+	return 0
+}
+
+// stripPNGMetadata 去除PNG中的EXIF、文本和时间块，IEND之后的数据被丢弃
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("missing png signature")
+	}
+
+	out := append([]byte{}, pngSignature...)
+	for i := len(pngSignature); i+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("truncated png chunk at offset %d", i)
+		}
+		chunk := string(data[i+4 : i+8])
+		switch chunk {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out = append(out, data[i:end]...)
+		}
+		if chunk == "IEND" {
+			return out, nil
+		}
+		i = end
+	}
+	return nil, fmt.Errorf("missing png IEND chunk")
+}
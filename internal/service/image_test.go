@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+)
+
+// testJPEG 生成给定尺寸和质量的JPEG，在SOI之后插入extra段
+func testJPEG(t *testing.T, width, height, quality int, extra ...[]byte) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}))
+	data := buf.Bytes()
+
+	out := append([]byte{}, data[:2]...)
+	for _, segment := range extra {
+		out = append(out, segment...)
+	}
+	return append(out, data[2:]...)
+}
+
+// testExif 带GPS信息标记的EXIF段，方向为orientation
+func testExif(orientation int) []byte {
+	segment := orientationExif(orientation)
+	return append(segment[:len(segment):len(segment)], 0xFF, 0xFE, 0x00, 0x05, 'G', 'P', 'S')
+}
+
+func testImageProcessor(enabled bool) *ImageProcessor {
+	return NewImageProcessor(config.ImageConfig{
+		Enabled:         enabled,
+		EnabledTenants:  []string{"acme"},
+		DisabledTenants: []string{"globex"},
+		MaxDimension:    64,
+		Quality:         85,
+		MaxPixels:       1 << 20,
+	}, "/")
+}
+
+func TestImageProcessor_Applies(t *testing.T) {
+	p := testImageProcessor(false)
+	assert.False(t, p.Applies("alice", "image/jpeg"))
+	assert.True(t, p.Applies("acme/alice", "image/JPEG; charset=binary"))
+	assert.False(t, p.Applies("acme/alice", "video/mp4"))
+
+	p = testImageProcessor(true)
+	assert.True(t, p.Applies("alice", "image/png"))
+	assert.False(t, p.Applies("globex/bob", "image/png"))
+	assert.False(t, p.Applies("alice", "image/gif"))
+}
+
+func TestImageProcessor_StripJPEG(t *testing.T) {
+	p := testImageProcessor(true)
+	data := testJPEG(t, 32, 16, 80, testExif(6))
+
+	out, contentType, err := p.Process(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/jpeg", contentType)
+	assert.NotContains(t, string(out), "GPS")
+
+	// 尺寸和质量未超限时无损处理，只保留方向
+	segments, err := parseJPEGSegments(out)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, exifOrientation(segments))
+	original, _ := parseJPEGSegments(data)
+	assert.Equal(t, original[len(original)-2].raw, segments[len(segments)-2].raw)
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+	assert.NoError(t, err)
+	assert.Equal(t, 32, cfg.Width)
+}
+
+func TestImageProcessor_RecompressJPEG(t *testing.T) {
+	p := testImageProcessor(true)
+
+	out, _, err := p.Process(testJPEG(t, 128, 32, 80, testExif(8)))
+	assert.NoError(t, err)
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+	assert.NoError(t, err)
+	assert.Equal(t, 64, cfg.Width)
+	assert.Equal(t, 16, cfg.Height)
+	segments, _ := parseJPEGSegments(out)
+	assert.Equal(t, 8, exifOrientation(segments))
+	assert.NotContains(t, string(out), "GPS")
+
+	// 质量高于配置时按配置的质量重新压缩
+	out, _, err = p.Process(testJPEG(t, 32, 32, 98))
+	assert.NoError(t, err)
+	segments, _ = parseJPEGSegments(out)
+	assert.Equal(t, 85, jpegQuality(segments))
+}
+
+func TestImageProcessor_PNG(t *testing.T) {
+	p := testImageProcessor(true)
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 100, 50))))
+	data := buf.Bytes()
+	// 在IHDR之后插入tEXt块
+	text := []byte("\x00\x00\x00\x07tEXtGPS:1,2")
+	text = binary.BigEndian.AppendUint32(text, crc32.ChecksumIEEE(text[4:]))
+	data = append(append(append([]byte{}, data[:33]...), text...), data[33:]...)
+
+	out, contentType, err := p.Process(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", contentType)
+	assert.NotContains(t, string(out), "GPS")
+	cfg, err := png.DecodeConfig(bytes.NewReader(out))
+	assert.NoError(t, err)
+	assert.Equal(t, 64, cfg.Width)
+	assert.Equal(t, 32, cfg.Height)
+
+	stripped, err := stripPNGMetadata(data)
+	assert.NoError(t, err)
+	assert.Equal(t, buf.Bytes(), stripped)
+}
+
+func TestImageProcessor_Invalid(t *testing.T) {
+	p := testImageProcessor(true)
+	_, _, err := p.Process([]byte("not an image"))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
+
+func TestJPEGQuality(t *testing.T) {
+	for _, quality := range []int{50, 75, 90} {
+		segments, err := parseJPEGSegments(testJPEG(t, 8, 8, quality))
+		assert.NoError(t, err)
+		assert.InDelta(t, quality, jpegQuality(segments), 2, "quality %d", quality)
+	}
+}
+
+func TestFitImageSize(t *testing.T) {
+	w, h := fitImageSize(4000, 3000, 2048)
+	assert.Equal(t, 2048, w)
+	assert.Equal(t, 1536, h)
+	w, h = fitImageSize(100, 5000, 2048)
+	assert.Equal(t, 41, w)
+	assert.Equal(t, 2048, h)
+	w, h = fitImageSize(800, 600, 2048)
+	assert.Equal(t, 800, w)
+	assert.Equal(t, 600, h)
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// LatencyTracker 投递耗时分阶段统计
+// 采样的消息在Message.Trace中携带各阶段时间，随Kafka传到推送的节点；首次推送时记录存储、入队和推送阶段的耗时，
+// 并把阶段时间保存到Redis，处理接收者确认的节点据此记录确认阶段和总耗时。耗时计入Prometheus直方图和Redis中的每日分桶
+// 未启用时为nil，记录方法都是空操作
+type LatencyTracker struct {
+	redisStore *store.RedisStore
+	cfg        config.DeliverySLOConfig
+}
+
+// NewLatencyTracker 创建投递耗时统计
+func NewLatencyTracker(redisStore *store.RedisStore, cfg config.DeliverySLOConfig) *LatencyTracker {
+	return &LatencyTracker{
+		redisStore: redisStore,
+		cfg:        cfg,
+	}
+}
+
+// stageSample 一个阶段的耗时
+type stageSample struct {
+	stage   model.DeliveryStage
+	latency time.Duration
+}
+
+// Start 按采样率开始追踪消息，返回发送和持久化的时间，未被采样时返回nil
+func (t *LatencyTracker) Start(sentAt, storedAt time.Time) *model.DeliveryTrace {
+	if t == nil || rand.Float64() >= t.cfg.SampleRate {
+		return nil
+	}
+	return &model.DeliveryTrace{SentAt: sentAt.UnixMilli(), StoredAt: storedAt.UnixMilli()}
+}
+
+// Queued 记录追踪的消息写入Kafka的时间
+func (t *LatencyTracker) Queued(message *model.Message) {
+	if t == nil || message.Trace == nil {
+		return
+	}
+	message.Trace.QueuedAt = time.Now().UnixMilli()
+}
+
+// Pushed 记录追踪的消息首次推送的时间，超大群分批扇出和Kafka重投时只记录一次
+func (t *LatencyTracker) Pushed(message *model.Message) {
+	if t == nil || message.Trace == nil || message.Trace.PushedAt != 0 {
+		return
+	}
+	now := time.Now()
+	trace := message.Trace
+	trace.PushedAt = now.UnixMilli()
+	created, err := t.redisStore.SaveDeliveryTrace(message.ID, trace, t.cfg.TraceTTL)
+	if err != nil {
+		logger.Warn("Failed to save delivery trace", logger.String("message_id", message.ID), logger.ErrorField(err))
+		return
+	}
+	if !created {
+		return
+	}
+
+	samples := []stageSample{{model.DeliveryStageStore, millisBetween(trace.SentAt, trace.StoredAt)}}
+	pushedFrom := trace.StoredAt
+	if trace.QueuedAt != 0 {
+		samples = append(samples, stageSample{model.DeliveryStageQueue, millisBetween(trace.StoredAt, trace.QueuedAt)})
+		pushedFrom = trace.QueuedAt
+	}
+	samples = append(samples, stageSample{model.DeliveryStagePush, millisBetween(pushedFrom, trace.PushedAt)})
+	t.observe(now, samples, nil)
+}
+
+// Acked 记录接收者首次确认追踪的消息，未推送过或推送后超过trace_ttl的确认不计入
+func (t *LatencyTracker) Acked(messageID, userID string, at time.Time) {
+	if t == nil {
+		return
+	}
+	trace, err := t.redisStore.AckDeliveryTrace(messageID, userID)
+	if err != nil {
+		logger.Warn("Failed to get delivery trace", logger.String("message_id", messageID), logger.ErrorField(err))
+		return
+	}
+	if trace == nil {
+		return
+	}
+
+	ackedAt := at.UnixMilli()
+	total := millisBetween(trace.SentAt, ackedAt)
+	within := total <= t.cfg.Target
+	result := "met"
+	if !within {
+		result = "missed"
+	}
+	metrics.DeliverySLO.WithLabelValues(result).Inc()
+	t.observe(at, []stageSample{
+		{model.DeliveryStageAck, millisBetween(trace.PushedAt, ackedAt)},
+		{model.DeliveryStageTotal, total},
+	}, &within)
+}
+
+// observe 把阶段耗时计入直方图和当日的分桶，within不为nil时同时记录是否在目标耗时内
+func (t *LatencyTracker) observe(at time.Time, samples []stageSample, within *bool) {
+	fields := make([]string, 0, len(samples)+1)
+	for _, sample := range samples {
+		metrics.DeliveryStageSeconds.WithLabelValues(string(sample.stage)).Observe(sample.latency.Seconds())
+		fields = append(fields, store.LatencyField(sample.stage, latencyBucket(sample.latency)))
+	}
+	if within != nil && *within {
+		fields = append(fields, store.LatencyFieldWithinTarget)
+	}
+	if err := t.redisStore.IncrLatencyStats(analyticsDay(at), fields, t.cfg.Retention); err != nil {
+		logger.Warn("Failed to record delivery latency", logger.ErrorField(err))
+	}
+}
+
+// Report 日期区间内的投递耗时SLO报告，日期为UTC的 YYYY-MM-DD，包含首尾两天
+func (t *LatencyTracker) Report(from, to string) (*model.DeliverySLOReport, error) {
+	fromDay, err := time.Parse(model.AnalyticsDayLayout, from)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "from must be a date in YYYY-MM-DD format")
+	}
+	toDay, err := time.Parse(model.AnalyticsDayLayout, to)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "to must be a date in YYYY-MM-DD format")
+	}
+	if toDay.Before(fromDay) {
+		return nil, newServiceError(ErrCodeInvalidRequest, "to must not be before from")
+	}
+	if toDay.Sub(fromDay) >= t.cfg.Retention {
+		return nil, newServiceError(ErrCodeInvalidRequest, "date range must not exceed the %s retention", t.cfg.Retention)
+	}
+
+	counts := make(map[string]int64)
+	for day := fromDay; !day.After(toDay); day = day.Add(24 * time.Hour) {
+		stats, err := t.redisStore.GetLatencyStats(day.Format(model.AnalyticsDayLayout))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get delivery latency: %w", err)
+		}
+		for field, n := range stats {
+			counts[field] += n
+		}
+	}
+	return buildSLOReport(from, to, t.cfg, counts), nil
+}
+
+// buildSLOReport 由分桶计数计算各阶段的分位数和SLO达成情况
+func buildSLOReport(from, to string, cfg config.DeliverySLOConfig, counts map[string]int64) *model.DeliverySLOReport {
+	report := &model.DeliverySLOReport{
+		From:         from,
+		To:           to,
+		TargetMs:     cfg.Target.Milliseconds(),
+		Objective:    cfg.Objective,
+		WithinTarget: counts[store.LatencyFieldWithinTarget],
+		Compliance:   1,
+		Stages:       make([]*model.StageLatency, 0, len(model.DeliveryStages)),
+	}
+	for _, stage := range model.DeliveryStages {
+		buckets := make([]int64, len(model.LatencyBucketsMs)+1)
+		var total int64
+		for i, bound := range model.LatencyBucketsMs {
+			buckets[i] = counts[store.LatencyField(stage, strconv.FormatInt(bound, 10))]
+			total += buckets[i]
+		}
+		buckets[len(buckets)-1] = counts[store.LatencyField(stage, "inf")]
+		total += buckets[len(buckets)-1]
+
+		report.Stages = append(report.Stages, &model.StageLatency{
+			Stage: stage,
+			Count: total,
+			P50Ms: bucketPercentile(buckets, total, 0.5),
+			P90Ms: bucketPercentile(buckets, total, 0.9),
+			P99Ms: bucketPercentile(buckets, total, 0.99),
+		})
+		if stage == model.DeliveryStageTotal {
+			report.Deliveries = total
+		}
+	}
+	if report.Deliveries > 0 {
+		report.Compliance = float64(report.WithinTarget) / float64(report.Deliveries)
+	}
+	report.Met = report.Compliance >= cfg.Objective
+	return report
+}
+
+// bucketPercentile 分位数所在分桶的上界，落在最后的inf分桶时返回-1，没有样本时返回0
+func bucketPercentile(buckets []int64, total int64, q float64) int64 {
+	if total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(total)))
+	var cumulative int64
+	for i, n := range buckets {
+		cumulative += n
+		if cumulative >= rank {
+			if i < len(model.LatencyBucketsMs) {
+				return model.LatencyBucketsMs[i]
+			}
+			break
+		}
+	}
+	return -1
+}
+
+// latencyBucket 耗时所在分桶的上界，超过最大分桶时为inf
+func latencyBucket(latency time.Duration) string {
+	for _, bound := range model.LatencyBucketsMs {
+		if latency <= time.Duration(bound)*time.Millisecond {
+			return strconv.FormatInt(bound, 10)
+		}
+	}
+	return "inf"
+}
+
+// millisBetween 两个Unix毫秒时间之差，节点时钟偏差导致为负时记为0
+func millisBetween(from, to int64) time.Duration {
+	if to < from {
+		return 0
+	}
+	return time.Duration(to-from) * time.Millisecond
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+func TestLatencyBucket(t *testing.T) {
+	assert.Equal(t, "5", latencyBucket(0))
+	assert.Equal(t, "500", latencyBucket(500*time.Millisecond))
+	assert.Equal(t, "1000", latencyBucket(500*time.Millisecond+time.Microsecond))
+	assert.Equal(t, "inf", latencyBucket(time.Minute))
+}
+
+func TestBucketPercentile(t *testing.T) {
+	buckets := make([]int64, len(model.LatencyBucketsMs)+1)
+	buckets[0] = 90 // <=5ms
+	buckets[6] = 9  // <=500ms
+	buckets[11] = 1 // inf
+	assert.Equal(t, int64(5), bucketPercentile(buckets, 100, 0.5))
+	assert.Equal(t, int64(500), bucketPercentile(buckets, 100, 0.99))
+	assert.Equal(t, int64(-1), bucketPercentile(buckets, 100, 1))
+	assert.Zero(t, bucketPercentile(make([]int64, len(buckets)), 0, 0.99))
+}
+
+func TestBuildSLOReport(t *testing.T) {
+	cfg := config.DeliverySLOConfig{Target: 500 * time.Millisecond, Objective: 0.99}
+	report := buildSLOReport("2024-01-01", "2024-01-02", cfg, map[string]int64{
+		store.LatencyField(model.DeliveryStageTotal, "250"):  97,
+		store.LatencyField(model.DeliveryStageTotal, "1000"): 3,
+		store.LatencyField(model.DeliveryStagePush, "10"):    100,
+		store.LatencyFieldWithinTarget:                       97,
+	})
+
+	assert.Equal(t, int64(500), report.TargetMs)
+	assert.Equal(t, int64(100), report.Deliveries)
+	assert.InDelta(t, 0.97, report.Compliance, 1e-9)
+	assert.False(t, report.Met)
+	assert.Len(t, report.Stages, len(model.DeliveryStages))
+	for _, stage := range report.Stages {
+		switch stage.Stage {
+		case model.DeliveryStagePush:
+			assert.Equal(t, int64(10), stage.P99Ms)
+		case model.DeliveryStageTotal:
+			assert.Equal(t, int64(250), stage.P50Ms)
+			assert.Equal(t, int64(1000), stage.P99Ms)
+		case model.DeliveryStageQueue:
+			assert.Zero(t, stage.Count)
+		}
+	}
+
+	// 没有投递时视为达标
+	report = buildSLOReport("2024-01-01", "2024-01-01", cfg, nil)
+	assert.Equal(t, float64(1), report.Compliance)
+	assert.True(t, report.Met)
+}
+
+func TestLatencyTracker_ReportValidatesRange(t *testing.T) {
+	tracker := NewLatencyTracker(nil, config.DeliverySLOConfig{Retention: 30 * 24 * time.Hour})
+	for _, tc := range []struct{ from, to string }{
+		{"", "2024-01-01"},
+		{"2024-01-02", "2024-01-01"},
+		{"2024-01-01", "2024-01-31"},
+	} {
+		_, err := tracker.Report(tc.from, tc.to)
+		assert.Equal(t, ErrCodeInvalidRequest, errorCode(err), tc)
+	}
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/netguard"
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxPreviewRedirects 抓取链接预览时最多跟随的重定向次数
+	maxPreviewRedirects = 3
+	// maxPreviewTitle 预览标题最大长度（字符）
+	maxPreviewTitle = 300
+	// maxPreviewDescription 预览描述最大长度（字符）
+	maxPreviewDescription = 1000
+)
+
+// LinkPreviewFetcher 链接预览抓取器，只访问公网地址的80和443端口，结果缓存在Redis中
+type LinkPreviewFetcher struct {
+	client     *http.Client
+	redisStore *store.RedisStore
+	cfg        config.PreviewConfig
+}
+
+// NewLinkPreviewFetcher 创建链接预览抓取器
+func NewLinkPreviewFetcher(redisStore *store.RedisStore, cfg config.PreviewConfig) *LinkPreviewFetcher {
+	// 在建立连接时校验解析后的地址，防止DNS重绑定绕过URL校验
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		Control: netguard.Control,
+	}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	client := &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxPreviewRedirects {
+				return errors.New("too many redirects")
+			}
+			return validatePreviewURL(req.URL)
+		},
+	}
+
+	return &LinkPreviewFetcher{
+		client:     client,
+		redisStore: redisStore,
+		cfg:        cfg,
+	}
+}
+
+// Fetch 获取链接预览，页面没有可用的元数据时返回nil
+func (f *LinkPreviewFetcher) Fetch(rawURL string) (*model.LinkPreview, error) {
+	if preview, ok, err := f.redisStore.GetLinkPreviewCache(rawURL); err == nil && ok {
+		return preview, nil
+	}
+
+	preview, err := f.fetch(rawURL)
+	if err != nil {
+		// 抓取失败也短暂缓存，避免同一链接被反复请求
+		f.redisStore.SetLinkPreviewCache(rawURL, nil, f.cfg.CacheTTL/10)
+		return nil, err
+	}
+
+	ttl := f.cfg.CacheTTL
+	if preview == nil {
+		ttl = f.cfg.CacheTTL / 10
+	}
+	f.redisStore.SetLinkPreviewCache(rawURL, preview, ttl)
+	return preview, nil
+}
+
+// fetch 请求页面并解析OpenGraph元数据
+func (f *LinkPreviewFetcher) fetch(rawURL string) (*model.LinkPreview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if err := validatePreviewURL(u); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", u.Host, resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, nil
+	}
+
+	preview := parseOpenGraph(io.LimitReader(resp.Body, f.cfg.MaxBodySize), resp.Request.URL)
+	if preview != nil {
+		preview.URL = rawURL
+	}
+	return preview, nil
+}
+
+// validatePreviewURL 校验链接的协议、端口和主机名
+func validatePreviewURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+	if u.User != nil {
+		return errors.New("url with credentials is not allowed")
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("host not allowed: %s", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !netguard.IsPublicIP(ip) {
+		return fmt.Errorf("address not allowed: %s", host)
+	}
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		return fmt.Errorf("port not allowed: %s", port)
+	}
+	return nil
+}
+
+// urlTrailingPunct 链接末尾不属于链接的标点
+const urlTrailingPunct = ".,;:!?)]}'\""
+
+// extractURL 提取消息内容中的第一个链接
+func extractURL(content string) string {
+	match := linkPattern.FindString(content)
+	if match == "" {
+		return ""
+	}
+	return normalizeLink(strings.TrimRight(match, urlTrailingPunct))
+}
+
+// normalizeLink 为www.开头的链接补全协议
+func normalizeLink(link string) string {
+	if strings.HasPrefix(strings.ToLower(link), "www.") {
+		return "http://" + link
+	}
+	return link
+}
+
+// parseOpenGraph 解析页面head中的OpenGraph元数据，缺失时回退到title和description
+func parseOpenGraph(r io.Reader, base *url.URL) *model.LinkPreview {
+	var (
+		preview   model.LinkPreview
+		title     string
+		metaDesc  string
+		inTitle   bool
+		tokenizer = html.NewTokenizer(r)
+	)
+
+scan:
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		token := tokenizer.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch token.Data {
+			case "body":
+				break scan
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "meta":
+				key, content := metaAttrs(token)
+				switch key {
+				case "og:title":
+					preview.Title = content
+				case "og:description":
+					preview.Description = content
+				case "og:image", "og:image:url", "og:image:secure_url":
+					if preview.Image == "" {
+						preview.Image = resolvePreviewImage(base, content)
+					}
+				case "og:site_name":
+					preview.SiteName = content
+				case "description":
+					metaDesc = content
+				}
+			}
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = strings.TrimSpace(token.Data)
+			}
+		case html.EndTagToken:
+			switch token.Data {
+			case "title":
+				inTitle = false
+			case "head":
+				break scan
+			}
+		}
+	}
+
+	if preview.Title == "" {
+		preview.Title = title
+	}
+	if preview.Description == "" {
+		preview.Description = metaDesc
+	}
+	if preview.Title == "" && preview.Description == "" && preview.Image == "" {
+		return nil
+	}
+	preview.Title = truncateRunes(strings.TrimSpace(preview.Title), maxPreviewTitle)
+	preview.Description = truncateRunes(strings.TrimSpace(preview.Description), maxPreviewDescription)
+	return &preview
+}
+
+// metaAttrs 读取meta标签的property或name及content
+func metaAttrs(token html.Token) (string, string) {
+	var key, content string
+	for _, attr := range token.Attr {
+		switch attr.Key {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(attr.Val))
+			}
+		case "content":
+			content = attr.Val
+		}
+	}
+	return key, content
+}
+
+// resolvePreviewImage 将图片地址解析为绝对地址，只保留http和https
+func resolvePreviewImage(base *url.URL, raw string) string {
+	ref, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		ref = base.ResolveReference(ref)
+	}
+	if ref.Scheme != "http" && ref.Scheme != "https" {
+		return ""
+	}
+	return ref.String()
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
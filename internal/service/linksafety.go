@@ -0,0 +1,221 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// 链接安全检查结果来源
+const (
+	LinkSourceBlocklist  = "blocklist"
+	LinkSourceReputation = "reputation"
+)
+
+// maxReputationLinks 每条消息最多向信誉服务查询的链接数，其余链接只检查黑名单
+const maxReputationLinks = 5
+
+// LinkSafety 链接安全检查
+// 文本消息中的链接先按域名黑名单检查，未命中时查询外部信誉服务，查询结果缓存在Redis中；
+// 命中的链接按配置被替换或拒绝发送，同时发布治理事件。信誉服务不可用时放行
+type LinkSafety struct {
+	redisStore *store.RedisStore
+	kafkaStore *store.KafkaStore
+	cfg        config.LinkSafetyConfig
+	topic      string
+	blocked    map[string]bool
+	client     *http.Client
+}
+
+// NewLinkSafety 创建链接安全检查，加载配置和黑名单文件中的域名，topic为空时不发布治理事件
+func NewLinkSafety(redisStore *store.RedisStore, kafkaStore *store.KafkaStore, cfg config.LinkSafetyConfig, topic string) (*LinkSafety, error) {
+	domains := append([]string{}, cfg.Blocklist...)
+	for _, file := range cfg.BlocklistFiles {
+		items, err := readListFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read domain blocklist %s: %w", file, err)
+		}
+		domains = append(domains, items...)
+	}
+	blocked := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		if domain = normalizeDomain(domain); domain != "" {
+			blocked[domain] = true
+		}
+	}
+	logger.Info("Link safety domain blocklist loaded", logger.Int("domains", len(blocked)))
+
+	return &LinkSafety{
+		redisStore: redisStore,
+		kafkaStore: kafkaStore,
+		cfg:        cfg,
+		topic:      topic,
+		blocked:    blocked,
+		client:     &http.Client{Timeout: cfg.ReputationTimeout},
+	}, nil
+}
+
+// Check 检查文本消息中的链接，rewrite动作返回替换命中链接后的内容，reject动作命中时返回错误；未设置时原样返回
+func (l *LinkSafety) Check(userID, content string) (string, error) {
+	if l == nil {
+		return content, nil
+	}
+	spans := linkSpans(content)
+	if len(spans) == 0 {
+		return content, nil
+	}
+
+	var flagged [][]int
+	queried := 0
+	for _, span := range spans {
+		link := normalizeLink(content[span[0]:span[1]])
+		verdict := l.checkBlocklist(link)
+		if verdict == nil && l.cfg.ReputationURL != "" && queried < maxReputationLinks {
+			queried++
+			verdict = l.checkReputation(link)
+		}
+		if verdict == nil || verdict.Safe {
+			continue
+		}
+
+		metrics.UnsafeLinks.WithLabelValues(verdict.Source, l.cfg.Action).Inc()
+		l.emit(userID, link, verdict)
+		if l.cfg.Action == config.LinkSafetyReject {
+			return "", newServiceError(ErrCodeUnsafeLink, "message contains an unsafe link")
+		}
+		flagged = append(flagged, span)
+	}
+	if len(flagged) == 0 {
+		return content, nil
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, span := range flagged {
+		b.WriteString(content[last:span[0]])
+		b.WriteString(l.cfg.Replacement)
+		last = span[1]
+	}
+	b.WriteString(content[last:])
+	return b.String(), nil
+}
+
+// checkBlocklist 按链接的主机名及其上级域名匹配黑名单，未命中时返回nil
+func (l *LinkSafety) checkBlocklist(link string) *model.LinkVerdict {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil
+	}
+	host := normalizeDomain(u.Hostname())
+	for host != "" {
+		if l.blocked[host] {
+			return &model.LinkVerdict{Safe: false, Source: LinkSourceBlocklist}
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			break
+		}
+		host = host[i+1:]
+	}
+	return nil
+}
+
+// checkReputation 查询链接的信誉，优先使用Redis中的缓存；查询失败时返回nil且不缓存
+func (l *LinkSafety) checkReputation(link string) *model.LinkVerdict {
+	if cached, err := l.redisStore.GetLinkVerdict(link); err == nil && cached != nil {
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.ReputationTimeout)
+	defer cancel()
+	verdict, err := l.queryReputation(ctx, link)
+	if err != nil {
+		logger.Warn("Failed to check link reputation", logger.String("url", link), logger.ErrorField(err))
+		return nil
+	}
+	if err := l.redisStore.SetLinkVerdict(link, verdict, l.cfg.CacheTTL); err != nil {
+		logger.Warn("Failed to cache link verdict", logger.String("url", link), logger.ErrorField(err))
+	}
+	return verdict
+}
+
+// queryReputation 向信誉服务POST {"url": "..."}，服务返回 {"safe": bool, "category": "..."}
+func (l *LinkSafety) queryReputation(ctx context.Context, link string) (*model.LinkVerdict, error) {
+	body, err := json.Marshal(map[string]string{"url": link})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.cfg.ReputationURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reputation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call reputation service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reputation service returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Safe     bool   `json:"safe"`
+		Category string `json:"category"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode reputation response: %w", err)
+	}
+	return &model.LinkVerdict{Safe: result.Safe, Source: LinkSourceReputation, Category: result.Category}, nil
+}
+
+// emit 发布治理事件
+func (l *LinkSafety) emit(userID, link string, verdict *model.LinkVerdict) {
+	logger.Warn("Unsafe link detected",
+		logger.String("user_id", userID),
+		logger.String("url", link),
+		logger.String("source", verdict.Source),
+		logger.String("category", verdict.Category))
+
+	if l.topic == "" {
+		return
+	}
+	event := &model.ModerationEvent{
+		Type:      "unsafe_link",
+		Rule:      verdict.Source,
+		UserID:    userID,
+		Sample:    link,
+		Timestamp: time.Now().Unix(),
+	}
+	if err := l.kafkaStore.Publish(l.topic, userID, event); err != nil {
+		logger.Error("Failed to publish moderation event", logger.ErrorField(err))
+	}
+}
+
+// linkSpans 消息内容中链接的字节范围，不含末尾的标点
+func linkSpans(content string) [][]int {
+	spans := linkPattern.FindAllStringIndex(content, -1)
+	for _, span := range spans {
+		span[1] = span[0] + len(strings.TrimRight(content[span[0]:span[1]], urlTrailingPunct))
+	}
+	return spans
+}
+
+// normalizeDomain 域名转为小写并去掉通配前缀和末尾的点
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimPrefix(domain, "*.")
+	return strings.Trim(domain, ".")
+}
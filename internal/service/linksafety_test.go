@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+)
+
+func TestLinkSafety_Blocklist(t *testing.T) {
+	l, err := NewLinkSafety(nil, nil, config.LinkSafetyConfig{
+		Blocklist:   []string{"*.Evil.example", "bad.test."},
+		Action:      config.LinkSafetyRewrite,
+		Replacement: "[link removed]",
+	}, "")
+	assert.NoError(t, err)
+
+	content, err := l.Check("user1", "看 https://login.evil.example/x, 和 www.bad.test 以及 https://good.example.")
+	assert.NoError(t, err)
+	assert.Equal(t, "看 [link removed], 和 [link removed] 以及 https://good.example.", content)
+
+	// 只匹配完整的域名层级
+	content, err = l.Check("user1", "https://notevil.example")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://notevil.example", content)
+
+	l.cfg.Action = config.LinkSafetyReject
+	_, err = l.Check("user1", "http://BAD.test/path")
+	assert.Equal(t, ErrCodeUnsafeLink, errorCode(err))
+
+	var nilSafety *LinkSafety
+	content, err = nilSafety.Check("user1", "http://bad.test")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://bad.test", content)
+}
+
+func TestLinkSafety_QueryReputation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URL string `json:"url"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string]interface{}{"safe": req.URL != "http://phish.test", "category": "phishing"})
+	}))
+	defer server.Close()
+
+	l, err := NewLinkSafety(nil, nil, config.LinkSafetyConfig{ReputationURL: server.URL}, "")
+	assert.NoError(t, err)
+
+	verdict, err := l.queryReputation(context.Background(), "http://phish.test")
+	assert.NoError(t, err)
+	assert.False(t, verdict.Safe)
+	assert.Equal(t, LinkSourceReputation, verdict.Source)
+	assert.Equal(t, "phishing", verdict.Category)
+
+	verdict, err = l.queryReputation(context.Background(), "https://example.com")
+	assert.NoError(t, err)
+	assert.True(t, verdict.Safe)
+}
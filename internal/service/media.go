@@ -0,0 +1,129 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/s3"
+)
+
+// MediaService 媒体消息的访问控制
+// 下载地址按消息和用户签名并带有效期；每次下载时重新检查用户能否访问该消息，
+// 消息撤回、被用户删除或用户退出群组后，已签发的地址立即失效
+type MediaService struct {
+	messages *MessageService
+	uploads  *UploadService
+	cfg      config.MediaConfig
+}
+
+// NewMediaService 创建媒体访问控制服务
+func NewMediaService(messages *MessageService, uploads *UploadService, cfg config.MediaConfig) *MediaService {
+	return &MediaService{
+		messages: messages,
+		uploads:  uploads,
+		cfg:      cfg,
+	}
+}
+
+// SignURL 为用户签发媒体消息的下载地址
+func (m *MediaService) SignURL(userID, messageID string) (*model.SignedMediaURL, error) {
+	if _, err := m.authorize(userID, messageID); err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().Add(m.cfg.URLTTL).Unix()
+	query := url.Values{
+		"u": {userID},
+		"e": {strconv.FormatInt(expires, 10)},
+		"s": {signMediaURL(m.cfg.SigningKey, messageID, userID, expires)},
+	}
+	return &model.SignedMediaURL{
+		URL:       strings.TrimRight(m.cfg.BaseURL, "/") + "/media/" + url.PathEscape(messageID) + "?" + query.Encode(),
+		ExpiresAt: expires,
+	}, nil
+}
+
+// Open 校验签名和访问权限后读取媒体文件，rangeHeader透传HTTP Range，调用方负责关闭响应体
+func (m *MediaService) Open(messageID, userID, expires, signature, rangeHeader string) (*http.Response, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || userID == "" || !verifyMediaURL(m.cfg.SigningKey, messageID, userID, expiresAt, signature, time.Now()) {
+		return nil, newServiceError(ErrCodeForbidden, "invalid or expired media url")
+	}
+
+	key, err := m.authorize(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.uploads.OpenObject(key, rangeHeader)
+	var statusErr *s3.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return nil, newServiceError(ErrCodeNotFound, "media of message %s not found", messageID)
+	}
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return nil, newServiceError(ErrCodeInvalidRequest, "requested range not satisfiable")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open media: %w", err)
+	}
+	return resp, nil
+}
+
+// authorize 检查用户能否访问媒体消息，返回文件的对象键
+// 撤回和用户自己删除的消息返回not_found，不再能访问会话时返回forbidden
+func (m *MediaService) authorize(userID, messageID string) (string, error) {
+	message, err := m.messages.GetMessage(userID, messageID)
+	if err != nil {
+		var svcErr *ServiceError
+		if errors.As(err, &svcErr) {
+			return "", err
+		}
+		return "", newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+	if message.IsDeleted() {
+		return "", newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+
+	ok, err := m.messages.canAccessMessage(userID, message)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", newServiceError(ErrCodeForbidden, "no access to message %s", messageID)
+	}
+
+	if !message.Type.IsMedia() {
+		return "", newServiceError(ErrCodeInvalidRequest, "message %s is not a media message", messageID)
+	}
+	key, ok := m.uploads.ObjectKey(message.Content)
+	if !ok {
+		return "", newServiceError(ErrCodeInvalidRequest, "message %s has no uploaded media", messageID)
+	}
+	return key, nil
+}
+
+// signMediaURL 下载地址签名，覆盖消息、用户和过期时间
+func signMediaURL(key, messageID, userID string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%s\n%d", messageID, userID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyMediaURL 校验下载地址的签名和有效期
+func verifyMediaURL(key, messageID, userID string, expires int64, signature string, now time.Time) bool {
+	if now.Unix() > expires {
+		return false
+	}
+	expected := signMediaURL(key, messageID, userID, expires)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/pkg/s3"
+)
+
+func TestVerifyMediaURL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	expires := now.Add(time.Minute).Unix()
+	sig := signMediaURL("secret", "msg1", "alice", expires)
+
+	assert.True(t, verifyMediaURL("secret", "msg1", "alice", expires, sig, now))
+	assert.False(t, verifyMediaURL("secret", "msg1", "alice", expires, sig, now.Add(2*time.Minute)), "expired")
+	assert.False(t, verifyMediaURL("secret", "msg1", "bob", expires, sig, now), "other user")
+	assert.False(t, verifyMediaURL("secret", "msg2", "alice", expires, sig, now), "other message")
+	assert.False(t, verifyMediaURL("secret", "msg1", "alice", expires+60, sig, now), "extended expiry")
+	assert.False(t, verifyMediaURL("other", "msg1", "alice", expires, sig, now), "other key")
+}
+
+func TestUploadService_ObjectKey(t *testing.T) {
+	storage, err := s3.New(s3.Config{Endpoint: "https://s3.example.com", AccessKey: "a", SecretKey: "b"})
+	assert.NoError(t, err)
+
+	uploads := NewUploadService(nil, storage, config.UploadConfig{S3: config.S3Config{Bucket: "media"}})
+	key, ok := uploads.ObjectKey(uploads.fileURL("uploads/2024/01/01/id/a b.mp4"))
+	assert.True(t, ok)
+	assert.Equal(t, "uploads/2024/01/01/id/a b.mp4", key)
+
+	_, ok = uploads.ObjectKey("https://other.example.com/media/x.png")
+	assert.False(t, ok)
+	_, ok = uploads.ObjectKey("https://s3.example.com/media/../secret")
+	assert.False(t, ok)
+	// 隔离区中未扫描的文件
+	_, ok = uploads.ObjectKey(uploads.fileURL("quarantine/2024/01/01/id/a.exe"))
+	assert.False(t, ok)
+
+	public := NewUploadService(nil, storage, config.UploadConfig{S3: config.S3Config{Bucket: "media", PublicURL: "https://cdn.example.com/"}})
+	key, ok = public.ObjectKey("https://cdn.example.com/uploads/x.png")
+	assert.True(t, ok)
+	assert.Equal(t, "uploads/x.png", key)
+}
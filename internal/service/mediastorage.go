@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+const (
+	// mediaLifecycleBatch 每轮删除的最大文件数
+	mediaLifecycleBatch = 100
+	// defaultMediaConsumerLimit 存储用量排行默认条数
+	defaultMediaConsumerLimit = 20
+	// maxMediaConsumerLimit 存储用量排行最多条数
+	maxMediaConsumerLimit = 100
+)
+
+// MediaStorageService 媒体存储统计和生命周期
+// 分片上传完成的文件登记上传者和大小，媒体消息发送和删除时增减文件的引用数和群组的用量；
+// 引用文件的消息全部删除（包括管理员清理和数据保留清理）delete_after之后，主节点删除文件并退还上传者的配额
+type MediaStorageService struct {
+	redisStore *store.RedisStore
+	uploads    *UploadService
+	quota      *QuotaService
+	cfg        config.MediaStorageConfig
+}
+
+// NewMediaStorageService 创建媒体存储统计服务
+func NewMediaStorageService(redisStore *store.RedisStore, uploads *UploadService, cfg config.MediaStorageConfig) *MediaStorageService {
+	return &MediaStorageService{
+		redisStore: redisStore,
+		uploads:    uploads,
+		cfg:        cfg,
+	}
+}
+
+// SetQuota 设置配额服务，删除文件时退还上传者的媒体存储配额
+func (m *MediaStorageService) SetQuota(quota *QuotaService) {
+	m.quota = quota
+}
+
+// Track 登记上传完成的文件并计入上传者的用量，作为分片上传的完成回调
+func (m *MediaStorageService) Track(userID, key string, size int64) {
+	object := &model.MediaObject{Key: key, OwnerID: userID, Size: size, CreatedAt: time.Now().Unix()}
+	if _, err := m.redisStore.SaveMediaObject(object); err != nil {
+		logger.Warn("Failed to track media object", logger.String("key", key), logger.ErrorField(err))
+	}
+}
+
+// CheckGroup 检查群组引用的媒体加上本条消息的文件是否超出group_media_bytes
+func (m *MediaStorageService) CheckGroup(groupID string, msgType model.MessageType, content string) error {
+	if m == nil || m.cfg.GroupMediaBytes <= 0 {
+		return nil
+	}
+	key, ok := m.objectKey(msgType, content)
+	if !ok {
+		return nil
+	}
+	object, err := m.redisStore.GetMediaObject(key)
+	if err != nil {
+		return fmt.Errorf("failed to get media object: %w", err)
+	}
+	if object == nil {
+		return nil
+	}
+	used, err := m.redisStore.GetMediaUsage(store.MediaUsageGroups, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to get group media usage: %w", err)
+	}
+	if used+object.Size > m.cfg.GroupMediaBytes {
+		return quotaExceeded(model.QuotaMediaBytes, model.QuotaSubject(model.QuotaSubjectGroup, groupID), m.cfg.GroupMediaBytes)
+	}
+	return nil
+}
+
+// Reference 媒体消息发送后增加文件的引用数，取消等待中的删除
+func (m *MediaStorageService) Reference(message *model.Message) {
+	if m == nil {
+		return
+	}
+	key, ok := m.objectKey(message.Type, message.Content)
+	if !ok {
+		return
+	}
+	if _, _, err := m.redisStore.ReferenceMediaObject(key, message.GroupID); err != nil {
+		logger.Warn("Failed to reference media object", logger.String("message_id", message.ID), logger.ErrorField(err))
+	}
+}
+
+// Release 媒体消息删除后减少文件的引用数，没有消息引用时文件在delete_after之后删除
+func (m *MediaStorageService) Release(message *model.Message) {
+	if m == nil {
+		return
+	}
+	key, ok := m.objectKey(message.Type, message.Content)
+	if !ok {
+		return
+	}
+	deleteAt := time.Now().Add(m.cfg.DeleteAfter).Unix()
+	if err := m.redisStore.DereferenceMediaObject(key, message.GroupID, deleteAt); err != nil {
+		logger.Warn("Failed to release media object", logger.String("message_id", message.ID), logger.ErrorField(err))
+	}
+}
+
+// Sizes 媒体消息引用的已登记文件的大小，按消息ID索引，未启用时返回空
+func (m *MediaStorageService) Sizes(messages []*model.Message) map[string]int64 {
+	sizes := make(map[string]int64)
+	if m == nil {
+		return sizes
+	}
+	keys := make(map[string]string, len(messages))
+	var objectKeys []string
+	for _, message := range messages {
+		if key, ok := m.objectKey(message.Type, message.Content); ok {
+			keys[message.ID] = key
+			objectKeys = append(objectKeys, key)
+		}
+	}
+	if len(objectKeys) == 0 {
+		return sizes
+	}
+
+	objects, err := m.redisStore.GetMediaObjects(objectKeys)
+	if err != nil {
+		logger.Warn("Failed to get media objects", logger.ErrorField(err))
+		return sizes
+	}
+	for messageID, key := range keys {
+		if object, ok := objects[key]; ok {
+			sizes[messageID] = object.Size
+		}
+	}
+	return sizes
+}
+
+// Cleanup 删除到期的文件并退还配额，作为主节点的后台任务定期执行
+func (m *MediaStorageService) Cleanup(ctx context.Context, fence int64) error {
+	keys, err := m.redisStore.GetExpiredMediaObjects(time.Now().Unix(), mediaLifecycleBatch)
+	if err != nil {
+		return fmt.Errorf("failed to get expired media objects: %w", err)
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		object, err := m.redisStore.GetMediaObject(key)
+		if err != nil {
+			logger.Warn("Failed to get media object", logger.String("key", key), logger.ErrorField(err))
+			continue
+		}
+		// 等待删除期间又被消息引用
+		if object == nil || object.Refs > 0 {
+			m.redisStore.CancelMediaExpiry(key)
+			continue
+		}
+		if err := m.uploads.DeleteObject(key); err != nil {
+			logger.Warn("Failed to delete media object", logger.String("key", key), logger.ErrorField(err))
+			continue
+		}
+		if err := m.redisStore.DeleteMediaObject(key); err != nil {
+			logger.Warn("Failed to delete media object record", logger.String("key", key), logger.ErrorField(err))
+			continue
+		}
+		m.quota.ReleaseMedia(object.OwnerID, object.Size)
+		deleted++
+	}
+
+	if deleted > 0 {
+		logger.Info("Expired media objects deleted", logger.Int("objects", deleted))
+	}
+	return nil
+}
+
+// TopConsumers 存储用量最大的用户或群组，kind为users或groups
+func (m *MediaStorageService) TopConsumers(kind string, limit int) ([]*model.MediaConsumer, error) {
+	var usageKey string
+	switch kind {
+	case "users":
+		usageKey = store.MediaUsageUsers
+	case "groups":
+		usageKey = store.MediaUsageGroups
+	default:
+		return nil, newServiceError(ErrCodeInvalidRequest, "kind must be users or groups")
+	}
+	if limit <= 0 {
+		limit = defaultMediaConsumerLimit
+	}
+	if limit > maxMediaConsumerLimit {
+		limit = maxMediaConsumerLimit
+	}
+
+	consumers, err := m.redisStore.GetTopMediaConsumers(usageKey, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media consumers: %w", err)
+	}
+	return consumers, nil
+}
+
+// objectKey 媒体消息引用的分片上传文件
+func (m *MediaStorageService) objectKey(msgType model.MessageType, content string) (string, bool) {
+	if !msgType.IsMedia() {
+		return "", false
+	}
+	return m.uploads.ObjectKey(content)
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/s3"
+)
+
+func TestMediaStorageService_TopConsumersKind(t *testing.T) {
+	m := NewMediaStorageService(nil, nil, config.MediaStorageConfig{})
+	_, err := m.TopConsumers("tenants", 10)
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
+
+func TestMediaStorageService_ObjectKey(t *testing.T) {
+	storage, err := s3.New(s3.Config{Endpoint: "https://s3.example.com", AccessKey: "a", SecretKey: "b"})
+	assert.NoError(t, err)
+	uploads := NewUploadService(nil, storage, config.UploadConfig{S3: config.S3Config{Bucket: "media"}})
+	m := NewMediaStorageService(nil, uploads, config.MediaStorageConfig{GroupMediaBytes: 1})
+
+	key, ok := m.objectKey(model.MessageTypeImage, uploads.fileURL("uploads/a.png"))
+	assert.True(t, ok)
+	assert.Equal(t, "uploads/a.png", key)
+
+	// 文本消息和外部地址不统计
+	_, ok = m.objectKey(model.MessageTypeText, uploads.fileURL("uploads/a.png"))
+	assert.False(t, ok)
+	_, ok = m.objectKey(model.MessageTypeImage, "https://cdn.example.com/a.png")
+	assert.False(t, ok)
+	assert.NoError(t, m.CheckGroup("g1", model.MessageTypeText, "hello"))
+
+	var disabled *MediaStorageService
+	assert.NoError(t, disabled.CheckGroup("g1", model.MessageTypeImage, uploads.fileURL("uploads/a.png")))
+}
@@ -1,14 +1,19 @@
 package service
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/federation"
+	"github.com/user/im/internal/i18n"
+	"github.com/user/im/internal/metrics"
 	"github.com/user/im/internal/model"
 	"github.com/user/im/internal/store"
-	"github.com/user/im/pkg/snowflake"
-	"github.com/user/im/pkg/websocket"
+	"github.com/user/im/pkg/idgen"
+	"github.com/user/im/pkg/logger"
+	"gorm.io/gorm"
 )
 
 // MessageStoreBackend 消息存储后端接口
@@ -16,15 +21,66 @@ type MessageStoreBackend interface {
 	SaveMessage(*model.Message) error
 	GetMessage(string) (*model.Message, error)
 	GetOfflineMessages(userID string, lastMessageID string, limit int) ([]*model.Message, error)
+	TombstoneMessage(messageID string, deletedAt int64) error
+	MarkMessageDeleted(userID, messageID string) error
+	GetDeletedMessageIDs(userID string, messageIDs []string) (map[string]bool, error)
+	GetTombstones(messageIDs []string) (map[string]int64, error)
+	PurgeMessage(messageID string) error
+	SetMessagePreview(messageID string, preview *model.LinkPreview) error
+	SetMessageVoice(messageID string, voice *model.VoiceMetadata) error
+	UpdateThreadSummary(messageID string, delta int64, lastReplyAt int64) error
+	GetThreadSummaries(messageIDs []string) (map[string]model.ThreadSummary, error)
+	SaveReceipt(messageID, userID string, status model.MessageStatus, at int64) error
+	GetReceipts(messageID string) ([]*model.MessageReceipt, error)
+}
+
+// Deliverer 消息下发接口
+// 单体模式下由本地会话管理器实现，业务节点模式下由网关转发实现
+type Deliverer interface {
+	IsOnline(userID string) bool
+	SendToUser(userID string, message interface{}) error
+	BroadcastToGroup(userIDs []string, message interface{})
+	DisconnectUser(userID string, message interface{}) error
 }
 
 // MessageService 消息服务
 type MessageService struct {
-	storeBackend MessageStoreBackend
-	mysqlStore   *store.MySQLStore
-	redisStore   *store.RedisStore
-	kafkaStore   *store.KafkaStore
-	wsManager    *websocket.Manager
+	storeBackend  MessageStoreBackend
+	mysqlStore    *store.MySQLStore
+	redisStore    *store.RedisStore
+	kafkaStore    *store.KafkaStore
+	deliverer     Deliverer
+	delivery      *DeliveryRouter
+	groupCfg      config.GroupConfig
+	groupEventCfg config.GroupEventConfig
+	pollCfg       config.PollConfig
+	spam          *SpamDetector
+	preview       *LinkPreviewFetcher
+	previewTopic  string
+	voice         *VoiceAnalyzer
+	voiceTopic    string
+	catalog       *i18n.Catalog
+	profiles      *ProfileService
+	events        *EventPublisher
+	analytics     *AnalyticsService
+	stats         *StatsService
+	flags         *FeatureFlagService
+	quota         *QuotaService
+	stickers      *StickerService
+	officials     *OfficialAccountService
+	mediaStorage  *MediaStorageService
+	words         *WordFilter
+	links         *LinkSafety
+	latency       *LatencyTracker
+	spool         *store.Spool
+	messageIDs    idgen.Generator
+	groupIDs      idgen.Generator
+	locker        *store.Locker
+	lookup        *messageLookup
+	federation    *federation.Service
+	pipeline      *sendPipeline
+	notifyCfg     config.NotificationConfig
+	digests       *groupDigests
 }
 
 // NewMessageServiceWithBackend 支持LevelDB/MySQL后端
@@ -32,26 +88,200 @@ func NewMessageServiceWithBackend(
 	storeBackend MessageStoreBackend,
 	redisStore *store.RedisStore,
 	kafkaStore *store.KafkaStore,
-	wsManager *websocket.Manager,
+	deliverer Deliverer,
 ) *MessageService {
 	var mysqlStore *store.MySQLStore
 	if ms, ok := storeBackend.(*store.MySQLStore); ok {
 		mysqlStore = ms
 	}
-	return &MessageService{
+	// 私聊投递按接收者状态选择方式，未传入投递路由时deliverer作为唯一的在线方式
+	delivery, ok := deliverer.(*DeliveryRouter)
+	if !ok {
+		delivery = NewDeliveryRouter()
+		if deliverer != nil {
+			delivery.Online(DeliveryLocal, deliverer)
+		}
+	}
+	s := &MessageService{
 		storeBackend: storeBackend,
 		mysqlStore:   mysqlStore,
 		redisStore:   redisStore,
 		kafkaStore:   kafkaStore,
-		wsManager:    wsManager,
+		deliverer:    deliverer,
+		delivery:     delivery,
+		groupCfg: config.GroupConfig{
+			MaxMembers:        500,
+			ChannelMaxMembers: 100000,
+			FanoutBatchSize:   1000,
+			CacheTTL:          24 * time.Hour,
+			Receipts:          model.ReceiptsFull,
+			ChannelReceipts:   model.ReceiptsAggregate,
+			ReceiptTTL:        7 * 24 * time.Hour,
+		},
+		groupEventCfg: config.GroupEventConfig{DefaultRemindBefore: 15 * time.Minute},
+		pollCfg:       config.PollConfig{MaxOptions: 10},
+		catalog:       i18n.Builtin(),
+		messageIDs:    idgen.Default(),
+		groupIDs:      idgen.Default(),
+		lookup:        newMessageLookup(redisStore, storeBackend, config.MessageCacheConfig{NegativeTTL: 30 * time.Second}),
+		pipeline:      newSendPipeline(),
+	}
+	s.registerBuiltinInterceptors()
+	s.delivery.Offline(OfflineQueue, s.enqueueOfflineMessage)
+	return s
+}
+
+// SetMessageCacheConfig 设置消息查询缓存，启用本地缓存时订阅其他节点的失效通知
+func (s *MessageService) SetMessageCacheConfig(cfg config.MessageCacheConfig) {
+	s.lookup = newMessageLookup(s.redisStore, s.storeBackend, cfg)
+	s.lookup.subscribe()
+}
+
+// SetIDGenerators 设置消息ID和群组（含群成员）ID的生成器，未设置时使用全局生成器
+func (s *MessageService) SetIDGenerators(messages, groups idgen.Generator) {
+	s.messageIDs = messages
+	s.groupIDs = groups
+}
+
+// SetGroupConfig 设置群组规模限制
+func (s *MessageService) SetGroupConfig(cfg config.GroupConfig) {
+	s.groupCfg = cfg
+}
+
+// SetGroupEventConfig 设置群活动消息的提醒时间
+func (s *MessageService) SetGroupEventConfig(cfg config.GroupEventConfig) {
+	s.groupEventCfg = cfg
+}
+
+// SetPollConfig 设置群投票消息的选项数上限
+func (s *MessageService) SetPollConfig(cfg config.PollConfig) {
+	s.pollCfg = cfg
+}
+
+// SetEventPublisher 设置规范事件发布器，为nil时不发布事件
+func (s *MessageService) SetEventPublisher(events *EventPublisher) {
+	s.events = events
+}
+
+// SetAnalytics 设置运营统计，为nil时不统计
+func (s *MessageService) SetAnalytics(analytics *AnalyticsService) {
+	s.analytics = analytics
+}
+
+// SetStats 设置运行统计，用于计算消息发送速率
+func (s *MessageService) SetStats(stats *StatsService) {
+	s.stats = stats
+}
+
+// SetFeatureFlags 设置功能开关，未设置时所有功能按默认开启
+func (s *MessageService) SetFeatureFlags(flags *FeatureFlagService) {
+	s.flags = flags
+}
+
+// SetQuota 设置配额，未设置时不限制
+func (s *MessageService) SetQuota(quota *QuotaService) {
+	s.quota = quota
+}
+
+// SetStickers 设置表情包，表情包消息发送前校验表情包和表情是否存在
+func (s *MessageService) SetStickers(stickers *StickerService) {
+	s.stickers = stickers
+}
+
+// SetOfficialAccounts 设置公众号服务，未设置时不能发送菜单消息
+func (s *MessageService) SetOfficialAccounts(officials *OfficialAccountService) {
+	s.officials = officials
+}
+
+// SetMediaStorage 设置媒体存储统计，媒体消息发送和删除时增减文件的引用，未设置时不统计
+func (s *MessageService) SetMediaStorage(mediaStorage *MediaStorageService) {
+	s.mediaStorage = mediaStorage
+}
+
+// SetSpamDetector 设置垃圾消息检测器，未设置时不检测
+func (s *MessageService) SetSpamDetector(detector *SpamDetector) {
+	s.spam = detector
+}
+
+// SetWordFilter 设置敏感词过滤器，文本消息发送前过滤，未设置时不过滤
+func (s *MessageService) SetWordFilter(words *WordFilter) {
+	s.words = words
+}
+
+// SetLinkSafety 设置链接安全检查，文本消息发送前检查其中的链接，未设置时不检查
+func (s *MessageService) SetLinkSafety(links *LinkSafety) {
+	s.links = links
+}
+
+// SetLatencyTracker 设置投递耗时统计，未设置时不记录
+func (s *MessageService) SetLatencyTracker(latency *LatencyTracker) {
+	s.latency = latency
+}
+
+// SetLinkPreview 设置链接预览抓取器，带链接的文本消息发送后投递到topic异步抓取预览
+func (s *MessageService) SetLinkPreview(fetcher *LinkPreviewFetcher, topic string) {
+	s.preview = fetcher
+	s.previewTopic = topic
+}
+
+// ParseUserPriority 解析终端用户请求的消息优先级，紧急优先级只开放给凭API密钥发送的服务间消息
+func ParseUserPriority(raw string) (model.MessagePriority, error) {
+	priority, err := model.ParseMessagePriority(raw)
+	if err != nil {
+		return "", newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	if priority == model.MessagePriorityUrgent {
+		return "", newServiceError(ErrCodeInvalidRequest, "urgent priority is only available to service messages")
+	}
+	return priority, nil
+}
+
+// saveMessage 保存经过拦截器的消息，失败时撤销拦截器登记的操作，如退还配额
+func (s *MessageService) saveMessage(req *SendRequest, message *model.Message) error {
+	if err := s.storeBackend.SaveMessage(message); err != nil {
+		req.abort()
+		return fmt.Errorf("failed to save message: %w", err)
 	}
+	return nil
 }
 
 // SendPrivateMessage 发送私聊消息
-func (s *MessageService) SendPrivateMessage(senderID, receiverID string, msgType model.MessageType, content string) (*model.Message, error) {
+func (s *MessageService) SendPrivateMessage(senderID, receiverID string, msgType model.MessageType, content string, priority model.MessagePriority) (*model.Message, error) {
+	return s.sendPrivateMessage(senderID, receiverID, "", msgType, content, priority)
+}
+
+// sendPrivateMessage 发送私聊消息，threadID不为空时作为该根消息的话题回复
+func (s *MessageService) sendPrivateMessage(senderID, receiverID, threadID string, msgType model.MessageType, content string, priority model.MessagePriority) (*model.Message, error) {
+	sentAt := time.Now()
+	if msgType == model.MessageTypeSystem {
+		return nil, newServiceError(ErrCodeInvalidRequest, "system messages cannot be sent by users")
+	}
+	if msgType == model.MessageTypeEvent || msgType == model.MessageTypePoll {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s messages can only be sent in group conversations", msgType)
+	}
+	if priority == "" {
+		priority = model.MessagePriorityNormal
+	}
+
+	receiverID, remote := s.resolveReceiver(receiverID)
+	req := &SendRequest{
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+		Remote:     remote,
+		Type:       msgType,
+		Content:    content,
+		Priority:   priority,
+		ThreadID:   threadID,
+		Official:   s.officials.IsOfficial(senderID),
+	}
+	if err := s.pipeline.run(StagePrePersist, req); err != nil {
+		return nil, err
+	}
+
 	// 生成消息ID
-	messageID, err := snowflake.GenerateIDString()
+	messageID, err := s.messageIDs.NextID()
 	if err != nil {
+		req.abort()
 		return nil, fmt.Errorf("failed to generate message ID: %w", err)
 	}
 
@@ -61,62 +291,170 @@ func (s *MessageService) SendPrivateMessage(senderID, receiverID string, msgType
 		SenderID:   senderID,
 		ReceiverID: receiverID,
 		Type:       msgType,
-		Content:    content,
+		Content:    req.Content,
 		Status:     model.MessageStatusSent,
 		Timestamp:  time.Now().Unix(),
+		Priority:   priority,
+		ThreadID:   threadID,
 	}
 
 	// 保存到数据库
-	if err := s.storeBackend.SaveMessage(message); err != nil {
-		return nil, fmt.Errorf("failed to save message: %w", err)
+	s.assignSeq(message)
+	if err := s.saveMessage(req, message); err != nil {
+		return nil, err
 	}
+	storedAt := time.Now()
+	metrics.MessagesSent.WithLabelValues(string(priority)).Inc()
+	s.recordThreadReply(message)
+	s.mediaStorage.Reference(message)
+	s.events.MessageCreated(message)
+	req.Message = message
+	s.pipeline.runPreDeliver(req)
 
 	// 缓存消息
 	s.redisStore.SetMessageCache(messageID, message)
 
-	// 检查接收者是否在线
-	if conn, exists := s.wsManager.GetUserConnection(receiverID); exists {
-		// 在线，直接推送
-		wsMessage := model.WebSocketMessage{
-			Type:      "new_message",
-			Data:      message,
-			Timestamp: time.Now().Unix(),
-			MessageID: messageID,
+	// 更新双方的最近会话，消息请求被接受前不出现在接收者的会话列表中
+	s.redisStore.TouchConversation(senderID, model.ConversationID(model.ConversationTypePrivate, receiverID), message.Timestamp)
+	s.requestPreview(message)
+	s.requestVoiceMetadata(message)
+	if remote {
+		// 发给远程域用户的消息进入该域的发件队列，由对方域投递
+		s.redisStore.AddContact(senderID, receiverID)
+		if err := s.federation.RelayMessage(message); err != nil {
+			return nil, fmt.Errorf("failed to relay message: %w", err)
 		}
+		return message, nil
+	}
+	if req.Pending {
+		s.queueMessageRequest(message)
+		return message, nil
+	}
+	// 缓存和事件中的消息不带阶段时间
+	message.Trace = s.latency.Start(sentAt, storedAt)
+	if err := s.deliverPrivateMessage(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
 
-		data, _ := json.Marshal(wsMessage)
-		conn.SendMessage(data)
+// deliverPrivateMessage 把已保存的私聊消息投递给本域的接收者，接收者在线时直接推送，否则交给离线策略
+func (s *MessageService) deliverPrivateMessage(message *model.Message) error {
+	receiverID := message.ReceiverID
+	s.redisStore.TouchConversation(receiverID, model.ConversationID(model.ConversationTypePrivate, message.SenderID), message.Timestamp)
+	s.redisStore.AddContact(message.SenderID, receiverID)
+	s.countUnread(message)
 
+	route, err := s.delivery.Deliver(receiverID, message, s.PrivateMessageFrame(message))
+	if err != nil {
+		return err
+	}
+	if route != DeliveryOffline {
 		// 更新消息状态为已投递
+		s.latency.Pushed(message)
 		message.Status = model.MessageStatusDelivered
-		s.mysqlStore.UpdateMessageStatus(messageID, model.MessageStatusDelivered)
-	} else {
-		// 离线，发送到Kafka进行异步投递
-		if err := s.kafkaStore.SendOfflineMessage(message); err != nil {
-			return nil, fmt.Errorf("failed to send offline message: %w", err)
-		}
+		s.recordReceipt(message, receiverID, model.MessageStatusDelivered)
+	}
+	return nil
+}
 
-		// 存储到Redis离线消息队列
-		s.redisStore.SetOfflineMessage(receiverID, message)
+// enqueueOfflineMessage 离线队列策略：发送到Kafka由离线消息消费者在接收者上线后推送，同时存入Redis离线队列供同步
+func (s *MessageService) enqueueOfflineMessage(userID string, message *model.Message) error {
+	s.latency.Queued(message)
+	if err := s.sendOfflineMessage(message); err != nil {
+		return fmt.Errorf("failed to send offline message: %w", err)
 	}
+	s.queueOfflineMessage(userID, message)
+	return nil
+}
 
-	return message, nil
+// DeliverQueuedMessage 离线消息消费者调用，接收者已上线时推送并记录已投递，仍离线时等待同步
+func (s *MessageService) DeliverQueuedMessage(message *model.Message) error {
+	route, err := s.delivery.Push(message.ReceiverID, s.PrivateMessageFrame(message))
+	if err != nil || route == DeliveryOffline {
+		return nil
+	}
+	if err := s.MarkDelivered(message.ReceiverID, message); err != nil {
+		logger.Warn("Failed to record delivery", logger.String("message_id", message.ID), logger.ErrorField(err))
+	}
+	return nil
 }
 
 // SendGroupMessage 发送群聊消息
-func (s *MessageService) SendGroupMessage(senderID, groupID string, msgType model.MessageType, content string) (*model.Message, error) {
+func (s *MessageService) SendGroupMessage(senderID, groupID string, msgType model.MessageType, content string, priority model.MessagePriority) (*model.Message, error) {
+	return s.sendGroupMessage(senderID, groupID, "", msgType, content, priority)
+}
+
+// sendGroupMessage 发送群聊消息，threadID不为空时作为该根消息的话题回复
+func (s *MessageService) sendGroupMessage(senderID, groupID, threadID string, msgType model.MessageType, content string, priority model.MessagePriority) (*model.Message, error) {
+	sentAt := time.Now()
+	if msgType == model.MessageTypeSystem {
+		return nil, newServiceError(ErrCodeInvalidRequest, "system messages cannot be sent by users")
+	}
+	if msgType == model.MessageTypeMenu {
+		return nil, newServiceError(ErrCodeInvalidRequest, "menu messages can only be sent in private conversations")
+	}
+	if msgType == model.MessageTypeEvent {
+		if threadID != "" {
+			return nil, newServiceError(ErrCodeInvalidRequest, "event messages cannot be sent as thread replies")
+		}
+		if err := s.validateGroupEvent(content); err != nil {
+			return nil, err
+		}
+	}
+	if msgType == model.MessageTypePoll {
+		if threadID != "" {
+			return nil, newServiceError(ErrCodeInvalidRequest, "poll messages cannot be sent as thread replies")
+		}
+		if err := s.validatePoll(content); err != nil {
+			return nil, err
+		}
+	}
+	if priority == "" {
+		priority = model.MessagePriorityNormal
+	}
+
+	// 其他域的群组只投递给本域成员并转发给群组的主域
+	if s.isRemoteAddress(groupID) {
+		if threadID != "" {
+			return nil, newServiceError(ErrCodeInvalidRequest, "thread replies are not supported in federated groups")
+		}
+		return s.sendRemoteGroupMessage(senderID, federation.Normalize(groupID), msgType, content, priority)
+	}
+
 	// 检查发送者是否为群组成员
-	isMember, err := s.mysqlStore.IsGroupMember(groupID, senderID)
+	member, err := s.mysqlStore.GetGroupMember(groupID, senderID)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, newServiceError(ErrCodeNotMember, "user %s is not a member of group %s", senderID, groupID)
+		}
 		return nil, fmt.Errorf("failed to check group membership: %w", err)
 	}
-	if !isMember {
-		return nil, fmt.Errorf("user %s is not a member of group %s", senderID, groupID)
+
+	group, err := s.mysqlStore.GetGroup(groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	// 发言权限和敏感词过滤级别由群组设置决定
+	req := &SendRequest{
+		SenderID: senderID,
+		GroupID:  groupID,
+		Group:    group,
+		Member:   member,
+		Type:     msgType,
+		Content:  content,
+		Priority: priority,
+		ThreadID: threadID,
+	}
+	if err := s.pipeline.run(StagePrePersist, req); err != nil {
+		return nil, err
 	}
 
 	// 生成消息ID
-	messageID, err := snowflake.GenerateIDString()
+	messageID, err := s.messageIDs.NextID()
 	if err != nil {
+		req.abort()
 		return nil, fmt.Errorf("failed to generate message ID: %w", err)
 	}
 
@@ -126,112 +464,300 @@ func (s *MessageService) SendGroupMessage(senderID, groupID string, msgType mode
 		SenderID:  senderID,
 		GroupID:   groupID,
 		Type:      msgType,
-		Content:   content,
+		Content:   req.Content,
 		Status:    model.MessageStatusSent,
 		Timestamp: time.Now().Unix(),
+		Priority:  priority,
+		ThreadID:  threadID,
 	}
 
 	// 保存到数据库
-	if err := s.storeBackend.SaveMessage(message); err != nil {
-		return nil, fmt.Errorf("failed to save message: %w", err)
+	s.assignSeq(message)
+	if err := s.saveMessage(req, message); err != nil {
+		return nil, err
 	}
+	storedAt := time.Now()
+	metrics.MessagesSent.WithLabelValues(string(priority)).Inc()
+	s.recordThreadReply(message)
+	s.mediaStorage.Reference(message)
+	s.events.MessageCreated(message)
+	req.Message = message
+	s.pipeline.runPreDeliver(req)
 
 	// 缓存消息
 	s.redisStore.SetMessageCache(messageID, message)
+	s.redisStore.SetGroupLastActive(groupID, message.Timestamp)
+	s.requestPreview(message)
+	s.requestVoiceMetadata(message)
+	s.scheduleGroupEventReminder(message)
+	s.schedulePollDeadline(message)
+	message.Trace = s.latency.Start(sentAt, storedAt)
+
+	// 超大群不在发送路径上直接广播，由Kafka消费者分批扇出
+	if !group.IsChannel() {
+		// 获取群组成员
+		members, err := s.groupMemberIDs(groupID)
+		if err != nil {
+			return nil, err
+		}
 
-	// 获取群组成员
-	members, err := s.mysqlStore.GetGroupMembers(groupID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get group members: %w", err)
-	}
-
-	// 提取用户ID列表
-	var userIDs []string
-	for _, member := range members {
-		if member.UserID != senderID { // 不发送给自己
-			userIDs = append(userIDs, member.UserID)
+		// 提取用户ID列表，其他域的成员由其所在的域投递
+		var userIDs []string
+		for _, member := range members {
+			if member != senderID && !s.isRemoteAddress(member) { // 不发送给自己
+				userIDs = append(userIDs, member)
+			}
 		}
+
+		// 广播消息给群组成员
+		s.broadcastGroupMessage(userIDs, message)
+		s.latency.Pushed(message)
+	} else {
+		s.latency.Queued(message)
 	}
 
-	// 广播消息给群组成员
-	s.wsManager.BroadcastToGroup(userIDs, model.WebSocketMessage{
-		Type:      "new_group_message",
-		Data:      message,
-		Timestamp: time.Now().Unix(),
-		MessageID: messageID,
-	})
+	s.relayGroupMessage(message)
 
 	// 发送到Kafka进行异步处理
-	if err := s.kafkaStore.SendGroupMessage(groupID, message); err != nil {
+	if err := s.sendGroupMessageEvent(message); err != nil {
 		return nil, fmt.Errorf("failed to send group message to kafka: %w", err)
 	}
 
 	return message, nil
 }
 
-// SyncOfflineMessages 同步离线消息
-func (s *MessageService) SyncOfflineMessages(userID, lastMessageID string, limit int) ([]*model.Message, error) {
-	// 先从Redis获取离线消息
-	messages, err := s.redisStore.GetOfflineMessages(userID, int64(limit))
+// requestPreview 带链接的文本消息投递到Kafka异步抓取预览
+func (s *MessageService) requestPreview(message *model.Message) {
+	if s.preview == nil || message.Type != model.MessageTypeText || extractURL(message.Content) == "" {
+		return
+	}
+	if !s.flags.Enabled(model.FeatureLinkPreview, message.SenderID, true) {
+		return
+	}
+	if err := s.kafkaStore.SendMessage(s.previewTopic, message); err != nil {
+		logger.Warn("Failed to request link preview", logger.String("message_id", message.ID), logger.ErrorField(err))
+	}
+}
+
+// EnrichMessage 抓取消息中链接的预览，保存后推送message_enriched给会话参与者
+func (s *MessageService) EnrichMessage(message *model.Message) error {
+	rawURL := extractURL(message.Content)
+	if s.preview == nil || rawURL == "" {
+		return nil
+	}
+
+	// 抓取前消息可能已被删除
+	current, err := s.storeBackend.GetMessage(message.ID)
+	if err != nil || current.IsDeleted() {
+		return nil
+	}
+
+	preview, err := s.preview.Fetch(rawURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch link preview: %w", err)
+	}
+	if preview == nil {
+		return nil
+	}
+
+	if err := s.storeBackend.SetMessagePreview(message.ID, preview); err != nil {
+		return fmt.Errorf("failed to save link preview: %w", err)
+	}
+	s.lookup.invalidate(message.ID)
+
+	return s.notifyParticipants(current, model.WebSocketMessage{
+		Type: model.FrameMessageEnriched,
+		Data: model.MessageEnrichedEvent{
+			MessageID:  message.ID,
+			GroupID:    message.GroupID,
+			ReceiverID: message.ReceiverID,
+			Preview:    preview,
+		},
+		Timestamp: time.Now().Unix(),
+		MessageID: message.ID,
+	})
+}
+
+// notifyParticipants 推送消息变更给私聊双方或普通群成员，超大群成员在拉取消息时看到最新状态
+func (s *MessageService) notifyParticipants(message *model.Message, frame model.WebSocketMessage) error {
+	if message.IsPrivateMessage() {
+		s.deliverer.SendToUser(message.ReceiverID, frame)
+		s.deliverer.SendToUser(message.SenderID, frame)
+		return nil
+	}
+
+	group, err := s.mysqlStore.GetGroup(message.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get group: %w", err)
+	}
+	if group.IsChannel() {
+		return nil
+	}
+	userIDs, err := s.groupMemberIDs(message.GroupID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get offline messages from redis: %w", err)
+		return err
+	}
+	s.deliverer.BroadcastToGroup(userIDs, frame)
+	return nil
+}
+
+// SyncOfflineMessages 同步离线消息，返回按请求者过滤删除后的消息、下一页游标和是否还有更多
+// 先消费Redis离线队列，不足limit时从消息存储中按消息ID顺序读取cursor之后的消息；
+// 指定types时不消费Redis离线队列，避免被过滤掉的消息出队后丢失，这些消息仍可从消息存储中读取
+func (s *MessageService) SyncOfflineMessages(userID, cursor string, limit int, types []model.MessageType) ([]*model.Message, string, bool, error) {
+	var messages []*model.Message
+	if len(types) == 0 {
+		queued, err := s.redisStore.GetOfflineMessages(userID, int64(limit))
+		if err != nil {
+			return nil, "", false, fmt.Errorf("failed to get offline messages from redis: %w", err)
+		}
+		messages = queued
 	}
 
-	// 如果Redis中没有足够的消息，从后端获取
-	if len(messages) < limit {
-		backendMessages, err := s.storeBackend.GetOfflineMessages(userID, lastMessageID, limit-len(messages))
+	// 游标只随消息存储的读取位置前进，同一游标总是从同一位置继续
+	nextCursor := cursor
+	hasMore := len(messages) == limit
+	for round := 0; len(messages) < limit; round++ {
+		if round == maxOfflineScanRounds {
+			hasMore = true
+			break
+		}
+		want := limit - len(messages)
+		batch, err := s.storeBackend.GetOfflineMessages(userID, nextCursor, want)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get offline messages from backend: %w", err)
+			return nil, "", false, fmt.Errorf("failed to get offline messages from backend: %w", err)
+		}
+		if len(batch) > 0 {
+			nextCursor = batch[len(batch)-1].ID
 		}
-		messages = append(messages, backendMessages...)
+		matched := filterMessageTypes(batch, types)
+		messages = append(messages, matched...)
 
 		// 如果是LevelDB，拉取后自动删除这些离线消息
 		if ldb, ok := s.storeBackend.(*store.LevelDBStore); ok {
-			for _, msg := range backendMessages {
+			for _, msg := range matched {
 				_ = ldb.RemoveOfflineMessage(userID, msg.ID)
 			}
 		}
+
+		hasMore = len(batch) == want
+		if !hasMore {
+			break
+		}
+	}
+
+	messages, err := s.applyDeletions(userID, s.filterPendingRequests(userID, messages))
+	if err != nil {
+		return nil, "", false, err
 	}
+	return s.localizeMessages(userID, messages), nextCursor, hasMore, nil
+}
 
-	return messages, nil
+// filterMessageTypes 只保留指定类型的消息，types为空时不过滤
+func filterMessageTypes(messages []*model.Message, types []model.MessageType) []*model.Message {
+	if len(types) == 0 {
+		return messages
+	}
+	matched := make([]*model.Message, 0, len(messages))
+	for _, message := range messages {
+		for _, t := range types {
+			if message.Type == t {
+				matched = append(matched, message)
+				break
+			}
+		}
+	}
+	return matched
 }
 
 // SyncGroupMessages 同步群聊消息
-func (s *MessageService) SyncGroupMessages(groupID, lastMessageID string, limit int) ([]*model.Message, error) {
-	return s.mysqlStore.GetGroupMessages(groupID, lastMessageID, limit)
+func (s *MessageService) SyncGroupMessages(groupID, userID, lastMessageID string, limit int) ([]*model.Message, error) {
+	messages, err := s.mysqlStore.GetGroupMessages(groupID, lastMessageID, limit)
+	if err != nil {
+		return nil, err
+	}
+	messages, err = s.applyDeletions(userID, messages)
+	if err != nil {
+		return nil, err
+	}
+	return s.localizeMessages(userID, messages), nil
 }
 
-// AcknowledgeMessage 确认消息
-func (s *MessageService) AcknowledgeMessage(messageID string, status model.MessageStatus) error {
-	return s.mysqlStore.UpdateMessageStatus(messageID, status)
-}
+// GetMessage 获取消息，userID不为空时请求者对自己删除的消息视为不存在
+func (s *MessageService) GetMessage(userID, messageID string) (*model.Message, error) {
+	message, err := s.lookup.get(messageID)
+	if err != nil {
+		return nil, err
+	}
 
-// GetMessage 获取消息
-func (s *MessageService) GetMessage(messageID string) (*model.Message, error) {
-	// 先从缓存获取
-	if message, err := s.redisStore.GetMessageCache(messageID); err == nil {
+	if userID == "" {
 		return message, nil
 	}
-
-	// 缓存未命中，从数据库获取
-	message, err := s.storeBackend.GetMessage(messageID)
+	messages, err := s.applyDeletions(userID, []*model.Message{message})
 	if err != nil {
 		return nil, err
 	}
+	if len(messages) == 0 {
+		return nil, newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+	return s.localizeMessages(userID, messages)[0], nil
+}
 
-	// 更新缓存
-	s.redisStore.SetMessageCache(messageID, message)
-
-	return message, nil
+// CreateGroup 创建群组，成员的隐私设置需允许创建者将其加入
+func (s *MessageService) CreateGroup(name, description, ownerID string, members []string, mode model.GroupMode) (*model.Group, error) {
+	for _, member := range members {
+		if s.isRemoteAddress(member) {
+			return nil, newServiceError(ErrCodeInvalidRequest, "remote user %s must join from their own domain", member)
+		}
+	}
+	if err := s.checkGroupInvites(ownerID, members); err != nil {
+		return nil, err
+	}
+	return s.createGroup(name, description, ownerID, members, mode)
 }
 
-// CreateGroup 创建群组
-func (s *MessageService) CreateGroup(name, description, ownerID string, members []string) (*model.Group, error) {
-	// 生成群组ID
-	groupID, err := snowflake.GenerateIDString()
+// createGroup 创建群组，不检查成员的隐私设置
+func (s *MessageService) createGroup(name, description, ownerID string, members []string, mode model.GroupMode) (*model.Group, error) {
+	if mode == "" {
+		mode = model.GroupModeNormal
+	}
+	if mode != model.GroupModeNormal && mode != model.GroupModeChannel {
+		return nil, fmt.Errorf("invalid group mode: %s", mode)
+	}
+	if limit := s.memberLimit(mode); len(members) > limit {
+		return nil, fmt.Errorf("group members exceed limit %d", limit)
+	}
+	if err := s.quota.CheckGroupSize(ownerID, int64(len(members))); err != nil {
+		return nil, err
+	}
+
+	// 生成群组ID和成员记录，ID生成失败时还没有扣减配额
+	groupID, err := s.groupIDs.NextID()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate group ID: %w", err)
 	}
+	records := make([]*model.GroupMember, 0, len(members))
+	for _, userID := range members {
+		memberID, err := s.groupIDs.NextID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate group member ID: %w", err)
+		}
+		member := &model.GroupMember{
+			ID:       memberID,
+			GroupID:  groupID,
+			UserID:   userID,
+			Role:     "member",
+			JoinedAt: time.Now(),
+		}
+		if userID == ownerID {
+			member.Role = "owner"
+		}
+		records = append(records, member)
+	}
+
+	if err := s.quota.ConsumeGroup(ownerID); err != nil {
+		return nil, err
+	}
 
 	// 创建群组
 	group := &model.Group{
@@ -239,41 +765,56 @@ func (s *MessageService) CreateGroup(name, description, ownerID string, members
 		Name:        name,
 		Description: description,
 		OwnerID:     ownerID,
+		Mode:        mode,
 		Members:     members,
+		Settings:    model.GroupSettings{PostPolicy: model.PostPolicyAll},
 	}
 
 	if err := s.mysqlStore.CreateGroup(group); err != nil {
+		s.quota.ReleaseGroup(ownerID)
 		return nil, fmt.Errorf("failed to create group: %w", err)
 	}
 
-	// 添加群组成员
-	for _, userID := range members {
-		memberID, _ := snowflake.GenerateIDString()
-		member := &model.GroupMember{
-			ID:       memberID,
-			GroupID:  groupID,
-			UserID:   userID,
-			Role:     "member",
-			JoinedAt: time.Now(),
-		}
-
-		if userID == ownerID {
-			member.Role = "owner"
-		}
-
+	// 添加群组成员，失败时群组未创建完成，退还建群配额
+	for _, member := range records {
 		if err := s.mysqlStore.AddGroupMember(member); err != nil {
+			s.quota.ReleaseGroup(ownerID)
 			return nil, fmt.Errorf("failed to add group member: %w", err)
 		}
 	}
 
-	// 更新Redis缓存
-	s.redisStore.SetGroupMembers(groupID, members)
+	// 更新Redis缓存，超大群不缓存成员
+	if group.IsChannel() {
+		s.checkGroupCacheWrite(groupID, s.redisStore.SetGroupMemberCountFenced(groupID, int64(len(members)), nil))
+	} else {
+		s.checkGroupCacheWrite(groupID, s.redisStore.FillGroupMembers(groupID, members, s.groupCfg.CacheTTL, nil))
+	}
+	for _, userID := range members {
+		s.events.MemberJoined(groupID, userID)
+	}
 
+	s.publishSystemMessage(groupID, model.SystemEventGroupCreated, map[string]string{"owner": ownerID, "name": name})
 	return group, nil
 }
 
 // JoinGroup 加入群组
 func (s *MessageService) JoinGroup(groupID, userID string) error {
+	if s.isRemoteAddress(groupID) {
+		return s.changeRemoteMembership(federation.Normalize(groupID), userID, true)
+	}
+	if err := s.withGroupLock(groupID, func(lock *store.Lock) error {
+		return s.addMember(groupID, userID, lock)
+	}); err != nil {
+		return err
+	}
+	s.events.MemberJoined(groupID, userID)
+
+	s.publishSystemMessage(groupID, model.SystemEventMemberJoined, map[string]string{"user": userID})
+	return nil
+}
+
+// addMember 持有群组锁时检查人数上限并添加成员，同一群组的并发加入不会超过上限
+func (s *MessageService) addMember(groupID, userID string, lock *store.Lock) error {
 	// 检查是否已经是群组成员
 	isMember, err := s.mysqlStore.IsGroupMember(groupID, userID)
 	if err != nil {
@@ -283,8 +824,24 @@ func (s *MessageService) JoinGroup(groupID, userID string) error {
 		return fmt.Errorf("user %s is already a member of group %s", userID, groupID)
 	}
 
+	// 检查群组人数上限
+	group, err := s.mysqlStore.GetGroup(groupID)
+	if err != nil {
+		return fmt.Errorf("failed to get group: %w", err)
+	}
+	count, err := s.memberCount(groupID, lock)
+	if err != nil {
+		return err
+	}
+	if limit := s.memberLimit(group.Mode); int(count) >= limit {
+		return fmt.Errorf("group %s is full (limit %d)", groupID, limit)
+	}
+	if err := s.quota.CheckGroupSize(group.OwnerID, count+1); err != nil {
+		return err
+	}
+
 	// 添加群组成员
-	memberID, err := snowflake.GenerateIDString()
+	memberID, err := s.groupIDs.NextID()
 	if err != nil {
 		return fmt.Errorf("failed to generate member ID: %w", err)
 	}
@@ -302,13 +859,28 @@ func (s *MessageService) JoinGroup(groupID, userID string) error {
 	}
 
 	// 更新Redis缓存
-	s.redisStore.AddGroupMember(groupID, userID)
-
+	s.checkGroupCacheWrite(groupID, s.redisStore.AddGroupMemberFenced(groupID, userID, lock))
 	return nil
 }
 
 // LeaveGroup 离开群组
 func (s *MessageService) LeaveGroup(groupID, userID string) error {
+	if s.isRemoteAddress(groupID) {
+		return s.changeRemoteMembership(federation.Normalize(groupID), userID, false)
+	}
+	if err := s.withGroupLock(groupID, func(lock *store.Lock) error {
+		return s.removeMember(groupID, userID, lock)
+	}); err != nil {
+		return err
+	}
+	s.events.MemberLeft(groupID, userID)
+
+	s.publishSystemMessage(groupID, model.SystemEventMemberLeft, map[string]string{"user": userID})
+	return nil
+}
+
+// removeMember 持有群组锁时移除成员
+func (s *MessageService) removeMember(groupID, userID string, lock *store.Lock) error {
 	// 检查是否为群组成员
 	isMember, err := s.mysqlStore.IsGroupMember(groupID, userID)
 	if err != nil {
@@ -324,14 +896,22 @@ func (s *MessageService) LeaveGroup(groupID, userID string) error {
 	}
 
 	// 更新Redis缓存
-	s.redisStore.RemoveGroupMember(groupID, userID)
-
+	s.checkGroupCacheWrite(groupID, s.redisStore.RemoveGroupMemberFenced(groupID, userID, lock))
+	s.redisStore.RemoveChannelCursor(groupID, userID)
 	return nil
 }
 
 // GetGroup 获取群组信息
 func (s *MessageService) GetGroup(groupID string) (*model.Group, error) {
-	return s.mysqlStore.GetGroup(groupID)
+	group, err := s.mysqlStore.GetGroup(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if count, err := s.GetMemberCount(groupID); err == nil {
+		group.MemberCount = count
+	}
+	return group, nil
 }
 
 // GetGroupMembers 获取群组成员
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// 消息查询结果来源
+const (
+	lookupLocal    = "local"    // 本地LRU
+	lookupRedis    = "redis"    // Redis缓存
+	lookupShared   = "shared"   // 等待同一消息的并发查询
+	lookupStore    = "store"    // 消息存储
+	lookupNegative = "negative" // 负缓存
+	lookupMissing  = "missing"  // 查询存储后确认不存在
+)
+
+// messageLookup 按ID查询消息
+// 依次查询本地LRU（启用时）、Redis缓存、负缓存和消息存储；同一消息的并发未命中只读取一次存储，
+// 不存在的ID写入短期的负缓存。返回的消息为副本，调用方可以修改
+type messageLookup struct {
+	redisStore   *store.RedisStore
+	storeBackend MessageStoreBackend
+	cfg          config.MessageCacheConfig
+	local        *messageLRU
+
+	mu       sync.Mutex
+	inflight map[string]*lookupCall
+}
+
+// lookupCall 进行中的存储读取
+type lookupCall struct {
+	done    chan struct{}
+	message *model.Message
+	err     error
+}
+
+// newMessageLookup 创建消息查询
+func newMessageLookup(redisStore *store.RedisStore, storeBackend MessageStoreBackend, cfg config.MessageCacheConfig) *messageLookup {
+	l := &messageLookup{
+		redisStore:   redisStore,
+		storeBackend: storeBackend,
+		cfg:          cfg,
+		inflight:     make(map[string]*lookupCall),
+	}
+	if cfg.LocalEnabled {
+		l.local = newMessageLRU(cfg.LocalSize, cfg.LocalTTL)
+	}
+	return l
+}
+
+// get 查询消息，不存在时返回not_found
+func (l *messageLookup) get(messageID string) (*model.Message, error) {
+	if message := l.local.get(messageID, time.Now()); message != nil {
+		metrics.MessageLookups.WithLabelValues(lookupLocal).Inc()
+		return copyMessage(message), nil
+	}
+	if message, err := l.redisStore.GetMessageCache(messageID); err == nil {
+		metrics.MessageLookups.WithLabelValues(lookupRedis).Inc()
+		l.local.put(messageID, message, time.Now())
+		return copyMessage(message), nil
+	}
+
+	l.mu.Lock()
+	if call, ok := l.inflight[messageID]; ok {
+		l.mu.Unlock()
+		<-call.done
+		metrics.MessageLookups.WithLabelValues(lookupShared).Inc()
+		if call.err != nil {
+			return nil, call.err
+		}
+		return copyMessage(call.message), nil
+	}
+	call := &lookupCall{done: make(chan struct{})}
+	l.inflight[messageID] = call
+	l.mu.Unlock()
+
+	call.message, call.err = l.load(messageID)
+	l.mu.Lock()
+	delete(l.inflight, messageID)
+	l.mu.Unlock()
+	close(call.done)
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	return copyMessage(call.message), nil
+}
+
+// load 检查负缓存后从消息存储读取，并写入Redis和本地缓存
+func (l *messageLookup) load(messageID string) (*model.Message, error) {
+	if missing, err := l.redisStore.IsMessageMissing(messageID); err == nil && missing {
+		metrics.MessageLookups.WithLabelValues(lookupNegative).Inc()
+		return nil, newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+
+	message, err := l.storeBackend.GetMessage(messageID)
+	if err != nil {
+		if !store.IsNotFound(err) {
+			return nil, err
+		}
+		metrics.MessageLookups.WithLabelValues(lookupMissing).Inc()
+		if err := l.redisStore.SetMessageMissing(messageID, l.cfg.NegativeTTL); err != nil {
+			logger.Warn("Failed to cache missing message", logger.String("message_id", messageID), logger.ErrorField(err))
+		}
+		return nil, newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+
+	metrics.MessageLookups.WithLabelValues(lookupStore).Inc()
+	l.redisStore.SetMessageCache(messageID, message)
+	l.local.put(messageID, message, time.Now())
+	return message, nil
+}
+
+// invalidate 消息变更后删除Redis缓存和各节点的本地缓存
+func (l *messageLookup) invalidate(messageID string) {
+	l.redisStore.DeleteMessageCache(messageID)
+	if l.local == nil {
+		return
+	}
+	l.local.remove(messageID)
+	if err := l.redisStore.PublishMessage(store.MessageCacheChannel, messageID); err != nil {
+		logger.Warn("Failed to publish message cache invalidation", logger.String("message_id", messageID), logger.ErrorField(err))
+	}
+}
+
+// subscribe 接收其他节点的失效通知，删除本地缓存
+func (l *messageLookup) subscribe() {
+	if l.local == nil {
+		return
+	}
+	go func() {
+		pubsub := l.redisStore.Subscribe(store.MessageCacheChannel)
+		defer pubsub.Close()
+		for msg := range pubsub.Channel() {
+			// 通知内容为JSON编码的消息ID
+			var messageID string
+			if err := json.Unmarshal([]byte(msg.Payload), &messageID); err != nil {
+				continue
+			}
+			l.local.remove(messageID)
+		}
+	}()
+}
+
+// copyMessage 复制缓存中的消息，避免调用方修改共享的实例
+func copyMessage(message *model.Message) *model.Message {
+	copied := *message
+	return &copied
+}
+
+// messageLRU 按最近访问淘汰的本地消息缓存，条目在ttl后过期；为nil时不缓存
+type messageLRU struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// lruEntry 本地缓存条目
+type lruEntry struct {
+	id        string
+	message   *model.Message
+	expiresAt time.Time
+}
+
+// newMessageLRU 创建本地缓存
+func newMessageLRU(size int, ttl time.Duration) *messageLRU {
+	return &messageLRU{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// get 获取未过期的消息，未命中时返回nil
+func (c *messageLRU) get(id string, now time.Time) *model.Message {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*lruEntry)
+	if !now.Before(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+		return nil
+	}
+	c.order.MoveToFront(elem)
+	return entry.message
+}
+
+// put 写入消息，超过容量时淘汰最久未访问的条目
+func (c *messageLRU) put(id string, message *model.Message, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[id]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.message = message
+		entry.expiresAt = now.Add(c.ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[id] = c.order.PushFront(&lruEntry{id: id, message: message, expiresAt: now.Add(c.ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).id)
+	}
+}
+
+// remove 删除消息
+func (c *messageLRU) remove(id string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[id]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+	}
+}
+
+// len 缓存的条目数
+func (c *messageLRU) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
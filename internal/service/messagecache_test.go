@@ -0,0 +1,48 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestMessageLRU_Eviction(t *testing.T) {
+	now := time.Now()
+	c := newMessageLRU(2, time.Minute)
+	c.put("1", &model.Message{ID: "1"}, now)
+	c.put("2", &model.Message{ID: "2"}, now)
+	// 访问1后2成为最久未访问的条目
+	assert.NotNil(t, c.get("1", now))
+	c.put("3", &model.Message{ID: "3"}, now)
+
+	assert.Equal(t, 2, c.len())
+	assert.Nil(t, c.get("2", now))
+	assert.NotNil(t, c.get("1", now))
+	assert.NotNil(t, c.get("3", now))
+
+	c.remove("1")
+	assert.Nil(t, c.get("1", now))
+}
+
+func TestMessageLRU_Expiry(t *testing.T) {
+	now := time.Now()
+	c := newMessageLRU(10, time.Second)
+	c.put("1", &model.Message{ID: "1"}, now)
+	assert.NotNil(t, c.get("1", now.Add(500*time.Millisecond)))
+	assert.Nil(t, c.get("1", now.Add(time.Second)))
+	assert.Equal(t, 0, c.len())
+
+	// 未启用本地缓存时为nil
+	var disabled *messageLRU
+	disabled.put("1", &model.Message{ID: "1"}, now)
+	assert.Nil(t, disabled.get("1", now))
+}
+
+func TestCopyMessage(t *testing.T) {
+	cached := &model.Message{ID: "1", Content: "hello"}
+	copied := copyMessage(cached)
+	copied.Content = "changed"
+	assert.Equal(t, "hello", cached.Content)
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+	"github.com/user/im/pkg/logger"
+)
+
+// ModerationService 用户全局处罚服务
+// 处罚状态以Redis为准供发送路径快速检查，MySQL持久化用于Redis数据丢失后恢复
+type ModerationService struct {
+	mysqlStore *store.MySQLStore
+	redisStore *store.RedisStore
+	deliverer  Deliverer
+}
+
+// NewModerationService 创建处罚服务，mysqlStore为nil时处罚只保存在Redis中
+func NewModerationService(mysqlStore *store.MySQLStore, redisStore *store.RedisStore, deliverer Deliverer) *ModerationService {
+	return &ModerationService{
+		mysqlStore: mysqlStore,
+		redisStore: redisStore,
+		deliverer:  deliverer,
+	}
+}
+
+// Restore 将数据库中仍生效的处罚同步到Redis，服务启动时调用
+func (m *ModerationService) Restore() error {
+	if m.mysqlStore == nil {
+		return nil
+	}
+
+	sanctions, err := m.mysqlStore.GetActiveUserSanctions()
+	if err != nil {
+		return fmt.Errorf("failed to load user sanctions: %w", err)
+	}
+	for _, sanction := range sanctions {
+		if err := m.redisStore.SetUserSanction(sanction.UserID, sanction.Type, sanction.Until); err != nil {
+			return fmt.Errorf("failed to restore user sanction: %w", err)
+		}
+	}
+
+	logger.Info("Restored user sanctions", logger.Int("count", len(sanctions)))
+	return nil
+}
+
+// Sanction 对用户施加全局禁言或封禁，duration为0表示永久，封禁会断开用户当前连接
+func (m *ModerationService) Sanction(userID string, sanctionType model.SanctionType, duration time.Duration, reason string) (*model.UserSanction, error) {
+	if sanctionType != model.SanctionMute && sanctionType != model.SanctionBan {
+		return nil, newServiceError(ErrCodeInvalidRequest, "invalid sanction type: %s", sanctionType)
+	}
+	if duration < 0 {
+		return nil, newServiceError(ErrCodeInvalidRequest, "duration must not be negative")
+	}
+
+	id, err := idgen.GenerateIDString()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate sanction ID: %w", err)
+	}
+
+	sanction := &model.UserSanction{
+		ID:        id,
+		UserID:    userID,
+		Type:      sanctionType,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+	if duration > 0 {
+		sanction.Until = time.Now().Add(duration).Unix()
+	}
+
+	if m.mysqlStore != nil {
+		if err := m.mysqlStore.SaveUserSanction(sanction); err != nil {
+			return nil, fmt.Errorf("failed to save user sanction: %w", err)
+		}
+	}
+	if err := m.redisStore.SetUserSanction(userID, sanctionType, sanction.Until); err != nil {
+		return nil, fmt.Errorf("failed to set user sanction: %w", err)
+	}
+
+	if sanctionType == model.SanctionBan {
+		if err := m.deliverer.DisconnectUser(userID, BannedFrame(sanction.Until)); err != nil {
+			logger.Warn("Failed to disconnect banned user",
+				logger.String("user_id", userID), logger.ErrorField(err))
+		}
+	}
+	return sanction, nil
+}
+
+// Lift 解除用户全局处罚
+func (m *ModerationService) Lift(userID string, sanctionType model.SanctionType) error {
+	if m.mysqlStore != nil {
+		if err := m.mysqlStore.DeleteUserSanction(userID, sanctionType); err != nil {
+			return fmt.Errorf("failed to delete user sanction: %w", err)
+		}
+	}
+	if err := m.redisStore.RemoveUserSanction(userID, sanctionType); err != nil {
+		return fmt.Errorf("failed to remove user sanction: %w", err)
+	}
+	return nil
+}
+
+// GetSanctions 获取用户当前生效的处罚
+func (m *ModerationService) GetSanctions(userID string) ([]*model.UserSanction, error) {
+	sanctions := make([]*model.UserSanction, 0)
+	for _, sanctionType := range []model.SanctionType{model.SanctionMute, model.SanctionBan} {
+		until, ok, err := m.redisStore.GetUserSanction(userID, sanctionType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user sanction: %w", err)
+		}
+		if ok {
+			sanctions = append(sanctions, &model.UserSanction{UserID: userID, Type: sanctionType, Until: until})
+		}
+	}
+	return sanctions, nil
+}
+
+// GetBan 获取用户封禁截止时间，Redis不可用时视为未封禁
+func (m *ModerationService) GetBan(userID string) (int64, bool) {
+	until, banned, err := m.redisStore.GetUserSanction(userID, model.SanctionBan)
+	return until, err == nil && banned
+}
+
+// BannedFrame 构造断开被封禁用户前下发的错误帧
+func BannedFrame(until int64) *model.WebSocketMessage {
+	return &model.WebSocketMessage{
+		Type: model.FrameError,
+		Data: map[string]interface{}{
+			"error": "your account has been banned",
+			"code":  ErrCodeBanned,
+			"until": until,
+		},
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// Disconnect 强制断开用户在所有节点上的连接，返回用户当时是否在线，客户端可重新登录
+func (m *ModerationService) Disconnect(userID, reason string) (bool, error) {
+	online := m.deliverer.IsOnline(userID)
+	if err := m.deliverer.DisconnectUser(userID, DisconnectedFrame(reason)); err != nil {
+		return online, fmt.Errorf("failed to disconnect user: %w", err)
+	}
+	return online, nil
+}
+
+// DisconnectedFrame 构造管理员强制断开连接前下发的错误帧
+func DisconnectedFrame(reason string) *model.WebSocketMessage {
+	data := map[string]interface{}{
+		"error": "your session was ended by an administrator",
+		"code":  ErrCodeDisconnected,
+	}
+	if reason != "" {
+		data["reason"] = reason
+	}
+	return &model.WebSocketMessage{
+		Type:      model.FrameError,
+		Data:      data,
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// checkUserSanction 检查发送者是否被全局封禁或禁言
+func checkUserSanction(redisStore *store.RedisStore, userID string) error {
+	if until, banned, err := redisStore.GetUserSanction(userID, model.SanctionBan); err == nil && banned {
+		return sanctionError(ErrCodeBanned, "your account has been banned", until)
+	}
+	if until, muted, err := redisStore.GetUserSanction(userID, model.SanctionMute); err == nil && muted {
+		return sanctionError(ErrCodeUserMuted, "your account has been muted", until)
+	}
+	return nil
+}
+
+// sanctionError 构造处罚错误，限时处罚附带剩余秒数
+func sanctionError(code, message string, until int64) error {
+	err := newServiceError(code, "%s", message)
+	if until > 0 {
+		err.RetryAfter = until - time.Now().Unix()
+	}
+	return err
+}
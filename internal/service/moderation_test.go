@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestModerationService_SanctionValidation(t *testing.T) {
+	m := NewModerationService(nil, nil, nil)
+
+	_, err := m.Sanction("u1", model.SanctionType("kick"), time.Hour, "")
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+	_, err = m.Sanction("u1", model.SanctionMute, -time.Second, "")
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
+
+func TestModerationService_Disconnect(t *testing.T) {
+	var history []string
+	m := NewModerationService(nil, nil, newTestDeliverer("local", &history, "u1"))
+
+	online, err := m.Disconnect("u1", "maintenance")
+	assert.NoError(t, err)
+	assert.True(t, online)
+	online, err = m.Disconnect("u2", "")
+	assert.NoError(t, err)
+	assert.False(t, online)
+	assert.Equal(t, []string{"local:close:u1", "local:close:u2"}, history)
+
+	frame := DisconnectedFrame("maintenance")
+	assert.Equal(t, model.FrameError, frame.Type)
+	assert.Equal(t, "maintenance", frame.Data.(map[string]interface{})["reason"])
+	assert.NotContains(t, DisconnectedFrame("").Data, "reason")
+}
+
+func TestSanctionError_RetryAfter(t *testing.T) {
+	err := sanctionError(ErrCodeUserMuted, "muted", time.Now().Add(time.Minute).Unix()).(*ServiceError)
+	assert.Equal(t, ErrCodeUserMuted, err.Code)
+	assert.InDelta(t, 60, err.RetryAfter, 1)
+
+	// 永久处罚不附带剩余时间
+	err = sanctionError(ErrCodeBanned, "banned", 0).(*ServiceError)
+	assert.Zero(t, err.RetryAfter)
+	assert.Equal(t, ErrCodeBanned, BannedFrame(0).Data.(map[string]interface{})["code"])
+}
+
+func TestUserSanction_IsActive(t *testing.T) {
+	now := time.Now()
+	assert.True(t, (&model.UserSanction{}).IsActive(now), "permanent")
+	assert.True(t, (&model.UserSanction{Until: now.Add(time.Minute).Unix()}).IsActive(now))
+	assert.False(t, (&model.UserSanction{Until: now.Add(-time.Minute).Unix()}).IsActive(now))
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// SetNotificationConfig 设置新消息推送提示中的通知内容和活跃群消息摘要
+func (s *MessageService) SetNotificationConfig(cfg config.NotificationConfig) {
+	s.notifyCfg = cfg
+	s.digests = nil
+	if cfg.DigestWindow > 0 {
+		s.digests = newGroupDigests(cfg.DigestWindow, s.busyGroup, s.sendDigest)
+	}
+}
+
+// notify 为发给userID的新消息推送帧补充通知内容：会话合并键、按消息类型和语言渲染的标题和正文，badge大于0时附带角标
+// 群消息的推送帧由同语言的成员共用，userID为空
+func (s *MessageService) notify(frame model.WebSocketMessage, message *model.Message, userID, lang string, badge int64) model.WebSocketMessage {
+	if !s.notifyCfg.Enabled {
+		return frame
+	}
+
+	push := model.PushOptions{Priority: message.Priority}
+	if frame.Push != nil {
+		push = *frame.Push
+	}
+	if push.Priority == "" {
+		push.Priority = model.MessagePriorityNormal
+	}
+	push.CollapseKey = message.CollapseKey(userID)
+	push.Title, push.Body = s.notificationText(message, lang)
+	push.Badge = badge
+	frame.Push = &push
+	return frame
+}
+
+// notificationText 按消息类型和语言渲染通知标题和正文，语言包中没有该类型的正文时使用通用文案
+func (s *MessageService) notificationText(message *model.Message, lang string) (string, string) {
+	params := map[string]string{
+		"sender":  message.SenderID,
+		"group":   message.GroupID,
+		"content": notificationBody(message.Content, s.notifyCfg.MaxBodyLength),
+	}
+
+	titleKey := "push_title_private"
+	if message.IsGroupMessage() {
+		titleKey = "push_title_group"
+	}
+	bodyKey := "push_body_" + string(message.Type)
+	if !s.catalog.Has(bodyKey) {
+		bodyKey = "push_body_default"
+	}
+	return s.catalog.Render(lang, titleKey, params), s.catalog.Render(lang, bodyKey, params)
+}
+
+// countUnread 私聊消息计入接收者的会话未读数，用于推送提示的角标
+func (s *MessageService) countUnread(message *model.Message) {
+	if !s.notifyCfg.Enabled {
+		return
+	}
+	conversationID := message.CollapseKey(message.ReceiverID)
+	if _, err := s.redisStore.IncrUnread(message.ReceiverID, conversationID); err != nil {
+		logger.Warn("Failed to count unread message", logger.String("message_id", message.ID), logger.ErrorField(err))
+	}
+}
+
+// clearUnread 用户已读会话中的消息后清除该会话的未读数
+func (s *MessageService) clearUnread(userID string, message *model.Message) {
+	if !s.notifyCfg.Enabled || !message.IsPrivateMessage() {
+		return
+	}
+	if err := s.redisStore.ClearUnread(userID, message.CollapseKey(userID)); err != nil {
+		logger.Warn("Failed to clear unread count", logger.String("user_id", userID), logger.ErrorField(err))
+	}
+}
+
+// unreadBadge 用户的未读总数，读取失败时不附带角标
+func (s *MessageService) unreadBadge(userID string) int64 {
+	badge, err := s.redisStore.GetUnreadTotal(userID)
+	if err != nil {
+		logger.Warn("Failed to get unread count", logger.String("user_id", userID), logger.ErrorField(err))
+		return 0
+	}
+	return badge
+}
+
+// notificationBody 截取通知正文，超出时以省略号结尾
+func notificationBody(content string, limit int) string {
+	if limit <= 0 {
+		return content
+	}
+	if truncated := truncateRunes(content, limit); truncated != content {
+		return truncated + "…"
+	}
+	return content
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/i18n"
+	"github.com/user/im/internal/model"
+)
+
+// frameDeliverer 记录广播的推送帧
+type frameDeliverer struct {
+	testDeliverer
+	broadcasts []model.WebSocketMessage
+}
+
+func (d *frameDeliverer) BroadcastToGroup(userIDs []string, message interface{}) {
+	d.broadcasts = append(d.broadcasts, message.(model.WebSocketMessage))
+}
+
+func newNotifyingService(enabled bool) *MessageService {
+	return &MessageService{
+		catalog:   i18n.Builtin(),
+		notifyCfg: config.NotificationConfig{Enabled: enabled, MaxBodyLength: 5},
+	}
+}
+
+func TestNotify_Disabled(t *testing.T) {
+	s := newNotifyingService(false)
+	message := &model.Message{ID: "m1", SenderID: "u1", ReceiverID: "u2", Type: model.MessageTypeText, Content: "hi"}
+
+	// 未启用时保持原有的推送提示，普通消息不带push
+	frame := s.notify(model.NewMessageFrame(model.FrameNewMessage, message), message, "u2", "en", 3)
+	assert.Nil(t, frame.Push)
+	assert.Nil(t, s.PrivateMessageFrame(message).Push)
+}
+
+func TestNotify_PrivateMessage(t *testing.T) {
+	s := newNotifyingService(true)
+	message := &model.Message{ID: "m1", SenderID: "u1", ReceiverID: "u2", Type: model.MessageTypeText, Content: "hello world"}
+
+	frame := s.notify(model.NewMessageFrame(model.FrameNewMessage, message), message, "u2", "en", 3)
+	if assert.NotNil(t, frame.Push) {
+		assert.Equal(t, model.MessagePriorityNormal, frame.Push.Priority)
+		assert.Equal(t, "private:u1", frame.Push.CollapseKey)
+		assert.Equal(t, "u1", frame.Push.Title)
+		assert.Equal(t, "hello…", frame.Push.Body)
+		assert.Equal(t, int64(3), frame.Push.Badge)
+	}
+
+	// 保留按优先级和免打扰生成的提示
+	message.Priority = model.MessagePriorityUrgent
+	frame = s.notify(model.NewMessageFrame(model.FrameNewMessage, message), message, "u2", "en", 1)
+	assert.Equal(t, model.PushSoundUrgent, frame.Push.Sound)
+	assert.True(t, frame.Push.BypassMute)
+	message.Priority = ""
+	frame = s.notify(model.NewQuietMessageFrame(model.FrameNewMessage, message), message, "u2", "en", 1)
+	assert.True(t, frame.Push.Silent)
+}
+
+func TestNotificationText_ByTypeAndLanguage(t *testing.T) {
+	s := newNotifyingService(true)
+	image := &model.Message{SenderID: "u1", GroupID: "g1", Type: model.MessageTypeImage, Content: "https://cdn.example.com/a.png"}
+
+	title, body := s.notificationText(image, "en")
+	assert.Equal(t, "u1 in g1", title)
+	assert.Equal(t, "[Photo]", body)
+	title, body = s.notificationText(image, "zh-CN")
+	assert.Equal(t, "u1（g1）", title)
+	assert.Equal(t, "[图片]", body)
+
+	// 语言包中没有的消息类型使用通用文案
+	_, body = s.notificationText(&model.Message{Type: "location"}, "en")
+	assert.Equal(t, "New message", body)
+
+	// 按字符截取
+	_, body = s.notificationText(&model.Message{Type: model.MessageTypeText, Content: strings.Repeat("好", 6)}, "en")
+	assert.Equal(t, strings.Repeat("好", 5)+"…", body)
+	_, body = s.notificationText(&model.Message{Type: model.MessageTypeText, Content: "short"}, "en")
+	assert.Equal(t, "short", body)
+}
+
+func TestBroadcastLocalized_Notification(t *testing.T) {
+	d := &frameDeliverer{}
+	s := newNotifyingService(true)
+	s.deliverer = d
+	message := &model.Message{ID: "m1", SenderID: "u1", GroupID: "g1", Type: model.MessageTypeText, Content: "hi"}
+
+	// 群消息按语言分组广播，不带角标
+	s.broadcastLocalized([]string{"u2", "u3"}, message, groupMessageFrame)
+	if assert.Len(t, d.broadcasts, 1) {
+		push := d.broadcasts[0].Push
+		assert.Equal(t, "group:g1", push.CollapseKey)
+		assert.Equal(t, "u1（g1）", push.Title)
+		assert.Equal(t, "hi", push.Body)
+		assert.Zero(t, push.Badge)
+	}
+}
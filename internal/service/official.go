@@ -0,0 +1,397 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+const (
+	// maxOfficialIDLength 公众号用户ID的最大长度
+	maxOfficialIDLength = 64
+	// maxOfficialNameLength 公众号名称的最大长度（字符）
+	maxOfficialNameLength = 100
+	// maxMenuLabelLength 菜单项文字的最大长度（字符）
+	maxMenuLabelLength = 16
+	// maxMenuKeyLength 菜单项key的最大长度
+	maxMenuKeyLength = 64
+)
+
+// OfficialAccountService 公众号
+// 公众号通过管理接口保存在Redis中，各节点在内存中保留一份，变更后经Redis频道通知所有节点重新加载；
+// 关注者集合也保存在Redis中，群发时分批读取关注者，逐个以公众号的身份发送私聊消息
+type OfficialAccountService struct {
+	redisStore *store.RedisStore
+	messages   *MessageService
+	cfg        config.OfficialConfig
+
+	mu       sync.RWMutex
+	accounts map[string]*model.OfficialAccount
+}
+
+// NewOfficialAccountService 创建公众号服务，启动前没有可用的公众号
+func NewOfficialAccountService(redisStore *store.RedisStore, messages *MessageService, cfg config.OfficialConfig) *OfficialAccountService {
+	return &OfficialAccountService{
+		redisStore: redisStore,
+		messages:   messages,
+		cfg:        cfg,
+		accounts:   make(map[string]*model.OfficialAccount),
+	}
+}
+
+// Start 加载Redis中的公众号，之后在收到变更通知或定期刷新时重新加载
+func (o *OfficialAccountService) Start() {
+	o.reload()
+
+	go func() {
+		pubsub := o.redisStore.Subscribe(store.OfficialAccountsChannel)
+		defer pubsub.Close()
+		for range pubsub.Channel() {
+			o.reload()
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(o.cfg.RefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			o.reload()
+		}
+	}()
+}
+
+// reload 重新加载公众号
+func (o *OfficialAccountService) reload() {
+	accounts, err := o.redisStore.GetOfficialAccounts()
+	if err != nil {
+		logger.Warn("Failed to load official accounts", logger.ErrorField(err))
+		return
+	}
+
+	o.mu.Lock()
+	changed := !reflect.DeepEqual(o.accounts, accounts)
+	o.accounts = accounts
+	o.mu.Unlock()
+
+	if changed {
+		logger.Info("Official accounts changed", logger.Int("accounts", len(accounts)))
+	}
+}
+
+// IsOfficial 用户是否为公众号；nil服务返回false
+func (o *OfficialAccountService) IsOfficial(userID string) bool {
+	if o == nil {
+		return false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	_, ok := o.accounts[userID]
+	return ok
+}
+
+// IsPinned 公众号是否在所有用户的会话列表中置顶；nil服务返回false
+func (o *OfficialAccountService) IsPinned(userID string) bool {
+	if o == nil {
+		return false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	account, ok := o.accounts[userID]
+	return ok && account.Pinned
+}
+
+// PinnedAccounts 置顶的公众号ID，按名称排序；nil服务返回nil
+func (o *OfficialAccountService) PinnedAccounts() []string {
+	if o == nil {
+		return nil
+	}
+	var ids []string
+	for _, account := range o.List() {
+		if account.Pinned {
+			ids = append(ids, account.ID)
+		}
+	}
+	return ids
+}
+
+// List 全部公众号，按名称和ID排序
+func (o *OfficialAccountService) List() []*model.OfficialAccount {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	accounts := make([]*model.OfficialAccount, 0, len(o.accounts))
+	for _, account := range o.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Name != accounts[j].Name {
+			return accounts[i].Name < accounts[j].Name
+		}
+		return accounts[i].ID < accounts[j].ID
+	})
+	return accounts
+}
+
+// ListForUser 全部公众号及用户的关注状态
+func (o *OfficialAccountService) ListForUser(userID string) ([]*model.OfficialAccountView, error) {
+	followed, err := o.followed(userID)
+	if err != nil {
+		return nil, err
+	}
+	accounts := o.List()
+	views := make([]*model.OfficialAccountView, 0, len(accounts))
+	for _, account := range accounts {
+		views = append(views, &model.OfficialAccountView{OfficialAccount: account, Following: followed[account.ID]})
+	}
+	return views, nil
+}
+
+// AccountForUser 公众号及用户的关注状态，不存在时返回not_found
+func (o *OfficialAccountService) AccountForUser(userID, id string) (*model.OfficialAccountView, error) {
+	account, err := o.account(id)
+	if err != nil {
+		return nil, err
+	}
+	followed, err := o.followed(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.OfficialAccountView{OfficialAccount: account, Following: followed[id]}, nil
+}
+
+// SetAccount 创建或整体替换公众号并通知所有节点
+func (o *OfficialAccountService) SetAccount(account *model.OfficialAccount) error {
+	if err := validateOfficialAccount(account, o.cfg.MaxMenuItems); err != nil {
+		return newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+
+	o.mu.RLock()
+	_, exists := o.accounts[account.ID]
+	count := len(o.accounts)
+	o.mu.RUnlock()
+	if !exists && count >= o.cfg.MaxAccounts {
+		return newServiceError(ErrCodeInvalidRequest, "official accounts exceed limit %d", o.cfg.MaxAccounts)
+	}
+
+	account.UpdatedAt = time.Now().Unix()
+	if err := o.redisStore.SetOfficialAccount(account); err != nil {
+		return fmt.Errorf("failed to save official account: %w", err)
+	}
+	o.notify()
+	return nil
+}
+
+// DeleteAccount 删除公众号及其关注者并通知所有节点，已发送的消息保留，账号本身变回普通用户
+func (o *OfficialAccountService) DeleteAccount(id string) error {
+	deleted, err := o.redisStore.DeleteOfficialAccount(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete official account: %w", err)
+	}
+	if !deleted {
+		return newServiceError(ErrCodeNotFound, "official account %s not found", id)
+	}
+	o.notify()
+	return nil
+}
+
+// Follow 关注公众号，公众号成为用户的联系人，之后的消息不进入消息请求
+func (o *OfficialAccountService) Follow(accountID, userID string) error {
+	if _, err := o.account(accountID); err != nil {
+		return err
+	}
+	if accountID == userID {
+		return newServiceError(ErrCodeInvalidRequest, "official accounts cannot follow themselves")
+	}
+	if _, err := o.redisStore.FollowOfficialAccount(accountID, userID); err != nil {
+		return fmt.Errorf("failed to follow official account: %w", err)
+	}
+	if err := o.redisStore.AddContact(userID, accountID); err != nil {
+		return fmt.Errorf("failed to add contact: %w", err)
+	}
+	return nil
+}
+
+// Unfollow 取消关注公众号，之后不再收到群发；已有的私聊往来不受影响
+func (o *OfficialAccountService) Unfollow(accountID, userID string) error {
+	if _, err := o.account(accountID); err != nil {
+		return err
+	}
+	if _, err := o.redisStore.UnfollowOfficialAccount(accountID, userID); err != nil {
+		return fmt.Errorf("failed to unfollow official account: %w", err)
+	}
+	return nil
+}
+
+// Broadcast 向公众号的全部关注者群发消息，返回群发时的关注者数
+// 群发在后台分批执行，每个关注者收到一条单独的私聊消息；节点在群发过程中退出时剩余的关注者不再收到
+func (o *OfficialAccountService) Broadcast(accountID string, msgType model.MessageType, content string) (int64, error) {
+	if _, err := o.account(accountID); err != nil {
+		return 0, err
+	}
+	switch msgType {
+	case model.MessageTypeSystem:
+		return 0, newServiceError(ErrCodeInvalidRequest, "system messages cannot be broadcast")
+	case model.MessageTypeMenu:
+		if err := o.ValidateMenuMessage(accountID, content); err != nil {
+			return 0, err
+		}
+	}
+
+	followers, err := o.redisStore.CountOfficialFollowers(accountID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count followers: %w", err)
+	}
+	if followers > 0 {
+		go o.broadcast(accountID, msgType, content)
+	}
+	return followers, nil
+}
+
+// broadcast 分批读取关注者并逐个发送，单个关注者发送失败（如隐私设置不允许）时跳过
+func (o *OfficialAccountService) broadcast(accountID string, msgType model.MessageType, content string) {
+	sent, failed := 0, 0
+	var cursor uint64
+	for {
+		followers, next, err := o.redisStore.ScanOfficialFollowers(accountID, cursor, int64(o.cfg.BroadcastBatch))
+		if err != nil {
+			logger.Error("Failed to scan official account followers", logger.String("account_id", accountID), logger.ErrorField(err))
+			break
+		}
+		for _, userID := range followers {
+			if _, err := o.messages.SendPrivateMessage(accountID, userID, msgType, content, model.MessagePriorityNormal); err != nil {
+				failed++
+				metrics.OfficialBroadcastMessages.WithLabelValues("failed").Inc()
+				logger.Debug("Failed to send broadcast message", logger.String("account_id", accountID), logger.String("user_id", userID), logger.ErrorField(err))
+				continue
+			}
+			sent++
+			metrics.OfficialBroadcastMessages.WithLabelValues("sent").Inc()
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	logger.Info("Official account broadcast finished",
+		logger.String("account_id", accountID),
+		logger.Int("sent", sent),
+		logger.Int("failed", failed))
+}
+
+// ValidateMenuMessage 校验菜单消息，只有公众号可以发送；nil服务拒绝菜单消息
+func (o *OfficialAccountService) ValidateMenuMessage(senderID, content string) error {
+	if o == nil {
+		return newServiceError(ErrCodeInvalidRequest, "menu messages are not enabled")
+	}
+	if !o.IsOfficial(senderID) {
+		return newServiceError(ErrCodeForbidden, "only official accounts can send menu messages")
+	}
+	var payload model.MenuPayload
+	if err := json.Unmarshal([]byte(content), &payload); err != nil || payload.Text == "" {
+		return newServiceError(ErrCodeInvalidRequest, "menu content must contain text and items")
+	}
+	if err := validateMenu(payload.Items, o.cfg.MaxMenuItems, false); err != nil {
+		return newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	return nil
+}
+
+// account 按ID获取公众号，不存在时返回not_found
+func (o *OfficialAccountService) account(id string) (*model.OfficialAccount, error) {
+	o.mu.RLock()
+	account, ok := o.accounts[id]
+	o.mu.RUnlock()
+	if !ok {
+		return nil, newServiceError(ErrCodeNotFound, "official account %s not found", id)
+	}
+	return account, nil
+}
+
+// followed 用户关注的公众号
+func (o *OfficialAccountService) followed(userID string) (map[string]bool, error) {
+	ids, err := o.redisStore.GetFollowedOfficialAccounts(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get followed official accounts: %w", err)
+	}
+	followed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		followed[id] = true
+	}
+	return followed, nil
+}
+
+// notify 通知所有节点重新加载，通知失败时本节点立即生效，其他节点等待定期刷新
+func (o *OfficialAccountService) notify() {
+	if err := o.redisStore.PublishMessage(store.OfficialAccountsChannel, time.Now().Unix()); err != nil {
+		logger.Warn("Failed to publish official account change", logger.ErrorField(err))
+		o.reload()
+	}
+}
+
+// validateOfficialAccount 校验公众号定义，常驻菜单可以为空
+func validateOfficialAccount(account *model.OfficialAccount, maxMenuItems int) error {
+	if account.ID == "" || len(account.ID) > maxOfficialIDLength {
+		return fmt.Errorf("official account id must be 1 to %d characters", maxOfficialIDLength)
+	}
+	if account.ID == model.SystemSenderID {
+		return fmt.Errorf("%s cannot be an official account", model.SystemSenderID)
+	}
+	if account.Name == "" || utf8.RuneCountInString(account.Name) > maxOfficialNameLength {
+		return fmt.Errorf("official account name must be 1 to %d characters", maxOfficialNameLength)
+	}
+	if len(account.Menu) == 0 {
+		return nil
+	}
+	return validateMenu(account.Menu, maxMenuItems, false)
+}
+
+// validateMenu 校验菜单项，每项必须且只能有key、url或子菜单之一，子菜单只支持一级
+func validateMenu(items []model.MenuItem, maxItems int, nested bool) error {
+	if len(items) == 0 || len(items) > maxItems {
+		return fmt.Errorf("menu must contain 1 to %d items", maxItems)
+	}
+	for _, item := range items {
+		if item.Label == "" || utf8.RuneCountInString(item.Label) > maxMenuLabelLength {
+			return fmt.Errorf("menu item label must be 1 to %d characters", maxMenuLabelLength)
+		}
+		targets := 0
+		for _, set := range []bool{item.Key != "", item.URL != "", len(item.Items) > 0} {
+			if set {
+				targets++
+			}
+		}
+		if targets != 1 {
+			return fmt.Errorf("menu item %s must have exactly one of key, url or items", item.Label)
+		}
+
+		switch {
+		case len(item.Items) > 0:
+			if nested {
+				return fmt.Errorf("menu item %s: sub menus can only be nested one level", item.Label)
+			}
+			if err := validateMenu(item.Items, maxItems, true); err != nil {
+				return err
+			}
+		case item.URL != "":
+			u, err := url.Parse(item.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("menu item %s: invalid url %s", item.Label, item.URL)
+			}
+		case len(item.Key) > maxMenuKeyLength:
+			return fmt.Errorf("menu item %s: key exceeds %d characters", item.Label, maxMenuKeyLength)
+		}
+	}
+	return nil
+}
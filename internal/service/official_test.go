@@ -0,0 +1,60 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func TestValidateMenu(t *testing.T) {
+	assert.NoError(t, validateMenu([]model.MenuItem{
+		{Label: "最新活动", URL: "https://example.com/events"},
+		{Label: "服务", Items: []model.MenuItem{
+			{Label: "查询订单", Key: "orders"},
+			{Label: "人工客服", Key: "support"},
+		}},
+	}, 5, false))
+
+	for _, items := range [][]model.MenuItem{
+		nil,
+		{{Label: "a", Key: "a"}, {Label: "b", Key: "b"}, {Label: "c", Key: "c"}},
+		{{Label: "", Key: "empty"}},
+		{{Label: "both", Key: "k", URL: "https://example.com"}},
+		{{Label: "none"}},
+		{{Label: "script", URL: "javascript:alert(1)"}},
+		{{Label: "nested", Items: []model.MenuItem{{Label: "sub", Items: []model.MenuItem{{Label: "x", Key: "x"}}}}}},
+	} {
+		assert.Error(t, validateMenu(items, 2, false), "%+v", items)
+	}
+}
+
+func TestValidateOfficialAccount(t *testing.T) {
+	assert.NoError(t, validateOfficialAccount(&model.OfficialAccount{ID: "news", Name: "新闻"}, 5))
+	assert.Error(t, validateOfficialAccount(&model.OfficialAccount{ID: "news"}, 5))
+	assert.Error(t, validateOfficialAccount(&model.OfficialAccount{ID: model.SystemSenderID, Name: "系统"}, 5))
+	assert.Error(t, validateOfficialAccount(&model.OfficialAccount{ID: "news", Name: "新闻", Menu: []model.MenuItem{{Label: "x"}}}, 5))
+}
+
+func TestOfficialAccounts_NilSafe(t *testing.T) {
+	var officials *OfficialAccountService
+	assert.False(t, officials.IsOfficial("news"))
+	assert.False(t, officials.IsPinned("news"))
+	assert.Nil(t, officials.PinnedAccounts())
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(officials.ValidateMenuMessage("news", `{"text":"hi"}`)))
+}
+
+func TestOfficialAccounts_ValidateMenuMessage(t *testing.T) {
+	officials := &OfficialAccountService{
+		cfg:      config.OfficialConfig{MaxMenuItems: 5},
+		accounts: map[string]*model.OfficialAccount{"news": {ID: "news", Name: "新闻", Pinned: true}},
+	}
+	assert.True(t, officials.IsPinned("news"))
+	assert.Equal(t, []string{"news"}, officials.PinnedAccounts())
+
+	assert.NoError(t, officials.ValidateMenuMessage("news", `{"text":"今日要闻","items":[{"label":"查看","url":"https://example.com"}]}`))
+	assert.Equal(t, ErrCodeForbidden, errorCode(officials.ValidateMenuMessage("u1", `{"text":"hi","items":[{"label":"a","key":"a"}]}`)))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(officials.ValidateMenuMessage("news", `{"items":[{"label":"a","key":"a"}]}`)))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(officials.ValidateMenuMessage("news", `{"text":"hi","items":[]}`)))
+}
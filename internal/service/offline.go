@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+// maxOfflineScanRounds 按消息类型过滤时，一次离线同步最多从消息存储读取的批次数
+const maxOfflineScanRounds = 5
+
+// InspectOfflineQueue 查看用户待投递的离线消息，不会消费队列
+func (s *MessageService) InspectOfflineQueue(userID string, limit int) (*model.OfflineQueueReport, error) {
+	report := &model.OfflineQueueReport{UserID: userID, Online: s.deliverer.IsOnline(userID)}
+
+	urgent, corruptedUrgent, err := s.redisStore.PeekOfflineMessages(userID, true, int64(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to peek urgent offline queue: %w", err)
+	}
+	queued, corrupted, err := s.redisStore.PeekOfflineMessages(userID, false, int64(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to peek offline queue: %w", err)
+	}
+	report.Urgent, report.Queued = urgent, queued
+	report.Corrupted = corruptedUrgent + corrupted
+
+	// MySQL中没有独立的离线队列，以未投递状态的私聊消息代替
+	switch backend := s.storeBackend.(type) {
+	case *store.MySQLStore:
+		report.Stored, err = backend.GetPendingMessages(userID, limit)
+	case *store.LevelDBStore:
+		report.Stored, err = backend.GetOfflineMessages(userID, "", limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending messages from backend: %w", err)
+	}
+	return report, nil
+}
+
+// RedeliverMessage 重新投递一条私聊消息，在线时直接推送，离线时重新放入Redis离线队列
+// 客户端按消息ID去重，重复投递是安全的；返回是否已直接推送
+func (s *MessageService) RedeliverMessage(userID, messageID string) (bool, error) {
+	message, err := s.storeBackend.GetMessage(messageID)
+	if err != nil || message.IsDeleted() {
+		return false, newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+	if !message.IsPrivateMessage() || message.ReceiverID != userID {
+		return false, newServiceError(ErrCodeInvalidRequest, "message %s is not a private message to %s", messageID, userID)
+	}
+
+	if route, err := s.delivery.Push(userID, s.PrivateMessageFrame(message)); err == nil && route != DeliveryOffline {
+		if err := s.recordReceipt(message, userID, model.MessageStatusDelivered); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if err := s.redisStore.SetOfflineMessage(userID, message); err != nil {
+		return false, fmt.Errorf("failed to requeue offline message: %w", err)
+	}
+	return false, nil
+}
+
+// ClearOfflineQueue 清空用户的Redis离线队列和LevelDB离线索引，消息本身和MySQL中的历史记录保留
+func (s *MessageService) ClearOfflineQueue(userID string) (int64, error) {
+	cleared, err := s.redisStore.ClearOfflineMessages(userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear offline queue: %w", err)
+	}
+	if ldb, ok := s.storeBackend.(*store.LevelDBStore); ok {
+		n, err := ldb.ClearOfflineMessages(userID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to clear leveldb offline index: %w", err)
+		}
+		cleared += int64(n)
+	}
+	return cleared, nil
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestFilterMessageTypes(t *testing.T) {
+	messages := []*model.Message{
+		{ID: "1", Type: model.MessageTypeText},
+		{ID: "2", Type: model.MessageTypeImage},
+		{ID: "3", Type: model.MessageTypeVideo},
+	}
+	assert.Equal(t, messages, filterMessageTypes(messages, nil))
+
+	types, err := model.ParseMessageTypes("image, video")
+	assert.NoError(t, err)
+	matched := filterMessageTypes(messages, types)
+	assert.Len(t, matched, 2)
+	assert.Equal(t, "2", matched[0].ID)
+	assert.Equal(t, "3", matched[1].ID)
+
+	_, err = model.ParseMessageTypes("text,gif")
+	assert.Error(t, err)
+}
@@ -0,0 +1,443 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/pkg/logger"
+)
+
+// oidcClockSkew 校验ID令牌有效期时允许的时钟偏差
+const oidcClockSkew = time.Minute
+
+// oidcMinJWKSInterval 遇到未知kid时重新获取公钥的最短间隔，避免伪造的令牌反复触发请求
+const oidcMinJWKSInterval = time.Minute
+
+// maxOIDCResponseSize 身份提供方响应的最大字节数
+const maxOIDCResponseSize = 1 << 20
+
+// oidcDiscovery 身份提供方的发现文档
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// jsonWebKey JWKS中的单个公钥，只支持RSA和P-256
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// OIDCProvider 外部身份提供方
+// 首次使用时从发现文档获取端点，签名公钥定期刷新，遇到未知kid时提前刷新
+type OIDCProvider struct {
+	cfg    config.OIDCConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// NewOIDCProvider 创建身份提供方
+func NewOIDCProvider(cfg config.OIDCConfig) (*OIDCProvider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, errors.New("oidc issuer and client_id are required")
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &OIDCProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Issuer 身份提供方地址
+func (p *OIDCProvider) Issuer() string {
+	return p.cfg.Issuer
+}
+
+// ClientID 在身份提供方注册的客户端ID
+func (p *OIDCProvider) ClientID() string {
+	return p.cfg.ClientID
+}
+
+// AuthorizationEndpoint 授权端点，客户端据此发起授权码流程
+func (p *OIDCProvider) AuthorizationEndpoint() (string, error) {
+	discovery, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+	return discovery.AuthorizationEndpoint, nil
+}
+
+// Exchange 用授权码换取ID令牌
+func (p *OIDCProvider) Exchange(code, redirectURI, codeVerifier string) (string, error) {
+	discovery, err := p.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", p.cfg.ClientID)
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var result struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(req, &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	if status != http.StatusOK {
+		if result.Error != "" {
+			return "", newServiceError(ErrCodeForbidden, "identity provider rejected code: %s %s", result.Error, result.ErrorDescription)
+		}
+		return "", fmt.Errorf("token endpoint returned status %d", status)
+	}
+	if result.IDToken == "" {
+		return "", fmt.Errorf("token endpoint returned no id_token")
+	}
+	return result.IDToken, nil
+}
+
+// Verify 校验ID令牌的签名、签发方、受众和有效期，返回其中的声明
+func (p *OIDCProvider) Verify(idToken string) (map[string]interface{}, error) {
+	parts, err := splitJWT(idToken)
+	if err != nil {
+		return nil, err
+	}
+	key, err := p.publicKey(parts.header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(parts, key); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(parts.payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := validateIDTokenClaims(claims, p.cfg.Issuer, p.cfg.ClientID, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// getDiscovery 获取发现文档，获取成功后缓存
+func (p *OIDCProvider) getDiscovery() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loadDiscovery()
+}
+
+// loadDiscovery 获取发现文档，调用方需持有锁
+func (p *OIDCProvider) loadDiscovery() (*oidcDiscovery, error) {
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, p.cfg.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build discovery request: %w", err)
+	}
+	var discovery oidcDiscovery
+	status, err := p.do(req, &discovery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oidc discovery document: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery returned status %d", status)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery issuer mismatch: %s", discovery.Issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery document has no jwks_uri")
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// publicKey 按kid查找签名公钥，公钥过期或kid未知时重新获取
+func (p *OIDCProvider) publicKey(kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, exists := p.lookupKey(kid)
+	stale := time.Since(p.keysFetched) > p.cfg.JWKSRefresh
+	if exists && !stale {
+		return key, nil
+	}
+	if !stale && time.Since(p.keysFetched) < oidcMinJWKSInterval {
+		return nil, ErrInvalidToken
+	}
+
+	if err := p.fetchKeys(); err != nil {
+		if exists {
+			// 刷新失败时继续使用旧公钥，避免身份提供方短暂不可用导致无法登录
+			logger.Warn("Failed to refresh oidc signing keys", logger.ErrorField(err))
+			return key, nil
+		}
+		return nil, err
+	}
+	if key, exists = p.lookupKey(kid); !exists {
+		return nil, ErrInvalidToken
+	}
+	return key, nil
+}
+
+// lookupKey 按kid查找公钥，只有一个公钥且令牌未指定kid时使用该公钥
+func (p *OIDCProvider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, exists := p.keys[kid]
+	return key, exists
+}
+
+// fetchKeys 获取签名公钥，调用方需持有锁
+func (p *OIDCProvider) fetchKeys() error {
+	discovery, err := p.loadDiscovery()
+	if err != nil {
+		return err
+	}
+	p.keysFetched = time.Now()
+
+	req, err := http.NewRequest(http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build jwks request: %w", err)
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	status, err := p.do(req, &jwks)
+	if err != nil {
+		return fmt.Errorf("failed to get oidc signing keys: %w", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("jwks endpoint returned status %d", status)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJSONWebKey(jwk)
+		if err != nil {
+			logger.Warn("Skipping unsupported oidc signing key", logger.String("kid", jwk.Kid), logger.ErrorField(err))
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	return nil
+}
+
+// do 发送请求并解码JSON响应，非200响应同样尝试解码以读取错误信息
+func (p *OIDCProvider) do(req *http.Request, result interface{}) (int, error) {
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOIDCResponseSize)).Decode(result); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// parseJSONWebKey 解析RSA或P-256公钥
+func parseJSONWebKey(jwk jsonWebKey) (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := jwtEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := jwtEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", jwk.Crv)
+		}
+		x, err := jwtEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := jwtEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("point not on curve")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+	}
+}
+
+// verifyJWTSignature 按头部声明的算法校验签名，算法必须与公钥类型匹配，只支持RS256和ES256
+func verifyJWTSignature(parts *jwtParts, key crypto.PublicKey) error {
+	digest := sha256.Sum256([]byte(parts.signingInput))
+	switch parts.header.Alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], parts.signature) != nil {
+			return ErrInvalidToken
+		}
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(parts.signature) != 64 {
+			return ErrInvalidToken
+		}
+		r := new(big.Int).SetBytes(parts.signature[:32])
+		s := new(big.Int).SetBytes(parts.signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return ErrInvalidToken
+		}
+	default:
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// validateIDTokenClaims 校验ID令牌的签发方、受众和有效期
+func validateIDTokenClaims(claims map[string]interface{}, issuer, clientID string, now time.Time) error {
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != issuer {
+		return ErrInvalidToken
+	}
+
+	audiences := claimStrings(claims, "aud")
+	found := false
+	for _, aud := range audiences {
+		if aud == clientID {
+			found = true
+		}
+	}
+	if !found {
+		return ErrInvalidToken
+	}
+	// 多个受众时授权方必须是本客户端
+	if azp, exists := claims["azp"].(string); exists && azp != clientID {
+		return ErrInvalidToken
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.Add(-oidcClockSkew).Unix() >= int64(exp) {
+		return ErrInvalidToken
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(oidcClockSkew).Unix() < int64(nbf) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// claimString 读取字符串声明，支持点号分隔的嵌套路径
+func claimString(claims map[string]interface{}, path string) string {
+	value, _ := claimValue(claims, path).(string)
+	return value
+}
+
+// claimStrings 读取字符串或字符串数组声明，支持点号分隔的嵌套路径
+func claimStrings(claims map[string]interface{}, path string) []string {
+	switch value := claimValue(claims, path).(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		result := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// claimValue 按路径读取声明，完整路径本身是声明名时优先使用
+func claimValue(claims map[string]interface{}, path string) interface{} {
+	if value, exists := claims[path]; exists {
+		return value
+	}
+	var current interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[name]
+	}
+	return current
+}
+
+// mapOIDCRoles 把身份提供方的组或角色映射为IM角色，去重后排序
+func mapOIDCRoles(values []string, mapping map[string]string) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, value := range values {
+		role, exists := mapping[strings.ToLower(value)]
+		if !exists || seen[role] {
+			continue
+		}
+		seen[role] = true
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+)
+
+// signTestJWT 用测试私钥签发JWT
+func signTestJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header, _ := json.Marshal(jwtHeader{Alg: alg, Typ: "JWT", Kid: kid})
+	payload, _ := json.Marshal(claims)
+	signingInput := jwtEncoding.EncodeToString(header) + "." + jwtEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		assert.NoError(t, err)
+		signature = sig
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		assert.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signingInput + "." + jwtEncoding.EncodeToString(signature)
+}
+
+func TestOIDCProvider_Verify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	provider, err := NewOIDCProvider(config.OIDCConfig{Issuer: "https://idp.example.com/", ClientID: "im", JWKSRefresh: time.Hour})
+	assert.NoError(t, err)
+	provider.keys = map[string]crypto.PublicKey{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey}
+	provider.keysFetched = time.Now()
+
+	claims := map[string]interface{}{
+		"iss": "https://idp.example.com",
+		"aud": []string{"im", "other"},
+		"azp": "im",
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	verified, err := provider.Verify(signTestJWT(t, "RS256", "rsa", rsaKey, claims))
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", claimString(verified, "sub"))
+	_, err = provider.Verify(signTestJWT(t, "ES256", "ec", ecKey, claims))
+	assert.NoError(t, err)
+
+	// 算法与公钥类型不匹配、未知kid、受众或签发方不符、已过期都不能通过
+	_, err = provider.Verify(signTestJWT(t, "ES256", "rsa", ecKey, claims))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = provider.Verify(signTestJWT(t, "RS256", "missing", rsaKey, claims))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	for name, value := range map[string]interface{}{
+		"aud": "other",
+		"iss": "https://evil.example.com",
+		"exp": time.Now().Add(-time.Hour).Unix(),
+		"azp": "other",
+	} {
+		modified := map[string]interface{}{}
+		for k, v := range claims {
+			modified[k] = v
+		}
+		modified[name] = value
+		_, err = provider.Verify(signTestJWT(t, "RS256", "rsa", rsaKey, modified))
+		assert.ErrorIs(t, err, ErrInvalidToken, name)
+	}
+}
+
+func TestMapOIDCRoles(t *testing.T) {
+	claims := map[string]interface{}{
+		"groups":       []interface{}{"IM-Admins", "staff", "im-admins"},
+		"realm_access": map[string]interface{}{"roles": []interface{}{"auditors"}},
+	}
+	mapping := map[string]string{"im-admins": "admin", "auditors": "auditor"}
+
+	assert.Equal(t, []string{"admin"}, mapOIDCRoles(claimStrings(claims, "groups"), mapping))
+	assert.Equal(t, []string{"auditor"}, mapOIDCRoles(claimStrings(claims, "realm_access.roles"), mapping))
+	assert.Empty(t, mapOIDCRoles(claimStrings(claims, "missing"), mapping))
+}
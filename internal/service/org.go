@@ -0,0 +1,369 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// maxDepartmentIDLength 部门ID和用户ID的最大长度，与存储层的列宽一致
+const maxDepartmentIDLength = 64
+
+// OrgService 组织架构和通讯录
+// 组织架构由企业目录整体推送；开启部门群时每次同步后为每个部门创建群组，并按部门成员增减群成员
+type OrgService struct {
+	mysqlStore *store.MySQLStore
+	messages   *MessageService
+	cfg        config.OrgConfig
+
+	// syncMu 同一节点上的目录同步和部门群同步串行执行
+	syncMu sync.Mutex
+}
+
+// NewOrgService 创建组织架构服务
+func NewOrgService(mysqlStore *store.MySQLStore, messages *MessageService, cfg config.OrgConfig) *OrgService {
+	return &OrgService{
+		mysqlStore: mysqlStore,
+		messages:   messages,
+		cfg:        cfg,
+	}
+}
+
+// Tree 完整的部门树，同级部门按排序值和名称排列
+func (o *OrgService) Tree() ([]*model.DepartmentNode, error) {
+	departments, err := o.mysqlStore.ListDepartments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list departments: %w", err)
+	}
+	counts, err := o.mysqlStore.CountDepartmentMembers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count department members: %w", err)
+	}
+	return buildDepartmentTree(departments, counts), nil
+}
+
+// Department 部门详情，包含直属成员和直属子部门
+func (o *OrgService) Department(id string) (*model.Department, []*model.DepartmentMember, []*model.Department, error) {
+	department, err := o.mysqlStore.GetDepartment(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil, newServiceError(ErrCodeNotFound, "department %s not found", id)
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get department: %w", err)
+	}
+	members, err := o.mysqlStore.ListDepartmentMembers(id)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list department members: %w", err)
+	}
+	children, err := o.mysqlStore.ListChildDepartments(id)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list child departments: %w", err)
+	}
+	return department, members, children, nil
+}
+
+// UserDepartments 用户所属的部门
+func (o *OrgService) UserDepartments(userID string) ([]*model.Department, error) {
+	departments, err := o.mysqlStore.ListUserDepartments(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user departments: %w", err)
+	}
+	return departments, nil
+}
+
+// Sync 用企业目录推送的快照整体替换组织架构，开启部门群时随后同步部门群
+// 部门群同步中的错误记录在结果中，不影响目录本身的同步
+func (o *OrgService) Sync(snapshot *model.OrgSnapshot) (*model.OrgSyncResult, error) {
+	if err := validateOrgSnapshot(snapshot); err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	// 部门群由服务端维护，目录中的值忽略
+	for _, d := range snapshot.Departments {
+		d.GroupID = ""
+	}
+
+	o.syncMu.Lock()
+	defer o.syncMu.Unlock()
+
+	removed, err := o.mysqlStore.ReplaceOrg(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replace org: %w", err)
+	}
+	result := &model.OrgSyncResult{
+		Departments: len(snapshot.Departments),
+		Members:     len(snapshot.Members),
+		Removed:     int(removed),
+	}
+	if o.cfg.DepartmentGroups {
+		if err := o.syncGroups(result); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Info("Org synced",
+		logger.Int("departments", result.Departments),
+		logger.Int("members", result.Members),
+		logger.Int("removed", result.Removed),
+		logger.Int("groups_created", result.GroupsCreated))
+	return result, nil
+}
+
+// SyncGroups 按当前组织架构同步部门群，用于开启部门群后或同步出错后重试
+func (o *OrgService) SyncGroups() (*model.OrgSyncResult, error) {
+	if !o.cfg.DepartmentGroups {
+		return nil, newServiceError(ErrCodeForbidden, "department groups are disabled")
+	}
+
+	o.syncMu.Lock()
+	defer o.syncMu.Unlock()
+
+	result := &model.OrgSyncResult{}
+	if err := o.syncGroups(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// syncGroups 逐个部门同步部门群，单个部门失败时记录错误并继续
+func (o *OrgService) syncGroups(result *model.OrgSyncResult) error {
+	departments, err := o.mysqlStore.ListDepartments()
+	if err != nil {
+		return fmt.Errorf("failed to list departments: %w", err)
+	}
+	members, err := o.mysqlStore.ListAllDepartmentMembers()
+	if err != nil {
+		return fmt.Errorf("failed to list department members: %w", err)
+	}
+	result.Departments = len(departments)
+	result.Members = len(members)
+
+	desired := departmentGroupMembers(departments, members, o.cfg.IncludeSubdepartments)
+	for _, d := range departments {
+		if err := o.syncDepartmentGroup(d, desired[d.ID], result); err != nil {
+			logger.Warn("Failed to sync department group", logger.String("department_id", d.ID), logger.ErrorField(err))
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", d.ID, err))
+		}
+	}
+	return nil
+}
+
+// syncDepartmentGroup 同步单个部门的部门群
+// 部门群在部门有成员后才创建，群主为部门负责人或org.group_owner；群主不会被移出，部门删除后群组保留
+func (o *OrgService) syncDepartmentGroup(d *model.Department, userIDs []string, result *model.OrgSyncResult) error {
+	if d.GroupID == "" {
+		if len(userIDs) == 0 {
+			return nil
+		}
+		owner := d.ManagerID
+		if owner == "" {
+			owner = o.cfg.GroupOwner
+		}
+		if owner == "" {
+			return fmt.Errorf("department has no manager and org.group_owner is empty")
+		}
+
+		// 部门群由组织架构同步维护，不受成员隐私设置限制
+		group, err := o.messages.createGroup(d.Name, "", owner, mergeMembers([]string{owner}, userIDs), model.GroupModeNormal)
+		if err != nil {
+			return fmt.Errorf("failed to create department group: %w", err)
+		}
+		if err := o.mysqlStore.SetDepartmentGroup(d.ID, group.ID); err != nil {
+			return fmt.Errorf("failed to record department group %s: %w", group.ID, err)
+		}
+		result.GroupsCreated++
+		result.MembersAdded += len(group.Members)
+		return nil
+	}
+
+	group, err := o.messages.GetGroup(d.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get department group %s: %w", d.GroupID, err)
+	}
+	if group.Name != d.Name {
+		if err := o.mysqlStore.UpdateGroupName(group.ID, d.Name); err != nil {
+			return fmt.Errorf("failed to rename department group: %w", err)
+		}
+	}
+	current, err := o.messages.GetGroupMembers(group.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get department group members: %w", err)
+	}
+	currentIDs := make([]string, 0, len(current))
+	for _, m := range current {
+		currentIDs = append(currentIDs, m.UserID)
+	}
+
+	add, remove := diffMembers(currentIDs, mergeMembers([]string{group.OwnerID}, userIDs))
+	for _, userID := range add {
+		if err := o.messages.JoinGroup(group.ID, userID); err != nil {
+			return fmt.Errorf("failed to add %s: %w", userID, err)
+		}
+		result.MembersAdded++
+	}
+	for _, userID := range remove {
+		if err := o.messages.LeaveGroup(group.ID, userID); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", userID, err)
+		}
+		result.MembersRemoved++
+	}
+	return nil
+}
+
+// validateOrgSnapshot 校验目录快照：ID唯一、父部门存在且无环、成员所属部门存在
+func validateOrgSnapshot(snapshot *model.OrgSnapshot) error {
+	parents := make(map[string]string, len(snapshot.Departments))
+	for _, d := range snapshot.Departments {
+		if d == nil || d.ID == "" || len(d.ID) > maxDepartmentIDLength {
+			return fmt.Errorf("department id must be 1-%d characters", maxDepartmentIDLength)
+		}
+		if d.Name == "" || len([]rune(d.Name)) > 100 {
+			return fmt.Errorf("department %s name must be 1-100 characters", d.ID)
+		}
+		if len(d.ManagerID) > maxDepartmentIDLength {
+			return fmt.Errorf("department %s manager_id exceeds %d characters", d.ID, maxDepartmentIDLength)
+		}
+		if _, exists := parents[d.ID]; exists {
+			return fmt.Errorf("duplicate department %s", d.ID)
+		}
+		parents[d.ID] = d.ParentID
+	}
+
+	for id, parent := range parents {
+		if parent == "" {
+			continue
+		}
+		if _, exists := parents[parent]; !exists {
+			return fmt.Errorf("department %s has unknown parent %s", id, parent)
+		}
+		// 沿父部门向上最多走部门总数步，仍未到根说明存在环
+		current := id
+		for steps := 0; current != ""; steps++ {
+			if steps > len(parents) {
+				return fmt.Errorf("department %s has a cyclic parent chain", id)
+			}
+			current = parents[current]
+		}
+	}
+
+	seen := make(map[[2]string]bool, len(snapshot.Members))
+	for _, m := range snapshot.Members {
+		if m == nil || m.UserID == "" || len(m.UserID) > maxDepartmentIDLength {
+			return fmt.Errorf("member user_id must be 1-%d characters", maxDepartmentIDLength)
+		}
+		if _, exists := parents[m.DepartmentID]; !exists {
+			return fmt.Errorf("member %s belongs to unknown department %s", m.UserID, m.DepartmentID)
+		}
+		key := [2]string{m.DepartmentID, m.UserID}
+		if seen[key] {
+			return fmt.Errorf("duplicate member %s in department %s", m.UserID, m.DepartmentID)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// buildDepartmentTree 按父部门组装部门树，departments需已按排序值排列；父部门不存在的部门作为根
+func buildDepartmentTree(departments []*model.Department, counts map[string]int) []*model.DepartmentNode {
+	nodes := make(map[string]*model.DepartmentNode, len(departments))
+	for _, d := range departments {
+		nodes[d.ID] = &model.DepartmentNode{
+			ID:          d.ID,
+			Name:        d.Name,
+			ManagerID:   d.ManagerID,
+			GroupID:     d.GroupID,
+			MemberCount: counts[d.ID],
+		}
+	}
+
+	roots := []*model.DepartmentNode{}
+	for _, d := range departments {
+		node := nodes[d.ID]
+		if parent, exists := nodes[d.ParentID]; exists && d.ParentID != d.ID {
+			parent.Children = append(parent.Children, node)
+			continue
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
+// departmentGroupMembers 计算每个部门群应有的成员，includeSub时包含所有子部门的成员，结果按用户ID排序
+func departmentGroupMembers(departments []*model.Department, members []*model.DepartmentMember, includeSub bool) map[string][]string {
+	direct := make(map[string][]string)
+	for _, m := range members {
+		direct[m.DepartmentID] = append(direct[m.DepartmentID], m.UserID)
+	}
+	children := make(map[string][]string)
+	for _, d := range departments {
+		if d.ParentID != "" {
+			children[d.ParentID] = append(children[d.ParentID], d.ID)
+		}
+	}
+
+	result := make(map[string][]string, len(departments))
+	for _, d := range departments {
+		userIDs := direct[d.ID]
+		if includeSub {
+			// 广度优先收集子部门，visited防止异常数据中的环
+			visited := map[string]bool{d.ID: true}
+			queue := append([]string(nil), children[d.ID]...)
+			for len(queue) > 0 {
+				id := queue[0]
+				queue = queue[1:]
+				if visited[id] {
+					continue
+				}
+				visited[id] = true
+				userIDs = append(userIDs, direct[id]...)
+				queue = append(queue, children[id]...)
+			}
+		}
+		result[d.ID] = mergeMembers(nil, userIDs)
+	}
+	return result
+}
+
+// mergeMembers 合并用户ID列表，去重后按ID排序
+func mergeMembers(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	merged := make([]string, 0, len(a)+len(b))
+	for _, list := range [][]string{a, b} {
+		for _, userID := range list {
+			if !seen[userID] {
+				seen[userID] = true
+				merged = append(merged, userID)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// diffMembers 计算从current变为desired需要加入和移出的成员
+func diffMembers(current, desired []string) ([]string, []string) {
+	currentSet := make(map[string]bool, len(current))
+	for _, userID := range current {
+		currentSet[userID] = true
+	}
+	desiredSet := make(map[string]bool, len(desired))
+	var add, remove []string
+	for _, userID := range desired {
+		desiredSet[userID] = true
+		if !currentSet[userID] {
+			add = append(add, userID)
+		}
+	}
+	for _, userID := range current {
+		if !desiredSet[userID] {
+			remove = append(remove, userID)
+		}
+	}
+	return add, remove
+}
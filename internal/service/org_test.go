@@ -0,0 +1,88 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func testDepartments() []*model.Department {
+	return []*model.Department{
+		{ID: "root", Name: "公司"},
+		{ID: "eng", ParentID: "root", Name: "研发"},
+		{ID: "sales", ParentID: "root", Name: "销售"},
+		{ID: "backend", ParentID: "eng", Name: "后端"},
+	}
+}
+
+func TestValidateOrgSnapshot(t *testing.T) {
+	valid := &model.OrgSnapshot{
+		Departments: testDepartments(),
+		Members: []*model.DepartmentMember{
+			{DepartmentID: "eng", UserID: "u1"},
+			{DepartmentID: "backend", UserID: "u1"},
+		},
+	}
+	assert.NoError(t, validateOrgSnapshot(valid))
+
+	cases := map[string]*model.OrgSnapshot{
+		"empty id": {Departments: []*model.Department{{Name: "x"}}},
+		"no name":  {Departments: []*model.Department{{ID: "a"}}},
+		"duplicate": {Departments: []*model.Department{
+			{ID: "a", Name: "x"}, {ID: "a", Name: "y"},
+		}},
+		"unknown parent": {Departments: []*model.Department{{ID: "a", ParentID: "b", Name: "x"}}},
+		"self parent":    {Departments: []*model.Department{{ID: "a", ParentID: "a", Name: "x"}}},
+		"cycle": {Departments: []*model.Department{
+			{ID: "a", ParentID: "b", Name: "x"}, {ID: "b", ParentID: "a", Name: "y"},
+		}},
+		"unknown member department": {
+			Departments: []*model.Department{{ID: "a", Name: "x"}},
+			Members:     []*model.DepartmentMember{{DepartmentID: "b", UserID: "u1"}},
+		},
+		"duplicate member": {
+			Departments: []*model.Department{{ID: "a", Name: "x"}},
+			Members: []*model.DepartmentMember{
+				{DepartmentID: "a", UserID: "u1"}, {DepartmentID: "a", UserID: "u1"},
+			},
+		},
+	}
+	for name, snapshot := range cases {
+		assert.Error(t, validateOrgSnapshot(snapshot), name)
+	}
+}
+
+func TestBuildDepartmentTree(t *testing.T) {
+	tree := buildDepartmentTree(testDepartments(), map[string]int{"eng": 2})
+
+	assert.Len(t, tree, 1)
+	assert.Equal(t, "root", tree[0].ID)
+	assert.Len(t, tree[0].Children, 2)
+	assert.Equal(t, "eng", tree[0].Children[0].ID)
+	assert.Equal(t, 2, tree[0].Children[0].MemberCount)
+	assert.Equal(t, "backend", tree[0].Children[0].Children[0].ID)
+}
+
+func TestDepartmentGroupMembers(t *testing.T) {
+	members := []*model.DepartmentMember{
+		{DepartmentID: "eng", UserID: "u2"},
+		{DepartmentID: "backend", UserID: "u3"},
+		{DepartmentID: "backend", UserID: "u2"},
+		{DepartmentID: "sales", UserID: "u1"},
+	}
+
+	direct := departmentGroupMembers(testDepartments(), members, false)
+	assert.Empty(t, direct["root"])
+	assert.Equal(t, []string{"u2"}, direct["eng"])
+
+	nested := departmentGroupMembers(testDepartments(), members, true)
+	assert.Equal(t, []string{"u1", "u2", "u3"}, nested["root"])
+	assert.Equal(t, []string{"u2", "u3"}, nested["eng"])
+}
+
+func TestDiffMembers(t *testing.T) {
+	add, remove := diffMembers([]string{"owner", "u1", "u2"}, []string{"owner", "u2", "u3"})
+	assert.Equal(t, []string{"u3"}, add)
+	assert.Equal(t, []string{"u1"}, remove)
+}
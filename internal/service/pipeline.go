@@ -0,0 +1,286 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// SendStage 发送拦截器所在的阶段
+type SendStage int
+
+const (
+	// StagePrePersist 生成消息前，拦截器可以改写内容，返回错误时拒绝发送
+	StagePrePersist SendStage = iota
+	// StagePreDeliver 消息保存后、投递前，拦截器可以补充消息字段或记录统计，返回错误时记录日志并继续投递
+	StagePreDeliver
+)
+
+// 内置发送拦截器名称，注册同名拦截器可以替换内置实现
+const (
+	InterceptorSticker        = "sticker"
+	InterceptorMenu           = "menu"
+	InterceptorSanction       = "sanction"
+	InterceptorPostPermission = "post_permission"
+	InterceptorSpam           = "spam"
+	InterceptorPrivacy        = "privacy"
+	InterceptorWordFilter     = "word_filter"
+	InterceptorLinkSafety     = "link_safety"
+	InterceptorMediaStorage   = "media_storage"
+	InterceptorQuota          = "quota"
+	InterceptorAnalytics      = "analytics"
+)
+
+// SendRequest 经过发送拦截器的一次发送
+type SendRequest struct {
+	SenderID   string
+	ReceiverID string             // 私聊接收者
+	Remote     bool               // 私聊接收者属于其他域，隐私设置由其所在域检查
+	GroupID    string             // 群聊的群组ID
+	Group      *model.Group       // 本域群组，私聊和其他域的群组为nil
+	Member     *model.GroupMember // 发送者在本域群组中的成员信息
+	Type       model.MessageType
+	Content    string // 保存前的拦截器可以改写
+	Priority   model.MessagePriority
+	ThreadID   string
+	Official   bool // 公众号发送，不做垃圾消息检测和配额限制
+	Inbound    bool // 其他域投递的私聊消息，发送者所在域已做过垃圾消息检测和配额限制，不计入统计
+	// Pending 非联系人的消息进入接收者的消息请求，由隐私设置拦截器设置
+	Pending bool
+	// Message 已保存的消息，只在StagePreDeliver阶段设置
+	Message *model.Message
+
+	// undo 保存前的拦截器通过后登记的撤销操作，如退还配额；消息未能保存时逆序执行
+	undo []func()
+}
+
+// OnAbort 登记消息未能保存时的撤销操作，后续拦截器拒绝或保存失败时逆序执行
+func (r *SendRequest) OnAbort(fn func()) {
+	r.undo = append(r.undo, fn)
+}
+
+// abort 逆序执行登记的撤销操作，每个操作只执行一次
+func (r *SendRequest) abort() {
+	for i := len(r.undo) - 1; i >= 0; i-- {
+		r.undo[i]()
+	}
+	r.undo = nil
+}
+
+// WordFilterLevel 敏感词过滤级别，本域群组按群组设置，其他为标准级别
+func (r *SendRequest) WordFilterLevel() model.WordFilterLevel {
+	if r.Group != nil {
+		return r.Group.WordFilterLevel()
+	}
+	return model.WordFilterStandard
+}
+
+// Exempt 公众号发送和其他域投递的私聊消息，不做垃圾消息检测和配额限制
+func (r *SendRequest) Exempt() bool {
+	return r.Official || r.Inbound
+}
+
+// RemoteGroup 发往其他域群组的消息
+func (r *SendRequest) RemoteGroup() bool {
+	return r.GroupID != "" && r.Group == nil
+}
+
+// SendInterceptor 发送拦截器
+type SendInterceptor func(req *SendRequest) error
+
+// namedInterceptor 带名称的拦截器
+type namedInterceptor struct {
+	name string
+	fn   SendInterceptor
+}
+
+// sendPipeline 消息发送的拦截器链，每个阶段按注册顺序执行，任一拦截器返回错误时中止
+type sendPipeline struct {
+	mu     sync.RWMutex
+	stages map[SendStage][]namedInterceptor
+}
+
+// newSendPipeline 创建空的拦截器链
+func newSendPipeline() *sendPipeline {
+	return &sendPipeline{stages: make(map[SendStage][]namedInterceptor)}
+}
+
+// use 注册拦截器，已有同名拦截器时原位替换，fn为nil时移除
+func (p *sendPipeline) use(stage SendStage, name string, fn SendInterceptor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	chain := p.stages[stage]
+	for i, interceptor := range chain {
+		if interceptor.name != name {
+			continue
+		}
+		if fn == nil {
+			p.stages[stage] = append(chain[:i:i], chain[i+1:]...)
+		} else {
+			p.stages[stage] = append(append(chain[:i:i], namedInterceptor{name: name, fn: fn}), chain[i+1:]...)
+		}
+		return
+	}
+	if fn != nil {
+		p.stages[stage] = append(chain[:len(chain):len(chain)], namedInterceptor{name: name, fn: fn})
+	}
+}
+
+// names 获取阶段内按执行顺序排列的拦截器名称
+func (p *sendPipeline) names(stage SendStage) []string {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	names := make([]string, len(p.stages[stage]))
+	for i, interceptor := range p.stages[stage] {
+		names[i] = interceptor.name
+	}
+	return names
+}
+
+// chain 获取阶段内的拦截器
+func (p *sendPipeline) chain(stage SendStage) []namedInterceptor {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.stages[stage]
+}
+
+// run 执行阶段内的拦截器，任一拦截器返回错误时中止并撤销已通过的拦截器登记的操作；未创建拦截器链时直接通过
+func (p *sendPipeline) run(stage SendStage, req *SendRequest) error {
+	for _, interceptor := range p.chain(stage) {
+		if err := interceptor.fn(req); err != nil {
+			req.abort()
+			return err
+		}
+	}
+	return nil
+}
+
+// runPreDeliver 执行StagePreDeliver阶段的拦截器，消息已保存并已发出创建事件，拦截器返回错误时记录日志并继续
+func (p *sendPipeline) runPreDeliver(req *SendRequest) {
+	for _, interceptor := range p.chain(StagePreDeliver) {
+		if err := interceptor.fn(req); err != nil {
+			logger.Warn("Send interceptor failed after message was saved",
+				logger.String("interceptor", interceptor.name),
+				logger.String("message_id", req.Message.ID),
+				logger.ErrorField(err))
+		}
+	}
+}
+
+// Intercept 在发送路径的指定阶段注册拦截器，同名拦截器原位替换，fn为nil时移除
+func (s *MessageService) Intercept(stage SendStage, name string, fn SendInterceptor) {
+	s.pipeline.use(stage, name, fn)
+}
+
+// Interceptors 获取阶段内按执行顺序排列的拦截器名称
+func (s *MessageService) Interceptors(stage SendStage) []string {
+	return s.pipeline.names(stage)
+}
+
+// registerBuiltinInterceptors 注册内置拦截器：保存前依次校验贴纸和菜单、检查处罚和发言权限、检测垃圾消息、检查隐私设置、
+// 过滤敏感词、检查链接、检查群媒体限制和扣减配额，保存后记录统计；拦截器在执行时读取依赖，依赖可以在注册之后设置
+func (s *MessageService) registerBuiltinInterceptors() {
+	s.Intercept(StagePrePersist, InterceptorSticker, func(req *SendRequest) error {
+		if req.Type != model.MessageTypeSticker {
+			return nil
+		}
+		return s.stickers.Validate(req.SenderID, req.Content)
+	})
+	s.Intercept(StagePrePersist, InterceptorMenu, func(req *SendRequest) error {
+		// 菜单消息只能在私聊中发送，群聊在拦截器之前拒绝
+		if req.Type != model.MessageTypeMenu {
+			return nil
+		}
+		return s.officials.ValidateMenuMessage(req.SenderID, req.Content)
+	})
+	s.Intercept(StagePrePersist, InterceptorSanction, func(req *SendRequest) error {
+		// 其他域的发送者同样受本域的全局处罚约束
+		return checkUserSanction(s.redisStore, req.SenderID)
+	})
+	s.Intercept(StagePrePersist, InterceptorPostPermission, func(req *SendRequest) error {
+		if req.Group == nil || req.Member == nil {
+			return nil
+		}
+		return s.checkPostPermission(req.Group, req.Member, req.Type, req.Content, req.Priority)
+	})
+	s.Intercept(StagePrePersist, InterceptorSpam, func(req *SendRequest) error {
+		// 公众号由管理员维护，群发时同一内容发给大量用户，不做垃圾消息检测
+		if s.spam == nil || req.Exempt() {
+			return nil
+		}
+		return s.spam.Check(req.SenderID, req.ReceiverID, req.Content)
+	})
+	s.Intercept(StagePrePersist, InterceptorPrivacy, func(req *SendRequest) error {
+		// 非联系人的消息可能进入消息请求，远程用户的隐私设置由其所在域检查
+		if req.ReceiverID == "" || req.Remote {
+			return nil
+		}
+		pending, err := s.checkMessagePrivacy(req.SenderID, req.ReceiverID)
+		if err != nil {
+			return err
+		}
+		req.Pending = pending
+		return nil
+	})
+	s.Intercept(StagePrePersist, InterceptorWordFilter, func(req *SendRequest) error {
+		// 敏感词过滤对群主和管理员同样生效
+		if req.Type != model.MessageTypeText {
+			return nil
+		}
+		content, err := s.words.Filter(req.Content, req.WordFilterLevel())
+		if err != nil {
+			return err
+		}
+		req.Content = content
+		return nil
+	})
+	s.Intercept(StagePrePersist, InterceptorLinkSafety, func(req *SendRequest) error {
+		// 链接在敏感词过滤后检查
+		if req.Type != model.MessageTypeText {
+			return nil
+		}
+		content, err := s.links.Check(req.SenderID, req.Content)
+		if err != nil {
+			return err
+		}
+		req.Content = content
+		return nil
+	})
+	s.Intercept(StagePrePersist, InterceptorMediaStorage, func(req *SendRequest) error {
+		if req.Group == nil {
+			return nil
+		}
+		return s.mediaStorage.CheckGroup(req.GroupID, req.Type, req.Content)
+	})
+	s.Intercept(StagePrePersist, InterceptorQuota, func(req *SendRequest) error {
+		// 其他域投递的私聊消息由发送者所在域限制，其他域用户在本域群组发言同样扣减配额
+		if req.Exempt() {
+			return nil
+		}
+		if err := s.quota.ConsumeMessage(req.SenderID); err != nil {
+			return err
+		}
+		// 后续拦截器拒绝或消息未能保存时退还
+		req.OnAbort(func() { s.quota.ReleaseMessage(req.SenderID) })
+		return nil
+	})
+	s.Intercept(StagePreDeliver, InterceptorAnalytics, func(req *SendRequest) error {
+		if req.Inbound {
+			return nil
+		}
+		// 其他域群组的活跃用户由群组的主域统计
+		if !req.RemoteGroup() {
+			s.analytics.RecordMessage(req.Message)
+		}
+		s.stats.RecordMessage()
+		return nil
+	})
+}
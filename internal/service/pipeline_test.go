@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func TestSendPipeline_OrderAndReplace(t *testing.T) {
+	p := newSendPipeline()
+	var calls []string
+	record := func(name string) SendInterceptor {
+		return func(req *SendRequest) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	p.use(StagePrePersist, "a", record("a"))
+	p.use(StagePrePersist, "b", record("b"))
+	p.use(StagePrePersist, "c", record("c"))
+	p.use(StagePreDeliver, "d", record("d"))
+
+	// 同名替换保持原位置，nil移除
+	p.use(StagePrePersist, "b", record("b2"))
+	p.use(StagePrePersist, "c", nil)
+	assert.Equal(t, []string{"a", "b"}, p.names(StagePrePersist))
+
+	assert.NoError(t, p.run(StagePrePersist, &SendRequest{}))
+	assert.Equal(t, []string{"a", "b2"}, calls)
+
+	// 返回错误时中止
+	rejected := errors.New("rejected")
+	p.use(StagePrePersist, "a", func(req *SendRequest) error { return rejected })
+	calls = nil
+	assert.Equal(t, rejected, p.run(StagePrePersist, &SendRequest{}))
+	assert.Empty(t, calls)
+
+	var nilPipeline *sendPipeline
+	assert.NoError(t, nilPipeline.run(StagePrePersist, &SendRequest{}))
+}
+
+func TestMessageService_BuiltinInterceptors(t *testing.T) {
+	s := NewMessageServiceWithBackend(nil, nil, nil, nil)
+	assert.Equal(t, []string{InterceptorSticker, InterceptorMenu, InterceptorSanction, InterceptorPostPermission, InterceptorSpam,
+		InterceptorPrivacy, InterceptorWordFilter, InterceptorLinkSafety, InterceptorMediaStorage, InterceptorQuota},
+		s.Interceptors(StagePrePersist))
+	assert.Equal(t, []string{InterceptorAnalytics}, s.Interceptors(StagePreDeliver))
+
+	// 处罚和隐私设置读取Redis，测试中移除
+	s.Intercept(StagePrePersist, InterceptorSanction, nil)
+	s.Intercept(StagePrePersist, InterceptorPrivacy, nil)
+
+	// 依赖在注册之后设置也生效，群组按群组设置的级别过滤
+	dir := t.TempDir()
+	writeWordList(t, dir, "en", "base.txt", "casino\n")
+	writeWordList(t, dir, "en", "public.strict.txt", "crypto\n")
+	words := NewWordFilter(nil, config.WordFilterConfig{Dir: dir, Action: config.WordFilterMask})
+	_, err := words.load()
+	assert.NoError(t, err)
+	s.SetWordFilter(words)
+
+	private := &SendRequest{SenderID: "u1", ReceiverID: "u2", Type: model.MessageTypeText, Content: "casino crypto"}
+	assert.NoError(t, s.pipeline.run(StagePrePersist, private))
+	assert.Equal(t, "****** crypto", private.Content)
+
+	group := &model.Group{ID: "g1", Settings: model.GroupSettings{WordFilter: model.WordFilterStrict}}
+	public := &SendRequest{SenderID: "u1", GroupID: "g1", Group: group, Type: model.MessageTypeText, Content: "casino crypto"}
+	assert.NoError(t, s.pipeline.run(StagePrePersist, public))
+	assert.Equal(t, "****** ******", public.Content)
+
+	// 自定义拦截器排在内置拦截器之后
+	s.Intercept(StagePrePersist, "tag", func(req *SendRequest) error {
+		req.Content += " #tagged"
+		return nil
+	})
+	image := &SendRequest{SenderID: "u1", ReceiverID: "u2", Type: model.MessageTypeImage, Content: "casino"}
+	assert.NoError(t, s.pipeline.run(StagePrePersist, image))
+	assert.Equal(t, "casino #tagged", image.Content)
+}
+
+func TestBuiltinInterceptors_PostPermission(t *testing.T) {
+	s := NewMessageServiceWithBackend(nil, nil, nil, nil)
+	s.Intercept(StagePrePersist, InterceptorSanction, nil)
+
+	group := &model.Group{ID: "g1", Settings: model.GroupSettings{PostPolicy: model.PostPolicyAdmins}}
+	req := &SendRequest{SenderID: "u1", GroupID: "g1", Group: group, Member: &model.GroupMember{Role: "member"}, Type: model.MessageTypeText, Content: "hi"}
+	assert.Equal(t, ErrCodePostForbidden, errorCode(s.pipeline.run(StagePrePersist, req)))
+
+	req.Member.Role = "admin"
+	assert.NoError(t, s.pipeline.run(StagePrePersist, req))
+}
+
+func TestSendRequest_ExemptAndStats(t *testing.T) {
+	private := &SendRequest{SenderID: "u1", ReceiverID: "u2"}
+	official := &SendRequest{SenderID: "o1", ReceiverID: "u2", Official: true}
+	inbound := &SendRequest{SenderID: "alice@a.example", ReceiverID: "u2", Inbound: true}
+	// 其他域用户在本域群组发言和本域用户一样检测和扣减配额
+	federatedSender := &SendRequest{SenderID: "alice@a.example", GroupID: "g1", Group: &model.Group{ID: "g1"}}
+	remoteGroup := &SendRequest{SenderID: "u1", GroupID: "g1@a.example"}
+
+	assert.False(t, private.Exempt())
+	assert.True(t, official.Exempt())
+	assert.True(t, inbound.Exempt())
+	assert.False(t, federatedSender.Exempt())
+	assert.False(t, remoteGroup.Exempt())
+	assert.True(t, remoteGroup.RemoteGroup())
+	assert.False(t, federatedSender.RemoteGroup())
+
+	s := NewMessageServiceWithBackend(nil, nil, nil, nil)
+	stats := &StatsService{}
+	s.SetStats(stats)
+	for _, req := range []*SendRequest{private, official, inbound, federatedSender, remoteGroup} {
+		req.Message = &model.Message{ID: "m1", SenderID: req.SenderID, GroupID: req.GroupID, Type: model.MessageTypeText}
+		s.pipeline.runPreDeliver(req)
+	}
+	// 其他域投递的私聊消息不计入统计
+	assert.Equal(t, int64(4), stats.messages.Load())
+}
+
+func TestSendPipeline_PreDeliverErrorsDoNotStopDelivery(t *testing.T) {
+	p := newSendPipeline()
+	var calls []string
+	p.use(StagePreDeliver, "a", func(req *SendRequest) error {
+		calls = append(calls, "a")
+		return errors.New("tagging unavailable")
+	})
+	p.use(StagePreDeliver, "b", func(req *SendRequest) error {
+		calls = append(calls, "b")
+		return nil
+	})
+
+	// 消息已保存，出错的拦截器只记录日志，后面的拦截器继续执行
+	p.runPreDeliver(&SendRequest{Message: &model.Message{ID: "m1"}})
+	assert.Equal(t, []string{"a", "b"}, calls)
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+const (
+	// maxPollQuestionLength 投票问题的最大长度（字符）
+	maxPollQuestionLength = 300
+	// maxPollOptionLength 投票选项的最大长度（字符）
+	maxPollOptionLength = 100
+	// maxPollDuration 截止时间距发送时间的上限
+	maxPollDuration = 366 * 24 * time.Hour
+	// pollRetention 最后一次投票或结束后投票结果的保留时长
+	pollRetention = 90 * 24 * time.Hour
+	// pollDeadlineBatch 每次检查处理的到期投票数
+	pollDeadlineBatch = 100
+)
+
+// parsePoll 解析并校验投票消息的内容
+func parsePoll(content string, maxOptions int, now time.Time) (*model.PollPayload, error) {
+	var poll model.PollPayload
+	if err := json.Unmarshal([]byte(content), &poll); err != nil {
+		return nil, fmt.Errorf("poll content must be a JSON object with question and options")
+	}
+	poll.Question = strings.TrimSpace(poll.Question)
+	if poll.Question == "" || utf8.RuneCountInString(poll.Question) > maxPollQuestionLength {
+		return nil, fmt.Errorf("poll question must be 1 to %d characters", maxPollQuestionLength)
+	}
+	if len(poll.Options) < 2 || len(poll.Options) > maxOptions {
+		return nil, fmt.Errorf("poll must have 2 to %d options", maxOptions)
+	}
+	seen := make(map[string]bool, len(poll.Options))
+	for _, option := range poll.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || utf8.RuneCountInString(option) > maxPollOptionLength {
+			return nil, fmt.Errorf("poll options must be 1 to %d characters", maxPollOptionLength)
+		}
+		if seen[option] {
+			return nil, fmt.Errorf("duplicate poll option: %s", option)
+		}
+		seen[option] = true
+	}
+	if poll.ClosesAt != 0 && (poll.ClosesAt <= now.Unix() || poll.ClosesAt > now.Add(maxPollDuration).Unix()) {
+		return nil, fmt.Errorf("poll closes_at must be in the future and within %d days", int(maxPollDuration/(24*time.Hour)))
+	}
+	return &poll, nil
+}
+
+// normalizeVote 校验选择的选项并排序：单选只能选一项，序号不能越界或重复
+func normalizeVote(poll *model.PollPayload, options []int) ([]int, error) {
+	if len(options) == 0 {
+		return nil, fmt.Errorf("at least one option is required")
+	}
+	if !poll.Multiple && len(options) > 1 {
+		return nil, fmt.Errorf("this poll allows only one option")
+	}
+	normalized := append([]int(nil), options...)
+	sort.Ints(normalized)
+	for i, option := range normalized {
+		if option < 0 || option >= len(poll.Options) {
+			return nil, fmt.Errorf("invalid poll option: %d", option)
+		}
+		if i > 0 && normalized[i-1] == option {
+			return nil, fmt.Errorf("duplicate poll option: %d", option)
+		}
+	}
+	return normalized, nil
+}
+
+// validatePoll 校验发送的投票消息
+func (s *MessageService) validatePoll(content string) error {
+	if _, err := parsePoll(content, s.pollCfg.MaxOptions, time.Now()); err != nil {
+		return newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	return nil
+}
+
+// schedulePollDeadline 投票消息保存后登记截止时间
+func (s *MessageService) schedulePollDeadline(message *model.Message) {
+	if message.Type != model.MessageTypePoll {
+		return
+	}
+	var poll model.PollPayload
+	if err := json.Unmarshal([]byte(message.Content), &poll); err != nil || poll.ClosesAt == 0 {
+		return
+	}
+	if err := s.redisStore.SchedulePollDeadline(message.ID, time.Unix(poll.ClosesAt, 0)); err != nil {
+		logger.Warn("Failed to schedule poll deadline", logger.String("message_id", message.ID), logger.ErrorField(err))
+	}
+}
+
+// pollMessage 获取投票消息及其内容，要求请求者是群成员
+func (s *MessageService) pollMessage(userID, messageID string) (*model.Message, *model.PollPayload, error) {
+	message, err := s.lookup.get(messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message.Type != model.MessageTypePoll || !message.IsGroupMessage() || message.IsDeleted() {
+		return nil, nil, newServiceError(ErrCodeInvalidRequest, "message %s is not a poll", messageID)
+	}
+	ok, err := s.canAccessMessage(userID, message)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, newServiceError(ErrCodeNotMember, "user %s is not a member of group %s", userID, message.GroupID)
+	}
+
+	var poll model.PollPayload
+	if err := json.Unmarshal([]byte(message.Content), &poll); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode poll %s: %w", messageID, err)
+	}
+	return message, &poll, nil
+}
+
+// Vote 记录成员的选择，替换之前的选择，并向群成员推送最新结果；投票结束后不能再投
+func (s *MessageService) Vote(userID, messageID string, options []int) (*model.PollTally, error) {
+	message, poll, err := s.pollMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	options, err = normalizeVote(poll, options)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	if poll.ClosesAt != 0 && poll.ClosesAt <= time.Now().Unix() {
+		return nil, newServiceError(ErrCodeInvalidRequest, "poll %s is closed", messageID)
+	}
+
+	cast, err := s.redisStore.CastPollVote(messageID, userID, options, pollRetention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save vote: %w", err)
+	}
+	if !cast {
+		return nil, newServiceError(ErrCodeInvalidRequest, "poll %s is closed", messageID)
+	}
+
+	tally, err := s.pollTally(message, poll)
+	if err != nil {
+		return nil, err
+	}
+	event := model.PollUpdatedEvent{MessageID: messageID, GroupID: message.GroupID, Tally: *tally}
+	if !poll.Anonymous {
+		event.UserID, event.Options = userID, options
+	}
+	s.notifyPollUpdated(message, event)
+	return tally, nil
+}
+
+// ClosePoll 发起者提前结束投票，已结束时直接返回结果
+func (s *MessageService) ClosePoll(userID, messageID string) (*model.PollTally, error) {
+	message, poll, err := s.pollMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderID != userID {
+		return nil, newServiceError(ErrCodeForbidden, "only the poll creator can close the poll")
+	}
+	return s.closePoll(message, poll)
+}
+
+// closePoll 结束投票，由本次调用结束时推送结果
+func (s *MessageService) closePoll(message *model.Message, poll *model.PollPayload) (*model.PollTally, error) {
+	closed, err := s.redisStore.ClosePoll(message.ID, time.Now(), pollRetention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close poll: %w", err)
+	}
+	tally, err := s.pollTally(message, poll)
+	if err != nil {
+		return nil, err
+	}
+	if closed {
+		s.notifyPollUpdated(message, model.PollUpdatedEvent{MessageID: message.ID, GroupID: message.GroupID, Tally: *tally})
+	}
+	return tally, nil
+}
+
+// GetPoll 获取投票详情，实名投票附带每个成员的选择
+func (s *MessageService) GetPoll(userID, messageID string) (*model.PollDetail, error) {
+	message, poll, err := s.pollMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	tally, err := s.pollTally(message, poll)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &model.PollDetail{MessageID: messageID, Tally: tally}
+	if poll.Anonymous {
+		if detail.MyVote, err = s.redisStore.GetPollVote(messageID, userID); err != nil {
+			return nil, fmt.Errorf("failed to get vote: %w", err)
+		}
+		return detail, nil
+	}
+	if detail.Votes, err = s.redisStore.GetPollVotes(messageID); err != nil {
+		return nil, fmt.Errorf("failed to get votes: %w", err)
+	}
+	detail.MyVote = detail.Votes[userID]
+	return detail, nil
+}
+
+// pollTally 获取单个投票的结果
+func (s *MessageService) pollTally(message *model.Message, poll *model.PollPayload) (*model.PollTally, error) {
+	tallies, err := s.redisStore.GetPollTallies(map[string]int{message.ID: len(poll.Options)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get poll tally: %w", err)
+	}
+	tally := tallies[message.ID]
+	markPollDeadline(tally, poll)
+	return tally, nil
+}
+
+// markPollDeadline 截止时间已过但主节点尚未结束的投票同样视为已结束
+func markPollDeadline(tally *model.PollTally, poll *model.PollPayload) {
+	if !tally.Closed && poll.ClosesAt != 0 && poll.ClosesAt <= time.Now().Unix() {
+		tally.Closed = true
+		tally.ClosedAt = poll.ClosesAt
+	}
+}
+
+// notifyPollUpdated 向群成员推送投票结果
+func (s *MessageService) notifyPollUpdated(message *model.Message, event model.PollUpdatedEvent) {
+	frame := model.WebSocketMessage{
+		Type:      model.FramePollUpdated,
+		Data:      event,
+		Timestamp: time.Now().Unix(),
+		MessageID: message.ID,
+	}
+	if err := s.notifyParticipants(message, frame); err != nil {
+		logger.Warn("Failed to notify poll update", logger.String("message_id", message.ID), logger.ErrorField(err))
+	}
+}
+
+// applyPollTallies 为投票消息附加结果，获取失败时不附加
+func (s *MessageService) applyPollTallies(messages []*model.Message) []*model.Message {
+	polls := make(map[string]*model.PollPayload)
+	options := make(map[string]int)
+	for _, m := range messages {
+		if m.Type != model.MessageTypePoll || m.IsDeleted() {
+			continue
+		}
+		var poll model.PollPayload
+		if err := json.Unmarshal([]byte(m.Content), &poll); err != nil {
+			continue
+		}
+		polls[m.ID] = &poll
+		options[m.ID] = len(poll.Options)
+	}
+	if len(polls) == 0 {
+		return messages
+	}
+
+	tallies, err := s.redisStore.GetPollTallies(options)
+	if err != nil {
+		logger.Warn("Failed to get poll tallies", logger.ErrorField(err))
+		return messages
+	}
+	for i, m := range messages {
+		if tally, ok := tallies[m.ID]; ok {
+			markPollDeadline(tally, polls[m.ID])
+			updated := *m
+			updated.Poll = tally
+			messages[i] = &updated
+		}
+	}
+	return messages
+}
+
+// ClosePollsAtDeadline 结束已到截止时间的投票并推送结果，由主节点定期执行
+func (s *MessageService) ClosePollsAtDeadline(ctx context.Context, fence int64) error {
+	for ctx.Err() == nil {
+		due, err := s.redisStore.GetDuePollDeadlines(time.Now(), pollDeadlineBatch)
+		if err != nil {
+			return fmt.Errorf("failed to get due polls: %w", err)
+		}
+		for _, messageID := range due {
+			if err := s.closePollAtDeadline(messageID); err != nil {
+				return err
+			}
+		}
+		if len(due) < pollDeadlineBatch {
+			return nil
+		}
+	}
+	return nil
+}
+
+// closePollAtDeadline 结束一个到期的投票，消息已删除时只移除登记
+func (s *MessageService) closePollAtDeadline(messageID string) error {
+	message, err := s.storeBackend.GetMessage(messageID)
+	var poll model.PollPayload
+	if err != nil || message.IsDeleted() || json.Unmarshal([]byte(message.Content), &poll) != nil {
+		if err := s.redisStore.RemovePollDeadline(messageID); err != nil {
+			return fmt.Errorf("failed to remove poll deadline: %w", err)
+		}
+		return nil
+	}
+	_, err = s.closePoll(message, &poll)
+	return err
+}
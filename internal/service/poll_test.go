@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestParsePoll(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	closesAt := now.Add(time.Hour).Unix()
+
+	poll, err := parsePoll(fmt.Sprintf(`{"question":" 聚餐去哪 ","options":["火锅","烧烤"],"multiple":true,"closes_at":%d}`, closesAt), 3, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "聚餐去哪", poll.Question)
+	assert.Equal(t, []string{"火锅", "烧烤"}, poll.Options)
+	assert.True(t, poll.Multiple)
+	assert.Equal(t, closesAt, poll.ClosesAt)
+
+	for _, content := range []string{
+		`not json`,
+		`{"question":"","options":["a","b"]}`,
+		`{"question":"q","options":["a"]}`,
+		`{"question":"q","options":["a","b","c","d"]}`,
+		`{"question":"q","options":["a"," a "]}`,
+		`{"question":"q","options":["a",""]}`,
+		fmt.Sprintf(`{"question":"q","options":["a","b"],"closes_at":%d}`, now.Unix()),
+		fmt.Sprintf(`{"question":"q","options":["a","b"],"closes_at":%d}`, now.Add(400*24*time.Hour).Unix()),
+	} {
+		_, err := parsePoll(content, 3, now)
+		assert.Error(t, err, content)
+	}
+}
+
+func TestNormalizeVote(t *testing.T) {
+	single := &model.PollPayload{Options: []string{"a", "b", "c"}}
+	multiple := &model.PollPayload{Options: []string{"a", "b", "c"}, Multiple: true}
+
+	options, err := normalizeVote(multiple, []int{2, 0})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 2}, options)
+
+	options, err = normalizeVote(single, []int{1})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, options)
+
+	_, err = normalizeVote(single, []int{0, 1})
+	assert.Error(t, err)
+	_, err = normalizeVote(multiple, nil)
+	assert.Error(t, err)
+	_, err = normalizeVote(multiple, []int{3})
+	assert.Error(t, err)
+	_, err = normalizeVote(multiple, []int{1, 1})
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// presenceWatchTTL 连接关注列表的保留时长，每次设置时刷新，节点异常退出时遗留的列表随之过期
+const presenceWatchTTL = sessionRecordTTL
+
+// PresenceService 在线状态扇出服务
+// 用户状态变化先进入防抖窗口，窗口结束时只发布最终状态，且与上次发布的状态相同则不发布，
+// 避免频繁上下线的用户对大量订阅者造成推送放大；订阅分为按用户保存的订阅和随连接关闭取消的关注列表
+type PresenceService struct {
+	redisStore       *store.RedisStore
+	deliverer        Deliverer
+	debounce         time.Duration
+	maxSubscriptions int
+	maxWatch         int
+	events           *EventPublisher
+
+	mu      sync.Mutex
+	pending map[string]string // userID -> 防抖窗口内的最新状态
+}
+
+// NewPresenceService 创建在线状态服务
+func NewPresenceService(redisStore *store.RedisStore, deliverer Deliverer, cfg config.PresenceConfig) *PresenceService {
+	return &PresenceService{
+		redisStore:       redisStore,
+		deliverer:        deliverer,
+		debounce:         cfg.Debounce,
+		maxSubscriptions: cfg.MaxSubscriptions,
+		maxWatch:         cfg.MaxWatch,
+		pending:          make(map[string]string),
+	}
+}
+
+// SetEventPublisher 设置规范事件发布器，为nil时不发布事件
+func (p *PresenceService) SetEventPublisher(events *EventPublisher) {
+	p.events = events
+}
+
+// SetOnline 标记用户上线
+func (p *PresenceService) SetOnline(userID string) {
+	p.update(userID, model.PresenceOnline)
+}
+
+// SetOffline 标记用户下线
+func (p *PresenceService) SetOffline(userID string) {
+	p.update(userID, model.PresenceOffline)
+}
+
+// update 记录状态变化，窗口内的多次变化合并为一次发布
+func (p *PresenceService) update(userID, status string) {
+	p.mu.Lock()
+	_, scheduled := p.pending[userID]
+	p.pending[userID] = status
+	p.mu.Unlock()
+
+	if !scheduled {
+		time.AfterFunc(p.debounce, func() {
+			p.flush(userID)
+		})
+	}
+}
+
+// flush 发布防抖窗口结束时的最终状态
+func (p *PresenceService) flush(userID string) {
+	p.mu.Lock()
+	status := p.pending[userID]
+	delete(p.pending, userID)
+	p.mu.Unlock()
+
+	// 只发布与上次不同的状态
+	if last, err := p.redisStore.GetUserStatus(userID); err == nil && last.Status == status {
+		return
+	}
+
+	now := time.Now()
+	p.redisStore.SetUserStatus(userID, &model.UserStatus{
+		UserID:   userID,
+		Status:   status,
+		LastSeen: now,
+	})
+	p.events.PresenceChanged(userID, status)
+
+	watchers := p.watchers(userID)
+	if len(watchers) == 0 {
+		return
+	}
+
+	p.deliverer.BroadcastToGroup(watchers, model.WebSocketMessage{
+		Type: model.FramePresence,
+		Data: model.PresenceEvent{
+			UserID:    userID,
+			Status:    status,
+			Timestamp: now.Unix(),
+		},
+		Timestamp: now.Unix(),
+	})
+}
+
+// Subscribe 订阅用户在线状态，返回订阅目标的当前状态
+func (p *PresenceService) Subscribe(watcherID string, userIDs []string) ([]*model.PresenceEvent, error) {
+	count, err := p.redisStore.CountPresenceWatching(watcherID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count subscriptions: %w", err)
+	}
+	if p.maxSubscriptions > 0 && int(count)+len(userIDs) > p.maxSubscriptions {
+		return nil, fmt.Errorf("presence subscriptions exceed limit %d", p.maxSubscriptions)
+	}
+
+	if err := p.redisStore.AddPresenceWatch(watcherID, userIDs); err != nil {
+		return nil, fmt.Errorf("failed to subscribe presence: %w", err)
+	}
+
+	return p.GetPresence(userIDs), nil
+}
+
+// watchers 获取按用户订阅和按连接关注该用户的订阅者，去重后返回
+func (p *PresenceService) watchers(userID string) []string {
+	subscribers, err := p.redisStore.GetPresenceWatchers(userID)
+	if err != nil {
+		logger.Warn("Failed to get presence watchers", logger.String("user_id", userID), logger.ErrorField(err))
+	}
+	connWatchers, err := p.redisStore.GetPresenceConnWatchers(userID)
+	if err != nil {
+		logger.Warn("Failed to get presence connection watchers", logger.String("user_id", userID), logger.ErrorField(err))
+	}
+	return uniqueIDs(append(subscribers, connWatchers...))
+}
+
+// Watch 替换连接的关注列表并返回关注用户的当前状态，去重后的用户数不能超过每个连接的上限
+func (p *PresenceService) Watch(watcherID, sessionID string, userIDs []string) ([]*model.PresenceEvent, error) {
+	userIDs = uniqueIDs(userIDs)
+	if p.maxWatch > 0 && len(userIDs) > p.maxWatch {
+		return nil, newServiceError(ErrCodeInvalidRequest, "presence watch list exceeds limit %d", p.maxWatch)
+	}
+	if err := p.redisStore.SetPresenceConnWatch(watcherID, sessionID, userIDs, presenceWatchTTL); err != nil {
+		return nil, fmt.Errorf("failed to watch presence: %w", err)
+	}
+	return p.GetPresence(userIDs), nil
+}
+
+// WatchFrame 处理客户端提交的subscribe_presence帧
+func (p *PresenceService) WatchFrame(watcherID, sessionID string, frame *model.WebSocketMessage) (*model.PresenceWatchResponse, error) {
+	if watcherID == "" {
+		return nil, newServiceError(ErrCodeUnauthenticated, "login required")
+	}
+	payload, err := model.DecodeFrame(frame)
+	if err != nil {
+		var verr *model.FrameValidationError
+		if errors.As(err, &verr) {
+			return nil, newServiceError(ErrCodeInvalidRequest, "%s", verr.Error())
+		}
+		return nil, newServiceError(ErrCodeInvalidRequest, "invalid subscribe_presence data")
+	}
+	events, err := p.Watch(watcherID, sessionID, payload.(*model.PresenceWatchRequest).UserIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PresenceWatchResponse{Presence: events}, nil
+}
+
+// Unwatch 连接关闭后删除其关注列表
+func (p *PresenceService) Unwatch(sessionID string) {
+	if err := p.redisStore.ClearPresenceConnWatch(sessionID); err != nil {
+		logger.Warn("Failed to clear presence watch list", logger.String("session_id", sessionID), logger.ErrorField(err))
+	}
+}
+
+// uniqueIDs 按首次出现的顺序去重，忽略空ID
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+// Unsubscribe 取消订阅用户在线状态
+func (p *PresenceService) Unsubscribe(watcherID string, userIDs []string) error {
+	if err := p.redisStore.RemovePresenceWatch(watcherID, userIDs); err != nil {
+		return fmt.Errorf("failed to unsubscribe presence: %w", err)
+	}
+	return nil
+}
+
+// GetPresence 批量获取用户当前状态，是否在线以会话路由为准，状态记录只提供最后变化时间
+func (p *PresenceService) GetPresence(userIDs []string) []*model.PresenceEvent {
+	events := make([]*model.PresenceEvent, 0, len(userIDs))
+	for _, userID := range userIDs {
+		event := &model.PresenceEvent{UserID: userID, Status: model.PresenceOffline}
+		if p.deliverer.IsOnline(userID) {
+			event.Status = model.PresenceOnline
+		}
+		if status, err := p.redisStore.GetUserStatus(userID); err == nil {
+			event.Timestamp = status.LastSeen.Unix()
+		}
+		events = append(events, event)
+	}
+	return events
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func TestUniqueIDs(t *testing.T) {
+	assert.Equal(t, []string{"u2", "u1", "u3"}, uniqueIDs([]string{"u2", "u1", "", "u2", "u3", "u1"}))
+	assert.Empty(t, uniqueIDs(nil))
+}
+
+func TestPresenceService_WatchLimit(t *testing.T) {
+	p := NewPresenceService(nil, nil, config.PresenceConfig{MaxWatch: 2})
+
+	// 超出上限时不写入关注列表
+	_, err := p.Watch("u1", "conn_1", []string{"u2", "u3", "u4"})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	_, err = p.WatchFrame("", "conn_1", &model.WebSocketMessage{
+		Type: model.FrameSubscribePresence,
+		Data: model.PresenceWatchRequest{UserIDs: []string{"u2"}},
+	})
+	assert.Equal(t, ErrCodeUnauthenticated, errorCode(err))
+
+	// 重复的用户只计一次
+	_, err = p.WatchFrame("u1", "conn_1", &model.WebSocketMessage{
+		Type: model.FrameSubscribePresence,
+		Data: model.PresenceWatchRequest{UserIDs: []string{"u2", "u3", "u2", "u4"}},
+	})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	_, err = p.WatchFrame("u1", "conn_1", &model.WebSocketMessage{
+		Type: model.FrameSubscribePresence,
+		Data: model.PresenceWatchRequest{UserIDs: []string{""}},
+	})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
+
+func TestPresenceService_DebounceKeepsLatestStatus(t *testing.T) {
+	// 窗口足够长，测试期间不会发布
+	p := NewPresenceService(nil, nil, config.PresenceConfig{Debounce: time.Hour})
+	p.SetOnline("u1")
+	p.SetOffline("u1")
+	p.SetOnline("u1")
+	p.SetOffline("u2")
+
+	// 窗口内的多次变化只保留最新状态，每个用户只有一个待发布的状态
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	assert.Equal(t, map[string]string{"u1": model.PresenceOnline, "u2": model.PresenceOffline}, p.pending)
+}
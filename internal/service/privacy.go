@@ -0,0 +1,237 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+const (
+	// maxPendingRequestMessages 消息请求被接受前同一发送者最多的待接受消息数
+	maxPendingRequestMessages = 20
+	// defaultMessageRequestLimit 消息请求列表默认条数
+	defaultMessageRequestLimit = 50
+	// maxMessageRequestLimit 消息请求列表最多条数
+	maxMessageRequestLimit = 200
+)
+
+// privacyOf 批量获取用户的隐私设置，未设置的用户使用默认设置
+func (s *MessageService) privacyOf(userIDs []string) (map[string]*model.PrivacySettings, error) {
+	settings, err := s.redisStore.GetPrivacySettings(userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get privacy settings: %w", err)
+	}
+	for _, userID := range userIDs {
+		if _, ok := settings[userID]; !ok {
+			settings[userID] = model.DefaultPrivacySettings()
+		}
+	}
+	return settings, nil
+}
+
+// checkMessagePrivacy 按接收者的隐私设置检查私聊消息，返回消息是否进入接收者的消息请求
+func (s *MessageService) checkMessagePrivacy(senderID, receiverID string) (bool, error) {
+	if senderID == receiverID {
+		return false, nil
+	}
+	settings, err := s.privacyOf([]string{receiverID})
+	if err != nil {
+		return false, err
+	}
+	contacts, err := s.redisStore.GetContacts(receiverID, []string{senderID})
+	if err != nil {
+		return false, fmt.Errorf("failed to get contacts: %w", err)
+	}
+	if contacts[senderID] {
+		if !settings[receiverID].WhoCanMessage.Allows(true) {
+			return false, newServiceError(ErrCodePrivacyRestricted, "%s does not accept private messages", receiverID)
+		}
+		return false, nil
+	}
+
+	switch settings[receiverID].WhoCanMessage {
+	case model.PrivacyEveryone:
+		count, err := s.redisStore.CountMessageRequest(receiverID, senderID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get message request: %w", err)
+		}
+		if count >= maxPendingRequestMessages {
+			return false, newServiceError(ErrCodePrivacyRestricted, "message request to %s has not been accepted yet", receiverID)
+		}
+		return true, nil
+	case model.PrivacyContacts:
+		return false, newServiceError(ErrCodePrivacyRestricted, "%s only accepts private messages from contacts", receiverID)
+	default:
+		return false, newServiceError(ErrCodePrivacyRestricted, "%s does not accept private messages", receiverID)
+	}
+}
+
+// checkGroupInvites 按成员的隐私设置检查邀请者能否在创建群组时将其加入
+func (s *MessageService) checkGroupInvites(inviterID string, userIDs []string) error {
+	invitees := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if userID != inviterID {
+			invitees = append(invitees, userID)
+		}
+	}
+	if len(invitees) == 0 {
+		return nil
+	}
+
+	settings, err := s.privacyOf(invitees)
+	if err != nil {
+		return err
+	}
+	// 联系人关系按被邀请者的视角判断
+	for _, userID := range invitees {
+		level := settings[userID].WhoCanAddToGroups
+		if level == model.PrivacyEveryone {
+			continue
+		}
+		isContact := false
+		if level == model.PrivacyContacts {
+			contacts, err := s.redisStore.GetContacts(userID, []string{inviterID})
+			if err != nil {
+				return fmt.Errorf("failed to get contacts: %w", err)
+			}
+			isContact = contacts[inviterID]
+		}
+		if !level.Allows(isContact) {
+			return newServiceError(ErrCodeGroupInviteRestricted, "%s cannot be added to groups by %s", userID, inviterID)
+		}
+	}
+	return nil
+}
+
+// queueMessageRequest 非联系人的消息进入接收者的消息请求，通知接收者的在线设备，不推送消息本身
+func (s *MessageService) queueMessageRequest(message *model.Message) {
+	count, err := s.redisStore.AddMessageRequest(message.ReceiverID, message.SenderID, message.ID, message.Timestamp)
+	if err != nil {
+		logger.Warn("Failed to add message request", logger.String("message_id", message.ID), logger.ErrorField(err))
+		return
+	}
+	s.deliverer.SendToUser(message.ReceiverID, model.WebSocketMessage{
+		Type: model.FrameMessageRequest,
+		Data: model.MessageRequest{
+			SenderID:     message.SenderID,
+			MessageCount: count,
+			UpdatedAt:    message.Timestamp,
+		},
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// ListMessageRequests 获取用户的消息请求，按最后一条消息的时间倒序
+func (s *MessageService) ListMessageRequests(userID string, limit int) ([]*model.MessageRequest, error) {
+	if limit <= 0 {
+		limit = defaultMessageRequestLimit
+	}
+	if limit > maxMessageRequestLimit {
+		limit = maxMessageRequestLimit
+	}
+
+	entries, err := s.redisStore.GetMessageRequests(userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message requests: %w", err)
+	}
+	requests := make([]*model.MessageRequest, 0, len(entries))
+	for _, entry := range entries {
+		senderID, _ := entry.Member.(string)
+		ids, err := s.redisStore.GetMessageRequestIDs(userID, senderID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get message request: %w", err)
+		}
+		request := &model.MessageRequest{
+			SenderID:     senderID,
+			MessageCount: int64(len(ids)),
+			UpdatedAt:    int64(entry.Score),
+		}
+		if len(ids) > 0 {
+			if latest, err := s.storeBackend.GetMessage(ids[len(ids)-1]); err == nil && !latest.IsDeleted() {
+				request.LatestMessage = latest
+			}
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// AcceptMessageRequest 接受消息请求，双方互为联系人，待接受的消息按发送顺序投递，返回投递的消息数
+func (s *MessageService) AcceptMessageRequest(userID, senderID string) (int, error) {
+	ids, err := s.redisStore.GetMessageRequestIDs(userID, senderID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get message request: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, newServiceError(ErrCodeNotFound, "no message request from %s", senderID)
+	}
+
+	if err := s.redisStore.AddContact(userID, senderID); err != nil {
+		return 0, fmt.Errorf("failed to add contact: %w", err)
+	}
+	if err := s.redisStore.AddContact(senderID, userID); err != nil {
+		return 0, fmt.Errorf("failed to add contact: %w", err)
+	}
+	if err := s.redisStore.DeleteMessageRequest(userID, senderID); err != nil {
+		return 0, fmt.Errorf("failed to delete message request: %w", err)
+	}
+	s.redisStore.TouchConversation(userID, model.ConversationID(model.ConversationTypePrivate, senderID), time.Now().Unix())
+
+	delivered := 0
+	for _, messageID := range ids {
+		if _, err := s.RedeliverMessage(userID, messageID); err != nil {
+			// 已被发送者删除的消息不再投递
+			continue
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+// DeclineMessageRequest 拒绝消息请求，待接受的消息不再投递，发送者之后的消息会形成新的消息请求
+func (s *MessageService) DeclineMessageRequest(userID, senderID string) error {
+	count, err := s.redisStore.CountMessageRequest(userID, senderID)
+	if err != nil {
+		return fmt.Errorf("failed to get message request: %w", err)
+	}
+	if count == 0 {
+		return newServiceError(ErrCodeNotFound, "no message request from %s", senderID)
+	}
+	if err := s.redisStore.DeleteMessageRequest(userID, senderID); err != nil {
+		return fmt.Errorf("failed to delete message request: %w", err)
+	}
+	return nil
+}
+
+// filterPendingRequests 过滤离线同步中仍在消息请求里的私聊消息，接受后再投递
+func (s *MessageService) filterPendingRequests(userID string, messages []*model.Message) []*model.Message {
+	var senders []string
+	seen := make(map[string]bool)
+	for _, m := range messages {
+		if m.IsPrivateMessage() && m.SenderID != userID && !seen[m.SenderID] {
+			seen[m.SenderID] = true
+			senders = append(senders, m.SenderID)
+		}
+	}
+	if len(senders) == 0 {
+		return messages
+	}
+
+	pending, err := s.redisStore.GetPendingSenders(userID, senders)
+	if err != nil {
+		logger.Warn("Failed to get message requests", logger.String("user_id", userID), logger.ErrorField(err))
+		return messages
+	}
+	if len(pending) == 0 {
+		return messages
+	}
+	filtered := messages[:0]
+	for _, m := range messages {
+		if !(m.IsPrivateMessage() && pending[m.SenderID]) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
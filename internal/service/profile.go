@@ -0,0 +1,252 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/i18n"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"gorm.io/gorm"
+)
+
+// ProfileService 用户资料服务，偏好语言持久化在MySQL并缓存在Redis，未启用MySQL时只保存在Redis；
+// 免打扰计划保存在Redis中，投递新消息时按接收者批量读取
+type ProfileService struct {
+	mysqlStore *store.MySQLStore
+	redisStore *store.RedisStore
+	catalog    *i18n.Catalog
+}
+
+// NewProfileService 创建用户资料服务，mysqlStore可以为nil
+func NewProfileService(mysqlStore *store.MySQLStore, redisStore *store.RedisStore, catalog *i18n.Catalog) *ProfileService {
+	return &ProfileService{
+		mysqlStore: mysqlStore,
+		redisStore: redisStore,
+		catalog:    catalog,
+	}
+}
+
+// GetProfile 获取用户资料，未设置时返回空语言
+func (p *ProfileService) GetProfile(userID string) (*model.UserProfile, error) {
+	if p.mysqlStore == nil {
+		languages, err := p.Languages([]string{userID})
+		if err != nil {
+			return nil, err
+		}
+		return &model.UserProfile{UserID: userID, Language: languages[userID]}, nil
+	}
+
+	profile, err := p.mysqlStore.GetUserProfile(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &model.UserProfile{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
+	}
+	return profile, nil
+}
+
+// UpdateLanguage 设置用户偏好语言，language为空表示恢复默认语言
+func (p *ProfileService) UpdateLanguage(userID, language string) (*model.UserProfile, error) {
+	if language != "" {
+		canonical, err := i18n.Canonicalize(language)
+		if err != nil {
+			return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+		}
+		language = canonical
+	}
+
+	profile := &model.UserProfile{
+		UserID:    userID,
+		Language:  language,
+		UpdatedAt: time.Now(),
+	}
+	if p.mysqlStore != nil {
+		if err := p.mysqlStore.SaveUserProfile(profile); err != nil {
+			return nil, fmt.Errorf("failed to save user profile: %w", err)
+		}
+	}
+	if err := p.redisStore.SetUserLanguage(userID, language); err != nil {
+		return nil, fmt.Errorf("failed to cache user language: %w", err)
+	}
+	return profile, nil
+}
+
+// Provision 身份提供方登录时创建或同步用户资料，新建时偏好语言取自身份提供方，返回是否新建
+// 未启用MySQL时不保存资料
+func (p *ProfileService) Provision(userID, displayName, email, locale string) (bool, error) {
+	if p.mysqlStore == nil {
+		return false, nil
+	}
+
+	profile := &model.UserProfile{
+		UserID:      userID,
+		DisplayName: displayName,
+		Email:       email,
+		UpdatedAt:   time.Now(),
+	}
+	if locale != "" {
+		if canonical, err := i18n.Canonicalize(locale); err == nil {
+			profile.Language = canonical
+		}
+	}
+	created, err := p.mysqlStore.ProvisionUserProfile(profile)
+	if err != nil {
+		return false, fmt.Errorf("failed to provision user profile: %w", err)
+	}
+	if created && profile.Language != "" {
+		if err := p.redisStore.SetUserLanguage(userID, profile.Language); err != nil {
+			return true, fmt.Errorf("failed to cache user language: %w", err)
+		}
+	}
+	return created, nil
+}
+
+// Languages 批量获取用户偏好语言，先查Redis，未缓存的用户回源MySQL并回填缓存
+func (p *ProfileService) Languages(userIDs []string) (map[string]string, error) {
+	languages, missing, err := p.redisStore.GetUserLanguages(userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached languages: %w", err)
+	}
+	if len(missing) == 0 || p.mysqlStore == nil {
+		return languages, nil
+	}
+
+	stored, err := p.mysqlStore.GetUserLanguages(missing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user languages: %w", err)
+	}
+	for _, userID := range missing {
+		// 未设置的用户也回填空值，避免每次都回源
+		languages[userID] = stored[userID]
+		p.redisStore.SetUserLanguage(userID, stored[userID])
+	}
+	return languages, nil
+}
+
+// EffectiveLanguage 用户实际使用的语言，即偏好语言在目录中最接近的匹配
+func (p *ProfileService) EffectiveLanguage(language string) string {
+	return p.catalog.Match(language)
+}
+
+// SupportedLanguages 目录支持的语言
+func (p *ProfileService) SupportedLanguages() []string {
+	return p.catalog.Languages()
+}
+
+// GetQuietHours 获取用户的免打扰计划，未设置时返回未启用的空计划
+func (p *ProfileService) GetQuietHours(userID string) (*model.QuietHours, error) {
+	schedules, err := p.redisStore.GetQuietHours([]string{userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiet hours: %w", err)
+	}
+	if quietHours, ok := schedules[userID]; ok {
+		return quietHours, nil
+	}
+	return &model.QuietHours{Windows: []model.QuietWindow{}}, nil
+}
+
+// UpdateQuietHours 设置用户的免打扰计划，整体覆盖
+func (p *ProfileService) UpdateQuietHours(userID string, quietHours *model.QuietHours) (*model.QuietHours, error) {
+	if err := quietHours.Validate(); err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	if quietHours.Windows == nil {
+		quietHours.Windows = []model.QuietWindow{}
+	}
+	quietHours.UpdatedAt = time.Now().Unix()
+	if err := p.redisStore.SetQuietHours(userID, quietHours); err != nil {
+		return nil, fmt.Errorf("failed to save quiet hours: %w", err)
+	}
+	return quietHours, nil
+}
+
+// QuietUsers 在now时刻处于免打扰时段的用户
+func (p *ProfileService) QuietUsers(userIDs []string, now time.Time) (map[string]bool, error) {
+	schedules, err := p.redisStore.GetQuietHours(userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quiet hours: %w", err)
+	}
+	quiet := make(map[string]bool)
+	for userID, quietHours := range schedules {
+		if quietHours.Active(now) {
+			quiet[userID] = true
+		}
+	}
+	return quiet, nil
+}
+
+// GetPrivacy 获取用户的隐私设置，未设置时返回默认设置
+func (p *ProfileService) GetPrivacy(userID string) (*model.PrivacySettings, error) {
+	settings, err := p.redisStore.GetPrivacySettings([]string{userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get privacy settings: %w", err)
+	}
+	if privacy, ok := settings[userID]; ok {
+		return privacy, nil
+	}
+	return model.DefaultPrivacySettings(), nil
+}
+
+// UpdatePrivacy 设置用户的隐私设置，为空的字段视为everyone
+func (p *ProfileService) UpdatePrivacy(userID string, whoCanMessage, whoCanAddToGroups string) (*model.PrivacySettings, error) {
+	message, err := model.ParsePrivacyLevel(whoCanMessage)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	groups, err := model.ParsePrivacyLevel(whoCanAddToGroups)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+
+	privacy := &model.PrivacySettings{
+		WhoCanMessage:     message,
+		WhoCanAddToGroups: groups,
+		UpdatedAt:         time.Now().Unix(),
+	}
+	if err := p.redisStore.SetPrivacySettings(userID, privacy); err != nil {
+		return nil, fmt.Errorf("failed to save privacy settings: %w", err)
+	}
+	return privacy, nil
+}
+
+// GetNotificationPreferences 获取用户的通知偏好，未设置时返回默认偏好
+func (p *ProfileService) GetNotificationPreferences(userID string) (*model.NotificationPreferences, error) {
+	prefs, err := p.redisStore.GetNotificationPreferences([]string{userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+	if pref, ok := prefs[userID]; ok {
+		return pref, nil
+	}
+	return model.DefaultNotificationPreferences(), nil
+}
+
+// UpdateNotificationPreferences 设置用户的通知偏好，groupDigest为空时视为auto
+func (p *ProfileService) UpdateNotificationPreferences(userID, groupDigest string) (*model.NotificationPreferences, error) {
+	mode, err := model.ParseDigestMode(groupDigest)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+
+	prefs := &model.NotificationPreferences{GroupDigest: mode, UpdatedAt: time.Now().Unix()}
+	if err := p.redisStore.SetNotificationPreferences(userID, prefs); err != nil {
+		return nil, fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// DigestModes 批量获取用户的群消息摘要方式，未设置的用户不在结果中
+func (p *ProfileService) DigestModes(userIDs []string) (map[string]model.DigestMode, error) {
+	prefs, err := p.redisStore.GetNotificationPreferences(userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+	modes := make(map[string]model.DigestMode, len(prefs))
+	for userID, pref := range prefs {
+		modes[userID] = pref.GroupDigest
+	}
+	return modes, nil
+}
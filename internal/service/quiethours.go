@@ -0,0 +1,35 @@
+package service
+
+import (
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// quietUsers 处于免打扰时段的接收者，紧急消息不静音，未配置用户资料或读取失败时按无人免打扰处理
+func (s *MessageService) quietUsers(userIDs []string, message *model.Message) map[string]bool {
+	if s.profiles == nil || len(userIDs) == 0 || message.Priority == model.MessagePriorityUrgent {
+		return nil
+	}
+	quiet, err := s.profiles.QuietUsers(userIDs, time.Now())
+	if err != nil {
+		logger.Warn("Failed to get quiet hours", logger.String("message_id", message.ID), logger.ErrorField(err))
+		return nil
+	}
+	return quiet
+}
+
+// PrivateMessageFrame 构造私聊新消息推送帧，接收者处于免打扰时段时静音提醒
+// 消息照常投递和计入离线队列，只改变推送提示；启用通知内容时按接收者的语言渲染并附带未读总数
+func (s *MessageService) PrivateMessageFrame(message *model.Message) model.WebSocketMessage {
+	receiverID := message.ReceiverID
+	frame := model.NewMessageFrame(model.FrameNewMessage, message)
+	if s.quietUsers([]string{receiverID}, message)[receiverID] {
+		frame = model.NewQuietMessageFrame(model.FrameNewMessage, message)
+	}
+	if !s.notifyCfg.Enabled {
+		return frame
+	}
+	return s.notify(frame, message, receiverID, s.userLanguage(receiverID), s.unreadBadge(receiverID))
+}
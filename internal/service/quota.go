@@ -0,0 +1,396 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// quotaPersistBatch 每轮持久化的最大主体数
+const quotaPersistBatch = 500
+
+// QuotaCounters 配额的用量计数和覆盖，由RedisStore实现
+type QuotaCounters interface {
+	ConsumeQuota(subject, field, day string, delta, limit int64) (int64, bool, error)
+	InitQuotaUsage(usage *model.QuotaUsage) error
+	GetQuotaUsage(subject string) (*model.QuotaUsage, bool, error)
+	SetQuotaOverrides(subject string, overrides model.QuotaOverrides) error
+	DeleteQuotaOverrides(subject string) error
+	GetQuotaOverrides(subject string) (model.QuotaOverrides, error)
+	PopDirtyQuotaSubjects(count int) ([]string, error)
+	MarkQuotaDirty(subjects ...string) error
+}
+
+// QuotaService 用户和租户的配额
+// 计数保存在Redis中并在检查时原子增减，定期持久化到MySQL；Redis丢失计数时从MySQL恢复
+// 用户同时受自身和所属租户的配额限制，租户由用户ID中的前缀确定
+type QuotaService struct {
+	redisStore QuotaCounters
+	mysqlStore *store.MySQLStore
+	cfg        config.QuotaConfig
+}
+
+// NewQuotaService 创建配额服务，mysqlStore为nil时计数只保存在Redis中
+func NewQuotaService(redisStore *store.RedisStore, mysqlStore *store.MySQLStore, cfg config.QuotaConfig) *QuotaService {
+	return &QuotaService{
+		redisStore: redisStore,
+		mysqlStore: mysqlStore,
+		cfg:        cfg,
+	}
+}
+
+// Start 定期持久化变化的计数
+func (q *QuotaService) Start() {
+	if q.mysqlStore == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(q.cfg.PersistInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := q.persist(); err != nil {
+				logger.Warn("Failed to persist quota usage", logger.ErrorField(err))
+			}
+		}
+	}()
+}
+
+// ConsumeMessage 计入一条消息，超出用户或租户的每日消息数时返回quota_exceeded
+func (q *QuotaService) ConsumeMessage(userID string) error {
+	if q == nil {
+		return nil
+	}
+	return q.consume(userID, store.QuotaFieldMessages, 1, func(l model.QuotaLimits) int64 { return l.MessagesPerDay })
+}
+
+// ReleaseMessage 退还ConsumeMessage计入的消息，用于未能保存的消息
+func (q *QuotaService) ReleaseMessage(userID string) {
+	if q == nil {
+		return
+	}
+	day := time.Now().UTC().Format(model.AnalyticsDayLayout)
+	for _, subject := range q.subjects(userID) {
+		q.release(subject, store.QuotaFieldMessages, day, 1)
+	}
+}
+
+// ReserveMedia 为即将上传的媒体文件预留存储空间
+func (q *QuotaService) ReserveMedia(userID string, size int64) error {
+	if q == nil {
+		return nil
+	}
+	if size <= 0 {
+		return newServiceError(ErrCodeInvalidRequest, "size must be positive")
+	}
+	return q.consume(userID, store.QuotaFieldMediaBytes, size, func(l model.QuotaLimits) int64 { return l.MediaBytes })
+}
+
+// ReleaseMedia 退还ReserveMedia预留的存储空间，用于未完成的上传
+func (q *QuotaService) ReleaseMedia(userID string, size int64) {
+	if q == nil {
+		return
+	}
+	day := time.Now().UTC().Format(model.AnalyticsDayLayout)
+	for _, subject := range q.subjects(userID) {
+		q.release(subject, store.QuotaFieldMediaBytes, day, size)
+	}
+}
+
+// ConsumeGroup 计入一个新建的群组，群组创建失败时需调用ReleaseGroup
+func (q *QuotaService) ConsumeGroup(ownerID string) error {
+	if q == nil {
+		return nil
+	}
+	return q.consume(ownerID, store.QuotaFieldOwnedGroups, 1, func(l model.QuotaLimits) int64 { return l.MaxGroups })
+}
+
+// ReleaseGroup 退还ConsumeGroup计入的群组
+func (q *QuotaService) ReleaseGroup(ownerID string) {
+	if q == nil {
+		return
+	}
+	day := time.Now().UTC().Format(model.AnalyticsDayLayout)
+	for _, subject := range q.subjects(ownerID) {
+		q.release(subject, store.QuotaFieldOwnedGroups, day, 1)
+	}
+}
+
+// CheckGroupSize 检查群组人数是否超出群主的群组人数配额
+func (q *QuotaService) CheckGroupSize(ownerID string, size int64) error {
+	if q == nil {
+		return nil
+	}
+	for _, subject := range q.subjects(ownerID) {
+		limits, _, err := q.limits(subject)
+		if err != nil {
+			return err
+		}
+		if limits.MaxGroupSize > 0 && size > limits.MaxGroupSize {
+			return quotaExceeded(model.QuotaMaxGroupSize, subject, limits.MaxGroupSize)
+		}
+	}
+	return nil
+}
+
+// Report 获取主体的配额和用量
+func (q *QuotaService) Report(subject string) (*model.QuotaReport, error) {
+	if err := q.validateSubject(subject); err != nil {
+		return nil, err
+	}
+	if err := q.ensureLoaded(subject); err != nil {
+		return nil, err
+	}
+	limits, overrides, err := q.limits(subject)
+	if err != nil {
+		return nil, err
+	}
+	usage, _, err := q.redisStore.GetQuotaUsage(subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+	if usage == nil {
+		usage = &model.QuotaUsage{Subject: subject}
+	}
+	// 计数只在下一次计入消息时按日期归零，这里按今天展示
+	if today := time.Now().UTC().Format(model.AnalyticsDayLayout); usage.Day != today {
+		usage.Day = today
+		usage.Messages = 0
+	}
+	usage.Overrides = overrides
+	return &model.QuotaReport{Subject: subject, Limits: limits, Overrides: overrides, Usage: usage}, nil
+}
+
+// SetOverrides 调整主体的配额，覆盖在已有覆盖之上合并
+func (q *QuotaService) SetOverrides(subject string, overrides model.QuotaOverrides) (*model.QuotaReport, error) {
+	if err := q.validateSubject(subject); err != nil {
+		return nil, err
+	}
+	for _, v := range []*int64{overrides.MessagesPerDay, overrides.MediaBytes, overrides.MaxGroups, overrides.MaxGroupSize} {
+		if v != nil && *v < 0 {
+			return nil, newServiceError(ErrCodeInvalidRequest, "quota limits must not be negative")
+		}
+	}
+	if err := q.ensureLoaded(subject); err != nil {
+		return nil, err
+	}
+	current, err := q.redisStore.GetQuotaOverrides(subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota overrides: %w", err)
+	}
+	if overrides.MessagesPerDay != nil {
+		current.MessagesPerDay = overrides.MessagesPerDay
+	}
+	if overrides.MediaBytes != nil {
+		current.MediaBytes = overrides.MediaBytes
+	}
+	if overrides.MaxGroups != nil {
+		current.MaxGroups = overrides.MaxGroups
+	}
+	if overrides.MaxGroupSize != nil {
+		current.MaxGroupSize = overrides.MaxGroupSize
+	}
+	if err := q.redisStore.SetQuotaOverrides(subject, current); err != nil {
+		return nil, fmt.Errorf("failed to set quota overrides: %w", err)
+	}
+	return q.Report(subject)
+}
+
+// ResetOverrides 删除主体的配额覆盖，恢复为默认配额
+func (q *QuotaService) ResetOverrides(subject string) (*model.QuotaReport, error) {
+	if err := q.validateSubject(subject); err != nil {
+		return nil, err
+	}
+	if err := q.ensureLoaded(subject); err != nil {
+		return nil, err
+	}
+	if err := q.redisStore.DeleteQuotaOverrides(subject); err != nil {
+		return nil, fmt.Errorf("failed to reset quota overrides: %w", err)
+	}
+	return q.Report(subject)
+}
+
+// consume 依次在用户和租户的配额内计数，任一超出时退还已计入的部分
+func (q *QuotaService) consume(userID, field string, delta int64, limitOf func(model.QuotaLimits) int64) error {
+	day := time.Now().UTC().Format(model.AnalyticsDayLayout)
+	var consumed []string
+	for _, subject := range q.subjects(userID) {
+		ok, limit, err := q.consumeSubject(subject, field, day, delta, limitOf)
+		if err != nil {
+			q.rollback(consumed, field, day, delta)
+			return err
+		}
+		if !ok {
+			q.rollback(consumed, field, day, delta)
+			return quotaExceeded(quotaKind(field), subject, limit)
+		}
+		consumed = append(consumed, subject)
+	}
+	return nil
+}
+
+// consumeSubject 在单个主体的配额内计数，计数不在Redis中时先恢复，恢复的配额覆盖随之生效
+func (q *QuotaService) consumeSubject(subject, field, day string, delta int64, limitOf func(model.QuotaLimits) int64) (bool, int64, error) {
+	limits, _, err := q.limits(subject)
+	if err != nil {
+		return false, 0, err
+	}
+	limit := limitOf(limits)
+	_, ok, err := q.redisStore.ConsumeQuota(subject, field, day, delta, limit)
+	if errors.Is(err, store.ErrQuotaUsageMissing) {
+		if err := q.ensureLoaded(subject); err != nil {
+			return false, 0, err
+		}
+		if limits, _, err = q.limits(subject); err != nil {
+			return false, 0, err
+		}
+		limit = limitOf(limits)
+		_, ok, err = q.redisStore.ConsumeQuota(subject, field, day, delta, limit)
+	}
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to consume quota: %w", err)
+	}
+	return ok, limit, nil
+}
+
+// rollback 退还已计入的计数
+func (q *QuotaService) rollback(subjects []string, field, day string, delta int64) {
+	for _, subject := range subjects {
+		q.release(subject, field, day, delta)
+	}
+}
+
+// release 减少主体的计数，失败时只记录日志，计数会略高于实际用量
+func (q *QuotaService) release(subject, field, day string, delta int64) {
+	if _, _, err := q.redisStore.ConsumeQuota(subject, field, day, -delta, 0); err != nil && !errors.Is(err, store.ErrQuotaUsageMissing) {
+		logger.Warn("Failed to release quota", logger.String("subject", subject), logger.String("field", field), logger.ErrorField(err))
+	}
+}
+
+// ensureLoaded 计数不在Redis中时从MySQL恢复，没有持久化记录时从零开始
+func (q *QuotaService) ensureLoaded(subject string) error {
+	if _, exists, err := q.redisStore.GetQuotaUsage(subject); err != nil || exists {
+		if err != nil {
+			return fmt.Errorf("failed to get quota usage: %w", err)
+		}
+		return nil
+	}
+
+	var usage *model.QuotaUsage
+	if q.mysqlStore != nil {
+		var err error
+		usage, err = q.mysqlStore.GetQuotaUsage(subject)
+		if err != nil {
+			return fmt.Errorf("failed to load quota usage: %w", err)
+		}
+	}
+	if usage == nil {
+		usage = &model.QuotaUsage{Subject: subject, Day: time.Now().UTC().Format(model.AnalyticsDayLayout)}
+	}
+	if err := q.redisStore.InitQuotaUsage(usage); err != nil {
+		return fmt.Errorf("failed to init quota usage: %w", err)
+	}
+	return nil
+}
+
+// limits 主体的有效配额和覆盖
+func (q *QuotaService) limits(subject string) (model.QuotaLimits, model.QuotaOverrides, error) {
+	overrides, err := q.redisStore.GetQuotaOverrides(subject)
+	if err != nil {
+		return model.QuotaLimits{}, overrides, fmt.Errorf("failed to get quota overrides: %w", err)
+	}
+	defaults := q.cfg.User
+	if strings.HasPrefix(subject, model.QuotaSubjectTenant+":") {
+		defaults = q.cfg.Tenant
+	}
+	return overrides.Apply(model.QuotaLimits{
+		MessagesPerDay: defaults.MessagesPerDay,
+		MediaBytes:     defaults.MediaBytes,
+		MaxGroups:      defaults.MaxGroups,
+		MaxGroupSize:   defaults.MaxGroupSize,
+	}), overrides, nil
+}
+
+// subjects 用户受限的配额主体：用户自身和所属租户
+func (q *QuotaService) subjects(userID string) []string {
+	subjects := []string{model.QuotaSubject(model.QuotaSubjectUser, userID)}
+	if tenant := tenantOf(userID, q.cfg.TenantSeparator); tenant != "" {
+		subjects = append(subjects, model.QuotaSubject(model.QuotaSubjectTenant, tenant))
+	}
+	return subjects
+}
+
+// validateSubject 校验管理接口传入的主体
+func (q *QuotaService) validateSubject(subject string) error {
+	kind, id, ok := strings.Cut(subject, ":")
+	if !ok || id == "" || (kind != model.QuotaSubjectUser && kind != model.QuotaSubjectTenant) {
+		return newServiceError(ErrCodeInvalidRequest, "invalid quota subject: %s", subject)
+	}
+	return nil
+}
+
+// persist 持久化计数变化过的主体，失败的主体重新标记等待下一轮
+func (q *QuotaService) persist() error {
+	subjects, err := q.redisStore.PopDirtyQuotaSubjects(quotaPersistBatch)
+	if err != nil || len(subjects) == 0 {
+		return err
+	}
+
+	usages := make([]*model.QuotaUsage, 0, len(subjects))
+	for _, subject := range subjects {
+		usage, exists, err := q.redisStore.GetQuotaUsage(subject)
+		if err != nil {
+			q.redisStore.MarkQuotaDirty(subjects...)
+			return fmt.Errorf("failed to get quota usage: %w", err)
+		}
+		if !exists {
+			continue
+		}
+		if usage.Overrides, err = q.redisStore.GetQuotaOverrides(subject); err != nil {
+			q.redisStore.MarkQuotaDirty(subjects...)
+			return fmt.Errorf("failed to get quota overrides: %w", err)
+		}
+		usage.UpdatedAt = time.Now()
+		usages = append(usages, usage)
+	}
+
+	if err := q.mysqlStore.SaveQuotaUsages(usages); err != nil {
+		q.redisStore.MarkQuotaDirty(subjects...)
+		return fmt.Errorf("failed to save quota usage: %w", err)
+	}
+	return nil
+}
+
+// tenantOf 用户ID中分隔符之前的部分为租户，没有分隔符或前缀为空时不属于任何租户
+func tenantOf(userID, separator string) string {
+	if separator == "" {
+		return ""
+	}
+	tenant, _, ok := strings.Cut(userID, separator)
+	if !ok {
+		return ""
+	}
+	return tenant
+}
+
+// quotaKind 计数字段对应的配额类型
+func quotaKind(field string) string {
+	switch field {
+	case store.QuotaFieldMessages:
+		return model.QuotaMessagesPerDay
+	case store.QuotaFieldMediaBytes:
+		return model.QuotaMediaBytes
+	default:
+		return model.QuotaMaxGroups
+	}
+}
+
+// quotaExceeded 超出配额的错误
+func quotaExceeded(kind, subject string, limit int64) error {
+	return newServiceError(ErrCodeQuotaExceeded, "%s quota exceeded for %s (limit %d)", kind, subject, limit)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+func TestQuotaSubjects(t *testing.T) {
+	q := NewQuotaService(nil, nil, config.QuotaConfig{TenantSeparator: ":"})
+	assert.Equal(t, []string{"user:acme:alice", "tenant:acme"}, q.subjects("acme:alice"))
+	assert.Equal(t, []string{"user:bob"}, q.subjects("bob"))
+
+	q = NewQuotaService(nil, nil, config.QuotaConfig{})
+	assert.Equal(t, []string{"user:acme:alice"}, q.subjects("acme:alice"))
+}
+
+func TestQuotaService_ValidateSubject(t *testing.T) {
+	q := NewQuotaService(nil, nil, config.QuotaConfig{})
+	assert.NoError(t, q.validateSubject("user:alice"))
+	assert.NoError(t, q.validateSubject("tenant:acme"))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(q.validateSubject("group:1")))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(q.validateSubject("user:")))
+}
+
+func TestQuotaOverrides_Apply(t *testing.T) {
+	zero, ten := int64(0), int64(10)
+	defaults := model.QuotaLimits{MessagesPerDay: 1000, MediaBytes: 1 << 20, MaxGroups: 5, MaxGroupSize: 200}
+
+	limits := model.QuotaOverrides{MessagesPerDay: &ten, MaxGroups: &zero}.Apply(defaults)
+	assert.Equal(t, model.QuotaLimits{MessagesPerDay: 10, MediaBytes: 1 << 20, MaxGroups: 0, MaxGroupSize: 200}, limits)
+	assert.True(t, model.QuotaOverrides{}.IsEmpty())
+}
+
+func TestQuotaService_NilIsUnlimited(t *testing.T) {
+	var q *QuotaService
+	assert.NoError(t, q.ConsumeMessage("u1"))
+	assert.NoError(t, q.ConsumeGroup("u1"))
+	assert.NoError(t, q.CheckGroupSize("u1", 10000))
+	assert.NoError(t, q.ReserveMedia("u1", 1))
+}
+
+// memoryQuotaCounters 内存中的配额计数，没有配额覆盖
+type memoryQuotaCounters struct {
+	usage map[string]*model.QuotaUsage
+}
+
+func newMemoryQuotaCounters() *memoryQuotaCounters {
+	return &memoryQuotaCounters{usage: make(map[string]*model.QuotaUsage)}
+}
+
+func (m *memoryQuotaCounters) ConsumeQuota(subject, field, day string, delta, limit int64) (int64, bool, error) {
+	usage, ok := m.usage[subject]
+	if !ok {
+		return 0, false, store.ErrQuotaUsageMissing
+	}
+	if field != store.QuotaFieldMessages {
+		return 0, false, errors.New("unexpected quota field " + field)
+	}
+	if usage.Day != day {
+		usage.Day, usage.Messages = day, 0
+	}
+	if delta > 0 && limit > 0 && usage.Messages+delta > limit {
+		return usage.Messages, false, nil
+	}
+	usage.Messages += delta
+	return usage.Messages, true, nil
+}
+
+func (m *memoryQuotaCounters) InitQuotaUsage(usage *model.QuotaUsage) error {
+	if _, ok := m.usage[usage.Subject]; !ok {
+		copied := *usage
+		m.usage[usage.Subject] = &copied
+	}
+	return nil
+}
+
+func (m *memoryQuotaCounters) GetQuotaUsage(subject string) (*model.QuotaUsage, bool, error) {
+	usage, ok := m.usage[subject]
+	return usage, ok, nil
+}
+
+func (m *memoryQuotaCounters) SetQuotaOverrides(string, model.QuotaOverrides) error { return nil }
+func (m *memoryQuotaCounters) DeleteQuotaOverrides(string) error                    { return nil }
+func (m *memoryQuotaCounters) GetQuotaOverrides(string) (model.QuotaOverrides, error) {
+	return model.QuotaOverrides{}, nil
+}
+func (m *memoryQuotaCounters) PopDirtyQuotaSubjects(int) ([]string, error) { return nil, nil }
+func (m *memoryQuotaCounters) MarkQuotaDirty(...string) error              { return nil }
+
+// failingBackend 保存消息总是失败的存储后端
+type failingBackend struct {
+	MessageStoreBackend
+}
+
+func (failingBackend) SaveMessage(*model.Message) error { return errors.New("mysql unavailable") }
+
+func TestQuotaInterceptor_RefundsUnsavedMessages(t *testing.T) {
+	counters := newMemoryQuotaCounters()
+	quota := NewQuotaService(nil, nil, config.QuotaConfig{TenantSeparator: ":", User: config.QuotaLimitsConfig{MessagesPerDay: 2}})
+	quota.redisStore = counters
+	s := NewMessageServiceWithBackend(failingBackend{}, nil, nil, nil)
+	s.Intercept(StagePrePersist, InterceptorSanction, nil)
+	s.Intercept(StagePrePersist, InterceptorPrivacy, nil)
+	s.SetQuota(quota)
+	messages := func(subject string) int64 { return counters.usage[subject].Messages }
+
+	// 保存失败时退还用户和租户的计数
+	req := &SendRequest{SenderID: "acme:alice", ReceiverID: "bob", Type: model.MessageTypeText, Content: "hi"}
+	assert.NoError(t, s.pipeline.run(StagePrePersist, req))
+	assert.Equal(t, int64(1), messages("user:acme:alice"))
+	assert.Equal(t, int64(1), messages("tenant:acme"))
+	err := s.saveMessage(req, &model.Message{ID: "m1", SenderID: "acme:alice", ReceiverID: "bob"})
+	assert.ErrorContains(t, err, "mysql unavailable")
+	assert.Equal(t, int64(0), messages("user:acme:alice"))
+	assert.Equal(t, int64(0), messages("tenant:acme"))
+
+	// 排在配额之后的拦截器拒绝时同样退还
+	s.Intercept(StagePrePersist, "reject", func(*SendRequest) error {
+		return newServiceError(ErrCodeContentBlocked, "blocked")
+	})
+	for i := 0; i < 3; i++ {
+		req = &SendRequest{SenderID: "acme:alice", ReceiverID: "bob", Type: model.MessageTypeText, Content: "hi"}
+		assert.Equal(t, ErrCodeContentBlocked, errorCode(s.pipeline.run(StagePrePersist, req)))
+	}
+	assert.Equal(t, int64(0), messages("user:acme:alice"))
+	s.Intercept(StagePrePersist, "reject", nil)
+
+	// 退还后的额度仍可使用，超出时拒绝且不退还已计入的消息
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, s.pipeline.run(StagePrePersist, &SendRequest{SenderID: "acme:alice", ReceiverID: "bob", Type: model.MessageTypeText, Content: "hi"}))
+	}
+	err = s.pipeline.run(StagePrePersist, &SendRequest{SenderID: "acme:alice", ReceiverID: "bob", Type: model.MessageTypeText, Content: "hi"})
+	assert.Equal(t, ErrCodeQuotaExceeded, errorCode(err))
+	assert.Equal(t, int64(2), messages("user:acme:alice"))
+}
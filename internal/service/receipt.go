@@ -0,0 +1,211 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// AcknowledgeMessage 接收者确认消息已投递或已读，记录回执
+func (s *MessageService) AcknowledgeMessage(userID, messageID string, status model.MessageStatus) error {
+	if status != model.MessageStatusDelivered && status != model.MessageStatusRead {
+		return newServiceError(ErrCodeInvalidRequest, "invalid ack status: %s", status)
+	}
+
+	message, err := s.storeBackend.GetMessage(messageID)
+	if err != nil {
+		return newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+	if message.SenderID == userID {
+		return newServiceError(ErrCodeInvalidRequest, "cannot acknowledge your own message")
+	}
+	ok, err := s.canAccessMessage(userID, message)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+
+	if err := s.recordReceipt(message, userID, status); err != nil {
+		return err
+	}
+	if status == model.MessageStatusRead {
+		s.clearUnread(userID, message)
+	}
+	s.latency.Acked(messageID, userID, time.Now())
+	return nil
+}
+
+// MarkDelivered 服务端把离线消息推送给在线的接收者后记录推送阶段耗时和投递回执，不计为客户端确认
+func (s *MessageService) MarkDelivered(userID string, message *model.Message) error {
+	s.latency.Pushed(message)
+	return s.recordReceipt(message, userID, model.MessageStatusDelivered)
+}
+
+// recordReceipt 按消息的回执粒度保存回执，私聊消息同时更新消息状态
+func (s *MessageService) recordReceipt(message *model.Message, userID string, status model.MessageStatus) error {
+	mode, err := s.receiptMode(message)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch mode {
+	case model.ReceiptsNone:
+	case model.ReceiptsAggregate:
+		if err := s.redisStore.AddReceiptCount(message.ID, userID, status, s.groupCfg.ReceiptTTL); err != nil {
+			return fmt.Errorf("failed to count receipt: %w", err)
+		}
+	default:
+		if err := s.storeBackend.SaveReceipt(message.ID, userID, status, now.Unix()); err != nil {
+			return fmt.Errorf("failed to save receipt: %w", err)
+		}
+	}
+	if mode != model.ReceiptsNone {
+		s.events.ReceiptRecorded(message, userID, status)
+	}
+	if status == model.MessageStatusDelivered {
+		s.analytics.RecordDelivery(message, now)
+	}
+	if message.IsPrivateMessage() && s.mysqlStore != nil {
+		if err := s.mysqlStore.UpdateMessageStatus(message.ID, status); err != nil {
+			logger.Warn("Failed to update message status", logger.String("message_id", message.ID), logger.ErrorField(err))
+		}
+	}
+	return nil
+}
+
+// receiptMode 消息生效的回执粒度，私聊总是按接收者记录
+func (s *MessageService) receiptMode(message *model.Message) (model.ReceiptMode, error) {
+	if message.IsPrivateMessage() || s.mysqlStore == nil {
+		return model.ReceiptsFull, nil
+	}
+	group, err := s.mysqlStore.GetGroup(message.GroupID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get group: %w", err)
+	}
+	return s.groupReceiptMode(group), nil
+}
+
+// groupReceiptMode 群组生效的回执粒度，未设置时按服务端配置；超大群不按成员记录，设置为full时按aggregate处理
+func (s *MessageService) groupReceiptMode(group *model.Group) model.ReceiptMode {
+	mode := group.Settings.Receipts
+	if mode == "" {
+		mode = s.groupCfg.Receipts
+		if group.IsChannel() {
+			mode = s.groupCfg.ChannelReceipts
+		}
+	}
+	if mode == "" {
+		mode = model.ReceiptsFull
+	}
+	if mode == model.ReceiptsFull && group.IsChannel() {
+		return model.ReceiptsAggregate
+	}
+	return mode
+}
+
+// GetReceipts 获取消息的投递报告，仅发送者可查看
+// 私聊只有一个接收者；群消息按群组的回执粒度：full列出除发送者外的所有成员，aggregate只有人数，none不统计
+func (s *MessageService) GetReceipts(userID, messageID string) (*model.ReceiptReport, error) {
+	message, err := s.storeBackend.GetMessage(messageID)
+	if err != nil {
+		return nil, newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+	if message.SenderID != userID {
+		return nil, newServiceError(ErrCodeForbidden, "only the sender can view delivery receipts")
+	}
+
+	if message.IsPrivateMessage() {
+		receipts, err := s.storeBackend.GetReceipts(messageID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get receipts: %w", err)
+		}
+		return buildReceiptReport(messageID, []string{message.ReceiverID}, receipts, 1), nil
+	}
+
+	group, err := s.mysqlStore.GetGroup(message.GroupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	mode := s.groupReceiptMode(group)
+	if mode != model.ReceiptsFull {
+		count, err := s.GetMemberCount(message.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		report := &model.ReceiptReport{MessageID: messageID, Mode: mode, Total: int(count) - 1, Receipts: []*model.MessageReceipt{}}
+		if mode == model.ReceiptsAggregate {
+			delivered, read, err := s.redisStore.GetReceiptCounts(messageID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get receipt counts: %w", err)
+			}
+			report.Delivered, report.Read = clampReceiptCounts(int(delivered), int(read), report.Total)
+		}
+		return report, nil
+	}
+
+	receipts, err := s.storeBackend.GetReceipts(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipts: %w", err)
+	}
+	members, err := s.groupMemberIDs(message.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	recipients := make([]string, 0, len(members))
+	for _, member := range members {
+		if member != message.SenderID {
+			recipients = append(recipients, member)
+		}
+	}
+	return buildReceiptReport(messageID, recipients, receipts, len(recipients)), nil
+}
+
+// clampReceiptCounts 近似人数不超过接收者总数，已读人数不超过已投递人数
+func clampReceiptCounts(delivered, read, total int) (int, int) {
+	if total < 0 {
+		total = 0
+	}
+	if delivered > total {
+		delivered = total
+	}
+	if read > delivered {
+		read = delivered
+	}
+	return delivered, read
+}
+
+// buildReceiptReport 汇总回执，recipients不为空时按接收者列出，未确认的接收者状态为sent
+func buildReceiptReport(messageID string, recipients []string, receipts []*model.MessageReceipt, total int) *model.ReceiptReport {
+	report := &model.ReceiptReport{MessageID: messageID, Mode: model.ReceiptsFull, Total: total}
+	if recipients != nil {
+		byUser := make(map[string]*model.MessageReceipt, len(receipts))
+		for _, r := range receipts {
+			byUser[r.UserID] = r
+		}
+		receipts = make([]*model.MessageReceipt, 0, len(recipients))
+		for _, userID := range recipients {
+			r, ok := byUser[userID]
+			if !ok {
+				r = &model.MessageReceipt{MessageID: messageID, UserID: userID}
+			}
+			receipts = append(receipts, r)
+		}
+	}
+
+	for _, r := range receipts {
+		switch r.ResolveStatus() {
+		case model.MessageStatusRead:
+			report.Read++
+			report.Delivered++
+		case model.MessageStatusDelivered:
+			report.Delivered++
+		}
+	}
+	report.Receipts = receipts
+	return report
+}
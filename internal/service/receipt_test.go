@@ -0,0 +1,67 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func TestBuildReceiptReport(t *testing.T) {
+	receipts := []*model.MessageReceipt{
+		{MessageID: "m1", UserID: "u2", DeliveredAt: 10, ReadAt: 20},
+		{MessageID: "m1", UserID: "u3", DeliveredAt: 15},
+	}
+
+	report := buildReceiptReport("m1", []string{"u2", "u3", "u4"}, receipts, 3)
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 2, report.Delivered)
+	assert.Equal(t, 1, report.Read)
+	assert.Len(t, report.Receipts, 3)
+	assert.Equal(t, model.MessageStatusRead, report.Receipts[0].Status)
+	assert.Equal(t, model.MessageStatusDelivered, report.Receipts[1].Status)
+	assert.Equal(t, "u4", report.Receipts[2].UserID)
+	assert.Equal(t, model.MessageStatusSent, report.Receipts[2].Status)
+
+	// 超大群只列出已确认的成员
+	report = buildReceiptReport("m1", nil, receipts, 1000)
+	assert.Equal(t, 1000, report.Total)
+	assert.Len(t, report.Receipts, 2)
+	assert.Equal(t, 2, report.Delivered)
+}
+
+func TestGroupReceiptMode(t *testing.T) {
+	s := &MessageService{groupCfg: config.GroupConfig{Receipts: model.ReceiptsFull, ChannelReceipts: model.ReceiptsNone}}
+	group := &model.Group{ID: "g1", Mode: model.GroupModeNormal}
+	channel := &model.Group{ID: "c1", Mode: model.GroupModeChannel}
+
+	// 未设置时按服务端配置
+	assert.Equal(t, model.ReceiptsFull, s.groupReceiptMode(group))
+	assert.Equal(t, model.ReceiptsNone, s.groupReceiptMode(channel))
+
+	group.Settings.Receipts = model.ReceiptsAggregate
+	assert.Equal(t, model.ReceiptsAggregate, s.groupReceiptMode(group))
+
+	// 超大群不按成员记录
+	channel.Settings.Receipts = model.ReceiptsFull
+	assert.Equal(t, model.ReceiptsAggregate, s.groupReceiptMode(channel))
+
+	empty := &MessageService{}
+	assert.Equal(t, model.ReceiptsFull, empty.groupReceiptMode(&model.Group{ID: "g2"}))
+	assert.Equal(t, model.ReceiptsAggregate, empty.groupReceiptMode(&model.Group{ID: "c2", Mode: model.GroupModeChannel}))
+}
+
+func TestClampReceiptCounts(t *testing.T) {
+	delivered, read := clampReceiptCounts(1003, 1001, 1000)
+	assert.Equal(t, 1000, delivered)
+	assert.Equal(t, 1000, read)
+
+	delivered, read = clampReceiptCounts(5, 7, 1000)
+	assert.Equal(t, 5, delivered)
+	assert.Equal(t, 5, read)
+
+	delivered, read = clampReceiptCounts(1, 1, -1)
+	assert.Equal(t, 0, delivered)
+	assert.Equal(t, 0, read)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// DeliveryRecovery 投递恢复
+// 节点在保存消息后、推送或写入离线消息主题前崩溃时，消息停留在已发送状态且不会再被投递。
+// 扫描超过阈值仍为已发送状态的私聊消息，重新写入离线消息主题，由离线消息消费者推送给在线的接收者；
+// 离线的接收者登录后从消息存储同步。扫描时在Redis中占用消息ID，同一条消息在max_age内只重新投递一次，
+// 离线消息消费者按消息ID去重，已经处理过的消息不会重复推送
+type DeliveryRecovery struct {
+	messages   *MessageService
+	mysqlStore *store.MySQLStore
+	redisStore *store.RedisStore
+	cfg        config.RecoveryConfig
+	dedup      *store.Deduplicator
+}
+
+// NewDeliveryRecovery 创建投递恢复
+func NewDeliveryRecovery(messages *MessageService, mysqlStore *store.MySQLStore, redisStore *store.RedisStore, cfg config.RecoveryConfig) *DeliveryRecovery {
+	return &DeliveryRecovery{
+		messages:   messages,
+		mysqlStore: mysqlStore,
+		redisStore: redisStore,
+		cfg:        cfg,
+		dedup:      store.NewDeduplicator(redisStore, "recovery", cfg.MaxAge),
+	}
+}
+
+// Run 扫描一轮并重新投递，节点启动时执行一次，之后作为主节点的后台任务定期执行
+func (r *DeliveryRecovery) Run(ctx context.Context, fence int64) error {
+	from, to := r.window(time.Now())
+
+	recovered := 0
+	cursor := ""
+	for ctx.Err() == nil {
+		messages, err := r.mysqlStore.GetStaleSentMessages(from, to, cursor, r.cfg.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to get stale sent messages: %w", err)
+		}
+		for _, message := range messages {
+			if r.recover(message) {
+				recovered++
+			}
+		}
+		if len(messages) < r.cfg.BatchSize {
+			break
+		}
+		cursor = messages[len(messages)-1].ID
+	}
+
+	if recovered > 0 {
+		logger.Info("Stale sent messages re-enqueued", logger.Int("messages", recovered))
+	}
+	return nil
+}
+
+// window 扫描的时间戳范围：已发送超过threshold且未超过max_age的消息
+func (r *DeliveryRecovery) window(now time.Time) (int64, int64) {
+	return now.Add(-r.cfg.MaxAge).Unix(), now.Add(-r.cfg.Threshold).Unix()
+}
+
+// recover 重新投递一条消息，返回是否已写入离线消息主题
+func (r *DeliveryRecovery) recover(message *model.Message) bool {
+	// 非联系人的消息在消息请求中等待接收者接受或已被拒绝，不能直接投递
+	if message.SenderID != message.ReceiverID {
+		contacts, err := r.redisStore.GetContacts(message.ReceiverID, []string{message.SenderID})
+		if err != nil {
+			logger.Warn("Failed to get contacts", logger.String("message_id", message.ID), logger.ErrorField(err))
+			return false
+		}
+		if !contacts[message.SenderID] {
+			return false
+		}
+	}
+
+	if !r.dedup.Claim(message.ID) {
+		return false
+	}
+	if err := r.messages.sendOfflineMessage(message); err != nil {
+		r.dedup.Release(message.ID)
+		logger.Warn("Failed to re-enqueue stale message", logger.String("message_id", message.ID), logger.ErrorField(err))
+		return false
+	}
+	metrics.RecoveredMessages.Inc()
+	return true
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+)
+
+func TestDeliveryRecovery_Window(t *testing.T) {
+	r := &DeliveryRecovery{cfg: config.RecoveryConfig{Threshold: time.Minute, MaxAge: time.Hour}}
+	now := time.Unix(1700000000, 0)
+
+	// 刚发送的消息可能仍在正常投递中，太旧的消息不再重新投递
+	from, to := r.window(now)
+	assert.Equal(t, int64(1700000000-3600), from)
+	assert.Equal(t, int64(1700000000-60), to)
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxReportTextLength 举报说明和处理说明的最大长度（字符）
+	maxReportTextLength = 1000
+	// defaultReportLimit 举报列表默认条数
+	defaultReportLimit = 50
+	// maxReportLimit 举报列表最多条数
+	maxReportLimit = 200
+)
+
+// ReportService 用户举报服务，举报保存在MySQL中并发布到事件主题通知审核人员，管理员处理时可删除消息、禁言或封禁
+type ReportService struct {
+	mysqlStore *store.MySQLStore
+	messages   *MessageService
+	moderation *ModerationService
+	events     *EventPublisher
+}
+
+// NewReportService 创建举报服务，需要MySQL
+func NewReportService(mysqlStore *store.MySQLStore, messages *MessageService, moderation *ModerationService, events *EventPublisher) *ReportService {
+	return &ReportService{
+		mysqlStore: mysqlStore,
+		messages:   messages,
+		moderation: moderation,
+		events:     events,
+	}
+}
+
+// ReportRequest 举报请求，举报消息时填写MessageID，举报用户时填写UserID
+type ReportRequest struct {
+	TargetType model.ReportTarget
+	MessageID  string
+	UserID     string
+	Reason     model.ReportReason
+	Comment    string
+}
+
+// Create 提交举报，举报消息时保存举报者可见的消息副本
+func (r *ReportService) Create(reporterID string, req ReportRequest) (*model.Report, error) {
+	if !model.ValidReportReasons[req.Reason] {
+		return nil, newServiceError(ErrCodeInvalidRequest, "invalid report reason: %s", req.Reason)
+	}
+	if utf8.RuneCountInString(req.Comment) > maxReportTextLength {
+		return nil, newServiceError(ErrCodeInvalidRequest, "comment exceeds %d characters", maxReportTextLength)
+	}
+
+	report := &model.Report{
+		ReporterID: reporterID,
+		TargetType: req.TargetType,
+		Reason:     req.Reason,
+		Comment:    req.Comment,
+		Status:     model.ReportStatusOpen,
+		CreatedAt:  time.Now(),
+	}
+	switch req.TargetType {
+	case model.ReportTargetMessage:
+		snapshot, err := r.snapshot(reporterID, req.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		report.MessageID = snapshot.ID
+		report.TargetUserID = snapshot.SenderID
+		report.Snapshot = snapshot
+	case model.ReportTargetUser:
+		if req.UserID == "" {
+			return nil, newServiceError(ErrCodeInvalidRequest, "user_id is required")
+		}
+		report.TargetUserID = req.UserID
+	default:
+		return nil, newServiceError(ErrCodeInvalidRequest, "target_type must be message or user")
+	}
+	if report.TargetUserID == reporterID {
+		return nil, newServiceError(ErrCodeInvalidRequest, "cannot report yourself")
+	}
+
+	id, err := idgen.GenerateIDString()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate report ID: %w", err)
+	}
+	report.ID = id
+	if err := r.mysqlStore.SaveReport(report); err != nil {
+		return nil, fmt.Errorf("failed to save report: %w", err)
+	}
+	r.events.ReportCreated(report)
+	return report, nil
+}
+
+// snapshot 举报者可见的消息副本，举报者无权访问或消息已删除时视为不存在
+func (r *ReportService) snapshot(reporterID, messageID string) (*model.Message, error) {
+	if messageID == "" {
+		return nil, newServiceError(ErrCodeInvalidRequest, "message_id is required")
+	}
+	message, err := r.messages.GetMessage(reporterID, messageID)
+	if err != nil {
+		return nil, newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+	ok, err := r.messages.canAccessMessage(reporterID, message)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || message.IsDeleted() {
+		return nil, newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+	snapshot := *message
+	return &snapshot, nil
+}
+
+// Get 获取举报
+func (r *ReportService) Get(id string) (*model.Report, error) {
+	report, err := r.mysqlStore.GetReport(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, newServiceError(ErrCodeNotFound, "report %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report: %w", err)
+	}
+	return report, nil
+}
+
+// List 按提交时间倒序查询举报
+func (r *ReportService) List(filter store.ReportFilter) ([]*model.Report, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultReportLimit
+	}
+	if filter.Limit > maxReportLimit {
+		filter.Limit = maxReportLimit
+	}
+	reports, err := r.mysqlStore.ListReports(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+	return reports, nil
+}
+
+// Resolve 处理待处理的举报：驳回、物理删除被举报的消息，或对被举报的用户禁言、封禁，duration为0表示永久
+func (r *ReportService) Resolve(id, actor string, action model.ReportAction, duration time.Duration, note string) (*model.Report, error) {
+	if utf8.RuneCountInString(note) > maxReportTextLength {
+		return nil, newServiceError(ErrCodeInvalidRequest, "note exceeds %d characters", maxReportTextLength)
+	}
+	report, err := r.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if report.Status != model.ReportStatusOpen {
+		return nil, newServiceError(ErrCodeConflict, "report %s is already %s", id, report.Status)
+	}
+
+	status := model.ReportStatusResolved
+	switch action {
+	case model.ReportActionDismiss:
+		status = model.ReportStatusDismissed
+	case model.ReportActionDeleteMessage:
+		if report.MessageID == "" {
+			return nil, newServiceError(ErrCodeInvalidRequest, "report %s is not about a message", id)
+		}
+		if err := r.messages.PurgeMessage(report.MessageID); err != nil {
+			return nil, err
+		}
+	case model.ReportActionMute, model.ReportActionBan:
+		sanctionType := model.SanctionMute
+		if action == model.ReportActionBan {
+			sanctionType = model.SanctionBan
+		}
+		reason := fmt.Sprintf("report %s: %s", report.ID, report.Reason)
+		if _, err := r.moderation.Sanction(report.TargetUserID, sanctionType, duration, reason); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, newServiceError(ErrCodeInvalidRequest, "action must be dismiss, delete_message, mute or ban")
+	}
+
+	report.Status = status
+	report.Action = action
+	report.ResolvedBy = actor
+	report.ResolvedAt = time.Now().Unix()
+	report.Note = note
+	updated, err := r.mysqlStore.ResolveReport(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve report: %w", err)
+	}
+	if !updated {
+		// 其他管理员同时处理了该举报，本次的措施已经执行
+		return nil, newServiceError(ErrCodeConflict, "report %s was resolved concurrently", id)
+	}
+	return report, nil
+}
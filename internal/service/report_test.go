@@ -0,0 +1,36 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestReportService_CreateValidation(t *testing.T) {
+	r := NewReportService(nil, nil, nil, nil)
+
+	_, err := r.Create("alice", ReportRequest{TargetType: model.ReportTargetUser, UserID: "bob", Reason: "boring"})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	_, err = r.Create("alice", ReportRequest{TargetType: "group", Reason: model.ReportReasonSpam})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	_, err = r.Create("alice", ReportRequest{TargetType: model.ReportTargetUser, Reason: model.ReportReasonSpam})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	_, err = r.Create("alice", ReportRequest{TargetType: model.ReportTargetUser, UserID: "alice", Reason: model.ReportReasonSpam})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	_, err = r.Create("alice", ReportRequest{
+		TargetType: model.ReportTargetUser,
+		UserID:     "bob",
+		Reason:     model.ReportReasonOther,
+		Comment:    strings.Repeat("举", maxReportTextLength+1),
+	})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	_, err = r.Create("alice", ReportRequest{TargetType: model.ReportTargetMessage, Reason: model.ReportReasonHate})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
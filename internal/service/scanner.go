@@ -0,0 +1,163 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/user/im/internal/config"
+)
+
+// clamdChunkSize 向clamd发送INSTREAM数据的分块大小
+const clamdChunkSize = 64 << 10
+
+// Scanner 上传文件扫描，部署方据此接入杀毒引擎或外部内容扫描服务
+type Scanner interface {
+	// Name 扫描引擎名称，记录在扫描结果中
+	Name() string
+	// Scan 扫描文件内容，返回发现的威胁名称，文件安全时返回空字符串
+	Scan(ctx context.Context, filename string, r io.Reader) (string, error)
+}
+
+// NewScanner 按配置创建扫描器，未配置扫描时返回nil
+func NewScanner(cfg config.ScanConfig) (Scanner, error) {
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case "clamav":
+		if cfg.Address == "" {
+			return nil, fmt.Errorf("clamav scanner address is required")
+		}
+		return &ClamAVScanner{address: cfg.Address}, nil
+	case "http":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("http scanner url is required")
+		}
+		return &HTTPScanner{url: cfg.URL, client: &http.Client{}}, nil
+	default:
+		return nil, fmt.Errorf("unknown scanner type: %s", cfg.Type)
+	}
+}
+
+// ClamAVScanner 通过clamd的INSTREAM命令扫描，文件大小受clamd的StreamMaxLength限制
+type ClamAVScanner struct {
+	address string
+}
+
+// Name 扫描引擎名称
+func (s *ClamAVScanner) Name() string {
+	return "clamav"
+}
+
+// Scan 把文件内容分块发送给clamd
+func (s *ClamAVScanner) Scan(ctx context.Context, filename string, r io.Reader) (string, error) {
+	network := "tcp"
+	if strings.HasPrefix(s.address, "/") {
+		network = "unix"
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, s.address)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", fmt.Errorf("failed to send clamd command: %w", err)
+	}
+	buf := make([]byte, clamdChunkSize)
+	header := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(header, uint32(n))
+			if _, err := conn.Write(append(header, buf[:n]...)); err != nil {
+				// clamd超过大小限制时关闭连接，回复中带有原因
+				return "", fmt.Errorf("failed to stream file to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return "", fmt.Errorf("failed to read file: %w", readErr)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return "", fmt.Errorf("failed to finish clamd stream: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return "", fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return parseClamdReply(reply)
+}
+
+// parseClamdReply 解析clamd的扫描结果，如 "stream: OK"、"stream: Eicar-Signature FOUND"
+func parseClamdReply(reply string) (string, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd error: %s", reply)
+	}
+}
+
+// HTTPScanner 把文件内容POST给外部扫描服务，服务返回 {"clean": bool, "threat": "..."}
+type HTTPScanner struct {
+	url    string
+	client *http.Client
+}
+
+// Name 扫描引擎名称
+func (s *HTTPScanner) Name() string {
+	return "http"
+}
+
+// Scan 上传文件内容到扫描服务
+func (s *HTTPScanner) Scan(ctx context.Context, filename string, r io.Reader) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, r)
+	if err != nil {
+		return "", fmt.Errorf("failed to create scan request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Filename", filename)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call scanner: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("scanner returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Clean  bool   `json:"clean"`
+		Threat string `json:"threat"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode scanner response: %w", err)
+	}
+	if result.Clean {
+		return "", nil
+	}
+	if result.Threat == "" {
+		return "unknown", nil
+	}
+	return result.Threat, nil
+}
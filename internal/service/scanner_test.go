@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+)
+
+func TestParseClamdReply(t *testing.T) {
+	threat, err := parseClamdReply("stream: OK\x00")
+	assert.NoError(t, err)
+	assert.Empty(t, threat)
+
+	threat, err = parseClamdReply("stream: Eicar-Signature FOUND\x00")
+	assert.NoError(t, err)
+	assert.Equal(t, "Eicar-Signature", threat)
+
+	_, err = parseClamdReply("INSTREAM size limit exceeded. ERROR\x00")
+	assert.Error(t, err)
+}
+
+func TestNewScanner(t *testing.T) {
+	scanner, err := NewScanner(config.ScanConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, scanner)
+
+	scanner, err = NewScanner(config.ScanConfig{Type: "clamav", Address: "127.0.0.1:3310"})
+	assert.NoError(t, err)
+	assert.Equal(t, "clamav", scanner.Name())
+
+	_, err = NewScanner(config.ScanConfig{Type: "clamav"})
+	assert.Error(t, err)
+	_, err = NewScanner(config.ScanConfig{Type: "http"})
+	assert.Error(t, err)
+	_, err = NewScanner(config.ScanConfig{Type: "sophos"})
+	assert.Error(t, err)
+}
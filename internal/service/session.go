@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+	"github.com/user/im/pkg/websocket"
+)
+
+// sessionRecordTTL 用户会话记录的保留时长，每次登录刷新
+const sessionRecordTTL = 24 * time.Hour
+
+// SessionService 已登录会话的记录，登录时把握手信息写入Redis并记录审计，供安全审查按用户列出
+type SessionService struct {
+	nodeID     string
+	redisStore *store.RedisStore
+	audit      *AuditService
+}
+
+// NewSessionService 创建会话记录服务
+func NewSessionService(nodeID string, redisStore *store.RedisStore, audit *AuditService) *SessionService {
+	return &SessionService{nodeID: nodeID, redisStore: redisStore, audit: audit}
+}
+
+// Bind 用户登录后保存会话记录并记录审计，拨测会话不记录
+func (s *SessionService) Bind(userID string, session websocket.Session) {
+	if session.Transport() == canaryTransport {
+		return
+	}
+	caps := session.Capabilities()
+	record := &model.SessionRecord{
+		SessionID:  session.ID(),
+		UserID:     userID,
+		NodeID:     s.nodeID,
+		Transport:  session.Transport(),
+		Platform:   caps.Platform,
+		AppVersion: caps.AppVersion,
+		Handshake:  session.Handshake(),
+		LoginAt:    time.Now().Unix(),
+	}
+	if err := s.redisStore.SaveSession(record, sessionRecordTTL); err != nil {
+		logger.Warn("Failed to save session record", logger.String("user_id", userID), logger.ErrorField(err))
+	}
+	if err := s.audit.Record(userID, model.AuditActionSessionLogin, record.SessionID, sessionAuditDetails(record)); err != nil {
+		logger.Error("Failed to record audit log", logger.String("action", model.AuditActionSessionLogin), logger.ErrorField(err))
+	}
+}
+
+// Unbind 会话下线后删除会话记录
+func (s *SessionService) Unbind(userID string, session websocket.Session) {
+	if session.Transport() == canaryTransport {
+		return
+	}
+	if err := s.redisStore.RemoveSession(userID, session.ID()); err != nil {
+		logger.Warn("Failed to remove session record", logger.String("user_id", userID), logger.ErrorField(err))
+	}
+}
+
+// List 列出用户的会话，按登录时间倒序
+func (s *SessionService) List(userID string) ([]*model.SessionRecord, error) {
+	records, err := s.redisStore.ListSessions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].LoginAt > records[j].LoginAt })
+	return records, nil
+}
+
+// sessionAuditDetails 登录审计记录的详情，只包含非空的字段
+func sessionAuditDetails(record *model.SessionRecord) map[string]string {
+	details := map[string]string{
+		"node_id":     record.NodeID,
+		"transport":   record.Transport,
+		"remote_ip":   record.RemoteIP,
+		"user_agent":  record.UserAgent,
+		"platform":    record.Platform,
+		"app_version": record.AppVersion,
+	}
+	if record.Geo != nil {
+		details["country"] = record.Geo.Country
+		details["region"] = record.Geo.Region
+		details["city"] = record.Geo.City
+	}
+	for key, value := range details {
+		if value == "" {
+			delete(details, key)
+		}
+	}
+	return details
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestSessionAuditDetails(t *testing.T) {
+	details := sessionAuditDetails(&model.SessionRecord{
+		SessionID: "conn-1",
+		NodeID:    "node-1",
+		Transport: "websocket",
+		Platform:  "ios",
+		Handshake: model.Handshake{
+			RemoteIP:  "198.51.100.7",
+			UserAgent: "im-ios/3.2",
+			Geo:       &model.GeoLocation{Country: "NL"},
+		},
+	})
+	assert.Equal(t, map[string]string{
+		"node_id":    "node-1",
+		"transport":  "websocket",
+		"remote_ip":  "198.51.100.7",
+		"user_agent": "im-ios/3.2",
+		"platform":   "ios",
+		"country":    "NL",
+	}, details)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+// maxSettingsRetries 未指定预期版本时并发更新冲突的最多重试次数
+const maxSettingsRetries = 3
+
+// settingKeyPattern 设置项的键，如 theme、notification.sound
+var settingKeyPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// SettingsService 用户偏好设置同步，设置保存在Redis中，更新后推送给用户的所有在线设备
+type SettingsService struct {
+	redisStore *store.RedisStore
+	deliverer  Deliverer
+	cfg        config.SettingsConfig
+}
+
+// NewSettingsService 创建偏好设置服务
+func NewSettingsService(redisStore *store.RedisStore, deliverer Deliverer, cfg config.SettingsConfig) *SettingsService {
+	return &SettingsService{
+		redisStore: redisStore,
+		deliverer:  deliverer,
+		cfg:        cfg,
+	}
+}
+
+// GetSettings 获取用户的偏好设置
+func (s *SettingsService) GetSettings(userID string) (*model.UserSettings, error) {
+	settings, err := s.redisStore.GetUserSettings(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user settings: %w", err)
+	}
+	return settings, nil
+}
+
+// UpdateSettings 合并更新偏好设置，值为null的键被删除
+// expected不为nil时只在当前版本等于expected时更新，否则返回冲突；为nil时基于最新版本合并
+func (s *SettingsService) UpdateSettings(userID string, patch map[string]json.RawMessage, expected *int64) (*model.UserSettings, error) {
+	for key := range patch {
+		if !settingKeyPattern.MatchString(key) {
+			return nil, newServiceError(ErrCodeInvalidRequest, "invalid setting key %q", key)
+		}
+	}
+
+	for attempt := 0; attempt < maxSettingsRetries; attempt++ {
+		current, err := s.redisStore.GetUserSettings(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user settings: %w", err)
+		}
+		if expected != nil && current.Version != *expected {
+			return nil, settingsConflict(*expected)
+		}
+
+		settings, err := s.merge(current, patch)
+		if err != nil {
+			return nil, err
+		}
+		version, ok, err := s.redisStore.SaveUserSettings(userID, settings, current.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save user settings: %w", err)
+		}
+		if !ok {
+			if expected != nil {
+				return nil, settingsConflict(*expected)
+			}
+			continue
+		}
+		settings.Version = version
+
+		// 推送给用户所有在线设备，客户端忽略不高于本地版本的更新
+		s.deliverer.SendToUser(userID, model.WebSocketMessage{
+			Type:      model.FrameSettingsUpdated,
+			Data:      settings,
+			Timestamp: time.Now().Unix(),
+		})
+		return settings, nil
+	}
+	return nil, newServiceError(ErrCodeConflict, "settings are being updated concurrently, retry later")
+}
+
+// merge 将patch合并到当前设置并检查键数和大小限制
+func (s *SettingsService) merge(current *model.UserSettings, patch map[string]json.RawMessage) (*model.UserSettings, error) {
+	values := make(map[string]json.RawMessage, len(current.Values)+len(patch))
+	for key, value := range current.Values {
+		values[key] = value
+	}
+	for key, value := range patch {
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			delete(values, key)
+			continue
+		}
+		values[key] = value
+	}
+	if len(values) > s.cfg.MaxKeys {
+		return nil, newServiceError(ErrCodeInvalidRequest, "settings exceed %d keys", s.cfg.MaxKeys)
+	}
+
+	settings := &model.UserSettings{Values: values, UpdatedAt: time.Now().Unix()}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "invalid setting value: %s", err.Error())
+	}
+	if len(data) > s.cfg.MaxSize {
+		return nil, newServiceError(ErrCodeInvalidRequest, "settings exceed %d bytes", s.cfg.MaxSize)
+	}
+	return settings, nil
+}
+
+// settingsConflict 预期版本已被其他设备的更新覆盖
+func settingsConflict(expected int64) error {
+	return newServiceError(ErrCodeConflict, "settings version %d is outdated", expected)
+}
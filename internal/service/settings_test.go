@@ -0,0 +1,40 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func TestSettingsService_Merge(t *testing.T) {
+	s := NewSettingsService(nil, nil, config.SettingsConfig{MaxKeys: 2, MaxSize: 64})
+	current := &model.UserSettings{
+		Values:  map[string]json.RawMessage{"theme": json.RawMessage(`"dark"`)},
+		Version: 3,
+	}
+
+	settings, err := s.merge(current, map[string]json.RawMessage{
+		"theme":              json.RawMessage(` null `),
+		"notification.sound": json.RawMessage(`false`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]json.RawMessage{"notification.sound": json.RawMessage(`false`)}, settings.Values)
+	// 合并不修改当前设置
+	assert.Contains(t, current.Values, "theme")
+
+	_, err = s.merge(current, map[string]json.RawMessage{"a": json.RawMessage(`1`), "b": json.RawMessage(`2`)})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+
+	_, err = s.merge(current, map[string]json.RawMessage{"bio": json.RawMessage(`"` + strings.Repeat("x", 64) + `"`)})
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(err))
+}
+
+func TestSettingKeyPattern(t *testing.T) {
+	assert.True(t, settingKeyPattern.MatchString("privacy.read_receipts"))
+	assert.False(t, settingKeyPattern.MatchString(""))
+	assert.False(t, settingKeyPattern.MatchString("theme color"))
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// 垃圾消息检测规则
+const (
+	SpamRuleDuplicate = "duplicate"
+	SpamRuleMassDM    = "mass_dm"
+	SpamRuleURL       = "url"
+)
+
+// maxSpamSample 治理事件中携带的消息内容最大长度
+const maxSpamSample = 200
+
+// SpamCounters 垃圾消息检测的窗口计数和发送限制，由RedisStore实现
+type SpamCounters interface {
+	IncrSpamCounter(userID, rule string, window time.Duration) (int64, error)
+	AddSpamTarget(userID, rule, target string, window time.Duration) (int64, error)
+	SetSpamThrottle(userID string, ttl time.Duration) error
+	GetSpamThrottle(userID string) (time.Duration, bool, error)
+}
+
+// ModerationPublisher 发布治理事件，由KafkaStore实现
+type ModerationPublisher interface {
+	Publish(topic, key string, value interface{}) error
+}
+
+// SpamDetector 垃圾消息检测
+// 计数保存在Redis中，多节点共享同一用户的统计窗口。命中任一规则后用户在限制期内不能发送消息，
+// 同时发布治理事件
+type SpamDetector struct {
+	redisStore SpamCounters
+	kafkaStore ModerationPublisher
+	cfg        config.SpamConfig
+	topic      string
+}
+
+// NewSpamDetector 创建垃圾消息检测器，topic为空时不发布治理事件
+func NewSpamDetector(redisStore *store.RedisStore, kafkaStore *store.KafkaStore, cfg config.SpamConfig, topic string) *SpamDetector {
+	return &SpamDetector{
+		redisStore: redisStore,
+		kafkaStore: kafkaStore,
+		cfg:        cfg,
+		topic:      topic,
+	}
+}
+
+// Check 检查用户发送的消息，receiverID仅私聊时非空
+// Redis不可用时放行，避免检测故障影响正常发送
+func (d *SpamDetector) Check(userID, receiverID, content string) error {
+	if remaining, throttled, err := d.redisStore.GetSpamThrottle(userID); err == nil && throttled {
+		metrics.SpamThrottledMessages.Inc()
+		return throttleError(remaining)
+	}
+
+	rule, count := d.evaluate(userID, receiverID, content)
+	if rule == "" {
+		return nil
+	}
+
+	metrics.SpamDetections.WithLabelValues(rule).Inc()
+	if err := d.redisStore.SetSpamThrottle(userID, d.cfg.Throttle); err != nil {
+		logger.Error("Failed to throttle spam sender", logger.String("user_id", userID), logger.ErrorField(err))
+	}
+	d.emit(rule, userID, count, content)
+	return throttleError(d.cfg.Throttle)
+}
+
+// evaluate 依次累加各规则的计数，返回首个超过阈值的规则
+func (d *SpamDetector) evaluate(userID, receiverID, content string) (string, int64) {
+	fingerprint := contentFingerprint(content)
+
+	if d.cfg.DuplicateThreshold > 0 {
+		count, err := d.redisStore.IncrSpamCounter(userID, "dup:"+fingerprint, d.cfg.Window)
+		if err == nil && count > int64(d.cfg.DuplicateThreshold) {
+			return SpamRuleDuplicate, count
+		}
+	}
+
+	if d.cfg.MassDMThreshold > 0 && receiverID != "" {
+		count, err := d.redisStore.AddSpamTarget(userID, "dm:"+fingerprint, receiverID, d.cfg.Window)
+		if err == nil && count > int64(d.cfg.MassDMThreshold) {
+			return SpamRuleMassDM, count
+		}
+	}
+
+	if d.cfg.URLThreshold > 0 && linkPattern.MatchString(content) {
+		count, err := d.redisStore.IncrSpamCounter(userID, "url", d.cfg.Window)
+		if err == nil && count > int64(d.cfg.URLThreshold) {
+			return SpamRuleURL, count
+		}
+	}
+
+	return "", 0
+}
+
+// emit 发布治理事件
+func (d *SpamDetector) emit(rule, userID string, count int64, content string) {
+	logger.Warn("Spam detected",
+		logger.String("rule", rule),
+		logger.String("user_id", userID),
+		logger.Int64("count", count))
+
+	if d.topic == "" {
+		return
+	}
+
+	sample := []rune(content)
+	if len(sample) > maxSpamSample {
+		sample = sample[:maxSpamSample]
+	}
+
+	now := time.Now()
+	event := &model.ModerationEvent{
+		Type:      "spam",
+		Rule:      rule,
+		UserID:    userID,
+		Count:     count,
+		Until:     now.Add(d.cfg.Throttle).Unix(),
+		Sample:    string(sample),
+		Timestamp: now.Unix(),
+	}
+	if err := d.kafkaStore.Publish(d.topic, userID, event); err != nil {
+		logger.Error("Failed to publish moderation event", logger.ErrorField(err))
+	}
+}
+
+// contentFingerprint 计算归一化后内容的指纹，忽略大小写和空白差异
+func contentFingerprint(content string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(content)), " ")
+	h := fnv.New64a()
+	h.Write([]byte(normalized))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// throttleError 构造发送限制错误
+func throttleError(remaining time.Duration) error {
+	retryAfter := int64(math.Ceil(remaining.Seconds()))
+	err := newServiceError(ErrCodeSpamThrottled, "sending too fast, retry in %d seconds", retryAfter)
+	err.RetryAfter = retryAfter
+	return err
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+// memorySpamCounters 内存中的窗口计数和发送限制，窗口从首次写入开始计时，now控制过期
+type memorySpamCounters struct {
+	now       time.Time
+	windows   map[string]*spamWindow
+	throttles map[string]time.Time
+}
+
+type spamWindow struct {
+	count     int64
+	targets   map[string]bool
+	expiresAt time.Time
+}
+
+func newMemorySpamCounters() *memorySpamCounters {
+	return &memorySpamCounters{
+		now:       time.Unix(1704067200, 0),
+		windows:   make(map[string]*spamWindow),
+		throttles: make(map[string]time.Time),
+	}
+}
+
+func (m *memorySpamCounters) window(key string, window time.Duration) *spamWindow {
+	w, ok := m.windows[key]
+	if !ok || !m.now.Before(w.expiresAt) {
+		w = &spamWindow{targets: make(map[string]bool), expiresAt: m.now.Add(window)}
+		m.windows[key] = w
+	}
+	return w
+}
+
+func (m *memorySpamCounters) IncrSpamCounter(userID, rule string, window time.Duration) (int64, error) {
+	w := m.window(rule+":"+userID, window)
+	w.count++
+	return w.count, nil
+}
+
+func (m *memorySpamCounters) AddSpamTarget(userID, rule, target string, window time.Duration) (int64, error) {
+	w := m.window(rule+":"+userID, window)
+	w.targets[target] = true
+	return int64(len(w.targets)), nil
+}
+
+func (m *memorySpamCounters) SetSpamThrottle(userID string, ttl time.Duration) error {
+	m.throttles[userID] = m.now.Add(ttl)
+	return nil
+}
+
+func (m *memorySpamCounters) GetSpamThrottle(userID string) (time.Duration, bool, error) {
+	remaining := m.throttles[userID].Sub(m.now)
+	return remaining, remaining > 0, nil
+}
+
+// moderationEvents 记录发布的治理事件
+type moderationEvents struct {
+	events []*model.ModerationEvent
+}
+
+func (p *moderationEvents) Publish(topic, key string, value interface{}) error {
+	p.events = append(p.events, value.(*model.ModerationEvent))
+	return nil
+}
+
+// newSpamService 只保留垃圾消息检测依赖的消息服务，处罚和隐私设置读取Redis，测试中移除
+func newSpamService(cfg config.SpamConfig) (*MessageService, *memorySpamCounters, *moderationEvents) {
+	counters, published := newMemorySpamCounters(), &moderationEvents{}
+	s := NewMessageServiceWithBackend(nil, nil, nil, nil)
+	s.Intercept(StagePrePersist, InterceptorSanction, nil)
+	s.Intercept(StagePrePersist, InterceptorPrivacy, nil)
+	s.SetSpamDetector(&SpamDetector{redisStore: counters, kafkaStore: published, cfg: cfg, topic: "moderation"})
+	return s, counters, published
+}
+
+// spamSend 发送一条文本消息，receiver为空时为群消息
+func spamSend(s *MessageService, receiver, content string) error {
+	req := &SendRequest{SenderID: "spammer", ReceiverID: receiver, Type: model.MessageTypeText, Content: content}
+	if receiver == "" {
+		req.GroupID = "g1"
+	}
+	return s.pipeline.run(StagePrePersist, req)
+}
+
+func TestContentFingerprint(t *testing.T) {
+	assert.Equal(t, contentFingerprint("Buy  NOW\n"), contentFingerprint("buy now"))
+	assert.NotEqual(t, contentFingerprint("buy now"), contentFingerprint("buy later"))
+}
+
+func TestSpamInterceptor_Rules(t *testing.T) {
+	type send struct{ receiver, content string }
+	cases := []struct {
+		name     string
+		cfg      config.SpamConfig
+		sends    []send
+		rejected int // 第一条被拒绝的消息序号，-1为全部放行
+		rule     string
+		count    int64
+	}{
+		{
+			name:     "duplicate content ignores case and whitespace",
+			cfg:      config.SpamConfig{DuplicateThreshold: 2},
+			sends:    []send{{"u2", "Buy NOW"}, {"", "buy  now"}, {"u3", "hello"}, {"u4", "buy now\n"}},
+			rejected: 3, rule: SpamRuleDuplicate, count: 3,
+		},
+		{
+			name:     "mass dm counts distinct receivers of the same content",
+			cfg:      config.SpamConfig{MassDMThreshold: 2},
+			sends:    []send{{"u2", "join my channel"}, {"u3", "Join my channel"}, {"u4", "join my channel"}},
+			rejected: 2, rule: SpamRuleMassDM, count: 3,
+		},
+		{
+			name:     "mass dm ignores repeats to one receiver",
+			cfg:      config.SpamConfig{MassDMThreshold: 2},
+			sends:    []send{{"u2", "hi"}, {"u2", "hi"}, {"u2", "hi"}, {"u2", "hi"}},
+			rejected: -1,
+		},
+		{
+			name:     "mass dm ignores group messages",
+			cfg:      config.SpamConfig{MassDMThreshold: 1},
+			sends:    []send{{"", "hi all"}, {"", "hi all"}, {"", "hi all"}},
+			rejected: -1,
+		},
+		{
+			name:     "url density counts only messages with links",
+			cfg:      config.SpamConfig{URLThreshold: 2},
+			sends:    []send{{"u2", "see https://a.example"}, {"u2", "plain text"}, {"", "www.b.example"}, {"u3", "no link"}, {"u2", "go http://c.example/x"}},
+			rejected: 4, rule: SpamRuleURL, count: 3,
+		},
+		{
+			name:     "duplicate is checked before url",
+			cfg:      config.SpamConfig{DuplicateThreshold: 1, URLThreshold: 1},
+			sends:    []send{{"u2", "https://a.example"}, {"u3", "https://a.example"}},
+			rejected: 1, rule: SpamRuleDuplicate, count: 2,
+		},
+		{
+			name:     "below every threshold",
+			cfg:      config.SpamConfig{DuplicateThreshold: 3, MassDMThreshold: 3, URLThreshold: 3},
+			sends:    []send{{"u2", "one"}, {"u3", "two https://a.example"}, {"u4", "three"}, {"u2", "one"}},
+			rejected: -1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.Window, tc.cfg.Throttle = time.Minute, 5*time.Minute
+			s, counters, published := newSpamService(tc.cfg)
+
+			rejected := -1
+			for i, m := range tc.sends {
+				if err := spamSend(s, m.receiver, m.content); err != nil {
+					rejected = i
+					assert.Equal(t, ErrCodeSpamThrottled, errorCode(err))
+					assert.Equal(t, int64(300), err.(*ServiceError).RetryAfter)
+					break
+				}
+			}
+			assert.Equal(t, tc.rejected, rejected)
+			if tc.rejected < 0 {
+				assert.Empty(t, published.events)
+				assert.Empty(t, counters.throttles)
+				return
+			}
+
+			// 命中规则后限制发送并发布一条治理事件
+			if assert.Len(t, published.events, 1) {
+				event := published.events[0]
+				assert.Equal(t, "spam", event.Type)
+				assert.Equal(t, tc.rule, event.Rule)
+				assert.Equal(t, "spammer", event.UserID)
+				assert.Equal(t, tc.count, event.Count)
+				assert.Equal(t, tc.sends[tc.rejected].content, event.Sample)
+			}
+			assert.Equal(t, counters.now.Add(5*time.Minute), counters.throttles["spammer"])
+		})
+	}
+}
+
+func TestSpamInterceptor_ThrottleAndDecay(t *testing.T) {
+	s, counters, published := newSpamService(config.SpamConfig{Window: time.Minute, Throttle: 5 * time.Minute, DuplicateThreshold: 2})
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, spamSend(s, "u2", "promo"))
+	}
+	assert.Equal(t, ErrCodeSpamThrottled, errorCode(spamSend(s, "u2", "promo")))
+
+	// 限制期内任何消息都被拒绝，等待时间随时间减少，不再重复发布治理事件
+	counters.now = counters.now.Add(2*time.Minute + 500*time.Millisecond)
+	err := spamSend(s, "u3", "something else")
+	assert.Equal(t, ErrCodeSpamThrottled, errorCode(err))
+	assert.Equal(t, int64(180), err.(*ServiceError).RetryAfter)
+	assert.Len(t, published.events, 1)
+
+	// 公众号和其他域投递的消息不检测
+	assert.NoError(t, s.pipeline.run(StagePrePersist, &SendRequest{SenderID: "spammer", ReceiverID: "u2", Type: model.MessageTypeText, Content: "promo", Official: true}))
+
+	// 限制期结束后统计窗口也已过期，计数重新开始
+	counters.now = counters.now.Add(3 * time.Minute)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, spamSend(s, "u2", "promo"))
+	}
+	assert.Equal(t, ErrCodeSpamThrottled, errorCode(spamSend(s, "u2", "promo")))
+	assert.Len(t, published.events, 2)
+
+	// 其他用户不受影响
+	other := &SendRequest{SenderID: "u9", ReceiverID: "u2", Type: model.MessageTypeText, Content: "promo"}
+	assert.NoError(t, s.pipeline.run(StagePrePersist, other))
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// SetSpool 设置本地暂存区，Kafka或Redis写入失败的投递事件暂存后由StartSpoolReplay重放；未设置时写入失败直接返回错误
+func (s *MessageService) SetSpool(spool *store.Spool) {
+	s.spool = spool
+}
+
+// StartSpoolReplay 定期重放暂存区中的事件
+func (s *MessageService) StartSpoolReplay(interval time.Duration) {
+	if s.spool == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.replaySpool()
+		}
+	}()
+}
+
+// sendOfflineMessage 把离线消息写入Kafka
+func (s *MessageService) sendOfflineMessage(message *model.Message) error {
+	return s.writeOrSpool(&model.SpoolEntry{Kind: model.SpoolOfflineMessage, Message: message})
+}
+
+// sendGroupMessageEvent 把群聊消息写入Kafka
+func (s *MessageService) sendGroupMessageEvent(message *model.Message) error {
+	return s.writeOrSpool(&model.SpoolEntry{Kind: model.SpoolGroupMessage, Message: message})
+}
+
+// queueOfflineMessage 把私聊消息放入接收者的Redis离线队列
+func (s *MessageService) queueOfflineMessage(userID string, message *model.Message) error {
+	return s.writeOrSpool(&model.SpoolEntry{Kind: model.SpoolOfflineQueue, UserID: userID, Message: message})
+}
+
+// writeOrSpool 执行投递事件，失败或该会话还有未重放的事件时写入暂存区，保证会话内有序
+func (s *MessageService) writeOrSpool(entry *model.SpoolEntry) error {
+	if s.spool == nil {
+		return s.writeSpoolEntry(entry)
+	}
+	if !s.spool.Pending(store.PartitionKey(entry.Message)) {
+		err := s.writeSpoolEntry(entry)
+		if err == nil {
+			return nil
+		}
+		logger.Warn("Failed to write delivery event, spooling",
+			logger.String("kind", string(entry.Kind)),
+			logger.String("message_id", entry.Message.ID),
+			logger.ErrorField(err))
+	}
+
+	entry.SpooledAt = time.Now().Unix()
+	if err := s.spool.Append(entry); err != nil {
+		return fmt.Errorf("failed to spool %s: %w", entry.Kind, err)
+	}
+	metrics.SpooledEvents.WithLabelValues(string(entry.Kind)).Inc()
+	return nil
+}
+
+// writeSpoolEntry 把投递事件写入对应的Kafka主题或Redis队列
+func (s *MessageService) writeSpoolEntry(entry *model.SpoolEntry) error {
+	switch entry.Kind {
+	case model.SpoolOfflineMessage:
+		return s.kafkaStore.SendOfflineMessage(entry.Message)
+	case model.SpoolGroupMessage:
+		return s.kafkaStore.SendGroupMessage(entry.Message.GroupID, entry.Message)
+	case model.SpoolOfflineQueue:
+		return s.redisStore.SetOfflineMessage(entry.UserID, entry.Message)
+	default:
+		// 未知类型来自更新版本写入的暂存区，无法处理时丢弃
+		logger.Error("Dropping spool entry of unknown kind", logger.String("kind", string(entry.Kind)))
+		return nil
+	}
+}
+
+// replaySpool 重放一轮暂存区中的事件
+func (s *MessageService) replaySpool() {
+	if s.spool.Len() == 0 {
+		return
+	}
+	replayed, err := s.spool.Replay(func(entry *model.SpoolEntry) error {
+		if err := s.writeSpoolEntry(entry); err != nil {
+			return err
+		}
+		metrics.SpoolReplayed.WithLabelValues(string(entry.Kind)).Inc()
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to replay spool", logger.ErrorField(err))
+	}
+	if replayed > 0 {
+		logger.Info("Spooled delivery events replayed",
+			logger.Int("replayed", replayed),
+			logger.Int("remaining", s.spool.Len()))
+	}
+}
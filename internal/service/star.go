@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/model"
+)
+
+// 单次获取收藏的条数
+const (
+	defaultStarredLimit = 50
+	maxStarredLimit     = 200
+)
+
+// StarMessage 收藏消息，只能收藏自己参与的会话中未删除的消息，重复收藏忽略
+func (s *MessageService) StarMessage(userID, messageID string) error {
+	if s.mysqlStore == nil {
+		return newServiceError(ErrCodeInvalidRequest, "starred messages require the MySQL store")
+	}
+	message, err := s.GetMessage(userID, messageID)
+	if err != nil || message.IsDeleted() {
+		return newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+	ok, err := s.canAccessMessage(userID, message)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return newServiceError(ErrCodeNotFound, "message %s not found", messageID)
+	}
+
+	if err := s.mysqlStore.StarMessage(&model.UserStarredMessage{
+		UserID:         userID,
+		MessageID:      messageID,
+		ConversationID: conversationOf(userID, message),
+		StarredAt:      time.Now().Unix(),
+	}); err != nil {
+		return fmt.Errorf("failed to star message: %w", err)
+	}
+	return nil
+}
+
+// UnstarMessage 取消收藏，未收藏时忽略
+func (s *MessageService) UnstarMessage(userID, messageID string) error {
+	if s.mysqlStore == nil {
+		return newServiceError(ErrCodeInvalidRequest, "starred messages require the MySQL store")
+	}
+	if err := s.mysqlStore.UnstarMessage(userID, messageID); err != nil {
+		return fmt.Errorf("failed to unstar message: %w", err)
+	}
+	return nil
+}
+
+// ListStarredMessages 按收藏时间倒序列出用户在所有会话中收藏的消息，返回下一页游标和是否还有更多
+// 已删除或已退出的群中的消息不返回，但计入翻页
+func (s *MessageService) ListStarredMessages(userID, cursor string, limit int) ([]*model.StarredMessage, string, bool, error) {
+	if s.mysqlStore == nil {
+		return nil, "", false, newServiceError(ErrCodeInvalidRequest, "starred messages require the MySQL store")
+	}
+	beforeAt, beforeID, err := parseStarCursor(cursor)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if limit <= 0 {
+		limit = defaultStarredLimit
+	}
+	if limit > maxStarredLimit {
+		limit = maxStarredLimit
+	}
+
+	stars, err := s.mysqlStore.GetStarredMessages(userID, beforeAt, beforeID, limit)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to get starred messages: %w", err)
+	}
+
+	nextCursor := ""
+	hasMore := len(stars) == limit
+	if hasMore {
+		last := stars[len(stars)-1]
+		nextCursor = formatStarCursor(last.StarredAt, last.MessageID)
+	}
+
+	membership := make(map[string]bool)
+	result := make([]*model.StarredMessage, 0, len(stars))
+	for _, star := range stars {
+		message, err := s.GetMessage(userID, star.MessageID)
+		if err != nil || message.IsDeleted() {
+			continue
+		}
+		if message.IsGroupMessage() {
+			isMember, checked := membership[message.GroupID]
+			if !checked {
+				if isMember, err = s.mysqlStore.IsGroupMember(message.GroupID, userID); err != nil {
+					return nil, "", false, fmt.Errorf("failed to check group membership: %w", err)
+				}
+				membership[message.GroupID] = isMember
+			}
+			if !isMember {
+				continue
+			}
+		}
+		result = append(result, &model.StarredMessage{
+			ConversationID: star.ConversationID,
+			StarredAt:      star.StarredAt,
+			Message:        message,
+		})
+	}
+	return result, nextCursor, hasMore, nil
+}
+
+// conversationOf 消息在用户会话列表中的会话ID
+func conversationOf(userID string, message *model.Message) string {
+	if message.IsGroupMessage() {
+		return model.ConversationID(model.ConversationTypeGroup, message.GroupID)
+	}
+	peer := message.ReceiverID
+	if peer == userID {
+		peer = message.SenderID
+	}
+	return model.ConversationID(model.ConversationTypePrivate, peer)
+}
+
+// formatStarCursor 收藏列表游标，由上一页最后一条的收藏时间和消息ID组成
+func formatStarCursor(starredAt int64, messageID string) string {
+	return strconv.FormatInt(starredAt, 10) + ":" + messageID
+}
+
+// parseStarCursor 解析收藏列表游标，空游标从最新的收藏开始
+func parseStarCursor(cursor string) (int64, string, error) {
+	if cursor == "" {
+		return 0, "", nil
+	}
+	rawAt, messageID, ok := strings.Cut(cursor, ":")
+	starredAt, err := strconv.ParseInt(rawAt, 10, 64)
+	if !ok || err != nil || starredAt <= 0 || messageID == "" {
+		return 0, "", newServiceError(ErrCodeInvalidRequest, "invalid cursor: %s", cursor)
+	}
+	return starredAt, messageID, nil
+}
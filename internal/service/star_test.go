@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestStarCursor(t *testing.T) {
+	at, id, err := parseStarCursor(formatStarCursor(1700000000, "msg:1"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1700000000), at)
+	assert.Equal(t, "msg:1", id)
+
+	at, id, err = parseStarCursor("")
+	assert.NoError(t, err)
+	assert.Zero(t, at)
+	assert.Empty(t, id)
+
+	for _, cursor := range []string{"abc", "123", "x:msg", "0:msg", "123:"} {
+		_, _, err = parseStarCursor(cursor)
+		assert.Equal(t, ErrCodeInvalidRequest, errorCode(err), cursor)
+	}
+}
+
+func TestConversationOf(t *testing.T) {
+	message := &model.Message{SenderID: "alice", ReceiverID: "bob"}
+	assert.Equal(t, "private:bob", conversationOf("alice", message))
+	assert.Equal(t, "private:alice", conversationOf("bob", message))
+	assert.Equal(t, "group:g1", conversationOf("bob", &model.Message{SenderID: "alice", GroupID: "g1"}))
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// ConnectionCounter 本节点的连接统计，由WebSocket管理器实现
+type ConnectionCounter interface {
+	GetConnectionCount() int
+	GetOnlineUserCount() int
+	GetTransportCounts() map[string]int
+}
+
+// StatsService 节点运行统计，定期计算发送速率并把快照上报到Redis，供集群统计视图汇总
+// 未初始化时为nil，记录方法都是空操作
+type StatsService struct {
+	nodeID      string
+	mode        string
+	redisStore  *store.RedisStore
+	kafkaStore  *store.KafkaStore
+	mysqlStore  *store.MySQLStore
+	connections ConnectionCounter
+	interval    time.Duration
+
+	messages   atomic.Int64
+	deliveries atomic.Int64
+	failures   atomic.Int64
+
+	mu           sync.Mutex
+	lastMessages int64
+	lastSample   time.Time
+	rate         float64
+}
+
+// NewStatsService 创建统计服务，mysqlStore为nil时不检查MySQL
+func NewStatsService(nodeID, mode string, redisStore *store.RedisStore, kafkaStore *store.KafkaStore, mysqlStore *store.MySQLStore, connections ConnectionCounter, interval time.Duration) *StatsService {
+	return &StatsService{
+		nodeID:      nodeID,
+		mode:        mode,
+		redisStore:  redisStore,
+		kafkaStore:  kafkaStore,
+		mysqlStore:  mysqlStore,
+		connections: connections,
+		interval:    interval,
+		lastSample:  time.Now(),
+	}
+}
+
+// RecordMessage 记录一条已保存的消息
+func (s *StatsService) RecordMessage() {
+	if s == nil {
+		return
+	}
+	s.messages.Add(1)
+}
+
+// Deliverer 包装推送器，统计推送次数和失败次数
+func (s *StatsService) Deliverer(deliverer Deliverer) Deliverer {
+	if s == nil {
+		return deliverer
+	}
+	return &countingDeliverer{Deliverer: deliverer, stats: s}
+}
+
+// countingDeliverer 统计推送结果的推送器
+type countingDeliverer struct {
+	Deliverer
+	stats *StatsService
+}
+
+// SendToUser 推送并记录结果，用户不在线也计为失败
+func (d *countingDeliverer) SendToUser(userID string, message interface{}) error {
+	err := d.Deliverer.SendToUser(userID, message)
+	d.stats.deliveries.Add(1)
+	if err != nil {
+		d.stats.failures.Add(1)
+	}
+	return err
+}
+
+// BroadcastToGroup 群推送不返回结果，按接收者数计入推送次数
+func (d *countingDeliverer) BroadcastToGroup(userIDs []string, message interface{}) {
+	d.Deliverer.BroadcastToGroup(userIDs, message)
+	d.stats.deliveries.Add(int64(len(userIDs)))
+}
+
+// Start 启动采样循环
+func (s *StatsService) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.sample(time.Now())
+			// 快照保留两个周期，节点停止后很快从集群视图中消失
+			if err := s.redisStore.SaveNodeStats(s.Snapshot(), 2*s.interval); err != nil {
+				logger.Warn("Failed to publish node stats", logger.ErrorField(err))
+			}
+		}
+	}()
+}
+
+// sample 按两次采样之间的消息数计算发送速率
+func (s *StatsService) sample(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := s.messages.Load()
+	if elapsed := now.Sub(s.lastSample).Seconds(); elapsed > 0 {
+		s.rate = float64(total-s.lastMessages) / elapsed
+	}
+	s.lastMessages = total
+	s.lastSample = now
+}
+
+// Snapshot 本节点的当前统计，依赖健康状态即时检查
+func (s *StatsService) Snapshot() *model.NodeStats {
+	s.mu.Lock()
+	rate := s.rate
+	s.mu.Unlock()
+
+	stats := &model.NodeStats{
+		NodeID:           s.nodeID,
+		Mode:             s.mode,
+		Connections:      s.connections.GetConnectionCount(),
+		OnlineUsers:      s.connections.GetOnlineUserCount(),
+		Transports:       s.connections.GetTransportCounts(),
+		MessagesPerSec:   rate,
+		Deliveries:       s.deliveries.Load(),
+		DeliveryFailures: s.failures.Load(),
+		KafkaLag:         s.kafkaStore.Lag(),
+		Health:           make(map[string]*model.HealthStatus),
+		Timestamp:        time.Now().Unix(),
+	}
+	stats.DeliverySuccessRate = successRate(stats.Deliveries, stats.DeliveryFailures)
+
+	queued, err := s.redisStore.CountOfflineMessages()
+	if err != nil {
+		logger.Warn("Failed to count offline messages", logger.ErrorField(err))
+	}
+	stats.OfflineQueued = queued
+
+	stats.Health["redis"] = checkHealth(s.redisStore.Ping)
+	stats.Health["kafka"] = checkHealth(s.kafkaStore.Ping)
+	if s.mysqlStore != nil {
+		stats.Health["mysql"] = checkHealth(s.mysqlStore.Ping)
+	}
+	return stats
+}
+
+// ClusterStats 汇总注册中心中各节点上报的快照，本节点使用即时快照
+func (s *StatsService) ClusterStats(nodeIDs []string) (*model.ClusterStats, error) {
+	reported, err := s.redisStore.GetNodeStats(nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+	reported[s.nodeID] = s.Snapshot()
+
+	result := &model.ClusterStats{
+		Nodes:   make([]*model.NodeStats, 0, len(nodeIDs)),
+		Missing: []string{},
+	}
+	for _, nodeID := range nodeIDs {
+		if stats, ok := reported[nodeID]; ok {
+			result.Nodes = append(result.Nodes, stats)
+		} else {
+			result.Missing = append(result.Missing, nodeID)
+		}
+	}
+	result.Totals = aggregateNodeStats(result.Nodes)
+	return result, nil
+}
+
+// aggregateNodeStats 合并各节点的统计，计数求和，离线队列为全局值取最新
+// 依赖在任一节点上不可用即视为不可用
+func aggregateNodeStats(nodes []*model.NodeStats) *model.NodeStats {
+	totals := &model.NodeStats{
+		Transports: make(map[string]int),
+		KafkaLag:   make(map[string]int64),
+		Health:     make(map[string]*model.HealthStatus),
+	}
+	for _, node := range nodes {
+		totals.Connections += node.Connections
+		totals.OnlineUsers += node.OnlineUsers
+		totals.MessagesPerSec += node.MessagesPerSec
+		totals.Deliveries += node.Deliveries
+		totals.DeliveryFailures += node.DeliveryFailures
+		for transport, count := range node.Transports {
+			totals.Transports[transport] += count
+		}
+		for topic, lag := range node.KafkaLag {
+			totals.KafkaLag[topic] += lag
+		}
+		for name, health := range node.Health {
+			current, ok := totals.Health[name]
+			if !ok || health.Status == model.HealthStatusDown || (current.Status == health.Status && health.LatencyMs > current.LatencyMs) {
+				totals.Health[name] = health
+			}
+		}
+		if node.Timestamp > totals.Timestamp {
+			totals.Timestamp = node.Timestamp
+			totals.OfflineQueued = node.OfflineQueued
+		}
+	}
+	totals.DeliverySuccessRate = successRate(totals.Deliveries, totals.DeliveryFailures)
+	return totals
+}
+
+// successRate 推送成功率，没有推送时为1
+func successRate(deliveries, failures int64) float64 {
+	if deliveries == 0 {
+		return 1
+	}
+	return float64(deliveries-failures) / float64(deliveries)
+}
+
+// checkHealth 执行检查并记录耗时
+func checkHealth(ping func() error) *model.HealthStatus {
+	start := time.Now()
+	err := ping()
+	status := &model.HealthStatus{
+		Status:    model.HealthStatusUp,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		status.Status = model.HealthStatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
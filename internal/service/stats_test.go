@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestAggregateNodeStats(t *testing.T) {
+	nodes := []*model.NodeStats{
+		{
+			NodeID:        "gw-1",
+			Connections:   10,
+			OnlineUsers:   8,
+			Transports:    map[string]int{"websocket": 10},
+			OfflineQueued: 5,
+			KafkaLag:      map[string]int64{},
+			Health:        map[string]*model.HealthStatus{"redis": {Status: model.HealthStatusUp, LatencyMs: 1}},
+			Timestamp:     100,
+		},
+		{
+			NodeID:           "worker-1",
+			MessagesPerSec:   2.5,
+			Deliveries:       40,
+			DeliveryFailures: 10,
+			OfflineQueued:    7,
+			KafkaLag:         map[string]int64{"im_group_chat": 3},
+			Health: map[string]*model.HealthStatus{
+				"redis": {Status: model.HealthStatusUp, LatencyMs: 4},
+				"mysql": {Status: model.HealthStatusDown, Error: "timeout"},
+			},
+			Timestamp: 110,
+		},
+		{
+			NodeID:         "worker-2",
+			MessagesPerSec: 1.5,
+			Deliveries:     60,
+			KafkaLag:       map[string]int64{"im_group_chat": 2},
+			Health:         map[string]*model.HealthStatus{"mysql": {Status: model.HealthStatusUp, LatencyMs: 2}},
+			Timestamp:      90,
+		},
+	}
+
+	totals := aggregateNodeStats(nodes)
+	assert.Equal(t, 10, totals.Connections)
+	assert.Equal(t, 4.0, totals.MessagesPerSec)
+	assert.Equal(t, int64(100), totals.Deliveries)
+	assert.Equal(t, 0.9, totals.DeliverySuccessRate)
+	assert.Equal(t, int64(7), totals.OfflineQueued)
+	assert.Equal(t, map[string]int64{"im_group_chat": 5}, totals.KafkaLag)
+	assert.Equal(t, int64(4), totals.Health["redis"].LatencyMs)
+	assert.Equal(t, model.HealthStatusDown, totals.Health["mysql"].Status)
+}
+
+func TestSuccessRate_NoDeliveries(t *testing.T) {
+	assert.Equal(t, 1.0, successRate(0, 0))
+}
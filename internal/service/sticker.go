@@ -0,0 +1,281 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// stickerIDPattern 表情包和表情的ID
+var stickerIDPattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// shortcodePattern 自定义表情的短代码，客户端以 :shortcode: 的形式插入
+var shortcodePattern = regexp.MustCompile(`^[a-z0-9_+-]{1,32}$`)
+
+// StickerService 表情包和自定义表情
+// 表情包通过管理接口保存在Redis中，各节点在内存中保留一份，变更后经Redis频道通知所有节点重新加载；
+// 租户表情包只对该租户的用户可见，租户划分沿用quota.tenant_separator
+type StickerService struct {
+	redisStore      *store.RedisStore
+	cfg             config.StickersConfig
+	tenantSeparator string
+
+	mu       sync.RWMutex
+	packs    map[string]*model.StickerPack
+	onChange []func()
+}
+
+// NewStickerService 创建表情包服务，启动前没有可用的表情包
+func NewStickerService(redisStore *store.RedisStore, cfg config.StickersConfig, tenantSeparator string) *StickerService {
+	return &StickerService{
+		redisStore:      redisStore,
+		cfg:             cfg,
+		tenantSeparator: tenantSeparator,
+		packs:           make(map[string]*model.StickerPack),
+	}
+}
+
+// OnChange 注册表情包变化回调，需在Start前调用
+func (s *StickerService) OnChange(fn func()) {
+	s.onChange = append(s.onChange, fn)
+}
+
+// Start 加载Redis中的表情包，之后在收到变更通知或定期刷新时重新加载
+func (s *StickerService) Start() {
+	s.reload()
+
+	go func() {
+		pubsub := s.redisStore.Subscribe(store.StickerPacksChannel)
+		defer pubsub.Close()
+		for range pubsub.Channel() {
+			s.reload()
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(s.cfg.RefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.reload()
+		}
+	}()
+}
+
+// reload 重新加载表情包，表情包变化时通知回调
+func (s *StickerService) reload() {
+	packs, err := s.redisStore.GetStickerPacks()
+	if err != nil {
+		logger.Warn("Failed to load sticker packs", logger.ErrorField(err))
+		return
+	}
+
+	s.mu.Lock()
+	changed := !reflect.DeepEqual(s.packs, packs)
+	s.packs = packs
+	s.mu.Unlock()
+
+	if changed {
+		logger.Info("Sticker packs changed", logger.Int("packs", len(packs)))
+		for _, fn := range s.onChange {
+			fn()
+		}
+	}
+}
+
+// Packs 用户可用的表情包，按名称排序
+func (s *StickerService) Packs(userID string) []*model.StickerPack {
+	tenant := tenantOf(userID, s.tenantSeparator)
+	return s.filter(func(p *model.StickerPack) bool {
+		return p.Tenant == "" || p.Tenant == tenant
+	})
+}
+
+// Summaries 用户可用的表情包摘要，随客户端配置下发；nil服务返回nil
+func (s *StickerService) Summaries(userID string) []model.StickerPackSummary {
+	if s == nil {
+		return nil
+	}
+	packs := s.Packs(userID)
+	summaries := make([]model.StickerPackSummary, 0, len(packs))
+	for _, p := range packs {
+		summaries = append(summaries, model.StickerPackSummary{
+			ID:        p.ID,
+			Name:      p.Name,
+			Kind:      p.Kind,
+			CoverURL:  p.CoverURL,
+			Count:     len(p.Stickers),
+			UpdatedAt: p.UpdatedAt,
+		})
+	}
+	return summaries
+}
+
+// List 全部表情包，tenant不为空时只返回该租户的表情包
+func (s *StickerService) List(tenant string) []*model.StickerPack {
+	return s.filter(func(p *model.StickerPack) bool {
+		return tenant == "" || p.Tenant == tenant
+	})
+}
+
+// Pack 用户可用的表情包，不存在或不可见时返回not_found
+func (s *StickerService) Pack(userID, id string) (*model.StickerPack, error) {
+	s.mu.RLock()
+	pack, exists := s.packs[id]
+	s.mu.RUnlock()
+	if !exists || (pack.Tenant != "" && pack.Tenant != tenantOf(userID, s.tenantSeparator)) {
+		return nil, newServiceError(ErrCodeNotFound, "sticker pack %s not found", id)
+	}
+	return pack, nil
+}
+
+// SetPack 上传或整体替换表情包并通知所有节点
+func (s *StickerService) SetPack(pack *model.StickerPack) error {
+	if pack.Kind == "" {
+		pack.Kind = model.StickerPackKindSticker
+	}
+	if err := validateStickerPack(pack, s.cfg.MaxStickers); err != nil {
+		return newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+
+	s.mu.RLock()
+	_, exists := s.packs[pack.ID]
+	count := len(s.packs)
+	s.mu.RUnlock()
+	if !exists && count >= s.cfg.MaxPacks {
+		return newServiceError(ErrCodeInvalidRequest, "sticker packs exceed limit %d", s.cfg.MaxPacks)
+	}
+
+	pack.UpdatedAt = time.Now().Unix()
+	if err := s.redisStore.SetStickerPack(pack); err != nil {
+		return fmt.Errorf("failed to save sticker pack: %w", err)
+	}
+	s.notify()
+	return nil
+}
+
+// DeletePack 删除表情包并通知所有节点，已发送的表情包消息保留
+func (s *StickerService) DeletePack(id string) error {
+	deleted, err := s.redisStore.DeleteStickerPack(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete sticker pack: %w", err)
+	}
+	if !deleted {
+		return newServiceError(ErrCodeNotFound, "sticker pack %s not found", id)
+	}
+	s.notify()
+	return nil
+}
+
+// Validate 校验表情包消息的内容，表情包必须对发送者可见；nil服务拒绝表情包消息
+func (s *StickerService) Validate(userID, content string) error {
+	if s == nil {
+		return newServiceError(ErrCodeInvalidRequest, "sticker messages are not enabled")
+	}
+	var payload model.StickerPayload
+	if err := json.Unmarshal([]byte(content), &payload); err != nil || payload.PackID == "" || payload.StickerID == "" {
+		return newServiceError(ErrCodeInvalidRequest, "sticker content must contain pack_id and sticker_id")
+	}
+	pack, err := s.Pack(userID, payload.PackID)
+	if err != nil {
+		return err
+	}
+	if _, ok := pack.Sticker(payload.StickerID); !ok {
+		return newServiceError(ErrCodeNotFound, "sticker %s not found in pack %s", payload.StickerID, payload.PackID)
+	}
+	return nil
+}
+
+// filter 按条件筛选表情包，按名称和ID排序
+func (s *StickerService) filter(keep func(*model.StickerPack) bool) []*model.StickerPack {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	packs := make([]*model.StickerPack, 0, len(s.packs))
+	for _, p := range s.packs {
+		if keep(p) {
+			packs = append(packs, p)
+		}
+	}
+	sort.Slice(packs, func(i, j int) bool {
+		if packs[i].Name != packs[j].Name {
+			return packs[i].Name < packs[j].Name
+		}
+		return packs[i].ID < packs[j].ID
+	})
+	return packs
+}
+
+// notify 通知所有节点重新加载，通知失败时本节点立即生效，其他节点等待定期刷新
+func (s *StickerService) notify() {
+	if err := s.redisStore.PublishMessage(store.StickerPacksChannel, time.Now().Unix()); err != nil {
+		logger.Warn("Failed to publish sticker pack change", logger.ErrorField(err))
+		s.reload()
+	}
+}
+
+// validateStickerPack 校验表情包定义，自定义表情必须有唯一的短代码
+func validateStickerPack(pack *model.StickerPack, maxStickers int) error {
+	if !stickerIDPattern.MatchString(pack.ID) {
+		return fmt.Errorf("invalid sticker pack id: %s", pack.ID)
+	}
+	if pack.Name == "" || len([]rune(pack.Name)) > 100 {
+		return fmt.Errorf("sticker pack name must be 1-100 characters")
+	}
+	if pack.Kind != model.StickerPackKindSticker && pack.Kind != model.StickerPackKindEmoji {
+		return fmt.Errorf("invalid sticker pack kind: %s", pack.Kind)
+	}
+	if len(pack.Tenant) > 64 {
+		return fmt.Errorf("tenant exceeds 64 characters")
+	}
+	if pack.CoverURL != "" && !isFileURL(pack.CoverURL) {
+		return fmt.Errorf("cover_url must be an http(s) URL")
+	}
+	if len(pack.Stickers) == 0 || len(pack.Stickers) > maxStickers {
+		return fmt.Errorf("sticker pack must contain 1-%d stickers", maxStickers)
+	}
+
+	ids := make(map[string]bool, len(pack.Stickers))
+	shortcodes := make(map[string]bool, len(pack.Stickers))
+	for _, sticker := range pack.Stickers {
+		if !stickerIDPattern.MatchString(sticker.ID) {
+			return fmt.Errorf("invalid sticker id: %s", sticker.ID)
+		}
+		if ids[sticker.ID] {
+			return fmt.Errorf("duplicate sticker id: %s", sticker.ID)
+		}
+		ids[sticker.ID] = true
+		if !isFileURL(sticker.FileURL) {
+			return fmt.Errorf("sticker %s file_url must be an http(s) URL", sticker.ID)
+		}
+		if sticker.Shortcode == "" {
+			if pack.Kind == model.StickerPackKindEmoji {
+				return fmt.Errorf("emoji %s requires a shortcode", sticker.ID)
+			}
+			continue
+		}
+		if !shortcodePattern.MatchString(sticker.Shortcode) {
+			return fmt.Errorf("invalid shortcode: %s", sticker.Shortcode)
+		}
+		if shortcodes[sticker.Shortcode] {
+			return fmt.Errorf("duplicate shortcode: %s", sticker.Shortcode)
+		}
+		shortcodes[sticker.Shortcode] = true
+	}
+	return nil
+}
+
+// isFileURL 判断是否为媒体服务返回的http(s)地址
+func isFileURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func testStickerPack(id, tenant string) *model.StickerPack {
+	return &model.StickerPack{
+		ID:     id,
+		Name:   id,
+		Kind:   model.StickerPackKindSticker,
+		Tenant: tenant,
+		Stickers: []model.Sticker{
+			{ID: "wave", FileURL: "https://media.example.com/stickers/wave.webp"},
+		},
+	}
+}
+
+func TestValidateStickerPack(t *testing.T) {
+	assert.NoError(t, validateStickerPack(testStickerPack("cats", ""), 10))
+
+	emoji := testStickerPack("emoji", "")
+	emoji.Kind = model.StickerPackKindEmoji
+	assert.Error(t, validateStickerPack(emoji, 10), "emoji requires shortcode")
+	emoji.Stickers[0].Shortcode = "wave"
+	assert.NoError(t, validateStickerPack(emoji, 10))
+
+	invalid := testStickerPack("Bad ID", "")
+	assert.Error(t, validateStickerPack(invalid, 10))
+
+	local := testStickerPack("local", "")
+	local.Stickers[0].FileURL = "file:///tmp/wave.webp"
+	assert.Error(t, validateStickerPack(local, 10))
+
+	duplicate := testStickerPack("dup", "")
+	duplicate.Stickers = append(duplicate.Stickers, duplicate.Stickers[0])
+	assert.Error(t, validateStickerPack(duplicate, 10))
+	assert.Error(t, validateStickerPack(testStickerPack("cats", ""), 0), "exceeds max stickers")
+}
+
+func TestStickerService_Validate(t *testing.T) {
+	s := NewStickerService(nil, config.StickersConfig{}, ":")
+	s.packs = map[string]*model.StickerPack{
+		"global": testStickerPack("global", ""),
+		"acme":   testStickerPack("acme", "acme"),
+	}
+
+	assert.NoError(t, s.Validate("acme:alice", `{"pack_id":"acme","sticker_id":"wave"}`))
+	assert.NoError(t, s.Validate("bob", `{"pack_id":"global","sticker_id":"wave"}`))
+	assert.Equal(t, ErrCodeNotFound, errorCode(s.Validate("bob", `{"pack_id":"acme","sticker_id":"wave"}`)))
+	assert.Equal(t, ErrCodeNotFound, errorCode(s.Validate("bob", `{"pack_id":"global","sticker_id":"missing"}`)))
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(s.Validate("bob", "wave")))
+
+	var disabled *StickerService
+	assert.Equal(t, ErrCodeInvalidRequest, errorCode(disabled.Validate("bob", `{"pack_id":"global","sticker_id":"wave"}`)))
+
+	assert.Len(t, s.Packs("acme:alice"), 2)
+	assert.Len(t, s.Packs("bob"), 1)
+	assert.Len(t, s.Summaries("bob"), 1)
+}
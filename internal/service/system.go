@@ -0,0 +1,181 @@
+package service
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/user/im/internal/i18n"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// SetI18n 设置系统消息的多语言目录和用户资料服务，未设置资料服务时所有用户使用默认语言
+func (s *MessageService) SetI18n(catalog *i18n.Catalog, profiles *ProfileService) {
+	s.catalog = catalog
+	s.profiles = profiles
+}
+
+// publishSystemMessage 在群内发布系统消息，失败只记录日志，不影响触发它的操作
+// 存储的Content为默认语言的渲染结果，推送和拉取时再按接收者的偏好语言重新渲染
+func (s *MessageService) publishSystemMessage(groupID string, event model.SystemEvent, params map[string]string) {
+	group, err := s.mysqlStore.GetGroup(groupID)
+	if err != nil {
+		logger.Warn("Failed to publish system message", logger.String("group_id", groupID), logger.ErrorField(err))
+		return
+	}
+	// 超大群成员变动频繁，不发布加入和退出消息
+	if group.IsChannel() && (event == model.SystemEventMemberJoined || event == model.SystemEventMemberLeft) {
+		return
+	}
+
+	messageID, err := s.messageIDs.NextID()
+	if err != nil {
+		logger.Warn("Failed to publish system message", logger.String("group_id", groupID), logger.ErrorField(err))
+		return
+	}
+	message := &model.Message{
+		ID:        messageID,
+		SenderID:  model.SystemSenderID,
+		GroupID:   groupID,
+		Type:      model.MessageTypeSystem,
+		Content:   s.catalog.Render(s.catalog.DefaultLanguage(), string(event), params),
+		System:    &model.SystemPayload{Event: event, Params: params},
+		Status:    model.MessageStatusSent,
+		Timestamp: time.Now().Unix(),
+	}
+	s.assignSeq(message)
+	if err := s.storeBackend.SaveMessage(message); err != nil {
+		logger.Warn("Failed to save system message", logger.String("group_id", groupID), logger.ErrorField(err))
+		return
+	}
+	s.events.MessageCreated(message)
+	s.redisStore.SetMessageCache(messageID, message)
+	s.redisStore.SetGroupLastActive(groupID, message.Timestamp)
+
+	if !group.IsChannel() {
+		userIDs, err := s.groupMemberIDs(groupID)
+		if err != nil {
+			logger.Warn("Failed to get group members", logger.String("group_id", groupID), logger.ErrorField(err))
+		} else {
+			s.broadcastGroupMessage(userIDs, message)
+		}
+	}
+
+	// 超大群由Kafka消费者分批扇出
+	if err := s.sendGroupMessageEvent(message); err != nil {
+		logger.Warn("Failed to send system message to kafka", logger.String("message_id", messageID), logger.ErrorField(err))
+	}
+}
+
+// broadcastGroupMessage 广播群消息，处于免打扰时段的接收者收到静音的推送帧，系统消息按接收者的语言分组渲染后分别广播；
+// 不在线的成员经推送提示通道得到通知
+func (s *MessageService) broadcastGroupMessage(userIDs []string, message *model.Message) {
+	defer s.hintOffline(userIDs, message)
+	quiet := s.quietUsers(userIDs, message)
+	if len(quiet) == 0 {
+		s.broadcastLocalized(userIDs, message, groupMessageFrame)
+		return
+	}
+	var normal, silenced []string
+	for _, userID := range userIDs {
+		if quiet[userID] {
+			silenced = append(silenced, userID)
+		} else {
+			normal = append(normal, userID)
+		}
+	}
+	s.broadcastLocalized(normal, message, groupMessageFrame)
+	s.broadcastLocalized(silenced, message, quietGroupMessageFrame)
+}
+
+// broadcastLocalized 用frame构造推送帧广播群消息，系统消息和启用通知内容时按接收者的语言分组渲染
+func (s *MessageService) broadcastLocalized(userIDs []string, message *model.Message, frame func(*model.Message) model.WebSocketMessage) {
+	if len(userIDs) == 0 {
+		return
+	}
+	if !message.IsSystem() && !s.notifyCfg.Enabled {
+		s.deliverer.BroadcastToGroup(userIDs, frame(message))
+		return
+	}
+	for lang, ids := range s.groupByLanguage(userIDs) {
+		localized := message
+		if message.IsSystem() {
+			localized = s.localize(message, lang)
+		}
+		s.deliverer.BroadcastToGroup(ids, s.notify(frame(localized), localized, "", lang, 0))
+	}
+}
+
+// groupByLanguage 按目录中匹配到的语言对用户分组
+func (s *MessageService) groupByLanguage(userIDs []string) map[string][]string {
+	var languages map[string]string
+	if s.profiles != nil {
+		var err error
+		if languages, err = s.profiles.Languages(userIDs); err != nil {
+			logger.Warn("Failed to get user languages", logger.ErrorField(err))
+		}
+	}
+
+	groups := make(map[string][]string)
+	for _, userID := range userIDs {
+		lang := s.catalog.Match(languages[userID])
+		groups[lang] = append(groups[lang], userID)
+	}
+	return groups
+}
+
+// localizeMessages 按请求者的偏好语言渲染其中的系统消息
+func (s *MessageService) localizeMessages(userID string, messages []*model.Message) []*model.Message {
+	lang, resolved := "", false
+	for i, m := range messages {
+		if !m.IsSystem() {
+			continue
+		}
+		if !resolved {
+			lang = s.userLanguage(userID)
+			resolved = true
+		}
+		messages[i] = s.localize(m, lang)
+	}
+	return messages
+}
+
+// userLanguage 获取用户的偏好语言，获取失败时使用默认语言
+func (s *MessageService) userLanguage(userID string) string {
+	if s.profiles == nil {
+		return ""
+	}
+	languages, err := s.profiles.Languages([]string{userID})
+	if err != nil {
+		logger.Warn("Failed to get user language", logger.String("user_id", userID), logger.ErrorField(err))
+		return ""
+	}
+	return languages[userID]
+}
+
+// localize 返回按语言渲染后的系统消息副本
+func (s *MessageService) localize(message *model.Message, lang string) *model.Message {
+	localized := *message
+	localized.Content = s.catalog.Render(lang, string(message.System.Event), message.System.Params)
+	return &localized
+}
+
+// muteParams 禁言系统消息的参数，时长向上取整到分钟
+func muteParams(operatorID, userID string, duration time.Duration) (model.SystemEvent, map[string]string) {
+	params := map[string]string{"operator": operatorID, "user": userID}
+	if duration <= 0 {
+		return model.SystemEventMemberUnmuted, params
+	}
+	params["minutes"] = strconv.FormatInt(int64((duration+time.Minute-1)/time.Minute), 10)
+	return model.SystemEventMemberMuted, params
+}
+
+// groupMessageFrame 构造群消息推送帧
+func groupMessageFrame(message *model.Message) model.WebSocketMessage {
+	return model.NewMessageFrame(model.FrameNewGroupMessage, message)
+}
+
+// quietGroupMessageFrame 构造发给处于免打扰时段的成员的群消息推送帧
+func quietGroupMessageFrame(message *model.Message) model.WebSocketMessage {
+	return model.NewQuietMessageFrame(model.FrameNewGroupMessage, message)
+}
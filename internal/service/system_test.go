@@ -0,0 +1,39 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/i18n"
+	"github.com/user/im/internal/model"
+)
+
+func TestMuteParams(t *testing.T) {
+	event, params := muteParams("admin", "u1", 90*time.Second)
+	assert.Equal(t, model.SystemEventMemberMuted, event)
+	assert.Equal(t, "2", params["minutes"])
+
+	event, params = muteParams("admin", "u1", 0)
+	assert.Equal(t, model.SystemEventMemberUnmuted, event)
+	assert.NotContains(t, params, "minutes")
+}
+
+func TestLocalizeMessages(t *testing.T) {
+	s := &MessageService{catalog: i18n.Builtin()}
+	system := &model.Message{
+		ID:      "1",
+		Type:    model.MessageTypeSystem,
+		Content: "stored",
+		System:  &model.SystemPayload{Event: model.SystemEventMemberJoined, Params: map[string]string{"user": "u1"}},
+	}
+	text := &model.Message{ID: "2", Type: model.MessageTypeText, Content: "hello"}
+
+	messages := s.localizeMessages("u2", []*model.Message{system, text})
+	assert.Equal(t, "u1 加入了群聊", messages[0].Content)
+	assert.Equal(t, "hello", messages[1].Content)
+	// 渲染的是副本，不修改缓存中的原消息
+	assert.Equal(t, "stored", system.Content)
+
+	assert.Equal(t, "u1 joined the group", s.localize(system, "en").Content)
+}
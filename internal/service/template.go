@@ -0,0 +1,274 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+)
+
+const (
+	// maxTemplates 消息模板总数上限
+	maxTemplates = 500
+	// maxTemplateVariables 单个模板的变量数上限
+	maxTemplateVariables = 20
+	// maxTemplateContentLength 模板内容的最大长度（字符）
+	maxTemplateContentLength = 4000
+	// maxTemplateValueLength 变量值的默认和最大长度上限（字符）
+	maxTemplateValueLength = 1000
+	// maxRenderedTemplateLength 替换变量后消息内容的最大长度（字符）
+	maxRenderedTemplateLength = 8000
+)
+
+// templateIDPattern 模板ID
+var templateIDPattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// templateVariablePattern 模板变量名
+var templateVariablePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// templatePlaceholderPattern 模板内容中的占位符，如 {{order_id}}
+var templatePlaceholderPattern = regexp.MustCompile(`\{\{\s*([a-z][a-z0-9_]{0,31})\s*\}\}`)
+
+// TemplateService 服务消息模板，模板通过管理接口保存在Redis中，后端系统凭API密钥按模板发送消息
+type TemplateService struct {
+	redisStore *store.RedisStore
+	apiKeys    *APIKeyService
+}
+
+// NewTemplateService 创建消息模板服务
+func NewTemplateService(redisStore *store.RedisStore, apiKeys *APIKeyService) *TemplateService {
+	return &TemplateService{
+		redisStore: redisStore,
+		apiKeys:    apiKeys,
+	}
+}
+
+// SetTemplate 创建或整体替换消息模板
+func (t *TemplateService) SetTemplate(template *model.MessageTemplate) error {
+	if template.Type == "" {
+		template.Type = model.MessageTypeText
+	}
+	if err := validateTemplate(template); err != nil {
+		return newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+
+	_, exists, err := t.redisStore.GetMessageTemplate(template.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get message template: %w", err)
+	}
+	if !exists {
+		count, err := t.redisStore.CountMessageTemplates()
+		if err != nil {
+			return fmt.Errorf("failed to count message templates: %w", err)
+		}
+		if count >= maxTemplates {
+			return newServiceError(ErrCodeInvalidRequest, "message templates exceed limit %d", maxTemplates)
+		}
+	}
+
+	template.UpdatedAt = time.Now().Unix()
+	if err := t.redisStore.SetMessageTemplate(template); err != nil {
+		return fmt.Errorf("failed to save message template: %w", err)
+	}
+	return nil
+}
+
+// ListTemplates 全部消息模板，按ID排序
+func (t *TemplateService) ListTemplates() ([]*model.MessageTemplate, error) {
+	templates, err := t.redisStore.GetMessageTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list message templates: %w", err)
+	}
+	list := make([]*model.MessageTemplate, 0, len(templates))
+	for _, template := range templates {
+		list = append(list, template)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+// GetTemplate 获取消息模板，不存在时返回not_found
+func (t *TemplateService) GetTemplate(id string) (*model.MessageTemplate, error) {
+	template, exists, err := t.redisStore.GetMessageTemplate(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message template: %w", err)
+	}
+	if !exists {
+		return nil, newServiceError(ErrCodeNotFound, "message template %s not found", id)
+	}
+	return template, nil
+}
+
+// DeleteTemplate 删除消息模板，已发送的消息保留
+func (t *TemplateService) DeleteTemplate(id string) error {
+	deleted, err := t.redisStore.DeleteMessageTemplate(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete message template: %w", err)
+	}
+	if !deleted {
+		return newServiceError(ErrCodeNotFound, "message template %s not found", id)
+	}
+	return nil
+}
+
+// Send 按模板渲染消息并以密钥绑定的账号发送，先校验变量和模板每分钟限额，再按密钥的范围和限额发送
+func (t *TemplateService) Send(key *model.APIKey, conversationID, templateID string, values map[string]string, priority model.MessagePriority) (*model.Message, error) {
+	template, err := t.GetTemplate(templateID)
+	if err != nil {
+		return nil, err
+	}
+	content, err := renderTemplate(template, values)
+	if err != nil {
+		return nil, newServiceError(ErrCodeInvalidRequest, "%s", err.Error())
+	}
+	if !key.Allows(conversationID) {
+		return nil, newServiceError(ErrCodeForbidden, "api key is not allowed to post to %s", conversationID)
+	}
+
+	if template.RateLimit > 0 {
+		count, remaining, err := t.redisStore.IncrTemplateUsage(template.ID, apiKeyRateWindow)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check template rate limit: %w", err)
+		}
+		if count > int64(template.RateLimit) {
+			svcErr := newServiceError(ErrCodeRateLimited, "template %s rate limit of %d messages per minute exceeded", template.ID, template.RateLimit)
+			svcErr.RetryAfter = int64((remaining + time.Second - 1) / time.Second)
+			return nil, svcErr
+		}
+	}
+
+	return t.apiKeys.SendMessage(key, conversationID, template.Type, content, priority)
+}
+
+// validateTemplate 校验模板定义：占位符必须与声明的变量一一对应，
+// 用默认值或示例值渲染一次，确保JSON类型的模板渲染结果合法
+func validateTemplate(template *model.MessageTemplate) error {
+	if !templateIDPattern.MatchString(template.ID) {
+		return fmt.Errorf("invalid template id: %s", template.ID)
+	}
+	if template.Name == "" || utf8.RuneCountInString(template.Name) > 100 {
+		return fmt.Errorf("template name must be 1-100 characters")
+	}
+	if template.Type != model.MessageTypeText && template.Type != model.MessageTypeMenu {
+		return fmt.Errorf("template type must be text or menu")
+	}
+	if template.Content == "" || utf8.RuneCountInString(template.Content) > maxTemplateContentLength {
+		return fmt.Errorf("template content must be 1-%d characters", maxTemplateContentLength)
+	}
+	if template.RateLimit < 0 {
+		return fmt.Errorf("rate_limit must not be negative")
+	}
+	if len(template.Variables) > maxTemplateVariables {
+		return fmt.Errorf("template must not have more than %d variables", maxTemplateVariables)
+	}
+
+	declared := make(map[string]bool, len(template.Variables))
+	for _, v := range template.Variables {
+		if !templateVariablePattern.MatchString(v.Name) {
+			return fmt.Errorf("invalid variable name: %s", v.Name)
+		}
+		if declared[v.Name] {
+			return fmt.Errorf("duplicate variable: %s", v.Name)
+		}
+		declared[v.Name] = true
+		if v.MaxLength < 0 || v.MaxLength > maxTemplateValueLength {
+			return fmt.Errorf("variable %s max_length must be 0-%d", v.Name, maxTemplateValueLength)
+		}
+		if v.Required && v.Default != "" {
+			return fmt.Errorf("required variable %s must not have a default", v.Name)
+		}
+		if utf8.RuneCountInString(v.Default) > variableMaxLength(&v) {
+			return fmt.Errorf("variable %s default exceeds max_length", v.Name)
+		}
+	}
+
+	rest := templatePlaceholderPattern.ReplaceAllString(template.Content, "")
+	if strings.Contains(rest, "{{") || strings.Contains(rest, "}}") {
+		return fmt.Errorf("template content has a malformed placeholder")
+	}
+	used := make(map[string]bool)
+	for _, match := range templatePlaceholderPattern.FindAllStringSubmatch(template.Content, -1) {
+		if !declared[match[1]] {
+			return fmt.Errorf("placeholder %s is not a declared variable", match[1])
+		}
+		used[match[1]] = true
+	}
+	for _, v := range template.Variables {
+		if !used[v.Name] {
+			return fmt.Errorf("variable %s is not used in content", v.Name)
+		}
+	}
+
+	sample := make(map[string]string, len(template.Variables))
+	for _, v := range template.Variables {
+		if v.Default == "" {
+			sample[v.Name] = "x"
+		}
+	}
+	if _, err := renderTemplate(template, sample); err != nil {
+		return fmt.Errorf("template does not render: %w", err)
+	}
+	return nil
+}
+
+// renderTemplate 替换模板中的占位符，JSON类型的模板对变量值做JSON转义，占位符应位于JSON字符串内
+func renderTemplate(template *model.MessageTemplate, values map[string]string) (string, error) {
+	for name := range values {
+		if _, ok := template.Variable(name); !ok {
+			return "", fmt.Errorf("unknown variable: %s", name)
+		}
+	}
+	resolved := make(map[string]string, len(template.Variables))
+	for i := range template.Variables {
+		v := &template.Variables[i]
+		value, ok := values[v.Name]
+		if !ok || value == "" {
+			if v.Required {
+				return "", fmt.Errorf("variable %s is required", v.Name)
+			}
+			value = v.Default
+		}
+		if utf8.RuneCountInString(value) > variableMaxLength(v) {
+			return "", fmt.Errorf("variable %s exceeds %d characters", v.Name, variableMaxLength(v))
+		}
+		if template.Type != model.MessageTypeText {
+			escaped, err := json.Marshal(value)
+			if err != nil {
+				return "", err
+			}
+			value = string(escaped[1 : len(escaped)-1])
+		}
+		resolved[v.Name] = value
+	}
+
+	content := templatePlaceholderPattern.ReplaceAllStringFunc(template.Content, func(placeholder string) string {
+		return resolved[templatePlaceholderPattern.FindStringSubmatch(placeholder)[1]]
+	})
+	if utf8.RuneCountInString(content) > maxRenderedTemplateLength {
+		return "", fmt.Errorf("rendered content exceeds %d characters", maxRenderedTemplateLength)
+	}
+	if template.Type == model.MessageTypeMenu {
+		var menu model.MenuPayload
+		if err := json.Unmarshal([]byte(content), &menu); err != nil {
+			return "", fmt.Errorf("rendered menu content is not valid JSON: %w", err)
+		}
+		if menu.Text == "" || len(menu.Items) == 0 {
+			return "", fmt.Errorf("rendered menu content must have text and items")
+		}
+	}
+	return content, nil
+}
+
+// variableMaxLength 变量值的长度上限
+func variableMaxLength(v *model.TemplateVariable) int {
+	if v.MaxLength > 0 {
+		return v.MaxLength
+	}
+	return maxTemplateValueLength
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	valid := func() *model.MessageTemplate {
+		return &model.MessageTemplate{
+			ID:      "order_shipped",
+			Name:    "订单发货",
+			Type:    model.MessageTypeText,
+			Content: "您的订单 {{order_id}} 已由{{ carrier }}发货",
+			Variables: []model.TemplateVariable{
+				{Name: "order_id", Required: true},
+				{Name: "carrier", Default: "顺丰"},
+			},
+		}
+	}
+	assert.NoError(t, validateTemplate(valid()))
+
+	menu := &model.MessageTemplate{
+		ID:        "survey",
+		Name:      "满意度",
+		Type:      model.MessageTypeMenu,
+		Content:   `{"text":"{{question}}","items":[{"label":"满意","key":"yes"},{"label":"不满意","key":"no"}]}`,
+		Variables: []model.TemplateVariable{{Name: "question", Required: true}},
+	}
+	assert.NoError(t, validateTemplate(menu))
+
+	for name, mutate := range map[string]func(*model.MessageTemplate){
+		"bad id":              func(tpl *model.MessageTemplate) { tpl.ID = "Order Shipped" },
+		"unsupported type":    func(tpl *model.MessageTemplate) { tpl.Type = model.MessageTypeImage },
+		"undeclared variable": func(tpl *model.MessageTemplate) { tpl.Content += "{{eta}}" },
+		"unused variable":     func(tpl *model.MessageTemplate) { tpl.Content = "{{order_id}}" },
+		"malformed":           func(tpl *model.MessageTemplate) { tpl.Content += "{{eta" },
+		"duplicate variable":  func(tpl *model.MessageTemplate) { tpl.Variables[1].Name = "order_id" },
+		"required default":    func(tpl *model.MessageTemplate) { tpl.Variables[0].Default = "1" },
+		"negative rate limit": func(tpl *model.MessageTemplate) { tpl.RateLimit = -1 },
+	} {
+		tpl := valid()
+		mutate(tpl)
+		assert.Error(t, validateTemplate(tpl), name)
+	}
+
+	menu.Content = `{"text":{{question}},"items":[]}`
+	assert.Error(t, validateTemplate(menu))
+}
+
+func TestRenderTemplate(t *testing.T) {
+	tpl := &model.MessageTemplate{
+		Type:    model.MessageTypeText,
+		Content: "订单 {{order_id}} 由{{carrier}}发货",
+		Variables: []model.TemplateVariable{
+			{Name: "order_id", Required: true, MaxLength: 8},
+			{Name: "carrier", Default: "顺丰"},
+		},
+	}
+
+	content, err := renderTemplate(tpl, map[string]string{"order_id": "A1001"})
+	assert.NoError(t, err)
+	assert.Equal(t, "订单 A1001 由顺丰发货", content)
+
+	_, err = renderTemplate(tpl, map[string]string{})
+	assert.Error(t, err)
+	_, err = renderTemplate(tpl, map[string]string{"order_id": "A1001", "eta": "明天"})
+	assert.Error(t, err)
+	_, err = renderTemplate(tpl, map[string]string{"order_id": "A100100100"})
+	assert.Error(t, err)
+
+	menu := &model.MessageTemplate{
+		Type:      model.MessageTypeMenu,
+		Content:   `{"text":"{{question}}","items":[{"label":"满意","key":"yes"}]}`,
+		Variables: []model.TemplateVariable{{Name: "question", Required: true}},
+	}
+	content, err = renderTemplate(menu, map[string]string{"question": `"好用"吗`})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"text":"\"好用\"吗","items":[{"label":"满意","key":"yes"}]}`, content)
+}
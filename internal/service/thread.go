@@ -0,0 +1,88 @@
+package service
+
+import (
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// SendThreadReply 回复消息所在的话题，会话由根消息决定
+// 回复话题回复时归入同一个根消息，话题只有一层
+func (s *MessageService) SendThreadReply(senderID, threadID string, msgType model.MessageType, content string, priority model.MessagePriority) (*model.Message, error) {
+	parent, err := s.storeBackend.GetMessage(threadID)
+	if err != nil {
+		return nil, newServiceError(ErrCodeNotFound, "message %s not found", threadID)
+	}
+	ok, err := s.canAccessMessage(senderID, parent)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, newServiceError(ErrCodeNotFound, "message %s not found", threadID)
+	}
+
+	root := parent
+	if parent.IsThreadReply() {
+		root, err = s.storeBackend.GetMessage(parent.ThreadID)
+		if err != nil {
+			return nil, newServiceError(ErrCodeNotFound, "message %s not found", parent.ThreadID)
+		}
+	}
+	if root.IsDeleted() {
+		return nil, newServiceError(ErrCodeInvalidRequest, "cannot reply to a deleted message")
+	}
+
+	if root.IsGroupMessage() {
+		return s.sendGroupMessage(senderID, root.GroupID, root.ID, msgType, content, priority)
+	}
+	receiverID := root.ReceiverID
+	if receiverID == senderID {
+		receiverID = root.SenderID
+	}
+	return s.sendPrivateMessage(senderID, receiverID, root.ID, msgType, content, priority)
+}
+
+// recordThreadReply 回复保存后增加根消息的回复数
+// 回复本身已保存，计数失败只记录日志
+func (s *MessageService) recordThreadReply(message *model.Message) {
+	if !message.IsThreadReply() {
+		return
+	}
+	s.updateThreadSummary(message.ThreadID, 1, message.Timestamp)
+}
+
+// updateThreadSummary 更新根消息的回复统计并清除其缓存
+func (s *MessageService) updateThreadSummary(threadID string, delta int64, lastReplyAt int64) {
+	if err := s.storeBackend.UpdateThreadSummary(threadID, delta, lastReplyAt); err != nil {
+		logger.Warn("Failed to update thread summary", logger.String("thread_id", threadID), logger.ErrorField(err))
+		return
+	}
+	s.lookup.invalidate(threadID)
+}
+
+// applyThreadSummaries 以存储中的回复统计为准，离线列表和缓存中的根消息副本可能是回复之前的
+func (s *MessageService) applyThreadSummaries(messages []*model.Message) ([]*model.Message, error) {
+	if len(messages) == 0 {
+		return messages, nil
+	}
+
+	ids := make([]string, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.ID)
+	}
+	summaries, err := s.storeBackend.GetThreadSummaries(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, m := range messages {
+		summary, ok := summaries[m.ID]
+		if !ok || (summary.ReplyCount == m.ReplyCount && summary.LastReplyAt == m.LastReplyAt) {
+			continue
+		}
+		updated := *m
+		updated.ReplyCount = summary.ReplyCount
+		updated.LastReplyAt = summary.LastReplyAt
+		messages[i] = &updated
+	}
+	return messages, nil
+}
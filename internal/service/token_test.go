@@ -0,0 +1,47 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func TestTokenService_IssueAndParse(t *testing.T) {
+	tokens := NewTokenService(config.AuthConfig{JWTSecret: "secret", Issuer: "im", TokenTTL: time.Hour})
+	token, err := tokens.Issue("u1", []string{model.RoleAdmin})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3600), token.ExpiresIn)
+
+	claims, err := tokens.Parse(token.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", claims.Subject)
+	assert.True(t, claims.HasRole(model.RoleAdmin))
+	assert.False(t, claims.HasRole(model.RoleAuditor))
+
+	// 篡改载荷、换签名密钥或签发方都不能通过
+	segments := strings.Split(token.AccessToken, ".")
+	forged, _ := tokens.Issue("u2", nil)
+	_, err = tokens.Parse(segments[0] + "." + strings.Split(forged.AccessToken, ".")[1] + "." + segments[2])
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = NewTokenService(config.AuthConfig{JWTSecret: "other", Issuer: "im", TokenTTL: time.Hour}).Parse(token.AccessToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = NewTokenService(config.AuthConfig{JWTSecret: "secret", Issuer: "other", TokenTTL: time.Hour}).Parse(token.AccessToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	expired, _ := NewTokenService(config.AuthConfig{JWTSecret: "secret", Issuer: "im", TokenTTL: -time.Second}).Issue("u1", nil)
+	_, err = tokens.Parse(expired.AccessToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestTokenService_AuthorizeLogin(t *testing.T) {
+	tokens := NewTokenService(config.AuthConfig{JWTSecret: "secret", Issuer: "im", TokenTTL: time.Hour})
+	token, _ := tokens.Issue("u1", nil)
+
+	assert.NoError(t, tokens.AuthorizeLogin(&model.LoginRequest{UserID: "u1", Token: token.AccessToken}))
+	assert.Equal(t, ErrCodeUnauthenticated, errorCode(tokens.AuthorizeLogin(&model.LoginRequest{UserID: "u2", Token: token.AccessToken})))
+	assert.Equal(t, ErrCodeUnauthenticated, errorCode(tokens.AuthorizeLogin(&model.LoginRequest{UserID: "u1"})))
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"gorm.io/gorm"
+)
+
+// TOTP参数，与常见验证器应用的默认值一致（RFC 6238，HMAC-SHA1）
+const (
+	totpPeriod      = 30
+	totpDigits      = 6
+	totpSecretBytes = 20
+)
+
+// recoveryCodeBytes 单个恢复码的随机字节数
+const recoveryCodeBytes = 5
+
+// totpEncoding 共享密钥的Base32编码，不带填充
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TwoFactorService 两步验证
+// 用户先绑定共享密钥，提交首个动态码后开启；开启后登录和签发API密钥都需要动态码或恢复码
+type TwoFactorService struct {
+	mysqlStore *store.MySQLStore
+	cfg        config.TwoFactorConfig
+	required   map[string]bool
+}
+
+// NewTwoFactorService 创建两步验证服务
+func NewTwoFactorService(mysqlStore *store.MySQLStore, cfg config.TwoFactorConfig) *TwoFactorService {
+	required := make(map[string]bool, len(cfg.RequiredAccounts))
+	for _, userID := range cfg.RequiredAccounts {
+		required[userID] = true
+	}
+	return &TwoFactorService{
+		mysqlStore: mysqlStore,
+		cfg:        cfg,
+		required:   required,
+	}
+}
+
+// Required 账号是否必须开启两步验证
+func (t *TwoFactorService) Required(userID string) bool {
+	return t.required[userID]
+}
+
+// Status 获取用户的两步验证状态
+func (t *TwoFactorService) Status(userID string) (*model.TwoFactorStatus, error) {
+	status := &model.TwoFactorStatus{UserID: userID, Required: t.Required(userID)}
+	tf, err := t.get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil || !tf.Enabled {
+		return status, nil
+	}
+	status.Enabled = true
+	status.EnabledAt = tf.EnabledAt
+	status.RecoveryCodesLeft = len(tf.RecoveryCodes)
+	return status, nil
+}
+
+// IsEnabled 用户是否已开启两步验证
+func (t *TwoFactorService) IsEnabled(userID string) (bool, error) {
+	tf, err := t.get(userID)
+	if err != nil {
+		return false, err
+	}
+	return tf != nil && tf.Enabled, nil
+}
+
+// Enroll 生成新的共享密钥，提交首个动态码前不生效；已开启时需先关闭
+func (t *TwoFactorService) Enroll(userID string) (*model.TwoFactorEnrollment, error) {
+	tf, err := t.get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf != nil && tf.Enabled {
+		return nil, newServiceError(ErrCodeInvalidRequest, "two-factor authentication is already enabled")
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.mysqlStore.SaveTwoFactor(&model.TwoFactor{UserID: userID, Secret: secret}); err != nil {
+		return nil, fmt.Errorf("failed to save two-factor secret: %w", err)
+	}
+	return &model.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totpURI(t.cfg.Issuer, userID, secret),
+	}, nil
+}
+
+// Activate 校验绑定后的首个动态码并开启两步验证，返回恢复码明文，明文只返回这一次
+func (t *TwoFactorService) Activate(userID, code string) ([]string, error) {
+	tf, err := t.get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil {
+		return nil, newServiceError(ErrCodeNotFound, "two-factor enrollment not found")
+	}
+	if tf.Enabled {
+		return nil, newServiceError(ErrCodeInvalidRequest, "two-factor authentication is already enabled")
+	}
+	step, ok := matchTOTP(tf.Secret, normalizeTwoFactorCode(code), time.Now(), t.cfg.Skew)
+	if !ok {
+		return nil, newServiceError(ErrCodeTwoFactorFailed, "invalid two-factor code")
+	}
+
+	codes, hashes, err := generateRecoveryCodes(t.cfg.RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	tf.Enabled = true
+	tf.EnabledAt = time.Now().Unix()
+	tf.LastStep = step
+	tf.RecoveryCodes = hashes
+	if err := t.mysqlStore.SaveTwoFactor(tf); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	return codes, nil
+}
+
+// Disable 关闭两步验证，需要动态码或恢复码；必须开启两步验证的账号不能关闭
+func (t *TwoFactorService) Disable(userID, code string) error {
+	if t.Required(userID) {
+		return newServiceError(ErrCodeForbidden, "two-factor authentication is required for this account")
+	}
+	if err := t.verify(userID, code); err != nil {
+		return err
+	}
+	if err := t.mysqlStore.DeleteTwoFactor(userID); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	return nil
+}
+
+// Reset 管理员清除用户的两步验证设置，用于用户同时丢失验证器和恢复码的情况
+func (t *TwoFactorService) Reset(userID string) error {
+	if err := t.mysqlStore.DeleteTwoFactor(userID); err != nil {
+		return fmt.Errorf("failed to reset two-factor authentication: %w", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧的恢复码全部失效
+func (t *TwoFactorService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	if err := t.verify(userID, code); err != nil {
+		return nil, err
+	}
+	tf, err := t.get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil || !tf.Enabled {
+		return nil, newServiceError(ErrCodeNotFound, "two-factor authentication is not enabled")
+	}
+
+	codes, hashes, err := generateRecoveryCodes(t.cfg.RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	tf.RecoveryCodes = hashes
+	if err := t.mysqlStore.SaveTwoFactor(tf); err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// Check 校验动态码或恢复码，通过的动态码和恢复码都不能再次使用；nil服务和未开启两步验证的用户返回false
+func (t *TwoFactorService) Check(userID, code string) (bool, error) {
+	if t == nil {
+		return false, nil
+	}
+	tf, err := t.get(userID)
+	if err != nil {
+		return false, err
+	}
+	if tf == nil || !tf.Enabled {
+		return false, nil
+	}
+
+	code = normalizeTwoFactorCode(code)
+	if step, ok := matchTOTP(tf.Secret, code, time.Now(), t.cfg.Skew); ok {
+		ok, err := t.mysqlStore.AdvanceTwoFactorStep(userID, step)
+		if err != nil {
+			return false, fmt.Errorf("failed to record two-factor code: %w", err)
+		}
+		return ok, nil
+	}
+	ok, err := t.mysqlStore.UseRecoveryCode(userID, hashRecoveryCode(code))
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return ok, nil
+}
+
+// Authorize 签发凭证前的两步验证
+// 已开启两步验证的账号需要有效的动态码或恢复码，必须开启但未开启的账号直接拒绝；nil服务直接通过
+func (t *TwoFactorService) Authorize(userID, code string) error {
+	if t == nil {
+		return nil
+	}
+	enabled, err := t.IsEnabled(userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		if t.Required(userID) {
+			return newServiceError(ErrCodeTwoFactorRequired, "two-factor authentication must be enabled for account %s", userID)
+		}
+		return nil
+	}
+	if code == "" {
+		return newServiceError(ErrCodeTwoFactorRequired, "two-factor code required for account %s", userID)
+	}
+	return t.verify(userID, code)
+}
+
+// verify 校验动态码或恢复码，未通过时返回业务错误
+func (t *TwoFactorService) verify(userID, code string) error {
+	ok, err := t.Check(userID, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return newServiceError(ErrCodeTwoFactorFailed, "invalid two-factor code")
+	}
+	return nil
+}
+
+// get 获取用户的两步验证设置，未绑定时返回nil
+func (t *TwoFactorService) get(userID string) (*model.TwoFactor, error) {
+	tf, err := t.mysqlStore.GetTwoFactor(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor settings: %w", err)
+	}
+	return tf, nil
+}
+
+// generateTOTPSecret 生成随机共享密钥
+func generateTOTPSecret() (string, error) {
+	buf := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate two-factor secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// totpCode 计算时间步对应的动态码
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// matchTOTP 在允许的时钟偏差内查找与动态码匹配的时间步
+func matchTOTP(secret, code string, now time.Time, skew int) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for i := -skew; i <= skew; i++ {
+		step := current + int64(i)
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpURI 验证器应用扫码绑定的otpauth地址
+func totpURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + query.Encode()
+}
+
+// generateRecoveryCodes 生成恢复码明文和对应的摘要，明文形如 1a2b3-c4d5e
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+	buf := make([]byte, recoveryCodeBytes)
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := hex.EncodeToString(buf)
+		codes = append(codes, raw[:len(raw)/2]+"-"+raw[len(raw)/2:])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeTwoFactorCode 去掉用户输入中的空格和连字符，恢复码不区分大小写
+func normalizeTwoFactorCode(code string) string {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code))
+	return strings.ToLower(code)
+}
+
+// hashRecoveryCode 计算恢复码的摘要
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeTwoFactorCode(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 附录B的SHA-1测试向量，取低6位
+func TestTOTPCode_RFCVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		assert.Equal(t, want, totpCode(key, unix/totpPeriod), "time %d", unix)
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+	current := now.Unix() / totpPeriod
+
+	step, ok := matchTOTP(secret, "081804", now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, current, step)
+
+	// 前一个时间步的动态码在允许的偏差内
+	key := []byte("12345678901234567890")
+	step, ok = matchTOTP(secret, totpCode(key, current-1), now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, current-1, step)
+
+	_, ok = matchTOTP(secret, totpCode(key, current-2), now, 1)
+	assert.False(t, ok)
+	_, ok = matchTOTP(secret, "08180", now, 1)
+	assert.False(t, ok)
+	_, ok = matchTOTP("not base32!", "081804", now, 1)
+	assert.False(t, ok)
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	assert.Len(t, hashes, 10)
+	for i, code := range codes {
+		assert.Len(t, code, 11)
+		assert.Equal(t, hashes[i], hashRecoveryCode(code))
+		// 用户输入时可以省略连字符、使用大写
+		assert.Equal(t, hashes[i], hashRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", ""))))
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := totpURI("IM", "u 1", "ABC")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/IM:u%201?"))
+	assert.Contains(t, uri, "secret=ABC")
+	assert.Contains(t, uri, "issuer=IM")
+}
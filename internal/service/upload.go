@@ -0,0 +1,542 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+	"github.com/user/im/pkg/s3"
+)
+
+const (
+	// uploadLockTTL 单次分片上传、合并或取消持有会话锁的最长时间
+	uploadLockTTL = 5 * time.Minute
+	// uploadCleanupBatch 每轮清理的最大会话数
+	uploadCleanupBatch = 100
+	// quarantineDir 需要扫描或处理图片时分段上传的目标目录，位于对象键前缀下，不能通过文件地址访问
+	quarantineDir = "quarantine"
+)
+
+// UploadService 大文件分片上传，协议参照tus：创建会话、按偏移量顺序上传分片、完成合并
+// 会话保存在Redis中，分片直接作为S3分段上传的一段写入对象存储并在完成时由对象存储合并，
+// 任意节点都可以继续上传；超过session_ttl未继续的会话由主节点清理。
+// 设置扫描器或图片处理时文件先合并到隔离区，扫描通过、图片处理后才写入文件地址对应的位置
+type UploadService struct {
+	redisStore *store.RedisStore
+	storage    *s3.Client
+	quota      *QuotaService
+	scanner    Scanner
+	audit      *AuditService
+	images     *ImageProcessor
+	cfg        config.UploadConfig
+	onComplete []func(userID, key string, size int64)
+}
+
+// NewUploadService 创建分片上传服务
+func NewUploadService(redisStore *store.RedisStore, storage *s3.Client, cfg config.UploadConfig) *UploadService {
+	return &UploadService{
+		redisStore: redisStore,
+		storage:    storage,
+		cfg:        cfg,
+	}
+}
+
+// SetQuota 设置配额服务，创建上传时按文件大小预留媒体存储配额，未设置时不检查
+func (u *UploadService) SetQuota(quota *QuotaService) {
+	u.quota = quota
+}
+
+// SetScanner 设置上传文件扫描器和审计服务，扫描结果记录审计；未设置扫描器时不扫描
+func (u *UploadService) SetScanner(scanner Scanner, audit *AuditService) {
+	u.scanner = scanner
+	u.audit = audit
+}
+
+// SetImageProcessor 设置图片处理，按租户开关去除上传图片的元数据并按需重新压缩
+func (u *UploadService) SetImageProcessor(images *ImageProcessor) {
+	u.images = images
+}
+
+// OnComplete 注册上传完成回调，参数为上传者、对象键和文件大小
+func (u *UploadService) OnComplete(fn func(userID, key string, size int64)) {
+	u.onComplete = append(u.onComplete, fn)
+}
+
+// Create 创建上传会话，预留配额并在对象存储中开始分段上传
+func (u *UploadService) Create(userID, filename, contentType string, size int64) (*model.UploadStatus, error) {
+	filename = sanitizeFilename(filename)
+	if filename == "" {
+		return nil, newServiceError(ErrCodeInvalidRequest, "filename is required")
+	}
+	if size <= 0 || size > u.cfg.MaxFileSize {
+		return nil, newServiceError(ErrCodeInvalidRequest, "size must be between 1 and %d bytes", u.cfg.MaxFileSize)
+	}
+	if len(contentType) > 255 {
+		return nil, newServiceError(ErrCodeInvalidRequest, "content_type exceeds 255 characters")
+	}
+	processImage := u.images != nil && u.images.Applies(userID, contentType)
+	if processImage && size > u.images.MaxFileSize() {
+		return nil, newServiceError(ErrCodeInvalidRequest, "image size must not exceed %d bytes", u.images.MaxFileSize())
+	}
+
+	if err := u.quota.ReserveMedia(userID, size); err != nil {
+		return nil, err
+	}
+
+	id, err := generateUploadID()
+	if err != nil {
+		u.quota.ReleaseMedia(userID, size)
+		return nil, err
+	}
+	now := time.Now()
+	date := now.UTC().Format("2006/01/02")
+	key := path.Join(u.cfg.S3.Prefix, date, id, filename)
+	staging := key
+	if u.scanner != nil || processImage {
+		staging = path.Join(u.cfg.S3.Prefix, quarantineDir, date, id, filename)
+	}
+	storageID, err := u.storage.CreateMultipartUpload(u.cfg.S3.Bucket, staging, contentType)
+	if err != nil {
+		u.quota.ReleaseMedia(userID, size)
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	session := &model.UploadSession{
+		ID:           id,
+		UserID:       userID,
+		Filename:     filename,
+		ContentType:  contentType,
+		Size:         size,
+		ChunkSize:    u.cfg.ChunkSize,
+		ObjectKey:    key,
+		StagingKey:   staging,
+		StorageID:    storageID,
+		ProcessImage: processImage,
+		CreatedAt:    now.Unix(),
+		ExpiresAt:    now.Add(u.cfg.SessionTTL).Unix(),
+	}
+	if err := u.redisStore.SaveUploadSession(session); err != nil {
+		u.storage.AbortMultipartUpload(u.cfg.S3.Bucket, staging, storageID)
+		u.quota.ReleaseMedia(userID, size)
+		return nil, fmt.Errorf("failed to save upload session: %w", err)
+	}
+	return session.Status(), nil
+}
+
+// Status 获取上传进度，客户端断线后据此从offset继续上传
+func (u *UploadService) Status(userID, id string) (*model.UploadStatus, error) {
+	session, err := u.session(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return session.Status(), nil
+}
+
+// WriteChunk 上传offset处的分片，offset必须等于已接收的字节数
+// 除最后一片外分片大小必须等于chunk_size；checksum为tus格式的 "sha256 <base64摘要>"
+func (u *UploadService) WriteChunk(userID, id string, offset int64, checksum string, body io.Reader) (*model.UploadStatus, error) {
+	expected, err := parseUploadChecksum(checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	var status *model.UploadStatus
+	err = u.withSession(userID, id, func(session *model.UploadSession) error {
+		if offset != session.Offset {
+			return newServiceError(ErrCodeConflict, "offset %d does not match upload offset %d", offset, session.Offset)
+		}
+		if session.Offset >= session.Size {
+			return newServiceError(ErrCodeConflict, "upload has received all %d bytes", session.Size)
+		}
+
+		length := session.ChunkSize
+		if remaining := session.Size - session.Offset; remaining < length {
+			length = remaining
+		}
+		data, err := io.ReadAll(io.LimitReader(body, length+1))
+		if err != nil {
+			return newServiceError(ErrCodeInvalidRequest, "failed to read chunk: %s", err.Error())
+		}
+		if int64(len(data)) != length {
+			return newServiceError(ErrCodeInvalidRequest, "chunk at offset %d must be %d bytes", offset, length)
+		}
+		if sum := sha256.Sum256(data); subtle.ConstantTimeCompare(sum[:], expected) != 1 {
+			return newServiceError(ErrCodeChecksumMismatch, "chunk checksum mismatch")
+		}
+
+		number := int(session.Offset/session.ChunkSize) + 1
+		etag, err := u.storage.UploadPart(u.cfg.S3.Bucket, session.StagingKey, session.StorageID, number, data)
+		if err != nil {
+			return fmt.Errorf("failed to upload chunk: %w", err)
+		}
+
+		session.Parts = append(session.Parts, model.UploadPart{Number: number, ETag: etag})
+		session.Offset += length
+		session.ExpiresAt = time.Now().Add(u.cfg.SessionTTL).Unix()
+		if err := u.redisStore.SaveUploadSession(session); err != nil {
+			return fmt.Errorf("failed to save upload session: %w", err)
+		}
+		status = session.Status()
+		return nil
+	})
+	return status, err
+}
+
+// Complete 所有分片上传后由对象存储合并为一个文件，设置扫描器时扫描通过才返回文件地址
+// 合并或扫描失败时保留会话，客户端可以重试；发现威胁时删除文件并返回file_infected，
+// 需要处理的图片不是有效图片时删除文件并返回invalid_request
+func (u *UploadService) Complete(userID, id string) (*model.UploadedFile, error) {
+	var file *model.UploadedFile
+	err := u.withSession(userID, id, func(session *model.UploadSession) error {
+		if session.Offset != session.Size {
+			return newServiceError(ErrCodeInvalidRequest, "upload incomplete: received %d of %d bytes", session.Offset, session.Size)
+		}
+
+		if !session.Assembled {
+			parts := make([]s3.Part, 0, len(session.Parts))
+			for _, p := range session.Parts {
+				parts = append(parts, s3.Part{Number: p.Number, ETag: p.ETag})
+			}
+			if err := u.storage.CompleteMultipartUpload(u.cfg.S3.Bucket, session.StagingKey, session.StorageID, parts); err != nil {
+				return fmt.Errorf("failed to complete upload: %w", err)
+			}
+			session.Assembled = true
+			if err := u.redisStore.SaveUploadSession(session); err != nil {
+				return fmt.Errorf("failed to save upload session: %w", err)
+			}
+		}
+
+		var scan *model.ScanResult
+		if session.StagingKey != session.ObjectKey {
+			if u.scanner != nil {
+				var err error
+				if scan, err = u.scan(session); err != nil {
+					return err
+				}
+			}
+			if err := u.release(session, scan); err != nil {
+				return err
+			}
+		}
+		if err := u.redisStore.DeleteUploadSession(session.ID); err != nil {
+			logger.Warn("Failed to delete upload session", logger.String("upload_id", session.ID), logger.ErrorField(err))
+		}
+
+		for _, fn := range u.onComplete {
+			fn(session.UserID, session.ObjectKey, session.Size)
+		}
+
+		file = &model.UploadedFile{
+			URL:         u.fileURL(session.ObjectKey),
+			Filename:    session.Filename,
+			ContentType: session.ContentType,
+			Size:        session.Size,
+			Scan:        scan,
+		}
+		return nil
+	})
+	return file, err
+}
+
+// scan 扫描隔离区中的文件并记录审计，发现威胁时删除文件
+func (u *UploadService) scan(session *model.UploadSession) (*model.ScanResult, error) {
+	resp, err := u.storage.GetObject(u.cfg.S3.Bucket, session.StagingKey, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload for scanning: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), u.cfg.Scan.Timeout)
+	threat, err := u.scanner.Scan(ctx, session.Filename, resp.Body)
+	cancel()
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan upload: %w", err)
+	}
+
+	result := &model.ScanResult{
+		Status:    model.ScanStatusClean,
+		Engine:    u.scanner.Name(),
+		Threat:    threat,
+		ScannedAt: time.Now().Unix(),
+	}
+	if threat != "" {
+		result.Status = model.ScanStatusInfected
+	}
+	if err := u.audit.Record(session.UserID, model.AuditActionScanUpload, session.ObjectKey, map[string]string{
+		"upload_id": session.ID,
+		"filename":  session.Filename,
+		"status":    string(result.Status),
+		"engine":    result.Engine,
+		"threat":    result.Threat,
+	}); err != nil {
+		logger.Error("Failed to record audit log", logger.String("action", model.AuditActionScanUpload), logger.ErrorField(err))
+	}
+
+	if result.Status == model.ScanStatusInfected {
+		logger.Warn("Infected upload rejected",
+			logger.String("upload_id", session.ID),
+			logger.String("user_id", session.UserID),
+			logger.String("threat", threat))
+		if err := u.abort(session); err != nil {
+			logger.Warn("Failed to delete infected upload", logger.String("upload_id", session.ID), logger.ErrorField(err))
+		}
+		return nil, newServiceError(ErrCodeFileInfected, "file rejected by %s: %s", result.Engine, threat)
+	}
+	return result, nil
+}
+
+// release 把隔离区中的文件写入文件地址对应的位置，扫描结果保存为对象元数据；需要时先处理图片
+func (u *UploadService) release(session *model.UploadSession, scan *model.ScanResult) error {
+	metadata := make(map[string]string)
+	if scan != nil {
+		metadata["scan-status"] = string(scan.Status)
+		metadata["scan-engine"] = scan.Engine
+		metadata["scan-scanned-at"] = strconv.FormatInt(scan.ScannedAt, 10)
+	}
+
+	if session.ProcessImage && u.images != nil {
+		if err := u.processImage(session, metadata); err != nil {
+			return err
+		}
+	} else if err := u.storage.CopyObject(u.cfg.S3.Bucket, session.StagingKey, session.ObjectKey, session.ContentType, metadata); err != nil {
+		return fmt.Errorf("failed to release upload from quarantine: %w", err)
+	}
+	if err := u.storage.DeleteObject(u.cfg.S3.Bucket, session.StagingKey); err != nil {
+		logger.Warn("Failed to delete quarantined upload", logger.String("upload_id", session.ID), logger.ErrorField(err))
+	}
+	return nil
+}
+
+// processImage 去除隔离区中图片的元数据并按需重新压缩后写入文件地址对应的位置，
+// 处理后变小的部分退还配额；内容不是有效图片时删除文件
+func (u *UploadService) processImage(session *model.UploadSession, metadata map[string]string) error {
+	resp, err := u.storage.GetObject(u.cfg.S3.Bucket, session.StagingKey, "")
+	if err != nil {
+		return fmt.Errorf("failed to read uploaded image: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, session.Size+1))
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read uploaded image: %w", err)
+	}
+
+	processed, contentType, err := u.images.Process(data)
+	if err != nil {
+		var svcErr *ServiceError
+		if errors.As(err, &svcErr) {
+			// 内容不是有效图片，重试也不会成功
+			if err := u.abort(session); err != nil {
+				logger.Warn("Failed to delete invalid image upload", logger.String("upload_id", session.ID), logger.ErrorField(err))
+			}
+		}
+		return err
+	}
+	if err := u.storage.PutObject(u.cfg.S3.Bucket, session.ObjectKey, contentType, metadata, processed); err != nil {
+		return fmt.Errorf("failed to store processed image: %w", err)
+	}
+
+	if saved := session.Size - int64(len(processed)); saved > 0 {
+		u.quota.ReleaseMedia(session.UserID, saved)
+	}
+	session.Size = int64(len(processed))
+	session.ContentType = contentType
+	return nil
+}
+
+// Cancel 取消上传，删除已上传的分片并退还预留的配额
+func (u *UploadService) Cancel(userID, id string) error {
+	return u.withSession(userID, id, func(session *model.UploadSession) error {
+		return u.abort(session)
+	})
+}
+
+// Cleanup 清理过期的上传会话，作为主节点的后台任务定期执行
+func (u *UploadService) Cleanup(ctx context.Context, fence int64) error {
+	now := time.Now().Unix()
+	ids, err := u.redisStore.GetExpiredUploadSessions(now, uploadCleanupBatch)
+	if err != nil {
+		return fmt.Errorf("failed to get expired upload sessions: %w", err)
+	}
+
+	cleaned := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		session, err := u.redisStore.GetUploadSession(id)
+		if err != nil {
+			logger.Warn("Failed to get upload session", logger.String("upload_id", id), logger.ErrorField(err))
+			continue
+		}
+		if session == nil {
+			u.redisStore.DeleteUploadSession(id)
+			continue
+		}
+		// 正在上传的会话由本次上传延长过期时间，下一轮再检查
+		owner := fmt.Sprintf("cleanup:%d", fence)
+		locked, err := u.redisStore.LockUploadSession(id, owner, uploadLockTTL)
+		if err != nil || !locked {
+			continue
+		}
+		if session, err = u.redisStore.GetUploadSession(id); err == nil && session != nil && session.ExpiresAt <= now {
+			if err := u.abort(session); err != nil {
+				logger.Warn("Failed to clean up upload session", logger.String("upload_id", id), logger.ErrorField(err))
+			} else {
+				cleaned++
+			}
+		}
+		u.redisStore.UnlockUploadSession(id, owner)
+	}
+
+	if cleaned > 0 {
+		logger.Info("Expired upload sessions cleaned", logger.Int("sessions", cleaned))
+	}
+	return nil
+}
+
+// abort 取消对象存储中的分段上传或删除已合并的文件，删除会话并退还配额；失败时保留会话由清理任务重试
+func (u *UploadService) abort(session *model.UploadSession) error {
+	if session.Assembled {
+		if err := u.storage.DeleteObject(u.cfg.S3.Bucket, session.StagingKey); err != nil {
+			return fmt.Errorf("failed to delete upload: %w", err)
+		}
+	} else if err := u.storage.AbortMultipartUpload(u.cfg.S3.Bucket, session.StagingKey, session.StorageID); err != nil {
+		return fmt.Errorf("failed to abort upload: %w", err)
+	}
+	if err := u.redisStore.DeleteUploadSession(session.ID); err != nil {
+		return fmt.Errorf("failed to delete upload session: %w", err)
+	}
+	u.quota.ReleaseMedia(session.UserID, session.Size)
+	return nil
+}
+
+// withSession 持有会话锁执行操作，会话正被其他请求使用时返回conflict
+func (u *UploadService) withSession(userID, id string, fn func(*model.UploadSession) error) error {
+	owner, err := generateUploadID()
+	if err != nil {
+		return err
+	}
+	locked, err := u.redisStore.LockUploadSession(id, owner, uploadLockTTL)
+	if err != nil {
+		return fmt.Errorf("failed to lock upload session: %w", err)
+	}
+	if !locked {
+		return newServiceError(ErrCodeConflict, "upload %s is busy", id)
+	}
+	defer u.redisStore.UnlockUploadSession(id, owner)
+
+	session, err := u.session(userID, id)
+	if err != nil {
+		return err
+	}
+	return fn(session)
+}
+
+// session 获取用户自己的上传会话，其他用户的会话也返回not_found
+func (u *UploadService) session(userID, id string) (*model.UploadSession, error) {
+	session, err := u.redisStore.GetUploadSession(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+	if session == nil || session.UserID != userID {
+		return nil, newServiceError(ErrCodeNotFound, "upload %s not found", id)
+	}
+	return session, nil
+}
+
+// fileURL 上传完成的文件地址
+func (u *UploadService) fileURL(key string) string {
+	if u.cfg.S3.PublicURL != "" {
+		return strings.TrimRight(u.cfg.S3.PublicURL, "/") + "/" + key
+	}
+	return u.storage.ObjectURL(u.cfg.S3.Bucket, key)
+}
+
+// ObjectKey 从上传完成时返回的文件地址得到对象键，不是本服务上传的文件时返回false
+func (u *UploadService) ObjectKey(fileURL string) (string, bool) {
+	prefix := u.fileURL("")
+	key, ok := strings.CutPrefix(fileURL, prefix)
+	if !ok || key == "" || strings.Contains(key, "..") {
+		return "", false
+	}
+	if u.cfg.S3.PublicURL == "" {
+		// 路径风格地址中的对象键经过编码
+		unescaped, err := url.PathUnescape(key)
+		if err != nil {
+			return "", false
+		}
+		key = unescaped
+	}
+	// 隔离区中的文件在扫描通过前不能访问
+	if strings.HasPrefix(key, path.Join(u.cfg.S3.Prefix, quarantineDir)+"/") {
+		return "", false
+	}
+	return key, true
+}
+
+// DeleteObject 从对象存储删除已上传的文件
+func (u *UploadService) DeleteObject(key string) error {
+	return u.storage.DeleteObject(u.cfg.S3.Bucket, key)
+}
+
+// OpenObject 从对象存储读取已上传的文件，rangeHeader透传HTTP Range
+func (u *UploadService) OpenObject(key, rangeHeader string) (*http.Response, error) {
+	return u.storage.GetObject(u.cfg.S3.Bucket, key, rangeHeader)
+}
+
+// generateUploadID 生成随机的上传会话ID
+func generateUploadID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate upload id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// parseUploadChecksum 解析tus格式的分片校验和 "sha256 <base64摘要>"
+func parseUploadChecksum(raw string) ([]byte, error) {
+	algorithm, encoded, ok := strings.Cut(strings.TrimSpace(raw), " ")
+	if !ok || !strings.EqualFold(algorithm, "sha256") {
+		return nil, newServiceError(ErrCodeInvalidRequest, "Upload-Checksum must be \"sha256 <base64 digest>\"")
+	}
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(sum) != sha256.Size {
+		return nil, newServiceError(ErrCodeInvalidRequest, "invalid sha256 checksum")
+	}
+	return sum, nil
+}
+
+// sanitizeFilename 文件名作为对象键的最后一段，去掉路径和控制字符，最长255字节
+func sanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(strings.TrimSpace(name), "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	for len(name) > 255 {
+		runes := []rune(name)
+		name = string(runes[:len(runes)-1])
+	}
+	return name
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUploadChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("chunk"))
+	parsed, err := parseUploadChecksum("sha256 " + base64.StdEncoding.EncodeToString(sum[:]))
+	assert.NoError(t, err)
+	assert.Equal(t, sum[:], parsed)
+
+	for _, raw := range []string{"", "md5 abc", "sha256", "sha256 !!", "sha256 " + base64.StdEncoding.EncodeToString([]byte("short"))} {
+		_, err := parseUploadChecksum(raw)
+		assert.Equal(t, ErrCodeInvalidRequest, errorCode(err), raw)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	assert.Equal(t, "video.mp4", sanitizeFilename("video.mp4"))
+	assert.Equal(t, "passwd", sanitizeFilename("../../etc/passwd"))
+	assert.Equal(t, "a.txt", sanitizeFilename(`C:\Users\me\a.txt`))
+	assert.Equal(t, "ab.txt", sanitizeFilename("a\nb.txt"))
+	assert.Equal(t, "", sanitizeFilename(".."))
+	assert.Equal(t, "", sanitizeFilename("  "))
+	assert.LessOrEqual(t, len(sanitizeFilename(strings.Repeat("文", 200))), 255)
+}
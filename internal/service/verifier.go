@@ -0,0 +1,66 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/user/im/internal/config"
+)
+
+// HTTPVerifier 把答案转发给外部验证服务校验，适用于验证码等由第三方校验的验证方式
+type HTTPVerifier struct {
+	cfg    config.VerifierConfig
+	client *http.Client
+}
+
+// NewHTTPVerifier 创建HTTP验证服务
+func NewHTTPVerifier(cfg config.VerifierConfig) (*HTTPVerifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("challenge verifier url is required")
+	}
+	return &HTTPVerifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Method 验证方式
+func (v *HTTPVerifier) Method() string {
+	return v.cfg.Method
+}
+
+// Issue 下发配置中的参数，验证内容由客户端向验证服务获取
+func (v *HTTPVerifier) Issue(userID, challengeID string) (map[string]string, error) {
+	return v.cfg.Params, nil
+}
+
+// Verify 向验证服务提交答案
+func (v *HTTPVerifier) Verify(userID, challengeID, answer string) (bool, error) {
+	body, err := json.Marshal(map[string]string{
+		"user_id":      userID,
+		"challenge_id": challengeID,
+		"answer":       answer,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := v.client.Post(v.cfg.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to call verifier: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("verifier returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to decode verifier response: %w", err)
+	}
+	return result.Success, nil
+}
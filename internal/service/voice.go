@@ -0,0 +1,303 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// opusGranuleRate Ogg Opus的granule position固定按48kHz计数
+const opusGranuleRate = 48000
+
+// VoiceAnalyzer 语音消息处理，从媒体服务下载音频并提取时长和波形
+// 只访问配置的媒体服务主机；WAV(PCM)提取时长和波形，Ogg Opus只提取时长，其余格式跳过
+type VoiceAnalyzer struct {
+	client *http.Client
+	hosts  map[string]bool
+	cfg    config.VoiceConfig
+}
+
+// NewVoiceAnalyzer 创建语音消息处理器
+func NewVoiceAnalyzer(cfg config.VoiceConfig) *VoiceAnalyzer {
+	hosts := make(map[string]bool, len(cfg.MediaHosts))
+	for _, host := range cfg.MediaHosts {
+		hosts[strings.ToLower(host)] = true
+	}
+	a := &VoiceAnalyzer{
+		hosts: hosts,
+		cfg:   cfg,
+	}
+	a.client = &http.Client{
+		Timeout: cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxPreviewRedirects {
+				return errors.New("too many redirects")
+			}
+			return a.validateURL(req.URL)
+		},
+	}
+	return a
+}
+
+// Analyze 下载音频并提取元数据，格式无法识别时返回nil
+func (a *VoiceAnalyzer) Analyze(rawURL string) (*model.VoiceMetadata, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if err := a.validateURL(u); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download voice: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download voice: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, a.cfg.MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read voice: %w", err)
+	}
+	if int64(len(data)) > a.cfg.MaxFileSize {
+		return nil, fmt.Errorf("voice exceeds %d bytes", a.cfg.MaxFileSize)
+	}
+	return analyzeVoice(data, a.cfg.WaveformBars)
+}
+
+// validateURL 只允许http(s)访问配置的媒体服务主机
+func (a *VoiceAnalyzer) validateURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+	if u.User != nil {
+		return errors.New("url with credentials is not allowed")
+	}
+	if host := strings.ToLower(u.Hostname()); !a.hosts[host] {
+		return fmt.Errorf("host not allowed: %s", host)
+	}
+	return nil
+}
+
+// analyzeVoice 按文件头识别格式并提取元数据，格式无法识别时返回nil
+func analyzeVoice(data []byte, bars int) (*model.VoiceMetadata, error) {
+	switch {
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return parseWAV(data, bars)
+	case len(data) >= 4 && string(data[0:4]) == "OggS":
+		return parseOggOpus(data)
+	default:
+		return nil, nil
+	}
+}
+
+// parseWAV 解析PCM整数或32位浮点WAV，计算时长和按峰值降采样的波形
+func parseWAV(data []byte, bars int) (*model.VoiceMetadata, error) {
+	var (
+		format, channels, bits uint16
+		byteRate               uint32
+		samples                []byte
+		haveFormat             bool
+	)
+	for offset := 12; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := data[offset+8:]
+		// 上传被截断时data块声明的长度可能超过实际内容
+		if size > len(body) {
+			size = len(body)
+		}
+		body = body[:size]
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("invalid wav fmt chunk")
+			}
+			format = binary.LittleEndian.Uint16(body[0:2])
+			channels = binary.LittleEndian.Uint16(body[2:4])
+			byteRate = binary.LittleEndian.Uint32(body[8:12])
+			bits = binary.LittleEndian.Uint16(body[14:16])
+			haveFormat = true
+		case "data":
+			samples = body
+		}
+		// 块按偶数字节对齐
+		offset += 8 + size + size%2
+	}
+	if !haveFormat || samples == nil {
+		return nil, errors.New("wav missing fmt or data chunk")
+	}
+	if channels == 0 || byteRate == 0 {
+		return nil, errors.New("invalid wav format")
+	}
+
+	// 0xFFFE为WAVE_FORMAT_EXTENSIBLE，按位深当作整数PCM处理
+	isFloat := format == 3
+	if format != 1 && format != 3 && format != 0xFFFE {
+		return nil, fmt.Errorf("unsupported wav format %d", format)
+	}
+	if isFloat && bits != 32 || !isFloat && bits != 8 && bits != 16 && bits != 24 && bits != 32 {
+		return nil, fmt.Errorf("unsupported wav bit depth %d", bits)
+	}
+
+	frameSize := int(channels) * int(bits/8)
+	frames := len(samples) / frameSize
+	voice := &model.VoiceMetadata{
+		DurationMs: int64(len(samples)) * 1000 / int64(byteRate),
+	}
+	if frames == 0 || bars <= 0 {
+		return voice, nil
+	}
+
+	peaks := make([]float64, bars)
+	sampleSize := int(bits / 8)
+	for i := 0; i < frames; i++ {
+		bar := i * bars / frames
+		frame := samples[i*frameSize : (i+1)*frameSize]
+		for c := 0; c < int(channels); c++ {
+			amplitude := pcmAmplitude(frame[c*sampleSize:(c+1)*sampleSize], isFloat)
+			if amplitude > peaks[bar] {
+				peaks[bar] = amplitude
+			}
+		}
+	}
+	voice.Waveform = normalizeWaveform(peaks)
+	return voice, nil
+}
+
+// pcmAmplitude 单个采样的绝对振幅，范围0-1；8位PCM为无符号，其余为有符号小端
+func pcmAmplitude(sample []byte, isFloat bool) float64 {
+	switch len(sample) {
+	case 1:
+		return math.Abs(float64(int(sample[0])-128)) / 128
+	case 2:
+		return math.Abs(float64(int16(binary.LittleEndian.Uint16(sample)))) / 32768
+	case 3:
+		v := int32(sample[0]) | int32(sample[1])<<8 | int32(int8(sample[2]))<<16
+		return math.Abs(float64(v)) / 8388608
+	default:
+		if isFloat {
+			v := math.Abs(float64(math.Float32frombits(binary.LittleEndian.Uint32(sample))))
+			return math.Min(v, 1)
+		}
+		return math.Abs(float64(int32(binary.LittleEndian.Uint32(sample)))) / 2147483648
+	}
+}
+
+// normalizeWaveform 按最大峰值缩放到0-100，静音时全为0
+func normalizeWaveform(peaks []float64) []int {
+	max := 0.0
+	for _, p := range peaks {
+		max = math.Max(max, p)
+	}
+	waveform := make([]int, len(peaks))
+	if max == 0 {
+		return waveform
+	}
+	for i, p := range peaks {
+		waveform[i] = int(math.Round(p / max * 100))
+	}
+	return waveform
+}
+
+// parseOggOpus 按最后一页的granule position和OpusHead中的pre-skip计算时长，不解码音频
+func parseOggOpus(data []byte) (*model.VoiceMetadata, error) {
+	head := bytes.Index(data, []byte("OpusHead"))
+	if head < 0 || head+12 > len(data) {
+		return nil, nil
+	}
+	preSkip := int64(binary.LittleEndian.Uint16(data[head+10 : head+12]))
+
+	last := bytes.LastIndex(data, []byte("OggS"))
+	if last < 0 || last+14 > len(data) || data[last+4] != 0 {
+		return nil, errors.New("invalid ogg page")
+	}
+	granule := int64(binary.LittleEndian.Uint64(data[last+6 : last+14]))
+	if granule < preSkip {
+		return nil, errors.New("invalid ogg granule position")
+	}
+	return &model.VoiceMetadata{
+		DurationMs: (granule - preSkip) * 1000 / opusGranuleRate,
+	}, nil
+}
+
+// SetVoiceAnalyzer 设置语音消息处理，语音消息发送后投递到topic异步处理
+func (s *MessageService) SetVoiceAnalyzer(analyzer *VoiceAnalyzer, topic string) {
+	s.voice = analyzer
+	s.voiceTopic = topic
+}
+
+// requestVoiceMetadata 语音消息投递到Kafka异步提取时长和波形
+func (s *MessageService) requestVoiceMetadata(message *model.Message) {
+	if s.voice == nil || message.Type != model.MessageTypeVoice {
+		return
+	}
+	if err := s.kafkaStore.SendMessage(s.voiceTopic, message); err != nil {
+		logger.Warn("Failed to request voice processing", logger.String("message_id", message.ID), logger.ErrorField(err))
+	}
+}
+
+// ProcessVoiceMessage 提取语音消息的时长和波形，保存后推送message_enriched给会话参与者
+func (s *MessageService) ProcessVoiceMessage(message *model.Message) error {
+	if s.voice == nil || message.Type != model.MessageTypeVoice {
+		return nil
+	}
+
+	// 处理前消息可能已被删除，Kafka重投时可能已处理过
+	current, err := s.storeBackend.GetMessage(message.ID)
+	if err != nil || current.IsDeleted() || current.Voice != nil {
+		return nil
+	}
+
+	voice, err := s.voice.Analyze(current.Content)
+	if err != nil {
+		// 音频无法下载或解析时不重试，客户端仍可下载音频播放
+		logger.Warn("Failed to analyze voice message", logger.String("message_id", message.ID), logger.ErrorField(err))
+		return nil
+	}
+	if voice == nil {
+		return nil
+	}
+
+	if err := s.storeBackend.SetMessageVoice(message.ID, voice); err != nil {
+		return fmt.Errorf("failed to save voice metadata: %w", err)
+	}
+	s.lookup.invalidate(message.ID)
+	logger.Debug("Voice message processed",
+		logger.String("message_id", message.ID),
+		logger.Int64("duration_ms", voice.DurationMs))
+
+	return s.notifyParticipants(current, model.WebSocketMessage{
+		Type: model.FrameMessageEnriched,
+		Data: model.MessageEnrichedEvent{
+			MessageID:  message.ID,
+			GroupID:    message.GroupID,
+			ReceiverID: message.ReceiverID,
+			Voice:      voice,
+		},
+		Timestamp: time.Now().Unix(),
+		MessageID: message.ID,
+	})
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/ahocorasick"
+	"github.com/user/im/pkg/logger"
+)
+
+// strictWordListSuffix 只在严格级别使用的词库文件后缀
+const strictWordListSuffix = ".strict.txt"
+
+// WordFilter 敏感词过滤
+// 词库按语言从配置目录加载，标准级别和严格级别各构建一个Aho-Corasick自动机，消息不区分语言同时匹配所有语言的词；
+// 管理接口重新加载后经Redis频道通知其他节点从各自的词库目录重新加载
+type WordFilter struct {
+	redisStore *store.RedisStore
+	cfg        config.WordFilterConfig
+
+	mu       sync.RWMutex
+	standard *ahocorasick.Matcher
+	strict   *ahocorasick.Matcher
+	status   *model.WordFilterStatus
+}
+
+// NewWordFilter 创建敏感词过滤器，Start加载词库前不过滤
+func NewWordFilter(redisStore *store.RedisStore, cfg config.WordFilterConfig) *WordFilter {
+	return &WordFilter{
+		redisStore: redisStore,
+		cfg:        cfg,
+		standard:   ahocorasick.New(nil),
+		strict:     ahocorasick.New(nil),
+		status:     &model.WordFilterStatus{},
+	}
+}
+
+// Start 加载词库，之后在收到重新加载通知时重新加载；首次加载失败时返回错误
+func (w *WordFilter) Start() error {
+	if _, err := w.load(); err != nil {
+		return err
+	}
+
+	go func() {
+		pubsub := w.redisStore.Subscribe(store.WordFilterChannel)
+		defer pubsub.Close()
+		for range pubsub.Channel() {
+			if _, err := w.load(); err != nil {
+				logger.Warn("Failed to reload word lists", logger.ErrorField(err))
+			}
+		}
+	}()
+	return nil
+}
+
+// Reload 重新加载本节点的词库并通知其他节点，词库有错误时保留当前词库
+func (w *WordFilter) Reload() (*model.WordFilterStatus, error) {
+	status, err := w.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load word lists: %w", err)
+	}
+	if err := w.redisStore.PublishMessage(store.WordFilterChannel, status.LoadedAt); err != nil {
+		logger.Warn("Failed to publish word filter reload", logger.ErrorField(err))
+	}
+	return status, nil
+}
+
+// Status 当前加载的词库
+func (w *WordFilter) Status() *model.WordFilterStatus {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.status
+}
+
+// Filter 按过滤级别检查文本消息内容，mask动作返回把敏感词替换为*的内容，reject动作命中时返回错误；未设置过滤器时原样返回
+func (w *WordFilter) Filter(content string, level model.WordFilterLevel) (string, error) {
+	if w == nil {
+		return content, nil
+	}
+	w.mu.RLock()
+	matcher := w.standard
+	if level == model.WordFilterStrict {
+		matcher = w.strict
+	}
+	w.mu.RUnlock()
+
+	matches := matcher.FindAll(content)
+	if len(matches) == 0 {
+		return content, nil
+	}
+	metrics.WordFilterHits.WithLabelValues(string(level), w.cfg.Action).Inc()
+	if w.cfg.Action == config.WordFilterReject {
+		return "", newServiceError(ErrCodeContentBlocked, "message contains blocked words")
+	}
+	return maskMatches(content, matches), nil
+}
+
+// maskMatches 把匹配到的每个字符替换为*
+func maskMatches(content string, matches []ahocorasick.Match) string {
+	masked := make([]bool, len(content))
+	for _, m := range matches {
+		for i := m.Start; i < m.End; i++ {
+			masked[i] = true
+		}
+	}
+	var b strings.Builder
+	b.Grow(len(content))
+	for i, r := range content {
+		if masked[i] {
+			b.WriteByte('*')
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// load 读取词库目录并替换当前的自动机，任一文件读取失败时保留当前词库
+func (w *WordFilter) load() (*model.WordFilterStatus, error) {
+	languages := w.cfg.Languages
+	if len(languages) == 0 {
+		entries, err := os.ReadDir(w.cfg.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read word list directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				languages = append(languages, entry.Name())
+			}
+		}
+	}
+
+	status := &model.WordFilterStatus{Packs: []model.WordPack{}, LoadedAt: time.Now().Unix()}
+	var standardWords, strictWords []string
+	for _, language := range languages {
+		dir := filepath.Join(w.cfg.Dir, language)
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("failed to read word lists of %s: %w", language, err)
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list word lists of %s: %w", language, err)
+		}
+
+		standard := model.WordPack{Language: language, Level: model.WordFilterStandard}
+		strict := model.WordPack{Language: language, Level: model.WordFilterStrict}
+		for _, file := range files {
+			words, err := readListFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read word list %s: %w", file, err)
+			}
+			pack := &standard
+			if strings.HasSuffix(file, strictWordListSuffix) {
+				pack = &strict
+				strictWords = append(strictWords, words...)
+			} else {
+				standardWords = append(standardWords, words...)
+			}
+			pack.Files = append(pack.Files, filepath.Base(file))
+			pack.Words += len(words)
+		}
+		for _, pack := range []model.WordPack{standard, strict} {
+			if len(pack.Files) > 0 {
+				status.Packs = append(status.Packs, pack)
+			}
+		}
+	}
+
+	standard := ahocorasick.New(standardWords)
+	// 严格级别同时包含标准词库
+	strict := ahocorasick.New(append(strictWords, standardWords...))
+
+	w.mu.Lock()
+	w.standard = standard
+	w.strict = strict
+	w.status = status
+	w.mu.Unlock()
+
+	logger.Info("Word lists loaded",
+		logger.Int("packs", len(status.Packs)),
+		logger.Int("standard_words", standard.Len()),
+		logger.Int("strict_words", strict.Len()))
+	return status, nil
+}
+
+// readListFile 读取词库或黑名单文件，每行一项，忽略空行和#开头的注释
+func readListFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var items []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		item := strings.TrimSpace(scanner.Text())
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, scanner.Err()
+}
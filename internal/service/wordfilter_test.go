@@ -0,0 +1,59 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+func writeWordList(t *testing.T, dir, language, name, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, language), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, language, name), []byte(content), 0o644))
+}
+
+func TestWordFilter_LevelsAndMask(t *testing.T) {
+	dir := t.TempDir()
+	writeWordList(t, dir, "zh-CN", "base.txt", "# 注释\n赌博\n\n")
+	writeWordList(t, dir, "zh-CN", "public.strict.txt", "代购\n")
+	writeWordList(t, dir, "en", "base.txt", "Casino\n")
+
+	w := NewWordFilter(nil, config.WordFilterConfig{Dir: dir, Action: config.WordFilterMask})
+	status, err := w.load()
+	assert.NoError(t, err)
+	assert.Len(t, status.Packs, 3)
+
+	content, err := w.Filter("去casino赌博，找代购", model.WordFilterStandard)
+	assert.NoError(t, err)
+	assert.Equal(t, "去********，找代购", content)
+
+	content, err = w.Filter("去casino赌博，找代购", model.WordFilterStrict)
+	assert.NoError(t, err)
+	assert.Equal(t, "去********，找**", content)
+}
+
+func TestWordFilter_Reject(t *testing.T) {
+	dir := t.TempDir()
+	writeWordList(t, dir, "en", "base.txt", "casino\n")
+
+	w := NewWordFilter(nil, config.WordFilterConfig{Dir: dir, Languages: []string{"en"}, Action: config.WordFilterReject})
+	_, err := w.load()
+	assert.NoError(t, err)
+
+	_, err = w.Filter("CASINO night", model.WordFilterStandard)
+	assert.Equal(t, ErrCodeContentBlocked, errorCode(err))
+
+	// 配置的语言目录不存在时保留当前词库
+	w.cfg.Languages = []string{"en", "fr"}
+	_, err = w.load()
+	assert.Error(t, err)
+	assert.Len(t, w.Status().Packs, 1)
+
+	var nilFilter *WordFilter
+	content, err := nilFilter.Filter("casino", model.WordFilterStrict)
+	assert.NoError(t, err)
+	assert.Equal(t, "casino", content)
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+// adminRolesKey 通过管理接口分配的管理角色，字段为用户ID，值为分配记录的JSON
+const adminRolesKey = "admin:roles"
+
+// SetAdminRoles 保存用户的管理角色，整体替换
+func (s *RedisStore) SetAdminRoles(assignment *model.AdminRoleAssignment) error {
+	data, err := json.Marshal(assignment)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(s.ctx, adminRolesKey, assignment.UserID, data).Err()
+}
+
+// GetAdminRoles 获取用户的管理角色，未分配时返回false
+func (s *RedisStore) GetAdminRoles(userID string) (*model.AdminRoleAssignment, bool, error) {
+	value, err := s.client.HGet(s.ctx, adminRolesKey, userID).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var assignment model.AdminRoleAssignment
+	if err := json.Unmarshal([]byte(value), &assignment); err != nil {
+		return nil, false, err
+	}
+	assignment.UserID = userID
+	return &assignment, true, nil
+}
+
+// GetAllAdminRoles 获取全部管理角色分配，跳过无法解析的记录
+func (s *RedisStore) GetAllAdminRoles() ([]*model.AdminRoleAssignment, error) {
+	values, err := s.client.HGetAll(s.ctx, adminRolesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	assignments := make([]*model.AdminRoleAssignment, 0, len(values))
+	for userID, value := range values {
+		var assignment model.AdminRoleAssignment
+		if err := json.Unmarshal([]byte(value), &assignment); err != nil {
+			continue
+		}
+		assignment.UserID = userID
+		assignments = append(assignments, &assignment)
+	}
+	return assignments, nil
+}
+
+// DeleteAdminRoles 撤销用户的管理角色，返回是否存在
+func (s *RedisStore) DeleteAdminRoles(userID string) (bool, error) {
+	n, err := s.client.HDel(s.ctx, adminRolesKey, userID).Result()
+	return n > 0, err
+}
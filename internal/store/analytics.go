@@ -0,0 +1,194 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm/clause"
+)
+
+// 统计计数器字段
+const (
+	analyticsFieldMessages   = "messages"
+	analyticsFieldDeliveries = "deliveries"
+	analyticsFieldLatency    = "latency_ms"
+	analyticsFieldPeak       = "peak_connections"
+)
+
+// analyticsKey 某日的统计键
+func analyticsKey(day, name string) string {
+	return fmt.Sprintf("analytics:%s:%s", day, name)
+}
+
+// analyticsConnectionsKey 各节点上报的当前连接数
+const analyticsConnectionsKey = "analytics:connections"
+
+// DailyCounters Redis中某日尚未汇总的计数
+type DailyCounters struct {
+	Messages        int64
+	ActiveUsers     int64
+	PeakConnections int64
+	Deliveries      int64
+	LatencyMs       int64 // 投递耗时之和
+	Groups          map[string]int64
+}
+
+// connectionReport 节点上报的连接数
+type connectionReport struct {
+	Count int   `json:"count"`
+	At    int64 `json:"at"`
+}
+
+// IncrMessageStats 累加某日的消息数，群消息同时累加群组活跃度
+func (s *RedisStore) IncrMessageStats(day, groupID string, ttl time.Duration) error {
+	pipe := s.client.TxPipeline()
+	counters := analyticsKey(day, "counters")
+	pipe.HIncrBy(s.ctx, counters, analyticsFieldMessages, 1)
+	pipe.Expire(s.ctx, counters, ttl)
+	if groupID != "" {
+		groups := analyticsKey(day, "groups")
+		pipe.HIncrBy(s.ctx, groups, groupID, 1)
+		pipe.Expire(s.ctx, groups, ttl)
+	}
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// AddActiveUser 记录某日的活跃用户
+func (s *RedisStore) AddActiveUser(day, userID string, ttl time.Duration) error {
+	key := analyticsKey(day, "dau")
+	pipe := s.client.TxPipeline()
+	pipe.PFAdd(s.ctx, key, userID)
+	pipe.Expire(s.ctx, key, ttl)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// IncrDeliveryStats 累加某日的投递数和投递耗时
+func (s *RedisStore) IncrDeliveryStats(day string, latency time.Duration, ttl time.Duration) error {
+	key := analyticsKey(day, "counters")
+	pipe := s.client.TxPipeline()
+	pipe.HIncrBy(s.ctx, key, analyticsFieldDeliveries, 1)
+	pipe.HIncrBy(s.ctx, key, analyticsFieldLatency, latency.Milliseconds())
+	pipe.Expire(s.ctx, key, ttl)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// ReportConnections 上报本节点的当前连接数
+func (s *RedisStore) ReportConnections(nodeID string, count int) error {
+	data, err := json.Marshal(connectionReport{Count: count, At: time.Now().Unix()})
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(s.ctx, analyticsConnectionsKey, nodeID, data).Err()
+}
+
+// SumConnections 汇总各节点最近上报的连接数，超过maxAge未上报的节点视为下线并清除
+func (s *RedisStore) SumConnections(maxAge time.Duration) (int64, error) {
+	reports, err := s.client.HGetAll(s.ctx, analyticsConnectionsKey).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-maxAge).Unix()
+	var total int64
+	for nodeID, raw := range reports {
+		var report connectionReport
+		if err := json.Unmarshal([]byte(raw), &report); err != nil || report.At < cutoff {
+			s.client.HDel(s.ctx, analyticsConnectionsKey, nodeID)
+			continue
+		}
+		total += int64(report.Count)
+	}
+	return total, nil
+}
+
+// UpdatePeakConnections 当前连接数超过某日峰值时更新峰值，只由主节点调用
+func (s *RedisStore) UpdatePeakConnections(day string, current int64, ttl time.Duration) error {
+	key := analyticsKey(day, "counters")
+	peak, err := s.client.HGet(s.ctx, key, analyticsFieldPeak).Int64()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if current <= peak {
+		return nil
+	}
+	pipe := s.client.TxPipeline()
+	pipe.HSet(s.ctx, key, analyticsFieldPeak, current)
+	pipe.Expire(s.ctx, key, ttl)
+	_, err = pipe.Exec(s.ctx)
+	return err
+}
+
+// GetDailyCounters 读取某日的统计计数
+func (s *RedisStore) GetDailyCounters(day string) (*DailyCounters, error) {
+	counters, err := s.client.HGetAll(s.ctx, analyticsKey(day, "counters")).Result()
+	if err != nil {
+		return nil, err
+	}
+	activeUsers, err := s.client.PFCount(s.ctx, analyticsKey(day, "dau")).Result()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.client.HGetAll(s.ctx, analyticsKey(day, "groups")).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	parse := func(raw string) int64 {
+		n, _ := strconv.ParseInt(raw, 10, 64)
+		return n
+	}
+	result := &DailyCounters{
+		Messages:        parse(counters[analyticsFieldMessages]),
+		ActiveUsers:     activeUsers,
+		PeakConnections: parse(counters[analyticsFieldPeak]),
+		Deliveries:      parse(counters[analyticsFieldDeliveries]),
+		LatencyMs:       parse(counters[analyticsFieldLatency]),
+		Groups:          make(map[string]int64, len(groups)),
+	}
+	for groupID, raw := range groups {
+		result.Groups[groupID] = parse(raw)
+	}
+	return result, nil
+}
+
+// SaveDailyStats 保存每日统计及群组活跃度，已存在时覆盖
+func (s *MySQLStore) SaveDailyStats(stats *model.DailyStats, groups []*model.GroupDailyStats) error {
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "day"}},
+		DoUpdates: clause.AssignmentColumns([]string{"messages", "active_users", "peak_connections", "deliveries", "avg_delivery_latency_ms", "updated_at"}),
+	}).Create(stats).Error
+	if err != nil || len(groups) == 0 {
+		return err
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "day"}, {Name: "group_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"messages"}),
+	}).CreateInBatches(groups, 500).Error
+}
+
+// ListDailyStats 获取日期区间内的每日统计，按日期升序
+func (s *MySQLStore) ListDailyStats(from, to string) ([]*model.DailyStats, error) {
+	var stats []*model.DailyStats
+	err := s.db.Where("day BETWEEN ? AND ?", from, to).Order("day ASC").Find(&stats).Error
+	return stats, err
+}
+
+// TopGroupActivity 获取日期区间内消息最多的群组
+func (s *MySQLStore) TopGroupActivity(from, to string, limit int) ([]*model.GroupActivity, error) {
+	var groups []*model.GroupActivity
+	err := s.db.Model(&model.GroupDailyStats{}).
+		Select("group_id, SUM(messages) AS messages").
+		Where("day BETWEEN ? AND ?", from, to).
+		Group("group_id").
+		Order("messages DESC").
+		Limit(limit).
+		Scan(&groups).Error
+	return groups, err
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"github.com/user/im/internal/model"
+)
+
+// CreateAPIKey 保存新签发的API密钥
+func (s *MySQLStore) CreateAPIKey(key *model.APIKey) error {
+	return s.db.Create(key).Error
+}
+
+// GetAPIKey 按ID获取API密钥
+func (s *MySQLStore) GetAPIKey(id string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := s.db.Where("id = ?", id).First(&key).Error
+	return &key, err
+}
+
+// GetAPIKeyByHash 按密钥摘要获取API密钥
+func (s *MySQLStore) GetAPIKeyByHash(hash string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := s.db.Where("key_hash = ?", hash).First(&key).Error
+	return &key, err
+}
+
+// ListAPIKeys 列出所有API密钥，按创建时间倒序
+func (s *MySQLStore) ListAPIKeys() ([]*model.APIKey, error) {
+	var keys []*model.APIKey
+	err := s.db.Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKey 吊销API密钥
+func (s *MySQLStore) RevokeAPIKey(id string, revokedAt int64) error {
+	return s.db.Model(&model.APIKey{}).Where("id = ? AND revoked_at = 0", id).Update("revoked_at", revokedAt).Error
+}
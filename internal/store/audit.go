@@ -0,0 +1,48 @@
+package store
+
+import (
+	"github.com/user/im/internal/model"
+)
+
+// AuditFilter 审计记录查询条件，空字段不过滤
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	Role   string // 操作人的管理角色，匹配角色列表中的任一项
+	Limit  int
+}
+
+// SaveAuditLog 保存审计记录
+func (s *MySQLStore) SaveAuditLog(entry *model.AuditLog) error {
+	return s.db.Create(entry).Error
+}
+
+// ListAuditLogs 按时间倒序查询审计记录
+func (s *MySQLStore) ListAuditLogs(filter AuditFilter) ([]*model.AuditLog, error) {
+	query := s.db.Model(&model.AuditLog{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Target != "" {
+		query = query.Where("target = ?", filter.Target)
+	}
+	if filter.Role != "" {
+		query = query.Where("FIND_IN_SET(?, role) > 0", filter.Role)
+	}
+
+	var entries []*model.AuditLog
+	err := query.Order("created_at DESC").Limit(filter.Limit).Find(&entries).Error
+	return entries, err
+}
+
+// GetPendingMessages 获取用户尚未投递的私聊消息
+func (s *MySQLStore) GetPendingMessages(userID string, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	err := s.db.Where("receiver_id = ? AND group_id = '' AND status = ? AND deleted_at = 0", userID, model.MessageStatusSent).
+		Order("timestamp ASC").Limit(limit).Find(&messages).Error
+	return messages, err
+}
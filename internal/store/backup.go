@@ -0,0 +1,171 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// backupBatchSize 备份与恢复时每批读写的行数
+const backupBatchSize = 500
+
+// BackupTables 参与备份的MySQL表，按恢复顺序排列
+// API密钥只保存摘要且不对外序列化，不参与备份，恢复后需重新签发；两步验证的共享密钥同样不参与备份，恢复后需重新绑定
+var BackupTables = []string{"groups", "group_members", "user_sanctions", "messages", "message_deletions", "message_receipts", "user_conversation_settings", "user_profiles", "audit_logs", "daily_stats", "group_daily_stats", "quota_usages", "departments", "department_members"}
+
+// SnapshotEach 在一致性快照上遍历所有键值，fn不能持有key和value
+func (s *LevelDBStore) SnapshotEach(fn func(key, value []byte) error) error {
+	snap, err := s.db.GetSnapshot()
+	if err != nil {
+		return fmt.Errorf("failed to get leveldb snapshot: %w", err)
+	}
+	defer snap.Release()
+
+	iter := snap.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := fn(iter.Key(), iter.Value()); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// WriteBatch 批量写入键值
+func (s *LevelDBStore) WriteBatch(keys, values [][]byte) error {
+	defer s.observe("write_batch", time.Now())
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	batch := new(leveldb.Batch)
+	for i := range keys {
+		batch.Put(keys[i], values[i])
+	}
+	return s.db.Write(batch, nil)
+}
+
+// ExportTables 在只读一致性事务中逐表导出数据，since大于0时消息表只导出该时间戳（含）之后发送或删除的消息
+func (s *MySQLStore) ExportTables(since int64, fn func(table string, row interface{}) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := exportTable[model.Group](tx, "groups", fn); err != nil {
+			return err
+		}
+		if err := exportTable[model.GroupMember](tx, "group_members", fn); err != nil {
+			return err
+		}
+		if err := exportTable[model.UserSanction](tx, "user_sanctions", fn); err != nil {
+			return err
+		}
+
+		messages := tx
+		if since > 0 {
+			messages = tx.Where("timestamp >= ? OR deleted_at >= ?", since, since)
+		}
+		if err := exportTable[model.Message](messages, "messages", fn); err != nil {
+			return err
+		}
+		if err := exportTable[model.MessageDeletion](tx, "message_deletions", fn); err != nil {
+			return err
+		}
+		if err := exportTable[model.MessageReceipt](tx, "message_receipts", fn); err != nil {
+			return err
+		}
+		if err := exportTable[model.UserConversationSettings](tx, "user_conversation_settings", fn); err != nil {
+			return err
+		}
+		if err := exportTable[model.UserProfile](tx, "user_profiles", fn); err != nil {
+			return err
+		}
+		if err := exportTable[model.AuditLog](tx, "audit_logs", fn); err != nil {
+			return err
+		}
+		if err := exportTable[model.DailyStats](tx, "daily_stats", fn); err != nil {
+			return err
+		}
+		if err := exportTable[model.GroupDailyStats](tx, "group_daily_stats", fn); err != nil {
+			return err
+		}
+		if err := exportTable[model.QuotaUsage](tx, "quota_usages", fn); err != nil {
+			return err
+		}
+		if err := exportTable[model.Department](tx, "departments", fn); err != nil {
+			return err
+		}
+		return exportTable[model.DepartmentMember](tx, "department_members", fn)
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+// exportTable 分批读取一张表
+func exportTable[T any](tx *gorm.DB, table string, fn func(table string, row interface{}) error) error {
+	var rows []*T
+	err := tx.FindInBatches(&rows, backupBatchSize, func(_ *gorm.DB, _ int) error {
+		for _, row := range rows {
+			if err := fn(table, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to export %s: %w", table, err)
+	}
+	return nil
+}
+
+// RestoreRows 写入一张表的备份行，主键冲突时以备份数据覆盖
+func (s *MySQLStore) RestoreRows(table string, rows []json.RawMessage) error {
+	switch table {
+	case "groups":
+		return restoreTable[model.Group](s.db, rows)
+	case "group_members":
+		return restoreTable[model.GroupMember](s.db, rows)
+	case "user_sanctions":
+		return restoreTable[model.UserSanction](s.db, rows)
+	case "messages":
+		return restoreTable[model.Message](s.db, rows)
+	case "message_deletions":
+		return restoreTable[model.MessageDeletion](s.db, rows)
+	case "message_receipts":
+		return restoreTable[model.MessageReceipt](s.db, rows)
+	case "user_conversation_settings":
+		return restoreTable[model.UserConversationSettings](s.db, rows)
+	case "user_profiles":
+		return restoreTable[model.UserProfile](s.db, rows)
+	case "audit_logs":
+		return restoreTable[model.AuditLog](s.db, rows)
+	case "daily_stats":
+		return restoreTable[model.DailyStats](s.db, rows)
+	case "group_daily_stats":
+		return restoreTable[model.GroupDailyStats](s.db, rows)
+	case "quota_usages":
+		return restoreTable[model.QuotaUsage](s.db, rows)
+	case "departments":
+		return restoreTable[model.Department](s.db, rows)
+	case "department_members":
+		return restoreTable[model.DepartmentMember](s.db, rows)
+	default:
+		return fmt.Errorf("unknown backup table: %s", table)
+	}
+}
+
+// restoreTable 解码并写入备份行
+func restoreTable[T any](db *gorm.DB, raw []json.RawMessage) error {
+	rows := make([]*T, 0, len(raw))
+	for _, r := range raw {
+		row := new(T)
+		if err := json.Unmarshal(r, row); err != nil {
+			return fmt.Errorf("failed to decode backup row: %w", err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(rows, backupBatchSize).Error
+}
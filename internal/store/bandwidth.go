@@ -0,0 +1,105 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+// bandwidthBucket 用户流量的统计粒度
+const bandwidthBucket = time.Minute
+
+// userTrafficKey 用户在一分钟内收发的字节数，字段为in和out
+func userTrafficKey(userID string, minute int64) string {
+	return fmt.Sprintf("bandwidth:user:%s:%d", userID, minute)
+}
+
+// connectionTrafficKey 用户当前连接的流量
+func connectionTrafficKey(userID string) string {
+	return fmt.Sprintf("bandwidth:conn:%s", userID)
+}
+
+// AddUserTraffic 把用户新增的流量累加到当前分钟，返回窗口内的合计
+func (s *RedisStore) AddUserTraffic(userID string, bytesIn, bytesOut int64, now time.Time, window time.Duration) (int64, int64, error) {
+	minute := now.Unix() / int64(bandwidthBucket/time.Second)
+	key := userTrafficKey(userID, minute)
+
+	pipe := s.client.Pipeline()
+	if bytesIn > 0 {
+		pipe.HIncrBy(s.ctx, key, "in", bytesIn)
+	}
+	if bytesOut > 0 {
+		pipe.HIncrBy(s.ctx, key, "out", bytesOut)
+	}
+	pipe.Expire(s.ctx, key, window+bandwidthBucket)
+	cmds := s.userTrafficCmds(pipe, userID, minute, window)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return 0, 0, err
+	}
+	in, out := sumUserTraffic(cmds)
+	return in, out, nil
+}
+
+// GetUserTraffic 获取用户在窗口内收发的字节数
+func (s *RedisStore) GetUserTraffic(userID string, now time.Time, window time.Duration) (int64, int64, error) {
+	minute := now.Unix() / int64(bandwidthBucket/time.Second)
+	pipe := s.client.Pipeline()
+	cmds := s.userTrafficCmds(pipe, userID, minute, window)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return 0, 0, err
+	}
+	in, out := sumUserTraffic(cmds)
+	return in, out, nil
+}
+
+// SetConnectionTraffic 保存用户当前连接的流量
+func (s *RedisStore) SetConnectionTraffic(userID string, traffic *model.ConnectionTraffic, ttl time.Duration) error {
+	data, err := json.Marshal(traffic)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(s.ctx, connectionTrafficKey(userID), data, ttl).Err()
+}
+
+// GetConnectionTraffic 获取用户当前连接的流量，用户不在线时返回nil
+func (s *RedisStore) GetConnectionTraffic(userID string) (*model.ConnectionTraffic, error) {
+	data, err := s.client.Get(s.ctx, connectionTrafficKey(userID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var traffic model.ConnectionTraffic
+	if err := json.Unmarshal(data, &traffic); err != nil {
+		return nil, err
+	}
+	return &traffic, nil
+}
+
+// userTrafficCmds 在管道中读取窗口内每分钟的流量
+func (s *RedisStore) userTrafficCmds(pipe redis.Pipeliner, userID string, minute int64, window time.Duration) []*redis.MapStringStringCmd {
+	buckets := int64(window / bandwidthBucket)
+	cmds := make([]*redis.MapStringStringCmd, 0, buckets)
+	for i := int64(0); i < buckets; i++ {
+		cmds = append(cmds, pipe.HGetAll(s.ctx, userTrafficKey(userID, minute-i)))
+	}
+	return cmds
+}
+
+// sumUserTraffic 合计各分钟的流量
+func sumUserTraffic(cmds []*redis.MapStringStringCmd) (int64, int64) {
+	var in, out int64
+	for _, cmd := range cmds {
+		values := cmd.Val()
+		n, _ := strconv.ParseInt(values["in"], 10, 64)
+		in += n
+		n, _ = strconv.ParseInt(values["out"], 10, 64)
+		out += n
+	}
+	return in, out
+}
@@ -0,0 +1,6 @@
+package store
+
+// CanaryChannel 探测消息送达通知频道，探测用户收到消息后通知发起探测的节点
+func CanaryChannel(nodeID string) string {
+	return "canary:" + nodeID
+}
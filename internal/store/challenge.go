@@ -0,0 +1,157 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+// loginDevicesKey 用户成功登录过的设备
+func loginDevicesKey(userID string) string {
+	return fmt.Sprintf("login:devices:%s", userID)
+}
+
+// loginNetworksKey 用户近期登录使用过的IP网段
+func loginNetworksKey(userID string) string {
+	return fmt.Sprintf("login:networks:%s", userID)
+}
+
+// loginFailuresKey 用户近期登录验证失败次数
+func loginFailuresKey(userID string) string {
+	return fmt.Sprintf("login:failures:%s", userID)
+}
+
+// trustedDeviceKey 设备信任令牌的摘要
+func trustedDeviceKey(userID, deviceID string) string {
+	return fmt.Sprintf("login:trusted:%s:%s", userID, deviceID)
+}
+
+// challengeKey 等待验证的登录
+func challengeKey(challengeID string) string {
+	return fmt.Sprintf("login:challenge:%s", challengeID)
+}
+
+// LoginHistory 用户的登录记录，用于评估登录风险
+type LoginHistory struct {
+	Devices     int64 // 成功登录过的设备数
+	Networks    int64 // 近期登录过的网段数
+	KnownDevice bool
+	KnownIP     bool
+	Failures    int64
+}
+
+// GetLoginHistory 获取用户的登录记录，以及设备和网段是否在记录中
+func (s *RedisStore) GetLoginHistory(userID, deviceID, network string) (*LoginHistory, error) {
+	pipe := s.client.Pipeline()
+	devices := pipe.SCard(s.ctx, loginDevicesKey(userID))
+	networks := pipe.SCard(s.ctx, loginNetworksKey(userID))
+	knownDevice := pipe.SIsMember(s.ctx, loginDevicesKey(userID), deviceID)
+	knownIP := pipe.SIsMember(s.ctx, loginNetworksKey(userID), network)
+	failures := pipe.Get(s.ctx, loginFailuresKey(userID))
+	if _, err := pipe.Exec(s.ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	history := &LoginHistory{
+		Devices:     devices.Val(),
+		Networks:    networks.Val(),
+		KnownDevice: deviceID != "" && knownDevice.Val(),
+		KnownIP:     knownIP.Val(),
+	}
+	history.Failures, _ = failures.Int64()
+	return history, nil
+}
+
+// RecordLoginSuccess 记录成功登录的设备和网段并清除失败计数，记录在ttl内没有新登录时过期
+func (s *RedisStore) RecordLoginSuccess(userID, deviceID, network string, ttl time.Duration) error {
+	pipe := s.client.TxPipeline()
+	if deviceID != "" {
+		pipe.SAdd(s.ctx, loginDevicesKey(userID), deviceID)
+		pipe.Expire(s.ctx, loginDevicesKey(userID), ttl)
+	}
+	if network != "" {
+		pipe.SAdd(s.ctx, loginNetworksKey(userID), network)
+		pipe.Expire(s.ctx, loginNetworksKey(userID), ttl)
+	}
+	pipe.Del(s.ctx, loginFailuresKey(userID))
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// IncrLoginFailures 增加用户的失败计数，计数从第一次失败起window后过期
+func (s *RedisStore) IncrLoginFailures(userID string, window time.Duration) (int64, error) {
+	pipe := s.client.TxPipeline()
+	count := pipe.Incr(s.ctx, loginFailuresKey(userID))
+	pipe.ExpireNX(s.ctx, loginFailuresKey(userID), window)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+// SetTrustedDevice 保存设备信任令牌的摘要
+func (s *RedisStore) SetTrustedDevice(userID, deviceID, tokenHash string, ttl time.Duration) error {
+	return s.client.Set(s.ctx, trustedDeviceKey(userID, deviceID), tokenHash, ttl).Err()
+}
+
+// GetTrustedDevice 获取设备信任令牌的摘要，没有记录时返回false
+func (s *RedisStore) GetTrustedDevice(userID, deviceID string) (string, bool, error) {
+	hash, err := s.client.Get(s.ctx, trustedDeviceKey(userID, deviceID)).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return hash, true, nil
+}
+
+// SaveChallenge 保存等待验证的登录
+func (s *RedisStore) SaveChallenge(challenge *model.PendingChallenge, ttl time.Duration) error {
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+	key := challengeKey(challenge.ChallengeID)
+	pipe := s.client.TxPipeline()
+	pipe.HSet(s.ctx, key, "data", data, "attempts", 0)
+	pipe.Expire(s.ctx, key, ttl)
+	_, err = pipe.Exec(s.ctx)
+	return err
+}
+
+// takeChallengeScript 挑战存在时计入一次尝试，返回 {data, attempts}，不存在时返回nil
+var takeChallengeScript = redis.NewScript(`
+local data = redis.call("HGET", KEYS[1], "data")
+if not data then
+	return nil
+end
+return {data, redis.call("HINCRBY", KEYS[1], "attempts", 1)}
+`)
+
+// TakeChallengeAttempt 获取等待验证的登录并计入一次验证尝试，返回尝试次数，不存在或已过期时返回false
+func (s *RedisStore) TakeChallengeAttempt(challengeID string) (*model.PendingChallenge, int64, bool, error) {
+	result, err := takeChallengeScript.Run(s.ctx, s.client, []string{challengeKey(challengeID)}).Slice()
+	if err == redis.Nil {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	data, _ := result[0].(string)
+	attempts, _ := result[1].(int64)
+
+	var challenge model.PendingChallenge
+	if err := json.Unmarshal([]byte(data), &challenge); err != nil {
+		return nil, 0, false, err
+	}
+	return &challenge, attempts, true, nil
+}
+
+// DeleteChallenge 删除等待验证的登录
+func (s *RedisStore) DeleteChallenge(challengeID string) error {
+	return s.client.Del(s.ctx, challengeKey(challengeID)).Err()
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+// clientCapabilitiesKey 用户最近一次登录上报的客户端能力
+func clientCapabilitiesKey(userID string) string {
+	return fmt.Sprintf("client:caps:%s", userID)
+}
+
+// SetClientCapabilities 保存用户的客户端能力
+func (s *RedisStore) SetClientCapabilities(userID string, caps model.ClientCapabilities, ttl time.Duration) error {
+	data, err := json.Marshal(caps)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(s.ctx, clientCapabilitiesKey(userID), data, ttl).Err()
+}
+
+// GetClientCapabilities 获取用户的客户端能力，没有记录时返回false
+func (s *RedisStore) GetClientCapabilities(userID string) (model.ClientCapabilities, bool, error) {
+	var caps model.ClientCapabilities
+	data, err := s.client.Get(s.ctx, clientCapabilitiesKey(userID)).Bytes()
+	if err == redis.Nil {
+		return caps, false, nil
+	}
+	if err != nil {
+		return caps, false, err
+	}
+	if err := json.Unmarshal(data, &caps); err != nil {
+		return caps, false, err
+	}
+	return caps, true, nil
+}
+
+// clientFeaturesKey 管理接口设置的功能开关覆盖
+const clientFeaturesKey = "client:features"
+
+// ClientConfigChannel 客户端配置变更通知频道
+const ClientConfigChannel = "client:config:changed"
+
+// SetClientFeature 覆盖功能开关
+func (s *RedisStore) SetClientFeature(name string, enabled bool) error {
+	return s.client.HSet(s.ctx, clientFeaturesKey, name, strconv.FormatBool(enabled)).Err()
+}
+
+// DeleteClientFeature 删除功能开关覆盖，恢复为配置文件中的默认值
+func (s *RedisStore) DeleteClientFeature(name string) error {
+	return s.client.HDel(s.ctx, clientFeaturesKey, name).Err()
+}
+
+// GetClientFeatures 获取全部功能开关覆盖
+func (s *RedisStore) GetClientFeatures() (map[string]bool, error) {
+	values, err := s.client.HGetAll(s.ctx, clientFeaturesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	features := make(map[string]bool, len(values))
+	for name, value := range values {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			continue
+		}
+		features[name] = enabled
+	}
+	return features, nil
+}
+
+// featureFlagsKey 管理接口设置的功能开关覆盖，值为整个开关的JSON
+const featureFlagsKey = "feature:flags"
+
+// FeatureFlagsChannel 功能开关变更通知频道
+const FeatureFlagsChannel = "feature:flags:changed"
+
+// SetFeatureFlag 覆盖功能开关
+func (s *RedisStore) SetFeatureFlag(flag *model.FeatureFlag) error {
+	data, err := json.Marshal(flag)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(s.ctx, featureFlagsKey, flag.Name, data).Err()
+}
+
+// DeleteFeatureFlag 删除功能开关覆盖，恢复为配置文件中的定义
+func (s *RedisStore) DeleteFeatureFlag(name string) error {
+	return s.client.HDel(s.ctx, featureFlagsKey, name).Err()
+}
+
+// GetFeatureFlags 获取全部功能开关覆盖，跳过无法解析的记录
+func (s *RedisStore) GetFeatureFlags() (map[string]*model.FeatureFlag, error) {
+	values, err := s.client.HGetAll(s.ctx, featureFlagsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	flags := make(map[string]*model.FeatureFlag, len(values))
+	for name, value := range values {
+		var flag model.FeatureFlag
+		if err := json.Unmarshal([]byte(value), &flag); err != nil {
+			continue
+		}
+		flag.Name = name
+		flags[name] = &flag
+	}
+	return flags, nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/user/im/internal/metrics"
+)
+
+// keyedPool 按记录键分发的工作池
+// 同一键（会话）的记录总在同一个worker上按到达顺序处理，不同键之间并行处理
+type keyedPool struct {
+	topic  string
+	queues []chan kafka.Message
+	wg     sync.WaitGroup
+}
+
+// newKeyedPool 创建并启动工作池，每个worker有独立的缓冲队列，队列满时分发会阻塞读取
+func newKeyedPool(topic string, workers, queueSize int, handle func(kafka.Message)) *keyedPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	p := &keyedPool{
+		topic:  topic,
+		queues: make([]chan kafka.Message, workers),
+	}
+	for i := range p.queues {
+		queue := make(chan kafka.Message, queueSize)
+		p.queues[i] = queue
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for msg := range queue {
+				start := time.Now()
+				handle(msg)
+				metrics.KafkaProcessingSeconds.WithLabelValues(p.topic).Observe(time.Since(start).Seconds())
+			}
+		}()
+	}
+	return p
+}
+
+// dispatch 把记录交给键对应的worker
+func (p *keyedPool) dispatch(msg kafka.Message) {
+	p.queues[p.worker(msg)] <- msg
+}
+
+// worker 计算记录所属的worker，没有键的记录按分区分配
+func (p *keyedPool) worker(msg kafka.Message) int {
+	key := msg.Key
+	if len(key) == 0 {
+		key = []byte(strconv.Itoa(msg.Partition))
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// close 停止接收记录，等待已分发的记录处理完成
+func (p *keyedPool) close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// lagTracker 记录本节点消费的各分区延迟，即读到的记录之后还有多少条未读
+type lagTracker struct {
+	mu  sync.Mutex
+	lag map[string]map[int]int64
+}
+
+// newLagTracker 创建延迟记录
+func newLagTracker() *lagTracker {
+	return &lagTracker{lag: make(map[string]map[int]int64)}
+}
+
+// record 按读到的记录更新分区延迟
+func (t *lagTracker) record(msg kafka.Message) {
+	lag := msg.HighWaterMark - msg.Offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+	metrics.KafkaConsumerLag.WithLabelValues(msg.Topic, strconv.Itoa(msg.Partition)).Set(float64(lag))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	partitions, ok := t.lag[msg.Topic]
+	if !ok {
+		partitions = make(map[int]int64)
+		t.lag[msg.Topic] = partitions
+	}
+	partitions[msg.Partition] = lag
+}
+
+// totals 各主题所有分区的延迟之和
+func (t *lagTracker) totals() map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	totals := make(map[string]int64, len(t.lag))
+	for topic, partitions := range t.lag {
+		for _, lag := range partitions {
+			totals[topic] += lag
+		}
+	}
+	return totals
+}
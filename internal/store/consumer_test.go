@@ -0,0 +1,55 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedPool_PreservesOrderPerKey(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string][]int64)
+	pool := newKeyedPool("test", 4, 8, func(msg kafka.Message) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[string(msg.Key)] = append(seen[string(msg.Key)], msg.Offset)
+	})
+
+	keys := []string{"group:g1", "group:g2", "private:a:b", "private:c:d", "group:g3"}
+	for offset := int64(0); offset < 200; offset++ {
+		pool.dispatch(kafka.Message{Key: []byte(keys[offset%int64(len(keys))]), Offset: offset})
+	}
+	pool.close()
+
+	for _, key := range keys {
+		offsets := seen[key]
+		assert.Len(t, offsets, 40, key)
+		for i := 1; i < len(offsets); i++ {
+			assert.Less(t, offsets[i-1], offsets[i], fmt.Sprintf("%s out of order", key))
+		}
+	}
+}
+
+func TestKeyedPool_SameKeySameWorker(t *testing.T) {
+	pool := newKeyedPool("test", 8, 1, func(kafka.Message) {})
+	defer pool.close()
+
+	msg := kafka.Message{Key: []byte("group:g1")}
+	assert.Equal(t, pool.worker(msg), pool.worker(msg))
+
+	// 没有键的记录按分区分配
+	assert.Equal(t, pool.worker(kafka.Message{Partition: 3}), pool.worker(kafka.Message{Partition: 3}))
+}
+
+func TestLagTracker_SumsLatestLagPerPartition(t *testing.T) {
+	tracker := newLagTracker()
+	tracker.record(kafka.Message{Topic: "chat", Partition: 0, Offset: 10, HighWaterMark: 20})
+	tracker.record(kafka.Message{Topic: "chat", Partition: 0, Offset: 17, HighWaterMark: 20})
+	tracker.record(kafka.Message{Topic: "chat", Partition: 1, Offset: 4, HighWaterMark: 5})
+	tracker.record(kafka.Message{Topic: "offline", Partition: 0, Offset: 3, HighWaterMark: 9})
+
+	assert.Equal(t, map[string]int64{"chat": 2, "offline": 5}, tracker.totals())
+}
@@ -0,0 +1,21 @@
+package store
+
+import (
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm/clause"
+)
+
+// SaveConversationSettings 保存用户会话设置，已存在时覆盖
+func (s *MySQLStore) SaveConversationSettings(settings *model.UserConversationSettings) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "conversation_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"archived", "pinned", "pinned_at", "labels", "updated_at"}),
+	}).Create(settings).Error
+}
+
+// GetConversationSettings 获取用户的所有会话设置
+func (s *MySQLStore) GetConversationSettings(userID string) ([]*model.UserConversationSettings, error) {
+	var settings []*model.UserConversationSettings
+	err := s.db.Where("user_id = ?", userID).Find(&settings).Error
+	return settings, err
+}
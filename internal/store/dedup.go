@@ -0,0 +1,67 @@
+package store
+
+import (
+	"time"
+
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// Deduplicator 消费端去重，在Redis中记录一段时间内处理过的ID
+// 消费者重启后Kafka会重投未提交的消息，去重后同一条消息最多推送一次
+type Deduplicator struct {
+	redisStore *RedisStore
+	scope      string
+	ttl        time.Duration
+}
+
+// NewDeduplicator 创建去重器，scope区分不同的消费者
+func NewDeduplicator(redisStore *RedisStore, scope string, ttl time.Duration) *Deduplicator {
+	return &Deduplicator{
+		redisStore: redisStore,
+		scope:      scope,
+		ttl:        ttl,
+	}
+}
+
+// Claim 在处理前占用ID，返回false表示已处理过，应跳过
+// 空ID和Redis不可用时放行，宁可重复推送也不丢消息
+func (d *Deduplicator) Claim(id string) bool {
+	if id == "" {
+		return true
+	}
+	first, err := d.redisStore.ClaimProcessed(d.scope, id, d.ttl)
+	if err != nil {
+		logger.Warn("Failed to check duplicate", logger.String("scope", d.scope), logger.String("id", id), logger.ErrorField(err))
+		return true
+	}
+	if !first {
+		metrics.DuplicatesSuppressed.WithLabelValues(d.scope).Inc()
+	}
+	return first
+}
+
+// Release 处理失败时释放ID，允许重投后再次处理
+func (d *Deduplicator) Release(id string) {
+	if id == "" {
+		return
+	}
+	if err := d.redisStore.ReleaseProcessed(d.scope, id); err != nil {
+		logger.Warn("Failed to release duplicate marker", logger.String("scope", d.scope), logger.String("id", id), logger.ErrorField(err))
+	}
+}
+
+// Messages 包装消息处理函数，跳过已处理过的消息
+func (d *Deduplicator) Messages(handler func(*model.Message) error) func(*model.Message) error {
+	return func(message *model.Message) error {
+		if !d.Claim(message.ID) {
+			return nil
+		}
+		if err := handler(message); err != nil {
+			d.Release(message.ID)
+			return err
+		}
+		return nil
+	}
+}
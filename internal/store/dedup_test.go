@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+// unreachableRedisStore 连接不可达地址的Redis存储，所有命令都返回错误
+func unreachableRedisStore() *RedisStore {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 50 * time.Millisecond, MaxRetries: -1})
+	return &RedisStore{client: client, ctx: context.Background()}
+}
+
+func TestDeduplicator_FailsOpen(t *testing.T) {
+	d := NewDeduplicator(unreachableRedisStore(), "test", time.Minute)
+
+	// 空ID不访问Redis，Redis不可用时放行
+	assert.True(t, d.Claim(""))
+	assert.True(t, d.Claim("m1"))
+	assert.True(t, d.Claim("m1"))
+	d.Release("")
+	d.Release("m1")
+
+	var handled int
+	handler := d.Messages(func(*model.Message) error {
+		handled++
+		return nil
+	})
+	assert.NoError(t, handler(&model.Message{ID: "m1"}))
+	assert.NoError(t, handler(&model.Message{ID: "m1"}))
+	assert.Equal(t, 2, handled)
+}
+
+func TestRedisDeduplicator_SkipsProcessedAndRetriesFailed(t *testing.T) {
+	s := testRedisStore(t)
+	scope := "test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	t.Cleanup(func() { s.ReleaseProcessed(scope, "m1"); s.ReleaseProcessed(scope, "m2") })
+	d := NewDeduplicator(s, scope, time.Minute)
+
+	var handled []string
+	fail := errors.New("push failed")
+	handler := d.Messages(func(message *model.Message) error {
+		handled = append(handled, message.ID)
+		if message.ID == "m2" && len(handled) == 2 {
+			return fail
+		}
+		return nil
+	})
+
+	// 重投的消息只处理一次，处理失败的消息释放后可再次处理
+	assert.NoError(t, handler(&model.Message{ID: "m1"}))
+	assert.NoError(t, handler(&model.Message{ID: "m1"}))
+	assert.ErrorIs(t, handler(&model.Message{ID: "m2"}), fail)
+	assert.NoError(t, handler(&model.Message{ID: "m2"}))
+	assert.NoError(t, handler(&model.Message{ID: "m2"}))
+	assert.Equal(t, []string{"m1", "m2", "m2"}, handled)
+}
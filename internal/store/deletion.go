@@ -0,0 +1,187 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TombstoneMessage 将消息标记为对所有人删除，清空内容但保留记录
+func (s *MySQLStore) TombstoneMessage(messageID string, deletedAt int64) error {
+	return s.db.Model(&model.Message{}).Where("id = ?", messageID).Updates(map[string]interface{}{
+		"content":    "",
+		"deleted_at": deletedAt,
+	}).Error
+}
+
+// MarkMessageDeleted 记录用户对自己删除了消息，重复删除忽略
+func (s *MySQLStore) MarkMessageDeleted(userID, messageID string) error {
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.MessageDeletion{
+		MessageID: messageID,
+		UserID:    userID,
+	}).Error
+}
+
+// GetDeletedMessageIDs 返回给定消息中用户已对自己删除的消息ID
+func (s *MySQLStore) GetDeletedMessageIDs(userID string, messageIDs []string) (map[string]bool, error) {
+	deleted := make(map[string]bool)
+	if len(messageIDs) == 0 {
+		return deleted, nil
+	}
+
+	var ids []string
+	err := s.db.Model(&model.MessageDeletion{}).
+		Where("message_id IN ? AND user_id = ?", messageIDs, userID).
+		Pluck("message_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	return deleted, nil
+}
+
+// GetTombstones 返回给定消息中已对所有人删除的消息及删除时间
+func (s *MySQLStore) GetTombstones(messageIDs []string) (map[string]int64, error) {
+	tombstones := make(map[string]int64)
+	if len(messageIDs) == 0 {
+		return tombstones, nil
+	}
+
+	var rows []struct {
+		ID        string
+		DeletedAt int64
+	}
+	err := s.db.Model(&model.Message{}).
+		Select("id", "deleted_at").
+		Where("id IN ? AND deleted_at > 0", messageIDs).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tombstones[row.ID] = row.DeletedAt
+	}
+	return tombstones, nil
+}
+
+// PurgeMessage 物理删除消息及其删除记录、回执和收藏，仅用于管理和数据保留清理
+func (s *MySQLStore) PurgeMessage(messageID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("message_id = ?", messageID).Delete(&model.MessageDeletion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", messageID).Delete(&model.UserStarredMessage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", messageID).Delete(&model.MessageReceipt{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", messageID).Delete(&model.Message{}).Error
+	})
+}
+
+// TombstoneMessage 将消息标记为对所有人删除，同时更新离线索引中的副本
+func (s *LevelDBStore) TombstoneMessage(messageID string, deletedAt int64) error {
+	defer s.observe("tombstone_message", time.Now())
+	return s.updateMessage(messageID, func(message *model.Message) {
+		message.Content = ""
+		message.DeletedAt = deletedAt
+	})
+}
+
+// MarkMessageDeleted 记录用户对自己删除了消息
+func (s *LevelDBStore) MarkMessageDeleted(userID, messageID string) error {
+	defer s.observe("mark_message_deleted", time.Now())
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	value := strconv.FormatInt(time.Now().Unix(), 10)
+	return s.db.Put([]byte(s.deletionKey(messageID, userID)), []byte(value), nil)
+}
+
+// GetDeletedMessageIDs 返回给定消息中用户已对自己删除的消息ID
+func (s *LevelDBStore) GetDeletedMessageIDs(userID string, messageIDs []string) (map[string]bool, error) {
+	defer s.observe("get_deleted_message_ids", time.Now())
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	deleted := make(map[string]bool)
+	for _, id := range messageIDs {
+		ok, err := s.db.Has([]byte(s.deletionKey(id, userID)), nil)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			deleted[id] = true
+		}
+	}
+	return deleted, nil
+}
+
+// GetTombstones 返回给定消息中已对所有人删除的消息及删除时间
+func (s *LevelDBStore) GetTombstones(messageIDs []string) (map[string]int64, error) {
+	defer s.observe("get_tombstones", time.Now())
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	tombstones := make(map[string]int64)
+	for _, id := range messageIDs {
+		raw, err := s.db.Get([]byte(s.messageKey(id)), nil)
+		if errors.Is(err, leveldb.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var message model.Message
+		if err := json.Unmarshal(raw, &message); err != nil {
+			continue
+		}
+		if message.IsDeleted() {
+			tombstones[id] = message.DeletedAt
+		}
+	}
+	return tombstones, nil
+}
+
+// PurgeMessage 物理删除消息、离线索引、删除记录和回执，仅用于管理和数据保留清理
+func (s *LevelDBStore) PurgeMessage(messageID string) error {
+	defer s.observe("purge_message", time.Now())
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := []byte(s.messageKey(messageID))
+	batch := new(leveldb.Batch)
+	if raw, err := s.db.Get(key, nil); err == nil {
+		var message model.Message
+		if err := json.Unmarshal(raw, &message); err == nil && message.IsPrivateMessage() {
+			batch.Delete([]byte(s.offlineKey(message.ReceiverID) + messageID))
+		}
+	} else if !errors.Is(err, leveldb.ErrNotFound) {
+		return err
+	}
+	batch.Delete(key)
+
+	for _, prefix := range []string{s.deletionKey(messageID, ""), s.receiptKey(messageID, "")} {
+		iter := s.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+		for iter.Next() {
+			batch.Delete(append([]byte(nil), iter.Key()...))
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+	return s.db.Write(batch, nil)
+}
+
+// deletionKey 用户删除记录键，按消息ID前缀组织以便物理删除时一并清理
+func (s *LevelDBStore) deletionKey(messageID, userID string) string {
+	return "deleted:" + messageID + ":" + userID
+}
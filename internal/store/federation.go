@@ -0,0 +1,121 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// federationDomainsKey 有发件队列的远程域
+const federationDomainsKey = "federation:domains"
+
+// federationOutboxKey 发往远程域的发件队列，按入队顺序投递
+func federationOutboxKey(domain string) string {
+	return fmt.Sprintf("federation:outbox:%s", domain)
+}
+
+// federationDeadKey 超过最多投递次数的事件，保留供排障
+func federationDeadKey(domain string) string {
+	return fmt.Sprintf("federation:dead:%s", domain)
+}
+
+// EnqueueFederationEvent 把事件加入远程域的发件队列
+func (s *RedisStore) EnqueueFederationEvent(domain string, data []byte) error {
+	pipe := s.client.TxPipeline()
+	pipe.RPush(s.ctx, federationOutboxKey(domain), data)
+	pipe.SAdd(s.ctx, federationDomainsKey, domain)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// PeekFederationEvents 读取发件队列队首的最多n个事件，不出队
+func (s *RedisStore) PeekFederationEvents(domain string, n int64) ([]string, error) {
+	return s.client.LRange(s.ctx, federationOutboxKey(domain), 0, n-1).Result()
+}
+
+// AckFederationEvents 移除发件队列队首已投递的n个事件
+func (s *RedisStore) AckFederationEvents(domain string, n int64) error {
+	return s.client.LTrim(s.ctx, federationOutboxKey(domain), n, -1).Err()
+}
+
+// UpdateFederationHead 更新队首事件，用于记录投递次数
+func (s *RedisStore) UpdateFederationHead(domain string, data []byte) error {
+	return s.client.LSet(s.ctx, federationOutboxKey(domain), 0, data).Err()
+}
+
+// DeadLetterFederationHead 把队首事件移入死信队列
+func (s *RedisStore) DeadLetterFederationHead(domain string, data []byte) error {
+	pipe := s.client.TxPipeline()
+	pipe.LPop(s.ctx, federationOutboxKey(domain))
+	pipe.RPush(s.ctx, federationDeadKey(domain), data)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// GetFederationDomains 获取有发件队列的远程域
+func (s *RedisStore) GetFederationDomains() ([]string, error) {
+	return s.client.SMembers(s.ctx, federationDomainsKey).Result()
+}
+
+// CountFederationEvents 获取远程域待投递和死信事件数
+func (s *RedisStore) CountFederationEvents(domain string) (int64, int64, error) {
+	pipe := s.client.Pipeline()
+	pending := pipe.LLen(s.ctx, federationOutboxKey(domain))
+	dead := pipe.LLen(s.ctx, federationDeadKey(domain))
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return 0, 0, err
+	}
+	return pending.Val(), dead.Val(), nil
+}
+
+// federationShardKey 联邦群组中某个域的成员分片，字段为用户ID，值为成员所在域分配的版本，正数表示在群中，负数表示已退出
+// 每个分片只由成员所在的域修改，各域的分片互不重叠，合并时不会冲突
+func federationShardKey(group, domain string) string {
+	return fmt.Sprintf("federation:shard:%s:%s", group, domain)
+}
+
+// federationShardDomainsKey 联邦群组中有成员分片的域
+func federationShardDomainsKey(group string) string {
+	return fmt.Sprintf("federation:shard_domains:%s", group)
+}
+
+// GetGroupShardVersion 获取用户在成员分片中的版本，没有记录时为0
+func (s *RedisStore) GetGroupShardVersion(group, domain, userID string) (int64, error) {
+	version, err := s.client.HGet(s.ctx, federationShardKey(group, domain), userID).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return version, err
+}
+
+// SetGroupShardVersion 记录用户在成员分片中的版本
+func (s *RedisStore) SetGroupShardVersion(group, domain, userID string, version int64) error {
+	pipe := s.client.TxPipeline()
+	pipe.HSet(s.ctx, federationShardKey(group, domain), userID, version)
+	pipe.SAdd(s.ctx, federationShardDomainsKey(group), domain)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// GetGroupShardMembers 获取成员分片中仍在群中的用户
+func (s *RedisStore) GetGroupShardMembers(group, domain string) ([]string, error) {
+	values, err := s.client.HGetAll(s.ctx, federationShardKey(group, domain)).Result()
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(values))
+	for userID, value := range values {
+		if version, _ := strconv.ParseInt(value, 10, 64); version > 0 {
+			members = append(members, userID)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// GetGroupShardDomains 获取联邦群组中有成员分片的域
+func (s *RedisStore) GetGroupShardDomains(group string) ([]string, error) {
+	return s.client.SMembers(s.ctx, federationShardDomainsKey(group)).Result()
+}
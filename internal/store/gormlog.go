@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// gormLogger 把GORM的日志输出到应用日志
+// 错误以Error级别、慢查询以Warn级别、其余SQL以Info级别记录；默认不记录参数值
+type gormLogger struct {
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+	logParams     bool
+}
+
+// newGormLogger 按数据库配置创建GORM日志适配器
+func newGormLogger(cfg *config.DatabaseConfig) gormlogger.Interface {
+	return &gormLogger{
+		level:         parseGormLogLevel(cfg.LogLevel),
+		slowThreshold: cfg.SlowThreshold,
+		logParams:     cfg.LogParams,
+	}
+}
+
+// parseGormLogLevel 解析SQL日志级别，未设置时为warn
+func parseGormLogLevel(level string) gormlogger.LogLevel {
+	switch level {
+	case config.DatabaseLogSilent:
+		return gormlogger.Silent
+	case config.DatabaseLogError:
+		return gormlogger.Error
+	case config.DatabaseLogInfo:
+		return gormlogger.Info
+	default:
+		return gormlogger.Warn
+	}
+}
+
+// LogMode 返回指定级别的副本，供db.Debug()等临时调整级别
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+// Info 记录GORM的提示信息
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		logger.Info(fmt.Sprintf(msg, data...), logger.String("source", utils.FileWithLineNum()))
+	}
+}
+
+// Warn 记录GORM的警告
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		logger.Warn(fmt.Sprintf(msg, data...), logger.String("source", utils.FileWithLineNum()))
+	}
+}
+
+// Error 记录GORM的错误
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		logger.Error(fmt.Sprintf(msg, data...), logger.String("source", utils.FileWithLineNum()))
+	}
+}
+
+// Trace 记录一条SQL，记录不存在不视为错误
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	fields := func() []zap.Field {
+		sql, rows := fc()
+		return []zap.Field{
+			logger.String("sql", truncateStatement(sql)),
+			logger.Int64("rows", rows),
+			logger.Int64("duration_ms", elapsed.Milliseconds()),
+			logger.String("source", utils.FileWithLineNum()),
+		}
+	}
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		logger.Error("SQL error", append(fields(), logger.ErrorField(err))...)
+	case l.slowThreshold > 0 && elapsed >= l.slowThreshold && l.level >= gormlogger.Warn:
+		logger.Warn("Slow SQL", fields()...)
+	case l.level >= gormlogger.Info:
+		logger.Info("SQL", fields()...)
+	}
+}
+
+// ParamsFilter 未开启log_params时去掉参数值，日志中的SQL保留占位符
+func (l *gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if !l.logParams {
+		return sql, nil
+	}
+	return sql, params
+}
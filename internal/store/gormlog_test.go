@@ -0,0 +1,31 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestGormLogger(t *testing.T) {
+	l := newGormLogger(&config.DatabaseConfig{SlowThreshold: 200 * time.Millisecond}).(*gormLogger)
+	assert.Equal(t, gormlogger.Warn, l.level)
+	assert.Equal(t, gormlogger.Info, parseGormLogLevel(config.DatabaseLogInfo))
+	assert.Equal(t, gormlogger.Silent, parseGormLogLevel(config.DatabaseLogSilent))
+
+	// db.Debug()不影响原日志器的级别
+	debug := l.LogMode(gormlogger.Info).(*gormLogger)
+	assert.Equal(t, gormlogger.Info, debug.level)
+	assert.Equal(t, gormlogger.Warn, l.level)
+
+	sql, params := l.ParamsFilter(context.Background(), "SELECT * FROM messages WHERE id = ?", "m1")
+	assert.Equal(t, "SELECT * FROM messages WHERE id = ?", sql)
+	assert.Nil(t, params)
+
+	l.logParams = true
+	_, params = l.ParamsFilter(context.Background(), "SELECT * FROM messages WHERE id = ?", "m1")
+	assert.Equal(t, []interface{}{"m1"}, params)
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 群组成员缓存
+// 成员集合只在版本号存在时有效，版本号缺失表示未加载，读取时从数据库回填；
+// 成员变更先写数据库再写缓存，每次写入版本号加一，写入失败时删除整个缓存，定期对账时据版本号判断对比期间是否有并发变更
+// 已加载缓存的群组ID记录在索引集合中，供对账任务遍历
+
+// groupCacheIndexKey 已加载成员缓存的群组ID集合
+const groupCacheIndexKey = "group:members_cached"
+
+// groupMembersKey 群组成员集合
+func groupMembersKey(groupID string) string {
+	return fmt.Sprintf("group:members:%s", groupID)
+}
+
+// groupMembersVersionKey 群组成员缓存的版本号
+func groupMembersVersionKey(groupID string) string {
+	return fmt.Sprintf("group:members_version:%s", groupID)
+}
+
+// groupMemberCountKey 群组成员数
+func groupMemberCountKey(groupID string) string {
+	return fmt.Sprintf("group:member_count:%s", groupID)
+}
+
+// groupFenceKey 群组缓存上记录的fencing token
+func groupFenceKey(groupID string) string {
+	return fmt.Sprintf("group:fence:%s", groupID)
+}
+
+// groupCacheKeys 带fencing token的群组缓存脚本使用的键
+func groupCacheKeys(groupID string) []string {
+	return []string{groupFenceKey(groupID), groupMembersKey(groupID), groupMembersVersionKey(groupID), groupMemberCountKey(groupID), groupCacheIndexKey}
+}
+
+// fillGroupMembersScript 替换成员集合并设置成员数，版本号加一并加入索引
+var fillGroupMembersScript = fencedScript(`
+redis.call("DEL", KEYS[2])
+for i = 4, #ARGV do
+	redis.call("SADD", KEYS[2], ARGV[i])
+end
+redis.call("INCR", KEYS[3])
+redis.call("PEXPIRE", KEYS[2], ARGV[2])
+redis.call("PEXPIRE", KEYS[3], ARGV[2])
+redis.call("SET", KEYS[4], #ARGV - 3)
+redis.call("SADD", KEYS[5], ARGV[3])`)
+
+// addGroupMemberScript 成员数存在时加一，成员集合已加载时添加成员并把版本号加一
+var addGroupMemberScript = fencedScript(`
+if redis.call("EXISTS", KEYS[4]) == 1 then
+	redis.call("INCRBY", KEYS[4], 1)
+end
+if redis.call("EXISTS", KEYS[3]) == 1 then
+	redis.call("SADD", KEYS[2], ARGV[2])
+	redis.call("INCR", KEYS[3])
+end`)
+
+// removeGroupMemberScript 成员数存在时减一，成员集合已加载时移除成员并把版本号加一
+var removeGroupMemberScript = fencedScript(`
+if redis.call("EXISTS", KEYS[4]) == 1 then
+	redis.call("INCRBY", KEYS[4], -1)
+end
+if redis.call("EXISTS", KEYS[3]) == 1 then
+	redis.call("SREM", KEYS[2], ARGV[2])
+	redis.call("INCR", KEYS[3])
+end`)
+
+// setGroupMemberCountScript 设置成员数
+var setGroupMemberCountScript = fencedScript(`
+redis.call("SET", KEYS[4], ARGV[2])`)
+
+// FillGroupMembers 写入从数据库加载的成员集合和成员数，缓存ttl后过期；锁已丢失或token过期时返回错误
+func (s *RedisStore) FillGroupMembers(groupID string, members []string, ttl time.Duration, lock *Lock) error {
+	args := make([]interface{}, 0, len(members)+2)
+	args = append(args, ttl.Milliseconds(), groupID)
+	for _, member := range members {
+		args = append(args, member)
+	}
+	return s.runFenced(fillGroupMembersScript, lock, groupCacheKeys(groupID), args...)
+}
+
+// AddGroupMemberFenced 持有群组锁时添加成员缓存，锁已丢失或token过期时返回错误
+func (s *RedisStore) AddGroupMemberFenced(groupID, userID string, lock *Lock) error {
+	return s.runFenced(addGroupMemberScript, lock, groupCacheKeys(groupID), userID)
+}
+
+// RemoveGroupMemberFenced 持有群组锁时移除成员缓存，锁已丢失或token过期时返回错误
+func (s *RedisStore) RemoveGroupMemberFenced(groupID, userID string, lock *Lock) error {
+	return s.runFenced(removeGroupMemberScript, lock, groupCacheKeys(groupID), userID)
+}
+
+// SetGroupMemberCountFenced 持有群组锁时回填成员数，锁已丢失或token过期时返回错误
+func (s *RedisStore) SetGroupMemberCountFenced(groupID string, count int64, lock *Lock) error {
+	return s.runFenced(setGroupMemberCountScript, lock, groupCacheKeys(groupID), count)
+}
+
+// GetCachedGroupMembers 获取缓存的成员集合和版本号，缓存未加载时返回false
+func (s *RedisStore) GetCachedGroupMembers(groupID string) ([]string, int64, bool, error) {
+	pipe := s.client.TxPipeline()
+	version := pipe.Get(s.ctx, groupMembersVersionKey(groupID))
+	members := pipe.SMembers(s.ctx, groupMembersKey(groupID))
+	if _, err := pipe.Exec(s.ctx); err != nil && err != redis.Nil {
+		return nil, 0, false, err
+	}
+	v, err := version.Int64()
+	if err == redis.Nil {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return members.Val(), v, true, nil
+}
+
+// GetGroupMemberCount 获取群组成员数，计数不存在时返回false
+func (s *RedisStore) GetGroupMemberCount(groupID string) (int64, bool, error) {
+	count, err := s.client.Get(s.ctx, groupMemberCountKey(groupID)).Int64()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return count, true, nil
+}
+
+// GetGroupMembersVersion 获取成员缓存的版本号，缓存未加载时返回0
+func (s *RedisStore) GetGroupMembersVersion(groupID string) (int64, error) {
+	v, err := s.client.Get(s.ctx, groupMembersVersionKey(groupID)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return v, err
+}
+
+// InvalidateGroupCache 删除群组的成员缓存和成员数，下次读取时从数据库回填
+func (s *RedisStore) InvalidateGroupCache(groupID string) error {
+	pipe := s.client.TxPipeline()
+	pipe.Del(s.ctx, groupMembersKey(groupID), groupMembersVersionKey(groupID), groupMemberCountKey(groupID))
+	pipe.SRem(s.ctx, groupCacheIndexKey, groupID)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// ScanCachedGroups 分批遍历已加载成员缓存的群组ID，cursor为0时从头开始，返回的cursor为0表示遍历结束
+func (s *RedisStore) ScanCachedGroups(cursor uint64, count int64) ([]string, uint64, error) {
+	return s.client.SScan(s.ctx, groupCacheIndexKey, cursor, "", count).Result()
+}
+
+// RemoveCachedGroup 从索引中移除缓存已过期的群组
+func (s *RedisStore) RemoveCachedGroup(groupID string) error {
+	return s.client.SRem(s.ctx, groupCacheIndexKey, groupID).Err()
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+// groupEventRemindersKey 待推送的群活动提醒，成员为活动消息ID，score为提醒时间
+const groupEventRemindersKey = "group_events:reminders"
+
+// groupEventRSVPKey 群活动的答复，字段为用户ID，值为答复
+func groupEventRSVPKey(messageID string) string {
+	return fmt.Sprintf("group_event:rsvp:%s", messageID)
+}
+
+// groupEventCountsKey 群活动各答复的人数
+func groupEventCountsKey(messageID string) string {
+	return fmt.Sprintf("group_event:rsvp_counts:%s", messageID)
+}
+
+// setRSVPScript 更新用户的答复并调整人数，返回之前的答复（没有时为空字符串）
+var setRSVPScript = redis.NewScript(`
+local previous = redis.call("HGET", KEYS[1], ARGV[1])
+if previous == ARGV[2] then
+	return previous
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+if previous then
+	redis.call("HINCRBY", KEYS[2], previous, -1)
+end
+redis.call("HINCRBY", KEYS[2], ARGV[2], 1)
+redis.call("EXPIREAT", KEYS[1], ARGV[3])
+redis.call("EXPIREAT", KEYS[2], ARGV[3])
+return previous or ""
+`)
+
+// SetGroupEventRSVP 记录用户对群活动的答复，expiresAt之后答复记录过期
+func (s *RedisStore) SetGroupEventRSVP(messageID, userID string, response model.RSVPResponse, expiresAt time.Time) (model.RSVPResponse, error) {
+	keys := []string{groupEventRSVPKey(messageID), groupEventCountsKey(messageID)}
+	previous, err := setRSVPScript.Run(s.ctx, s.client, keys, userID, string(response), expiresAt.Unix()).Text()
+	if err != nil {
+		return "", err
+	}
+	return model.RSVPResponse(previous), nil
+}
+
+// GetGroupEventRSVPs 获取群活动的全部答复
+func (s *RedisStore) GetGroupEventRSVPs(messageID string) (map[string]model.RSVPResponse, error) {
+	values, err := s.client.HGetAll(s.ctx, groupEventRSVPKey(messageID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	responses := make(map[string]model.RSVPResponse, len(values))
+	for userID, response := range values {
+		responses[userID] = model.RSVPResponse(response)
+	}
+	return responses, nil
+}
+
+// GetGroupEventCounts 批量获取群活动的答复人数，没有答复的活动人数为0
+func (s *RedisStore) GetGroupEventCounts(messageIDs []string) (map[string]*model.RSVPCounts, error) {
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(messageIDs))
+	for i, id := range messageIDs {
+		cmds[i] = pipe.HGetAll(s.ctx, groupEventCountsKey(id))
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]*model.RSVPCounts, len(messageIDs))
+	for i, id := range messageIDs {
+		values := cmds[i].Val()
+		c := &model.RSVPCounts{}
+		c.Going, _ = strconv.ParseInt(values[string(model.RSVPGoing)], 10, 64)
+		c.Maybe, _ = strconv.ParseInt(values[string(model.RSVPMaybe)], 10, 64)
+		c.Declined, _ = strconv.ParseInt(values[string(model.RSVPDeclined)], 10, 64)
+		counts[id] = c
+	}
+	return counts, nil
+}
+
+// ScheduleGroupEventReminder 安排群活动提醒
+func (s *RedisStore) ScheduleGroupEventReminder(messageID string, remindAt time.Time) error {
+	return s.client.ZAdd(s.ctx, groupEventRemindersKey, redis.Z{Score: float64(remindAt.Unix()), Member: messageID}).Err()
+}
+
+// GetDueGroupEventReminders 获取到期的群活动提醒，按提醒时间排序
+func (s *RedisStore) GetDueGroupEventReminders(now time.Time, limit int64) ([]string, error) {
+	return s.client.ZRangeByScore(s.ctx, groupEventRemindersKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: limit,
+	}).Result()
+}
+
+// RemoveGroupEventReminder 移除群活动提醒，返回是否由本次调用移除，用于避免重复推送
+func (s *RedisStore) RemoveGroupEventReminder(messageID string) (bool, error) {
+	n, err := s.client.ZRem(s.ctx, groupEventRemindersKey, messageID).Result()
+	return n > 0, err
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+// guestTokenKey 访客令牌，值为令牌和密钥摘要的JSON，随令牌到期过期
+func guestTokenKey(id string) string {
+	return fmt.Sprintf("guest:token:%s", id)
+}
+
+// groupGuestTokensKey 群组的访客令牌索引，score为过期时间
+func groupGuestTokensKey(groupID string) string {
+	return fmt.Sprintf("guest:tokens:%s", groupID)
+}
+
+// storedGuestToken Redis中保存的访客令牌，密钥只保存摘要
+type storedGuestToken struct {
+	*model.GuestToken
+	SecretHash string `json:"secret_hash"`
+}
+
+// SetGuestToken 保存访客令牌并加入群组索引，同时清理索引中已过期的令牌
+func (s *RedisStore) SetGuestToken(token *model.GuestToken, secretHash string) error {
+	data, err := json.Marshal(storedGuestToken{GuestToken: token, SecretHash: secretHash})
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(time.Unix(token.ExpiresAt, 0))
+	if ttl <= 0 {
+		return fmt.Errorf("guest token %s already expired", token.ID)
+	}
+
+	indexKey := groupGuestTokensKey(token.GroupID)
+	pipe := s.client.TxPipeline()
+	pipe.Set(s.ctx, guestTokenKey(token.ID), data, ttl)
+	pipe.ZAdd(s.ctx, indexKey, redis.Z{Score: float64(token.ExpiresAt), Member: token.ID})
+	pipe.ZRemRangeByScore(s.ctx, indexKey, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+	_, err = pipe.Exec(s.ctx)
+	return err
+}
+
+// GetGuestToken 获取有效的访客令牌及其密钥摘要，不存在或已过期时ok为false
+func (s *RedisStore) GetGuestToken(id string) (*model.GuestToken, string, bool, error) {
+	data, err := s.client.Get(s.ctx, guestTokenKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, "", false, nil
+	}
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	var stored storedGuestToken
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, "", false, err
+	}
+	if stored.GuestToken == nil {
+		return nil, "", false, nil
+	}
+	return stored.GuestToken, stored.SecretHash, true, nil
+}
+
+// DeleteGuestToken 删除访客令牌，返回是否存在
+func (s *RedisStore) DeleteGuestToken(token *model.GuestToken) (bool, error) {
+	pipe := s.client.TxPipeline()
+	deleted := pipe.Del(s.ctx, guestTokenKey(token.ID))
+	pipe.ZRem(s.ctx, groupGuestTokensKey(token.GroupID), token.ID)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return false, err
+	}
+	return deleted.Val() > 0, nil
+}
+
+// ListGuestTokens 获取群组未过期的访客令牌，按过期时间排序，同时清理索引中已过期的令牌
+func (s *RedisStore) ListGuestTokens(groupID string) ([]*model.GuestToken, error) {
+	indexKey := groupGuestTokensKey(groupID)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	pipe := s.client.TxPipeline()
+	pipe.ZRemRangeByScore(s.ctx, indexKey, "-inf", now)
+	members := pipe.ZRange(s.ctx, indexKey, 0, -1)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return nil, err
+	}
+	ids := members.Val()
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = guestTokenKey(id)
+	}
+	values, err := s.client.MGet(s.ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]*model.GuestToken, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var stored storedGuestToken
+		if err := json.Unmarshal([]byte(data), &stored); err != nil || stored.GuestToken == nil {
+			continue
+		}
+		tokens = append(tokens, stored.GuestToken)
+	}
+	return tokens, nil
+}
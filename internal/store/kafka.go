@@ -4,36 +4,96 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
+	"strconv"
+	"time"
 
 	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
 	"github.com/user/im/internal/config"
 	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
 )
 
 // KafkaStore Kafka存储实现
 type KafkaStore struct {
-	config *config.KafkaConfig
-	ctx    context.Context
+	config    *config.KafkaConfig
+	ctx       context.Context
+	dialer    *kafka.Dialer    // 管理连接和消费者使用
+	transport *kafka.Transport // 生产者使用，所有写入共享连接池
+	lag       *lagTracker
 }
 
 // NewKafkaStore 创建Kafka存储实例
 func NewKafkaStore(cfg *config.KafkaConfig) (*KafkaStore, error) {
 	ctx := context.Background()
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure kafka tls: %w", err)
+	}
+	mechanism, err := newSASLMechanism(cfg.SASL)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &KafkaStore{
+		config: cfg,
+		ctx:    ctx,
+		dialer: &kafka.Dialer{
+			Timeout:       10 * time.Second,
+			DualStack:     true,
+			TLS:           tlsConfig,
+			SASLMechanism: mechanism,
+		},
+		transport: &kafka.Transport{
+			TLS:  tlsConfig,
+			SASL: mechanism,
+		},
+		lag: newLagTracker(),
+	}
+
+	// 首次部署时主题尚不存在，需先创建再测试连接
+	if cfg.Provision.Enabled {
+		if err := s.EnsureTopics(cfg.TopicNames()...); err != nil {
+			return nil, err
+		}
+	}
 
 	// 测试连接
-	conn, err := kafka.DialLeader(ctx, "tcp", cfg.Brokers[0], cfg.Topics.MessageQueue, 0)
+	conn, err := s.dialer.DialLeader(ctx, "tcp", cfg.Brokers[0], cfg.Topics.MessageQueue, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to kafka: %w", err)
 	}
 	defer conn.Close()
 
-	return &KafkaStore{
-		config: cfg,
-		ctx:    ctx,
-	}, nil
+	return s, nil
+}
+
+// newSASLMechanism 根据配置创建SASL认证机制，未配置时返回nil
+func newSASLMechanism(cfg config.KafkaSASLConfig) (sasl.Mechanism, error) {
+	switch cfg.Mechanism {
+	case "":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case "scram-sha-256", "scram-sha-512":
+		algorithm := scram.SHA256
+		if cfg.Mechanism == "scram-sha-512" {
+			algorithm = scram.SHA512
+		}
+		mechanism, err := scram.Mechanism(algorithm, cfg.Username, cfg.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure kafka sasl: %w", err)
+		}
+		return mechanism, nil
+	default:
+		return nil, fmt.Errorf("unsupported kafka sasl mechanism: %s", cfg.Mechanism)
+	}
 }
 
-// SendMessage 发送消息到队列
+// SendMessage 发送消息到队列，按会话哈希分区，保证同一会话内的消息有序
 func (s *KafkaStore) SendMessage(topic string, message *model.Message) error {
 	data, err := json.Marshal(message)
 	if err != nil {
@@ -41,18 +101,146 @@ func (s *KafkaStore) SendMessage(topic string, message *model.Message) error {
 	}
 
 	writer := &kafka.Writer{
-		Addr:     kafka.TCP(s.config.Brokers...),
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+		Addr:      kafka.TCP(s.config.Brokers...),
+		Topic:     topic,
+		Balancer:  &kafka.Hash{},
+		Transport: s.transport,
+	}
+	defer writer.Close()
+
+	return writer.WriteMessages(s.ctx, kafka.Message{
+		Key:   []byte(PartitionKey(message)),
+		Value: data,
+	})
+}
+
+// PartitionKey 消息的分区键：群聊为群组ID，私聊为与方向无关的会话键
+func PartitionKey(message *model.Message) string {
+	if message.IsGroupMessage() {
+		return "group:" + message.GroupID
+	}
+	a, b := message.SenderID, message.ReceiverID
+	if a > b {
+		a, b = b, a
+	}
+	return "private:" + a + ":" + b
+}
+
+// Publish 发送任意JSON数据到指定主题
+func (s *KafkaStore) Publish(topic, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(s.config.Brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		AllowAutoTopicCreation: true,
+		Transport:              s.transport,
 	}
 	defer writer.Close()
 
 	return writer.WriteMessages(s.ctx, kafka.Message{
-		Key:   []byte(message.ID),
+		Key:   []byte(key),
+		Value: data,
+	})
+}
+
+// AsyncPublisher 长期持有的异步写入器，写入立即返回并在后台批量发送，发送失败只记录日志
+type AsyncPublisher struct {
+	writer *kafka.Writer
+	ctx    context.Context
+}
+
+// NewAsyncPublisher 创建指定主题的异步写入器
+func (s *KafkaStore) NewAsyncPublisher(topic string) *AsyncPublisher {
+	return &AsyncPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(s.config.Brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			Transport:    s.transport,
+			BatchTimeout: 50 * time.Millisecond,
+			Async:        true,
+			Completion: func(messages []kafka.Message, err error) {
+				if err != nil {
+					logger.Warn("Failed to publish to kafka", logger.String("topic", topic), logger.Int("count", len(messages)), logger.ErrorField(err))
+				}
+			},
+		},
+		ctx: s.ctx,
+	}
+}
+
+// Publish 序列化后加入发送队列
+func (p *AsyncPublisher) Publish(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return p.writer.WriteMessages(p.ctx, kafka.Message{
+		Key:   []byte(key),
 		Value: data,
 	})
 }
 
+// Close 发送队列中剩余的记录后关闭
+func (p *AsyncPublisher) Close() error {
+	return p.writer.Close()
+}
+
+// Consume 以指定消费者组消费主题中的原始数据
+func (s *KafkaStore) Consume(topic, groupID string, handler func(value []byte) error) error {
+	return s.consume(kafka.ReaderConfig{
+		Brokers:  s.config.Brokers,
+		Topic:    topic,
+		GroupID:  groupID,
+		MinBytes: 1,
+		MaxBytes: 10e6, // 10MB
+		Dialer:   s.dialer,
+	}, handler)
+}
+
+// consume 读取主题并交给按键分发的工作池处理，读取失败时等待已分发的记录处理完后返回
+func (s *KafkaStore) consume(readerConfig kafka.ReaderConfig, handler func(value []byte) error) error {
+	reader := kafka.NewReader(readerConfig)
+	defer reader.Close()
+
+	spec := s.config.ConsumerSpec(readerConfig.Topic)
+	pool := newKeyedPool(readerConfig.Topic, spec.Workers, spec.QueueSize, func(msg kafka.Message) {
+		if err := handler(msg.Value); err != nil {
+			// 记录错误但继续处理
+			fmt.Printf("Error handling message: %v\n", err)
+		}
+	})
+	defer pool.close()
+
+	for {
+		msg, err := reader.ReadMessage(s.ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+		s.lag.record(msg)
+		pool.dispatch(msg)
+	}
+}
+
+// Lag 本节点消费的各主题当前延迟，只包含已读到过记录的主题
+func (s *KafkaStore) Lag() map[string]int64 {
+	return s.lag.totals()
+}
+
+// Ping 检查Kafka集群是否可连接
+func (s *KafkaStore) Ping() error {
+	conn, err := s.dialer.DialContext(s.ctx, "tcp", s.config.Brokers[0])
+	if err != nil {
+		return fmt.Errorf("failed to connect to kafka: %w", err)
+	}
+	return conn.Close()
+}
+
 // SendGroupMessage 发送群聊消息
 func (s *KafkaStore) SendGroupMessage(groupID string, message *model.Message) error {
 	return s.SendMessage(s.config.Topics.GroupChat, message)
@@ -65,31 +253,20 @@ func (s *KafkaStore) SendOfflineMessage(message *model.Message) error {
 
 // ConsumeMessages 消费消息
 func (s *KafkaStore) ConsumeMessages(topic string, handler func(*model.Message) error) error {
-	reader := kafka.NewReader(kafka.ReaderConfig{
+	return s.consume(kafka.ReaderConfig{
 		Brokers:  s.config.Brokers,
 		Topic:    topic,
 		GroupID:  s.config.GroupID,
 		MinBytes: 10e3, // 10KB
 		MaxBytes: 10e6, // 10MB
-	})
-	defer reader.Close()
-
-	for {
-		msg, err := reader.ReadMessage(s.ctx)
-		if err != nil {
-			return fmt.Errorf("failed to read message: %w", err)
-		}
-
+		Dialer:   s.dialer,
+	}, func(value []byte) error {
 		var message model.Message
-		if err := json.Unmarshal(msg.Value, &message); err != nil {
-			continue
+		if err := json.Unmarshal(value, &message); err != nil {
+			return nil
 		}
-
-		if err := handler(&message); err != nil {
-			// 记录错误但继续处理
-			fmt.Printf("Error handling message: %v\n", err)
-		}
-	}
+		return handler(&message)
+	})
 }
 
 // ConsumeGroupMessages 消费群聊消息
@@ -104,22 +281,46 @@ func (s *KafkaStore) ConsumeOfflineMessages(handler func(*model.Message) error)
 
 // CreateTopic 创建主题
 func (s *KafkaStore) CreateTopic(topic string, partitions int, replicationFactor int) error {
-	conn, err := kafka.Dial("tcp", s.config.Brokers[0])
+	return s.createTopics(kafka.TopicConfig{
+		Topic:             topic,
+		NumPartitions:     partitions,
+		ReplicationFactor: replicationFactor,
+	})
+}
+
+// EnsureTopics 按配置的分区数和副本数创建缺失的主题，已存在的主题不做修改
+func (s *KafkaStore) EnsureTopics(topics ...string) error {
+	topicConfigs := make([]kafka.TopicConfig, 0, len(topics))
+	for _, topic := range topics {
+		spec := s.config.TopicSpec(topic)
+		topicConfigs = append(topicConfigs, kafka.TopicConfig{
+			Topic:             topic,
+			NumPartitions:     spec.Partitions,
+			ReplicationFactor: spec.ReplicationFactor,
+		})
+	}
+	return s.createTopics(topicConfigs...)
+}
+
+// createTopics 在控制器节点上创建主题，其他节点会拒绝创建请求
+func (s *KafkaStore) createTopics(topicConfigs ...kafka.TopicConfig) error {
+	conn, err := s.dialer.DialContext(s.ctx, "tcp", s.config.Brokers[0])
 	if err != nil {
 		return fmt.Errorf("failed to connect to kafka: %w", err)
 	}
 	defer conn.Close()
 
-	topicConfigs := []kafka.TopicConfig{
-		{
-			Topic:             topic,
-			NumPartitions:     partitions,
-			ReplicationFactor: replicationFactor,
-		},
+	controller, err := conn.Controller()
+	if err != nil {
+		return fmt.Errorf("failed to get kafka controller: %w", err)
 	}
-
-	err = conn.CreateTopics(topicConfigs...)
+	controllerConn, err := s.dialer.DialContext(s.ctx, "tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
 	if err != nil {
+		return fmt.Errorf("failed to connect to kafka controller: %w", err)
+	}
+	defer controllerConn.Close()
+
+	if err := controllerConn.CreateTopics(topicConfigs...); err != nil {
 		return fmt.Errorf("failed to create topic: %w", err)
 	}
 
@@ -128,7 +329,7 @@ func (s *KafkaStore) CreateTopic(topic string, partitions int, replicationFactor
 
 // GetTopicInfo 获取主题信息
 func (s *KafkaStore) GetTopicInfo(topic string) (*kafka.Topic, error) {
-	conn, err := kafka.Dial("tcp", s.config.Brokers[0])
+	conn, err := s.dialer.DialContext(s.ctx, "tcp", s.config.Brokers[0])
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to kafka: %w", err)
 	}
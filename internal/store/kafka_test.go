@@ -0,0 +1,19 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestPartitionKey(t *testing.T) {
+	// 私聊双方方向无关，落在同一分区
+	ab := &model.Message{SenderID: "alice", ReceiverID: "bob"}
+	ba := &model.Message{SenderID: "bob", ReceiverID: "alice"}
+	assert.Equal(t, "private:alice:bob", PartitionKey(ab))
+	assert.Equal(t, PartitionKey(ab), PartitionKey(ba))
+
+	group := &model.Message{SenderID: "alice", GroupID: "g1"}
+	assert.Equal(t, "group:g1", PartitionKey(group))
+}
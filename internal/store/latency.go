@@ -0,0 +1,91 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+const (
+	// traceFieldTrace 投递追踪中保存阶段时间的字段，其余字段为已确认的接收者
+	traceFieldTrace = "trace"
+	// LatencyFieldWithinTarget 每日统计中在目标耗时内完成的投递数
+	LatencyFieldWithinTarget = "within_target"
+)
+
+// deliveryTraceKey 采样消息首次推送后的投递追踪，等待接收者确认
+func deliveryTraceKey(messageID string) string {
+	return fmt.Sprintf("delivery:trace:%s", messageID)
+}
+
+// LatencyField 每日统计中某阶段某分桶的字段，bucket为分桶上界（毫秒）或inf
+func LatencyField(stage model.DeliveryStage, bucket string) string {
+	return fmt.Sprintf("%s:%s", stage, bucket)
+}
+
+// SaveDeliveryTrace 保存消息首次推送时的阶段时间，已保存时不覆盖，返回是否为首次保存
+func (s *RedisStore) SaveDeliveryTrace(messageID string, trace *model.DeliveryTrace, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(trace)
+	if err != nil {
+		return false, err
+	}
+	key := deliveryTraceKey(messageID)
+	pipe := s.client.TxPipeline()
+	created := pipe.HSetNX(s.ctx, key, traceFieldTrace, data)
+	pipe.ExpireNX(s.ctx, key, ttl)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return false, err
+	}
+	return created.Val(), nil
+}
+
+// AckDeliveryTrace 记录接收者的首次确认，返回推送时的阶段时间；消息未被追踪或该接收者已确认过时返回nil
+func (s *RedisStore) AckDeliveryTrace(messageID, userID string) (*model.DeliveryTrace, error) {
+	key := deliveryTraceKey(messageID)
+	data, err := s.client.HGet(s.ctx, key, traceFieldTrace).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	first, err := s.client.HSetNX(s.ctx, key, userID, 1).Result()
+	if err != nil || !first {
+		return nil, err
+	}
+
+	var trace model.DeliveryTrace
+	if err := json.Unmarshal(data, &trace); err != nil {
+		return nil, err
+	}
+	return &trace, nil
+}
+
+// IncrLatencyStats 累加某日各阶段分桶的计数，fields为LatencyField或LatencyFieldWithinTarget
+func (s *RedisStore) IncrLatencyStats(day string, fields []string, ttl time.Duration) error {
+	key := analyticsKey(day, "latency")
+	pipe := s.client.TxPipeline()
+	for _, field := range fields {
+		pipe.HIncrBy(s.ctx, key, field, 1)
+	}
+	pipe.Expire(s.ctx, key, ttl)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// GetLatencyStats 获取某日各阶段分桶的计数
+func (s *RedisStore) GetLatencyStats(day string) (map[string]int64, error) {
+	values, err := s.client.HGetAll(s.ctx, analyticsKey(day, "latency")).Result()
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(values))
+	for field, raw := range values {
+		counts[field], _ = strconv.ParseInt(raw, 10, 64)
+	}
+	return counts, nil
+}
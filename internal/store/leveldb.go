@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/util"
@@ -13,21 +14,36 @@ import (
 
 // LevelDBStore LevelDB存储实现
 type LevelDBStore struct {
-	db   *leveldb.DB
-	lock sync.RWMutex
+	db            *leveldb.DB
+	lock          sync.RWMutex
+	slowThreshold time.Duration
 }
 
+// defaultLevelDBSlowThreshold LevelDB慢操作日志的默认阈值
+const defaultLevelDBSlowThreshold = 50 * time.Millisecond
+
 // NewLevelDBStore 创建LevelDB存储实例
 func NewLevelDBStore(dbPath string) (*LevelDBStore, error) {
 	db, err := leveldb.OpenFile(filepath.Clean(dbPath), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open leveldb: %w", err)
 	}
-	return &LevelDBStore{db: db}, nil
+	return &LevelDBStore{db: db, slowThreshold: defaultLevelDBSlowThreshold}, nil
+}
+
+// SetSlowThreshold 设置慢操作日志的阈值，需在开始读写前调用
+func (s *LevelDBStore) SetSlowThreshold(threshold time.Duration) {
+	s.slowThreshold = threshold
+}
+
+// observe 记录一次操作的耗时，包括等待锁的时间
+func (s *LevelDBStore) observe(operation string, start time.Time) {
+	observeStore(storeLevelDB, operation, time.Since(start), s.slowThreshold, nil)
 }
 
 // SaveMessage 保存消息
 func (s *LevelDBStore) SaveMessage(message *model.Message) error {
+	defer s.observe("save_message", time.Now())
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	key := s.messageKey(message.ID)
@@ -40,6 +56,7 @@ func (s *LevelDBStore) SaveMessage(message *model.Message) error {
 
 // GetMessage 获取消息
 func (s *LevelDBStore) GetMessage(messageID string) (*model.Message, error) {
+	defer s.observe("get_message", time.Now())
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 	key := s.messageKey(messageID)
@@ -56,6 +73,7 @@ func (s *LevelDBStore) GetMessage(messageID string) (*model.Message, error) {
 
 // GetOfflineMessages 获取离线消息（按时间顺序）
 func (s *LevelDBStore) GetOfflineMessages(userID string, lastMessageID string, limit int) ([]*model.Message, error) {
+	defer s.observe("get_offline_messages", time.Now())
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 	prefix := s.offlineKey(userID)
@@ -80,6 +98,7 @@ func (s *LevelDBStore) GetOfflineMessages(userID string, lastMessageID string, l
 
 // SetOfflineMessage 添加离线消息
 func (s *LevelDBStore) SetOfflineMessage(userID string, message *model.Message) error {
+	defer s.observe("set_offline_message", time.Now())
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	key := s.offlineKey(userID) + message.ID
@@ -92,12 +111,63 @@ func (s *LevelDBStore) SetOfflineMessage(userID string, message *model.Message)
 
 // RemoveOfflineMessage 删除离线消息
 func (s *LevelDBStore) RemoveOfflineMessage(userID, messageID string) error {
+	defer s.observe("remove_offline_message", time.Now())
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	key := s.offlineKey(userID) + messageID
 	return s.db.Delete([]byte(key), nil)
 }
 
+// ClearOfflineMessages 删除用户的全部离线索引，消息本身保留，返回删除的条目数
+func (s *LevelDBStore) ClearOfflineMessages(userID string) (int, error) {
+	defer s.observe("clear_offline_messages", time.Now())
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	batch := new(leveldb.Batch)
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(s.offlineKey(userID))), nil)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+	return batch.Len(), s.db.Write(batch, nil)
+}
+
+// updateMessage 读取并修改消息，同时更新离线索引中的副本
+func (s *LevelDBStore) updateMessage(messageID string, fn func(*model.Message)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := []byte(s.messageKey(messageID))
+	raw, err := s.db.Get(key, nil)
+	if err != nil {
+		return err
+	}
+	var message model.Message
+	if err := json.Unmarshal(raw, &message); err != nil {
+		return err
+	}
+	fn(&message)
+	message.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(&message)
+	if err != nil {
+		return err
+	}
+	batch := new(leveldb.Batch)
+	batch.Put(key, data)
+	if message.IsPrivateMessage() {
+		offlineKey := []byte(s.offlineKey(message.ReceiverID) + messageID)
+		if ok, err := s.db.Has(offlineKey, nil); err == nil && ok {
+			batch.Put(offlineKey, data)
+		}
+	}
+	return s.db.Write(batch, nil)
+}
+
 // Close 关闭LevelDB
 func (s *LevelDBStore) Close() error {
 	return s.db.Close()
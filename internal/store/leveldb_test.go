@@ -2,6 +2,7 @@ package store
 
 import (
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
@@ -68,6 +69,14 @@ func TestLevelDBStore_OfflineMessages(t *testing.T) {
 	got, err = store.GetOfflineMessages(userID, "", 10)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(got))
+
+	// 清空离线索引
+	cleared, err := store.ClearOfflineMessages(userID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cleared)
+	got, err = store.GetOfflineMessages(userID, "", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
 }
 
 func TestLevelDBStore_Concurrent(t *testing.T) {
@@ -85,7 +94,7 @@ func TestLevelDBStore_Concurrent(t *testing.T) {
 	go func() {
 		for i := 0; i < 100; i++ {
 			msg := &model.Message{
-				ID:         "mc" + string(i),
+				ID:         "mc" + strconv.Itoa(i),
 				SenderID:   "A",
 				ReceiverID: userID,
 				Content:    "c",
@@ -105,3 +114,110 @@ func TestLevelDBStore_Concurrent(t *testing.T) {
 	<-done
 	<-done
 }
+
+func TestLevelDBStore_Deletions(t *testing.T) {
+	dbPath := "./testdata/leveldb4"
+	_ = os.RemoveAll(dbPath)
+	store, err := NewLevelDBStore(dbPath)
+	assert.NoError(t, err)
+	defer func() {
+		store.Close()
+		_ = os.RemoveAll(dbPath)
+	}()
+
+	msg := &model.Message{ID: "md1", SenderID: "A", ReceiverID: "B", Content: "secret", Timestamp: 1, Status: "sent"}
+	assert.NoError(t, store.SaveMessage(msg))
+	assert.NoError(t, store.SetOfflineMessage("B", msg))
+
+	// 对自己删除
+	assert.NoError(t, store.MarkMessageDeleted("A", "md1"))
+	deleted, err := store.GetDeletedMessageIDs("A", []string{"md1", "md2"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"md1": true}, deleted)
+	deleted, err = store.GetDeletedMessageIDs("B", []string{"md1"})
+	assert.NoError(t, err)
+	assert.Empty(t, deleted)
+
+	// 对所有人删除，离线副本同步变为墓碑
+	assert.NoError(t, store.TombstoneMessage("md1", 100))
+	tombstones, err := store.GetTombstones([]string{"md1", "md2"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"md1": 100}, tombstones)
+	offline, err := store.GetOfflineMessages("B", "", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(offline))
+	assert.Equal(t, "", offline[0].Content)
+	assert.True(t, offline[0].IsDeleted())
+
+	// 物理删除
+	assert.NoError(t, store.PurgeMessage("md1"))
+	_, err = store.GetMessage("md1")
+	assert.Error(t, err)
+	offline, err = store.GetOfflineMessages("B", "", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, offline)
+	deleted, err = store.GetDeletedMessageIDs("A", []string{"md1"})
+	assert.NoError(t, err)
+	assert.Empty(t, deleted)
+}
+
+func TestLevelDBStore_Receipts(t *testing.T) {
+	dbPath := "./testdata/leveldb5"
+	_ = os.RemoveAll(dbPath)
+	store, err := NewLevelDBStore(dbPath)
+	assert.NoError(t, err)
+	defer func() {
+		store.Close()
+		_ = os.RemoveAll(dbPath)
+	}()
+
+	assert.NoError(t, store.SaveMessage(&model.Message{ID: "mr1", SenderID: "A", GroupID: "g1", Timestamp: 1}))
+	assert.NoError(t, store.SaveReceipt("mr1", "B", model.MessageStatusDelivered, 10))
+	assert.NoError(t, store.SaveReceipt("mr1", "B", model.MessageStatusRead, 20))
+	// 重复确认不覆盖首次时间
+	assert.NoError(t, store.SaveReceipt("mr1", "B", model.MessageStatusDelivered, 30))
+	// 直接已读同时视为已投递
+	assert.NoError(t, store.SaveReceipt("mr1", "C", model.MessageStatusRead, 40))
+
+	receipts, err := store.GetReceipts("mr1")
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.MessageReceipt{
+		{MessageID: "mr1", UserID: "B", DeliveredAt: 10, ReadAt: 20},
+		{MessageID: "mr1", UserID: "C", DeliveredAt: 40, ReadAt: 40},
+	}, receipts)
+
+	assert.NoError(t, store.PurgeMessage("mr1"))
+	receipts, err = store.GetReceipts("mr1")
+	assert.NoError(t, err)
+	assert.Empty(t, receipts)
+}
+
+func TestLevelDBStore_ThreadSummaries(t *testing.T) {
+	dbPath := "./testdata/leveldb6"
+	_ = os.RemoveAll(dbPath)
+	store, err := NewLevelDBStore(dbPath)
+	assert.NoError(t, err)
+	defer func() {
+		store.Close()
+		_ = os.RemoveAll(dbPath)
+	}()
+
+	root := &model.Message{ID: "mt1", SenderID: "A", ReceiverID: "B", Timestamp: 1}
+	assert.NoError(t, store.SaveMessage(root))
+	assert.NoError(t, store.SetOfflineMessage("B", root))
+	assert.NoError(t, store.SaveMessage(&model.Message{ID: "mt2", SenderID: "A", ReceiverID: "B", Timestamp: 2}))
+
+	assert.NoError(t, store.UpdateThreadSummary("mt1", 1, 20))
+	assert.NoError(t, store.UpdateThreadSummary("mt1", 1, 10))
+	// 删除回复只减少计数，不回退最近回复时间
+	assert.NoError(t, store.UpdateThreadSummary("mt1", -1, 0))
+
+	summaries, err := store.GetThreadSummaries([]string{"mt1", "mt2", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]model.ThreadSummary{"mt1": {ReplyCount: 1, LastReplyAt: 20}}, summaries)
+
+	// 离线索引中的副本同步更新
+	offline, err := store.GetOfflineMessages("B", "", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), offline[0].ReplyCount)
+}
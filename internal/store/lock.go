@@ -0,0 +1,189 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/pkg/logger"
+)
+
+// ErrLockNotAcquired 等待超时仍未获取到锁
+var ErrLockNotAcquired = errors.New("lock not acquired")
+
+// ErrStaleFence 写入携带的fencing token小于资源上已记录的token，锁已过期并被其他持有者获取
+var ErrStaleFence = errors.New("stale fencing token")
+
+// ErrLockLost 续期失败、锁已不属于当前持有者，持锁期间的写入不再执行
+var ErrLockLost = errors.New("lock lost")
+
+// lockRetryInterval 锁被占用时的重试间隔
+const lockRetryInterval = 20 * time.Millisecond
+
+// LockBackend 分布式锁的存储，由RedisStore实现
+type LockBackend interface {
+	AcquireLock(key, owner string, ttl time.Duration) (int64, bool, error)
+	RenewLock(key, owner string, ttl time.Duration) (bool, error)
+	ReleaseLock(key, owner string) error
+}
+
+// Locker 基于Redis的分布式锁，SET NX PX加锁，每次加锁获得递增的fencing token，持有期间自动续期
+// 锁可能在持有者停顿时过期，写入共享数据时应携带token，由存储拒绝旧token的写入
+type Locker struct {
+	backend LockBackend
+	owner   string
+	ttl     time.Duration
+	wait    time.Duration
+	seq     atomic.Uint64
+}
+
+// NewLocker 创建分布式锁，owner标识本节点，ttl为锁的过期时间，wait为获取锁的最长等待时间
+func NewLocker(backend LockBackend, owner string, ttl, wait time.Duration) *Locker {
+	return &Locker{
+		backend: backend,
+		owner:   owner,
+		ttl:     ttl,
+		wait:    wait,
+	}
+}
+
+// Lock 持有中的锁
+type Lock struct {
+	backend LockBackend
+	key     string
+	owner   string
+	token   int64
+	lost    atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// lockKey 锁的键，fencing token计数器为键加:fence后缀
+func lockKey(name string) string {
+	return "lock:" + name
+}
+
+// Acquire 获取锁，被占用时在wait内重试，超时返回ErrLockNotAcquired
+func (l *Locker) Acquire(name string) (*Lock, error) {
+	key := lockKey(name)
+	// 同一节点的并发请求使用不同的持有者，互相排斥
+	owner := l.owner + ":" + strconv.FormatUint(l.seq.Add(1), 10)
+	deadline := time.Now().Add(l.wait)
+	for {
+		token, ok, err := l.backend.AcquireLock(key, owner, l.ttl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+		}
+		if ok {
+			lock := &Lock{
+				backend: l.backend,
+				key:     key,
+				owner:   owner,
+				token:   token,
+				stop:    make(chan struct{}),
+				done:    make(chan struct{}),
+			}
+			go lock.renew(l.ttl)
+			return lock, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLockNotAcquired
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// WithLock 持有锁执行fn，fn中的带fencing token的写入在锁丢失后返回ErrLockLost
+func (l *Locker) WithLock(name string, fn func(lock *Lock) error) error {
+	lock, err := l.Acquire(name)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	return fn(lock)
+}
+
+// Token 本次加锁获得的fencing token，未加锁（nil）时为0
+func (lk *Lock) Token() int64 {
+	if lk == nil {
+		return 0
+	}
+	return lk.token
+}
+
+// Lost 续期失败、锁已不属于当前持有者，未加锁（nil）时为false
+func (lk *Lock) Lost() bool {
+	return lk != nil && lk.lost.Load()
+}
+
+// Release 停止续期并释放锁，锁已丢失时不释放他人的锁
+func (lk *Lock) Release() {
+	lk.once.Do(func() {
+		close(lk.stop)
+		<-lk.done
+		if err := lk.backend.ReleaseLock(lk.key, lk.owner); err != nil {
+			logger.Warn("Failed to release lock", logger.String("key", lk.key), logger.ErrorField(err))
+		}
+	})
+}
+
+// renew 每三分之一个ttl续期一次，直到释放或锁丢失
+func (lk *Lock) renew(ttl time.Duration) {
+	defer close(lk.done)
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lk.stop:
+			return
+		case <-ticker.C:
+			ok, err := lk.backend.RenewLock(lk.key, lk.owner, ttl)
+			if err != nil {
+				logger.Warn("Failed to renew lock", logger.String("key", lk.key), logger.ErrorField(err))
+				continue
+			}
+			if !ok {
+				lk.lost.Store(true)
+				logger.Warn("Lock lost before release", logger.String("key", lk.key))
+				return
+			}
+		}
+	}
+}
+
+// checkFence 比较并记录资源上的fencing token，token小于已记录的值时返回0；token为0表示未加锁，不检查
+const checkFence = `
+local token = tonumber(ARGV[1])
+if token ~= 0 then
+	local fence = tonumber(redis.call("GET", KEYS[1]) or "0")
+	if token < fence then
+		return 0
+	end
+	redis.call("SET", KEYS[1], token)
+end
+`
+
+// fencedScript 在checkFence之后执行写入，KEYS[1]为资源的token键，ARGV[1]为token
+func fencedScript(body string) *redis.Script {
+	return redis.NewScript(checkFence + body + "\nreturn 1")
+}
+
+// runFenced 执行带fencing token的脚本，lock为nil时不检查；锁已丢失时不执行并返回ErrLockLost，资源上已有更大的token时返回ErrStaleFence
+func (s *RedisStore) runFenced(script *redis.Script, lock *Lock, keys []string, args ...interface{}) error {
+	if lock.Lost() {
+		return ErrLockLost
+	}
+	n, err := script.Run(s.ctx, s.client, keys, append([]interface{}{lock.Token()}, args...)...).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrStaleFence
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLockBackend 内存中的锁存储，与Redis脚本语义一致：加锁时递增fencing token，续期和释放检查持有者
+type fakeLockBackend struct {
+	mu     sync.Mutex
+	owners map[string]string
+	fences map[string]int64
+}
+
+func newFakeLockBackend() *fakeLockBackend {
+	return &fakeLockBackend{owners: make(map[string]string), fences: make(map[string]int64)}
+}
+
+func (b *fakeLockBackend) AcquireLock(key, owner string, ttl time.Duration) (int64, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, held := b.owners[key]; held {
+		return 0, false, nil
+	}
+	b.owners[key] = owner
+	b.fences[key]++
+	return b.fences[key], true, nil
+}
+
+func (b *fakeLockBackend) RenewLock(key, owner string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.owners[key] == owner, nil
+}
+
+func (b *fakeLockBackend) ReleaseLock(key, owner string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.owners[key] == owner {
+		delete(b.owners, key)
+	}
+	return nil
+}
+
+// expire 模拟锁过期
+func (b *fakeLockBackend) expire(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.owners, key)
+}
+
+func (b *fakeLockBackend) owner(key string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.owners[key]
+}
+
+func TestLocker_SecondAcquirerRefused(t *testing.T) {
+	locker := NewLocker(newFakeLockBackend(), "node-1", time.Minute, 50*time.Millisecond)
+
+	lock, err := locker.Acquire("group:g1")
+	assert.NoError(t, err)
+	defer lock.Release()
+
+	_, err = locker.Acquire("group:g1")
+	assert.ErrorIs(t, err, ErrLockNotAcquired)
+
+	// 不同的锁互不影响
+	other, err := locker.Acquire("group:g2")
+	assert.NoError(t, err)
+	other.Release()
+}
+
+func TestLocker_TokenIncreasesOnEachAcquire(t *testing.T) {
+	locker := NewLocker(newFakeLockBackend(), "node-1", time.Minute, 0)
+
+	var last int64
+	for i := 0; i < 3; i++ {
+		lock, err := locker.Acquire("group:g1")
+		assert.NoError(t, err)
+		assert.Greater(t, lock.Token(), last)
+		last = lock.Token()
+		lock.Release()
+	}
+}
+
+func TestLock_ReleaseIsOwnerChecked(t *testing.T) {
+	backend := newFakeLockBackend()
+	locker := NewLocker(backend, "node-1", time.Minute, 0)
+
+	stale, err := locker.Acquire("group:g1")
+	assert.NoError(t, err)
+
+	// 锁过期后被其他持有者获取，旧持有者释放时不删除他人的锁
+	backend.expire(lockKey("group:g1"))
+	current, err := locker.Acquire("group:g1")
+	assert.NoError(t, err)
+	assert.Greater(t, current.Token(), stale.Token())
+
+	stale.Release()
+	assert.Equal(t, current.owner, backend.owner(lockKey("group:g1")))
+
+	current.Release()
+	assert.Empty(t, backend.owner(lockKey("group:g1")))
+}
+
+func TestWithLock_LostLockSkipsFencedWrite(t *testing.T) {
+	backend := newFakeLockBackend()
+	locker := NewLocker(backend, "node-1", 30*time.Millisecond, 0)
+	s := &RedisStore{}
+
+	err := locker.WithLock("group:g1", func(lock *Lock) error {
+		backend.expire(lockKey("group:g1"))
+		assert.Eventually(t, lock.Lost, time.Second, 5*time.Millisecond)
+		// 锁丢失后不再执行脚本，RedisStore未连接也不会访问Redis
+		return s.SetGroupMemberCountFenced("g1", 1, lock)
+	})
+	assert.ErrorIs(t, err, ErrLockLost)
+}
+
+func TestLock_NilIsUnlocked(t *testing.T) {
+	var lock *Lock
+	assert.Zero(t, lock.Token())
+	assert.False(t, lock.Lost())
+}
+
+func TestRedisLock_StaleTokenRejectedByFence(t *testing.T) {
+	s := testRedisStore(t)
+	name := "test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	key := lockKey(name)
+	t.Cleanup(func() { s.client.Del(s.ctx, key, key+":fence", groupFenceKey(name)) })
+
+	token, ok, err := s.AcquireLock(key, "a", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, ok, err = s.AcquireLock(key, "b", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, s.ReleaseLock(key, "b"))
+	owner, err := s.GetLockOwner(key)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", owner)
+	assert.NoError(t, s.ReleaseLock(key, "a"))
+
+	next, ok, err := s.AcquireLock(key, "b", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Greater(t, next, token)
+
+	// 新持有者写入后，携带旧token的写入被拒绝
+	script := fencedScript(``)
+	keys := []string{groupFenceKey(name)}
+	assert.NoError(t, s.runFenced(script, &Lock{token: next}, keys))
+	assert.ErrorIs(t, s.runFenced(script, &Lock{token: token}, keys), ErrStaleFence)
+	assert.NoError(t, s.runFenced(script, nil, keys))
+}
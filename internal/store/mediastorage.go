@@ -0,0 +1,179 @@
+package store
+
+import (
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+const (
+	// mediaExpiryKey 没有消息引用的媒体文件按删除时间排序，生命周期任务据此删除文件
+	mediaExpiryKey = "media:expiry"
+	// MediaUsageUsers 用户上传的媒体文件总字节数
+	MediaUsageUsers = "media:usage:users"
+	// MediaUsageGroups 群组中消息引用的媒体文件总字节数
+	MediaUsageGroups = "media:usage:groups"
+)
+
+func mediaObjectKey(key string) string {
+	return "media:object:" + key
+}
+
+// saveMediaObjectScript 登记媒体文件并计入上传者的用量，已登记的文件不重复计入
+var saveMediaObjectScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+redis.call("HSET", KEYS[1], "owner", ARGV[1], "size", ARGV[2], "refs", 0, "created_at", ARGV[3])
+redis.call("ZINCRBY", KEYS[2], ARGV[2], ARGV[1])
+return 1
+`)
+
+// referenceMediaObjectScript 增加文件的引用数并取消删除，计入群组的用量；文件未登记时返回-1
+var referenceMediaObjectScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+redis.call("HINCRBY", KEYS[1], "refs", 1)
+redis.call("ZREM", KEYS[2], ARGV[1])
+local size = tonumber(redis.call("HGET", KEYS[1], "size"))
+if ARGV[2] ~= "" then
+	redis.call("ZINCRBY", KEYS[3], size, ARGV[2])
+end
+return size
+`)
+
+// dereferenceMediaObjectScript 减少文件的引用数，没有引用时按删除时间登记，并从群组的用量中减去
+var dereferenceMediaObjectScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+local refs = redis.call("HINCRBY", KEYS[1], "refs", -1)
+if refs <= 0 then
+	redis.call("HSET", KEYS[1], "refs", 0)
+	redis.call("ZADD", KEYS[2], ARGV[3], ARGV[1])
+end
+local size = tonumber(redis.call("HGET", KEYS[1], "size"))
+if ARGV[2] ~= "" then
+	if tonumber(redis.call("ZINCRBY", KEYS[3], -size, ARGV[2])) <= 0 then
+		redis.call("ZREM", KEYS[3], ARGV[2])
+	end
+end
+return refs
+`)
+
+// deleteMediaObjectScript 删除文件的登记并从上传者的用量中减去
+var deleteMediaObjectScript = redis.NewScript(`
+local owner = redis.call("HGET", KEYS[1], "owner")
+local size = tonumber(redis.call("HGET", KEYS[1], "size") or "0")
+redis.call("DEL", KEYS[1])
+redis.call("ZREM", KEYS[2], ARGV[1])
+if owner and tonumber(redis.call("ZINCRBY", KEYS[3], -size, owner)) <= 0 then
+	redis.call("ZREM", KEYS[3], owner)
+end
+return 1
+`)
+
+// SaveMediaObject 登记上传完成的媒体文件并计入上传者的用量，返回是否新登记
+func (s *RedisStore) SaveMediaObject(object *model.MediaObject) (bool, error) {
+	created, err := saveMediaObjectScript.Run(s.ctx, s.client, []string{mediaObjectKey(object.Key), MediaUsageUsers},
+		object.OwnerID, object.Size, object.CreatedAt).Int()
+	return created == 1, err
+}
+
+// GetMediaObject 获取媒体文件的登记，未登记时返回nil
+func (s *RedisStore) GetMediaObject(key string) (*model.MediaObject, error) {
+	values, err := s.client.HGetAll(s.ctx, mediaObjectKey(key)).Result()
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+	return parseMediaObject(key, values), nil
+}
+
+// GetMediaObjects 批量获取媒体文件的登记，未登记的文件不在结果中
+func (s *RedisStore) GetMediaObjects(keys []string) (map[string]*model.MediaObject, error) {
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.HGetAll(s.ctx, mediaObjectKey(key))
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	objects := make(map[string]*model.MediaObject, len(keys))
+	for i, cmd := range cmds {
+		if values := cmd.Val(); len(values) > 0 {
+			objects[keys[i]] = parseMediaObject(keys[i], values)
+		}
+	}
+	return objects, nil
+}
+
+// parseMediaObject 解析媒体文件登记的哈希字段
+func parseMediaObject(key string, values map[string]string) *model.MediaObject {
+	object := &model.MediaObject{Key: key, OwnerID: values["owner"]}
+	object.Size, _ = strconv.ParseInt(values["size"], 10, 64)
+	object.Refs, _ = strconv.ParseInt(values["refs"], 10, 64)
+	object.CreatedAt, _ = strconv.ParseInt(values["created_at"], 10, 64)
+	return object
+}
+
+// ReferenceMediaObject 消息引用媒体文件，groupID不为空时计入群组的用量；返回文件大小，未登记时返回false
+func (s *RedisStore) ReferenceMediaObject(key, groupID string) (int64, bool, error) {
+	size, err := referenceMediaObjectScript.Run(s.ctx, s.client, []string{mediaObjectKey(key), mediaExpiryKey, MediaUsageGroups},
+		key, groupID).Int64()
+	if err != nil {
+		return 0, false, err
+	}
+	return size, size >= 0, nil
+}
+
+// DereferenceMediaObject 引用媒体文件的消息被删除，没有引用时文件在deleteAt之后删除
+func (s *RedisStore) DereferenceMediaObject(key, groupID string, deleteAt int64) error {
+	return dereferenceMediaObjectScript.Run(s.ctx, s.client, []string{mediaObjectKey(key), mediaExpiryKey, MediaUsageGroups},
+		key, groupID, deleteAt).Err()
+}
+
+// GetExpiredMediaObjects 获取删除时间不晚于before的媒体文件
+func (s *RedisStore) GetExpiredMediaObjects(before int64, limit int) ([]string, error) {
+	return s.client.ZRangeByScore(s.ctx, mediaExpiryKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(before, 10),
+		Count: int64(limit),
+	}).Result()
+}
+
+// CancelMediaExpiry 取消文件的删除，用于删除前又被引用的文件
+func (s *RedisStore) CancelMediaExpiry(key string) error {
+	return s.client.ZRem(s.ctx, mediaExpiryKey, key).Err()
+}
+
+// DeleteMediaObject 删除媒体文件的登记并从上传者的用量中减去
+func (s *RedisStore) DeleteMediaObject(key string) error {
+	return deleteMediaObjectScript.Run(s.ctx, s.client, []string{mediaObjectKey(key), mediaExpiryKey, MediaUsageUsers}, key).Err()
+}
+
+// GetMediaUsage 获取用户或群组的媒体用量，usageKey为MediaUsageUsers或MediaUsageGroups
+func (s *RedisStore) GetMediaUsage(usageKey, id string) (int64, error) {
+	bytes, err := s.client.ZScore(s.ctx, usageKey, id).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return int64(bytes), err
+}
+
+// GetTopMediaConsumers 按用量从大到小获取用户或群组
+func (s *RedisStore) GetTopMediaConsumers(usageKey string, limit int) ([]*model.MediaConsumer, error) {
+	entries, err := s.client.ZRevRangeWithScores(s.ctx, usageKey, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	consumers := make([]*model.MediaConsumer, 0, len(entries))
+	for _, entry := range entries {
+		id, _ := entry.Member.(string)
+		consumers = append(consumers, &model.MediaConsumer{ID: id, Bytes: int64(entry.Score)})
+	}
+	return consumers, nil
+}
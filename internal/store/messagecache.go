@@ -0,0 +1,34 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"gorm.io/gorm"
+)
+
+// MessageCacheChannel 消息缓存失效通知频道，消息为失效的消息ID，各节点收到后删除本地缓存
+const MessageCacheChannel = "msg:cache:invalidate"
+
+// messageMissingKey 不存在的消息ID的负缓存
+func messageMissingKey(messageID string) string {
+	return fmt.Sprintf("msg:missing:%s", messageID)
+}
+
+// SetMessageMissing 记录消息不存在，ttl内的查询不再访问消息存储
+func (s *RedisStore) SetMessageMissing(messageID string, ttl time.Duration) error {
+	return s.client.Set(s.ctx, messageMissingKey(messageID), 1, ttl).Err()
+}
+
+// IsMessageMissing 消息是否在负缓存中
+func (s *RedisStore) IsMessageMissing(messageID string) (bool, error) {
+	n, err := s.client.Exists(s.ctx, messageMissingKey(messageID)).Result()
+	return n > 0, err
+}
+
+// IsNotFound 消息存储返回的错误是否表示记录不存在，MySQL和LevelDB后端均适用
+func IsNotFound(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, leveldb.ErrNotFound)
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm/clause"
+)
+
+// ScanMessages 按消息ID顺序读取afterID之后的一批消息
+func (s *LevelDBStore) ScanMessages(afterID string, limit int) ([]*model.Message, error) {
+	defer s.observe("scan_messages", time.Now())
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	rng := util.BytesPrefix([]byte(s.messageKey("")))
+	if afterID != "" {
+		// 从afterID的下一个键开始
+		rng.Start = append([]byte(s.messageKey(afterID)), 0)
+	}
+
+	iter := s.db.NewIterator(rng, nil)
+	defer iter.Release()
+
+	var messages []*model.Message
+	for len(messages) < limit && iter.Next() {
+		var message model.Message
+		if err := json.Unmarshal(iter.Value(), &message); err != nil {
+			continue
+		}
+		messages = append(messages, &message)
+	}
+	return messages, iter.Error()
+}
+
+// SaveMessages 批量保存消息，已存在的消息被覆盖
+func (s *LevelDBStore) SaveMessages(messages []*model.Message) error {
+	defer s.observe("save_messages", time.Now())
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	batch := new(leveldb.Batch)
+	for _, message := range messages {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		batch.Put([]byte(s.messageKey(message.ID)), data)
+		// 未送达的私聊消息同时写入离线索引
+		if message.IsPrivateMessage() && message.Status == model.MessageStatusSent {
+			batch.Put([]byte(s.offlineKey(message.ReceiverID)+message.ID), data)
+		}
+	}
+	return s.db.Write(batch, nil)
+}
+
+// CountMessages 统计消息数
+func (s *LevelDBStore) CountMessages() (int64, error) {
+	defer s.observe("count_messages", time.Now())
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(s.messageKey(""))), nil)
+	defer iter.Release()
+
+	var count int64
+	for iter.Next() {
+		count++
+	}
+	return count, iter.Error()
+}
+
+// ScanMessages 按消息ID顺序读取afterID之后的一批消息
+func (s *MySQLStore) ScanMessages(afterID string, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	err := s.db.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// SaveMessages 批量保存消息，主键冲突时覆盖
+func (s *MySQLStore) SaveMessages(messages []*model.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&messages).Error
+}
+
+// CountMessages 统计消息数
+func (s *MySQLStore) CountMessages() (int64, error) {
+	var count int64
+	err := s.db.Model(&model.Message{}).Count(&count).Error
+	return count, err
+}
+
+// ScanGroups 按群组ID顺序读取afterID之后的一批群组
+func (s *MySQLStore) ScanGroups(afterID string, limit int) ([]*model.Group, error) {
+	var groups []*model.Group
+	err := s.db.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&groups).Error
+	return groups, err
+}
+
+// SaveGroup 保存群组及其成员，已存在的记录被覆盖
+func (s *MySQLStore) SaveGroup(group *model.Group, members []*model.GroupMember) error {
+	if err := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(group).Error; err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	return s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&members).Error
+}
@@ -0,0 +1,755 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-gormigrate/gormigrate/v2"
+	"gorm.io/gorm"
+)
+
+// migrationTable 记录已执行迁移的表
+const migrationTable = "schema_migrations"
+
+// 迁移中使用的表结构快照，与当前模型解耦，保证历史迁移在模型演进后仍然可重复执行
+
+type migrationMessage struct {
+	ID         string `gorm:"primaryKey;type:varchar(64)"`
+	SenderID   string `gorm:"type:varchar(64);index"`
+	ReceiverID string `gorm:"type:varchar(64);index"`
+	GroupID    string `gorm:"type:varchar(64);index"`
+	Type       string `gorm:"type:varchar(20)"`
+	Content    string `gorm:"type:text"`
+	Status     string `gorm:"type:varchar(20);default:'sent'"`
+	Timestamp  int64  `gorm:"index"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (migrationMessage) TableName() string { return "messages" }
+
+type migrationGroup struct {
+	ID          string `gorm:"primaryKey;type:varchar(64)"`
+	Name        string `gorm:"type:varchar(100)"`
+	Description string `gorm:"type:text"`
+	OwnerID     string `gorm:"type:varchar(64)"`
+	Members     string `gorm:"type:json"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (migrationGroup) TableName() string { return "groups" }
+
+type migrationGroupMember struct {
+	ID       string `gorm:"primaryKey;type:varchar(64)"`
+	GroupID  string `gorm:"type:varchar(64);index"`
+	UserID   string `gorm:"type:varchar(64);index"`
+	Role     string `gorm:"type:varchar(20)"`
+	JoinedAt time.Time
+}
+
+func (migrationGroupMember) TableName() string { return "group_members" }
+
+type migrationGroupMode struct {
+	Mode string `gorm:"type:varchar(20);default:'normal'"`
+}
+
+func (migrationGroupMode) TableName() string { return "groups" }
+
+type migrationMemberProfile struct {
+	Nickname   string `gorm:"type:varchar(100)"`
+	MutedUntil int64  `gorm:"default:0"`
+}
+
+func (migrationMemberProfile) TableName() string { return "group_members" }
+
+type migrationGroupSettings struct {
+	SettingsPostPolicy string `gorm:"type:varchar(20);default:'all'"`
+	SettingsSlowMode   int    `gorm:"default:0"`
+	SettingsBlockLinks bool   `gorm:"default:false"`
+	SettingsBlockMedia bool   `gorm:"default:false"`
+}
+
+func (migrationGroupSettings) TableName() string { return "groups" }
+
+type migrationUserSanction struct {
+	ID        string `gorm:"primaryKey;type:varchar(64)"`
+	UserID    string `gorm:"type:varchar(64);uniqueIndex:idx_user_sanction"`
+	Type      string `gorm:"type:varchar(20);uniqueIndex:idx_user_sanction"`
+	Reason    string `gorm:"type:varchar(255)"`
+	Until     int64  `gorm:"index"`
+	CreatedAt time.Time
+}
+
+func (migrationUserSanction) TableName() string { return "user_sanctions" }
+
+type migrationMessageTombstone struct {
+	DeletedAt int64 `gorm:"default:0"`
+}
+
+func (migrationMessageTombstone) TableName() string { return "messages" }
+
+type migrationMessageDeletion struct {
+	MessageID string `gorm:"primaryKey;type:varchar(64)"`
+	UserID    string `gorm:"primaryKey;type:varchar(64)"`
+	CreatedAt time.Time
+}
+
+func (migrationMessageDeletion) TableName() string { return "message_deletions" }
+
+type migrationConversationSettings struct {
+	UserID         string `gorm:"primaryKey;type:varchar(64)"`
+	ConversationID string `gorm:"primaryKey;type:varchar(140)"`
+	Archived       bool   `gorm:"default:false"`
+	Pinned         bool   `gorm:"default:false"`
+	PinnedAt       int64  `gorm:"default:0"`
+	Labels         string `gorm:"type:json"`
+	UpdatedAt      time.Time
+}
+
+func (migrationConversationSettings) TableName() string { return "user_conversation_settings" }
+
+type migrationMessagePreview struct {
+	Preview string `gorm:"type:json"`
+}
+
+func (migrationMessagePreview) TableName() string { return "messages" }
+
+type migrationMessageSystem struct {
+	System string `gorm:"type:json"`
+}
+
+func (migrationMessageSystem) TableName() string { return "messages" }
+
+type migrationUserProfile struct {
+	UserID    string `gorm:"primaryKey;type:varchar(64)"`
+	Language  string `gorm:"type:varchar(35)"`
+	UpdatedAt time.Time
+}
+
+func (migrationUserProfile) TableName() string { return "user_profiles" }
+
+type migrationAPIKey struct {
+	ID            string `gorm:"primaryKey;type:varchar(64)"`
+	Name          string `gorm:"type:varchar(100)"`
+	SenderID      string `gorm:"type:varchar(64);index"`
+	Prefix        string `gorm:"type:varchar(16)"`
+	KeyHash       string `gorm:"type:varchar(64);uniqueIndex"`
+	Conversations string `gorm:"type:json"`
+	RateLimit     int
+	RevokedAt     int64 `gorm:"default:0"`
+	CreatedAt     time.Time
+}
+
+func (migrationAPIKey) TableName() string { return "api_keys" }
+
+type migrationMessagePriority struct {
+	Priority string `gorm:"type:varchar(10);default:'normal'"`
+}
+
+func (migrationMessagePriority) TableName() string { return "messages" }
+
+type migrationMessageReceipt struct {
+	MessageID   string `gorm:"primaryKey;type:varchar(64)"`
+	UserID      string `gorm:"primaryKey;type:varchar(64)"`
+	DeliveredAt int64  `gorm:"default:0"`
+	ReadAt      int64  `gorm:"default:0"`
+}
+
+func (migrationMessageReceipt) TableName() string { return "message_receipts" }
+
+type migrationAuditLog struct {
+	ID        string    `gorm:"primaryKey;type:varchar(64)"`
+	Actor     string    `gorm:"type:varchar(64);index"`
+	Action    string    `gorm:"type:varchar(64);index"`
+	Target    string    `gorm:"type:varchar(140);index"`
+	Details   string    `gorm:"type:json"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (migrationAuditLog) TableName() string { return "audit_logs" }
+
+type migrationDailyStats struct {
+	Day                  string `gorm:"primaryKey;type:varchar(10)"`
+	Messages             int64
+	ActiveUsers          int64
+	PeakConnections      int64
+	Deliveries           int64
+	AvgDeliveryLatencyMs int64
+	UpdatedAt            time.Time
+}
+
+func (migrationDailyStats) TableName() string { return "daily_stats" }
+
+type migrationGroupDailyStats struct {
+	Day      string `gorm:"primaryKey;type:varchar(10)"`
+	GroupID  string `gorm:"primaryKey;type:varchar(64)"`
+	Messages int64
+}
+
+func (migrationGroupDailyStats) TableName() string { return "group_daily_stats" }
+
+type migrationMessageSeq struct {
+	GroupID string `gorm:"type:varchar(64);index:idx_messages_group_seq,priority:1"`
+	Seq     int64  `gorm:"default:0;index:idx_messages_group_seq,priority:2"`
+}
+
+func (migrationMessageSeq) TableName() string { return "messages" }
+
+// migrationMessageFilterIndexes 会话消息按日期、类型和发送者查询使用的索引
+type migrationMessageFilterIndexes struct {
+	SenderID   string `gorm:"type:varchar(64);index:idx_messages_pair_seq,priority:1;index:idx_messages_group_sender_seq,priority:2"`
+	ReceiverID string `gorm:"type:varchar(64);index:idx_messages_pair_seq,priority:2"`
+	GroupID    string `gorm:"type:varchar(64);index:idx_messages_group_type_seq,priority:1;index:idx_messages_group_sender_seq,priority:1;index:idx_messages_group_timestamp,priority:1"`
+	Type       string `gorm:"type:varchar(20);index:idx_messages_group_type_seq,priority:2"`
+	Timestamp  int64  `gorm:"index:idx_messages_group_timestamp,priority:2"`
+	Seq        int64  `gorm:"default:0;index:idx_messages_pair_seq,priority:3;index:idx_messages_group_type_seq,priority:3;index:idx_messages_group_sender_seq,priority:3"`
+}
+
+func (migrationMessageFilterIndexes) TableName() string { return "messages" }
+
+// messageFilterIndexes 会话消息查询索引
+var messageFilterIndexes = []string{
+	"idx_messages_pair_seq",
+	"idx_messages_group_type_seq",
+	"idx_messages_group_sender_seq",
+	"idx_messages_group_timestamp",
+}
+
+// migrationMessagePairTypeIndex 私聊会话按类型查询媒体消息使用的索引
+type migrationMessagePairTypeIndex struct {
+	SenderID   string `gorm:"type:varchar(64);index:idx_messages_pair_type_seq,priority:1"`
+	ReceiverID string `gorm:"type:varchar(64);index:idx_messages_pair_type_seq,priority:2"`
+	Type       string `gorm:"type:varchar(20);index:idx_messages_pair_type_seq,priority:3"`
+	Seq        int64  `gorm:"default:0;index:idx_messages_pair_type_seq,priority:4"`
+}
+
+func (migrationMessagePairTypeIndex) TableName() string { return "messages" }
+
+// migrationMessageOfflineIndex 拉取离线私聊消息使用的索引，按接收者过滤并按消息ID分页
+type migrationMessageOfflineIndex struct {
+	ID         string `gorm:"primaryKey;type:varchar(64);index:idx_messages_offline,priority:3"`
+	ReceiverID string `gorm:"type:varchar(64);index:idx_messages_offline,priority:1"`
+	GroupID    string `gorm:"type:varchar(64);index:idx_messages_offline,priority:2"`
+}
+
+func (migrationMessageOfflineIndex) TableName() string { return "messages" }
+
+type migrationUserStarredMessage struct {
+	UserID         string `gorm:"primaryKey;type:varchar(64);index:idx_starred_user_time,priority:1"`
+	MessageID      string `gorm:"primaryKey;type:varchar(64);index"`
+	ConversationID string `gorm:"type:varchar(140)"`
+	StarredAt      int64  `gorm:"index:idx_starred_user_time,priority:2"`
+}
+
+func (migrationUserStarredMessage) TableName() string { return "user_starred_messages" }
+
+type migrationReport struct {
+	ID           string `gorm:"primaryKey;type:varchar(64);index:idx_reports_status_id,priority:2"`
+	ReporterID   string `gorm:"type:varchar(64);index"`
+	TargetType   string `gorm:"type:varchar(16)"`
+	TargetUserID string `gorm:"type:varchar(64);index"`
+	MessageID    string `gorm:"type:varchar(64);index"`
+	Reason       string `gorm:"type:varchar(32)"`
+	Comment      string `gorm:"type:varchar(1000)"`
+	Snapshot     string `gorm:"type:json"`
+	Status       string `gorm:"type:varchar(16);index:idx_reports_status_id,priority:1"`
+	Action       string `gorm:"type:varchar(32)"`
+	ResolvedBy   string `gorm:"type:varchar(64)"`
+	ResolvedAt   int64
+	Note         string `gorm:"type:varchar(1000)"`
+	CreatedAt    time.Time
+}
+
+func (migrationReport) TableName() string { return "reports" }
+
+type migrationQuotaUsage struct {
+	Subject     string `gorm:"primaryKey;type:varchar(100)"`
+	Day         string `gorm:"type:varchar(10)"`
+	Messages    int64
+	MediaBytes  int64
+	OwnedGroups int64
+	Overrides   string `gorm:"type:json"`
+	UpdatedAt   time.Time
+}
+
+func (migrationQuotaUsage) TableName() string { return "quota_usages" }
+
+type migrationTwoFactor struct {
+	UserID        string `gorm:"primaryKey;type:varchar(64)"`
+	Secret        string `gorm:"type:varchar(64)"`
+	Enabled       bool   `gorm:"default:false"`
+	RecoveryCodes string `gorm:"type:json"`
+	LastStep      int64  `gorm:"default:0"`
+	EnabledAt     int64  `gorm:"default:0"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (migrationTwoFactor) TableName() string { return "two_factors" }
+
+type migrationUserProfileIdentity struct {
+	DisplayName string `gorm:"type:varchar(100)"`
+	Email       string `gorm:"type:varchar(255)"`
+}
+
+func (migrationUserProfileIdentity) TableName() string { return "user_profiles" }
+
+type migrationDepartment struct {
+	ID        string `gorm:"primaryKey;type:varchar(64)"`
+	ParentID  string `gorm:"type:varchar(64);index"`
+	Name      string `gorm:"type:varchar(100)"`
+	ManagerID string `gorm:"type:varchar(64)"`
+	SortOrder int    `gorm:"default:0"`
+	GroupID   string `gorm:"type:varchar(64)"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (migrationDepartment) TableName() string { return "departments" }
+
+type migrationDepartmentMember struct {
+	DepartmentID string `gorm:"primaryKey;type:varchar(64)"`
+	UserID       string `gorm:"primaryKey;type:varchar(64);index"`
+	Title        string `gorm:"type:varchar(100)"`
+}
+
+func (migrationDepartmentMember) TableName() string { return "department_members" }
+
+type migrationMessageThread struct {
+	ThreadID    string `gorm:"type:varchar(64)"`
+	ReplyCount  int64  `gorm:"default:0"`
+	LastReplyAt int64  `gorm:"default:0"`
+}
+
+func (migrationMessageThread) TableName() string { return "messages" }
+
+type migrationMessageVoice struct {
+	Voice string `gorm:"type:json"`
+}
+
+func (migrationMessageVoice) TableName() string { return "messages" }
+
+type migrationGroupWordFilter struct {
+	SettingsWordFilter string `gorm:"type:varchar(20);default:''"`
+}
+
+func (migrationGroupWordFilter) TableName() string { return "groups" }
+
+type migrationAuditLogRole struct {
+	Role string `gorm:"type:varchar(64);index"`
+}
+
+func (migrationAuditLogRole) TableName() string { return "audit_logs" }
+
+type migrationGroupReceipts struct {
+	SettingsReceipts string `gorm:"type:varchar(20);default:''"`
+}
+
+func (migrationGroupReceipts) TableName() string { return "groups" }
+
+// Migrations 数据库结构迁移，按ID顺序执行，已发布的迁移不能修改，只能追加
+// 初始迁移兼容此前由AutoMigrate创建的库：表和列已存在时跳过
+var Migrations = []*gormigrate.Migration{
+	{
+		ID: "202401010001_create_im_tables",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationMessage{}, &migrationGroup{}, &migrationGroupMember{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationGroupMember{}, &migrationGroup{}, &migrationMessage{})
+		},
+	},
+	{
+		ID: "202401010002_add_group_mode",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &migrationGroupMode{}, "Mode")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropColumns(tx, &migrationGroupMode{}, "Mode")
+		},
+	},
+	{
+		ID: "202401010003_add_member_nickname_and_mute",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &migrationMemberProfile{}, "Nickname", "MutedUntil")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropColumns(tx, &migrationMemberProfile{}, "Nickname", "MutedUntil")
+		},
+	},
+	{
+		ID: "202401010004_add_group_settings",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &migrationGroupSettings{},
+				"SettingsPostPolicy", "SettingsSlowMode", "SettingsBlockLinks", "SettingsBlockMedia")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropColumns(tx, &migrationGroupSettings{},
+				"SettingsPostPolicy", "SettingsSlowMode", "SettingsBlockLinks", "SettingsBlockMedia")
+		},
+	},
+	{
+		ID: "202401010005_create_user_sanctions",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationUserSanction{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationUserSanction{})
+		},
+	},
+	{
+		ID: "202401010006_add_message_deletions",
+		Migrate: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &migrationMessageTombstone{}, "DeletedAt"); err != nil {
+				return err
+			}
+			return tx.AutoMigrate(&migrationMessageDeletion{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&migrationMessageDeletion{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &migrationMessageTombstone{}, "DeletedAt")
+		},
+	},
+	{
+		ID: "202401010007_create_user_conversation_settings",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationConversationSettings{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationConversationSettings{})
+		},
+	},
+	{
+		ID: "202401010008_add_message_preview",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &migrationMessagePreview{}, "Preview")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropColumns(tx, &migrationMessagePreview{}, "Preview")
+		},
+	},
+	{
+		ID: "202401010009_add_system_messages_and_user_profiles",
+		Migrate: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &migrationMessageSystem{}, "System"); err != nil {
+				return err
+			}
+			return tx.AutoMigrate(&migrationUserProfile{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&migrationUserProfile{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &migrationMessageSystem{}, "System")
+		},
+	},
+	{
+		ID: "202401010010_create_api_keys",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationAPIKey{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationAPIKey{})
+		},
+	},
+	{
+		ID: "202401010011_add_message_priority",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &migrationMessagePriority{}, "Priority")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropColumns(tx, &migrationMessagePriority{}, "Priority")
+		},
+	},
+	{
+		ID: "202401010012_create_message_receipts",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationMessageReceipt{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationMessageReceipt{})
+		},
+	},
+	{
+		ID: "202401010013_create_audit_logs",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationAuditLog{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationAuditLog{})
+		},
+	},
+	{
+		ID: "202401010014_create_daily_stats",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationDailyStats{}, &migrationGroupDailyStats{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationDailyStats{}, &migrationGroupDailyStats{})
+		},
+	},
+	{
+		ID: "202401010015_add_message_seq",
+		Migrate: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &migrationMessageSeq{}, "Seq"); err != nil {
+				return err
+			}
+			if tx.Migrator().HasIndex(&migrationMessageSeq{}, "idx_messages_group_seq") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&migrationMessageSeq{}, "idx_messages_group_seq")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if tx.Migrator().HasIndex(&migrationMessageSeq{}, "idx_messages_group_seq") {
+				if err := tx.Migrator().DropIndex(&migrationMessageSeq{}, "idx_messages_group_seq"); err != nil {
+					return err
+				}
+			}
+			return dropColumns(tx, &migrationMessageSeq{}, "Seq")
+		},
+	},
+	{
+		ID: "202401010016_create_quota_usages",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationQuotaUsage{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationQuotaUsage{})
+		},
+	},
+	{
+		ID: "202401010017_create_two_factors",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationTwoFactor{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationTwoFactor{})
+		},
+	},
+	{
+		ID: "202401010018_add_user_profile_identity",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &migrationUserProfileIdentity{}, "DisplayName", "Email")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropColumns(tx, &migrationUserProfileIdentity{}, "DisplayName", "Email")
+		},
+	},
+	{
+		ID: "202401010019_create_departments",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationDepartment{}, &migrationDepartmentMember{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationDepartmentMember{}, &migrationDepartment{})
+		},
+	},
+	{
+		ID: "202401010020_add_message_threads",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &migrationMessageThread{}, "ThreadID", "ReplyCount", "LastReplyAt")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropColumns(tx, &migrationMessageThread{}, "ThreadID", "ReplyCount", "LastReplyAt")
+		},
+	},
+	{
+		ID: "202401010021_add_message_voice",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &migrationMessageVoice{}, "Voice")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropColumns(tx, &migrationMessageVoice{}, "Voice")
+		},
+	},
+	{
+		ID: "202401010022_add_message_filter_indexes",
+		Migrate: func(tx *gorm.DB) error {
+			for _, name := range messageFilterIndexes {
+				if tx.Migrator().HasIndex(&migrationMessageFilterIndexes{}, name) {
+					continue
+				}
+				if err := tx.Migrator().CreateIndex(&migrationMessageFilterIndexes{}, name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Rollback: func(tx *gorm.DB) error {
+			for _, name := range messageFilterIndexes {
+				if !tx.Migrator().HasIndex(&migrationMessageFilterIndexes{}, name) {
+					continue
+				}
+				if err := tx.Migrator().DropIndex(&migrationMessageFilterIndexes{}, name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID: "202401010023_add_message_pair_type_index",
+		Migrate: func(tx *gorm.DB) error {
+			if tx.Migrator().HasIndex(&migrationMessagePairTypeIndex{}, "idx_messages_pair_type_seq") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&migrationMessagePairTypeIndex{}, "idx_messages_pair_type_seq")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasIndex(&migrationMessagePairTypeIndex{}, "idx_messages_pair_type_seq") {
+				return nil
+			}
+			return tx.Migrator().DropIndex(&migrationMessagePairTypeIndex{}, "idx_messages_pair_type_seq")
+		},
+	},
+	{
+		ID: "202401010024_create_user_starred_messages",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationUserStarredMessage{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationUserStarredMessage{})
+		},
+	},
+	{
+		ID: "202401010025_create_reports",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&migrationReport{})
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&migrationReport{})
+		},
+	},
+	{
+		ID: "202401010026_add_group_word_filter",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &migrationGroupWordFilter{}, "SettingsWordFilter")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropColumns(tx, &migrationGroupWordFilter{}, "SettingsWordFilter")
+		},
+	},
+	{
+		ID: "202401010027_add_audit_log_role",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &migrationAuditLogRole{}, "Role")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropColumns(tx, &migrationAuditLogRole{}, "Role")
+		},
+	},
+	{
+		ID: "202401010028_add_group_receipts",
+		Migrate: func(tx *gorm.DB) error {
+			return addColumns(tx, &migrationGroupReceipts{}, "SettingsReceipts")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return dropColumns(tx, &migrationGroupReceipts{}, "SettingsReceipts")
+		},
+	},
+	{
+		ID: "202401010029_add_message_offline_index",
+		Migrate: func(tx *gorm.DB) error {
+			if tx.Migrator().HasIndex(&migrationMessageOfflineIndex{}, "idx_messages_offline") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&migrationMessageOfflineIndex{}, "idx_messages_offline")
+		},
+		Rollback: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasIndex(&migrationMessageOfflineIndex{}, "idx_messages_offline") {
+				return nil
+			}
+			return tx.Migrator().DropIndex(&migrationMessageOfflineIndex{}, "idx_messages_offline")
+		},
+	},
+}
+
+// addColumns 添加不存在的列
+func addColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(model, field); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// dropColumns 删除存在的列
+func dropColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	for _, field := range fields {
+		if !tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := tx.Migrator().DropColumn(model, field); err != nil {
+			return fmt.Errorf("failed to drop column %s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// migrator 创建迁移执行器
+func (s *MySQLStore) migrator() *gormigrate.Gormigrate {
+	options := *gormigrate.DefaultOptions
+	options.TableName = migrationTable
+	// MySQL的DDL会隐式提交事务，逐条迁移执行
+	options.UseTransaction = false
+	return gormigrate.New(s.db, &options, Migrations)
+}
+
+// Migrate 执行所有未执行的迁移
+func (s *MySQLStore) Migrate() error {
+	if err := s.migrator().Migrate(); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	return nil
+}
+
+// RollbackLast 回滚最后一次执行的迁移
+func (s *MySQLStore) RollbackLast() error {
+	if err := s.migrator().RollbackLast(); err != nil {
+		return fmt.Errorf("failed to rollback schema: %w", err)
+	}
+	return nil
+}
+
+// PendingMigrations 获取尚未执行的迁移ID
+func (s *MySQLStore) PendingMigrations() ([]string, error) {
+	applied := make(map[string]bool)
+	if s.db.Migrator().HasTable(migrationTable) {
+		var ids []string
+		if err := s.db.Table(migrationTable).Pluck("id", &ids).Error; err != nil {
+			return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+		for _, id := range ids {
+			applied[id] = true
+		}
+	}
+
+	var pending []string
+	for _, m := range Migrations {
+		if !applied[m.ID] {
+			pending = append(pending, m.ID)
+		}
+	}
+	return pending, nil
+}
+
+// CheckSchema 检查数据库结构是否已迁移到最新版本
+func (s *MySQLStore) CheckSchema() error {
+	pending, err := s.PendingMigrations()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is out of date, %d pending migrations (%s), run `server migrate up`",
+			len(pending), strings.Join(pending, ", "))
+	}
+	return nil
+}
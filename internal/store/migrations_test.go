@@ -0,0 +1,110 @@
+package store
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm/schema"
+)
+
+// tableColumns 解析结构体对应的表名和列名
+func tableColumns(t *testing.T, v interface{}) (string, []string) {
+	s, err := schema.Parse(v, &sync.Map{}, schema.NamingStrategy{})
+	assert.NoError(t, err)
+
+	var columns []string
+	for _, f := range s.Fields {
+		if f.DBName != "" {
+			columns = append(columns, f.DBName)
+		}
+	}
+	return s.Table, columns
+}
+
+func TestMigrations_UniqueOrderedIDs(t *testing.T) {
+	for i := 1; i < len(Migrations); i++ {
+		assert.Less(t, Migrations[i-1].ID, Migrations[i].ID)
+	}
+}
+
+// 模型新增列时必须追加对应的迁移
+func TestMigrations_CoverModels(t *testing.T) {
+	migrated := make(map[string]map[string]bool)
+	for _, v := range []interface{}{
+		&migrationMessage{}, &migrationGroup{}, &migrationGroupMember{}, &migrationGroupMode{},
+		&migrationMemberProfile{}, &migrationGroupSettings{}, &migrationUserSanction{},
+		&migrationMessageTombstone{}, &migrationMessageDeletion{}, &migrationConversationSettings{},
+		&migrationMessagePreview{}, &migrationMessageSystem{}, &migrationUserProfile{},
+		&migrationAPIKey{}, &migrationMessagePriority{}, &migrationMessageReceipt{},
+		&migrationAuditLog{}, &migrationDailyStats{}, &migrationGroupDailyStats{},
+		&migrationMessageSeq{}, &migrationQuotaUsage{}, &migrationTwoFactor{}, &migrationUserProfileIdentity{},
+		&migrationDepartment{}, &migrationDepartmentMember{}, &migrationMessageThread{},
+		&migrationMessageVoice{}, &migrationUserStarredMessage{}, &migrationReport{},
+		&migrationGroupWordFilter{}, &migrationAuditLogRole{}, &migrationGroupReceipts{},
+	} {
+		table, columns := tableColumns(t, v)
+		if migrated[table] == nil {
+			migrated[table] = make(map[string]bool)
+		}
+		for _, c := range columns {
+			migrated[table][c] = true
+		}
+	}
+
+	for _, v := range []interface{}{
+		&model.Message{}, &model.Group{}, &model.GroupMember{}, &model.UserSanction{},
+		&model.MessageDeletion{}, &model.UserConversationSettings{}, &model.UserProfile{},
+		&model.APIKey{}, &model.MessageReceipt{}, &model.AuditLog{},
+		&model.DailyStats{}, &model.GroupDailyStats{}, &model.QuotaUsage{}, &model.TwoFactor{},
+		&model.Department{}, &model.DepartmentMember{}, &model.UserStarredMessage{}, &model.Report{},
+	} {
+		table, columns := tableColumns(t, v)
+		assert.Contains(t, migrated, table)
+		for _, c := range columns {
+			assert.True(t, migrated[table][c], "column %s.%s has no migration", table, c)
+		}
+	}
+}
+
+func TestMigrationMessageFilterIndexes_Defined(t *testing.T) {
+	s, err := schema.Parse(&migrationMessageFilterIndexes{}, &sync.Map{}, schema.NamingStrategy{})
+	assert.NoError(t, err)
+
+	// 迁移按名称创建的索引都能在结构体上找到，列顺序与查询条件一致
+	columns := map[string][]string{
+		"idx_messages_pair_seq":         {"sender_id", "receiver_id", "seq"},
+		"idx_messages_group_type_seq":   {"group_id", "type", "seq"},
+		"idx_messages_group_sender_seq": {"group_id", "sender_id", "seq"},
+		"idx_messages_group_timestamp":  {"group_id", "timestamp"},
+	}
+	assert.Len(t, messageFilterIndexes, len(columns))
+	for _, name := range messageFilterIndexes {
+		index := s.LookIndex(name)
+		if !assert.NotNil(t, index, name) {
+			continue
+		}
+		var fields []string
+		for _, f := range index.Fields {
+			fields = append(fields, f.DBName)
+		}
+		assert.Equal(t, columns[name], fields, name)
+	}
+}
+
+func TestMigrationMessageOfflineIndex_Defined(t *testing.T) {
+	s, err := schema.Parse(&migrationMessageOfflineIndex{}, &sync.Map{}, schema.NamingStrategy{})
+	assert.NoError(t, err)
+
+	// 列顺序与GetOfflineMessages的过滤和排序一致
+	index := s.LookIndex("idx_messages_offline")
+	if !assert.NotNil(t, index) {
+		return
+	}
+	var fields []string
+	for _, f := range index.Fields {
+		fields = append(fields, f.DBName)
+	}
+	assert.Equal(t, []string{"receiver_id", "group_id", "id"}, fields)
+}
@@ -2,13 +2,14 @@ package store
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/user/im/internal/config"
 	"github.com/user/im/internal/model"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	"gorm.io/gorm/clause"
 )
 
 // MySQLStore MySQL存储实现
@@ -16,16 +17,33 @@ type MySQLStore struct {
 	db *gorm.DB
 }
 
-// NewMySQLStore 创建MySQL存储实例
+// NewMySQLStore 创建MySQL存储实例，数据库结构不是最新版本时返回错误
 func NewMySQLStore(cfg *config.DatabaseConfig) (*MySQLStore, error) {
+	s, err := OpenMySQLStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.CheckSchema(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenMySQLStore 连接MySQL但不检查数据库结构，用于执行迁移
+func OpenMySQLStore(cfg *config.DatabaseConfig) (*MySQLStore, error) {
 	dsn := cfg.GetDSN()
 
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: newGormLogger(cfg),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	if err := registerGormTiming(db); err != nil {
+		return nil, fmt.Errorf("failed to register query timing: %w", err)
+	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -37,15 +55,6 @@ func NewMySQLStore(cfg *config.DatabaseConfig) (*MySQLStore, error) {
 	sqlDB.SetMaxOpenConns(cfg.MaxOpen)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	// 自动迁移表结构
-	if err := db.AutoMigrate(
-		&model.Message{},
-		&model.Group{},
-		&model.GroupMember{},
-	); err != nil {
-		return nil, fmt.Errorf("failed to auto migrate: %w", err)
-	}
-
 	return &MySQLStore{db: db}, nil
 }
 
@@ -73,7 +82,20 @@ func (s *MySQLStore) GetOfflineMessages(userID string, lastMessageID string, lim
 		query = query.Where("id > ?", lastMessageID)
 	}
 
-	err := query.Order("timestamp ASC").Limit(limit).Find(&messages).Error
+	// 按消息ID排序，与游标条件一致，同一时间戳的消息不会在分页时遗漏或重复
+	err := query.Order("id ASC").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// GetStaleSentMessages 获取时间戳在[from, to]内仍为已发送状态的私聊消息，按消息ID分页
+func (s *MySQLStore) GetStaleSentMessages(from, to int64, afterID string, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	query := s.db.Where("timestamp >= ? AND timestamp <= ? AND status = ? AND group_id = '' AND deleted_at = 0",
+		from, to, model.MessageStatusSent)
+	if afterID != "" {
+		query = query.Where("id > ?", afterID)
+	}
+	err := query.Order("id ASC").Limit(limit).Find(&messages).Error
 	return messages, err
 }
 
@@ -117,6 +139,89 @@ func (s *MySQLStore) GetGroupMembers(groupID string) ([]*model.GroupMember, erro
 	return members, err
 }
 
+// MemberFilter 群组成员查询条件
+type MemberFilter struct {
+	Cursor   string // 上一页最后一个成员ID，优先于Offset
+	Offset   int
+	Limit    int
+	Role     string
+	Muted    *bool
+	Nickname string // 群昵称前缀
+}
+
+// ListGroupMembers 按条件分页获取群组成员，按成员ID排序
+func (s *MySQLStore) ListGroupMembers(groupID string, filter MemberFilter) ([]*model.GroupMember, error) {
+	query := s.db.Where("group_id = ?", groupID)
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Muted != nil {
+		if *filter.Muted {
+			query = query.Where("muted_until > ?", time.Now().Unix())
+		} else {
+			query = query.Where("muted_until <= ?", time.Now().Unix())
+		}
+	}
+	if filter.Nickname != "" {
+		query = query.Where("nickname LIKE ?", escapeLike(filter.Nickname)+"%")
+	}
+	if filter.Cursor != "" {
+		query = query.Where("id > ?", filter.Cursor)
+	} else if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var members []*model.GroupMember
+	err := query.Order("id ASC").Limit(filter.Limit).Find(&members).Error
+	return members, err
+}
+
+// UpdateGroupMember 更新群组成员字段
+func (s *MySQLStore) UpdateGroupMember(groupID, userID string, updates map[string]interface{}) error {
+	return s.db.Model(&model.GroupMember{}).
+		Where("group_id = ? AND user_id = ?", groupID, userID).
+		Updates(updates).Error
+}
+
+// GetGroupMember 获取群组成员
+func (s *MySQLStore) GetGroupMember(groupID, userID string) (*model.GroupMember, error) {
+	var member model.GroupMember
+	err := s.db.Where("group_id = ? AND user_id = ?", groupID, userID).First(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// UpdateGroupSettings 更新群组发言设置
+func (s *MySQLStore) UpdateGroupSettings(groupID string, settings model.GroupSettings) error {
+	return s.db.Model(&model.Group{}).Where("id = ?", groupID).Updates(map[string]interface{}{
+		"settings_post_policy": settings.PostPolicy,
+		"settings_slow_mode":   settings.SlowMode,
+		"settings_block_links": settings.BlockLinks,
+		"settings_block_media": settings.BlockMedia,
+		"settings_word_filter": settings.WordFilter,
+		"settings_receipts":    settings.Receipts,
+	}).Error
+}
+
+// escapeLike 转义LIKE通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
+
+// CountGroupMembers 统计群组成员数
+func (s *MySQLStore) CountGroupMembers(groupID string) (int64, error) {
+	var count int64
+	err := s.db.Model(&model.GroupMember{}).Where("group_id = ?", groupID).Count(&count).Error
+	return count, err
+}
+
+// UpdateGroupMode 更新群组模式
+func (s *MySQLStore) UpdateGroupMode(groupID string, mode model.GroupMode) error {
+	return s.db.Model(&model.Group{}).Where("id = ?", groupID).Update("mode", mode).Error
+}
+
 // AddGroupMember 添加群组成员
 func (s *MySQLStore) AddGroupMember(member *model.GroupMember) error {
 	return s.db.Create(member).Error
@@ -134,6 +239,40 @@ func (s *MySQLStore) IsGroupMember(groupID, userID string) (bool, error) {
 	return count > 0, err
 }
 
+// GetUserGroupIDs 获取用户加入的所有群组ID
+func (s *MySQLStore) GetUserGroupIDs(userID string) ([]string, error) {
+	var groupIDs []string
+	err := s.db.Model(&model.GroupMember{}).Where("user_id = ?", userID).Pluck("group_id", &groupIDs).Error
+	return groupIDs, err
+}
+
+// SaveUserSanction 保存用户处罚，同一用户同类处罚只保留最新一条
+func (s *MySQLStore) SaveUserSanction(sanction *model.UserSanction) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "until", "created_at"}),
+	}).Create(sanction).Error
+}
+
+// DeleteUserSanction 删除用户处罚
+func (s *MySQLStore) DeleteUserSanction(userID string, sanctionType model.SanctionType) error {
+	return s.db.Where("user_id = ? AND type = ?", userID, sanctionType).Delete(&model.UserSanction{}).Error
+}
+
+// GetUserSanctions 获取用户的所有处罚记录
+func (s *MySQLStore) GetUserSanctions(userID string) ([]*model.UserSanction, error) {
+	var sanctions []*model.UserSanction
+	err := s.db.Where("user_id = ?", userID).Find(&sanctions).Error
+	return sanctions, err
+}
+
+// GetActiveUserSanctions 获取所有仍在生效期内的处罚
+func (s *MySQLStore) GetActiveUserSanctions() ([]*model.UserSanction, error) {
+	var sanctions []*model.UserSanction
+	err := s.db.Where("until = 0 OR until > ?", time.Now().Unix()).Find(&sanctions).Error
+	return sanctions, err
+}
+
 // Close 关闭数据库连接
 func (s *MySQLStore) Close() error {
 	sqlDB, err := s.db.DB()
@@ -142,3 +281,12 @@ func (s *MySQLStore) Close() error {
 	}
 	return sqlDB.Close()
 }
+
+// Ping 检查数据库连接是否可用
+func (s *MySQLStore) Ping() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// sqlRecorder 记录生成的SQL
+type sqlRecorder struct {
+	gormlogger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) LogMode(gormlogger.LogLevel) gormlogger.Interface { return r }
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// dryRunMySQLStore 只生成SQL不连接数据库的MySQL存储
+func dryRunMySQLStore(t *testing.T) (*MySQLStore, *sqlRecorder) {
+	recorder := &sqlRecorder{Interface: gormlogger.Discard}
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "im:im@tcp(127.0.0.1:3306)/im", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: recorder})
+	assert.NoError(t, err)
+	return &MySQLStore{db: db}, recorder
+}
+
+// lastSQL 最后一条生成的SQL
+func (r *sqlRecorder) lastSQL() string {
+	if len(r.statements) == 0 {
+		return ""
+	}
+	return r.statements[len(r.statements)-1]
+}
+
+func TestListGroupMembers_Filters(t *testing.T) {
+	s, recorder := dryRunMySQLStore(t)
+
+	_, err := s.ListGroupMembers("g1", MemberFilter{Limit: 10, Offset: 20})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM `group_members` WHERE group_id = 'g1' ORDER BY id ASC LIMIT 10 OFFSET 20", recorder.lastSQL())
+
+	// 游标优先于偏移量，昵称前缀中的通配符被转义
+	muted := true
+	_, err = s.ListGroupMembers("g1", MemberFilter{Limit: 10, Offset: 20, Cursor: "m9", Role: "admin", Muted: &muted, Nickname: "a_b%"})
+	assert.NoError(t, err)
+	sql := recorder.lastSQL()
+	assert.Contains(t, sql, "role = 'admin'")
+	assert.Contains(t, sql, "muted_until > ")
+	assert.Contains(t, sql, `nickname LIKE 'a\_b\%%'`)
+	assert.Contains(t, sql, "id > 'm9'")
+	assert.NotContains(t, sql, "OFFSET")
+
+	muted = false
+	_, err = s.ListGroupMembers("g1", MemberFilter{Limit: 10, Muted: &muted})
+	assert.NoError(t, err)
+	assert.Contains(t, recorder.lastSQL(), "muted_until <= ")
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `50\%\_off\\`, escapeLike(`50%_off\`))
+}
+
+func TestUserSanction_SQL(t *testing.T) {
+	s, recorder := dryRunMySQLStore(t)
+
+	// 同一用户同类处罚覆盖旧记录
+	assert.NoError(t, s.SaveUserSanction(&model.UserSanction{ID: "s1", UserID: "u1", Type: model.SanctionMute, Until: 100}))
+	sql := recorder.lastSQL()
+	assert.Contains(t, sql, "INSERT INTO `user_sanctions`")
+	assert.Contains(t, sql, "ON DUPLICATE KEY UPDATE `reason`=VALUES(`reason`),`until`=VALUES(`until`),`created_at`=VALUES(`created_at`)")
+
+	_, err := s.GetActiveUserSanctions()
+	assert.NoError(t, err)
+	assert.Contains(t, recorder.lastSQL(), "WHERE until = 0 OR until > ")
+
+	assert.NoError(t, s.DeleteUserSanction("u1", model.SanctionBan))
+	assert.Equal(t, "DELETE FROM `user_sanctions` WHERE user_id = 'u1' AND type = 'ban'", recorder.lastSQL())
+}
+
+func TestGetStaleSentMessages_SQL(t *testing.T) {
+	s, recorder := dryRunMySQLStore(t)
+
+	_, err := s.GetStaleSentMessages(100, 200, "", 50)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM `messages` WHERE timestamp >= 100 AND timestamp <= 200 AND status = 'sent' AND group_id = '' AND deleted_at = 0 ORDER BY id ASC LIMIT 50", recorder.lastSQL())
+
+	// 按消息ID继续翻页
+	_, err = s.GetStaleSentMessages(100, 200, "m9", 50)
+	assert.NoError(t, err)
+	assert.Contains(t, recorder.lastSQL(), "AND id > 'm9' ORDER BY id ASC LIMIT 50")
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 存储类型，作为耗时指标的store标签
+const (
+	storeMySQL   = "mysql"
+	storeRedis   = "redis"
+	storeLevelDB = "leveldb"
+)
+
+// maxSlowLogStatement 慢操作日志中SQL或命令的最大长度
+const maxSlowLogStatement = 1024
+
+// observeStore 记录一次存储操作的耗时，超过阈值时以Warn级别记录慢操作日志；阈值不大于0时不记录日志
+func observeStore(store, operation string, elapsed, threshold time.Duration, err error, fields ...zap.Field) {
+	metrics.StoreOperationSeconds.WithLabelValues(store, operation).Observe(elapsed.Seconds())
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+	fields = append([]zap.Field{
+		logger.String("store", store),
+		logger.String("operation", operation),
+		logger.Int64("duration_ms", elapsed.Milliseconds()),
+	}, fields...)
+	if err != nil {
+		fields = append(fields, logger.ErrorField(err))
+	}
+	logger.Warn("Slow store operation", fields...)
+}
+
+// truncateStatement 截断过长的SQL或命令
+func truncateStatement(s string) string {
+	if len(s) > maxSlowLogStatement {
+		return strings.ToValidUTF8(s[:maxSlowLogStatement], "") + "..."
+	}
+	return s
+}
+
+// gormTimingStartKey 操作开始时间在gorm.Statement中的键
+const gormTimingStartKey = "im:timing_start"
+
+// registerGormTiming 在GORM的各类操作前后注册回调，统计每个操作的耗时
+// 操作名为操作类型加表名，如 query messages；慢查询日志由gormLogger记录
+func registerGormTiming(db *gorm.DB) error {
+	before := func(db *gorm.DB) {
+		db.InstanceSet(gormTimingStartKey, time.Now())
+	}
+	after := func(kind string) func(*gorm.DB) {
+		return func(db *gorm.DB) {
+			value, ok := db.InstanceGet(gormTimingStartKey)
+			if !ok {
+				return
+			}
+			operation := kind
+			if table := db.Statement.Table; table != "" {
+				operation = kind + " " + table
+			}
+			observeStore(storeMySQL, operation, time.Since(value.(time.Time)), 0, nil)
+		}
+	}
+
+	callbacks := db.Callback()
+	for _, register := range []struct {
+		kind   string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}{
+		{"create", callbacks.Create().Before("gorm:create").Register, callbacks.Create().After("gorm:create").Register},
+		{"query", callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:query").Register},
+		{"update", callbacks.Update().Before("gorm:update").Register, callbacks.Update().After("gorm:update").Register},
+		{"delete", callbacks.Delete().Before("gorm:delete").Register, callbacks.Delete().After("gorm:delete").Register},
+		{"row", callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register},
+		{"raw", callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register},
+	} {
+		if err := register.before("im:timing_before_"+register.kind, before); err != nil {
+			return err
+		}
+		if err := register.after("im:timing_after_"+register.kind, after(register.kind)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redisTimingHook 统计每条Redis命令和每个管道的耗时，操作名为小写的命令名，管道为pipeline
+// 慢命令日志附带第一个键，不记录值
+type redisTimingHook struct {
+	threshold time.Duration
+}
+
+// DialHook 建立连接不计入命令耗时
+func (h redisTimingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ProcessHook 统计单条命令的耗时
+func (h redisTimingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		if err == redis.Nil {
+			err = nil
+		}
+		observeStore(storeRedis, cmd.Name(), time.Since(start), h.threshold, err, logger.String("key", redisCmdKey(cmd)))
+		return err
+	}
+}
+
+// ProcessPipelineHook 统计整个管道的耗时
+func (h redisTimingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		if err == redis.Nil {
+			err = nil
+		}
+		var first string
+		for _, cmd := range cmds {
+			// 事务管道以MULTI开头
+			if cmd.Name() != "multi" {
+				first = truncateStatement(cmd.Name() + " " + redisCmdKey(cmd))
+				break
+			}
+		}
+		observeStore(storeRedis, "pipeline", time.Since(start), h.threshold, err,
+			logger.Int("commands", len(cmds)),
+			logger.String("first", first))
+		return err
+	}
+}
+
+// redisCmdKey 命令的第一个键，脚本命令取KEYS中的第一个
+func redisCmdKey(cmd redis.Cmder) string {
+	args := cmd.Args()
+	index := 1
+	switch cmd.Name() {
+	case "eval", "evalsha":
+		// EVALSHA sha numkeys key ...
+		index = 3
+	}
+	if len(args) <= index {
+		return ""
+	}
+	if key, ok := args[index].(string); ok {
+		return truncateStatement(key)
+	}
+	return ""
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisCmdKey(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "user:1", redisCmdKey(redis.NewStringCmd(ctx, "get", "user:1")))
+	assert.Equal(t, "poll:votes:m1", redisCmdKey(redis.NewCmd(ctx, "evalsha", "sha", 2, "poll:votes:m1", "poll:tally:m1", "u1")))
+	assert.Equal(t, "", redisCmdKey(redis.NewCmd(ctx, "evalsha", "sha", 0)))
+	assert.Equal(t, "", redisCmdKey(redis.NewStatusCmd(ctx, "ping")))
+}
+
+func TestTruncateStatement(t *testing.T) {
+	assert.Equal(t, "SELECT 1", truncateStatement("SELECT 1"))
+
+	long := truncateStatement(strings.Repeat("a", maxSlowLogStatement-1) + "中文")
+	assert.Equal(t, strings.Repeat("a", maxSlowLogStatement-1)+"...", long)
+}
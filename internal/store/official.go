@@ -0,0 +1,99 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/user/im/internal/model"
+)
+
+// officialAccountsKey 管理接口维护的公众号，值为公众号的JSON
+const officialAccountsKey = "official:accounts"
+
+// OfficialAccountsChannel 公众号变更通知频道
+const OfficialAccountsChannel = "official:accounts:changed"
+
+// officialFollowersKey 公众号的关注者集合
+func officialFollowersKey(accountID string) string {
+	return fmt.Sprintf("official:followers:%s", accountID)
+}
+
+// followedOfficialKey 用户关注的公众号集合
+func followedOfficialKey(userID string) string {
+	return fmt.Sprintf("user:official:%s", userID)
+}
+
+// SetOfficialAccount 保存公众号，同ID的公众号整体替换
+func (s *RedisStore) SetOfficialAccount(account *model.OfficialAccount) error {
+	data, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(s.ctx, officialAccountsKey, account.ID, data).Err()
+}
+
+// DeleteOfficialAccount 删除公众号及其关注者集合，返回是否存在；用户一侧的关注记录在读取时忽略
+func (s *RedisStore) DeleteOfficialAccount(id string) (bool, error) {
+	pipe := s.client.TxPipeline()
+	deleted := pipe.HDel(s.ctx, officialAccountsKey, id)
+	pipe.Del(s.ctx, officialFollowersKey(id))
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return false, err
+	}
+	return deleted.Val() > 0, nil
+}
+
+// GetOfficialAccounts 获取全部公众号，跳过无法解析的记录
+func (s *RedisStore) GetOfficialAccounts() (map[string]*model.OfficialAccount, error) {
+	values, err := s.client.HGetAll(s.ctx, officialAccountsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[string]*model.OfficialAccount, len(values))
+	for id, value := range values {
+		var account model.OfficialAccount
+		if err := json.Unmarshal([]byte(value), &account); err != nil {
+			continue
+		}
+		account.ID = id
+		accounts[id] = &account
+	}
+	return accounts, nil
+}
+
+// FollowOfficialAccount 记录用户关注公众号，返回此前是否未关注
+func (s *RedisStore) FollowOfficialAccount(accountID, userID string) (bool, error) {
+	pipe := s.client.TxPipeline()
+	added := pipe.SAdd(s.ctx, officialFollowersKey(accountID), userID)
+	pipe.SAdd(s.ctx, followedOfficialKey(userID), accountID)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return false, err
+	}
+	return added.Val() > 0, nil
+}
+
+// UnfollowOfficialAccount 取消关注，返回此前是否已关注
+func (s *RedisStore) UnfollowOfficialAccount(accountID, userID string) (bool, error) {
+	pipe := s.client.TxPipeline()
+	removed := pipe.SRem(s.ctx, officialFollowersKey(accountID), userID)
+	pipe.SRem(s.ctx, followedOfficialKey(userID), accountID)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return false, err
+	}
+	return removed.Val() > 0, nil
+}
+
+// GetFollowedOfficialAccounts 获取用户关注的公众号ID
+func (s *RedisStore) GetFollowedOfficialAccounts(userID string) ([]string, error) {
+	return s.client.SMembers(s.ctx, followedOfficialKey(userID)).Result()
+}
+
+// CountOfficialFollowers 获取公众号的关注者数
+func (s *RedisStore) CountOfficialFollowers(accountID string) (int64, error) {
+	return s.client.SCard(s.ctx, officialFollowersKey(accountID)).Result()
+}
+
+// ScanOfficialFollowers 分批遍历公众号的关注者，cursor为0时从头开始，返回的cursor为0表示遍历结束
+func (s *RedisStore) ScanOfficialFollowers(accountID string, cursor uint64, count int64) ([]string, uint64, error) {
+	return s.client.SScan(s.ctx, officialFollowersKey(accountID), cursor, "", count).Result()
+}
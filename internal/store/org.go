@@ -0,0 +1,124 @@
+package store
+
+import (
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// orgBatchSize 同步组织架构时每批写入的行数
+const orgBatchSize = 500
+
+// ListDepartments 获取全部部门
+func (s *MySQLStore) ListDepartments() ([]*model.Department, error) {
+	var departments []*model.Department
+	err := s.db.Order("sort_order, name").Find(&departments).Error
+	return departments, err
+}
+
+// GetDepartment 获取部门
+func (s *MySQLStore) GetDepartment(id string) (*model.Department, error) {
+	var department model.Department
+	err := s.db.Where("id = ?", id).First(&department).Error
+	return &department, err
+}
+
+// ListChildDepartments 获取直属子部门
+func (s *MySQLStore) ListChildDepartments(parentID string) ([]*model.Department, error) {
+	var departments []*model.Department
+	err := s.db.Where("parent_id = ?", parentID).Order("sort_order, name").Find(&departments).Error
+	return departments, err
+}
+
+// ListDepartmentMembers 获取部门的直属成员
+func (s *MySQLStore) ListDepartmentMembers(departmentID string) ([]*model.DepartmentMember, error) {
+	var members []*model.DepartmentMember
+	err := s.db.Where("department_id = ?", departmentID).Order("user_id").Find(&members).Error
+	return members, err
+}
+
+// CountDepartmentMembers 统计各部门的直属成员数
+func (s *MySQLStore) CountDepartmentMembers() (map[string]int, error) {
+	var rows []struct {
+		DepartmentID string
+		Count        int
+	}
+	err := s.db.Model(&model.DepartmentMember{}).
+		Select("department_id, COUNT(*) AS count").
+		Group("department_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.DepartmentID] = row.Count
+	}
+	return counts, nil
+}
+
+// ListAllDepartmentMembers 获取全部部门成员
+func (s *MySQLStore) ListAllDepartmentMembers() ([]*model.DepartmentMember, error) {
+	var members []*model.DepartmentMember
+	err := s.db.Find(&members).Error
+	return members, err
+}
+
+// ListUserDepartments 获取用户所属的部门
+func (s *MySQLStore) ListUserDepartments(userID string) ([]*model.Department, error) {
+	var departments []*model.Department
+	err := s.db.Where("id IN (?)", s.db.Model(&model.DepartmentMember{}).Select("department_id").Where("user_id = ?", userID)).
+		Order("sort_order, name").
+		Find(&departments).Error
+	return departments, err
+}
+
+// ReplaceOrg 在事务中用目录快照整体替换组织架构，已有部门的部门群保留，返回删除的部门数
+func (s *MySQLStore) ReplaceOrg(snapshot *model.OrgSnapshot) (int64, error) {
+	var removed int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		ids := make([]string, 0, len(snapshot.Departments))
+		for _, d := range snapshot.Departments {
+			ids = append(ids, d.ID)
+		}
+
+		deleted := tx.Where("1 = 1")
+		if len(ids) > 0 {
+			deleted = tx.Where("id NOT IN ?", ids)
+		}
+		result := deleted.Delete(&model.Department{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+
+		if len(snapshot.Departments) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"parent_id", "name", "manager_id", "sort_order", "updated_at"}),
+			}).CreateInBatches(snapshot.Departments, orgBatchSize).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("1 = 1").Delete(&model.DepartmentMember{}).Error; err != nil {
+			return err
+		}
+		if len(snapshot.Members) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(snapshot.Members, orgBatchSize).Error
+	})
+	return removed, err
+}
+
+// SetDepartmentGroup 记录部门群
+func (s *MySQLStore) SetDepartmentGroup(departmentID, groupID string) error {
+	return s.db.Model(&model.Department{}).Where("id = ?", departmentID).Update("group_id", groupID).Error
+}
+
+// UpdateGroupName 修改群组名称
+func (s *MySQLStore) UpdateGroupName(groupID, name string) error {
+	return s.db.Model(&model.Group{}).Where("id = ?", groupID).Update("name", name).Error
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+// pollDeadlinesKey 设置了截止时间且尚未结束的投票，成员为投票消息ID，score为截止时间
+const pollDeadlinesKey = "polls:deadlines"
+
+// pollVotesKey 投票的选择，字段为用户ID，值为逗号分隔的选项序号
+func pollVotesKey(messageID string) string {
+	return fmt.Sprintf("poll:votes:%s", messageID)
+}
+
+// pollTallyKey 投票的统计，字段为选项序号、voters（投票人数）和closed_at（结束时间）
+func pollTallyKey(messageID string) string {
+	return fmt.Sprintf("poll:tally:%s", messageID)
+}
+
+// castVoteScript 投票已结束时返回0；否则替换用户之前的选择并调整票数，返回1
+// ARGV: 用户ID、编码后的选择、保留秒数、新选择的各选项序号
+var castVoteScript = redis.NewScript(`
+if redis.call("HEXISTS", KEYS[2], "closed_at") == 1 then
+	return 0
+end
+local previous = redis.call("HGET", KEYS[1], ARGV[1])
+if previous then
+	for option in string.gmatch(previous, "[^,]+") do
+		redis.call("HINCRBY", KEYS[2], option, -1)
+	end
+else
+	redis.call("HINCRBY", KEYS[2], "voters", 1)
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+for i = 4, #ARGV do
+	redis.call("HINCRBY", KEYS[2], ARGV[i], 1)
+end
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+redis.call("EXPIRE", KEYS[2], ARGV[3])
+return 1
+`)
+
+// closePollScript 结束投票并移除截止时间登记，已结束时返回0
+var closePollScript = redis.NewScript(`
+redis.call("ZREM", KEYS[3], ARGV[3])
+if redis.call("HSETNX", KEYS[2], "closed_at", ARGV[1]) == 0 then
+	return 0
+end
+redis.call("EXPIRE", KEYS[1], ARGV[2])
+redis.call("EXPIRE", KEYS[2], ARGV[2])
+return 1
+`)
+
+// CastPollVote 记录用户的选择，替换之前的选择，投票已结束时返回false
+func (s *RedisStore) CastPollVote(messageID, userID string, options []int, retention time.Duration) (bool, error) {
+	args := []interface{}{userID, encodePollOptions(options), int64(retention / time.Second)}
+	for _, option := range options {
+		args = append(args, option)
+	}
+	n, err := castVoteScript.Run(s.ctx, s.client, []string{pollVotesKey(messageID), pollTallyKey(messageID)}, args...).Int()
+	return n == 1, err
+}
+
+// ClosePoll 结束投票，返回是否由本次调用结束
+func (s *RedisStore) ClosePoll(messageID string, closedAt time.Time, retention time.Duration) (bool, error) {
+	keys := []string{pollVotesKey(messageID), pollTallyKey(messageID), pollDeadlinesKey}
+	n, err := closePollScript.Run(s.ctx, s.client, keys, closedAt.Unix(), int64(retention/time.Second), messageID).Int()
+	return n == 1, err
+}
+
+// GetPollTallies 批量获取投票结果，polls为投票消息ID到选项数
+func (s *RedisStore) GetPollTallies(polls map[string]int) (map[string]*model.PollTally, error) {
+	pipe := s.client.Pipeline()
+	cmds := make(map[string]*redis.MapStringStringCmd, len(polls))
+	for id := range polls {
+		cmds[id] = pipe.HGetAll(s.ctx, pollTallyKey(id))
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return nil, err
+	}
+
+	tallies := make(map[string]*model.PollTally, len(polls))
+	for id, options := range polls {
+		values := cmds[id].Val()
+		tally := &model.PollTally{Counts: make([]int64, options)}
+		for i := range tally.Counts {
+			tally.Counts[i], _ = strconv.ParseInt(values[strconv.Itoa(i)], 10, 64)
+		}
+		tally.Voters, _ = strconv.ParseInt(values["voters"], 10, 64)
+		if closedAt, ok := values["closed_at"]; ok {
+			tally.Closed = true
+			tally.ClosedAt, _ = strconv.ParseInt(closedAt, 10, 64)
+		}
+		tallies[id] = tally
+	}
+	return tallies, nil
+}
+
+// GetPollVotes 获取投票中每个用户的选择
+func (s *RedisStore) GetPollVotes(messageID string) (map[string][]int, error) {
+	values, err := s.client.HGetAll(s.ctx, pollVotesKey(messageID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	votes := make(map[string][]int, len(values))
+	for userID, value := range values {
+		votes[userID] = decodePollOptions(value)
+	}
+	return votes, nil
+}
+
+// GetPollVote 获取用户在投票中的选择，未投票时返回nil
+func (s *RedisStore) GetPollVote(messageID, userID string) ([]int, error) {
+	value, err := s.client.HGet(s.ctx, pollVotesKey(messageID), userID).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodePollOptions(value), nil
+}
+
+// SchedulePollDeadline 登记投票的截止时间
+func (s *RedisStore) SchedulePollDeadline(messageID string, closesAt time.Time) error {
+	return s.client.ZAdd(s.ctx, pollDeadlinesKey, redis.Z{Score: float64(closesAt.Unix()), Member: messageID}).Err()
+}
+
+// GetDuePollDeadlines 获取已到截止时间的投票，按截止时间排序
+func (s *RedisStore) GetDuePollDeadlines(now time.Time, limit int64) ([]string, error) {
+	return s.client.ZRangeByScore(s.ctx, pollDeadlinesKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: limit,
+	}).Result()
+}
+
+// RemovePollDeadline 移除投票的截止时间登记
+func (s *RedisStore) RemovePollDeadline(messageID string) error {
+	return s.client.ZRem(s.ctx, pollDeadlinesKey, messageID).Err()
+}
+
+// encodePollOptions 把选项序号编码为逗号分隔的字符串
+func encodePollOptions(options []int) string {
+	parts := make([]string, len(options))
+	for i, option := range options {
+		parts[i] = strconv.Itoa(option)
+	}
+	return strings.Join(parts, ",")
+}
+
+// decodePollOptions 解析逗号分隔的选项序号，跳过无法解析的部分
+func decodePollOptions(value string) []int {
+	var options []int
+	for _, part := range strings.Split(value, ",") {
+		if option, err := strconv.Atoi(part); err == nil {
+			options = append(options, option)
+		}
+	}
+	return options
+}
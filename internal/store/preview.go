@@ -0,0 +1,36 @@
+package store
+
+import (
+	"time"
+
+	"github.com/user/im/internal/model"
+)
+
+// SetMessagePreview 保存消息的链接预览
+func (s *MySQLStore) SetMessagePreview(messageID string, preview *model.LinkPreview) error {
+	// 按结构体更新才会经过json序列化器
+	return s.db.Model(&model.Message{}).Where("id = ?", messageID).
+		Select("preview").Updates(&model.Message{Preview: preview}).Error
+}
+
+// SetMessagePreview 保存消息的链接预览，同时更新离线索引中的副本
+func (s *LevelDBStore) SetMessagePreview(messageID string, preview *model.LinkPreview) error {
+	defer s.observe("set_message_preview", time.Now())
+	return s.updateMessage(messageID, func(message *model.Message) {
+		message.Preview = preview
+	})
+}
+
+// SetMessageVoice 保存语音消息的时长和波形
+func (s *MySQLStore) SetMessageVoice(messageID string, voice *model.VoiceMetadata) error {
+	return s.db.Model(&model.Message{}).Where("id = ?", messageID).
+		Select("voice").Updates(&model.Message{Voice: voice}).Error
+}
+
+// SetMessageVoice 保存语音消息的时长和波形，同时更新离线索引中的副本
+func (s *LevelDBStore) SetMessageVoice(messageID string, voice *model.VoiceMetadata) error {
+	defer s.observe("set_message_voice", time.Now())
+	return s.updateMessage(messageID, func(message *model.Message) {
+		message.Voice = voice
+	})
+}
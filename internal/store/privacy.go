@@ -0,0 +1,175 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+// privacySettingsKey 用户的隐私设置，hash字段为用户ID，值为JSON
+const privacySettingsKey = "user:privacy"
+
+// notificationPreferencesKey 用户的通知偏好，hash字段为用户ID，值为JSON
+const notificationPreferencesKey = "user:notification_prefs"
+
+func contactsKey(userID string) string {
+	return "user:contacts:" + userID
+}
+
+func messageRequestsKey(userID string) string {
+	return "message_requests:" + userID
+}
+
+func messageRequestKey(userID, senderID string) string {
+	return fmt.Sprintf("message_request:%s:%s", userID, senderID)
+}
+
+// SetPrivacySettings 保存用户的隐私设置
+func (s *RedisStore) SetPrivacySettings(userID string, settings *model.PrivacySettings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(s.ctx, privacySettingsKey, userID, data).Err()
+}
+
+// GetPrivacySettings 批量获取用户的隐私设置，未设置的用户不在结果中
+func (s *RedisStore) GetPrivacySettings(userIDs []string) (map[string]*model.PrivacySettings, error) {
+	result := make(map[string]*model.PrivacySettings, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	values, err := s.client.HMGet(s.ctx, privacySettingsKey, userIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var settings model.PrivacySettings
+		if err := json.Unmarshal([]byte(data), &settings); err == nil {
+			result[userIDs[i]] = &settings
+		}
+	}
+	return result, nil
+}
+
+// SetNotificationPreferences 保存用户的通知偏好
+func (s *RedisStore) SetNotificationPreferences(userID string, prefs *model.NotificationPreferences) error {
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(s.ctx, notificationPreferencesKey, userID, data).Err()
+}
+
+// GetNotificationPreferences 批量获取用户的通知偏好，未设置的用户不在结果中
+func (s *RedisStore) GetNotificationPreferences(userIDs []string) (map[string]*model.NotificationPreferences, error) {
+	result := make(map[string]*model.NotificationPreferences, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	values, err := s.client.HMGet(s.ctx, notificationPreferencesKey, userIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var prefs model.NotificationPreferences
+		if err := json.Unmarshal([]byte(data), &prefs); err == nil {
+			result[userIDs[i]] = &prefs
+		}
+	}
+	return result, nil
+}
+
+// AddContact 将contactID加入用户的联系人
+func (s *RedisStore) AddContact(userID, contactID string) error {
+	return s.client.SAdd(s.ctx, contactsKey(userID), contactID).Err()
+}
+
+// GetContacts 批量判断用户是否为userID的联系人
+// 联系人集合上线前已有私聊往来的用户按最近会话判断
+func (s *RedisStore) GetContacts(userID string, otherIDs []string) (map[string]bool, error) {
+	pipe := s.client.Pipeline()
+	members := make([]*redis.BoolCmd, len(otherIDs))
+	recent := make([]*redis.FloatCmd, len(otherIDs))
+	conversationsKey := fmt.Sprintf("user:conversations:%s", userID)
+	for i, otherID := range otherIDs {
+		members[i] = pipe.SIsMember(s.ctx, contactsKey(userID), otherID)
+		recent[i] = pipe.ZScore(s.ctx, conversationsKey, model.ConversationID(model.ConversationTypePrivate, otherID))
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	contacts := make(map[string]bool, len(otherIDs))
+	for i, otherID := range otherIDs {
+		if members[i].Val() || recent[i].Err() == nil {
+			contacts[otherID] = true
+		}
+	}
+	return contacts, nil
+}
+
+// AddMessageRequest 记录非联系人发来的待接受消息，返回该发送者待接受的消息数
+func (s *RedisStore) AddMessageRequest(userID, senderID, messageID string, timestamp int64) (int64, error) {
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(s.ctx, messageRequestsKey(userID), redis.Z{Score: float64(timestamp), Member: senderID})
+	count := pipe.RPush(s.ctx, messageRequestKey(userID, senderID), messageID)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+// CountMessageRequest 获取发送者待接受的消息数，没有消息请求时为0
+func (s *RedisStore) CountMessageRequest(userID, senderID string) (int64, error) {
+	return s.client.LLen(s.ctx, messageRequestKey(userID, senderID)).Result()
+}
+
+// GetMessageRequests 按最后一条消息的时间倒序获取消息请求，返回发送者及其时间
+func (s *RedisStore) GetMessageRequests(userID string, limit int) ([]redis.Z, error) {
+	return s.client.ZRevRangeWithScores(s.ctx, messageRequestsKey(userID), 0, int64(limit-1)).Result()
+}
+
+// GetPendingSenders 批量判断发送者是否有待接受的消息请求
+func (s *RedisStore) GetPendingSenders(userID string, senderIDs []string) (map[string]bool, error) {
+	pending := make(map[string]bool)
+	if len(senderIDs) == 0 {
+		return pending, nil
+	}
+	scores, err := s.client.ZMScore(s.ctx, messageRequestsKey(userID), senderIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, score := range scores {
+		if score != 0 {
+			pending[senderIDs[i]] = true
+		}
+	}
+	return pending, nil
+}
+
+// GetMessageRequestIDs 获取发送者待接受的消息ID，按发送顺序排列
+func (s *RedisStore) GetMessageRequestIDs(userID, senderID string) ([]string, error) {
+	return s.client.LRange(s.ctx, messageRequestKey(userID, senderID), 0, -1).Result()
+}
+
+// DeleteMessageRequest 删除消息请求
+func (s *RedisStore) DeleteMessageRequest(userID, senderID string) error {
+	pipe := s.client.TxPipeline()
+	pipe.ZRem(s.ctx, messageRequestsKey(userID), senderID)
+	pipe.Del(s.ctx, messageRequestKey(userID, senderID))
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
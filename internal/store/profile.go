@@ -0,0 +1,51 @@
+package store
+
+import (
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm/clause"
+)
+
+// SaveUserProfile 保存用户资料，已存在时覆盖
+func (s *MySQLStore) SaveUserProfile(profile *model.UserProfile) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"language", "updated_at"}),
+	}).Create(profile).Error
+}
+
+// GetUserProfile 获取用户资料
+func (s *MySQLStore) GetUserProfile(userID string) (*model.UserProfile, error) {
+	var profile model.UserProfile
+	err := s.db.Where("user_id = ?", userID).First(&profile).Error
+	return &profile, err
+}
+
+// GetUserLanguages 批量获取用户偏好语言，未设置的用户不在结果中
+func (s *MySQLStore) GetUserLanguages(userIDs []string) (map[string]string, error) {
+	languages := make(map[string]string)
+	if len(userIDs) == 0 {
+		return languages, nil
+	}
+
+	var profiles []*model.UserProfile
+	err := s.db.Select("user_id", "language").
+		Where("user_id IN ? AND language <> ''", userIDs).
+		Find(&profiles).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range profiles {
+		languages[p.UserID] = p.Language
+	}
+	return languages, nil
+}
+
+// ProvisionUserProfile 身份提供方登录时创建或同步用户资料，已存在时只更新名称和邮箱，返回是否新建
+func (s *MySQLStore) ProvisionUserProfile(profile *model.UserProfile) (bool, error) {
+	result := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"display_name", "email", "updated_at"}),
+	}).Create(profile)
+	// MySQL的upsert插入新行时影响行数为1，更新已有行时为2
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuotaUsageMissing 用量计数不在Redis中，调用方需先用InitQuotaUsage恢复
+var ErrQuotaUsageMissing = errors.New("quota usage not loaded")
+
+// quotaDirtyKey 计数变化后等待持久化的主体
+const quotaDirtyKey = "quota:dirty"
+
+// 用量哈希中的字段
+const (
+	QuotaFieldMessages    = "messages"
+	QuotaFieldMediaBytes  = "media_bytes"
+	QuotaFieldOwnedGroups = "owned_groups"
+)
+
+// quotaUsageKey 主体的用量计数
+func quotaUsageKey(subject string) string {
+	return fmt.Sprintf("quota:usage:%s", subject)
+}
+
+// quotaOverridesKey 主体的配额覆盖
+func quotaOverridesKey(subject string) string {
+	return fmt.Sprintf("quota:overrides:%s", subject)
+}
+
+// consumeQuotaScript 检查配额并增减计数，日期变化时消息计数归零
+// 返回 {-2, 0} 表示计数不存在，{-1, 当前值} 表示超出配额，{1, 新值} 表示成功
+var consumeQuotaScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return {-2, 0}
+end
+if ARGV[2] == "messages" and redis.call("HGET", KEYS[1], "day") ~= ARGV[1] then
+	redis.call("HSET", KEYS[1], "day", ARGV[1], "messages", 0)
+end
+local current = tonumber(redis.call("HGET", KEYS[1], ARGV[2]) or "0")
+local delta = tonumber(ARGV[3])
+local limit = tonumber(ARGV[4])
+if delta > 0 and limit > 0 and current + delta > limit then
+	return {-1, current}
+end
+local value = redis.call("HINCRBY", KEYS[1], ARGV[2], delta)
+redis.call("SADD", KEYS[2], ARGV[5])
+return {1, value}
+`)
+
+// ConsumeQuota 在配额内增减主体的计数，limit为0时不限制，delta为负时总是成功
+// 返回增减后的值（超出配额时为当前值）和是否成功
+func (s *RedisStore) ConsumeQuota(subject, field, day string, delta, limit int64) (int64, bool, error) {
+	result, err := consumeQuotaScript.Run(s.ctx, s.client, []string{quotaUsageKey(subject), quotaDirtyKey},
+		day, field, delta, limit, subject).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	switch result[0] {
+	case -2:
+		return 0, false, ErrQuotaUsageMissing
+	case -1:
+		return result[1], false, nil
+	}
+	return result[1], true, nil
+}
+
+// InitQuotaUsage 用持久化的用量初始化计数，已存在的字段不覆盖
+func (s *RedisStore) InitQuotaUsage(usage *model.QuotaUsage) error {
+	key := quotaUsageKey(usage.Subject)
+	pipe := s.client.TxPipeline()
+	pipe.HSetNX(s.ctx, key, "day", usage.Day)
+	pipe.HSetNX(s.ctx, key, QuotaFieldMessages, usage.Messages)
+	pipe.HSetNX(s.ctx, key, QuotaFieldMediaBytes, usage.MediaBytes)
+	pipe.HSetNX(s.ctx, key, QuotaFieldOwnedGroups, usage.OwnedGroups)
+	if !usage.Overrides.IsEmpty() {
+		data, err := json.Marshal(usage.Overrides)
+		if err != nil {
+			return err
+		}
+		pipe.SetNX(s.ctx, quotaOverridesKey(usage.Subject), data, 0)
+	}
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// GetQuotaUsage 获取主体的用量计数，计数不存在时返回false
+func (s *RedisStore) GetQuotaUsage(subject string) (*model.QuotaUsage, bool, error) {
+	values, err := s.client.HGetAll(s.ctx, quotaUsageKey(subject)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(values) == 0 {
+		return nil, false, nil
+	}
+	usage := &model.QuotaUsage{Subject: subject, Day: values["day"]}
+	usage.Messages, _ = strconv.ParseInt(values[QuotaFieldMessages], 10, 64)
+	usage.MediaBytes, _ = strconv.ParseInt(values[QuotaFieldMediaBytes], 10, 64)
+	usage.OwnedGroups, _ = strconv.ParseInt(values[QuotaFieldOwnedGroups], 10, 64)
+	return usage, true, nil
+}
+
+// SetQuotaOverrides 保存主体的配额覆盖并标记待持久化
+func (s *RedisStore) SetQuotaOverrides(subject string, overrides model.QuotaOverrides) error {
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Set(s.ctx, quotaOverridesKey(subject), data, 0)
+	pipe.SAdd(s.ctx, quotaDirtyKey, subject)
+	_, err = pipe.Exec(s.ctx)
+	return err
+}
+
+// DeleteQuotaOverrides 删除主体的配额覆盖并标记待持久化
+func (s *RedisStore) DeleteQuotaOverrides(subject string) error {
+	pipe := s.client.TxPipeline()
+	pipe.Del(s.ctx, quotaOverridesKey(subject))
+	pipe.SAdd(s.ctx, quotaDirtyKey, subject)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// GetQuotaOverrides 获取主体的配额覆盖，没有覆盖时返回空值
+func (s *RedisStore) GetQuotaOverrides(subject string) (model.QuotaOverrides, error) {
+	var overrides model.QuotaOverrides
+	data, err := s.client.Get(s.ctx, quotaOverridesKey(subject)).Bytes()
+	if err == redis.Nil {
+		return overrides, nil
+	}
+	if err != nil {
+		return overrides, err
+	}
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return overrides, err
+	}
+	return overrides, nil
+}
+
+// PopDirtyQuotaSubjects 取出最多count个待持久化的主体，多个节点并发取出时互不重复
+func (s *RedisStore) PopDirtyQuotaSubjects(count int) ([]string, error) {
+	return s.client.SPopN(s.ctx, quotaDirtyKey, int64(count)).Result()
+}
+
+// MarkQuotaDirty 重新标记待持久化的主体，用于持久化失败后重试
+func (s *RedisStore) MarkQuotaDirty(subjects ...string) error {
+	if len(subjects) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(subjects))
+	for i, subject := range subjects {
+		members[i] = subject
+	}
+	return s.client.SAdd(s.ctx, quotaDirtyKey, members...).Err()
+}
+
+// SaveQuotaUsages 持久化配额用量，主体已存在时覆盖
+func (s *MySQLStore) SaveQuotaUsages(usages []*model.QuotaUsage) error {
+	if len(usages) == 0 {
+		return nil
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{"day", "messages", "media_bytes", "owned_groups", "overrides", "updated_at"}),
+	}).CreateInBatches(usages, 500).Error
+}
+
+// GetQuotaUsage 获取持久化的配额用量，没有记录时返回nil
+func (s *MySQLStore) GetQuotaUsage(subject string) (*model.QuotaUsage, error) {
+	var usage model.QuotaUsage
+	err := s.db.Where("subject = ?", subject).First(&usage).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
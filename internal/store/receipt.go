@@ -0,0 +1,115 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveReceipt 记录接收者的投递或已读确认，各状态只保留首次确认的时间
+func (s *MySQLStore) SaveReceipt(messageID, userID string, status model.MessageStatus, at int64) error {
+	receipt := &model.MessageReceipt{MessageID: messageID, UserID: userID}
+	receipt.Apply(status, at)
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "message_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"delivered_at": gorm.Expr("IF(delivered_at = 0, VALUES(delivered_at), delivered_at)"),
+			"read_at":      gorm.Expr("IF(read_at = 0, VALUES(read_at), read_at)"),
+		}),
+	}).Create(receipt).Error
+}
+
+// GetReceipts 获取消息的所有回执
+func (s *MySQLStore) GetReceipts(messageID string) ([]*model.MessageReceipt, error) {
+	var receipts []*model.MessageReceipt
+	err := s.db.Where("message_id = ?", messageID).Find(&receipts).Error
+	return receipts, err
+}
+
+// SaveReceipt 记录接收者的投递或已读确认，各状态只保留首次确认的时间
+func (s *LevelDBStore) SaveReceipt(messageID, userID string, status model.MessageStatus, at int64) error {
+	defer s.observe("save_receipt", time.Now())
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := []byte(s.receiptKey(messageID, userID))
+	receipt := model.MessageReceipt{MessageID: messageID, UserID: userID}
+	raw, err := s.db.Get(key, nil)
+	if err == nil {
+		if err := json.Unmarshal(raw, &receipt); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, leveldb.ErrNotFound) {
+		return err
+	}
+
+	receipt.Apply(status, at)
+	data, err := json.Marshal(&receipt)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(key, data, nil)
+}
+
+// GetReceipts 获取消息的所有回执
+func (s *LevelDBStore) GetReceipts(messageID string) ([]*model.MessageReceipt, error) {
+	defer s.observe("get_receipts", time.Now())
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var receipts []*model.MessageReceipt
+	iter := s.db.NewIterator(util.BytesPrefix([]byte(s.receiptKey(messageID, ""))), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var receipt model.MessageReceipt
+		if err := json.Unmarshal(iter.Value(), &receipt); err != nil {
+			continue
+		}
+		receipts = append(receipts, &receipt)
+	}
+	return receipts, iter.Error()
+}
+
+// receiptKey 回执键，按消息ID前缀组织以便一次读取和清理
+func (s *LevelDBStore) receiptKey(messageID, userID string) string {
+	return "receipt:" + messageID + ":" + userID
+}
+
+// receiptCountKey 按人数统计的群消息回执，HyperLogLog，大小与接收者数无关
+func receiptCountKey(messageID string, status model.MessageStatus) string {
+	return fmt.Sprintf("receipt:%s:%s", status, messageID)
+}
+
+// AddReceiptCount 把接收者计入消息的已投递人数，已读时同时计入已读人数，同一接收者重复确认不重复计数
+func (s *RedisStore) AddReceiptCount(messageID, userID string, status model.MessageStatus, ttl time.Duration) error {
+	statuses := []model.MessageStatus{model.MessageStatusDelivered}
+	if status == model.MessageStatusRead {
+		statuses = append(statuses, model.MessageStatusRead)
+	}
+	pipe := s.client.TxPipeline()
+	for _, st := range statuses {
+		key := receiptCountKey(messageID, st)
+		pipe.PFAdd(s.ctx, key, userID)
+		pipe.Expire(s.ctx, key, ttl)
+	}
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// GetReceiptCounts 获取消息的已投递和已读人数，HyperLogLog计数有约1%的误差
+func (s *RedisStore) GetReceiptCounts(messageID string) (int64, int64, error) {
+	pipe := s.client.Pipeline()
+	delivered := pipe.PFCount(s.ctx, receiptCountKey(messageID, model.MessageStatusDelivered))
+	read := pipe.PFCount(s.ctx, receiptCountKey(messageID, model.MessageStatusRead))
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return 0, 0, err
+	}
+	return delivered.Val(), read.Val(), nil
+}
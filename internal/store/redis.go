@@ -2,8 +2,11 @@ package store
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -19,12 +22,20 @@ type RedisStore struct {
 
 // NewRedisStore 创建Redis存储实例
 func NewRedisStore(cfg *config.RedisConfig) (*RedisStore, error) {
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure redis tls: %w", err)
+	}
+
 	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.GetAddr(),
-		Password: cfg.Password,
-		DB:       cfg.Database,
-		PoolSize: cfg.PoolSize,
+		Addr:      cfg.GetAddr(),
+		Username:  cfg.Username,
+		Password:  cfg.Password,
+		DB:        cfg.Database,
+		PoolSize:  cfg.PoolSize,
+		TLSConfig: tlsConfig,
 	})
+	client.AddHook(redisTimingHook{threshold: cfg.SlowThreshold})
 
 	ctx := context.Background()
 
@@ -67,6 +78,138 @@ func (s *RedisStore) GetUserStatus(userID string) (*model.UserStatus, error) {
 	return &status, nil
 }
 
+// AddPresenceWatch 订阅用户在线状态，watchers集合记录谁在关注该用户，watching集合记录订阅者关注了谁
+func (s *RedisStore) AddPresenceWatch(watcherID string, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	pipe := s.client.TxPipeline()
+	members := make([]interface{}, len(userIDs))
+	for i, userID := range userIDs {
+		members[i] = userID
+		pipe.SAdd(s.ctx, fmt.Sprintf("presence:watchers:%s", userID), watcherID)
+	}
+	pipe.SAdd(s.ctx, fmt.Sprintf("presence:watching:%s", watcherID), members...)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// RemovePresenceWatch 取消订阅用户在线状态
+func (s *RedisStore) RemovePresenceWatch(watcherID string, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	pipe := s.client.TxPipeline()
+	members := make([]interface{}, len(userIDs))
+	for i, userID := range userIDs {
+		members[i] = userID
+		pipe.SRem(s.ctx, fmt.Sprintf("presence:watchers:%s", userID), watcherID)
+	}
+	pipe.SRem(s.ctx, fmt.Sprintf("presence:watching:%s", watcherID), members...)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// GetPresenceWatchers 获取关注该用户在线状态的订阅者
+func (s *RedisStore) GetPresenceWatchers(userID string) ([]string, error) {
+	return s.client.SMembers(s.ctx, fmt.Sprintf("presence:watchers:%s", userID)).Result()
+}
+
+// GetPresenceWatching 获取订阅者关注的用户
+func (s *RedisStore) GetPresenceWatching(watcherID string) ([]string, error) {
+	return s.client.SMembers(s.ctx, fmt.Sprintf("presence:watching:%s", watcherID)).Result()
+}
+
+// CountPresenceWatching 获取订阅者关注的用户数
+func (s *RedisStore) CountPresenceWatching(watcherID string) (int64, error) {
+	return s.client.SCard(s.ctx, fmt.Sprintf("presence:watching:%s", watcherID)).Result()
+}
+
+// presenceConnWatchersKey 按连接关注该用户在线状态的订阅者，哈希字段为会话ID，值为订阅者的用户ID
+func presenceConnWatchersKey(userID string) string {
+	return fmt.Sprintf("presence:conn_watchers:%s", userID)
+}
+
+// presenceConnWatchingKey 连接关注的用户
+func presenceConnWatchingKey(sessionID string) string {
+	return fmt.Sprintf("presence:conn_watching:%s", sessionID)
+}
+
+// SetPresenceConnWatch 替换连接的在线状态关注列表，并刷新关注记录的过期时间，节点异常退出时遗留的列表随之过期
+func (s *RedisStore) SetPresenceConnWatch(watcherID, sessionID string, userIDs []string, ttl time.Duration) error {
+	watchingKey := presenceConnWatchingKey(sessionID)
+	previous, err := s.client.SMembers(s.ctx, watchingKey).Result()
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		keep[userID] = true
+	}
+	pipe := s.client.TxPipeline()
+	for _, userID := range previous {
+		if !keep[userID] {
+			pipe.HDel(s.ctx, presenceConnWatchersKey(userID), sessionID)
+		}
+	}
+	pipe.Del(s.ctx, watchingKey)
+	if len(userIDs) > 0 {
+		members := make([]interface{}, len(userIDs))
+		for i, userID := range userIDs {
+			members[i] = userID
+			key := presenceConnWatchersKey(userID)
+			pipe.HSet(s.ctx, key, sessionID, watcherID)
+			pipe.Expire(s.ctx, key, ttl)
+		}
+		pipe.SAdd(s.ctx, watchingKey, members...)
+		pipe.Expire(s.ctx, watchingKey, ttl)
+	}
+	_, err = pipe.Exec(s.ctx)
+	return err
+}
+
+// ClearPresenceConnWatch 删除连接的在线状态关注列表
+func (s *RedisStore) ClearPresenceConnWatch(sessionID string) error {
+	return s.SetPresenceConnWatch("", sessionID, nil, 0)
+}
+
+// GetPresenceConnWatchers 获取按连接关注该用户的订阅者用户ID，同时清理关注列表已过期的连接
+func (s *RedisStore) GetPresenceConnWatchers(userID string) ([]string, error) {
+	key := presenceConnWatchersKey(userID)
+	watchers, err := s.client.HGetAll(s.ctx, key).Result()
+	if err != nil || len(watchers) == 0 {
+		return nil, err
+	}
+
+	sessionIDs := make([]string, 0, len(watchers))
+	pipe := s.client.Pipeline()
+	exists := make([]*redis.IntCmd, 0, len(watchers))
+	for sessionID := range watchers {
+		sessionIDs = append(sessionIDs, sessionID)
+		exists = append(exists, pipe.Exists(s.ctx, presenceConnWatchingKey(sessionID)))
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(watchers))
+	var expired []string
+	for i, sessionID := range sessionIDs {
+		if exists[i].Val() == 0 {
+			expired = append(expired, sessionID)
+			continue
+		}
+		userIDs = append(userIDs, watchers[sessionID])
+	}
+	if len(expired) > 0 {
+		s.client.HDel(s.ctx, key, expired...)
+	}
+	return userIDs, nil
+}
+
 // SetUserConnection 设置用户连接信息
 func (s *RedisStore) SetUserConnection(userID, connID string) error {
 	key := fmt.Sprintf("user:conn:%s", userID)
@@ -85,6 +228,115 @@ func (s *RedisStore) RemoveUserConnection(userID string) error {
 	return s.client.Del(s.ctx, key).Err()
 }
 
+// SetUserGateway 记录用户所在的网关节点
+func (s *RedisStore) SetUserGateway(userID, gatewayID string, ttl time.Duration) error {
+	key := fmt.Sprintf("user:gateway:%s", userID)
+	return s.client.Set(s.ctx, key, gatewayID, ttl).Err()
+}
+
+// GetUserGateway 获取用户所在的网关节点
+func (s *RedisStore) GetUserGateway(userID string) (string, error) {
+	key := fmt.Sprintf("user:gateway:%s", userID)
+	return s.client.Get(s.ctx, key).Result()
+}
+
+// GetUserGateways 批量获取用户所在的网关节点，不在线的用户不包含在结果中
+func (s *RedisStore) GetUserGateways(userIDs []string) (map[string]string, error) {
+	result := make(map[string]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = fmt.Sprintf("user:gateway:%s", userID)
+	}
+
+	values, err := s.client.MGet(s.ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		if gatewayID, ok := value.(string); ok && gatewayID != "" {
+			result[userIDs[i]] = gatewayID
+		}
+	}
+	return result, nil
+}
+
+// RemoveUserGateway 移除用户网关映射，仅当映射仍指向该网关时删除
+func (s *RedisStore) RemoveUserGateway(userID, gatewayID string) error {
+	key := fmt.Sprintf("user:gateway:%s", userID)
+	current, err := s.client.Get(s.ctx, key).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current != gatewayID {
+		return nil
+	}
+	return s.client.Del(s.ctx, key).Err()
+}
+
+// acquireLockScript 加锁成功时在同一脚本内递增fencing token并返回，锁已被占用时返回0
+var acquireLockScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+// renewLockScript 仅当锁仍由owner持有时续期
+var renewLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLockScript 仅当锁仍由owner持有时释放
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// AcquireLock 尝试获取锁，成功时返回单调递增的fencing token
+func (s *RedisStore) AcquireLock(key, owner string, ttl time.Duration) (int64, bool, error) {
+	token, err := acquireLockScript.Run(s.ctx, s.client, []string{key, key + ":fence"}, owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, false, err
+	}
+	return token, token > 0, nil
+}
+
+// RenewLock 续期锁，锁已不属于owner时返回false
+func (s *RedisStore) RenewLock(key, owner string, ttl time.Duration) (bool, error) {
+	n, err := renewLockScript.Run(s.ctx, s.client, []string{key}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ReleaseLock 释放锁
+func (s *RedisStore) ReleaseLock(key, owner string) error {
+	return releaseLockScript.Run(s.ctx, s.client, []string{key}, owner).Err()
+}
+
+// GetLockOwner 获取锁的持有者
+func (s *RedisStore) GetLockOwner(key string) (string, error) {
+	owner, err := s.client.Get(s.ctx, key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return owner, err
+}
+
 // PublishMessage 发布消息到频道
 func (s *RedisStore) PublishMessage(channel string, message interface{}) error {
 	data, err := json.Marshal(message)
@@ -100,23 +352,51 @@ func (s *RedisStore) Subscribe(channels ...string) *redis.PubSub {
 	return s.client.Subscribe(s.ctx, channels...)
 }
 
+// offlineQueuedKey 全部离线队列的消息总数，入队和出队时同步增减
+const offlineQueuedKey = "stats:offline_queued"
+
+// offlineKey 离线消息队列键，紧急消息单独排队以便优先投递
+func offlineKey(userID string, urgent bool) string {
+	if urgent {
+		return fmt.Sprintf("offline:urgent:%s", userID)
+	}
+	return fmt.Sprintf("offline:msg:%s", userID)
+}
+
 // SetOfflineMessage 设置离线消息
 func (s *RedisStore) SetOfflineMessage(userID string, message *model.Message) error {
-	key := fmt.Sprintf("offline:msg:%s", userID)
+	key := offlineKey(userID, message.IsUrgent())
 	data, err := json.Marshal(message)
 	if err != nil {
 		return err
 	}
 
 	// 使用List存储离线消息，过期时间7天
-	return s.client.LPush(s.ctx, key, data).Err()
+	pipe := s.client.TxPipeline()
+	pipe.LPush(s.ctx, key, data)
+	pipe.Incr(s.ctx, offlineQueuedKey)
+	_, err = pipe.Exec(s.ctx)
+	return err
 }
 
-// GetOfflineMessages 获取离线消息
+// GetOfflineMessages 获取离线消息，紧急消息排在最前
 func (s *RedisStore) GetOfflineMessages(userID string, limit int64) ([]*model.Message, error) {
-	key := fmt.Sprintf("offline:msg:%s", userID)
+	messages, err := s.popOfflineMessages(offlineKey(userID, true), limit)
+	if err != nil {
+		return nil, err
+	}
+	if remaining := limit - int64(len(messages)); remaining > 0 {
+		normal, err := s.popOfflineMessages(offlineKey(userID, false), remaining)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, normal...)
+	}
+	return messages, nil
+}
 
-	// 获取并删除离线消息
+// popOfflineMessages 获取并删除队列中的离线消息
+func (s *RedisStore) popOfflineMessages(key string, limit int64) ([]*model.Message, error) {
 	data, err := s.client.LRange(s.ctx, key, 0, limit-1).Result()
 	if err != nil {
 		return nil, err
@@ -132,54 +412,106 @@ func (s *RedisStore) GetOfflineMessages(userID string, limit int64) ([]*model.Me
 	}
 
 	// 删除已获取的消息
-	if len(messages) > 0 {
-		s.client.LTrim(s.ctx, key, int64(len(messages)), -1)
+	if len(data) > 0 {
+		pipe := s.client.TxPipeline()
+		pipe.LTrim(s.ctx, key, int64(len(data)), -1)
+		pipe.DecrBy(s.ctx, offlineQueuedKey, int64(len(data)))
+		pipe.Exec(s.ctx)
 	}
 
 	return messages, nil
 }
 
-// SetGroupMembers 设置群组成员
-func (s *RedisStore) SetGroupMembers(groupID string, members []string) error {
-	key := fmt.Sprintf("group:members:%s", groupID)
+// HasUrgentOfflineMessages 用户的紧急离线队列是否非空
+func (s *RedisStore) HasUrgentOfflineMessages(userID string) (bool, error) {
+	n, err := s.client.Exists(s.ctx, offlineKey(userID, true)).Result()
+	return n > 0, err
+}
 
-	// 删除旧数据
-	s.client.Del(s.ctx, key)
+// PeekOfflineMessages 查看离线队列中的消息但不删除，同时返回无法解析的条目数
+func (s *RedisStore) PeekOfflineMessages(userID string, urgent bool, limit int64) ([]*model.Message, int, error) {
+	data, err := s.client.LRange(s.ctx, offlineKey(userID, urgent), 0, limit-1).Result()
+	if err != nil {
+		return nil, 0, err
+	}
 
-	// 添加新数据
-	if len(members) > 0 {
-		args := make([]interface{}, len(members))
-		for i, member := range members {
-			args[i] = member
+	messages := make([]*model.Message, 0, len(data))
+	corrupted := 0
+	for _, item := range data {
+		var message model.Message
+		if err := json.Unmarshal([]byte(item), &message); err != nil {
+			corrupted++
+			continue
 		}
-		return s.client.SAdd(s.ctx, key, args...).Err()
+		messages = append(messages, &message)
 	}
+	return messages, corrupted, nil
+}
 
-	return nil
+// ClearOfflineMessages 清空用户的普通和紧急离线队列，返回删除的条目数
+func (s *RedisStore) ClearOfflineMessages(userID string) (int64, error) {
+	keys := []string{offlineKey(userID, true), offlineKey(userID, false)}
+	var total int64
+	for _, key := range keys {
+		n, err := s.client.LLen(s.ctx, key).Result()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Del(s.ctx, keys...)
+	pipe.DecrBy(s.ctx, offlineQueuedKey, total)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return 0, err
+	}
+	return total, nil
 }
 
-// GetGroupMembers 获取群组成员
-func (s *RedisStore) GetGroupMembers(groupID string) ([]string, error) {
-	key := fmt.Sprintf("group:members:%s", groupID)
-	return s.client.SMembers(s.ctx, key).Result()
+// CountOfflineMessages 全部用户离线队列中的消息总数
+func (s *RedisStore) CountOfflineMessages() (int64, error) {
+	n, err := s.client.Get(s.ctx, offlineQueuedKey).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	// 计数器引入之前入队的消息出队后会减成负数
+	if n < 0 {
+		n = 0
+	}
+	return n, nil
 }
 
-// AddGroupMember 添加群组成员
-func (s *RedisStore) AddGroupMember(groupID, userID string) error {
-	key := fmt.Sprintf("group:members:%s", groupID)
-	return s.client.SAdd(s.ctx, key, userID).Err()
+// incrIfExistsScript 仅当计数存在时增减
+var incrIfExistsScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("INCRBY", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// SetChannelCursor 设置超大群成员的读游标
+func (s *RedisStore) SetChannelCursor(groupID, userID, messageID string) error {
+	key := fmt.Sprintf("channel:cursor:%s", groupID)
+	return s.client.HSet(s.ctx, key, userID, messageID).Err()
 }
 
-// RemoveGroupMember 移除群组成员
-func (s *RedisStore) RemoveGroupMember(groupID, userID string) error {
-	key := fmt.Sprintf("group:members:%s", groupID)
-	return s.client.SRem(s.ctx, key, userID).Err()
+// GetChannelCursor 获取超大群成员的读游标
+func (s *RedisStore) GetChannelCursor(groupID, userID string) (string, error) {
+	key := fmt.Sprintf("channel:cursor:%s", groupID)
+	cursor, err := s.client.HGet(s.ctx, key, userID).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return cursor, err
 }
 
-// IsGroupMember 检查是否为群组成员
-func (s *RedisStore) IsGroupMember(groupID, userID string) (bool, error) {
-	key := fmt.Sprintf("group:members:%s", groupID)
-	return s.client.SIsMember(s.ctx, key, userID).Result()
+// RemoveChannelCursor 移除超大群成员的读游标
+func (s *RedisStore) RemoveChannelCursor(groupID, userID string) error {
+	key := fmt.Sprintf("channel:cursor:%s", groupID)
+	return s.client.HDel(s.ctx, key, userID).Err()
 }
 
 // SetMessageCache 设置消息缓存
@@ -194,6 +526,12 @@ func (s *RedisStore) SetMessageCache(messageID string, message *model.Message) e
 	return s.client.Set(s.ctx, key, data, time.Hour).Err()
 }
 
+// DeleteMessageCache 删除消息缓存
+func (s *RedisStore) DeleteMessageCache(messageID string) error {
+	key := fmt.Sprintf("msg:cache:%s", messageID)
+	return s.client.Del(s.ctx, key).Err()
+}
+
 // GetMessageCache 获取消息缓存
 func (s *RedisStore) GetMessageCache(messageID string) (*model.Message, error) {
 	key := fmt.Sprintf("msg:cache:%s", messageID)
@@ -214,3 +552,482 @@ func (s *RedisStore) GetMessageCache(messageID string) (*model.Message, error) {
 func (s *RedisStore) Close() error {
 	return s.client.Close()
 }
+
+// Ping 检查Redis连接是否可用
+func (s *RedisStore) Ping() error {
+	return s.client.Ping(s.ctx).Err()
+}
+
+// AcquireSlowMode 占用成员在慢速模式群中的发言窗口，窗口未结束时返回false和剩余时间
+func (s *RedisStore) AcquireSlowMode(groupID, userID string, window time.Duration) (bool, time.Duration, error) {
+	key := fmt.Sprintf("group:slowmode:%s:%s", groupID, userID)
+	ok, err := s.client.SetNX(s.ctx, key, 1, window).Result()
+	if err != nil || ok {
+		return ok, 0, err
+	}
+
+	ttl, err := s.client.TTL(s.ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	return false, ttl, nil
+}
+
+// SetUserSanction 设置用户全局处罚，until为截止时间（Unix秒），0表示永久
+func (s *RedisStore) SetUserSanction(userID string, sanctionType model.SanctionType, until int64) error {
+	key := fmt.Sprintf("user:sanction:%s:%s", userID, sanctionType)
+	var ttl time.Duration
+	if until > 0 {
+		ttl = time.Until(time.Unix(until, 0))
+		if ttl <= 0 {
+			return s.client.Del(s.ctx, key).Err()
+		}
+	}
+	return s.client.Set(s.ctx, key, until, ttl).Err()
+}
+
+// GetUserSanction 获取用户全局处罚的截止时间，未处罚时返回false
+func (s *RedisStore) GetUserSanction(userID string, sanctionType model.SanctionType) (int64, bool, error) {
+	key := fmt.Sprintf("user:sanction:%s:%s", userID, sanctionType)
+	until, err := s.client.Get(s.ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return until, true, nil
+}
+
+// RemoveUserSanction 解除用户全局处罚
+func (s *RedisStore) RemoveUserSanction(userID string, sanctionType model.SanctionType) error {
+	key := fmt.Sprintf("user:sanction:%s:%s", userID, sanctionType)
+	return s.client.Del(s.ctx, key).Err()
+}
+
+// incrUnreadScript 会话未读数加一，返回所有会话的未读总数
+var incrUnreadScript = redis.NewScript(`
+redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+local total = 0
+for _, n in ipairs(redis.call("HVALS", KEYS[1])) do
+	total = total + tonumber(n)
+end
+return total
+`)
+
+// unreadKey 用户各会话未读数的哈希
+func unreadKey(userID string) string {
+	return fmt.Sprintf("unread:%s", userID)
+}
+
+// IncrUnread 用户在会话中的未读数加一，返回所有会话的未读总数
+func (s *RedisStore) IncrUnread(userID, conversationID string) (int64, error) {
+	return incrUnreadScript.Run(s.ctx, s.client, []string{unreadKey(userID)}, conversationID).Int64()
+}
+
+// GetUnreadTotal 获取用户所有会话的未读总数
+func (s *RedisStore) GetUnreadTotal(userID string) (int64, error) {
+	counts, err := s.client.HVals(s.ctx, unreadKey(userID)).Result()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, count := range counts {
+		n, _ := strconv.ParseInt(count, 10, 64)
+		total += n
+	}
+	return total, nil
+}
+
+// ClearUnread 清除用户在会话中的未读数
+func (s *RedisStore) ClearUnread(userID, conversationID string) error {
+	return s.client.HDel(s.ctx, unreadKey(userID), conversationID).Err()
+}
+
+// incrWindowScript 窗口计数器，首次计数时设置过期时间
+var incrWindowScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// addWindowSetScript 窗口去重集合，首次写入时设置过期时间
+var addWindowSetScript = redis.NewScript(`
+redis.call("SADD", KEYS[1], ARGV[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return redis.call("SCARD", KEYS[1])
+`)
+
+// IncrSpamCounter 累加用户在统计窗口内的规则计数，返回当前计数
+func (s *RedisStore) IncrSpamCounter(userID, rule string, window time.Duration) (int64, error) {
+	key := fmt.Sprintf("spam:%s:%s", rule, userID)
+	return incrWindowScript.Run(s.ctx, s.client, []string{key}, window.Milliseconds()).Int64()
+}
+
+// AddGroupTraffic 把消息计入群在窗口内的消息数，返回当前计数，同一条消息分批扇出时只计一次
+func (s *RedisStore) AddGroupTraffic(groupID, messageID string, window time.Duration) (int64, error) {
+	key := fmt.Sprintf("group:traffic:%s", groupID)
+	return addWindowSetScript.Run(s.ctx, s.client, []string{key}, messageID, window.Milliseconds()).Int64()
+}
+
+// AddSpamTarget 记录用户在统计窗口内触达的目标，返回不同目标数
+func (s *RedisStore) AddSpamTarget(userID, rule, target string, window time.Duration) (int64, error) {
+	key := fmt.Sprintf("spam:%s:%s", rule, userID)
+	return addWindowSetScript.Run(s.ctx, s.client, []string{key}, target, window.Milliseconds()).Int64()
+}
+
+// SetSpamThrottle 限制用户发送
+func (s *RedisStore) SetSpamThrottle(userID string, ttl time.Duration) error {
+	key := fmt.Sprintf("spam:throttle:%s", userID)
+	return s.client.Set(s.ctx, key, 1, ttl).Err()
+}
+
+// GetSpamThrottle 获取用户发送限制的剩余时间，未限制时返回false
+func (s *RedisStore) GetSpamThrottle(userID string) (time.Duration, bool, error) {
+	key := fmt.Sprintf("spam:throttle:%s", userID)
+	ttl, err := s.client.PTTL(s.ctx, key).Result()
+	if err != nil {
+		return 0, false, err
+	}
+	// 键不存在时PTTL返回负值
+	if ttl <= 0 {
+		return 0, false, nil
+	}
+	return ttl, true, nil
+}
+
+// maxRecentConversations 每个用户保留的最近私聊会话数
+const maxRecentConversations = 1000
+
+// conversationSettingsTTL 会话设置缓存时长
+const conversationSettingsTTL = 24 * time.Hour
+
+// TouchConversation 更新用户最近会话的活跃时间，只保留最近的若干个
+func (s *RedisStore) TouchConversation(userID, conversationID string, timestamp int64) error {
+	key := fmt.Sprintf("user:conversations:%s", userID)
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(s.ctx, key, redis.Z{Score: float64(timestamp), Member: conversationID})
+	pipe.ZRemRangeByRank(s.ctx, key, 0, -maxRecentConversations-1)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// GetRecentConversations 获取用户最近会话及其活跃时间
+func (s *RedisStore) GetRecentConversations(userID string) (map[string]int64, error) {
+	key := fmt.Sprintf("user:conversations:%s", userID)
+	items, err := s.client.ZRangeWithScores(s.ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	conversations := make(map[string]int64, len(items))
+	for _, item := range items {
+		if id, ok := item.Member.(string); ok {
+			conversations[id] = int64(item.Score)
+		}
+	}
+	return conversations, nil
+}
+
+// SetGroupLastActive 记录群组最后一条消息的时间
+func (s *RedisStore) SetGroupLastActive(groupID string, timestamp int64) error {
+	return s.client.HSet(s.ctx, "group:last_active", groupID, timestamp).Err()
+}
+
+// GetGroupLastActive 批量获取群组最后一条消息的时间
+func (s *RedisStore) GetGroupLastActive(groupIDs []string) (map[string]int64, error) {
+	result := make(map[string]int64, len(groupIDs))
+	if len(groupIDs) == 0 {
+		return result, nil
+	}
+
+	values, err := s.client.HMGet(s.ctx, "group:last_active", groupIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		if str, ok := v.(string); ok {
+			if ts, err := strconv.ParseInt(str, 10, 64); err == nil {
+				result[groupIDs[i]] = ts
+			}
+		}
+	}
+	return result, nil
+}
+
+// SetConversationSettingsCache 缓存用户的全部会话设置
+func (s *RedisStore) SetConversationSettingsCache(userID string, settings []*model.UserConversationSettings) error {
+	key := fmt.Sprintf("conversation:settings:%s", userID)
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(s.ctx, key, data, conversationSettingsTTL).Err()
+}
+
+// GetConversationSettingsCache 获取缓存的用户会话设置，未缓存时返回false
+func (s *RedisStore) GetConversationSettingsCache(userID string) ([]*model.UserConversationSettings, bool, error) {
+	key := fmt.Sprintf("conversation:settings:%s", userID)
+	data, err := s.client.Get(s.ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var settings []*model.UserConversationSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, false, err
+	}
+	return settings, true, nil
+}
+
+// DeleteConversationSettingsCache 删除用户会话设置缓存
+func (s *RedisStore) DeleteConversationSettingsCache(userID string) error {
+	key := fmt.Sprintf("conversation:settings:%s", userID)
+	return s.client.Del(s.ctx, key).Err()
+}
+
+// SetDraft 保存用户在会话中的草稿
+func (s *RedisStore) SetDraft(userID string, draft *model.Draft, ttl time.Duration) error {
+	key := fmt.Sprintf("draft:%s:%s", userID, draft.ConversationID)
+	data, err := json.Marshal(draft)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(s.ctx, key, data, ttl).Err()
+}
+
+// GetDraft 获取用户在会话中的草稿，不存在时返回false
+func (s *RedisStore) GetDraft(userID, conversationID string) (*model.Draft, bool, error) {
+	key := fmt.Sprintf("draft:%s:%s", userID, conversationID)
+	data, err := s.client.Get(s.ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var draft model.Draft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		return nil, false, err
+	}
+	return &draft, true, nil
+}
+
+// DeleteDraft 删除用户在会话中的草稿
+func (s *RedisStore) DeleteDraft(userID, conversationID string) error {
+	key := fmt.Sprintf("draft:%s:%s", userID, conversationID)
+	return s.client.Del(s.ctx, key).Err()
+}
+
+// linkPreviewKey 链接预览缓存键，URL取摘要避免键过长
+func linkPreviewKey(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return "linkpreview:" + hex.EncodeToString(sum[:])
+}
+
+// SetLinkPreviewCache 缓存链接预览，preview为nil表示抓取失败
+func (s *RedisStore) SetLinkPreviewCache(url string, preview *model.LinkPreview, ttl time.Duration) error {
+	data, err := json.Marshal(preview)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(s.ctx, linkPreviewKey(url), data, ttl).Err()
+}
+
+// GetLinkPreviewCache 获取缓存的链接预览，未缓存时返回false，缓存了抓取失败时预览为nil
+func (s *RedisStore) GetLinkPreviewCache(url string) (*model.LinkPreview, bool, error) {
+	data, err := s.client.Get(s.ctx, linkPreviewKey(url)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var preview *model.LinkPreview
+	if err := json.Unmarshal(data, &preview); err != nil {
+		return nil, false, err
+	}
+	return preview, true, nil
+}
+
+// linkVerdictKey 链接信誉缓存键，URL取摘要避免键过长
+func linkVerdictKey(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return "linksafety:" + hex.EncodeToString(sum[:])
+}
+
+// SetLinkVerdict 缓存链接的信誉查询结果
+func (s *RedisStore) SetLinkVerdict(url string, verdict *model.LinkVerdict, ttl time.Duration) error {
+	data, err := json.Marshal(verdict)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(s.ctx, linkVerdictKey(url), data, ttl).Err()
+}
+
+// GetLinkVerdict 获取缓存的链接信誉查询结果，未缓存时返回nil
+func (s *RedisStore) GetLinkVerdict(url string) (*model.LinkVerdict, error) {
+	data, err := s.client.Get(s.ctx, linkVerdictKey(url)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var verdict model.LinkVerdict
+	if err := json.Unmarshal(data, &verdict); err != nil {
+		return nil, err
+	}
+	return &verdict, nil
+}
+
+// userLanguageKey 用户偏好语言缓存，hash字段为用户ID
+const userLanguageKey = "user:language"
+
+// SetUserLanguage 缓存用户偏好语言，空字符串表示使用默认语言
+func (s *RedisStore) SetUserLanguage(userID, language string) error {
+	return s.client.HSet(s.ctx, userLanguageKey, userID, language).Err()
+}
+
+// GetUserLanguages 批量获取缓存的用户偏好语言，返回命中的结果和未缓存的用户
+func (s *RedisStore) GetUserLanguages(userIDs []string) (map[string]string, []string, error) {
+	languages := make(map[string]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return languages, nil, nil
+	}
+
+	values, err := s.client.HMGet(s.ctx, userLanguageKey, userIDs...).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	var missing []string
+	for i, v := range values {
+		if lang, ok := v.(string); ok {
+			languages[userIDs[i]] = lang
+		} else {
+			missing = append(missing, userIDs[i])
+		}
+	}
+	return languages, missing, nil
+}
+
+// quietHoursKey 用户的免打扰计划，hash字段为用户ID，值为JSON
+const quietHoursKey = "user:quiet_hours"
+
+// SetQuietHours 保存用户的免打扰计划
+func (s *RedisStore) SetQuietHours(userID string, quietHours *model.QuietHours) error {
+	data, err := json.Marshal(quietHours)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(s.ctx, quietHoursKey, userID, data).Err()
+}
+
+// GetQuietHours 批量获取用户的免打扰计划，未设置的用户不在结果中
+func (s *RedisStore) GetQuietHours(userIDs []string) (map[string]*model.QuietHours, error) {
+	schedules := make(map[string]*model.QuietHours, len(userIDs))
+	if len(userIDs) == 0 {
+		return schedules, nil
+	}
+
+	values, err := s.client.HMGet(s.ctx, quietHoursKey, userIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var quietHours model.QuietHours
+		if err := json.Unmarshal([]byte(data), &quietHours); err == nil {
+			schedules[userIDs[i]] = &quietHours
+		}
+	}
+	return schedules, nil
+}
+
+// apiKeyCacheKey API密钥缓存键，按密钥摘要索引
+func apiKeyCacheKey(hash string) string {
+	return "apikey:" + hash
+}
+
+// SetAPIKeyCache 缓存已验证的API密钥
+func (s *RedisStore) SetAPIKeyCache(hash string, key *model.APIKey, ttl time.Duration) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(s.ctx, apiKeyCacheKey(hash), data, ttl).Err()
+}
+
+// GetAPIKeyCache 获取缓存的API密钥，未缓存时返回false
+func (s *RedisStore) GetAPIKeyCache(hash string) (*model.APIKey, bool, error) {
+	data, err := s.client.Get(s.ctx, apiKeyCacheKey(hash)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var key model.APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, false, err
+	}
+	return &key, true, nil
+}
+
+// DeleteAPIKeyCache 删除API密钥缓存
+func (s *RedisStore) DeleteAPIKeyCache(hash string) error {
+	return s.client.Del(s.ctx, apiKeyCacheKey(hash)).Err()
+}
+
+// IncrAPIKeyUsage 累加API密钥在窗口内的调用次数，返回当前计数和窗口剩余时间
+func (s *RedisStore) IncrAPIKeyUsage(keyID string, window time.Duration) (int64, time.Duration, error) {
+	key := fmt.Sprintf("apikey:rate:%s", keyID)
+	n, err := incrWindowScript.Run(s.ctx, s.client, []string{key}, window.Milliseconds()).Int64()
+	if err != nil {
+		return 0, 0, err
+	}
+	ttl, err := s.client.PTTL(s.ctx, key).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, ttl, nil
+}
+
+// IncrHTTPRequests 累加客户端在窗口内的HTTP请求数，返回当前计数和窗口剩余时间
+func (s *RedisStore) IncrHTTPRequests(client string, window time.Duration) (int64, time.Duration, error) {
+	key := fmt.Sprintf("http:rate:%s", client)
+	n, err := incrWindowScript.Run(s.ctx, s.client, []string{key}, window.Milliseconds()).Int64()
+	if err != nil {
+		return 0, 0, err
+	}
+	ttl, err := s.client.PTTL(s.ctx, key).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, ttl, nil
+}
+
+// ClaimProcessed 标记ID在某个消费者中已处理，返回false表示此前已处理过
+func (s *RedisStore) ClaimProcessed(scope, id string, ttl time.Duration) (bool, error) {
+	key := fmt.Sprintf("dedup:%s:%s", scope, id)
+	return s.client.SetNX(s.ctx, key, 1, ttl).Result()
+}
+
+// ReleaseProcessed 取消处理标记
+func (s *RedisStore) ReleaseProcessed(scope, id string) error {
+	return s.client.Del(s.ctx, fmt.Sprintf("dedup:%s:%s", scope, id)).Err()
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/model"
+)
+
+// testRedisStore 连接IM_TEST_REDIS_ADDR（host:port）指定的Redis，未设置时跳过
+func testRedisStore(t *testing.T) *RedisStore {
+	addr := os.Getenv("IM_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("IM_TEST_REDIS_ADDR not set")
+	}
+	host, port, _ := strings.Cut(addr, ":")
+	portNum, err := strconv.Atoi(port)
+	assert.NoError(t, err)
+	s, err := NewRedisStore(&config.RedisConfig{Host: host, Port: portNum})
+	if err != nil {
+		t.Skipf("redis unavailable: %v", err)
+	}
+	return s
+}
+
+func TestRedisDraft_RoundTrip(t *testing.T) {
+	s := testRedisStore(t)
+	userID := "test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	conversationID := model.ConversationID(model.ConversationTypePrivate, "u2")
+	t.Cleanup(func() { s.DeleteDraft(userID, conversationID) })
+
+	_, ok, err := s.GetDraft(userID, conversationID)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	draft := &model.Draft{ConversationID: conversationID, Content: "hello", UpdatedAt: 100}
+	assert.NoError(t, s.SetDraft(userID, draft, time.Minute))
+	got, ok, err := s.GetDraft(userID, conversationID)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, draft, got)
+	ttl := s.client.TTL(s.ctx, "draft:"+userID+":"+conversationID).Val()
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+
+	assert.NoError(t, s.DeleteDraft(userID, conversationID))
+	_, ok, err = s.GetDraft(userID, conversationID)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRedisUnread_CountsPerConversation(t *testing.T) {
+	s := testRedisStore(t)
+	userID := "test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	t.Cleanup(func() { s.client.Del(s.ctx, unreadKey(userID)) })
+
+	total, err := s.IncrUnread(userID, "private:u1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	s.IncrUnread(userID, "private:u1")
+	total, err = s.IncrUnread(userID, "private:u2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+
+	// 已读后只清零该会话
+	assert.NoError(t, s.ClearUnread(userID, "private:u1"))
+	total, err = s.GetUnreadTotal(userID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+}
+
+func TestRedisGroupTraffic_CountsMessagesOnce(t *testing.T) {
+	s := testRedisStore(t)
+	groupID := "test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	t.Cleanup(func() { s.client.Del(s.ctx, "group:traffic:"+groupID) })
+
+	// 分批扇出的同一条消息只计一次
+	for _, id := range []string{"m1", "m1", "m2"} {
+		_, err := s.AddGroupTraffic(groupID, id, time.Minute)
+		assert.NoError(t, err)
+	}
+	n, err := s.AddGroupTraffic(groupID, "m3", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+}
+
+func TestRedisNotificationPreferences_RoundTrip(t *testing.T) {
+	s := testRedisStore(t)
+	userID := "test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	t.Cleanup(func() { s.client.HDel(s.ctx, notificationPreferencesKey, userID) })
+
+	assert.NoError(t, s.SetNotificationPreferences(userID, &model.NotificationPreferences{GroupDigest: model.DigestOff, UpdatedAt: 1}))
+	prefs, err := s.GetNotificationPreferences([]string{userID, userID + ":missing"})
+	assert.NoError(t, err)
+	assert.Len(t, prefs, 1)
+	assert.Equal(t, model.DigestOff, prefs[userID].GroupDigest)
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"github.com/user/im/internal/model"
+)
+
+// ReportFilter 举报列表查询条件，空字段不过滤
+type ReportFilter struct {
+	Status       model.ReportStatus
+	TargetUserID string
+	BeforeID     string // 游标，只返回ID小于该值的举报
+	Limit        int
+}
+
+// SaveReport 保存举报
+func (s *MySQLStore) SaveReport(report *model.Report) error {
+	return s.db.Create(report).Error
+}
+
+// GetReport 获取举报
+func (s *MySQLStore) GetReport(id string) (*model.Report, error) {
+	var report model.Report
+	err := s.db.Where("id = ?", id).First(&report).Error
+	return &report, err
+}
+
+// ListReports 按提交时间倒序查询举报
+func (s *MySQLStore) ListReports(filter ReportFilter) ([]*model.Report, error) {
+	query := s.db.Model(&model.Report{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.TargetUserID != "" {
+		query = query.Where("target_user_id = ?", filter.TargetUserID)
+	}
+	if filter.BeforeID != "" {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	var reports []*model.Report
+	err := query.Order("id DESC").Limit(filter.Limit).Find(&reports).Error
+	return reports, err
+}
+
+// ResolveReport 记录举报的处理结果，只更新仍待处理的举报，返回是否更新
+func (s *MySQLStore) ResolveReport(report *model.Report) (bool, error) {
+	result := s.db.Model(&model.Report{}).
+		Where("id = ? AND status = ?", report.ID, model.ReportStatusOpen).
+		Updates(map[string]interface{}{
+			"status":      report.Status,
+			"action":      report.Action,
+			"resolved_by": report.ResolvedBy,
+			"resolved_at": report.ResolvedAt,
+			"note":        report.Note,
+		})
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm"
+)
+
+// seqKey 会话的消息序号计数器，会话键与消息的分区键相同
+func seqKey(conversation string) string {
+	return fmt.Sprintf("seq:%s", conversation)
+}
+
+// NextSeq 分配会话的下一个消息序号，计数器不存在时返回false，调用方需先用InitSeq从数据库恢复
+func (s *RedisStore) NextSeq(conversation string) (int64, bool, error) {
+	seq, err := incrIfExistsScript.Run(s.ctx, s.client, []string{seqKey(conversation)}, 1).Int64()
+	if err != nil {
+		return 0, false, err
+	}
+	return seq, seq > 0, nil
+}
+
+// InitSeq 以已分配的最大序号初始化计数器，计数器已存在时不覆盖
+func (s *RedisStore) InitSeq(conversation string, maxSeq int64) error {
+	return s.client.SetNX(s.ctx, seqKey(conversation), maxSeq, 0).Err()
+}
+
+// conversationMessages 消息所在会话的查询条件，私聊不区分方向
+func (s *MySQLStore) conversationMessages(message *model.Message) *gorm.DB {
+	if message.IsGroupMessage() {
+		return s.db.Model(&model.Message{}).Where("group_id = ?", message.GroupID)
+	}
+	return s.db.Model(&model.Message{}).
+		Where("group_id = '' AND ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))",
+			message.SenderID, message.ReceiverID, message.ReceiverID, message.SenderID)
+}
+
+// GetMaxSeq 获取消息所在会话已分配的最大序号
+func (s *MySQLStore) GetMaxSeq(message *model.Message) (int64, error) {
+	var maxSeq int64
+	err := s.conversationMessages(message).Select("COALESCE(MAX(seq), 0)").Scan(&maxSeq).Error
+	return maxSeq, err
+}
+
+// GetMessagesAfterSeq 按序号获取会话中afterSeq之后的消息，message只用于确定会话
+func (s *MySQLStore) GetMessagesAfterSeq(message *model.Message, afterSeq int64, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	err := s.conversationMessages(message).
+		Where("seq > ?", afterSeq).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// MessageFilter 会话消息查询条件，空字段不过滤
+// BeforeSeq大于0时按序号倒序查询BeforeSeq之前的消息，否则按序号正序查询AfterSeq之后的消息
+type MessageFilter struct {
+	SenderID  string
+	Types     []model.MessageType
+	Since     int64 // 只返回该时间（Unix秒）及之后的消息
+	AfterSeq  int64
+	BeforeSeq int64
+	Limit     int
+}
+
+// GetConversationMessages 按条件获取会话中的消息，message只用于确定会话
+// 按类型过滤时不返回已对所有人删除的消息
+func (s *MySQLStore) GetConversationMessages(message *model.Message, filter MessageFilter) ([]*model.Message, error) {
+	query := s.conversationMessages(message)
+	if filter.SenderID != "" {
+		query = query.Where("sender_id = ?", filter.SenderID)
+	}
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ? AND deleted_at = 0", filter.Types)
+	}
+	if filter.Since > 0 {
+		query = query.Where("timestamp >= ?", filter.Since)
+	}
+	if filter.BeforeSeq > 0 {
+		query = query.Where("seq < ?", filter.BeforeSeq).Order("seq DESC")
+	} else {
+		query = query.Where("seq > ?", filter.AfterSeq).Order("seq ASC")
+	}
+
+	var messages []*model.Message
+	err := query.Limit(filter.Limit).Find(&messages).Error
+	return messages, err
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestGetConversationMessages_Filters(t *testing.T) {
+	s, recorder := dryRunMySQLStore(t)
+	group := &model.Message{GroupID: "g1"}
+
+	_, err := s.GetConversationMessages(group, MessageFilter{AfterSeq: 5, Limit: 50})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM `messages` WHERE group_id = 'g1' AND seq > 5 ORDER BY seq ASC LIMIT 50", recorder.lastSQL())
+
+	// 按类型过滤时排除已删除的消息，before_seq倒序翻页
+	_, err = s.GetConversationMessages(group, MessageFilter{
+		SenderID:  "u1",
+		Types:     []model.MessageType{model.MessageTypeImage, model.MessageTypeVideo},
+		Since:     1700000000,
+		BeforeSeq: 100,
+		Limit:     20,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM `messages` WHERE group_id = 'g1' AND sender_id = 'u1' AND (type IN ('image','video') AND deleted_at = 0) AND timestamp >= 1700000000 AND seq < 100 ORDER BY seq DESC LIMIT 20", recorder.lastSQL())
+
+	// 私聊不区分方向
+	_, err = s.GetConversationMessages(&model.Message{SenderID: "u1", ReceiverID: "u2"}, MessageFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Contains(t, recorder.lastSQL(), "((sender_id = 'u1' AND receiver_id = 'u2') OR (sender_id = 'u2' AND receiver_id = 'u1'))")
+}
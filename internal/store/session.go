@@ -0,0 +1,50 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/model"
+)
+
+// userSessionsKey 用户已登录会话的记录，哈希字段为会话ID，值为会话记录的JSON
+func userSessionsKey(userID string) string {
+	return fmt.Sprintf("user:sessions:%s", userID)
+}
+
+// SaveSession 保存会话记录，每次保存刷新整个哈希的过期时间，节点异常退出时遗留的记录随之过期
+func (s *RedisStore) SaveSession(record *model.SessionRecord, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	key := userSessionsKey(record.UserID)
+	pipe := s.client.TxPipeline()
+	pipe.HSet(s.ctx, key, record.SessionID, data)
+	pipe.Expire(s.ctx, key, ttl)
+	_, err = pipe.Exec(s.ctx)
+	return err
+}
+
+// RemoveSession 删除会话记录
+func (s *RedisStore) RemoveSession(userID, sessionID string) error {
+	return s.client.HDel(s.ctx, userSessionsKey(userID), sessionID).Err()
+}
+
+// ListSessions 获取用户的全部会话记录，解析失败的记录被跳过
+func (s *RedisStore) ListSessions(userID string) ([]*model.SessionRecord, error) {
+	values, err := s.client.HGetAll(s.ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*model.SessionRecord, 0, len(values))
+	for _, value := range values {
+		var record model.SessionRecord
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			continue
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+func userSettingsKey(userID string) string {
+	return "settings:" + userID
+}
+
+// saveUserSettingsScript 版本与预期一致时保存设置并将版本加一，不一致时返回-1
+var saveUserSettingsScript = redis.NewScript(`
+local current = tonumber(redis.call("HGET", KEYS[1], "version") or "0")
+if current ~= tonumber(ARGV[1]) then
+	return -1
+end
+redis.call("HSET", KEYS[1], "version", current + 1, "data", ARGV[2])
+return current + 1
+`)
+
+// GetUserSettings 获取用户的偏好设置，未保存过时返回版本为0的空设置
+func (s *RedisStore) GetUserSettings(userID string) (*model.UserSettings, error) {
+	values, err := s.client.HGetAll(s.ctx, userSettingsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	settings := &model.UserSettings{}
+	if data, ok := values["data"]; ok {
+		if err := json.Unmarshal([]byte(data), settings); err != nil {
+			return nil, err
+		}
+	}
+	if settings.Values == nil {
+		settings.Values = make(map[string]json.RawMessage)
+	}
+	return settings, nil
+}
+
+// SaveUserSettings 版本仍为expected时保存设置，返回新版本和是否保存成功
+func (s *RedisStore) SaveUserSettings(userID string, settings *model.UserSettings, expected int64) (int64, bool, error) {
+	saved := *settings
+	saved.Version = expected + 1
+	data, err := json.Marshal(&saved)
+	if err != nil {
+		return 0, false, err
+	}
+	version, err := saveUserSettingsScript.Run(s.ctx, s.client, []string{userSettingsKey(userID)}, expected, data).Int64()
+	if err != nil {
+		return 0, false, err
+	}
+	return version, version >= 0, nil
+}
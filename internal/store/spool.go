@@ -0,0 +1,144 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/logger"
+)
+
+// ErrSpoolFull 暂存的事件数已达上限
+var ErrSpoolFull = errors.New("spool is full")
+
+// Spool 本地预写暂存区
+// 事件按写入顺序以递增序号为键保存在独立的LevelDB中，每次写入都同步落盘；重放按序号顺序进行，
+// 同一会话（分区键）的事件只要有一条重放失败，其后的事件本轮都不再重放，保证会话内有序
+type Spool struct {
+	db         *leveldb.DB
+	maxEntries int
+
+	mu      sync.Mutex
+	seq     uint64
+	pending map[string]int // 各会话暂存的事件数
+	size    int
+}
+
+// OpenSpool 打开暂存区并从已有事件恢复序号和各会话的暂存数，maxEntries不大于0时不限制
+func OpenSpool(path string, maxEntries int) (*Spool, error) {
+	db, err := leveldb.OpenFile(filepath.Clean(path), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+	s := &Spool{db: db, maxEntries: maxEntries, pending: make(map[string]int)}
+
+	iter := db.NewIterator(nil, nil)
+	for iter.Next() {
+		s.seq = binary.BigEndian.Uint64(iter.Key())
+		var entry model.SpoolEntry
+		if err := json.Unmarshal(iter.Value(), &entry); err != nil || entry.Message == nil {
+			continue
+		}
+		s.pending[PartitionKey(entry.Message)]++
+		s.size++
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read spool: %w", err)
+	}
+	metrics.SpoolEntries.Set(float64(s.size))
+	if s.size > 0 {
+		logger.Info("Spool restored", logger.Int("entries", s.size))
+	}
+	return s, nil
+}
+
+// Append 暂存事件，写入磁盘后返回
+func (s *Spool) Append(entry *model.SpoolEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxEntries > 0 && s.size >= s.maxEntries {
+		return ErrSpoolFull
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, s.seq+1)
+	if err := s.db.Put(key, data, &opt.WriteOptions{Sync: true}); err != nil {
+		return fmt.Errorf("failed to write spool: %w", err)
+	}
+	s.seq++
+	s.pending[PartitionKey(entry.Message)]++
+	s.size++
+	metrics.SpoolEntries.Set(float64(s.size))
+	return nil
+}
+
+// Pending 会话是否还有未重放的事件，有时新事件需继续暂存，不能越过已暂存的事件
+func (s *Spool) Pending(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending[key] > 0
+}
+
+// Len 暂存的事件数
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Replay 按写入顺序把事件交给handler，成功的事件从暂存区删除；返回本轮重放成功的事件数
+func (s *Spool) Replay(handler func(entry *model.SpoolEntry) error) (int, error) {
+	blocked := make(map[string]bool)
+	replayed := 0
+	iter := s.db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := append([]byte(nil), iter.Key()...)
+		var entry model.SpoolEntry
+		if err := json.Unmarshal(iter.Value(), &entry); err != nil || entry.Message == nil {
+			// 无法解析的事件无法重放，直接丢弃
+			logger.Error("Dropping corrupt spool entry", logger.Int64("seq", int64(binary.BigEndian.Uint64(key))))
+			s.db.Delete(key, nil)
+			continue
+		}
+		partition := PartitionKey(entry.Message)
+		if blocked[partition] {
+			continue
+		}
+		if err := handler(&entry); err != nil {
+			blocked[partition] = true
+			continue
+		}
+
+		if err := s.db.Delete(key, &opt.WriteOptions{Sync: true}); err != nil {
+			return replayed, fmt.Errorf("failed to delete spool entry: %w", err)
+		}
+		replayed++
+		s.mu.Lock()
+		if s.pending[partition]--; s.pending[partition] <= 0 {
+			delete(s.pending, partition)
+		}
+		s.size--
+		metrics.SpoolEntries.Set(float64(s.size))
+		s.mu.Unlock()
+	}
+	return replayed, iter.Error()
+}
+
+// Close 关闭暂存区
+func (s *Spool) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestSpool_ReplayPreservesConversationOrder(t *testing.T) {
+	path := t.TempDir()
+	spool, err := OpenSpool(path, 3)
+	assert.NoError(t, err)
+
+	ab := func(id string) *model.Message { return &model.Message{ID: id, SenderID: "a", ReceiverID: "b"} }
+	group := &model.Message{ID: "g1", SenderID: "a", GroupID: "g"}
+	for _, message := range []*model.Message{ab("m1"), group, ab("m2")} {
+		assert.NoError(t, spool.Append(&model.SpoolEntry{Kind: model.SpoolOfflineMessage, Message: message}))
+	}
+	assert.ErrorIs(t, spool.Append(&model.SpoolEntry{Kind: model.SpoolOfflineMessage, Message: ab("m3")}), ErrSpoolFull)
+	assert.True(t, spool.Pending(PartitionKey(ab("m1"))))
+
+	// m1失败后同一会话的m2本轮不重放，其他会话不受影响
+	var order []string
+	replayed, err := spool.Replay(func(entry *model.SpoolEntry) error {
+		order = append(order, entry.Message.ID)
+		if entry.Message.ID == "m1" {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, []string{"m1", "g1"}, order)
+	assert.False(t, spool.Pending(PartitionKey(group)))
+	assert.NoError(t, spool.Close())
+
+	// 重新打开后恢复暂存数，新事件排在已有事件之后
+	spool, err = OpenSpool(path, 3)
+	assert.NoError(t, err)
+	defer spool.Close()
+	assert.Equal(t, 2, spool.Len())
+	assert.NoError(t, spool.Append(&model.SpoolEntry{Kind: model.SpoolOfflineQueue, UserID: "b", Message: ab("m3")}))
+
+	order = nil
+	replayed, err = spool.Replay(func(entry *model.SpoolEntry) error {
+		order = append(order, entry.Message.ID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, replayed)
+	assert.Equal(t, []string{"m1", "m2", "m3"}, order)
+	assert.Zero(t, spool.Len())
+	assert.False(t, spool.Pending(PartitionKey(ab("m1"))))
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm/clause"
+)
+
+// StarMessage 收藏消息，重复收藏忽略
+func (s *MySQLStore) StarMessage(star *model.UserStarredMessage) error {
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(star).Error
+}
+
+// UnstarMessage 取消用户对消息的收藏
+func (s *MySQLStore) UnstarMessage(userID, messageID string) error {
+	return s.db.Where("user_id = ? AND message_id = ?", userID, messageID).Delete(&model.UserStarredMessage{}).Error
+}
+
+// DeleteMessageStars 删除所有用户对消息的收藏
+func (s *MySQLStore) DeleteMessageStars(messageID string) error {
+	return s.db.Where("message_id = ?", messageID).Delete(&model.UserStarredMessage{}).Error
+}
+
+// GetStarredMessages 按收藏时间倒序获取用户的收藏，beforeAt和beforeID为上一页最后一条，beforeAt为0时从最新的开始
+func (s *MySQLStore) GetStarredMessages(userID string, beforeAt int64, beforeID string, limit int) ([]*model.UserStarredMessage, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if beforeAt > 0 {
+		query = query.Where("starred_at < ? OR (starred_at = ? AND message_id < ?)", beforeAt, beforeAt, beforeID)
+	}
+
+	var stars []*model.UserStarredMessage
+	err := query.Order("starred_at DESC, message_id DESC").Limit(limit).Find(&stars).Error
+	return stars, err
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/user/im/internal/model"
+)
+
+// nodeStatsKey 节点上报的统计快照
+func nodeStatsKey(nodeID string) string {
+	return fmt.Sprintf("stats:node:%s", nodeID)
+}
+
+// SaveNodeStats 保存节点的统计快照，节点停止上报后快照随过期时间消失
+func (s *RedisStore) SaveNodeStats(stats *model.NodeStats, ttl time.Duration) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(s.ctx, nodeStatsKey(stats.NodeID), data, ttl).Err()
+}
+
+// GetNodeStats 批量获取节点的统计快照，没有快照的节点不在结果中
+func (s *RedisStore) GetNodeStats(nodeIDs []string) (map[string]*model.NodeStats, error) {
+	result := make(map[string]*model.NodeStats, len(nodeIDs))
+	if len(nodeIDs) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		keys[i] = nodeStatsKey(nodeID)
+	}
+	values, err := s.client.MGet(s.ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var stats model.NodeStats
+		if err := json.Unmarshal([]byte(data), &stats); err != nil {
+			continue
+		}
+		result[nodeIDs[i]] = &stats
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/user/im/internal/model"
+)
+
+// stickerPacksKey 管理接口维护的表情包，值为整个表情包的JSON
+const stickerPacksKey = "stickers:packs"
+
+// StickerPacksChannel 表情包变更通知频道
+const StickerPacksChannel = "stickers:packs:changed"
+
+// SetStickerPack 保存表情包，同ID的表情包整体替换
+func (s *RedisStore) SetStickerPack(pack *model.StickerPack) error {
+	data, err := json.Marshal(pack)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(s.ctx, stickerPacksKey, pack.ID, data).Err()
+}
+
+// DeleteStickerPack 删除表情包，返回是否存在
+func (s *RedisStore) DeleteStickerPack(id string) (bool, error) {
+	n, err := s.client.HDel(s.ctx, stickerPacksKey, id).Result()
+	return n > 0, err
+}
+
+// GetStickerPacks 获取全部表情包，跳过无法解析的记录
+func (s *RedisStore) GetStickerPacks() (map[string]*model.StickerPack, error) {
+	values, err := s.client.HGetAll(s.ctx, stickerPacksKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	packs := make(map[string]*model.StickerPack, len(values))
+	for id, value := range values {
+		var pack model.StickerPack
+		if err := json.Unmarshal([]byte(value), &pack); err != nil {
+			continue
+		}
+		pack.ID = id
+		packs[id] = &pack
+	}
+	return packs, nil
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+// messageTemplatesKey 管理接口维护的服务消息模板，值为模板的JSON
+const messageTemplatesKey = "templates:messages"
+
+// SetMessageTemplate 保存消息模板，同ID的模板整体替换
+func (s *RedisStore) SetMessageTemplate(template *model.MessageTemplate) error {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(s.ctx, messageTemplatesKey, template.ID, data).Err()
+}
+
+// GetMessageTemplate 获取消息模板，不存在时返回false
+func (s *RedisStore) GetMessageTemplate(id string) (*model.MessageTemplate, bool, error) {
+	value, err := s.client.HGet(s.ctx, messageTemplatesKey, id).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var template model.MessageTemplate
+	if err := json.Unmarshal([]byte(value), &template); err != nil {
+		return nil, false, err
+	}
+	template.ID = id
+	return &template, true, nil
+}
+
+// GetMessageTemplates 获取全部消息模板，跳过无法解析的记录
+func (s *RedisStore) GetMessageTemplates() (map[string]*model.MessageTemplate, error) {
+	values, err := s.client.HGetAll(s.ctx, messageTemplatesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	templates := make(map[string]*model.MessageTemplate, len(values))
+	for id, value := range values {
+		var template model.MessageTemplate
+		if err := json.Unmarshal([]byte(value), &template); err != nil {
+			continue
+		}
+		template.ID = id
+		templates[id] = &template
+	}
+	return templates, nil
+}
+
+// CountMessageTemplates 获取消息模板数
+func (s *RedisStore) CountMessageTemplates() (int64, error) {
+	return s.client.HLen(s.ctx, messageTemplatesKey).Result()
+}
+
+// DeleteMessageTemplate 删除消息模板，返回是否存在
+func (s *RedisStore) DeleteMessageTemplate(id string) (bool, error) {
+	n, err := s.client.HDel(s.ctx, messageTemplatesKey, id).Result()
+	return n > 0, err
+}
+
+// IncrTemplateUsage 累加模板在窗口内的发送次数，返回当前计数和窗口剩余时间
+func (s *RedisStore) IncrTemplateUsage(templateID string, window time.Duration) (int64, time.Duration, error) {
+	key := fmt.Sprintf("template:rate:%s", templateID)
+	n, err := incrWindowScript.Run(s.ctx, s.client, []string{key}, window.Milliseconds()).Int64()
+	if err != nil {
+		return 0, 0, err
+	}
+	ttl, err := s.client.PTTL(s.ctx, key).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, ttl, nil
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm"
+)
+
+// UpdateThreadSummary 增减根消息的话题回复数，lastReplyAt大于0时同时推进最近回复时间
+// 在数据库中原子更新，并发回复不会丢失计数
+func (s *MySQLStore) UpdateThreadSummary(messageID string, delta int64, lastReplyAt int64) error {
+	updates := map[string]interface{}{
+		"reply_count": gorm.Expr("GREATEST(reply_count + ?, 0)", delta),
+	}
+	if lastReplyAt > 0 {
+		updates["last_reply_at"] = gorm.Expr("GREATEST(last_reply_at, ?)", lastReplyAt)
+	}
+	return s.db.Model(&model.Message{}).Where("id = ?", messageID).Updates(updates).Error
+}
+
+// GetThreadSummaries 返回给定消息中有过话题回复的根消息的回复统计
+func (s *MySQLStore) GetThreadSummaries(messageIDs []string) (map[string]model.ThreadSummary, error) {
+	summaries := make(map[string]model.ThreadSummary)
+	if len(messageIDs) == 0 {
+		return summaries, nil
+	}
+
+	var rows []struct {
+		ID          string
+		ReplyCount  int64
+		LastReplyAt int64
+	}
+	err := s.db.Model(&model.Message{}).
+		Select("id", "reply_count", "last_reply_at").
+		Where("id IN ? AND last_reply_at > 0", messageIDs).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		summaries[row.ID] = model.ThreadSummary{ReplyCount: row.ReplyCount, LastReplyAt: row.LastReplyAt}
+	}
+	return summaries, nil
+}
+
+// UpdateThreadSummary 增减根消息的话题回复数，同时更新离线索引中的副本
+func (s *LevelDBStore) UpdateThreadSummary(messageID string, delta int64, lastReplyAt int64) error {
+	defer s.observe("update_thread_summary", time.Now())
+	return s.updateMessage(messageID, func(message *model.Message) {
+		message.ReplyCount += delta
+		if message.ReplyCount < 0 {
+			message.ReplyCount = 0
+		}
+		if lastReplyAt > message.LastReplyAt {
+			message.LastReplyAt = lastReplyAt
+		}
+	})
+}
+
+// GetThreadSummaries 返回给定消息中有过话题回复的根消息的回复统计
+func (s *LevelDBStore) GetThreadSummaries(messageIDs []string) (map[string]model.ThreadSummary, error) {
+	defer s.observe("get_thread_summaries", time.Now())
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	summaries := make(map[string]model.ThreadSummary)
+	for _, id := range messageIDs {
+		raw, err := s.db.Get([]byte(s.messageKey(id)), nil)
+		if errors.Is(err, leveldb.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var message model.Message
+		if err := json.Unmarshal(raw, &message); err != nil {
+			continue
+		}
+		if message.LastReplyAt > 0 {
+			summaries[id] = model.ThreadSummary{ReplyCount: message.ReplyCount, LastReplyAt: message.LastReplyAt}
+		}
+	}
+	return summaries, nil
+}
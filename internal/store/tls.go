@@ -0,0 +1,144 @@
+package store
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/user/im/internal/config"
+	"github.com/user/im/pkg/logger"
+)
+
+// newTLSConfig 根据配置创建客户端TLS配置，未启用时返回nil
+// 证书文件在每次握手时检查修改时间，轮换后下次建立连接即使用新证书，无需重启
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("tls cert_file and key_file must be set together")
+	}
+
+	r := &certReloader{cfg: cfg}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+		// 由VerifyConnection使用最新加载的CA校验服务端证书
+		InsecureSkipVerify: true,
+		VerifyConnection:   r.verifyConnection,
+	}
+	if cfg.CertFile != "" {
+		tlsConfig.GetClientCertificate = r.clientCertificate
+	}
+	return tlsConfig, nil
+}
+
+// certReloader 按文件修改时间重新加载CA和客户端证书
+type certReloader struct {
+	cfg config.TLSConfig
+
+	mu       sync.RWMutex
+	roots    *x509.CertPool
+	cert     *tls.Certificate
+	modTimes map[string]time.Time
+}
+
+// reload 加载证书文件，文件未变化时跳过
+func (r *certReloader) reload() error {
+	files := []string{r.cfg.CAFile, r.cfg.CertFile, r.cfg.KeyFile}
+	modTimes := make(map[string]time.Time, len(files))
+	changed := r.modTimes == nil
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("failed to stat tls file %s: %w", file, err)
+		}
+		modTimes[file] = info.ModTime()
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	var roots *x509.CertPool
+	if r.cfg.CAFile != "" {
+		pem, err := os.ReadFile(r.cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read tls ca file: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", r.cfg.CAFile)
+		}
+	}
+
+	var cert *tls.Certificate
+	if r.cfg.CertFile != "" {
+		loaded, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load tls key pair: %w", err)
+		}
+		cert = &loaded
+	}
+
+	r.mu.Lock()
+	r.roots, r.cert, r.modTimes = roots, cert, modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+// current 检查文件是否更新后返回当前的CA和客户端证书，重新加载失败时继续使用旧证书
+func (r *certReloader) current() (*x509.CertPool, *tls.Certificate) {
+	if err := r.reload(); err != nil {
+		logger.Warn("Failed to reload tls certificates", logger.ErrorField(err))
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.roots, r.cert
+}
+
+// clientCertificate 双向TLS时提供客户端证书
+func (r *certReloader) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	_, cert := r.current()
+	return cert, nil
+}
+
+// verifyConnection 校验服务端证书链和主机名，未配置CA时使用系统根证书
+func (r *certReloader) verifyConnection(state tls.ConnectionState) error {
+	if r.cfg.InsecureSkipVerify {
+		return nil
+	}
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	if state.ServerName == "" {
+		return errors.New("tls server_name is required to verify the server certificate")
+	}
+
+	roots, _ := r.current()
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       state.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := state.PeerCertificates[0].Verify(opts); err != nil {
+		return fmt.Errorf("failed to verify server certificate: %w", err)
+	}
+	return nil
+}
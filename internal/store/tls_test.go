@@ -0,0 +1,102 @@
+package store
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+)
+
+// newTestCert 生成证书，parent为nil时生成自签名CA
+func newTestCert(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, dnsName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	} else {
+		tmpl.DNSNames = []string{dnsName}
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert, key
+}
+
+func writeCertPEM(t *testing.T, path string, cert *x509.Certificate, modTime time.Time) {
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+	assert.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestTLSConfig_VerifiesAndReloadsCA(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+
+	ca, caKey := newTestCert(t, nil, nil, "test-ca")
+	server, _ := newTestCert(t, ca, caKey, "kafka.internal")
+	writeCertPEM(t, caFile, ca, time.Now().Add(-time.Minute))
+
+	tlsConfig, err := newTLSConfig(config.TLSConfig{Enabled: true, CAFile: caFile})
+	assert.NoError(t, err)
+
+	state := tls.ConnectionState{ServerName: "kafka.internal", PeerCertificates: []*x509.Certificate{server}}
+	assert.NoError(t, tlsConfig.VerifyConnection(state))
+
+	// 主机名不匹配
+	state.ServerName = "other.internal"
+	assert.Error(t, tlsConfig.VerifyConnection(state))
+	state.ServerName = "kafka.internal"
+
+	// 轮换为另一个CA后，旧CA签发的证书不再被信任
+	otherCA, _ := newTestCert(t, nil, nil, "other-ca")
+	writeCertPEM(t, caFile, otherCA, time.Now())
+	assert.Error(t, tlsConfig.VerifyConnection(state))
+}
+
+func TestTLSConfig_Disabled(t *testing.T) {
+	tlsConfig, err := newTLSConfig(config.TLSConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+
+	_, err = newTLSConfig(config.TLSConfig{Enabled: true, CertFile: "client.pem"})
+	assert.Error(t, err)
+}
+
+func TestNewSASLMechanism(t *testing.T) {
+	mechanism, err := newSASLMechanism(config.KafkaSASLConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, mechanism)
+
+	for name, want := range map[string]string{"plain": "PLAIN", "scram-sha-256": "SCRAM-SHA-256", "scram-sha-512": "SCRAM-SHA-512"} {
+		mechanism, err := newSASLMechanism(config.KafkaSASLConfig{Mechanism: name, Username: "u", Password: "p"})
+		assert.NoError(t, err)
+		assert.Equal(t, want, mechanism.Name())
+	}
+
+	_, err = newSASLMechanism(config.KafkaSASLConfig{Mechanism: "gssapi"})
+	assert.Error(t, err)
+}
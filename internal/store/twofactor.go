@@ -0,0 +1,61 @@
+package store
+
+import (
+	"github.com/user/im/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetTwoFactor 获取用户的两步验证设置，未绑定时返回gorm.ErrRecordNotFound
+func (s *MySQLStore) GetTwoFactor(userID string) (*model.TwoFactor, error) {
+	var tf model.TwoFactor
+	err := s.db.Where("user_id = ?", userID).First(&tf).Error
+	return &tf, err
+}
+
+// SaveTwoFactor 保存两步验证设置，已存在时覆盖
+func (s *MySQLStore) SaveTwoFactor(tf *model.TwoFactor) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "enabled", "recovery_codes", "last_step", "enabled_at", "updated_at"}),
+	}).Create(tf).Error
+}
+
+// DeleteTwoFactor 删除用户的两步验证设置
+func (s *MySQLStore) DeleteTwoFactor(userID string) error {
+	return s.db.Where("user_id = ?", userID).Delete(&model.TwoFactor{}).Error
+}
+
+// AdvanceTwoFactorStep 记录通过校验的时间步，时间步不大于已记录的值时返回false，防止动态码重放
+func (s *MySQLStore) AdvanceTwoFactorStep(userID string, step int64) (bool, error) {
+	result := s.db.Model(&model.TwoFactor{}).
+		Where("user_id = ? AND enabled = ? AND last_step < ?", userID, true, step).
+		Update("last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// UseRecoveryCode 删除一个恢复码，恢复码不存在时返回false
+func (s *MySQLStore) UseRecoveryCode(userID, hash string) (bool, error) {
+	used := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var tf model.TwoFactor
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND enabled = ?", userID, true).First(&tf).Error; err != nil {
+			return err
+		}
+
+		remaining := make([]string, 0, len(tf.RecoveryCodes))
+		for _, code := range tf.RecoveryCodes {
+			if !used && code == hash {
+				used = true
+				continue
+			}
+			remaining = append(remaining, code)
+		}
+		if !used {
+			return nil
+		}
+		tf.RecoveryCodes = remaining
+		return tx.Model(&tf).Select("recovery_codes").Updates(&tf).Error
+	})
+	return used, err
+}
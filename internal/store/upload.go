@@ -0,0 +1,79 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/im/internal/model"
+)
+
+// uploadExpiryKey 全部上传会话按过期时间排序，清理任务据此找到过期会话
+const uploadExpiryKey = "uploads:expiry"
+
+func uploadSessionKey(id string) string {
+	return "upload:" + id
+}
+
+func uploadLockKey(id string) string {
+	return "upload:" + id + ":lock"
+}
+
+// SaveUploadSession 保存上传会话并按过期时间登记，会话由清理任务删除，不设置TTL
+func (s *RedisStore) SaveUploadSession(session *model.UploadSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Set(s.ctx, uploadSessionKey(session.ID), data, 0)
+	pipe.ZAdd(s.ctx, uploadExpiryKey, redis.Z{Score: float64(session.ExpiresAt), Member: session.ID})
+	_, err = pipe.Exec(s.ctx)
+	return err
+}
+
+// GetUploadSession 获取上传会话，不存在时返回nil
+func (s *RedisStore) GetUploadSession(id string) (*model.UploadSession, error) {
+	data, err := s.client.Get(s.ctx, uploadSessionKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session model.UploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("invalid upload session %s: %w", id, err)
+	}
+	return &session, nil
+}
+
+// DeleteUploadSession 删除上传会话
+func (s *RedisStore) DeleteUploadSession(id string) error {
+	pipe := s.client.TxPipeline()
+	pipe.Del(s.ctx, uploadSessionKey(id))
+	pipe.ZRem(s.ctx, uploadExpiryKey, id)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// GetExpiredUploadSessions 获取过期时间不晚于before的上传会话ID
+func (s *RedisStore) GetExpiredUploadSessions(before int64, limit int) ([]string, error) {
+	return s.client.ZRangeByScore(s.ctx, uploadExpiryKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(before, 10),
+		Count: int64(limit),
+	}).Result()
+}
+
+// LockUploadSession 上传分片、完成和取消时独占会话，同一会话的并发请求只有一个能成功
+func (s *RedisStore) LockUploadSession(id, owner string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(s.ctx, uploadLockKey(id), owner, ttl).Result()
+}
+
+// UnlockUploadSession 释放会话锁，锁已过期被他人持有时不释放
+func (s *RedisStore) UnlockUploadSession(id, owner string) error {
+	return releaseLockScript.Run(s.ctx, s.client, []string{uploadLockKey(id)}, owner).Err()
+}
@@ -0,0 +1,4 @@
+package store
+
+// WordFilterChannel 敏感词库重新加载通知频道，词库文件需已同步到所有节点
+const WordFilterChannel = "word_filter:reload"
@@ -0,0 +1,128 @@
+package ahocorasick
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Match 匹配到的词在原文中的字节范围[Start, End)
+type Match struct {
+	Start int
+	End   int
+}
+
+// Matcher Aho-Corasick多模式匹配自动机，按字符匹配且不区分大小写，构建后只读，可并发使用
+type Matcher struct {
+	nodes    []node
+	patterns int
+}
+
+type node struct {
+	next map[rune]int32
+	fail int32
+	// longest 以该状态结尾的最长词的字符数，包括失败链上的词，0表示没有词在此结束
+	longest int
+}
+
+// New 构建自动机，空词被忽略，重复的词只计一次
+func New(patterns []string) *Matcher {
+	m := &Matcher{nodes: []node{{}}}
+	for _, p := range patterns {
+		m.add(p)
+	}
+	m.build()
+	return m
+}
+
+// add 把词加入字典树
+func (m *Matcher) add(pattern string) {
+	state := int32(0)
+	length := 0
+	for _, r := range pattern {
+		r = unicode.ToLower(r)
+		next, ok := m.nodes[state].next[r]
+		if !ok {
+			next = int32(len(m.nodes))
+			m.nodes = append(m.nodes, node{})
+			if m.nodes[state].next == nil {
+				m.nodes[state].next = make(map[rune]int32)
+			}
+			m.nodes[state].next[r] = next
+		}
+		state = next
+		length++
+	}
+	if length > 0 && m.nodes[state].longest == 0 {
+		m.nodes[state].longest = length
+		m.patterns++
+	}
+}
+
+// build 按广度优先计算失败指针，并沿失败链合并最长词长度
+func (m *Matcher) build() {
+	queue := make([]int32, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[state].next {
+			fail := m.nodes[state].fail
+			for {
+				if next, ok := m.nodes[fail].next[r]; ok {
+					m.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if inherited := m.nodes[m.nodes[child].fail].longest; inherited > m.nodes[child].longest {
+				m.nodes[child].longest = inherited
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+// Len 字典中的词数
+func (m *Matcher) Len() int {
+	return m.patterns
+}
+
+// FindAll 查找文本中出现的词，同一位置结束的多个词只返回最长的，结果按结束位置排序
+func (m *Matcher) FindAll(text string) []Match {
+	if m.patterns == 0 {
+		return nil
+	}
+	var matches []Match
+	// starts 最近匹配过的字符的起始字节位置，用于把字符数换算为字节范围
+	var starts []int
+	state := int32(0)
+	for i, r := range text {
+		starts = append(starts, i)
+		r = unicode.ToLower(r)
+		for {
+			if next, ok := m.nodes[state].next[r]; ok {
+				state = next
+				break
+			}
+			if state == 0 {
+				break
+			}
+			state = m.nodes[state].fail
+		}
+		if length := m.nodes[state].longest; length > 0 {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			matches = append(matches, Match{Start: starts[len(starts)-length], End: i + size})
+		}
+	}
+	return matches
+}
+
+// Contains 文本中是否出现任一词
+func (m *Matcher) Contains(text string) bool {
+	return len(m.FindAll(text)) > 0
+}
@@ -0,0 +1,31 @@
+package ahocorasick
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatcher_FindAll(t *testing.T) {
+	m := New([]string{"he", "she", "his", "hers", ""})
+	assert.Equal(t, 4, m.Len())
+	// 同一位置结束的she和he只返回较长的she
+	assert.Equal(t, []Match{{Start: 1, End: 4}, {Start: 2, End: 6}}, m.FindAll("ushers"))
+	assert.Nil(t, m.FindAll("nothing"))
+}
+
+func TestMatcher_UnicodeAndCase(t *testing.T) {
+	m := New([]string{"赌博", "Casino"})
+	text := "去CASINO还是赌博"
+	matches := m.FindAll(text)
+	assert.Len(t, matches, 2)
+	assert.Equal(t, "CASINO", text[matches[0].Start:matches[0].End])
+	assert.Equal(t, "赌博", text[matches[1].Start:matches[1].End])
+	assert.False(t, m.Contains("赌"))
+}
+
+func TestMatcher_Empty(t *testing.T) {
+	m := New(nil)
+	assert.Equal(t, 0, m.Len())
+	assert.False(t, m.Contains("anything"))
+}
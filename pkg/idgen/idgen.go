@@ -0,0 +1,84 @@
+package idgen
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ID生成策略
+const (
+	StrategySnowflake = "snowflake"
+	StrategyULID      = "ulid"
+	StrategyKSUID     = "ksuid"
+)
+
+// Generator ID生成器
+type Generator interface {
+	// NextID 生成唯一ID
+	NextID() (string, error)
+}
+
+// Func 把函数作为ID生成器，用于测试中注入确定的ID
+type Func func() (string, error)
+
+// NextID 调用函数生成ID
+func (f Func) NextID() (string, error) {
+	return f()
+}
+
+var (
+	mu        sync.Mutex
+	generator Generator
+)
+
+// New 按策略创建ID生成器，machineID只用于snowflake
+// snowflake生成十进制数字ID；ulid和ksuid生成定长字符串，按字典序排列即按生成时间排列，同一进程内严格递增
+func New(strategy string, machineID uint16) (Generator, error) {
+	switch strategy {
+	case StrategySnowflake:
+		return newSnowflake(machineID), nil
+	case StrategyULID:
+		return newULID(), nil
+	case StrategyKSUID:
+		return newKSUID(), nil
+	default:
+		return nil, fmt.Errorf("unknown id strategy: %s", strategy)
+	}
+}
+
+// Valid 策略是否可用
+func Valid(strategy string) bool {
+	switch strategy {
+	case StrategySnowflake, StrategyULID, StrategyKSUID:
+		return true
+	}
+	return false
+}
+
+// Init 初始化全局ID生成器，需在生成ID前调用
+func Init(strategy string, machineID uint16) error {
+	g, err := New(strategy, machineID)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	generator = g
+	mu.Unlock()
+	return nil
+}
+
+// Default 返回委托给全局生成器的生成器，Init在其后调用时同样生效
+func Default() Generator {
+	return Func(GenerateIDString)
+}
+
+// GenerateIDString 使用全局生成器生成ID，未初始化时使用机器ID为1的snowflake
+func GenerateIDString() (string, error) {
+	mu.Lock()
+	if generator == nil {
+		generator = newSnowflake(1)
+	}
+	g := generator
+	mu.Unlock()
+	return g.NextID()
+}
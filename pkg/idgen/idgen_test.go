@@ -0,0 +1,124 @@
+package idgen
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUnknownStrategy(t *testing.T) {
+	_, err := New("uuid", 1)
+	assert.Error(t, err)
+	assert.False(t, Valid("uuid"))
+}
+
+func TestDefaultFollowsInit(t *testing.T) {
+	g := Default()
+	assert.NoError(t, Init(StrategyKSUID, 1))
+	defer Init(StrategySnowflake, 1)
+
+	id, err := g.NextID()
+	assert.NoError(t, err)
+	assert.Len(t, id, 27)
+}
+
+func TestFunc(t *testing.T) {
+	n := 0
+	var g Generator = Func(func() (string, error) {
+		n++
+		return fmt.Sprintf("id-%d", n), nil
+	})
+	first, _ := g.NextID()
+	second, _ := g.NextID()
+	assert.Equal(t, []string{"id-1", "id-2"}, []string{first, second})
+}
+
+func TestNoCollisions(t *testing.T) {
+	for _, strategy := range []string{StrategySnowflake, StrategyULID, StrategyKSUID} {
+		t.Run(strategy, func(t *testing.T) {
+			g, err := New(strategy, 1)
+			assert.NoError(t, err)
+
+			const workers, perWorker = 8, 2000
+			ids := make(chan string, workers*perWorker)
+			var wg sync.WaitGroup
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < perWorker; j++ {
+						id, err := g.NextID()
+						assert.NoError(t, err)
+						ids <- id
+					}
+				}()
+			}
+			wg.Wait()
+			close(ids)
+
+			seen := make(map[string]bool, workers*perWorker)
+			for id := range ids {
+				assert.False(t, seen[id], "duplicate id %s", id)
+				seen[id] = true
+			}
+			assert.Len(t, seen, workers*perWorker)
+		})
+	}
+}
+
+func TestLexicographicOrder(t *testing.T) {
+	for _, strategy := range []string{StrategyULID, StrategyKSUID} {
+		t.Run(strategy, func(t *testing.T) {
+			g, err := New(strategy, 1)
+			assert.NoError(t, err)
+
+			ids := make([]string, 0, 5000)
+			for i := 0; i < cap(ids); i++ {
+				id, err := g.NextID()
+				assert.NoError(t, err)
+				ids = append(ids, id)
+			}
+			assert.True(t, sort.StringsAreSorted(ids))
+			for i := 1; i < len(ids); i++ {
+				assert.NotEqual(t, ids[i-1], ids[i])
+			}
+		})
+	}
+}
+
+func TestOrderAcrossGenerators(t *testing.T) {
+	// 不同节点的生成器之间按时间排序，ULID精确到毫秒，KSUID精确到秒
+	cases := map[string]time.Duration{StrategyULID: 2 * time.Millisecond, StrategyKSUID: 1100 * time.Millisecond}
+	for strategy, gap := range cases {
+		t.Run(strategy, func(t *testing.T) {
+			first, _ := New(strategy, 1)
+			second, _ := New(strategy, 2)
+			earlier, err := first.NextID()
+			assert.NoError(t, err)
+			time.Sleep(gap)
+			later, err := second.NextID()
+			assert.NoError(t, err)
+			assert.Less(t, earlier, later)
+		})
+	}
+}
+
+func TestEncodingLength(t *testing.T) {
+	ulid, _ := newULID().NextID()
+	assert.Len(t, ulid, 26)
+	ksuid, _ := newKSUID().NextID()
+	assert.Len(t, ksuid, 27)
+
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+	assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", encodeULID(max))
+	assert.Equal(t, "000000000000000000000000000", encodeBase62(make([]byte, 20)))
+	assert.Equal(t, "00000000000000000000000000z", encodeBase62([]byte{61}))
+	assert.Equal(t, "000000000000000000000000010", encodeBase62([]byte{62}))
+}
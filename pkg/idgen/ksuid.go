@@ -0,0 +1,80 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ksuidEpoch KSUID的起始时间（Unix秒）
+const ksuidEpoch = 1400000000
+
+// base62 KSUID使用的字母表，按ASCII顺序排列，编码后的字典序与数值顺序一致
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// ksuidLength 20字节的KSUID编码后的长度
+const ksuidLength = 27
+
+// ksuidGenerator KSUID生成器：32位秒级时间戳 + 128位随机数，编码为27个字符
+// 同一秒内在上一个ID的随机部分上加一，保证同一进程生成的ID严格递增
+type ksuidGenerator struct {
+	mu      sync.Mutex
+	lastSec uint32
+	payload [16]byte
+}
+
+// newKSUID 创建KSUID生成器
+func newKSUID() *ksuidGenerator {
+	return &ksuidGenerator{}
+}
+
+// NextID 生成KSUID
+func (g *ksuidGenerator) NextID() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sec := uint32(time.Now().Unix() - ksuidEpoch)
+	if sec <= g.lastSec {
+		sec = g.lastSec
+		if !increment(g.payload[:]) {
+			return "", errors.New("ksuid payload exhausted within second")
+		}
+	} else {
+		if _, err := rand.Read(g.payload[:]); err != nil {
+			return "", err
+		}
+		g.lastSec = sec
+	}
+
+	raw := make([]byte, 20)
+	raw[0], raw[1], raw[2], raw[3] = byte(sec>>24), byte(sec>>16), byte(sec>>8), byte(sec)
+	copy(raw[4:], g.payload[:])
+	return encodeBase62(raw), nil
+}
+
+// encodeBase62 把大端字节序的数编码为定长的base62字符串，不足时左侧补0
+func encodeBase62(raw []byte) string {
+	out := make([]byte, ksuidLength)
+	for i := range out {
+		out[i] = base62[0]
+	}
+	pos := len(out) - 1
+	for len(raw) > 0 {
+		// 长除法：raw /= 62，余数为最低位
+		quotient := make([]byte, 0, len(raw))
+		var remainder uint32
+		for _, b := range raw {
+			acc := remainder<<8 | uint32(b)
+			digit := acc / 62
+			remainder = acc % 62
+			if len(quotient) > 0 || digit > 0 {
+				quotient = append(quotient, byte(digit))
+			}
+		}
+		out[pos] = base62[remainder]
+		pos--
+		raw = quotient
+	}
+	return string(out)
+}
@@ -0,0 +1,37 @@
+package idgen
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/sony/sonyflake"
+)
+
+// snowflakeEpoch Sonyflake的起始时间
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// snowflakeGenerator 基于Sonyflake的ID生成器：39位时间戳（10毫秒）+ 8位序列号 + 16位机器ID，部署多个节点时机器ID不能重复
+type snowflakeGenerator struct {
+	sf *sonyflake.Sonyflake
+}
+
+// newSnowflake 创建Snowflake生成器
+func newSnowflake(machineID uint16) *snowflakeGenerator {
+	return &snowflakeGenerator{
+		sf: sonyflake.NewSonyflake(sonyflake.Settings{
+			StartTime: snowflakeEpoch,
+			MachineID: func() (uint16, error) {
+				return machineID, nil
+			},
+		}),
+	}
+}
+
+// NextID 生成十进制字符串格式的ID
+func (g *snowflakeGenerator) NextID() (string, error) {
+	id, err := g.sf.NextID()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(id, 10), nil
+}
@@ -0,0 +1,83 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+)
+
+// crockford ULID使用的Crockford Base32字母表
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidGenerator ULID生成器：48位毫秒时间戳 + 80位随机数，编码为26个字符
+// 同一毫秒内在上一个ID的随机部分上加一，保证同一进程生成的ID严格递增
+type ulidGenerator struct {
+	mu      sync.Mutex
+	lastMs  uint64
+	entropy [10]byte
+}
+
+// newULID 创建ULID生成器
+func newULID() *ulidGenerator {
+	return &ulidGenerator{}
+}
+
+// NextID 生成ULID
+func (g *ulidGenerator) NextID() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms <= g.lastMs {
+		// 时钟回拨时沿用上一个时间戳，保持递增
+		ms = g.lastMs
+		if !increment(g.entropy[:]) {
+			return "", errors.New("ulid entropy exhausted within millisecond")
+		}
+	} else {
+		if _, err := rand.Read(g.entropy[:]); err != nil {
+			return "", err
+		}
+		g.lastMs = ms
+	}
+
+	var raw [16]byte
+	for i := 0; i < 6; i++ {
+		raw[i] = byte(ms >> (40 - 8*i))
+	}
+	copy(raw[6:], g.entropy[:])
+	return encodeULID(raw), nil
+}
+
+// encodeULID 把128位按每5位一个字符编码，首个字符只有高位的3位
+func encodeULID(raw [16]byte) string {
+	var out [26]byte
+	// 128位补足到130位，从最低位开始每次取5位
+	var acc uint16
+	bits := 0
+	pos := len(out) - 1
+	for i := len(raw) - 1; i >= 0; i-- {
+		acc |= uint16(raw[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			out[pos] = crockford[acc&0x1f]
+			pos--
+			acc >>= 5
+			bits -= 5
+		}
+	}
+	out[pos] = crockford[acc&0x1f]
+	return string(out[:])
+}
+
+// increment 把大端字节序的数加一，溢出时返回false
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
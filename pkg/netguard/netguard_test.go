@@ -0,0 +1,17 @@
+package netguard
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControl(t *testing.T) {
+	assert.Error(t, Control("tcp", "127.0.0.1:80", nil))
+	assert.Error(t, Control("tcp", "100.64.1.1:443", nil))
+	assert.Error(t, Control("tcp", "[fd00::1]:443", nil))
+	assert.Error(t, Control("tcp", "93.184.216.34:8080", nil))
+	assert.NoError(t, Control("tcp", "93.184.216.34:443", nil))
+	assert.True(t, IsPublicIP(net.ParseIP("2606:2800:220:1:248:1893:25c8:1946")))
+}
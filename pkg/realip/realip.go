@@ -0,0 +1,130 @@
+package realip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Resolver 客户端IP解析器，只有来自可信代理的X-Forwarded-For才被采信
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// New 按可信代理的CIDR或IP创建解析器，不带掩码的单个IP只匹配该地址；为空时只使用对端地址
+func New(proxies []string) (*Resolver, error) {
+	trusted := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy: %s", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %s", proxy)
+		}
+		trusted = append(trusted, network)
+	}
+	return &Resolver{trusted: trusted}, nil
+}
+
+// Trusted 地址是否属于可信代理
+func (r *Resolver) Trusted(ip net.IP) bool {
+	if r == nil {
+		return false
+	}
+	for _, network := range r.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 客户端IP，对端是可信代理时按X-Forwarded-For从右向左取第一个不可信的地址
+// 代理链上全部是可信代理时取最左侧的地址；不可信的对端携带的请求头可以伪造，直接使用对端地址
+func (r *Resolver) ClientIP(req *http.Request) string {
+	peer := PeerIP(req)
+	ip := net.ParseIP(peer)
+	if ip == nil || !r.Trusted(ip) {
+		return peer
+	}
+
+	var hops []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hopIP := net.ParseIP(hops[i])
+		if hopIP == nil {
+			// 无法解析的地址之前的部分不可信
+			break
+		}
+		client = hopIP.String()
+		if !r.Trusted(hopIP) {
+			break
+		}
+	}
+	return client
+}
+
+// Scheme 客户端请求使用的协议，http或https；对端是可信代理时采信X-Forwarded-Proto中最靠近客户端的值
+func (r *Resolver) Scheme(req *http.Request) string {
+	if ip := net.ParseIP(PeerIP(req)); ip != nil && r.Trusted(ip) {
+		proto, _, _ := strings.Cut(req.Header.Get("X-Forwarded-Proto"), ",")
+		switch proto = strings.ToLower(strings.TrimSpace(proto)); proto {
+		case "http", "https":
+			return proto
+		}
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// PeerIP 连接的对端IP
+func PeerIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// contextKey 请求上下文中保存客户端IP的键
+type contextKey struct{}
+
+// NewContext 在请求上下文中保存已解析的客户端IP
+func NewContext(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, contextKey{}, ip)
+}
+
+// FromContext 读取中间件已解析的客户端IP
+func FromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(contextKey{}).(string)
+	return ip, ok && ip != ""
+}
+
+// FromRequest 客户端IP，优先使用中间件已解析的结果，否则按r解析
+func (r *Resolver) FromRequest(req *http.Request) string {
+	if ip, ok := FromContext(req.Context()); ok {
+		return ip
+	}
+	return r.ClientIP(req)
+}
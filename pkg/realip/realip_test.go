@@ -0,0 +1,71 @@
+package realip
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := New([]string{"10.0.0.0/8", "192.168.1.1"})
+	assert.NoError(t, err)
+
+	request := func(remoteAddr string, forwarded ...string) string {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		r.RemoteAddr = remoteAddr
+		for _, f := range forwarded {
+			r.Header.Add("X-Forwarded-For", f)
+		}
+		return resolver.ClientIP(r)
+	}
+
+	// 不可信的对端伪造的请求头被忽略
+	assert.Equal(t, "203.0.113.9", request("203.0.113.9:5000", "1.2.3.4"))
+	// 从右向左跳过可信代理
+	assert.Equal(t, "198.51.100.7", request("10.0.0.2:5000", "1.2.3.4, 198.51.100.7, 10.1.2.3"))
+	assert.Equal(t, "198.51.100.7", request("192.168.1.1:5000", "1.2.3.4", "198.51.100.7"))
+	// 全部是可信代理时取最左侧的地址
+	assert.Equal(t, "10.9.9.9", request("10.0.0.2:5000", "10.9.9.9, 10.1.2.3"))
+	// 无法解析的地址之前的部分不可信
+	assert.Equal(t, "198.51.100.7", request("10.0.0.2:5000", "1.2.3.4, unknown, 198.51.100.7"))
+	// 可信代理没有透传地址时使用对端地址
+	assert.Equal(t, "10.0.0.2", request("10.0.0.2:5000"))
+	assert.Equal(t, "192.168.1.2", request("192.168.1.2:5000", "1.2.3.4"))
+
+	// 没有可信代理时只使用对端地址
+	none, err := New(nil)
+	assert.NoError(t, err)
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.RemoteAddr = "10.0.0.2:5000"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+	assert.Equal(t, "10.0.0.2", none.ClientIP(r))
+
+	_, err = New([]string{"not-a-cidr"})
+	assert.EqualError(t, err, "invalid trusted proxy: not-a-cidr")
+}
+
+func TestScheme(t *testing.T) {
+	resolver, err := New([]string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+
+	request := func(remoteAddr, proto string) string {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		r.RemoteAddr = remoteAddr
+		if proto != "" {
+			r.Header.Set("X-Forwarded-Proto", proto)
+		}
+		return resolver.Scheme(r)
+	}
+
+	// 可信代理终结TLS后转发的请求
+	assert.Equal(t, "https", request("10.0.0.2:5000", "https"))
+	assert.Equal(t, "https", request("10.0.0.2:5000", "HTTPS, http"))
+	assert.Equal(t, "http", request("10.0.0.2:5000", "http"))
+	// 不可信的对端和无法识别的值被忽略
+	assert.Equal(t, "http", request("203.0.113.9:5000", "https"))
+	assert.Equal(t, "http", request("10.0.0.2:5000", "gopher"))
+
+	r := httptest.NewRequest("GET", "https://im.example.com/ws", nil)
+	assert.Equal(t, "https", resolver.Scheme(r))
+}
@@ -0,0 +1,469 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config S3连接配置，Endpoint为空时使用AWS按区域的地址
+type Config struct {
+	Endpoint     string
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Timeout      time.Duration // 单次请求包括读取响应体的总超时，为0时不限制
+	// ResponseHeaderTimeout 等待响应头的超时，用于读取大文件等无法限制总时长的场景
+	ResponseHeaderTimeout time.Duration
+}
+
+// StatusError 对象存储返回的非2xx响应
+type StatusError struct {
+	Op         string
+	Bucket     string
+	Key        string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+// Error 实现error接口
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to %s s3://%s/%s: %s: %s", e.Op, e.Bucket, e.Key, e.Status, e.Body)
+}
+
+// Client 最小化的S3对象存储客户端，支持单次上传下载和分段上传
+// 设置Endpoint时使用兼容S3的对象存储（如MinIO），统一使用路径风格的地址
+type Client struct {
+	endpoint     string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	httpClient   *http.Client
+}
+
+// Part 分段上传中已上传的分段
+type Part struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// New 创建S3客户端
+func New(cfg Config) (*Client, error) {
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("s3 access key and secret key are required")
+	}
+	c := &Client{
+		endpoint:     cfg.Endpoint,
+		region:       cfg.Region,
+		accessKey:    cfg.AccessKey,
+		secretKey:    cfg.SecretKey,
+		sessionToken: cfg.SessionToken,
+		httpClient:   &http.Client{Timeout: cfg.Timeout},
+	}
+	if cfg.ResponseHeaderTimeout > 0 {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+		c.httpClient.Transport = transport
+	}
+	if c.region == "" {
+		c.region = "us-east-1"
+	}
+	if c.endpoint == "" {
+		c.endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", c.region)
+	}
+	c.endpoint = strings.TrimRight(c.endpoint, "/")
+	return c, nil
+}
+
+// NewFromEnv 从环境变量创建S3客户端
+// 凭证读取 AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY、AWS_SESSION_TOKEN 和 AWS_REGION，
+// 设置 S3_ENDPOINT 时使用兼容S3的对象存储
+func NewFromEnv() (*Client, error) {
+	client, err := New(Config{
+		Endpoint:     os.Getenv("S3_ENDPOINT"),
+		Region:       os.Getenv("AWS_REGION"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Timeout:      30 * time.Minute,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for s3 targets")
+	}
+	return client, nil
+}
+
+// ObjectURL 对象的路径风格地址
+func (c *Client) ObjectURL(bucket, key string) string {
+	return c.endpoint + awsEscapePath("/"+bucket+"/"+key)
+}
+
+// UploadFile 上传本地文件
+func (c *Client) UploadFile(bucket, key, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(http.MethodPut, bucket, key, nil, nil, file)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+
+	resp, err := c.do(req, "upload", bucket, key)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// DownloadFile 下载对象到本地文件
+func (c *Client) DownloadFile(bucket, key, path string) error {
+	req, err := c.newRequest(http.MethodGet, bucket, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req, "download", bucket, key)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to download s3://%s/%s: %w", bucket, key, err)
+	}
+	return file.Close()
+}
+
+// GetObject 读取对象，rangeHeader不为空时按HTTP Range读取部分内容，调用方负责关闭响应体
+func (c *Client) GetObject(bucket, key, rangeHeader string) (*http.Response, error) {
+	req, err := c.newRequest(http.MethodGet, bucket, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	return c.do(req, "get", bucket, key)
+}
+
+// PutObject 上传内存中的对象，metadata为x-amz-meta-*元数据
+func (c *Client) PutObject(bucket, key, contentType string, metadata map[string]string, data []byte) error {
+	headers := make(map[string]string, len(metadata))
+	for name, value := range metadata {
+		headers["x-amz-meta-"+strings.ToLower(name)] = value
+	}
+	req, err := c.newRequest(http.MethodPut, bucket, key, nil, headers, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.do(req, "put", bucket, key)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// CopyObject 在同一存储桶内复制对象，metadata替换为新的x-amz-meta-*元数据；单次复制的对象不超过5GB
+func (c *Client) CopyObject(bucket, srcKey, dstKey, contentType string, metadata map[string]string) error {
+	headers := map[string]string{
+		"x-amz-copy-source":        awsEscapePath("/" + bucket + "/" + srcKey),
+		"x-amz-metadata-directive": "REPLACE",
+	}
+	for name, value := range metadata {
+		headers["x-amz-meta-"+strings.ToLower(name)] = value
+	}
+	req, err := c.newRequest(http.MethodPut, bucket, dstKey, nil, headers, nil)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.do(req, "copy", bucket, dstKey)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 复制失败时S3可能返回200和错误内容
+	result, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if bytes.Contains(result, []byte("<Error>")) {
+		return fmt.Errorf("failed to copy s3://%s/%s: %s", bucket, srcKey, result)
+	}
+	return nil
+}
+
+// DeleteObject 删除对象，对象不存在时也返回成功
+func (c *Client) DeleteObject(bucket, key string) error {
+	req, err := c.newRequest(http.MethodDelete, bucket, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req, "delete", bucket, key)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// CreateMultipartUpload 开始分段上传，返回分段上传ID
+func (c *Client) CreateMultipartUpload(bucket, key, contentType string) (string, error) {
+	req, err := c.newRequest(http.MethodPost, bucket, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.do(req, "create multipart upload", bucket, key)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("failed to create multipart upload s3://%s/%s: invalid response", bucket, key)
+	}
+	return result.UploadID, nil
+}
+
+// UploadPart 上传一个分段，返回分段的ETag；同一分段号重复上传时覆盖之前的内容
+func (c *Client) UploadPart(bucket, key, uploadID string, number int, data []byte) (string, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	req, err := c.newRequest(http.MethodPut, bucket, key, query, nil, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(len(data))
+
+	resp, err := c.do(req, "upload part", bucket, key)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("failed to upload part %d of s3://%s/%s: missing etag", number, bucket, key)
+	}
+	return etag, nil
+}
+
+// CompleteMultipartUpload 按分段号顺序合并所有分段为一个对象
+func (c *Client) CompleteMultipartUpload(bucket, key, uploadID string, parts []Part) error {
+	type completePart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	body := struct {
+		XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+		Parts   []completePart `xml:"Part"`
+	}{}
+	sorted := append([]Part(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Number < sorted[j].Number })
+	for _, p := range sorted {
+		body.Parts = append(body.Parts, completePart{PartNumber: p.Number, ETag: p.ETag})
+	}
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}}, nil, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+
+	resp, err := c.do(req, "complete multipart upload", bucket, key)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 合并失败时S3可能返回200和错误内容
+	result, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if bytes.Contains(result, []byte("<Error>")) {
+		return fmt.Errorf("failed to complete multipart upload s3://%s/%s: %s", bucket, key, result)
+	}
+	return nil
+}
+
+// AbortMultipartUpload 取消分段上传并删除已上传的分段
+func (c *Client) AbortMultipartUpload(bucket, key, uploadID string) error {
+	req, err := c.newRequest(http.MethodDelete, bucket, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req, "abort multipart upload", bucket, key)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do 发送请求，非2xx响应转换为错误
+func (c *Client) do(req *http.Request, op, bucket, key string) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s s3://%s/%s: %w", op, bucket, key, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, &StatusError{Op: op, Bucket: bucket, Key: key, StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
+	}
+	return resp, nil
+}
+
+// newRequest 创建带SigV4签名的请求，请求体不参与签名；headers中的x-amz-*头一并签名
+func (c *Client) newRequest(method, bucket, key string, query url.Values, headers map[string]string, body io.Reader) (*http.Request, error) {
+	path := "/" + bucket + "/" + key
+	req, err := http.NewRequest(method, c.endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawPath = awsEscapePath(path)
+	canonicalQuery := awsCanonicalQuery(query)
+	req.URL.RawQuery = canonicalQuery
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := "UNSIGNED-PAYLOAD"
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", c.sessionToken)
+	}
+
+	names := []string{"host"}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		value := req.URL.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		}
+		canonicalHeaders.WriteString(name + ":" + value + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		req.URL.RawPath,
+		canonicalQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	signingKey = hmacSHA256(signingKey, c.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+	return req, nil
+}
+
+// awsCanonicalQuery 按SigV4规则排序并编码查询参数，没有值的参数保留为 key=
+func awsCanonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for k, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, awsEscape(k, false)+"="+awsEscape(v, false))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscapePath 按SigV4规则编码路径，保留'/'和非保留字符
+func awsEscapePath(path string) string {
+	return awsEscape(path, true)
+}
+
+// awsEscape 按SigV4规则编码，只保留非保留字符，keepSlash时同时保留'/'
+func awsEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if (keepSlash && ch == '/') || ch == '-' || ch == '_' || ch == '.' || ch == '~' ||
+			('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z') || ('0' <= ch && ch <= '9') {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,18 @@
+package s3
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAWSEscapePath(t *testing.T) {
+	assert.Equal(t, "/bucket/a%20b/c%2Bd~e.gz", awsEscapePath("/bucket/a b/c+d~e.gz"))
+}
+
+func TestAWSCanonicalQuery(t *testing.T) {
+	assert.Equal(t, "uploads=", awsCanonicalQuery(url.Values{"uploads": {""}}))
+	assert.Equal(t, "partNumber=2&uploadId=a%2Fb%3D", awsCanonicalQuery(url.Values{"uploadId": {"a/b="}, "partNumber": {"2"}}))
+	assert.Equal(t, "", awsCanonicalQuery(nil))
+}
@@ -0,0 +1,566 @@
+package server
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/cluster"
+	"github.com/user/im/internal/lifecycle"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// adminActorKey 上下文中由IM令牌确定的操作人
+const adminActorKey = "admin_actor"
+
+// adminRolesKey 上下文中操作人生效的管理角色
+const adminRolesKey = "admin_roles"
+
+// adminAuth 管理接口认证中间件，支持静态令牌（X-Admin-Token，视为superadmin）或带管理角色的IM令牌
+// IM令牌中的角色与通过管理接口分配的角色合并生效，各接口的权限由adminPermissions检查
+func adminAuth(token string, tokens *service.TokenService, adminRoles *service.AdminRoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 带管理角色的IM令牌，操作人取令牌中的用户
+		if auth := c.GetHeader("Authorization"); tokens != nil && strings.HasPrefix(auth, "Bearer ") {
+			claims, err := tokens.Parse(strings.TrimPrefix(auth, "Bearer "))
+			if err != nil {
+				c.AbortWithStatusJSON(401, gin.H{"error": "Invalid token"})
+				return
+			}
+			roles, err := adminRoles.EffectiveRoles(claims.Subject, claims.Roles)
+			if err != nil {
+				c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			if len(roles) == 0 {
+				c.AbortWithStatusJSON(403, gin.H{"error": "Admin role required"})
+				return
+			}
+			c.Set(adminActorKey, claims.Subject)
+			c.Set(adminRolesKey, roles)
+			c.Next()
+			return
+		}
+
+		if token == "" {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Admin API disabled"})
+			return
+		}
+
+		provided := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid admin token"})
+			return
+		}
+
+		c.Set(adminRolesKey, []string{model.RoleSuperAdmin})
+		c.Next()
+	}
+}
+
+// adminPermissions 返回按权限拦截管理接口的中间件，拒绝的请求记录审计
+func adminPermissions(auditService *service.AuditService) func(model.AdminPermission) gin.HandlerFunc {
+	return func(permission model.AdminPermission) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if model.AdminRolesAllow(adminRoles(c), permission) {
+				c.Next()
+				return
+			}
+			actor := c.GetString(adminActorKey)
+			if actor == "" {
+				actor = c.GetHeader("X-Admin-Actor")
+			}
+			recordAudit(c, auditService, actor, model.AuditActionPermissionDenied, c.FullPath(), map[string]string{
+				"method":     c.Request.Method,
+				"path":       c.Request.URL.Path,
+				"permission": string(permission),
+			})
+			c.AbortWithStatusJSON(403, gin.H{"error": "Permission denied", "permission": permission})
+		}
+	}
+}
+
+// adminRoles 读取操作人生效的管理角色
+func adminRoles(c *gin.Context) []string {
+	roles, _ := c.Get(adminRolesKey)
+	list, _ := roles.([]string)
+	return list
+}
+
+func handleListNodes(registry cluster.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nodes := registry.Nodes()
+		c.JSON(200, gin.H{
+			"nodes": nodes,
+			"count": len(nodes),
+		})
+	}
+}
+
+func handleGetDrain(drainer *cluster.Drainer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, drainer.Status())
+	}
+}
+
+func handleStartDrain(srv *Server, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		started := srv.Drain()
+		if started {
+			recordAudit(c, auditService, actor, model.AuditActionDrainNode, srv.cfg.Cluster.NodeID, nil)
+		}
+		c.JSON(200, gin.H{"started": started, "drain": srv.drainer.Status()})
+	}
+}
+
+func handleListComponents(components *lifecycle.Lifecycle) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"components": components.Status(),
+			"healthy":    components.Healthy(),
+		})
+	}
+}
+
+func handleListJobs(jobs *cluster.Coordinator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"leader":    jobs.Elector().Leader(),
+			"is_leader": jobs.Elector().IsLeader(),
+			"jobs":      jobs.Jobs(),
+		})
+	}
+}
+
+func handleGetSanctions(moderationService *service.ModerationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sanctions, err := moderationService.GetSanctions(c.Param("userID"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"sanctions": sanctions})
+	}
+}
+
+func handleCreateSanction(moderationService *service.ModerationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Type     model.SanctionType `json:"type" binding:"required"`
+			Duration int64              `json:"duration"` // 处罚时长（秒），0表示永久
+			Reason   string             `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		sanction, err := moderationService.Sanction(c.Param("userID"), req.Type, time.Duration(req.Duration)*time.Second, req.Reason)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"sanction": sanction})
+	}
+}
+
+func handleLiftSanction(moderationService *service.ModerationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := moderationService.Lift(c.Param("userID"), model.SanctionType(c.Param("type"))); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// handleDisconnectUser 强制断开用户在所有节点上的连接，不影响令牌，客户端可重新登录
+func handleDisconnectUser(moderationService *service.ModerationService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		userID := c.Param("userID")
+		online, err := moderationService.Disconnect(userID, req.Reason)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionDisconnectUser, userID, map[string]string{
+			"reason": req.Reason,
+			"online": strconv.FormatBool(online),
+		})
+
+		c.JSON(200, gin.H{"success": true, "online": online})
+	}
+}
+
+func handlePurgeMessage(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := messageService.PurgeMessage(c.Param("messageID")); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// adminActor 读取执行操作的管理人员标识，审计记录需要它；使用IM令牌时为令牌中的用户，不读取X-Admin-Actor
+func adminActor(c *gin.Context) (string, bool) {
+	if actor := c.GetString(adminActorKey); actor != "" {
+		return actor, true
+	}
+	actor := c.GetHeader("X-Admin-Actor")
+	if actor == "" {
+		c.JSON(400, gin.H{"error": "X-Admin-Actor header is required"})
+		return "", false
+	}
+	return actor, true
+}
+
+// recordAudit 记录管理操作及操作人的角色，持久化失败时只记录日志，不影响已完成的操作
+func recordAudit(c *gin.Context, auditService *service.AuditService, actor, action, target string, details map[string]string) {
+	if err := auditService.RecordAs(actor, strings.Join(adminRoles(c), ","), action, target, details); err != nil {
+		logger.Error("Failed to record audit log", logger.String("action", action), logger.String("target", target), logger.ErrorField(err))
+	}
+}
+
+func handleInspectOfflineQueue(messageService *service.MessageService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		limit, err := queryInt(c, "limit", 100, 1, 1000)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID := c.Param("userID")
+		report, err := messageService.InspectOfflineQueue(userID, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionInspectOfflineQueue, userID, map[string]string{
+			"limit": strconv.Itoa(limit),
+		})
+
+		c.JSON(200, gin.H{"queue": report})
+	}
+}
+
+func handleRedeliverMessage(messageService *service.MessageService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+
+		userID, messageID := c.Param("userID"), c.Param("messageID")
+		delivered, err := messageService.RedeliverMessage(userID, messageID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionRedeliverMessage, userID, map[string]string{
+			"message_id": messageID,
+			"delivered":  strconv.FormatBool(delivered),
+		})
+
+		c.JSON(200, gin.H{"success": true, "delivered": delivered})
+	}
+}
+
+func handleClearOfflineQueue(messageService *service.MessageService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+
+		userID := c.Param("userID")
+		cleared, err := messageService.ClearOfflineQueue(userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionClearOfflineQueue, userID, map[string]string{
+			"cleared": strconv.FormatInt(cleared, 10),
+		})
+
+		c.JSON(200, gin.H{"success": true, "cleared": cleared})
+	}
+}
+
+func handleListAuditLogs(auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := queryInt(c, "limit", 50, 1, 500)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		entries, err := auditService.List(store.AuditFilter{
+			Actor:  c.Query("actor"),
+			Action: c.Query("action"),
+			Target: c.Query("target"),
+			Role:   c.Query("role"),
+			Limit:  limit,
+		})
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"audit_logs": entries})
+	}
+}
+
+func handleGetAnalytics(analytics *service.AnalyticsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupLimit, err := queryInt(c, "groups", 20, 1, 200)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		report, err := analytics.Report(c.Query("from"), c.Query("to"), groupLimit)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"analytics": report})
+	}
+}
+
+func handleGetDeliverySLO(latency *service.LatencyTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := latency.Report(c.Query("from"), c.Query("to"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"delivery": report})
+	}
+}
+
+func handleGetClientCapabilities(clientService *service.ClientService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("userID")
+		caps, ok, err := clientService.Get(userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if !ok {
+			c.JSON(404, gin.H{"error": "No client capabilities recorded"})
+			return
+		}
+		c.JSON(200, gin.H{"user_id": userID, "capabilities": caps})
+	}
+}
+
+func handleListSessions(sessions *service.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("userID")
+		records, err := sessions.List(userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"user_id": userID, "sessions": records})
+	}
+}
+
+func handleGetUserBandwidth(bandwidth *service.BandwidthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		usage, err := bandwidth.Get(c.Param("userID"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, usage)
+	}
+}
+
+func handleGetClientConfig(clientConfig *service.ClientConfigService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"config": clientConfig.Current()})
+	}
+}
+
+func handleSetClientFeature(clientConfig *service.ClientConfigService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Enabled == nil {
+			c.JSON(400, gin.H{"error": "enabled is required"})
+			return
+		}
+
+		name := c.Param("name")
+		if err := clientConfig.SetFeature(name, *req.Enabled); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionSetClientFeature, name, map[string]string{
+			"enabled": strconv.FormatBool(*req.Enabled),
+		})
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleResetClientFeature(clientConfig *service.ClientConfigService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+
+		name := c.Param("name")
+		if err := clientConfig.ResetFeature(name); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionResetClientFeature, name, nil)
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleListFeatureFlags(flags *service.FeatureFlagService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"flags": flags.List()})
+	}
+}
+
+func handleEvaluateFeatureFlag(flags *service.FeatureFlagService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		userID := c.Param("userID")
+		c.JSON(200, gin.H{
+			"name":    name,
+			"user_id": userID,
+			"enabled": flags.Enabled(name, userID, false),
+		})
+	}
+}
+
+func handleSetFeatureFlag(flags *service.FeatureFlagService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Enabled    bool     `json:"enabled"`
+			Percentage int      `json:"percentage"`
+			Allowlist  []string `json:"allowlist"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		flag := &model.FeatureFlag{
+			Name:       c.Param("name"),
+			Enabled:    req.Enabled,
+			Percentage: req.Percentage,
+			Allowlist:  req.Allowlist,
+		}
+		if err := flags.SetFlag(flag); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionSetFeatureFlag, flag.Name, map[string]string{
+			"enabled":    strconv.FormatBool(flag.Enabled),
+			"percentage": strconv.Itoa(flag.Percentage),
+			"allowlist":  strconv.Itoa(len(flag.Allowlist)),
+		})
+
+		c.JSON(200, gin.H{"success": true, "flag": flag})
+	}
+}
+
+func handleResetFeatureFlag(flags *service.FeatureFlagService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+
+		name := c.Param("name")
+		if err := flags.ResetFlag(name); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionResetFeatureFlag, name, nil)
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleGetWordFilter(words *service.WordFilter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"status": words.Status()})
+	}
+}
+
+func handleReloadWordFilter(words *service.WordFilter, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+
+		status, err := words.Reload()
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		total := 0
+		for _, pack := range status.Packs {
+			total += pack.Words
+		}
+		recordAudit(c, auditService, actor, model.AuditActionReloadWordFilter, "", map[string]string{
+			"packs": strconv.Itoa(len(status.Packs)),
+			"words": strconv.Itoa(total),
+		})
+
+		c.JSON(200, gin.H{"status": status})
+	}
+}
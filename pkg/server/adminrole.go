@@ -0,0 +1,90 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+// handleGetAdminSelf 返回当前操作人生效的管理角色和权限，管理后台据此显示可用的功能
+func handleGetAdminSelf() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles := adminRoles(c)
+		permissions := make([]model.AdminPermission, 0, len(model.AllAdminPermissions))
+		for _, permission := range model.AllAdminPermissions {
+			if model.AdminRolesAllow(roles, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+		c.JSON(200, gin.H{
+			"actor":       c.GetString(adminActorKey),
+			"roles":       roles,
+			"permissions": permissions,
+		})
+	}
+}
+
+func handleListAdminRoles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"roles": model.AdminRolePermissions})
+	}
+}
+
+func handleListAdmins(adminRoles *service.AdminRoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assignments, err := adminRoles.List()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"admins": assignments})
+	}
+}
+
+func handleAssignAdminRoles(adminRoles *service.AdminRoleService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Roles []string `json:"roles" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID := c.Param("userID")
+		assignment, err := adminRoles.Assign(userID, req.Roles, actor)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionAssignAdminRole, userID, map[string]string{
+			"roles": strings.Join(assignment.Roles, ","),
+		})
+
+		c.JSON(200, gin.H{"admin": assignment})
+	}
+}
+
+func handleRevokeAdminRoles(adminRoles *service.AdminRoleService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+
+		userID := c.Param("userID")
+		if err := adminRoles.Revoke(userID); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionRevokeAdminRole, userID, nil)
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
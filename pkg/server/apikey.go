@@ -0,0 +1,134 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+// apiKeyAuth 服务间调用认证，密钥通过 Authorization: Bearer <key> 或 X-API-Key 传递
+func apiKeyAuth(apiKeyService *service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.GetHeader("X-API-Key")
+		if auth := c.GetHeader("Authorization"); raw == "" && strings.HasPrefix(auth, "Bearer ") {
+			raw = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if raw == "" {
+			c.AbortWithStatusJSON(401, gin.H{"error": "API key required"})
+			return
+		}
+
+		key, err := apiKeyService.Authenticate(raw)
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid API key"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set("api_key", key)
+		c.Next()
+	}
+}
+
+func handleServiceSendMessage(apiKeyService *service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.MustGet("api_key").(*model.APIKey)
+
+		var req struct {
+			ConversationID string `json:"conversation_id" binding:"required"`
+			Type           string `json:"type"`
+			Content        string `json:"content" binding:"required"`
+			Priority       string `json:"priority"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Type == "" {
+			req.Type = string(model.MessageTypeText)
+		}
+
+		priority, err := model.ParseMessagePriority(req.Priority)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		message, err := apiKeyService.SendMessage(key, req.ConversationID, model.MessageType(req.Type), req.Content, priority)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"message": message,
+		})
+	}
+}
+
+func handleListAPIKeys(apiKeyService *service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := apiKeyService.ListKeys()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"api_keys": keys,
+			"count":    len(keys),
+		})
+	}
+}
+
+func handleCreateAPIKey(apiKeyService *service.APIKeyService, twoFactor *service.TwoFactorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name          string   `json:"name"`
+			SenderID      string   `json:"sender_id" binding:"required"`
+			Conversations []string `json:"conversations" binding:"required"`
+			RateLimit     int      `json:"rate_limit"`
+			TwoFactorCode string   `json:"two_factor_code"` // 服务账号开启了两步验证时必填
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 密钥以服务账号的身份发送消息，签发前校验该账号的两步验证
+		if err := twoFactor.Authorize(req.SenderID, req.TwoFactorCode); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		key, raw, err := apiKeyService.CreateKey(req.Name, req.SenderID, req.Conversations, req.RateLimit)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		// 明文密钥只在创建时返回
+		c.JSON(200, gin.H{
+			"api_key": key,
+			"key":     raw,
+		})
+	}
+}
+
+func handleRevokeAPIKey(apiKeyService *service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := apiKeyService.RevokeKey(c.Param("keyID")); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+func handleGetOIDCConfig(auth *service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config, err := auth.OIDCConfig()
+		if err != nil {
+			c.JSON(502, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, config)
+	}
+}
+
+func handleOIDCLogin(auth *service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.OIDCLoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		token, err := auth.LoginOIDC(&req)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, token)
+	}
+}
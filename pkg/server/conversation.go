@@ -0,0 +1,235 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+	"github.com/user/im/internal/store"
+)
+
+func handleListConversations(conversationService *service.ConversationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		offset, err := queryInt(c, "offset", 0, 0, 1000000)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		limit, err := queryInt(c, "limit", 50, 1, 200)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter := service.ConversationFilter{
+			Label:  c.Query("label"),
+			Offset: offset,
+			Limit:  limit,
+		}
+		if filter.Archived, err = queryBool(c, "archived"); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if filter.Pinned, err = queryBool(c, "pinned"); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		conversations, total, err := conversationService.ListConversations(userID, filter)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"conversations": conversations,
+			"total":         total,
+			"has_more":      offset+len(conversations) < total,
+		})
+	}
+}
+
+func handleUpdateConversationSettings(conversationService *service.ConversationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			Archived *bool     `json:"archived"`
+			Pinned   *bool     `json:"pinned"`
+			Labels   *[]string `json:"labels"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		settings, err := conversationService.UpdateSettings(userID, c.Param("conversationID"), service.ConversationSettingsUpdate{
+			Archived: req.Archived,
+			Pinned:   req.Pinned,
+			Labels:   req.Labels,
+		})
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"settings": settings})
+	}
+}
+
+func handleGetDraft(draftService *service.DraftService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		draft, err := draftService.GetDraft(userID, c.Param("conversationID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"draft": draft})
+	}
+}
+
+func handleSaveDraft(draftService *service.DraftService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			Content string `json:"content"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		draft, err := draftService.SaveDraft(userID, c.Param("conversationID"), req.Content)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"draft": draft})
+	}
+}
+
+func handleListConversationMessages(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		filter := store.MessageFilter{SenderID: c.Query("sender")}
+		var err error
+		if filter.Limit, err = queryInt(c, "limit", 50, 1, 200); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		afterSeq, err := queryInt(c, "after_seq", 0, 0, math.MaxInt)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		beforeSeq, err := queryInt(c, "before_seq", 0, 0, math.MaxInt)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		filter.AfterSeq, filter.BeforeSeq = int64(afterSeq), int64(beforeSeq)
+		if filter.Types, err = model.ParseMessageTypes(c.Query("types")); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		// date按tz指定的时区（默认UTC）取当天零点，跳转到当天及之后的第一条消息
+		if date := c.Query("date"); date != "" {
+			loc := time.UTC
+			if tz := c.Query("tz"); tz != "" {
+				if loc, err = time.LoadLocation(tz); err != nil {
+					c.JSON(400, gin.H{"error": "invalid tz: " + tz})
+					return
+				}
+			}
+			day, err := time.ParseInLocation("2006-01-02", date, loc)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "date must be in YYYY-MM-DD format"})
+				return
+			}
+			filter.Since = day.Unix()
+		}
+
+		messages, cursor, hasMore, err := messageService.ListConversationMessages(userID, c.Param("conversationID"), filter)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"messages": messages,
+			"next_seq": cursor,
+			"has_more": hasMore,
+		})
+	}
+}
+
+func handleListConversationMedia(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		limit, err := queryInt(c, "limit", 50, 1, 200)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		var beforeSeq int64
+		if cursor := c.Query("cursor"); cursor != "" {
+			if beforeSeq, err = strconv.ParseInt(cursor, 10, 64); err != nil || beforeSeq <= 0 {
+				c.JSON(400, gin.H{"error": "invalid cursor"})
+				return
+			}
+		}
+
+		items, next, hasMore, err := messageService.ListConversationMedia(userID, c.Param("conversationID"), model.MessageType(c.Query("type")), beforeSeq, limit)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		nextCursor := ""
+		if hasMore {
+			nextCursor = strconv.FormatInt(next, 10)
+		}
+		c.JSON(200, gin.H{
+			"media":       items,
+			"next_cursor": nextCursor,
+			"has_more":    hasMore,
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/federation"
+	"github.com/user/im/internal/model"
+)
+
+// maxFederationEventSize 单个联邦事件请求体的最大字节数
+const maxFederationEventSize = 1 << 20
+
+func handleFederationDiscovery(fed *federation.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, fed.Discovery())
+	}
+}
+
+// handleFederationInbox 接收远程域投递的事件，4xx表示拒绝，对方不再重试；5xx时对方退避后重投
+func handleFederationInbox(fed *federation.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFederationEventSize)
+		var signed model.SignedFederationEvent
+		if err := c.ShouldBindJSON(&signed); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid federation event"})
+			return
+		}
+
+		err := fed.Receive(c.Request.Context(), &signed)
+		switch {
+		case err == nil:
+			c.JSON(202, gin.H{"status": "accepted"})
+		case errors.Is(err, federation.ErrInvalidSignature), errors.Is(err, federation.ErrUnknownDomain):
+			c.JSON(403, gin.H{"error": err.Error()})
+		case errors.Is(err, federation.ErrMalformedEvent), errors.Is(err, federation.ErrMisdirected), errors.Is(err, federation.ErrStaleEvent):
+			c.JSON(400, gin.H{"error": err.Error()})
+		default:
+			respondServiceError(c, err)
+		}
+	}
+}
+
+func handleListFederationQueues(fed *federation.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		queues, err := fed.Queues()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"domain": fed.Domain(), "queues": queues})
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/graphql"
+	"github.com/user/im/internal/service"
+)
+
+// maxGraphQLRequestSize GraphQL请求体的最大字节数
+const maxGraphQLRequestSize = 64 << 10
+
+// handleGraphQL 执行GraphQL查询，查询本身的错误在响应的errors中返回，HTTP状态码为200
+// 请求体大小在此限制，嵌套深度、字段数和执行时解析的字段总数按graphql配置限制
+func handleGraphQL(graphQLService *service.GraphQLService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxGraphQLRequestSize)
+		var req graphql.Request
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Query == "" {
+			c.JSON(400, gin.H{"error": "query is required"})
+			return
+		}
+
+		c.JSON(200, graphQLService.Execute(userID, req))
+	}
+}
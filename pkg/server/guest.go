@@ -0,0 +1,230 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+)
+
+// guestAuth 访客令牌认证，令牌通过 Authorization: Bearer <token> 或 token 查询参数传递（EventSource不能设置请求头）
+func guestAuth(guests *service.GuestService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.Query("token")
+		if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			raw = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if raw == "" {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Guest token required"})
+			return
+		}
+
+		token, err := guests.Authenticate(raw)
+		if errors.Is(err, service.ErrInvalidGuestToken) {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid guest token"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set("guest_token", token)
+		c.Next()
+	}
+}
+
+func handleGuestToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.MustGet("guest_token").(*model.GuestToken)
+		c.JSON(200, gin.H{"token": token})
+	}
+}
+
+func handleGuestMessages(guests *service.GuestService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.MustGet("guest_token").(*model.GuestToken)
+
+		var filter store.MessageFilter
+		var err error
+		if filter.Limit, err = queryInt(c, "limit", 50, 1, 200); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		afterSeq, err := queryInt(c, "after_seq", 0, 0, math.MaxInt)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		beforeSeq, err := queryInt(c, "before_seq", 0, 0, math.MaxInt)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		filter.AfterSeq, filter.BeforeSeq = int64(afterSeq), int64(beforeSeq)
+
+		messages, cursor, hasMore, err := guests.ListMessages(token, filter)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"messages": messages,
+			"next_seq": cursor,
+			"has_more": hasMore,
+		})
+	}
+}
+
+// handleGuestEvents 以SSE推送群组的新消息，事件ID为消息序号，断线重连时通过Last-Event-ID续传
+// 令牌吊销或过期时发送revoked事件后断开
+func handleGuestEvents(guests *service.GuestService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.MustGet("guest_token").(*model.GuestToken)
+
+		var afterSeq int64
+		if raw := c.GetHeader("Last-Event-ID"); raw != "" {
+			seq, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || seq < 0 {
+				c.JSON(400, gin.H{"error": "invalid Last-Event-ID"})
+				return
+			}
+			afterSeq = seq
+		} else if c.Query("after_seq") != "" {
+			seq, err := queryInt(c, "after_seq", 0, 0, math.MaxInt)
+			if err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			afterSeq = int64(seq)
+		} else {
+			seq, err := guests.LatestSeq(token)
+			if err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			afterSeq = seq
+		}
+
+		// 长连接不受服务器写超时限制
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			logger.Warn("Failed to clear write deadline for guest events", logger.ErrorField(err))
+		}
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(200)
+
+		ticker := time.NewTicker(guests.PollInterval())
+		defer ticker.Stop()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-ticker.C:
+			}
+
+			messages, cursor, err := guests.Poll(token, afterSeq)
+			if errors.Is(err, service.ErrInvalidGuestToken) {
+				fmt.Fprint(w, "event: revoked\ndata: {}\n\n")
+				return false
+			}
+			if err != nil {
+				logger.Warn("Failed to poll guest messages", logger.String("token_id", token.ID), logger.ErrorField(err))
+				return true
+			}
+			for _, message := range messages {
+				data, err := json.Marshal(message)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "id: %d\nevent: message\ndata: %s\n\n", message.Seq, data)
+			}
+			if len(messages) == 0 {
+				// 注释行作为心跳，避免代理断开空闲连接
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			afterSeq = cursor
+			return true
+		})
+	}
+}
+
+func handleCreateGuestToken(guests *service.GuestService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			TTL  int64  `json:"ttl"` // 有效期（秒），0表示使用默认有效期
+			Note string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		groupID := c.Param("groupID")
+		token, raw, err := guests.CreateToken(groupID, actor, req.Note, time.Duration(req.TTL)*time.Second)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionCreateGuestToken, groupID, map[string]string{
+			"token_id":   token.ID,
+			"expires_at": strconv.FormatInt(token.ExpiresAt, 10),
+			"note":       token.Note,
+		})
+
+		// 明文令牌只在签发时返回
+		c.JSON(200, gin.H{
+			"guest_token": token,
+			"token":       raw,
+		})
+	}
+}
+
+func handleListGuestTokens(guests *service.GuestService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokens, err := guests.ListTokens(c.Param("groupID"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"guest_tokens": tokens})
+	}
+}
+
+func handleRevokeGuestToken(guests *service.GuestService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+
+		token, err := guests.RevokeToken(c.Param("tokenID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionRevokeGuestToken, token.GroupID, map[string]string{
+			"token_id": token.ID,
+		})
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
@@ -0,0 +1,823 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/cluster"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/lifecycle"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/logger"
+	"github.com/user/im/pkg/websocket"
+)
+
+// HTTP处理器函数
+func handleReadiness(canary *service.Canary, components *lifecycle.Lifecycle, drainer *cluster.Drainer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 排空中的节点不再接收新连接，负载均衡据此摘除
+		if drainer != nil && drainer.Draining() {
+			c.JSON(503, gin.H{"status": "draining", "drain": drainer.Status()})
+			return
+		}
+		if !canary.Healthy() || !components.Healthy() {
+			c.JSON(503, gin.H{"status": "not_ready", "canary": canary.Status(), "components": components.Status()})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ready", "canary": canary.Status(), "components": components.Status()})
+	}
+}
+
+func handleSendMessage(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			ReceiverID string `json:"receiver_id"`
+			GroupID    string `json:"group_id"`
+			Type       string `json:"type"`
+			Content    string `json:"content"`
+			Priority   string `json:"priority"`
+			ThreadID   string `json:"thread_id"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 从请求中获取发送者ID（实际应用中应该从认证中获取）
+		senderID := c.GetHeader("X-User-ID")
+		if senderID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		priority, err := service.ParseUserPriority(req.Priority)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		var message *model.Message
+		if req.ThreadID != "" {
+			// 回复话题，会话由根消息决定
+			message, err = messageService.SendThreadReply(senderID, req.ThreadID, model.MessageType(req.Type), req.Content, priority)
+		} else if req.GroupID != "" {
+			// 发送群聊消息
+			message, err = messageService.SendGroupMessage(senderID, req.GroupID, model.MessageType(req.Type), req.Content, priority)
+		} else {
+			// 发送私聊消息
+			message, err = messageService.SendPrivateMessage(senderID, req.ReceiverID, model.MessageType(req.Type), req.Content, priority)
+		}
+
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success":    true,
+			"message":    message,
+			"message_id": message.ID,
+		})
+	}
+}
+
+func handleGetMessage(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		messageID := c.Param("messageID")
+
+		message, err := messageService.GetMessage(c.GetHeader("X-User-ID"), messageID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "Message not found"})
+			return
+		}
+
+		c.JSON(200, gin.H{"message": message})
+	}
+}
+
+func handleDeleteMessage(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		scope := model.DeleteScope(c.DefaultQuery("scope", string(model.DeleteScopeMe)))
+		if err := messageService.DeleteMessage(userID, c.Param("messageID"), scope); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleStarMessage(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		if err := messageService.StarMessage(userID, c.Param("messageID")); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleUnstarMessage(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		if err := messageService.UnstarMessage(userID, c.Param("messageID")); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleListStarredMessages(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		limit, err := queryInt(c, "limit", 50, 1, 200)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		starred, nextCursor, hasMore, err := messageService.ListStarredMessages(userID, c.Query("cursor"), limit)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"starred":     starred,
+			"next_cursor": nextCursor,
+			"has_more":    hasMore,
+		})
+	}
+}
+
+func handleAckMessage(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		messageID := c.Param("messageID")
+
+		var req struct {
+			Status string `json:"status"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := messageService.AcknowledgeMessage(userID, messageID, model.MessageStatus(req.Status))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleGetReceipts(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		report, err := messageService.GetReceipts(userID, c.Param("messageID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, report)
+	}
+}
+
+func handleGetPoll(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		poll, err := messageService.GetPoll(userID, c.Param("messageID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"poll": poll})
+	}
+}
+
+func handleSyncOfflineMessages(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		limit, err := queryInt(c, "limit", 50, 1, 200)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		types, err := model.ParseMessageTypes(c.Query("types"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		// last_message_id为旧版客户端使用的游标参数
+		cursor := c.Query("cursor")
+		if cursor == "" {
+			cursor = c.Query("last_message_id")
+		}
+
+		messages, nextCursor, hasMore, err := messageService.SyncOfflineMessages(userID, cursor, limit, types)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"messages":    messages,
+			"next_cursor": nextCursor,
+			"has_more":    hasMore,
+		})
+	}
+}
+
+func handleCreateGroup(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Name        string          `json:"name"`
+			Description string          `json:"description"`
+			Members     []string        `json:"members"`
+			Mode        model.GroupMode `json:"mode"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		ownerID := c.GetHeader("X-User-ID")
+		if ownerID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		group, err := messageService.CreateGroup(req.Name, req.Description, ownerID, req.Members, req.Mode)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"group": group})
+	}
+}
+
+func handleGetGroup(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupID")
+
+		group, err := messageService.GetGroup(groupID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "Group not found"})
+			return
+		}
+
+		c.JSON(200, gin.H{"group": group})
+	}
+}
+
+func handleGetGroupMembers(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupID")
+
+		offset, err := queryInt(c, "offset", 0, 0, math.MaxInt32)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		limit, err := queryInt(c, "limit", 100, 1, 1000)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter := store.MemberFilter{
+			Cursor:   c.Query("cursor"),
+			Offset:   offset,
+			Limit:    limit,
+			Role:     c.Query("role"),
+			Nickname: c.Query("nickname"),
+		}
+		if filter.Muted, err = queryBool(c, "muted"); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		members, nextCursor, err := messageService.ListGroupMembers(groupID, filter)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		total, err := messageService.GetMemberCount(groupID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"members":     members,
+			"total":       total,
+			"next_cursor": nextCursor,
+			"has_more":    nextCursor != "",
+		})
+	}
+}
+
+func handleSetMemberNickname(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupID")
+		userID := c.GetHeader("X-User-ID")
+
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			Nickname string `json:"nickname"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := messageService.SetMemberNickname(groupID, userID, req.Nickname); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleMuteMember(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupID")
+		operatorID := c.GetHeader("X-User-ID")
+
+		if operatorID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			Duration int64 `json:"duration"` // 禁言时长（秒），0表示解除禁言
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Duration < 0 {
+			c.JSON(400, gin.H{"error": "duration must be a non-negative number of seconds"})
+			return
+		}
+
+		err := messageService.MuteMember(groupID, operatorID, c.Param("userID"), time.Duration(req.Duration)*time.Second)
+		if err != nil {
+			c.JSON(403, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleGetGroupSettings(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settings, err := messageService.GetGroupSettings(c.Param("groupID"))
+		if err != nil {
+			c.JSON(404, gin.H{"error": "Group not found"})
+			return
+		}
+
+		c.JSON(200, gin.H{"settings": settings})
+	}
+}
+
+func handleUpdateGroupSettings(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupID")
+		operatorID := c.GetHeader("X-User-ID")
+
+		if operatorID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var settings model.GroupSettings
+		if err := c.ShouldBindJSON(&settings); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := messageService.UpdateGroupSettings(groupID, operatorID, settings); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleUpgradeGroup(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupID")
+		userID := c.GetHeader("X-User-ID")
+
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		group, err := messageService.UpgradeToChannel(groupID, userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"group": group})
+	}
+}
+
+func handleSyncChannelMessages(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupID")
+		userID := c.GetHeader("X-User-ID")
+
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		limit, err := queryInt(c, "limit", 50, 1, 200)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		messages, cursor, hasMore, err := messageService.SyncChannelMessages(groupID, userID, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"messages": messages,
+			"cursor":   cursor,
+			"has_more": hasMore,
+		})
+	}
+}
+
+func handleMarkChannelRead(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupID")
+		userID := c.GetHeader("X-User-ID")
+
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			MessageID string `json:"message_id"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := messageService.MarkChannelRead(groupID, userID, req.MessageID); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleJoinGroup(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupID")
+		userID := c.GetHeader("X-User-ID")
+
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		err := messageService.JoinGroup(groupID, userID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleLeaveGroup(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID := c.Param("groupID")
+		userID := c.GetHeader("X-User-ID")
+
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		err := messageService.LeaveGroup(groupID, userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleGetPresence(presenceService *service.PresenceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDs := strings.Split(c.Query("user_ids"), ",")
+		if c.Query("user_ids") == "" {
+			c.JSON(400, gin.H{"error": "user_ids required"})
+			return
+		}
+
+		c.JSON(200, gin.H{"presence": presenceService.GetPresence(userIDs)})
+	}
+}
+
+func handleSubscribePresence(presenceService *service.PresenceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req model.PresenceSubscribeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		presence, err := presenceService.Subscribe(userID, req.UserIDs)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"presence": presence})
+	}
+}
+
+func handleUnsubscribePresence(presenceService *service.PresenceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req model.PresenceSubscribeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := presenceService.Unsubscribe(userID, req.UserIDs); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// queryInt 解析整数查询参数，缺省时返回默认值
+// respondServiceError 按业务错误码返回HTTP错误，非业务错误视为内部错误
+func respondServiceError(c *gin.Context, err error) {
+	var svcErr *service.ServiceError
+	if !errors.As(err, &svcErr) {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := 403
+	switch svcErr.Code {
+	case service.ErrCodeInvalidRequest:
+		status = 400
+	case service.ErrCodeUnauthenticated:
+		status = 401
+	case service.ErrCodeNotFound:
+		status = 404
+	case service.ErrCodeConflict:
+		status = 409
+	case service.ErrCodeChecksumMismatch:
+		// 与tus协议一致，客户端重传该分片
+		status = 460
+	case service.ErrCodeFileInfected:
+		status = 422
+	case service.ErrCodeSlowMode, service.ErrCodeSpamThrottled, service.ErrCodeRateLimited:
+		status = 429
+		c.Header("Retry-After", strconv.FormatInt(svcErr.RetryAfter, 10))
+	}
+
+	body := gin.H{"error": svcErr.Message, "code": svcErr.Code}
+	if svcErr.RetryAfter > 0 {
+		body["retry_after"] = svcErr.RetryAfter
+	}
+	c.JSON(status, body)
+}
+
+// requeueFrames 把未能在确认窗口内下发或未确认的私聊消息放回离线队列
+// 群聊消息不入离线队列，客户端按会话序号通过sync_gap补齐
+func requeueFrames(redisStore *store.RedisStore, userID string, frames [][]byte) {
+	for _, data := range frames {
+		var frame struct {
+			Data model.Message `json:"data"`
+		}
+		if err := json.Unmarshal(data, &frame); err != nil {
+			logger.Warn("Failed to decode flow control frame", logger.String("user_id", userID), logger.ErrorField(err))
+			continue
+		}
+		message := &frame.Data
+		if !message.IsPrivateMessage() || message.ReceiverID != userID {
+			continue
+		}
+		if err := redisStore.SetOfflineMessage(userID, message); err != nil {
+			logger.Warn("Failed to requeue offline message", logger.String("message_id", message.ID), logger.ErrorField(err))
+		}
+	}
+}
+
+// handshakeUser 从握手中带的IM令牌取得用户，令牌通过 Authorization: Bearer <token> 或 token 查询参数传递（浏览器不能设置握手请求头）
+// 只在校验签名和有效期后返回用户，登录时仍按login帧认证
+func handshakeUser(tokens *service.TokenService, r *http.Request) (string, bool) {
+	raw := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		raw = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if raw == "" {
+		return "", false
+	}
+	claims, err := tokens.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+// hasUrgentMessages 握手准入时判断用户是否有待投递的紧急离线消息，userID来自已校验的握手令牌
+func hasUrgentMessages(redisStore *store.RedisStore, userID string) bool {
+	if userID == "" {
+		return false
+	}
+	urgent, err := redisStore.HasUrgentOfflineMessages(userID)
+	if err != nil {
+		logger.Warn("Failed to check urgent offline messages", logger.String("user_id", userID), logger.ErrorField(err))
+		return false
+	}
+	return urgent
+}
+
+// chainLoginGuards 依次执行登录检查，第一个要求回复的检查生效
+func chainLoginGuards(guards []websocket.LoginGuard) websocket.LoginGuard {
+	return func(s websocket.Session, req *model.LoginRequest) (model.FrameType, interface{}) {
+		for _, guard := range guards {
+			if msgType, reply := guard(s, req); msgType != "" {
+				return msgType, reply
+			}
+		}
+		return "", nil
+	}
+}
+
+func queryInt(c *gin.Context, name string, def, min, max int) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < min || value > max {
+		return 0, fmt.Errorf("%s must be an integer between %d and %d", name, min, max)
+	}
+	return value, nil
+}
+
+// queryBool 解析可选的布尔查询参数，未提供时返回nil
+func queryBool(c *gin.Context, name string) (*bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false", name)
+	}
+	return &value, nil
+}
+
+func handleRoute(userRouter *cluster.Router) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("user_id")
+		if userID == "" {
+			c.JSON(400, gin.H{"error": "user_id required"})
+			return
+		}
+
+		node := userRouter.HomeNode(userID)
+		if node == nil {
+			c.JSON(503, gin.H{"error": "No available node"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"user_id": userID,
+			"node_id": node.ID,
+			"address": node.Address,
+		})
+	}
+}
+
+func handleGetStats(stats *service.StatsService, registry cluster.Registry, mode string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resp := struct {
+			*model.NodeStats
+			Cluster *model.ClusterStats `json:"cluster,omitempty"`
+		}{NodeStats: stats.Snapshot()}
+
+		// 集群模式下汇总注册中心中所有节点的快照
+		if mode != config.ModeMonolith {
+			nodes := registry.Nodes()
+			nodeIDs := make([]string, 0, len(nodes))
+			for _, node := range nodes {
+				nodeIDs = append(nodeIDs, node.ID)
+			}
+			clusterStats, err := stats.ClusterStats(nodeIDs)
+			if err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			resp.Cluster = clusterStats
+		}
+
+		c.JSON(200, resp)
+	}
+}
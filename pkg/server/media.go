@@ -0,0 +1,65 @@
+package server
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/service"
+)
+
+// mediaProxyHeaders 从对象存储透传给客户端的响应头
+var mediaProxyHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"}
+
+func handleSignMediaURL(media *service.MediaService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		signed, err := media.SignURL(userID, c.Param("messageID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"url": signed.URL, "expires_at": signed.ExpiresAt})
+	}
+}
+
+// handleDownloadMedia 下载代理，签名地址本身即凭证，不需要X-User-ID
+func handleDownloadMedia(media *service.MediaService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resp, err := media.Open(c.Param("messageID"), c.Query("u"), c.Query("e"), c.Query("s"), c.GetHeader("Range"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		for _, name := range mediaProxyHeaders {
+			if value := resp.Header.Get(name); value != "" {
+				c.Header(name, value)
+			}
+		}
+		// 访问权限在每次下载时检查，不允许中间缓存保留撤销后的内容
+		c.Header("Cache-Control", "private, no-store")
+		c.Status(resp.StatusCode)
+		io.Copy(c.Writer, resp.Body)
+	}
+}
+
+func handleTopMediaConsumers(mediaStorage *service.MediaStorageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		consumers, err := mediaStorage.TopConsumers(c.Param("kind"), limit)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"kind": c.Param("kind"), "consumers": consumers})
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/service"
+)
+
+func handleListMessageRequests(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		limit, err := queryInt(c, "limit", 50, 1, 200)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		requests, err := messageService.ListMessageRequests(userID, limit)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"requests": requests})
+	}
+}
+
+func handleAcceptMessageRequest(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		delivered, err := messageService.AcceptMessageRequest(userID, c.Param("userID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true, "delivered": delivered})
+	}
+}
+
+func handleDeclineMessageRequest(messageService *service.MessageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		if err := messageService.DeclineMessageRequest(userID, c.Param("userID")); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/pkg/websocket"
+)
+
+// newMonitorServer 创建监控端口上的HTTP服务器
+// 除监控指标外开放pprof和expvar，用于排查线上内存和goroutine泄漏，诊断接口只接受静态管理员令牌
+func newMonitorServer(cfg *config.Config, wsManager *websocket.Manager) *http.Server {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.GET(cfg.Monitor.Path, gin.WrapH(promhttp.Handler()))
+
+	publishRuntimeVars(wsManager)
+
+	debug := router.Group("/debug", adminAuth(cfg.Admin.Token, nil, nil))
+	{
+		debug.GET("/vars", gin.WrapH(expvar.Handler()))
+		debug.GET("/pprof/", gin.WrapF(pprof.Index))
+		debug.GET("/pprof/cmdline", gin.WrapF(pprof.Cmdline))
+		debug.GET("/pprof/profile", gin.WrapF(pprof.Profile))
+		debug.POST("/pprof/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/pprof/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/pprof/trace", gin.WrapF(pprof.Trace))
+		// heap、goroutine、allocs、block、mutex、threadcreate等命名profile
+		debug.GET("/pprof/:name", func(c *gin.Context) {
+			pprof.Handler(c.Param("name")).ServeHTTP(c.Writer, c.Request)
+		})
+	}
+
+	return &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Monitor.Port),
+		Handler: router,
+	}
+}
+
+// publishRuntimeVars 在expvar中发布goroutine数、GC统计和会话索引大小
+// expvar默认已发布memstats和cmdline，这里补充便于直接观察的摘要，只能调用一次
+func publishRuntimeVars(wsManager *websocket.Manager) {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("gc", expvar.Func(func() interface{} {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return map[string]interface{}{
+			"num_gc":          stats.NumGC,
+			"pause_total_ns":  stats.PauseTotalNs,
+			"last_pause_ns":   stats.PauseNs[(stats.NumGC+255)%256],
+			"heap_alloc":      stats.HeapAlloc,
+			"heap_objects":    stats.HeapObjects,
+			"next_gc":         stats.NextGC,
+			"gc_cpu_fraction": stats.GCCPUFraction,
+		}
+	}))
+	expvar.Publish("websocket", expvar.Func(func() interface{} {
+		return wsManager.GetMapSizes()
+	}))
+}
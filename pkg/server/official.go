@@ -0,0 +1,176 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+func handleListOfficialAccounts(officials *service.OfficialAccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		accounts, err := officials.ListForUser(userID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"accounts": accounts})
+	}
+}
+
+func handleGetOfficialAccount(officials *service.OfficialAccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		account, err := officials.AccountForUser(userID, c.Param("accountID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"account": account})
+	}
+}
+
+func handleFollowOfficialAccount(officials *service.OfficialAccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		if err := officials.Follow(c.Param("accountID"), userID); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleUnfollowOfficialAccount(officials *service.OfficialAccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		if err := officials.Unfollow(c.Param("accountID"), userID); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleAdminListOfficialAccounts(officials *service.OfficialAccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"accounts": officials.List()})
+	}
+}
+
+func handleSetOfficialAccount(officials *service.OfficialAccountService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Name        string           `json:"name"`
+			Description string           `json:"description"`
+			AvatarURL   string           `json:"avatar_url"`
+			Pinned      bool             `json:"pinned"`
+			Menu        []model.MenuItem `json:"menu"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		account := &model.OfficialAccount{
+			ID:          c.Param("accountID"),
+			Name:        req.Name,
+			Description: req.Description,
+			AvatarURL:   req.AvatarURL,
+			Pinned:      req.Pinned,
+			Menu:        req.Menu,
+		}
+		if err := officials.SetAccount(account); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionSetOfficialAccount, account.ID, map[string]string{
+			"name":   account.Name,
+			"pinned": strconv.FormatBool(account.Pinned),
+		})
+
+		c.JSON(200, gin.H{"success": true, "account": account})
+	}
+}
+
+func handleDeleteOfficialAccount(officials *service.OfficialAccountService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		accountID := c.Param("accountID")
+
+		if err := officials.DeleteAccount(accountID); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionDeleteOfficialAccount, accountID, nil)
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleOfficialBroadcast(officials *service.OfficialAccountService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Type    string `json:"type"`
+			Content string `json:"content"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Type == "" {
+			req.Type = string(model.MessageTypeText)
+		}
+		accountID := c.Param("accountID")
+
+		followers, err := officials.Broadcast(accountID, model.MessageType(req.Type), req.Content)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionOfficialBroadcast, accountID, map[string]string{
+			"type":      req.Type,
+			"followers": strconv.FormatInt(followers, 10),
+		})
+
+		// 群发在后台执行
+		c.JSON(202, gin.H{"success": true, "followers": followers})
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net"
+
+	"github.com/user/im/internal/api"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/service"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/websocket"
+)
+
+// options New的选项
+type options struct {
+	cfg          *config.Config
+	listener     net.Listener
+	redisStore   *store.RedisStore
+	kafkaStore   *store.KafkaStore
+	messageStore service.MessageStoreBackend
+	tokens       api.TokenParser
+	loginGuards  []websocket.LoginGuard
+	geo          websocket.GeoLocator
+}
+
+// Option 服务器选项
+type Option func(*options)
+
+// WithConfig 使用已加载的配置，配置应经过config.LoadConfig补全默认值
+func WithConfig(cfg *config.Config) Option {
+	return func(o *options) {
+		o.cfg = cfg
+	}
+}
+
+// WithListener 在指定的监听器上提供HTTP服务，如测试中监听127.0.0.1:0，忽略server.host和server.port
+func WithListener(listener net.Listener) Option {
+	return func(o *options) {
+		o.listener = listener
+	}
+}
+
+// WithRedisStore 使用调用方创建的Redis存储，由调用方关闭
+func WithRedisStore(redisStore *store.RedisStore) Option {
+	return func(o *options) {
+		o.redisStore = redisStore
+	}
+}
+
+// WithKafkaStore 使用调用方创建的Kafka存储，由调用方关闭
+func WithKafkaStore(kafkaStore *store.KafkaStore) Option {
+	return func(o *options) {
+		o.kafkaStore = kafkaStore
+	}
+}
+
+// WithMessageStore 使用调用方提供的消息存储代替store.type配置的存储，由调用方关闭
+// 不是*store.MySQLStore时，依赖MySQL的功能（会话列表、API密钥、举报等）与LevelDB模式一样不可用
+func WithMessageStore(backend service.MessageStoreBackend) Option {
+	return func(o *options) {
+		o.messageStore = backend
+	}
+}
+
+// WithTokenParser 用户API的令牌校验，代替按auth.jwt_secret签发的IM令牌
+func WithTokenParser(tokens api.TokenParser) Option {
+	return func(o *options) {
+		o.tokens = tokens
+	}
+}
+
+// WithLoginGuard 追加WebSocket登录认证，在内置的令牌、探测和风险评估之后执行
+func WithLoginGuard(guard websocket.LoginGuard) Option {
+	return func(o *options) {
+		o.loginGuards = append(o.loginGuards, guard)
+	}
+}
+
+// WithGeoLocator 握手时按客户端IP查询地理位置，记录在会话记录和登录审计中
+func WithGeoLocator(geo websocket.GeoLocator) Option {
+	return func(o *options) {
+		o.geo = geo
+	}
+}
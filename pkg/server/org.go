@@ -0,0 +1,104 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+func handleGetOrgTree(orgService *service.OrgService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tree, err := orgService.Tree()
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"departments": tree})
+	}
+}
+
+func handleGetDepartment(orgService *service.OrgService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		department, members, children, err := orgService.Department(c.Param("departmentID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"department": department,
+			"members":    members,
+			"children":   children,
+		})
+	}
+}
+
+func handleGetUserDepartments(orgService *service.OrgService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		departments, err := orgService.UserDepartments(c.Param("userID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"departments": departments})
+	}
+}
+
+func handleSyncOrg(orgService *service.OrgService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+
+		var snapshot model.OrgSnapshot
+		if err := c.ShouldBindJSON(&snapshot); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := orgService.Sync(&snapshot)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionSyncOrg, "", orgSyncDetails(result))
+
+		c.JSON(200, gin.H{"result": result})
+	}
+}
+
+func handleSyncDepartmentGroups(orgService *service.OrgService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+
+		result, err := orgService.SyncGroups()
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionSyncDeptGroups, "", orgSyncDetails(result))
+
+		c.JSON(200, gin.H{"result": result})
+	}
+}
+
+// orgSyncDetails 同步结果的审计详情
+func orgSyncDetails(result *model.OrgSyncResult) map[string]string {
+	return map[string]string{
+		"departments":     strconv.Itoa(result.Departments),
+		"members":         strconv.Itoa(result.Members),
+		"removed":         strconv.Itoa(result.Removed),
+		"groups_created":  strconv.Itoa(result.GroupsCreated),
+		"members_added":   strconv.Itoa(result.MembersAdded),
+		"members_removed": strconv.Itoa(result.MembersRemoved),
+		"errors":          strconv.Itoa(len(result.Errors)),
+	}
+}
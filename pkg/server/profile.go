@@ -0,0 +1,201 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+func handleGetProfile(profileService *service.ProfileService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		profile, err := profileService.GetProfile(userID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		respondProfile(c, profileService, profile)
+	}
+}
+
+func handleUpdateProfile(profileService *service.ProfileService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			Language string `json:"language"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		profile, err := profileService.UpdateLanguage(userID, req.Language)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		respondProfile(c, profileService, profile)
+	}
+}
+
+// respondProfile 返回用户资料及实际用于渲染系统消息的语言
+func respondProfile(c *gin.Context, profileService *service.ProfileService, profile *model.UserProfile) {
+	c.JSON(200, gin.H{
+		"profile":             profile,
+		"effective_language":  profileService.EffectiveLanguage(profile.Language),
+		"supported_languages": profileService.SupportedLanguages(),
+	})
+}
+
+func handleGetQuietHours(profileService *service.ProfileService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		quietHours, err := profileService.GetQuietHours(userID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		respondQuietHours(c, quietHours)
+	}
+}
+
+func handleUpdateQuietHours(profileService *service.ProfileService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req model.QuietHours
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		quietHours, err := profileService.UpdateQuietHours(userID, &req)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		respondQuietHours(c, quietHours)
+	}
+}
+
+// respondQuietHours 返回免打扰计划及当前是否处于免打扰时段
+func respondQuietHours(c *gin.Context, quietHours *model.QuietHours) {
+	c.JSON(200, gin.H{
+		"quiet_hours": quietHours,
+		"active":      quietHours.Active(time.Now()),
+	})
+}
+
+func handleGetPrivacy(profileService *service.ProfileService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		privacy, err := profileService.GetPrivacy(userID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"privacy": privacy})
+	}
+}
+
+func handleUpdatePrivacy(profileService *service.ProfileService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			WhoCanMessage     string `json:"who_can_message"`
+			WhoCanAddToGroups string `json:"who_can_add_to_groups"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		privacy, err := profileService.UpdatePrivacy(userID, req.WhoCanMessage, req.WhoCanAddToGroups)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"privacy": privacy})
+	}
+}
+
+func handleGetNotificationPreferences(profileService *service.ProfileService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		prefs, err := profileService.GetNotificationPreferences(userID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"notification_preferences": prefs})
+	}
+}
+
+func handleUpdateNotificationPreferences(profileService *service.ProfileService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			GroupDigest string `json:"group_digest"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		prefs, err := profileService.UpdateNotificationPreferences(userID, req.GroupDigest)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"notification_preferences": prefs})
+	}
+}
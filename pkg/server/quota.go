@@ -0,0 +1,134 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+func handleReserveMedia(quota *service.QuotaService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			Size int64 `json:"size"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := quota.ReserveMedia(userID, req.Size); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// quotaSubject 从路径参数解析配额主体，kind为users或tenants
+func quotaSubject(c *gin.Context) (string, bool) {
+	switch c.Param("kind") {
+	case "users":
+		return model.QuotaSubject(model.QuotaSubjectUser, c.Param("id")), true
+	case "tenants":
+		return model.QuotaSubject(model.QuotaSubjectTenant, c.Param("id")), true
+	default:
+		c.JSON(404, gin.H{"error": "quota subject must be users or tenants"})
+		return "", false
+	}
+}
+
+func handleGetQuota(quota *service.QuotaService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject, ok := quotaSubject(c)
+		if !ok {
+			return
+		}
+
+		report, err := quota.Report(subject)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"quota": report})
+	}
+}
+
+func handleSetQuota(quota *service.QuotaService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		subject, ok := quotaSubject(c)
+		if !ok {
+			return
+		}
+
+		var req model.QuotaOverrides
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if req.IsEmpty() {
+			c.JSON(400, gin.H{"error": "at least one quota limit is required"})
+			return
+		}
+
+		report, err := quota.SetOverrides(subject, req)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionSetQuota, subject, quotaAuditDetails(req))
+
+		c.JSON(200, gin.H{"success": true, "quota": report})
+	}
+}
+
+func handleResetQuota(quota *service.QuotaService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		subject, ok := quotaSubject(c)
+		if !ok {
+			return
+		}
+
+		report, err := quota.ResetOverrides(subject)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionResetQuota, subject, nil)
+
+		c.JSON(200, gin.H{"success": true, "quota": report})
+	}
+}
+
+// quotaAuditDetails 审计记录中调整过的配额
+func quotaAuditDetails(o model.QuotaOverrides) map[string]string {
+	details := make(map[string]string)
+	for name, v := range map[string]*int64{
+		model.QuotaMessagesPerDay: o.MessagesPerDay,
+		model.QuotaMediaBytes:     o.MediaBytes,
+		model.QuotaMaxGroups:      o.MaxGroups,
+		model.QuotaMaxGroupSize:   o.MaxGroupSize,
+	} {
+		if v != nil {
+			details[name] = strconv.FormatInt(*v, 10)
+		}
+	}
+	return details
+}
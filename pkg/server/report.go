@@ -0,0 +1,124 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+	"github.com/user/im/internal/store"
+)
+
+func handleCreateReport(reportService *service.ReportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			TargetType model.ReportTarget `json:"target_type" binding:"required"`
+			MessageID  string             `json:"message_id"`
+			UserID     string             `json:"user_id"`
+			Reason     model.ReportReason `json:"reason" binding:"required"`
+			Comment    string             `json:"comment"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		report, err := reportService.Create(userID, service.ReportRequest{
+			TargetType: req.TargetType,
+			MessageID:  req.MessageID,
+			UserID:     req.UserID,
+			Reason:     req.Reason,
+			Comment:    req.Comment,
+		})
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		// 举报者只需要知道举报已受理，不返回消息副本
+		c.JSON(200, gin.H{"report_id": report.ID, "status": report.Status})
+	}
+}
+
+func handleListReports(reportService *service.ReportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := queryInt(c, "limit", 50, 1, 200)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		reports, err := reportService.List(store.ReportFilter{
+			Status:       model.ReportStatus(c.Query("status")),
+			TargetUserID: c.Query("target_user_id"),
+			BeforeID:     c.Query("cursor"),
+			Limit:        limit,
+		})
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		nextCursor := ""
+		if len(reports) == limit {
+			nextCursor = reports[len(reports)-1].ID
+		}
+		c.JSON(200, gin.H{"reports": reports, "next_cursor": nextCursor})
+	}
+}
+
+func handleGetReport(reportService *service.ReportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := reportService.Get(c.Param("reportID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"report": report})
+	}
+}
+
+func handleResolveReport(reportService *service.ReportService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			Action   model.ReportAction `json:"action" binding:"required"`
+			Duration int64              `json:"duration"` // 禁言或封禁时长（秒），0表示永久
+			Note     string             `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Duration < 0 {
+			c.JSON(400, gin.H{"error": "duration must not be negative"})
+			return
+		}
+
+		report, err := reportService.Resolve(c.Param("reportID"), actor, req.Action, time.Duration(req.Duration)*time.Second, req.Note)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionResolveReport, report.TargetUserID, map[string]string{
+			"report_id":  report.ID,
+			"action":     string(report.Action),
+			"message_id": report.MessageID,
+			"duration":   strconv.FormatInt(req.Duration, 10),
+		})
+
+		c.JSON(200, gin.H{"report": report})
+	}
+}
@@ -0,0 +1,1316 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/user/im/internal/api"
+	"github.com/user/im/internal/cluster"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/federation"
+	"github.com/user/im/internal/i18n"
+	"github.com/user/im/internal/lifecycle"
+	"github.com/user/im/internal/metrics"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+	"github.com/user/im/internal/store"
+	"github.com/user/im/pkg/idgen"
+	"github.com/user/im/pkg/logger"
+	"github.com/user/im/pkg/realip"
+	"github.com/user/im/pkg/s3"
+	"github.com/user/im/pkg/websocket"
+)
+
+// Server 可嵌入的IM服务器
+// New 按配置创建存储、服务和路由，Start 启动后台任务并开始监听，Shutdown 按与启动相反的顺序停止
+type Server struct {
+	cfg           *config.Config
+	listener      net.Listener
+	wsManager     *websocket.Manager
+	httpServer    *http.Server
+	monitorServer *http.Server
+	// drainer 滚动发布时排空本节点的连接
+	drainer *cluster.Drainer
+
+	// ctx 在Shutdown时取消，用于停止后台循环
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// lifecycle 按依赖顺序管理存储和后台任务的启动、停止和健康检查
+	lifecycle *lifecycle.Lifecycle
+}
+
+// component 添加组件，start或stop为nil表示没有对应的操作
+func (srv *Server) component(name string, start, stop func() error) {
+	srv.lifecycle.Append(lifecycle.Component{Name: name, Start: start, Stop: stop})
+}
+
+// resource 添加创建时已打开的资源，如存储，关闭时停止，health为nil表示不做健康检查
+func (srv *Server) resource(name string, stop, health func() error) {
+	srv.lifecycle.Append(lifecycle.Component{Name: name, Stop: stop, Health: health})
+}
+
+// noErr 把没有返回值的启动或停止函数转换为组件的操作
+func noErr(fn func()) func() error {
+	return func() error {
+		fn()
+		return nil
+	}
+}
+
+// New 按配置创建服务器，失败时关闭已打开的存储
+func New(opts ...Option) (*Server, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	cfg := o.cfg
+	if cfg == nil {
+		return nil, errors.New("server config is required")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := &Server{cfg: cfg, listener: o.listener, ctx: ctx, cancel: cancel,
+		lifecycle: lifecycle.New(cfg.Components.Disabled)}
+	if err := srv.build(o); err != nil {
+		cancel()
+		srv.lifecycle.Stop()
+		return nil, err
+	}
+	return srv, nil
+}
+
+// build 创建存储、服务和路由；后台任务添加为组件，在Start时启动
+func (srv *Server) build(o *options) error {
+	cfg := srv.cfg
+	var err error
+
+	logger.Info("Starting IM Server...")
+
+	// 初始化ID生成器
+	if err := idgen.Init(cfg.ID.Strategy, cfg.ID.MachineID); err != nil {
+		return fmt.Errorf("failed to initialize ID generator: %w", err)
+	}
+
+	logger.Info("Cluster mode",
+		logger.String("mode", cfg.Cluster.Mode),
+		logger.String("node_id", cfg.Cluster.NodeID))
+
+	// 注入的存储由调用方关闭
+	redisStore := o.redisStore
+	if redisStore == nil {
+		if redisStore, err = store.NewRedisStore(&cfg.Redis); err != nil {
+			return fmt.Errorf("failed to initialize Redis store: %w", err)
+		}
+		srv.resource("redis", redisStore.Close, redisStore.Ping)
+	}
+
+	kafkaStore := o.kafkaStore
+	if kafkaStore == nil {
+		if kafkaStore, err = store.NewKafkaStore(&cfg.Kafka); err != nil {
+			return fmt.Errorf("failed to initialize Kafka store: %w", err)
+		}
+		srv.resource("kafka", kafkaStore.Close, kafkaStore.Ping)
+	}
+
+	// 上行负载大小上限，进程内的所有服务共用帧注册表
+	frameLimits := make(map[model.FrameType]int, len(cfg.Server.FrameLimits))
+	for frameType, limit := range cfg.Server.FrameLimits {
+		frameLimits[model.FrameType(frameType)] = limit
+	}
+	model.SetFrameSizeLimits(frameLimits)
+
+	// 初始化WebSocket管理器
+	wsManager := websocket.NewManager()
+	srv.wsManager = wsManager
+	transport, err := websocket.NewWebSocketTransport(wsManager, websocket.TransportOptions{
+		AllowedOrigins: cfg.Server.AllowedOrigins,
+		Protocols:      cfg.Server.Protocols,
+		MaxMessageSize: cfg.Server.MaxMessageSize,
+		TrustedProxies: cfg.HTTP.TrustedProxies,
+		GeoLocator:     o.geo,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure websocket transport: %w", err)
+	}
+	wsManager.RegisterTransport(transport)
+	if cfg.Server.AckWindow > 0 {
+		wsManager.SetFlowControl(websocket.FlowOptions{
+			Window:    cfg.Server.AckWindow,
+			MaxQueued: cfg.Server.AckQueue,
+		}, func(userID string, frames [][]byte) {
+			requeueFrames(redisStore, userID, frames)
+		})
+	}
+	wsManager.SetBandwidth(websocket.BandwidthOptions{MaxBytesPerSecond: cfg.Bandwidth.MaxBytesPerSecond})
+
+	// 注册本节点到集群
+	registry, err := cluster.NewRegistry(&cfg.Cluster.Registry, &cluster.Node{
+		ID:       cfg.Cluster.NodeID,
+		Address:  cfg.Cluster.AdvertiseAddr,
+		Mode:     cfg.Cluster.Mode,
+		Capacity: cfg.Cluster.Capacity,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize node registry: %w", err)
+	}
+	srv.component("registry", registry.Start, registry.Stop)
+
+	// 接入节点优先推送到本地会话，网关与业务节点模式下其他用户经网关推送主题转发
+	delivery := service.NewDeliveryRouter()
+	var (
+		deliverer service.Deliverer = delivery
+		relay     *cluster.Relay
+	)
+	if cfg.Cluster.Mode != config.ModeWorker {
+		delivery.Online(service.DeliveryLocal, wsManager)
+	}
+	if cfg.Cluster.Mode != config.ModeMonolith {
+		// 启用注册中心时，只向存活的网关节点推送
+		var peers cluster.Registry
+		if cfg.Cluster.Registry.Type != "" {
+			peers = registry
+		}
+		relay = cluster.NewRelay(redisStore, kafkaStore, peers, cfg.Kafka.Topics.GatewayPush)
+		delivery.Online(service.DeliveryRelay, relay)
+	}
+
+	// 规范事件流，供分析和下游系统消费
+	events := service.NewEventPublisher(kafkaStore, cfg.Kafka.Topics.Events)
+	srv.resource("events", events.Close, nil)
+
+	// 客户端登录时上报的能力
+	clientService := service.NewClientService(redisStore)
+
+	// 连接流量统计，由持有客户端连接的节点累加，任意节点可查询
+	bandwidth := service.NewBandwidthService(redisStore, wsManager, cfg.Bandwidth, cfg.Cluster.NodeID)
+	if cfg.Cluster.Mode != config.ModeWorker {
+		srv.component("bandwidth", noErr(bandwidth.Start), nil)
+	}
+
+	// 功能开关，按用户灰度
+	flags := service.NewFeatureFlagService(redisStore, cfg.Flags)
+
+	// 表情包和自定义表情，租户划分与配额一致
+	stickers := service.NewStickerService(redisStore, cfg.Stickers, cfg.Quota.TenantSeparator)
+
+	// 客户端配置，登录后和变更时下发，附带按用户计算的功能开关和可用表情包
+	clientConfig := service.NewClientConfigService(redisStore, cfg.Client)
+	clientConfig.SetFeatureFlags(flags)
+	clientConfig.SetStickers(stickers)
+	if cfg.Cluster.Mode != config.ModeWorker {
+		pushClientConfig := func() {
+			wsManager.ForEachUser(func(userID string, s websocket.Session) {
+				wsManager.Reply(s, model.FrameClientConfig, clientConfig.ForUser(userID))
+			})
+		}
+		clientConfig.OnChange(func(*model.ClientConfig) { pushClientConfig() })
+		flags.OnChange(pushClientConfig)
+		stickers.OnChange(pushClientConfig)
+		wsManager.OnBind(func(userID string, s websocket.Session) {
+			wsManager.Reply(s, model.FrameClientConfig, clientConfig.ForUser(userID))
+		})
+		// 未登录的会话没有用户，只能使用行为默认开启的上行帧
+		wsManager.SetFrameGate(func(s websocket.Session, msgType model.FrameType) bool {
+			return flags.Enabled(model.FeatureFlagFramePrefix+string(msgType), s.UserID(), true)
+		})
+	}
+	srv.component("feature_flags", noErr(flags.Start), nil)
+	srv.component("stickers", noErr(stickers.Start), nil)
+	srv.component("client_config", noErr(clientConfig.Start), nil)
+
+	// 大文件分片上传，会话保存在Redis中，分片直接写入对象存储
+	var uploads *service.UploadService
+	if cfg.Upload.Enabled {
+		storage, err := s3.New(s3.Config{
+			Endpoint:  cfg.Upload.S3.Endpoint,
+			Region:    cfg.Upload.S3.Region,
+			AccessKey: cfg.Upload.S3.AccessKey,
+			SecretKey: cfg.Upload.S3.SecretKey,
+			// 下载代理按客户端速度转发大文件，只限制等待响应头的时间
+			ResponseHeaderTimeout: time.Minute,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to initialize upload storage: %w", err)
+		}
+		uploads = service.NewUploadService(redisStore, storage, cfg.Upload)
+		if cfg.Upload.Image.Enabled || len(cfg.Upload.Image.EnabledTenants) > 0 {
+			uploads.SetImageProcessor(service.NewImageProcessor(cfg.Upload.Image, cfg.Quota.TenantSeparator))
+		}
+	}
+
+	// 媒体存储统计，任意节点完成的上传都需要登记
+	var mediaStorage *service.MediaStorageService
+	if cfg.MediaStorage.Enabled {
+		if uploads == nil {
+			return errors.New("media storage accounting requires upload.enabled")
+		}
+		mediaStorage = service.NewMediaStorageService(redisStore, uploads, cfg.MediaStorage)
+		uploads.OnComplete(mediaStorage.Track)
+	}
+
+	// 在线状态扇出
+	presenceService := service.NewPresenceService(redisStore, deliverer, cfg.Presence)
+	presenceService.SetEventPublisher(events)
+	if cfg.Cluster.Mode != config.ModeWorker {
+		wsManager.OnBind(func(userID string, s websocket.Session) {
+			presenceService.SetOnline(userID)
+			clientService.Bind(userID, s.Capabilities())
+		})
+		wsManager.OnUnbind(func(userID string, s websocket.Session) {
+			presenceService.SetOffline(userID)
+		})
+		// 关注列表随连接保存，在接入节点本地处理，连接关闭时取消
+		wsManager.HandleFrame(model.FrameSubscribePresence, func(s websocket.Session, frame *model.WebSocketMessage) {
+			resp, err := presenceService.WatchFrame(s.UserID(), s.ID(), frame)
+			if err != nil {
+				reply := service.ServiceErrorFrame(err)
+				wsManager.Reply(s, reply.Type, reply.Data)
+				return
+			}
+			wsManager.Reply(s, model.FrameSubscribePresence, resp)
+		})
+		wsManager.OnClose(func(userID string, s websocket.Session) {
+			presenceService.Unwatch(s.ID())
+		})
+	}
+
+	// 网关模式不需要消息存储，业务节点与单体模式需要初始化存储层和消息服务
+	var (
+		messageService *service.MessageService
+		analytics      *service.AnalyticsService
+		stats          *service.StatsService
+		quota          *service.QuotaService
+		words          *service.WordFilter
+		latency        *service.LatencyTracker
+		mysqlStore     *store.MySQLStore
+		jobs           *cluster.Coordinator
+		fed            *federation.Service
+	)
+	if cfg.Cluster.Mode != config.ModeGateway {
+		var (
+			leveldbStore *store.LevelDBStore
+			storeBackend service.MessageStoreBackend
+		)
+
+		if o.messageStore != nil {
+			storeBackend = o.messageStore
+			mysqlStore, _ = o.messageStore.(*store.MySQLStore)
+		} else if cfg.Store.Type == "leveldb" {
+			leveldbStore, err = store.NewLevelDBStore(cfg.Store.LevelDBPath)
+			if err == nil {
+				leveldbStore.SetSlowThreshold(cfg.Store.SlowThreshold)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to initialize LevelDB store: %w", err)
+			}
+			srv.resource("leveldb", leveldbStore.Close, nil)
+			storeBackend = leveldbStore
+			logger.Info("Using LevelDB as message store", logger.String("path", cfg.Store.LevelDBPath))
+		} else {
+			mysqlStore, err = store.NewMySQLStore(&cfg.Database)
+			if err != nil {
+				return fmt.Errorf("failed to initialize MySQL store: %w", err)
+			}
+			srv.resource("mysql", mysqlStore.Close, mysqlStore.Ping)
+			storeBackend = mysqlStore
+			logger.Info("Using MySQL as message store")
+		}
+
+		// 消息推送经统计包装，用于计算投递成功率
+		stats = service.NewStatsService(cfg.Cluster.NodeID, cfg.Cluster.Mode, redisStore, kafkaStore, mysqlStore, wsManager, cfg.Stats.Interval)
+		messageDeliverer := delivery.Wrap(stats.Deliverer)
+
+		if cfg.Cluster.Mode == config.ModeWorker {
+			messageService = service.NewMessageServiceWithBackend(storeBackend, redisStore, kafkaStore, messageDeliverer)
+			srv.component("worker", noErr(cluster.NewWorker(kafkaStore, relay, messageService, cfg.Kafka.Topics.GatewayUpstream, cfg.Kafka.GroupID).Start), nil)
+		} else {
+			messageService = service.NewMessageServiceWithBackend(storeBackend, redisStore, kafkaStore, messageDeliverer)
+			for _, frameType := range cluster.BusinessFrames {
+				wsManager.HandleFrame(frameType, func(s websocket.Session, frame *model.WebSocketMessage) {
+					if reply := messageService.HandleFrame(s.UserID(), frame); reply != nil {
+						wsManager.Reply(s, reply.Type, reply.Data)
+					}
+				})
+			}
+		}
+
+		messageService.SetGroupConfig(cfg.Group)
+		messageService.SetGroupEventConfig(cfg.GroupEvents)
+		messageService.SetPollConfig(cfg.Polls)
+		messageService.SetNotificationConfig(cfg.Notification)
+		srv.component("group_digests", noErr(messageService.StartGroupDigests), nil)
+		messageService.SetMessageCacheConfig(cfg.MessageCache)
+		messageService.SetLocker(store.NewLocker(redisStore, cfg.Cluster.NodeID, cfg.Lock.TTL, cfg.Lock.Wait))
+		messageIDs, err := newIDGenerator(cfg.ID, config.IDComponentMessage)
+		if err != nil {
+			return err
+		}
+		groupIDs, err := newIDGenerator(cfg.ID, config.IDComponentGroup)
+		if err != nil {
+			return err
+		}
+		messageService.SetIDGenerators(messageIDs, groupIDs)
+		messageService.SetStats(stats)
+		messageService.SetFeatureFlags(flags)
+		messageService.SetStickers(stickers)
+		messageService.SetEventPublisher(events)
+		if mediaStorage != nil {
+			messageService.SetMediaStorage(mediaStorage)
+		}
+		if cfg.Spam.Enabled {
+			messageService.SetSpamDetector(service.NewSpamDetector(redisStore, kafkaStore, cfg.Spam, cfg.Kafka.Topics.Moderation))
+		}
+		if cfg.WordFilter.Enabled {
+			words = service.NewWordFilter(redisStore, cfg.WordFilter)
+			srv.component("word_filter", words.Start, nil)
+			messageService.SetWordFilter(words)
+		}
+		if cfg.DeliverySLO.Enabled {
+			latency = service.NewLatencyTracker(redisStore, cfg.DeliverySLO)
+			messageService.SetLatencyTracker(latency)
+		}
+		if cfg.LinkSafety.Enabled {
+			links, err := service.NewLinkSafety(redisStore, kafkaStore, cfg.LinkSafety, cfg.Kafka.Topics.Moderation)
+			if err != nil {
+				return fmt.Errorf("failed to initialize link safety: %w", err)
+			}
+			messageService.SetLinkSafety(links)
+		}
+		if cfg.Spool.Enabled {
+			spool, err := store.OpenSpool(cfg.Spool.Path, cfg.Spool.MaxEntries)
+			if err != nil {
+				return fmt.Errorf("failed to open spool: %w", err)
+			}
+			srv.resource("spool", spool.Close, nil)
+			messageService.SetSpool(spool)
+			srv.component("spool_replay", noErr(func() { messageService.StartSpoolReplay(cfg.Spool.ReplayInterval) }), nil)
+		}
+		if cfg.Quota.Enabled {
+			quota = service.NewQuotaService(redisStore, mysqlStore, cfg.Quota)
+			messageService.SetQuota(quota)
+			srv.component("quota", noErr(quota.Start), nil)
+			if uploads != nil {
+				uploads.SetQuota(quota)
+			}
+			if mediaStorage != nil {
+				mediaStorage.SetQuota(quota)
+			}
+		}
+		previewTopic := ""
+		if cfg.Preview.Enabled {
+			previewTopic = cfg.Kafka.Topics.LinkPreview
+			messageService.SetLinkPreview(service.NewLinkPreviewFetcher(redisStore, cfg.Preview), previewTopic)
+		}
+		voiceTopic := ""
+		if cfg.Voice.Enabled {
+			voiceTopic = cfg.Kafka.Topics.MediaProcessing
+			messageService.SetVoiceAnalyzer(service.NewVoiceAnalyzer(cfg.Voice), voiceTopic)
+		}
+
+		// 跨域联邦，发给其他域用户的消息进入发件队列，配置了接收主题时同时消费Kafka传输的事件
+		if cfg.Federation.Enabled {
+			fed, err = federation.New(cfg.Federation, redisStore, kafkaStore)
+			if err != nil {
+				return fmt.Errorf("failed to initialize federation: %w", err)
+			}
+			messageService.SetFederation(fed)
+			srv.component("federation", noErr(func() {
+				go func() {
+					if err := fed.Consume(cfg.Kafka.GroupID + "-federation"); err != nil {
+						logger.Error("Failed to consume federation events", logger.ErrorField(err))
+					}
+				}()
+			}), nil)
+		}
+
+		// 启动Kafka消费者
+		srv.component("kafka_consumers", noErr(func() {
+			startKafkaConsumers(kafkaStore, redisStore, messageService, previewTopic, voiceTopic, cfg.Kafka.DedupTTL)
+		}), nil)
+
+		// 集群级后台任务，只在选举出的主节点上运行
+		jobs = cluster.NewCoordinator(cluster.NewElector(redisStore, cfg.Cluster.Leader.Key, cfg.Cluster.NodeID, cfg.Cluster.Leader.TTL))
+
+		// 运营统计汇总到MySQL，LevelDB模式下不可用
+		if cfg.Analytics.Enabled && mysqlStore != nil {
+			analytics = service.NewAnalyticsService(mysqlStore, redisStore, cfg.Analytics)
+			messageService.SetAnalytics(analytics)
+			jobs.Register("analytics", cfg.Analytics.Interval, analytics.Rollup)
+		}
+		// 投递恢复扫描MySQL中的消息，启动时先扫描一次，恢复崩溃前中断的投递
+		if cfg.Recovery.Enabled {
+			if mysqlStore == nil {
+				return errors.New("delivery recovery requires MySQL store")
+			}
+			recovery := service.NewDeliveryRecovery(messageService, mysqlStore, redisStore, cfg.Recovery)
+			jobs.Register("delivery_recovery", cfg.Recovery.Interval, recovery.Run)
+			srv.component("startup_recovery", noErr(func() {
+				go func() {
+					if err := recovery.Run(srv.ctx, 0); err != nil {
+						logger.Error("Startup delivery recovery failed", logger.ErrorField(err))
+					}
+				}()
+			}), nil)
+		}
+		// 过期上传会话由业务节点的主节点统一清理，网关节点创建的会话同样保存在Redis中
+		if uploads != nil {
+			jobs.Register("upload_cleanup", cfg.Upload.CleanupInterval, uploads.Cleanup)
+		}
+		// 没有消息引用的媒体文件到期删除
+		if mediaStorage != nil {
+			jobs.Register("media_lifecycle", cfg.MediaStorage.CleanupInterval, mediaStorage.Cleanup)
+		}
+		// 群组成员缓存与MySQL对账，群活动开始前的提醒和投票截止
+		if mysqlStore != nil {
+			jobs.Register("group_cache_reconcile", cfg.Group.ReconcileInterval, messageService.ReconcileGroupCache)
+			jobs.Register("group_event_reminders", cfg.GroupEvents.ReminderInterval, messageService.SendGroupEventReminders)
+			jobs.Register("poll_deadlines", cfg.Polls.CloseInterval, messageService.ClosePollsAtDeadline)
+		}
+		// 各远程域的发件队列由主节点按顺序投递
+		if fed != nil {
+			jobs.Register("federation_outbox", cfg.Federation.FlushInterval, fed.Flush)
+		}
+		srv.component("jobs", noErr(jobs.Start), noErr(jobs.Stop))
+	}
+
+	// 网关节点只记录活跃用户和连接数，由业务节点的主节点汇总
+	if cfg.Analytics.Enabled && cfg.Cluster.Mode == config.ModeGateway {
+		analytics = service.NewAnalyticsService(nil, redisStore, cfg.Analytics)
+	}
+	if cfg.Cluster.Mode != config.ModeWorker {
+		wsManager.OnBind(func(userID string, s websocket.Session) {
+			analytics.RecordActive(userID)
+		})
+	}
+	if stats == nil {
+		stats = service.NewStatsService(cfg.Cluster.NodeID, cfg.Cluster.Mode, redisStore, kafkaStore, nil, wsManager, cfg.Stats.Interval)
+	}
+	srv.component("stats", noErr(stats.Start), nil)
+
+	// 用户全局处罚，被封禁用户登录后立即断开
+	moderationService := service.NewModerationService(mysqlStore, redisStore, deliverer)
+	srv.component("moderation", noErr(func() {
+		if err := moderationService.Restore(); err != nil {
+			logger.Error("Failed to restore user sanctions", logger.ErrorField(err))
+		}
+	}), nil)
+	// 会话列表设置保存在MySQL中，LevelDB模式下不可用
+	var conversationService *service.ConversationService
+	if mysqlStore != nil {
+		conversationService = service.NewConversationService(mysqlStore, redisStore)
+	}
+
+	// 公众号保存在Redis中，群发经消息服务发送，网关模式下不可用
+	var officials *service.OfficialAccountService
+	if messageService != nil {
+		officials = service.NewOfficialAccountService(redisStore, messageService, cfg.Official)
+		messageService.SetOfficialAccounts(officials)
+		srv.component("official_accounts", noErr(officials.Start), nil)
+		if conversationService != nil {
+			conversationService.SetOfficialAccounts(officials)
+		}
+	}
+	draftService := service.NewDraftService(redisStore, deliverer, cfg.Draft)
+	settingsService := service.NewSettingsService(redisStore, deliverer, cfg.Settings)
+
+	// 系统消息按用户资料中的偏好语言渲染
+	catalog, err := i18n.NewCatalog(cfg.I18n.DefaultLanguage, cfg.I18n.LocalesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load i18n catalog: %w", err)
+	}
+	profileService := service.NewProfileService(mysqlStore, redisStore, catalog)
+	if messageService != nil {
+		messageService.SetI18n(catalog, profileService)
+	}
+
+	// 服务间调用的API密钥保存在MySQL中，LevelDB模式和网关模式下不可用
+	var apiKeyService *service.APIKeyService
+	if mysqlStore != nil && messageService != nil {
+		apiKeyService = service.NewAPIKeyService(mysqlStore, redisStore, messageService, cfg.APIKey)
+	}
+	// 服务消息模板保存在Redis中，按模板发送依赖API密钥
+	var templates *service.TemplateService
+	if apiKeyService != nil {
+		templates = service.NewTemplateService(redisStore, apiKeyService)
+	}
+	auditService := service.NewAuditService(mysqlStore)
+
+	// 会话记录，登录时保存握手信息并记录审计
+	sessions := service.NewSessionService(cfg.Cluster.NodeID, redisStore, auditService)
+	if cfg.Cluster.Mode != config.ModeWorker {
+		wsManager.OnBind(sessions.Bind)
+		wsManager.OnUnbind(sessions.Unbind)
+	}
+
+	// 只读访客令牌保存在Redis中，读取历史消息需要MySQL，LevelDB模式和网关模式下不可用
+	var guests *service.GuestService
+	if mysqlStore != nil && messageService != nil {
+		guests = service.NewGuestService(mysqlStore, redisStore, messageService, cfg.Guest)
+	}
+
+	// 用户举报保存在MySQL中，LevelDB模式和网关模式下不可用
+	var reportService *service.ReportService
+	if mysqlStore != nil && messageService != nil {
+		reportService = service.NewReportService(mysqlStore, messageService, moderationService, events)
+	}
+
+	// 上传文件扫描，扫描通过前文件保存在隔离区
+	if uploads != nil {
+		scanner, err := service.NewScanner(cfg.Upload.Scan)
+		if err != nil {
+			return fmt.Errorf("failed to initialize upload scanner: %w", err)
+		}
+		if scanner != nil {
+			uploads.SetScanner(scanner, auditService)
+		}
+	}
+
+	// 媒体消息的签名下载地址，文件保存在分片上传的对象存储中
+	var mediaService *service.MediaService
+	if cfg.Media.Enabled {
+		if uploads == nil || cfg.Media.SigningKey == "" {
+			return errors.New("media access control requires upload.enabled and media.signing_key")
+		}
+		if messageService != nil {
+			mediaService = service.NewMediaService(messageService, uploads, cfg.Media)
+		}
+	}
+
+	// 组织架构保存在MySQL中，部门群通过消息服务维护
+	var orgService *service.OrgService
+	if mysqlStore != nil && messageService != nil {
+		orgService = service.NewOrgService(mysqlStore, messageService, cfg.Org)
+	}
+
+	// IM令牌，身份提供方登录后签发，也用于管理接口和WebSocket登录认证
+	var (
+		tokens      *service.TokenService
+		loginGuards []websocket.LoginGuard
+	)
+	if cfg.Auth.JWTSecret != "" {
+		tokens = service.NewTokenService(cfg.Auth)
+	}
+	if (cfg.Auth.OIDC.Enabled || cfg.Auth.RequireToken) && tokens == nil {
+		return errors.New("auth.jwt_secret is required for OIDC login and token authentication")
+	}
+	if cfg.Admission.Enabled {
+		admission := websocket.AdmissionOptions{
+			Rate:            cfg.Admission.Rate,
+			Burst:           cfg.Admission.Burst,
+			PriorityReserve: cfg.Admission.PriorityReserve,
+			MinRetryAfter:   cfg.Admission.MinRetryAfter,
+			MaxRetryAfter:   cfg.Admission.MaxRetryAfter,
+			OnDecision: func(result websocket.AdmissionResult) {
+				metrics.WebSocketAdmissions.WithLabelValues(string(result)).Inc()
+			},
+		}
+		// 保留额度只按握手中带的IM令牌认定的用户判断，没有签发令牌时不设优先客户端
+		if tokens != nil {
+			admission.Priority = func(r *http.Request) bool {
+				userID, ok := handshakeUser(tokens, r)
+				return ok && hasUrgentMessages(redisStore, userID)
+			}
+		}
+		wsManager.SetAdmission(admission)
+	}
+	if cfg.Auth.RequireToken && cfg.Cluster.Mode != config.ModeWorker {
+		loginGuards = append(loginGuards, func(s websocket.Session, req *model.LoginRequest) (model.FrameType, interface{}) {
+			if err := tokens.AuthorizeLogin(req); err != nil {
+				reply := service.ServiceErrorFrame(err)
+				return reply.Type, reply.Data
+			}
+			return "", nil
+		})
+	}
+
+	// 合成探测，接入节点登录自己的探测用户；网关模式下同时探测其他网关节点
+	var canary *service.Canary
+	if cfg.Canary.Enabled && cfg.Cluster.Mode != config.ModeWorker {
+		var peers func() []string
+		if cfg.Cluster.Mode == config.ModeGateway {
+			peers = func() []string {
+				var ids []string
+				for _, node := range registry.Nodes() {
+					if node.Mode == config.ModeGateway {
+						ids = append(ids, node.ID)
+					}
+				}
+				return ids
+			}
+		}
+		canary = service.NewCanary(wsManager, redisStore, cfg.Canary, cfg.Cluster.NodeID, peers)
+		loginGuards = append(loginGuards, func(s websocket.Session, req *model.LoginRequest) (model.FrameType, interface{}) {
+			if err := canary.AuthorizeLogin(req); err != nil {
+				reply := service.ServiceErrorFrame(err)
+				return reply.Type, reply.Data
+			}
+			return "", nil
+		})
+	}
+
+	// 两步验证数据保存在MySQL中，LevelDB模式下不可用；网关模式在接入层校验，单独连接MySQL
+	var twoFactor *service.TwoFactorService
+	if cfg.TwoFactor.Enabled {
+		twoFactorStore := mysqlStore
+		if twoFactorStore == nil && cfg.Cluster.Mode == config.ModeGateway {
+			twoFactorStore, err = store.NewMySQLStore(&cfg.Database)
+			if err != nil {
+				return fmt.Errorf("failed to initialize MySQL store for two-factor authentication: %w", err)
+			}
+			srv.resource("two_factor_store", twoFactorStore.Close, twoFactorStore.Ping)
+		}
+		if twoFactorStore == nil {
+			return errors.New("two-factor authentication requires MySQL store")
+		}
+		twoFactor = service.NewTwoFactorService(twoFactorStore, cfg.TwoFactor)
+	}
+
+	// 可疑登录验证和两步验证，在接入层处理
+	if (cfg.Challenge.Enabled || twoFactor != nil) && cfg.Cluster.Mode != config.ModeWorker {
+		var verifier service.Verifier
+		if cfg.Challenge.Enabled {
+			verifier, err = service.NewHTTPVerifier(cfg.Challenge.Verifier)
+			if err != nil {
+				return fmt.Errorf("failed to initialize challenge verifier: %w", err)
+			}
+		}
+		challenges := service.NewChallengeService(redisStore, cfg.Challenge, verifier)
+		challenges.SetTwoFactor(twoFactor)
+		loginGuards = append(loginGuards, func(s websocket.Session, req *model.LoginRequest) (model.FrameType, interface{}) {
+			challenge, err := challenges.Evaluate(s.ID(), req, s.RemoteIP())
+			var svcErr *service.ServiceError
+			if errors.As(err, &svcErr) {
+				reply := service.ServiceErrorFrame(err)
+				return reply.Type, reply.Data
+			}
+			if err != nil {
+				// 风险评估依赖Redis，评估失败时放行，避免Redis故障导致所有用户无法登录
+				logger.Warn("Failed to evaluate login risk", logger.String("user_id", req.UserID), logger.ErrorField(err))
+				return "", nil
+			}
+			if challenge == nil {
+				return "", nil
+			}
+			return model.FrameChallengeRequired, challenge
+		})
+		wsManager.HandleFrame(model.FrameVerifyChallenge, func(s websocket.Session, frame *model.WebSocketMessage) {
+			req, deviceToken, err := challenges.VerifyFrame(s.ID(), frame)
+			if err != nil {
+				reply := service.ServiceErrorFrame(err)
+				wsManager.Reply(s, reply.Type, reply.Data)
+				return
+			}
+			wsManager.CompleteLogin(s, req, deviceToken)
+		})
+	}
+	// 嵌入方注入的认证在内置认证之后执行
+	loginGuards = append(loginGuards, o.loginGuards...)
+	if len(loginGuards) > 0 {
+		wsManager.SetLoginGuard(chainLoginGuards(loginGuards))
+	}
+
+	// 身份提供方登录
+	var auth *service.AuthService
+	if cfg.Auth.OIDC.Enabled && cfg.Cluster.Mode != config.ModeWorker {
+		provider, err := service.NewOIDCProvider(cfg.Auth.OIDC)
+		if err != nil {
+			return fmt.Errorf("failed to initialize OIDC provider: %w", err)
+		}
+		auth = service.NewAuthService(provider, tokens, profileService, twoFactor, cfg.Auth.OIDC)
+	}
+
+	if cfg.Cluster.Mode != config.ModeWorker {
+		wsManager.OnBind(func(userID string, s websocket.Session) {
+			if until, banned := moderationService.GetBan(userID); banned {
+				wsManager.DisconnectUser(userID, service.BannedFrame(until))
+			}
+		})
+	}
+
+	if cfg.Cluster.Mode == config.ModeGateway {
+		gateway := cluster.NewGateway(cfg.Cluster.NodeID, wsManager, redisStore, kafkaStore,
+			cfg.Kafka.Topics.GatewayUpstream, cfg.Kafka.Topics.GatewayPush, cfg.Cluster.RouteTTL, cfg.Kafka.DedupTTL)
+		srv.component("gateway", func() error {
+			// 推送主题按网关节点生成，由网关自己创建
+			if cfg.Kafka.Provision.Enabled {
+				if err := kafkaStore.EnsureTopics(cluster.PushTopic(cfg.Kafka.Topics.GatewayPush, cfg.Cluster.NodeID)); err != nil {
+					return fmt.Errorf("failed to provision gateway push topic: %w", err)
+				}
+			}
+			gateway.Start()
+			return nil
+		}, nil)
+	}
+	// 会话绑定回调都已注册，探测用户登录后才能维护网关路由和在线状态
+	if canary != nil {
+		srv.component("canary", noErr(canary.Start), nil)
+	}
+
+	// 启动心跳检测
+	srv.component("heartbeat", noErr(func() { go startHeartbeatChecker(srv.ctx, wsManager, analytics, cfg.Cluster.NodeID) }), nil)
+
+	// 创建HTTP服务器，中间件按http配置组装
+	resolver, err := realip.New(cfg.HTTP.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("failed to configure trusted proxies: %w", err)
+	}
+	routerOptions := api.RouterOptions{Counter: redisStore, Tokens: o.tokens, RealIP: resolver}
+	if routerOptions.Tokens == nil && tokens != nil {
+		routerOptions.Tokens = tokens
+	}
+	router := api.NewRouter(cfg.HTTP, routerOptions)
+
+	// 健康检查
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"status":    "ok",
+			"mode":      cfg.Cluster.Mode,
+			"timestamp": time.Now().Unix(),
+		})
+	})
+
+	// 用户归属节点路由，排空时按同一份节点列表为客户端选择新节点
+	userRouter := cluster.NewRouter(registry, cfg.Cluster.Routing.VirtualNodes)
+	srv.drainer = cluster.NewDrainer(cfg.Cluster.Drain, cfg.Cluster.NodeID, registry, userRouter, wsManager)
+
+	// 就绪检查，本节点的合成探测连续失败、有组件不健康或正在排空时返回503
+	router.GET("/readyz", handleReadiness(canary, srv.lifecycle, srv.drainer))
+
+	// 监控指标
+	if cfg.Monitor.Enabled {
+		router.GET(cfg.Monitor.Path, gin.WrapH(promhttp.Handler()))
+	}
+
+	if cfg.Cluster.Routing.Enabled {
+		router.GET("/route", handleRoute(userRouter))
+	}
+
+	// WebSocket路由（业务节点不接入客户端连接）
+	if cfg.Cluster.Mode != config.ModeWorker {
+		router.GET("/ws", func(c *gin.Context) {
+			if cfg.Cluster.Routing.Enabled && cfg.Cluster.Routing.RedirectOnUpgrade {
+				if userID := c.Query("user_id"); userID != "" {
+					if home := userRouter.HomeNode(userID); home != nil && home.ID != cfg.Cluster.NodeID {
+						// 代理终结TLS时按可信代理的X-Forwarded-Proto保持客户端使用的协议
+						scheme := resolver.Scheme(c.Request)
+						c.Redirect(http.StatusTemporaryRedirect, fmt.Sprintf("%s://%s%s", scheme, home.Address, c.Request.URL.RequestURI()))
+						return
+					}
+				}
+			}
+			wsManager.HandleWebSocket(c.Writer, c.Request)
+		})
+	}
+
+	// 媒体下载代理，签名地址本身即凭证
+	if mediaService != nil {
+		router.GET("/media/:messageID", handleDownloadMedia(mediaService))
+	}
+
+	// 跨域联邦接口，远程域凭事件签名认证，不经过版本协商
+	if fed != nil {
+		router.GET(federation.DiscoveryPath, handleFederationDiscovery(fed))
+		router.POST("/federation/v1"+federation.InboxPath, handleFederationInbox(fed))
+	}
+
+	// 访客凭令牌只读访问群组消息，不经过版本协商，SSE响应不能缓冲
+	if guests != nil {
+		guest := router.Group("/guest/v1", guestAuth(guests))
+		guest.GET("/token", handleGuestToken())
+		guest.GET("/messages", handleGuestMessages(guests))
+		guest.GET("/events", handleGuestEvents(guests))
+	}
+
+	// API路由，各版本共用同一组处理函数，由版本协商层转换请求和响应格式
+	negotiator, err := api.NewNegotiator(cfg.API)
+	if err != nil {
+		return fmt.Errorf("failed to initialize API version negotiation: %w", err)
+	}
+	registerAPI := func(api *gin.RouterGroup) {
+		if messageService != nil {
+			// 消息相关API
+			api.POST("/messages", handleSendMessage(messageService))
+			api.GET("/messages/:messageID", handleGetMessage(messageService))
+			api.PUT("/messages/:messageID/star", handleStarMessage(messageService))
+			api.DELETE("/messages/:messageID/star", handleUnstarMessage(messageService))
+			api.POST("/messages/:messageID/ack", handleAckMessage(messageService))
+			api.GET("/messages/:messageID/poll", handleGetPoll(messageService))
+			api.GET("/messages/:messageID/receipts", handleGetReceipts(messageService))
+			api.DELETE("/messages/:messageID", handleDeleteMessage(messageService))
+			if mediaService != nil {
+				api.GET("/messages/:messageID/media", handleSignMediaURL(mediaService))
+			}
+
+			// 离线消息同步
+			api.GET("/messages/offline", handleSyncOfflineMessages(messageService))
+
+			// 收藏的消息
+			api.GET("/messages/starred", handleListStarredMessages(messageService))
+
+			// 非联系人发来的消息请求
+			api.GET("/message-requests", handleListMessageRequests(messageService))
+			api.POST("/message-requests/:userID/accept", handleAcceptMessageRequest(messageService))
+			api.DELETE("/message-requests/:userID", handleDeclineMessageRequest(messageService))
+
+			// 群组相关API
+			api.POST("/groups", handleCreateGroup(messageService))
+			api.GET("/groups/:groupID", handleGetGroup(messageService))
+			api.GET("/groups/:groupID/members", handleGetGroupMembers(messageService))
+			api.PUT("/groups/:groupID/members/me", handleSetMemberNickname(messageService))
+			api.POST("/groups/:groupID/members/:userID/mute", handleMuteMember(messageService))
+			api.POST("/groups/:groupID/join", handleJoinGroup(messageService))
+			api.POST("/groups/:groupID/leave", handleLeaveGroup(messageService))
+			api.POST("/groups/:groupID/upgrade", handleUpgradeGroup(messageService))
+			api.GET("/groups/:groupID/settings", handleGetGroupSettings(messageService))
+			api.PUT("/groups/:groupID/settings", handleUpdateGroupSettings(messageService))
+			api.GET("/groups/:groupID/messages", handleSyncChannelMessages(messageService))
+			api.POST("/groups/:groupID/cursor", handleMarkChannelRead(messageService))
+
+			// 会话消息按日期、发送者和类型查询，以及会话的媒体列表
+			api.GET("/conversations/:conversationID/messages", handleListConversationMessages(messageService))
+			api.GET("/conversations/:conversationID/media", handleListConversationMedia(messageService))
+		}
+
+		// 会话列表
+		if conversationService != nil {
+			api.GET("/conversations", handleListConversations(conversationService))
+			api.PUT("/conversations/:conversationID/settings", handleUpdateConversationSettings(conversationService))
+		}
+		api.GET("/conversations/:conversationID/draft", handleGetDraft(draftService))
+		api.PUT("/conversations/:conversationID/draft", handleSaveDraft(draftService))
+
+		// 举报消息或用户
+		if reportService != nil {
+			api.POST("/reports", handleCreateReport(reportService))
+		}
+
+		// 用户资料
+		api.GET("/users/me/profile", handleGetProfile(profileService))
+		api.PUT("/users/me/profile", handleUpdateProfile(profileService))
+		api.GET("/users/me/quiet-hours", handleGetQuietHours(profileService))
+		api.PUT("/users/me/quiet-hours", handleUpdateQuietHours(profileService))
+		api.GET("/users/me/privacy", handleGetPrivacy(profileService))
+		api.PUT("/users/me/privacy", handleUpdatePrivacy(profileService))
+		api.GET("/users/me/notification-preferences", handleGetNotificationPreferences(profileService))
+		api.PUT("/users/me/notification-preferences", handleUpdateNotificationPreferences(profileService))
+
+		// 偏好设置在多个设备间同步
+		api.GET("/settings", handleGetSettings(settingsService))
+		api.PUT("/settings", handleUpdateSettings(settingsService))
+
+		// 身份提供方登录
+		if auth != nil {
+			api.GET("/auth/oidc/config", handleGetOIDCConfig(auth))
+			api.POST("/auth/oidc/login", handleOIDCLogin(auth))
+		}
+
+		// 两步验证
+		if twoFactor != nil {
+			api.GET("/users/me/two-factor", handleGetTwoFactor(twoFactor))
+			api.POST("/users/me/two-factor/enroll", handleEnrollTwoFactor(twoFactor))
+			api.POST("/users/me/two-factor/activate", handleActivateTwoFactor(twoFactor))
+			api.POST("/users/me/two-factor/recovery-codes", handleRegenerateRecoveryCodes(twoFactor))
+			api.DELETE("/users/me/two-factor", handleDisableTwoFactor(twoFactor))
+		}
+
+		// 组织架构通讯录
+		if orgService != nil {
+			api.GET("/org/departments", handleGetOrgTree(orgService))
+			api.GET("/org/departments/:departmentID", handleGetDepartment(orgService))
+			api.GET("/org/users/:userID/departments", handleGetUserDepartments(orgService))
+		}
+
+		// 后端系统凭API密钥发送消息，与终端用户认证分开
+		if apiKeyService != nil {
+			api.POST("/service/messages", apiKeyAuth(apiKeyService), handleServiceSendMessage(apiKeyService))
+			api.POST("/service/messages/from-template", apiKeyAuth(apiKeyService), handleServiceSendTemplate(templates))
+		}
+
+		// 在线状态订阅
+		api.GET("/presence", handleGetPresence(presenceService))
+		api.POST("/presence/subscriptions", handleSubscribePresence(presenceService))
+		api.DELETE("/presence/subscriptions", handleUnsubscribePresence(presenceService))
+
+		// 媒体上传前预留存储配额
+		if quota != nil {
+			api.POST("/media/reservations", handleReserveMedia(quota))
+		}
+
+		// 大文件分片上传
+		if uploads != nil {
+			api.POST("/uploads", handleCreateUpload(uploads))
+			api.GET("/uploads/:uploadID", handleGetUpload(uploads))
+			api.PUT("/uploads/:uploadID", handleUploadChunk(uploads))
+			api.POST("/uploads/:uploadID/complete", handleCompleteUpload(uploads))
+			api.DELETE("/uploads/:uploadID", handleCancelUpload(uploads))
+		}
+
+		// 表情包
+		api.GET("/stickers/packs", handleListStickerPacks(stickers))
+		api.GET("/stickers/packs/:packID", handleGetStickerPack(stickers))
+
+		// 公众号
+		if officials != nil {
+			api.GET("/official-accounts", handleListOfficialAccounts(officials))
+			api.GET("/official-accounts/:accountID", handleGetOfficialAccount(officials))
+			api.POST("/official-accounts/:accountID/follow", handleFollowOfficialAccount(officials))
+			api.DELETE("/official-accounts/:accountID/follow", handleUnfollowOfficialAccount(officials))
+		}
+
+		// 统计信息
+		api.GET("/stats", handleGetStats(stats, registry, cfg.Cluster.Mode))
+	}
+	registerAPI(router.APIGroup("/api/v1", negotiator.Pin(api.V1)))
+	registerAPI(router.APIGroup("/api/v2", negotiator.Pin(api.V2)))
+	// 未带版本号时按请求头协商
+	registerAPI(router.APIGroup("/api", negotiator.Negotiate()))
+
+	// GraphQL查询接口与用户API共用认证和限流，字段名与v1一致，不经过版本协商
+	if cfg.GraphQL.Enabled && messageService != nil && conversationService != nil {
+		graphQLService := service.NewGraphQLService(messageService, conversationService, profileService, cfg.GraphQL)
+		router.APIGroup("/graphql").POST("", handleGraphQL(graphQLService))
+	}
+
+	// 管理API路由
+	adminRoles := service.NewAdminRoleService(redisStore)
+	require := adminPermissions(auditService)
+	admin := router.Group("/admin/v1", adminAuth(cfg.Admin.Token, tokens, adminRoles))
+	{
+		// 当前操作人的角色和权限，管理角色分配
+		admin.GET("/me", handleGetAdminSelf())
+		admin.GET("/roles", require(model.AdminPermRolesManage), handleListAdminRoles())
+		admin.GET("/admins", require(model.AdminPermRolesManage), handleListAdmins(adminRoles))
+		admin.PUT("/admins/:userID", require(model.AdminPermRolesManage), handleAssignAdminRoles(adminRoles, auditService))
+		admin.DELETE("/admins/:userID", require(model.AdminPermRolesManage), handleRevokeAdminRoles(adminRoles, auditService))
+
+		// 集群节点
+		admin.GET("/nodes", require(model.AdminPermClusterView), handleListNodes(registry))
+
+		// 排空本节点的连接
+		admin.GET("/drain", require(model.AdminPermClusterView), handleGetDrain(srv.drainer))
+		admin.POST("/drain", require(model.AdminPermClusterManage), handleStartDrain(srv, auditService))
+
+		// 本节点的组件状态
+		admin.GET("/components", require(model.AdminPermClusterView), handleListComponents(srv.lifecycle))
+
+		// 后台任务
+		if jobs != nil {
+			admin.GET("/jobs", require(model.AdminPermClusterView), handleListJobs(jobs))
+		}
+
+		// 用户全局处罚
+		admin.GET("/users/:userID/sanctions", require(model.AdminPermModerationView), handleGetSanctions(moderationService))
+		admin.POST("/users/:userID/sanctions", require(model.AdminPermModerationManage), handleCreateSanction(moderationService))
+		admin.DELETE("/users/:userID/sanctions/:type", require(model.AdminPermModerationManage), handleLiftSanction(moderationService))
+
+		// 强制下线
+		admin.POST("/users/:userID/disconnect", require(model.AdminPermUsersDisconnect), handleDisconnectUser(moderationService, auditService))
+
+		// 消息物理删除
+		if messageService != nil {
+			admin.DELETE("/messages/:messageID", require(model.AdminPermMessagesDelete), handlePurgeMessage(messageService))
+
+			// 离线队列排障，所有操作记录审计
+			admin.GET("/users/:userID/offline", require(model.AdminPermUsersView), handleInspectOfflineQueue(messageService, auditService))
+			admin.POST("/users/:userID/offline/:messageID/redeliver", require(model.AdminPermUsersManage), handleRedeliverMessage(messageService, auditService))
+			admin.DELETE("/users/:userID/offline", require(model.AdminPermUsersManage), handleClearOfflineQueue(messageService, auditService))
+		}
+
+		// 举报审核，处理操作记录审计
+		if reportService != nil {
+			admin.GET("/reports", require(model.AdminPermModerationView), handleListReports(reportService))
+			admin.GET("/reports/:reportID", require(model.AdminPermModerationView), handleGetReport(reportService))
+			admin.POST("/reports/:reportID/resolve", require(model.AdminPermModerationManage), handleResolveReport(reportService, auditService))
+		}
+
+		// 服务间调用API密钥
+		if apiKeyService != nil {
+			admin.GET("/api-keys", require(model.AdminPermConfigView), handleListAPIKeys(apiKeyService))
+			admin.POST("/api-keys", require(model.AdminPermConfigManage), handleCreateAPIKey(apiKeyService, twoFactor))
+			admin.DELETE("/api-keys/:keyID", require(model.AdminPermConfigManage), handleRevokeAPIKey(apiKeyService))
+		}
+
+		// 服务消息模板
+		if templates != nil {
+			admin.GET("/message-templates", require(model.AdminPermConfigView), handleListTemplates(templates))
+			admin.GET("/message-templates/:templateID", require(model.AdminPermConfigView), handleGetTemplate(templates))
+			admin.PUT("/message-templates/:templateID", require(model.AdminPermConfigManage), handleSetTemplate(templates, auditService))
+			admin.DELETE("/message-templates/:templateID", require(model.AdminPermConfigManage), handleDeleteTemplate(templates, auditService))
+		}
+
+		// 只读访客令牌
+		if guests != nil {
+			admin.GET("/groups/:groupID/guest-tokens", require(model.AdminPermModerationView), handleListGuestTokens(guests))
+			admin.POST("/groups/:groupID/guest-tokens", require(model.AdminPermModerationManage), handleCreateGuestToken(guests, auditService))
+			admin.DELETE("/guest-tokens/:tokenID", require(model.AdminPermModerationManage), handleRevokeGuestToken(guests, auditService))
+		}
+
+		if twoFactor != nil {
+			admin.DELETE("/users/:userID/two-factor", require(model.AdminPermUsersManage), handleResetTwoFactor(twoFactor, auditService))
+		}
+
+		// 企业目录同步
+		if orgService != nil {
+			admin.PUT("/org", require(model.AdminPermConfigManage), handleSyncOrg(orgService, auditService))
+			admin.POST("/org/groups/sync", require(model.AdminPermConfigManage), handleSyncDepartmentGroups(orgService, auditService))
+		}
+
+		// 管理操作审计
+		admin.GET("/audit-logs", require(model.AdminPermAuditView), handleListAuditLogs(auditService))
+		admin.GET("/users/:userID/client", require(model.AdminPermUsersView), handleGetClientCapabilities(clientService))
+		admin.GET("/users/:userID/sessions", require(model.AdminPermUsersView), handleListSessions(sessions))
+		admin.GET("/users/:userID/bandwidth", require(model.AdminPermUsersView), handleGetUserBandwidth(bandwidth))
+		if fed != nil {
+			admin.GET("/federation/queues", require(model.AdminPermClusterView), handleListFederationQueues(fed))
+		}
+
+		// 客户端配置
+		admin.GET("/client-config", require(model.AdminPermConfigView), handleGetClientConfig(clientConfig))
+		admin.PUT("/client-config/features/:name", require(model.AdminPermConfigManage), handleSetClientFeature(clientConfig, auditService))
+		admin.DELETE("/client-config/features/:name", require(model.AdminPermConfigManage), handleResetClientFeature(clientConfig, auditService))
+		admin.GET("/feature-flags", require(model.AdminPermConfigView), handleListFeatureFlags(flags))
+		admin.GET("/feature-flags/:name/users/:userID", require(model.AdminPermConfigView), handleEvaluateFeatureFlag(flags))
+		admin.PUT("/feature-flags/:name", require(model.AdminPermConfigManage), handleSetFeatureFlag(flags, auditService))
+		admin.DELETE("/feature-flags/:name", require(model.AdminPermConfigManage), handleResetFeatureFlag(flags, auditService))
+
+		// 表情包
+		admin.GET("/sticker-packs", require(model.AdminPermConfigView), handleAdminListStickerPacks(stickers))
+		admin.PUT("/sticker-packs/:packID", require(model.AdminPermConfigManage), handleSetStickerPack(stickers, auditService))
+		admin.DELETE("/sticker-packs/:packID", require(model.AdminPermConfigManage), handleDeleteStickerPack(stickers, auditService))
+
+		// 公众号
+		if officials != nil {
+			admin.GET("/official-accounts", require(model.AdminPermConfigView), handleAdminListOfficialAccounts(officials))
+			admin.PUT("/official-accounts/:accountID", require(model.AdminPermConfigManage), handleSetOfficialAccount(officials, auditService))
+			admin.DELETE("/official-accounts/:accountID", require(model.AdminPermConfigManage), handleDeleteOfficialAccount(officials, auditService))
+			admin.POST("/official-accounts/:accountID/broadcast", require(model.AdminPermConfigManage), handleOfficialBroadcast(officials, auditService))
+		}
+
+		// 敏感词库
+		if words != nil {
+			admin.GET("/word-filter", require(model.AdminPermConfigView), handleGetWordFilter(words))
+			admin.POST("/word-filter/reload", require(model.AdminPermConfigManage), handleReloadWordFilter(words, auditService))
+		}
+
+		// 用户和租户配额
+		if quota != nil {
+			admin.GET("/quotas/:kind/:id", require(model.AdminPermConfigView), handleGetQuota(quota))
+			admin.PUT("/quotas/:kind/:id", require(model.AdminPermConfigManage), handleSetQuota(quota, auditService))
+			admin.DELETE("/quotas/:kind/:id", require(model.AdminPermConfigManage), handleResetQuota(quota, auditService))
+		}
+
+		// 媒体存储用量排行
+		if mediaStorage != nil {
+			admin.GET("/media-storage/:kind", require(model.AdminPermClusterView), handleTopMediaConsumers(mediaStorage))
+		}
+
+		// 运营统计
+		if analytics != nil && mysqlStore != nil {
+			admin.GET("/analytics", require(model.AdminPermClusterView), handleGetAnalytics(analytics))
+		}
+		if latency != nil {
+			admin.GET("/analytics/delivery", require(model.AdminPermClusterView), handleGetDeliverySLO(latency))
+		}
+	}
+
+	// 创建HTTP服务器
+	srv.httpServer = &http.Server{
+		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+	}
+
+	// 监控端口上的诊断接口
+	if cfg.Monitor.Enabled && cfg.Monitor.Diagnostics {
+		srv.monitorServer = newMonitorServer(cfg, wsManager)
+	}
+	return nil
+}
+
+// Handler 返回HTTP处理器，供测试直接发起请求
+func (srv *Server) Handler() http.Handler {
+	return srv.httpServer.Handler
+}
+
+// Addr 返回HTTP服务实际监听的地址，Start之前为配置的地址
+func (srv *Server) Addr() string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.listener != nil {
+		return srv.listener.Addr().String()
+	}
+	return srv.httpServer.Addr
+}
+
+// Start 按添加顺序启动组件，然后开始监听HTTP端口，监听成功后返回
+// 启动失败时已启动的部分需调用Shutdown停止；ctx只用于启动过程，不影响之后的运行
+func (srv *Server) Start(ctx context.Context) error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if err := srv.lifecycle.Start(ctx); err != nil {
+		return err
+	}
+	go srv.lifecycle.Watch(srv.ctx, srv.cfg.Components.HealthInterval)
+
+	if srv.listener == nil {
+		listener, err := net.Listen("tcp", srv.httpServer.Addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", srv.httpServer.Addr, err)
+		}
+		srv.listener = listener
+	}
+	logger.Info("Starting HTTP server", logger.String("addr", srv.listener.Addr().String()))
+	go func(listener net.Listener) {
+		if err := srv.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error("HTTP server stopped", logger.ErrorField(err))
+		}
+	}(srv.listener)
+
+	if srv.monitorServer != nil {
+		go func() {
+			logger.Info("Starting monitor server", logger.String("addr", srv.monitorServer.Addr))
+			if err := srv.monitorServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("Failed to start monitor server", logger.ErrorField(err))
+			}
+		}()
+	}
+	return nil
+}
+
+// Drain 开始排空本节点的连接，已经开始时返回false
+func (srv *Server) Drain() bool {
+	return srv.drainer.Start(srv.ctx)
+}
+
+// Drained 排空结束时关闭，之后可以调用Shutdown
+func (srv *Server) Drained() <-chan struct{} {
+	return srv.drainer.Done()
+}
+
+// Shutdown 停止接收新请求并等待处理中的请求完成，然后关闭所有WebSocket连接，
+// 按与启动相反的顺序停止后台任务和存储；ctx到期时不再等待处理中的请求
+func (srv *Server) Shutdown(ctx context.Context) error {
+	var shutdownErr error
+	if err := srv.httpServer.Shutdown(ctx); err != nil {
+		shutdownErr = fmt.Errorf("failed to shut down HTTP server: %w", err)
+	}
+	if srv.monitorServer != nil {
+		srv.monitorServer.Shutdown(ctx)
+	}
+
+	// 关闭所有WebSocket连接
+	srv.wsManager.CloseAll()
+
+	srv.cancel()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if err := srv.lifecycle.Stop(); err != nil && shutdownErr == nil {
+		shutdownErr = err
+	}
+	return shutdownErr
+}
+
+// newIDGenerator 创建组件的ID生成器，未单独配置的组件使用全局生成器
+func newIDGenerator(cfg config.IDConfig, component string) (idgen.Generator, error) {
+	spec, ok := cfg.Override(component)
+	if !ok {
+		return idgen.Default(), nil
+	}
+	generator, err := idgen.New(spec.Strategy, spec.MachineID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize %s ID generator: %w", component, err)
+	}
+	return generator, nil
+}
+
+// startKafkaConsumers 启动Kafka消费者
+// 消费者重启后Kafka会重投未提交的消息，推送前按消息ID去重
+func startKafkaConsumers(kafkaStore *store.KafkaStore, redisStore *store.RedisStore, messageService *service.MessageService, previewTopic, voiceTopic string, dedupTTL time.Duration) {
+	// 消费离线消息，接收者已上线时推送
+	offlineDedup := store.NewDeduplicator(redisStore, "offline", dedupTTL)
+	go func() {
+		if err := kafkaStore.ConsumeOfflineMessages(offlineDedup.Messages(messageService.DeliverQueuedMessage)); err != nil {
+			logger.Error("Failed to consume offline messages", logger.ErrorField(err))
+		}
+	}()
+
+	// 消费群聊消息
+	groupDedup := store.NewDeduplicator(redisStore, "group_fanout", dedupTTL)
+	go func() {
+		if err := kafkaStore.ConsumeGroupMessages(groupDedup.Messages(func(message *model.Message) error {
+			// 超大群消息在这里分批扇出，普通群已在发送时直接广播
+			return messageService.FanoutGroupMessage(message)
+		})); err != nil {
+			logger.Error("Failed to consume group messages", logger.ErrorField(err))
+		}
+	}()
+
+	// 异步抓取链接预览
+	if previewTopic != "" {
+		go func() {
+			if err := kafkaStore.ConsumeMessages(previewTopic, messageService.EnrichMessage); err != nil {
+				logger.Error("Failed to consume link preview requests", logger.ErrorField(err))
+			}
+		}()
+	}
+
+	// 异步提取语音消息的时长和波形
+	if voiceTopic != "" {
+		go func() {
+			if err := kafkaStore.ConsumeMessages(voiceTopic, messageService.ProcessVoiceMessage); err != nil {
+				logger.Error("Failed to consume voice processing requests", logger.ErrorField(err))
+			}
+		}()
+	}
+}
+
+// reportClientVersions 按当前会话重建客户端版本分布，已下线的版本随之消失
+func reportClientVersions(wsManager *websocket.Manager) {
+	metrics.ClientSessions.Reset()
+	for version, count := range wsManager.GetClientVersionCounts() {
+		platform, appVersion := version.Platform, version.AppVersion
+		if platform == "" {
+			platform = "unknown"
+		}
+		if appVersion == "" {
+			appVersion = "unknown"
+		}
+		metrics.ClientSessions.WithLabelValues(platform, appVersion).Set(float64(count))
+	}
+}
+
+// startHeartbeatChecker 启动心跳检测
+// 同时上报本节点连接数，供统计采样全集群的连接峰值
+func startHeartbeatChecker(ctx context.Context, wsManager *websocket.Manager, analytics *service.AnalyticsService, nodeID string) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// 检查连接状态
+		connectionCount := wsManager.GetConnectionCount()
+		onlineUserCount := wsManager.GetOnlineUserCount()
+
+		logger.Debug("Heartbeat check",
+			logger.Int("connections", connectionCount),
+			logger.Int("online_users", onlineUserCount))
+		analytics.ReportConnections(nodeID, connectionCount)
+		reportClientVersions(wsManager)
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/config"
+	"github.com/user/im/internal/lifecycle"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+	"github.com/user/im/pkg/websocket"
+)
+
+func newTestServer(t *testing.T) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		cfg:        &config.Config{Components: config.ComponentsConfig{HealthInterval: time.Minute}},
+		listener:   listener,
+		wsManager:  websocket.NewManager(),
+		httpServer: &http.Server{Handler: http.NotFoundHandler()},
+		ctx:        ctx,
+		cancel:     cancel,
+		lifecycle:  lifecycle.New(nil),
+	}
+}
+
+func TestServerComponents(t *testing.T) {
+	srv := newTestServer(t)
+	var events []string
+	record := func(event string) func() error {
+		return func() error {
+			events = append(events, event)
+			return nil
+		}
+	}
+	srv.resource("store", record("close store"), nil)
+	srv.component("registry", record("start registry"), record("stop registry"))
+	srv.component("jobs", record("start jobs"), nil)
+
+	assert.NoError(t, srv.Start(context.Background()))
+	assert.NotEqual(t, "127.0.0.1:0", srv.Addr())
+	assert.NoError(t, srv.Shutdown(context.Background()))
+	assert.Equal(t, []string{"start registry", "start jobs", "stop registry", "close store"}, events)
+	assert.Error(t, srv.ctx.Err())
+}
+
+func TestServerStartFailure(t *testing.T) {
+	srv := newTestServer(t)
+	srv.component("registry", func() error { return errors.New("boom") }, nil)
+
+	assert.EqualError(t, srv.Start(context.Background()), "failed to start registry: boom")
+	assert.NoError(t, srv.Shutdown(context.Background()))
+}
+
+func TestReadinessComponents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	components := lifecycle.New(nil)
+	healthErr := errors.New("connection refused")
+	components.Append(lifecycle.Component{Name: "redis", Health: func() error { return healthErr }})
+	router := gin.New()
+	router.GET("/readyz", handleReadiness(nil, components, nil))
+
+	readiness := func() int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w.Code
+	}
+	assert.Equal(t, 200, readiness())
+	components.CheckHealth()
+	assert.Equal(t, 503, readiness())
+	healthErr = nil
+	components.CheckHealth()
+	assert.Equal(t, 200, readiness())
+}
+
+func TestAdminPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	require := adminPermissions(service.NewAuditService(nil))
+	router := gin.New()
+	router.Use(adminAuth("secret", nil, nil))
+	router.Use(func(c *gin.Context) {
+		if role := c.GetHeader("X-Test-Role"); role != "" {
+			c.Set(adminRolesKey, []string{role})
+		}
+	})
+	ok := func(c *gin.Context) { c.JSON(200, gin.H{"success": true}) }
+	router.GET("/users/:userID/sessions", require(model.AdminPermUsersView), ok)
+	router.POST("/users/:userID/disconnect", require(model.AdminPermUsersDisconnect), ok)
+	router.DELETE("/messages/:messageID", require(model.AdminPermMessagesDelete), ok)
+
+	request := func(method, path, role string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Admin-Token", "secret")
+		req.Header.Set("X-Test-Role", role)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	// 静态令牌视为超级管理员
+	assert.Equal(t, 200, request("DELETE", "/messages/m1", ""))
+	assert.Equal(t, 200, request("GET", "/users/u1/sessions", model.RoleSupport))
+	assert.Equal(t, 200, request("POST", "/users/u1/disconnect", model.RoleSupport))
+	assert.Equal(t, 403, request("DELETE", "/messages/m1", model.RoleSupport))
+	assert.Equal(t, 200, request("DELETE", "/messages/m1", model.RoleModerator))
+	assert.Equal(t, 200, request("GET", "/users/u1/sessions", model.RoleAuditor))
+	assert.Equal(t, 403, request("POST", "/users/u1/disconnect", model.RoleAuditor))
+	assert.Equal(t, 403, request("POST", "/users/u1/disconnect", model.RoleOperator))
+}
+
+func TestHandshakeUser(t *testing.T) {
+	tokens := service.NewTokenService(config.AuthConfig{JWTSecret: "secret", Issuer: "im", TokenTTL: time.Hour})
+	token, err := tokens.Issue("alice", nil)
+	assert.NoError(t, err)
+
+	// 未带令牌或令牌无效时不认定用户，user_id参数不再生效
+	for _, target := range []string{"/ws", "/ws?user_id=alice", "/ws?token=forged"} {
+		_, ok := handshakeUser(tokens, httptest.NewRequest("GET", target, nil))
+		assert.False(t, ok, target)
+	}
+
+	r := httptest.NewRequest("GET", "/ws?token="+token.AccessToken, nil)
+	userID, ok := handshakeUser(tokens, r)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", userID)
+
+	r = httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	userID, ok = handshakeUser(tokens, r)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", userID)
+}
+
+func TestHandleGraphQL_RequestSize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/graphql", handleGraphQL(nil))
+
+	// 超出大小的请求体在执行查询前拒绝
+	body := `{"query":"{ me { user_id } }","variables":{"pad":"` + strings.Repeat("x", maxGraphQLRequestSize) + `"}}`
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	req.Header.Set("X-User-ID", "u1")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
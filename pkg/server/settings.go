@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+func handleGetSettings(settingsService *service.SettingsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		settings, err := settingsService.GetSettings(userID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		etag := settingsETag(settings)
+		c.Header("ETag", etag)
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(304)
+			return
+		}
+		c.JSON(200, gin.H{"settings": settings})
+	}
+}
+
+func handleUpdateSettings(settingsService *service.SettingsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			Values map[string]json.RawMessage `json:"values" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		expected, err := parseIfMatch(c.GetHeader("If-Match"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		settings, err := settingsService.UpdateSettings(userID, req.Values, expected)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.Header("ETag", settingsETag(settings))
+		c.JSON(200, gin.H{"settings": settings})
+	}
+}
+
+// settingsETag 以设置版本作为ETag
+func settingsETag(settings *model.UserSettings) string {
+	return strconv.Quote(strconv.FormatInt(settings.Version, 10))
+}
+
+// parseIfMatch 解析If-Match中的设置版本，为空或*时不检查版本
+func parseIfMatch(header string) (*int64, error) {
+	header = strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if err != nil || version < 0 {
+		return nil, fmt.Errorf("invalid If-Match %q, expected a settings version", header)
+	}
+	return &version, nil
+}
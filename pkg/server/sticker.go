@@ -0,0 +1,103 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+func handleListStickerPacks(stickers *service.StickerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		c.JSON(200, gin.H{"packs": stickers.Packs(userID)})
+	}
+}
+
+func handleGetStickerPack(stickers *service.StickerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		pack, err := stickers.Pack(userID, c.Param("packID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"pack": pack})
+	}
+}
+
+func handleAdminListStickerPacks(stickers *service.StickerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{"packs": stickers.List(c.Query("tenant"))})
+	}
+}
+
+func handleSetStickerPack(stickers *service.StickerService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Name     string                `json:"name"`
+			Kind     model.StickerPackKind `json:"kind"`
+			Tenant   string                `json:"tenant"`
+			CoverURL string                `json:"cover_url"`
+			Stickers []model.Sticker       `json:"stickers"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		pack := &model.StickerPack{
+			ID:       c.Param("packID"),
+			Name:     req.Name,
+			Kind:     req.Kind,
+			Tenant:   req.Tenant,
+			CoverURL: req.CoverURL,
+			Stickers: req.Stickers,
+		}
+		if err := stickers.SetPack(pack); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionSetStickerPack, pack.ID, map[string]string{
+			"kind":     string(pack.Kind),
+			"tenant":   pack.Tenant,
+			"stickers": strconv.Itoa(len(pack.Stickers)),
+		})
+
+		c.JSON(200, gin.H{"success": true, "pack": pack})
+	}
+}
+
+func handleDeleteStickerPack(stickers *service.StickerService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		packID := c.Param("packID")
+
+		if err := stickers.DeletePack(packID); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionDeleteStickerPack, packID, nil)
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+func handleServiceSendTemplate(templates *service.TemplateService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.MustGet("api_key").(*model.APIKey)
+
+		var req struct {
+			ConversationID string            `json:"conversation_id" binding:"required"`
+			TemplateID     string            `json:"template_id" binding:"required"`
+			Variables      map[string]string `json:"variables"`
+			Priority       string            `json:"priority"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		priority, err := model.ParseMessagePriority(req.Priority)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		message, err := templates.Send(key, req.ConversationID, req.TemplateID, req.Variables, priority)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"message": message,
+		})
+	}
+}
+
+func handleListTemplates(templates *service.TemplateService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := templates.ListTemplates()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"templates": list})
+	}
+}
+
+func handleGetTemplate(templates *service.TemplateService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		template, err := templates.GetTemplate(c.Param("templateID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"template": template})
+	}
+}
+
+func handleSetTemplate(templates *service.TemplateService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Name      string                   `json:"name"`
+			Type      model.MessageType        `json:"type"`
+			Content   string                   `json:"content"`
+			Variables []model.TemplateVariable `json:"variables"`
+			RateLimit int                      `json:"rate_limit"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		template := &model.MessageTemplate{
+			ID:        c.Param("templateID"),
+			Name:      req.Name,
+			Type:      req.Type,
+			Content:   req.Content,
+			Variables: req.Variables,
+			RateLimit: req.RateLimit,
+		}
+		if err := templates.SetTemplate(template); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionSetMessageTemplate, template.ID, map[string]string{
+			"type":       string(template.Type),
+			"rate_limit": strconv.Itoa(template.RateLimit),
+		})
+
+		c.JSON(200, gin.H{"success": true, "template": template})
+	}
+}
+
+func handleDeleteTemplate(templates *service.TemplateService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		templateID := c.Param("templateID")
+
+		if err := templates.DeleteTemplate(templateID); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionDeleteMessageTemplate, templateID, nil)
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+func handleGetTwoFactor(twoFactor *service.TwoFactorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		status, err := twoFactor.Status(userID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"two_factor": status})
+	}
+}
+
+func handleEnrollTwoFactor(twoFactor *service.TwoFactorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		enrollment, err := twoFactor.Enroll(userID)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		// 共享密钥只在绑定时返回
+		c.JSON(200, gin.H{"enrollment": enrollment})
+	}
+}
+
+func handleActivateTwoFactor(twoFactor *service.TwoFactorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		codes, err := twoFactor.Activate(userID, req.Code)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		// 恢复码明文只在生成时返回
+		c.JSON(200, gin.H{
+			"success":        true,
+			"recovery_codes": codes,
+		})
+	}
+}
+
+func handleRegenerateRecoveryCodes(twoFactor *service.TwoFactorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		codes, err := twoFactor.RegenerateRecoveryCodes(userID, req.Code)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"recovery_codes": codes})
+	}
+}
+
+func handleDisableTwoFactor(twoFactor *service.TwoFactorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := twoFactor.Disable(userID, req.Code); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+func handleResetTwoFactor(twoFactor *service.TwoFactorService, auditService *service.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := adminActor(c)
+		if !ok {
+			return
+		}
+		userID := c.Param("userID")
+
+		if err := twoFactor.Reset(userID); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+		recordAudit(c, auditService, actor, model.AuditActionResetTwoFactor, userID, nil)
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
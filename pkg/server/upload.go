@@ -0,0 +1,126 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/internal/service"
+)
+
+// 分片上传的请求头，与tus协议一致
+const (
+	headerUploadOffset   = "Upload-Offset"
+	headerUploadChecksum = "Upload-Checksum"
+)
+
+func handleCreateUpload(uploads *service.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		var req struct {
+			Filename    string `json:"filename" binding:"required"`
+			ContentType string `json:"content_type"`
+			Size        int64  `json:"size" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		status, err := uploads.Create(userID, req.Filename, req.ContentType, req.Size)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		respondUploadStatus(c, status)
+	}
+}
+
+func handleGetUpload(uploads *service.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		status, err := uploads.Status(userID, c.Param("uploadID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		respondUploadStatus(c, status)
+	}
+}
+
+func handleUploadChunk(uploads *service.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		offset, err := strconv.ParseInt(c.GetHeader(headerUploadOffset), 10, 64)
+		if err != nil || offset < 0 {
+			c.JSON(400, gin.H{"error": "Upload-Offset header required"})
+			return
+		}
+
+		status, err := uploads.WriteChunk(userID, c.Param("uploadID"), offset, c.GetHeader(headerUploadChecksum), c.Request.Body)
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		respondUploadStatus(c, status)
+	}
+}
+
+func handleCompleteUpload(uploads *service.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		file, err := uploads.Complete(userID, c.Param("uploadID"))
+		if err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"file": file})
+	}
+}
+
+func handleCancelUpload(uploads *service.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetHeader("X-User-ID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": "User ID required"})
+			return
+		}
+
+		if err := uploads.Cancel(userID, c.Param("uploadID")); err != nil {
+			respondServiceError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// respondUploadStatus 返回上传进度，同时在Upload-Offset头中返回已接收的字节数
+func respondUploadStatus(c *gin.Context, status *model.UploadStatus) {
+	c.Header(headerUploadOffset, strconv.FormatInt(status.Offset, 10))
+	c.JSON(200, gin.H{"upload": status})
+}
@@ -0,0 +1,174 @@
+package websocket
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AdmissionResult 握手准入结果
+type AdmissionResult string
+
+const (
+	AdmissionAccepted AdmissionResult = "accepted" // 普通额度内接受
+	AdmissionPriority AdmissionResult = "priority" // 普通额度用尽，使用保留额度接受
+	AdmissionRejected AdmissionResult = "rejected" // 拒绝，客户端按retry_after重连
+)
+
+// AdmissionOptions 握手准入控制，节点重启后大量客户端同时重连时按令牌桶限制接受握手的速率
+type AdmissionOptions struct {
+	// Rate 每秒接受的握手数，0表示不限制
+	Rate float64
+	// Burst 令牌桶容量，即空闲后可以一次接受的握手数
+	Burst int
+	// PriorityReserve 令牌桶中只供优先客户端使用的比例
+	PriorityReserve float64
+	// MinRetryAfter、MaxRetryAfter 下发给被拒绝客户端的重连等待时间范围
+	MinRetryAfter time.Duration
+	MaxRetryAfter time.Duration
+	// Priority 普通额度用尽时判断请求能否使用保留额度，如用户有待投递的紧急消息；nil表示没有优先客户端
+	Priority func(r *http.Request) bool
+	// OnDecision 每次准入判断后回调，用于统计
+	OnDecision func(result AdmissionResult)
+}
+
+// admission 握手令牌桶
+type admission struct {
+	opts AdmissionOptions
+	now  func() time.Time
+	rand func(n int64) int64
+
+	mu      sync.Mutex
+	tokens  float64
+	updated time.Time
+	// backlog 已拒绝的客户端按接受速率排到的最晚时间，新拒绝的客户端在此之前随机选择重连时间
+	backlog time.Time
+}
+
+// newAdmission 创建握手令牌桶，初始为满
+func newAdmission(opts AdmissionOptions) *admission {
+	if opts.Burst < 1 {
+		opts.Burst = 1
+	}
+	return &admission{
+		opts:    opts,
+		now:     time.Now,
+		rand:    rand.Int63n,
+		tokens:  float64(opts.Burst),
+		updated: time.Now(),
+	}
+}
+
+// SetAdmission 开启握手准入控制，Rate为0时关闭
+func (m *Manager) SetAdmission(opts AdmissionOptions) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if opts.Rate <= 0 {
+		m.admission = nil
+		return
+	}
+	m.admission = newAdmission(opts)
+}
+
+// admit 判断能否接受握手，拒绝时返回建议的重连等待时间
+func (m *Manager) admit(r *http.Request) (time.Duration, bool) {
+	m.mu.RLock()
+	a := m.admission
+	m.mu.RUnlock()
+	if a == nil {
+		return 0, true
+	}
+
+	result, priority := AdmissionAccepted, false
+	ok := a.take(false)
+	if !ok && a.opts.Priority != nil && a.opts.Priority(r) {
+		result, priority = AdmissionPriority, true
+		ok = a.take(true)
+	}
+	var retryAfter time.Duration
+	if !ok {
+		result = AdmissionRejected
+		retryAfter = a.retryAfter(priority)
+	}
+	if a.opts.OnDecision != nil {
+		a.opts.OnDecision(result)
+	}
+	return retryAfter, ok
+}
+
+// take 取一个令牌，普通客户端不能使用保留额度
+func (a *admission) take(priority bool) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	burst := float64(a.opts.Burst)
+	a.tokens = math.Min(burst, a.tokens+now.Sub(a.updated).Seconds()*a.opts.Rate)
+	a.updated = now
+
+	floor := 0.0
+	if !priority {
+		floor = burst * a.opts.PriorityReserve
+	}
+	if a.tokens-1 < floor {
+		return false
+	}
+	a.tokens--
+	return true
+}
+
+// retryAfter 为被拒绝的客户端选择重连等待时间：每拒绝一个客户端，排队窗口按接受速率延长一个间隔，
+// 客户端在窗口内随机选择重连时间，同一批被拒绝的客户端因此按节点能接受的速率分散重连；
+// 优先客户端不排队，只在最短等待时间上加少量抖动
+func (a *admission) retryAfter(priority bool) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	minWait, maxWait := a.opts.MinRetryAfter, a.opts.MaxRetryAfter
+	if priority {
+		return minWait + a.jitter(minWait)
+	}
+
+	now := a.now()
+	if a.backlog.Before(now) {
+		a.backlog = now
+	}
+	a.backlog = a.backlog.Add(time.Duration(float64(time.Second) / a.opts.Rate))
+	window := a.backlog.Sub(now)
+	if window > maxWait-minWait {
+		window = maxWait - minWait
+		a.backlog = now.Add(window)
+	}
+	return minWait + a.jitter(window)
+}
+
+// jitter 返回[0, d]内的随机时长
+func (a *admission) jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(a.rand(int64(d) + 1))
+}
+
+// retryAfterSeconds 重连等待时间向上取整到秒
+func retryAfterSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// rejectHandshake 在升级连接前拒绝握手，返回503，响应体和Retry-After头带重连等待秒数
+// 浏览器读不到握手失败的响应，只能看到连接失败，应按自身的退避策略重连
+func rejectHandshake(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := retryAfterSeconds(retryAfter)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       "Too many connections, retry later",
+		"code":        "reconnect_throttled",
+		"retry_after": seconds,
+	})
+}
@@ -0,0 +1,123 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAdmission(opts AdmissionOptions) (*admission, *time.Time) {
+	now := time.Unix(1704067200, 0)
+	a := newAdmission(opts)
+	a.now = func() time.Time { return now }
+	a.updated = now
+	// 抖动取上限，便于断言排队窗口
+	a.rand = func(n int64) int64 { return n - 1 }
+	return a, &now
+}
+
+func TestAdmission_TokenBucket(t *testing.T) {
+	a, now := newTestAdmission(AdmissionOptions{Rate: 10, Burst: 10, PriorityReserve: 0.2, MinRetryAfter: time.Second, MaxRetryAfter: 5 * time.Second})
+
+	// 普通客户端只能用到保留额度之上
+	for i := 0; i < 8; i++ {
+		assert.True(t, a.take(false))
+	}
+	assert.False(t, a.take(false))
+	assert.True(t, a.take(true))
+	assert.True(t, a.take(true))
+	assert.False(t, a.take(true))
+
+	// 按速率补充，最多补满
+	*now = now.Add(100 * time.Millisecond)
+	assert.False(t, a.take(false))
+	assert.True(t, a.take(true))
+	*now = now.Add(time.Hour)
+	for i := 0; i < 8; i++ {
+		assert.True(t, a.take(false))
+	}
+	assert.False(t, a.take(false))
+}
+
+func TestAdmission_RetryAfterSpread(t *testing.T) {
+	a, now := newTestAdmission(AdmissionOptions{Rate: 10, Burst: 1, MinRetryAfter: time.Second, MaxRetryAfter: 3 * time.Second})
+
+	// 每拒绝一个客户端，重连窗口延长一个接受间隔
+	assert.Equal(t, time.Second+100*time.Millisecond, a.retryAfter(false))
+	assert.Equal(t, time.Second+200*time.Millisecond, a.retryAfter(false))
+	for i := 0; i < 50; i++ {
+		a.retryAfter(false)
+	}
+	// 窗口超出时封顶
+	assert.Equal(t, 3*time.Second, a.retryAfter(false))
+	// 时间推移后窗口收缩
+	*now = now.Add(2 * time.Second)
+	assert.Equal(t, time.Second+100*time.Millisecond, a.retryAfter(false))
+	// 优先客户端不排队
+	assert.Equal(t, 2*time.Second, a.retryAfter(true))
+}
+
+func TestManager_AdmissionPriority(t *testing.T) {
+	m := NewManager()
+	var results []AdmissionResult
+	m.SetAdmission(AdmissionOptions{
+		Rate: 0.001, Burst: 2, PriorityReserve: 0.5, MinRetryAfter: time.Second, MaxRetryAfter: time.Minute,
+		Priority:   func(r *http.Request) bool { return r.URL.Query().Get("user_id") == "vip" },
+		OnDecision: func(result AdmissionResult) { results = append(results, result) },
+	})
+
+	normal := httptest.NewRequest("GET", "/ws?user_id=alice", nil)
+	vip := httptest.NewRequest("GET", "/ws?user_id=vip", nil)
+	_, ok := m.admit(normal)
+	assert.True(t, ok)
+	retryAfter, ok := m.admit(normal)
+	assert.False(t, ok)
+	assert.GreaterOrEqual(t, retryAfter, time.Second)
+	_, ok = m.admit(vip)
+	assert.True(t, ok)
+	_, ok = m.admit(vip)
+	assert.False(t, ok)
+	assert.Equal(t, []AdmissionResult{AdmissionAccepted, AdmissionRejected, AdmissionPriority, AdmissionRejected}, results)
+
+	m.SetAdmission(AdmissionOptions{})
+	_, ok = m.admit(normal)
+	assert.True(t, ok)
+}
+
+func TestWebSocketTransport_RejectsOverAdmission(t *testing.T) {
+	m := NewManager()
+	m.SetAdmission(AdmissionOptions{Rate: 0.001, Burst: 1, MinRetryAfter: 2 * time.Second, MaxRetryAfter: 2 * time.Second})
+	server := httptest.NewServer(http.HandlerFunc(m.HandleWebSocket))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	client, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	client.Close()
+
+	// 升级连接前返回503，浏览器客户端同样不完成握手
+	for _, header := range []http.Header{nil, {"Origin": []string{"https://app.example.com"}}} {
+		_, resp, err := websocket.DefaultDialer.Dial(url, header)
+		assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+		if !assert.NotNil(t, resp) {
+			continue
+		}
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+		var body struct {
+			Code       string `json:"code"`
+			RetryAfter int64  `json:"retry_after"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "reconnect_throttled", body.Code)
+		assert.Equal(t, int64(2), body.RetryAfter)
+	}
+}
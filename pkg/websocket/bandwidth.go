@@ -0,0 +1,108 @@
+package websocket
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Traffic 会话自建立以来收发的字节数，按帧载荷计算，不含WebSocket帧头
+type Traffic struct {
+	BytesIn   int64
+	BytesOut  int64
+	RateLimit int64 // 当前的下行限速（字节/秒），0表示不限制
+}
+
+// Metered 统计流量并支持下行限速的会话
+type Metered interface {
+	// Traffic 会话收发的字节数
+	Traffic() Traffic
+	// SetRateLimit 设置下行限速（字节/秒），0表示不限制，立即对排队中的帧生效
+	SetRateLimit(bytesPerSecond int64)
+}
+
+// trafficCounter 连接收发字节计数，读写协程并发累加
+type trafficCounter struct {
+	in  atomic.Int64
+	out atomic.Int64
+}
+
+// tokenBucket 写协程使用的令牌桶，突发上限为一秒的额度
+// 令牌不足时允许透支，写协程等待额度恢复后再写下一帧，使大帧不会被永久阻塞
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// setRate 设置每秒额度，0表示不限制
+func (b *tokenBucket) setRate(bytesPerSecond int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = float64(bytesPerSecond)
+	b.tokens = b.rate
+	b.last = time.Now()
+}
+
+// limit 当前的每秒额度
+func (b *tokenBucket) limit() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return int64(b.rate)
+}
+
+// reserve 扣除n字节的额度，返回写出前需要等待的时间
+func (b *tokenBucket) reserve(n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return 0
+	}
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.tokens -= float64(n)
+	return wait
+}
+
+// wait 扣除n字节的额度并等待，done关闭时立即返回，关闭前的剩余帧不再限速
+func (b *tokenBucket) wait(n int, done <-chan struct{}) {
+	delay := b.reserve(n, time.Now())
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-done:
+	}
+}
+
+// BandwidthOptions 连接带宽限制
+type BandwidthOptions struct {
+	// MaxBytesPerSecond 新连接的默认下行限速，0表示不限制
+	MaxBytesPerSecond int64
+}
+
+// SetBandwidth 设置新连接的默认下行限速，已建立的连接不受影响
+func (m *Manager) SetBandwidth(opts BandwidthOptions) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bandwidth = opts
+}
+
+// bandwidthOptions 当前的带宽限制
+func (m *Manager) bandwidthOptions() BandwidthOptions {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.bandwidth
+}
@@ -0,0 +1,26 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var b tokenBucket
+	now := time.Now()
+	assert.Zero(t, b.reserve(1<<20, now), "zero rate is unlimited")
+
+	b.setRate(1000)
+	now = b.last
+	assert.Zero(t, b.reserve(600, now))
+	assert.Zero(t, b.reserve(600, now), "a frame may overdraw the remaining tokens")
+	assert.Equal(t, 200*time.Millisecond, b.reserve(100, now), "waits until the overdraft is repaid")
+
+	// 空闲后额度只恢复到一秒的上限
+	later := now.Add(10 * time.Second)
+	assert.Zero(t, b.reserve(1100, later))
+	assert.Equal(t, 100*time.Millisecond, b.reserve(100, later))
+	assert.Equal(t, int64(1000), b.limit())
+}
@@ -0,0 +1,203 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 帧协议版本，通过握手的Sec-WebSocket-Protocol协商
+const (
+	ProtocolJSONV1  = "im.v1.json"
+	ProtocolProtoV2 = "im.v2.proto"
+)
+
+// CloseUnsupportedProtocol 客户端请求的协议版本都不受支持时的关闭码
+const CloseUnsupportedProtocol = 4406
+
+// Codec 帧编解码器
+// 服务端内部统一使用JSON帧，编解码器负责与各协议版本的线上格式互相转换
+type Codec interface {
+	// Protocol 协议版本名称
+	Protocol() string
+	// MessageType 线上帧的WebSocket消息类型
+	MessageType() int
+	// Encode 把JSON帧编码为线上格式
+	Encode(frame []byte) ([]byte, error)
+	// Decode 把线上格式解码为JSON帧
+	Decode(data []byte) ([]byte, error)
+}
+
+// codecs 内置编解码器，按服务端偏好排列，协商时优先选择靠前的版本
+var codecs = []Codec{protoCodec{}, jsonCodec{}}
+
+// SupportedProtocols 内置的全部协议版本
+func SupportedProtocols() []string {
+	protocols := make([]string, len(codecs))
+	for i, codec := range codecs {
+		protocols[i] = codec.Protocol()
+	}
+	return protocols
+}
+
+// codecFor 获取协议版本对应的编解码器
+func codecFor(protocol string) (Codec, bool) {
+	for _, codec := range codecs {
+		if codec.Protocol() == protocol {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+// negotiate 按服务端偏好从客户端请求的版本中选择一个
+// 客户端未请求任何版本时视为请求v1 JSON，兼容不发送Sec-WebSocket-Protocol的旧客户端，v1 JSON未启用时协商失败
+func negotiate(requested, enabled []string) (string, bool) {
+	if len(requested) == 0 {
+		requested = []string{ProtocolJSONV1}
+	}
+	for _, protocol := range enabled {
+		for _, r := range requested {
+			if r == protocol {
+				return protocol, true
+			}
+		}
+	}
+	return "", false
+}
+
+// unsupportedProtocolReason 拒绝握手时的关闭原因，列出服务端支持的版本
+func unsupportedProtocolReason(enabled []string) string {
+	return "unsupported protocol, supported: " + strings.Join(enabled, ", ")
+}
+
+// jsonCodec v1：JSON文本帧，内部格式即线上格式
+type jsonCodec struct{}
+
+func (jsonCodec) Protocol() string                    { return ProtocolJSONV1 }
+func (jsonCodec) MessageType() int                    { return websocket.TextMessage }
+func (jsonCodec) Encode(frame []byte) ([]byte, error) { return frame, nil }
+func (jsonCodec) Decode(data []byte) ([]byte, error)  { return data, nil }
+
+// protoCodec v2：protobuf二进制信封，业务数据仍为JSON字节，信封字段可独立演进
+//
+//	message Frame {
+//	  string type = 1;
+//	  bytes data = 2;       // JSON
+//	  int64 timestamp = 3;
+//	  string message_id = 4;
+//	  bytes push = 5;       // JSON，新消息推送的提醒方式
+//	}
+type protoCodec struct{}
+
+// 信封字段编号
+const (
+	protoFieldType      protowire.Number = 1
+	protoFieldData      protowire.Number = 2
+	protoFieldTimestamp protowire.Number = 3
+	protoFieldMessageID protowire.Number = 4
+	protoFieldPush      protowire.Number = 5
+)
+
+// envelope 帧信封，只解析到字段级别，业务数据保持原始JSON
+type envelope struct {
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp int64           `json:"timestamp"`
+	MessageID string          `json:"message_id,omitempty"`
+	Push      json.RawMessage `json:"push,omitempty"`
+}
+
+func (protoCodec) Protocol() string { return ProtocolProtoV2 }
+func (protoCodec) MessageType() int { return websocket.BinaryMessage }
+
+// Encode 把JSON帧编码为protobuf信封
+func (protoCodec) Encode(frame []byte) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(frame, &env); err != nil {
+		return nil, fmt.Errorf("failed to decode frame: %w", err)
+	}
+
+	var b []byte
+	b = protowire.AppendTag(b, protoFieldType, protowire.BytesType)
+	b = protowire.AppendString(b, env.Type)
+	if len(env.Data) > 0 && string(env.Data) != "null" {
+		b = protowire.AppendTag(b, protoFieldData, protowire.BytesType)
+		b = protowire.AppendBytes(b, env.Data)
+	}
+	if env.Timestamp != 0 {
+		b = protowire.AppendTag(b, protoFieldTimestamp, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(env.Timestamp))
+	}
+	if env.MessageID != "" {
+		b = protowire.AppendTag(b, protoFieldMessageID, protowire.BytesType)
+		b = protowire.AppendString(b, env.MessageID)
+	}
+	if len(env.Push) > 0 && string(env.Push) != "null" {
+		b = protowire.AppendTag(b, protoFieldPush, protowire.BytesType)
+		b = protowire.AppendBytes(b, env.Push)
+	}
+	return b, nil
+}
+
+// Decode 把protobuf信封解码为JSON帧，忽略未知字段
+func (protoCodec) Decode(data []byte) ([]byte, error) {
+	var env envelope
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, fmt.Errorf("failed to decode frame: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		switch {
+		case num == protoFieldType && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return nil, fmt.Errorf("failed to decode frame type: %w", protowire.ParseError(n))
+			}
+			env.Type, data = v, data[n:]
+		case num == protoFieldData && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil, fmt.Errorf("failed to decode frame data: %w", protowire.ParseError(n))
+			}
+			env.Data, data = json.RawMessage(v), data[n:]
+		case num == protoFieldTimestamp && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return nil, fmt.Errorf("failed to decode frame timestamp: %w", protowire.ParseError(n))
+			}
+			env.Timestamp, data = int64(v), data[n:]
+		case num == protoFieldMessageID && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return nil, fmt.Errorf("failed to decode frame message id: %w", protowire.ParseError(n))
+			}
+			env.MessageID, data = v, data[n:]
+		case num == protoFieldPush && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil, fmt.Errorf("failed to decode frame push: %w", protowire.ParseError(n))
+			}
+			env.Push, data = json.RawMessage(v), data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return nil, fmt.Errorf("failed to decode frame: %w", protowire.ParseError(n))
+			}
+			data = data[n:]
+		}
+	}
+
+	if len(env.Data) > 0 && !json.Valid(env.Data) {
+		return nil, fmt.Errorf("frame data is not valid json")
+	}
+	if len(env.Push) > 0 && !json.Valid(env.Push) {
+		return nil, fmt.Errorf("frame push is not valid json")
+	}
+	return json.Marshal(env)
+}
@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	enabled := []string{ProtocolProtoV2, ProtocolJSONV1}
+
+	protocol, ok := negotiate(nil, enabled)
+	assert.True(t, ok)
+	assert.Equal(t, ProtocolJSONV1, protocol)
+
+	protocol, ok = negotiate([]string{ProtocolJSONV1, ProtocolProtoV2}, enabled)
+	assert.True(t, ok)
+	assert.Equal(t, ProtocolProtoV2, protocol, "server preference wins")
+
+	_, ok = negotiate([]string{"im.v9.cbor"}, enabled)
+	assert.False(t, ok)
+
+	// 旧客户端只会v1 JSON，关闭v1 JSON后不能回退到其他版本
+	_, ok = negotiate(nil, []string{ProtocolProtoV2})
+	assert.False(t, ok)
+}
+
+func TestProtoCodec_RoundTrip(t *testing.T) {
+	frame := []byte(`{"type":"new_message","data":{"id":"m1","content":"hi"},"timestamp":1700000000,"message_id":"m1","push":{"silent":true}}`)
+
+	encoded, err := protoCodec{}.Encode(frame)
+	assert.NoError(t, err)
+	decoded, err := protoCodec{}.Decode(encoded)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(frame), string(decoded))
+
+	_, err = protoCodec{}.Decode([]byte{0x0a, 0x05, 'a'})
+	assert.Error(t, err)
+}
+
+func TestOriginChecker(t *testing.T) {
+	check := originChecker([]string{"https://app.example.com", "*.example.org"})
+	request := func(origin string) *http.Request {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+
+	assert.True(t, check(request("https://app.example.com")))
+	assert.True(t, check(request("https://chat.example.org")))
+	assert.True(t, check(request("")))
+	assert.False(t, check(request("http://app.example.com")))
+	assert.False(t, check(request("https://evil.com")))
+	assert.True(t, originChecker(nil)(request("https://evil.com")))
+}
+
+func TestWebSocketTransport_RejectsUnknownProtocol(t *testing.T) {
+	m := NewManager()
+	server := httptest.NewServer(http.HandlerFunc(m.HandleWebSocket))
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"im.v9.cbor"}}
+	client, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer client.Close()
+	assert.Equal(t, "im.v2.proto, im.v1.json", resp.Header.Get("X-IM-Protocols"))
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = client.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, CloseUnsupportedProtocol), "unexpected error: %v", err)
+	assert.Equal(t, 0, m.GetConnectionCount())
+}
+
+func TestWebSocketTransport_RejectsLegacyClientWithoutJSON(t *testing.T) {
+	m := NewManager()
+	transport, err := NewWebSocketTransport(m, TransportOptions{Protocols: []string{ProtocolProtoV2}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	server := httptest.NewServer(http.HandlerFunc(transport.Handle))
+	defer server.Close()
+
+	// 不发送Sec-WebSocket-Protocol的客户端只会v1 JSON，升级后以协议不支持关闭
+	client, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer client.Close()
+	assert.Equal(t, "im.v2.proto", resp.Header.Get("X-IM-Protocols"))
+	assert.Empty(t, resp.Header.Get("Sec-WebSocket-Protocol"))
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = client.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, CloseUnsupportedProtocol), "unexpected error: %v", err)
+	assert.Equal(t, 0, m.GetConnectionCount())
+}
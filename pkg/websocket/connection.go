@@ -0,0 +1,417 @@
+package websocket
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/realip"
+)
+
+// WebSocketTransport WebSocket传输实现
+type WebSocketTransport struct {
+	manager   *Manager
+	upgrader  websocket.Upgrader
+	protocols []string
+	readLimit int64
+	realIP    *realip.Resolver
+	geo       GeoLocator
+}
+
+// TransportOptions WebSocket传输选项
+type TransportOptions struct {
+	// AllowedOrigins 允许的浏览器来源，支持 * 和 *.example.com 形式的通配，为空时允许所有来源
+	AllowedOrigins []string
+	// Protocols 启用的帧协议版本，按偏好排列，为空时启用全部内置版本
+	Protocols []string
+	// MaxMessageSize 单条上行消息的最大字节数，超出时关闭连接，0表示使用默认值
+	MaxMessageSize int64
+	// TrustedProxies 可信代理的CIDR或IP，对端属于可信代理时按X-Forwarded-For解析客户端IP，为空时只使用对端地址；
+	// 经过real-IP中间件的请求直接使用中间件解析的结果
+	TrustedProxies []string
+	// GeoLocator 握手时按客户端IP查询地理位置，nil表示不查询
+	GeoLocator GeoLocator
+}
+
+// NewWebSocketTransport 创建WebSocket传输
+func NewWebSocketTransport(manager *Manager, opts TransportOptions) (*WebSocketTransport, error) {
+	protocols := opts.Protocols
+	if len(protocols) == 0 {
+		protocols = SupportedProtocols()
+	}
+	for _, protocol := range protocols {
+		if _, ok := codecFor(protocol); !ok {
+			return nil, fmt.Errorf("unsupported websocket protocol: %s", protocol)
+		}
+	}
+
+	resolver, err := realip.New(opts.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	readLimit := opts.MaxMessageSize
+	if readLimit <= 0 {
+		readLimit = defaultReadLimit()
+	}
+
+	return &WebSocketTransport{
+		manager:   manager,
+		protocols: protocols,
+		readLimit: readLimit,
+		realIP:    resolver,
+		geo:       opts.GeoLocator,
+		upgrader: websocket.Upgrader{
+			CheckOrigin:     originChecker(opts.AllowedOrigins),
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}, nil
+}
+
+// originChecker 按允许列表校验Origin，非浏览器客户端不带Origin时放行
+func originChecker(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		if len(allowed) == 0 {
+			return true
+		}
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		for _, pattern := range allowed {
+			if matchOrigin(pattern, origin, u.Host) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// matchOrigin 匹配单条来源规则，规则可以是完整来源（含协议）或主机名
+func matchOrigin(pattern, origin, host string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(host, pattern[1:])
+	case strings.Contains(pattern, "://"):
+		return strings.EqualFold(pattern, origin)
+	default:
+		return strings.EqualFold(pattern, host)
+	}
+}
+
+// Name 传输协议名称
+func (t *WebSocketTransport) Name() string {
+	return TransportWebSocket
+}
+
+// Handle 升级HTTP连接为WebSocket并注册会话
+// 客户端请求的协议版本都不受支持时仍完成握手，再以CloseUnsupportedProtocol关闭，便于浏览器客户端读到原因；
+// 开启准入控制时先取握手令牌，取不到时拒绝握手并下发重连等待时间
+func (t *WebSocketTransport) Handle(w http.ResponseWriter, r *http.Request) {
+	if retryAfter, ok := t.manager.admit(r); !ok {
+		rejectHandshake(w, retryAfter)
+		return
+	}
+
+	requested := websocket.Subprotocols(r)
+	protocol, ok := negotiate(requested, t.protocols)
+
+	header := http.Header{}
+	header.Set("X-IM-Protocols", strings.Join(t.protocols, ", "))
+	switch {
+	case len(requested) == 0:
+	case ok:
+		header.Set("Sec-WebSocket-Protocol", protocol)
+	default:
+		// 浏览器在响应未回应所请求的子协议时直接判定握手失败，读不到关闭码，这里回应客户端的首选项后再关闭
+		header.Set("Sec-WebSocket-Protocol", requested[0])
+	}
+
+	conn, err := t.upgrader.Upgrade(w, r, header)
+	if err != nil {
+		fmt.Printf("Failed to upgrade connection: %v\n", err)
+		return
+	}
+
+	if !ok {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(CloseUnsupportedProtocol, unsupportedProtocolReason(t.protocols)),
+			time.Now().Add(writeWait))
+		conn.Close()
+		return
+	}
+
+	codec, _ := codecFor(protocol)
+	connection := newConnection(conn, t.manager, codec)
+	connection.handshake = t.handshake(r)
+	connection.readLimit = t.readLimit
+	connection.SetRateLimit(t.manager.bandwidthOptions().MaxBytesPerSecond)
+	t.manager.Register(connection)
+
+	// 启动读写协程
+	go connection.readPump()
+	go connection.writePump()
+}
+
+// 连接超时参数
+const (
+	writeWait         = 10 * time.Second
+	pongWait          = 60 * time.Second
+	pingPeriod        = 54 * time.Second // 必须小于pongWait
+	frameEnvelopeSize = 512              // 帧类型、请求ID等负载之外字段的余量
+)
+
+// defaultReadLimit 未配置最大消息大小时单条上行消息的最大字节数，容纳最大的帧负载上限和信封字段
+func defaultReadLimit() int64 {
+	return int64(model.MaxFramePayloadSize()) + frameEnvelopeSize
+}
+
+// Connection WebSocket连接
+// 生命周期：writePump是底层连接的唯一所有者，负责全部写入和最终关闭；
+// Close只关闭done通知写协程退出，可被读写协程和Manager并发重复调用；
+// Send通道从不关闭，发送方通过done判断连接是否已关闭，避免向已关闭的通道发送导致panic
+type Connection struct {
+	id        string
+	userID    string
+	caps      model.ClientCapabilities
+	handshake model.Handshake
+	Conn      *websocket.Conn
+	Send      chan []byte
+	Manager   *Manager
+	codec     Codec
+	readLimit int64
+	mu        sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+	traffic   trafficCounter
+	limiter   tokenBucket
+}
+
+// newConnection 创建连接，调用方负责注册到Manager并启动读写协程
+func newConnection(conn *websocket.Conn, manager *Manager, codec Codec) *Connection {
+	return &Connection{
+		id:      generateConnID(),
+		Conn:    conn,
+		Send:    make(chan []byte, 256),
+		Manager: manager,
+		codec:   codec,
+		done:    make(chan struct{}),
+	}
+}
+
+// Protocol 握手协商的帧协议版本
+func (c *Connection) Protocol() string {
+	return c.codec.Protocol()
+}
+
+// ID 连接ID
+func (c *Connection) ID() string {
+	return c.id
+}
+
+// UserID 连接绑定的用户ID
+func (c *Connection) UserID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.userID
+}
+
+// SetUserID 绑定用户ID
+func (c *Connection) SetUserID(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.userID = userID
+}
+
+// Transport 传输协议名称
+func (c *Connection) Transport() string {
+	return TransportWebSocket
+}
+
+// RemoteIP 客户端IP
+func (c *Connection) RemoteIP() string {
+	return c.handshake.RemoteIP
+}
+
+// Handshake 建立连接时的握手信息
+func (c *Connection) Handshake() model.Handshake {
+	return c.handshake
+}
+
+// Capabilities 客户端登录时上报的能力
+func (c *Connection) Capabilities() model.ClientCapabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.caps
+}
+
+// SetCapabilities 设置客户端能力
+func (c *Connection) SetCapabilities(caps model.ClientCapabilities) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.caps = caps
+}
+
+// Traffic 连接收发的字节数
+func (c *Connection) Traffic() Traffic {
+	return Traffic{
+		BytesIn:   c.traffic.in.Load(),
+		BytesOut:  c.traffic.out.Load(),
+		RateLimit: c.limiter.limit(),
+	}
+}
+
+// SetRateLimit 设置下行限速（字节/秒），0表示不限制
+func (c *Connection) SetRateLimit(bytesPerSecond int64) {
+	c.limiter.setRate(bytesPerSecond)
+}
+
+// Done 连接关闭后关闭的通道
+func (c *Connection) Done() <-chan struct{} {
+	return c.done
+}
+
+// readPump 读取消息泵，读取失败（包括写协程关闭底层连接）时从Manager注销并通知写协程退出
+func (c *Connection) readPump() {
+	defer func() {
+		c.Manager.Unregister(c)
+		c.Close()
+	}()
+
+	readLimit := c.readLimit
+	if readLimit <= 0 {
+		readLimit = defaultReadLimit()
+	}
+	c.Conn.SetReadLimit(readLimit) // 限制消息大小，各帧类型的负载上限在Dispatch中检查
+	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.Conn.SetPongHandler(func(string) error {
+		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, message, err := c.Conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				fmt.Printf("WebSocket read error: %v\n", err)
+			}
+			break
+		}
+		c.traffic.in.Add(int64(len(message)))
+
+		frame, err := c.codec.Decode(message)
+		if err != nil {
+			c.Manager.sendError(c, "Invalid message format")
+			continue
+		}
+
+		// 处理消息
+		c.Manager.Dispatch(c, frame)
+	}
+}
+
+// writePump 写入消息泵，退出时关闭底层连接，使读协程随之退出
+func (c *Connection) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Close()
+		c.Conn.Close()
+	}()
+
+	for {
+		select {
+		case message := <-c.Send:
+			if err := c.writeFrame(message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.write(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			c.flush()
+			c.write(websocket.CloseMessage, []byte{})
+			return
+		}
+	}
+}
+
+// flush 尽力写出关闭前已排队的消息，例如断开用户前发送的最后一帧
+func (c *Connection) flush() {
+	for {
+		select {
+		case message := <-c.Send:
+			if err := c.writeFrame(message); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// writeFrame 按协商的协议编码后写入一帧JSON帧，无法编码的帧直接丢弃
+func (c *Connection) writeFrame(frame []byte) error {
+	data, err := c.codec.Encode(frame)
+	if err != nil {
+		fmt.Printf("Failed to encode frame: %v\n", err)
+		return nil
+	}
+	// 限速时在写出前等待额度，期间新帧在发送缓冲中排队，缓冲满后按发送失败处理
+	c.limiter.wait(len(data), c.done)
+	return c.write(c.codec.MessageType(), data)
+}
+
+// write 写入一帧，只能在writePump中调用
+func (c *Connection) write(messageType int, data []byte) error {
+	c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+	c.traffic.out.Add(int64(len(data)))
+	return c.Conn.WriteMessage(messageType, data)
+}
+
+// SendMessage 发送消息
+func (c *Connection) SendMessage(message []byte) error {
+	select {
+	case <-c.done:
+		return fmt.Errorf("connection is closed")
+	default:
+	}
+
+	select {
+	case c.Send <- message:
+		return nil
+	case <-c.done:
+		return fmt.Errorf("connection is closed")
+	default:
+		return fmt.Errorf("send buffer is full")
+	}
+}
+
+// Close 关闭连接，可重复调用，底层连接由写协程在写出剩余消息后关闭
+func (c *Connection) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// generateConnID 生成连接ID
+// 同一纳秒内创建的连接以序号区分，避免在Manager中互相覆盖
+func generateConnID() string {
+	return fmt.Sprintf("conn_%d_%d", time.Now().UnixNano(), connSeq.Add(1))
+}
+
+// connSeq 连接序号
+var connSeq atomic.Uint64
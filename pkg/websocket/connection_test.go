@@ -0,0 +1,135 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// startServer 启动测试服务器并建立一个客户端连接，返回服务端会话
+func startServer(t *testing.T, m *Manager) (*Connection, *websocket.Conn) {
+	server := httptest.NewServer(http.HandlerFunc(m.HandleWebSocket))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { client.Close() })
+
+	var conn *Connection
+	assert.Eventually(t, func() bool {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		for _, s := range m.sessions {
+			conn = s.(*Connection)
+		}
+		return conn != nil
+	}, time.Second, 5*time.Millisecond)
+	return conn, client
+}
+
+func TestConnection_ConcurrentSendCloseUnregister(t *testing.T) {
+	m := NewManager()
+	c := newConnection(nil, m, jsonCodec{})
+	m.Register(c)
+	m.BindUser("u1", c)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.SendMessage([]byte("hello"))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			c.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			m.Unregister(c)
+		}()
+	}
+	wg.Wait()
+
+	assert.Error(t, c.SendMessage([]byte("late")))
+	assert.Equal(t, 0, m.GetConnectionCount())
+	assert.False(t, m.IsOnline("u1"))
+	select {
+	case <-c.Done():
+	default:
+		t.Fatal("done channel not closed")
+	}
+}
+
+func TestConnection_ServerCloseFlushesAndUnregisters(t *testing.T) {
+	m := NewManager()
+	conn, client := startServer(t, m)
+
+	// 断开前发送的最后一帧应先于关闭帧到达客户端
+	assert.NoError(t, conn.SendMessage([]byte(`{"type":"banned"}`)))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			conn.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			conn.SendMessage([]byte("racing"))
+		}()
+	}
+	wg.Wait()
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := client.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"banned"}`, string(data))
+	for err == nil {
+		_, _, err = client.ReadMessage()
+	}
+
+	assert.Eventually(t, func() bool { return m.GetConnectionCount() == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestConnection_ClientCloseStopsPumps(t *testing.T) {
+	m := NewManager()
+	conn, client := startServer(t, m)
+	m.BindUser("u1", conn)
+
+	client.Close()
+
+	select {
+	case <-conn.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection not closed after client disconnect")
+	}
+	assert.Eventually(t, func() bool { return m.GetConnectionCount() == 0 && !m.IsOnline("u1") }, time.Second, 5*time.Millisecond)
+	assert.Error(t, conn.SendMessage([]byte("after close")))
+}
+
+func TestConnection_DefaultReadLimitFitsLargestFrame(t *testing.T) {
+	m := NewManager()
+	_, client := startServer(t, m)
+
+	// 登录帧的负载上限为16KB，未配置最大消息大小时读取上限不能更小
+	payload := `{"type":"login","data":{"user_id":"u1","token":"` + strings.Repeat("t", 12<<10) + `"}}`
+	assert.NoError(t, client.WriteMessage(websocket.TextMessage, []byte(payload)))
+
+	client.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err := client.ReadMessage()
+	var closeErr *websocket.CloseError
+	assert.False(t, errors.As(err, &closeErr), "connection closed: %v", err)
+	assert.Equal(t, 1, m.GetConnectionCount())
+}
@@ -0,0 +1,186 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/user/im/internal/model"
+)
+
+// FlowOptions 消息确认窗口（基于credit的流控）
+type FlowOptions struct {
+	// Window 每个会话最多未确认的新消息数，0表示不限制
+	Window int
+	// MaxQueued 窗口已满时在服务端排队的最多消息数，超出的消息交给FlowFallback
+	MaxQueued int
+}
+
+// FlowFallback 会话关闭时未确认和仍在排队的消息帧，以及排队已满时溢出的消息帧，
+// 由使用方转入离线队列，客户端重连后按消息ID去重
+type FlowFallback func(userID string, frames [][]byte)
+
+// flowFrameTypes 需要客户端确认、受窗口限制的下行帧类型，其余帧直接下发
+var flowFrameTypes = map[model.FrameType]bool{
+	model.FrameNewMessage:      true,
+	model.FrameNewGroupMessage: true,
+}
+
+// flowFrame 受窗口限制的消息帧
+type flowFrame struct {
+	messageID string
+	data      []byte
+}
+
+// flowWindow 单个会话的确认窗口
+// inflight为已下发未确认的消息，按下发顺序排列；queued为窗口已满时排队的消息
+type flowWindow struct {
+	mu       sync.Mutex
+	inflight []flowFrame
+	queued   []flowFrame
+	closed   bool
+}
+
+// frames 未确认和排队中的全部消息帧，按下发顺序排列
+func (w *flowWindow) frames() [][]byte {
+	frames := make([][]byte, 0, len(w.inflight)+len(w.queued))
+	for _, f := range w.inflight {
+		frames = append(frames, f.data)
+	}
+	for _, f := range w.queued {
+		frames = append(frames, f.data)
+	}
+	return frames
+}
+
+// SetFlowControl 启用消息确认窗口，只对登录时声明supports_ack_window的客户端生效
+func (m *Manager) SetFlowControl(opts FlowOptions, fallback FlowFallback) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flow = opts
+	m.fallback = fallback
+}
+
+// ackWindow 会话适用的窗口大小，未启用或客户端不支持时为0
+func (m *Manager) ackWindow(s Session) int {
+	m.mu.RLock()
+	window := m.flow.Window
+	m.mu.RUnlock()
+	if window <= 0 || !s.Capabilities().Supports(model.ClientFeatureAckWindow) {
+		return 0
+	}
+	return window
+}
+
+// Deliver 向会话下发一帧，新消息帧受确认窗口限制，窗口已满时在服务端排队
+func (m *Manager) Deliver(s Session, data []byte) error {
+	window := m.ackWindow(s)
+	if window <= 0 {
+		return s.SendMessage(data)
+	}
+
+	var header struct {
+		Type      model.FrameType `json:"type"`
+		MessageID string          `json:"message_id"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil || !flowFrameTypes[header.Type] || header.MessageID == "" {
+		return s.SendMessage(data)
+	}
+
+	m.mu.Lock()
+	w, exists := m.windows[s.ID()]
+	if _, registered := m.sessions[s.ID()]; !exists && registered {
+		w = &flowWindow{}
+		m.windows[s.ID()] = w
+	} else if !exists {
+		// 会话已注销，不再创建窗口
+		w = &flowWindow{closed: true}
+	}
+	maxQueued := m.flow.MaxQueued
+	fallback := m.fallback
+	m.mu.Unlock()
+
+	frame := flowFrame{messageID: header.MessageID, data: data}
+	w.mu.Lock()
+	switch {
+	case w.closed:
+	case len(w.inflight) < window:
+		// 在窗口锁内发送，保证消息按顺序进入发送缓冲
+		if err := s.SendMessage(data); err == nil {
+			w.inflight = append(w.inflight, frame)
+			w.mu.Unlock()
+			return nil
+		}
+	case len(w.queued) < maxQueued:
+		w.queued = append(w.queued, frame)
+		w.mu.Unlock()
+		return nil
+	}
+	w.mu.Unlock()
+
+	// 会话已关闭、发送失败或排队已满，交给离线回退
+	if fallback != nil {
+		fallback(s.UserID(), [][]byte{data})
+	}
+	return nil
+}
+
+// release 客户端确认消息后释放窗口，并下发排队中的消息
+func (m *Manager) release(s Session, req *model.AckRequest) {
+	if req.MessageID == "" {
+		return
+	}
+
+	m.mu.RLock()
+	w, exists := m.windows[s.ID()]
+	window := m.flow.Window
+	fallback := m.fallback
+	m.mu.RUnlock()
+	if !exists {
+		return
+	}
+
+	var failed [][]byte
+	w.mu.Lock()
+	for i, f := range w.inflight {
+		if f.messageID == req.MessageID {
+			w.inflight = append(w.inflight[:i], w.inflight[i+1:]...)
+			break
+		}
+	}
+	for !w.closed && len(w.inflight) < window && len(w.queued) > 0 {
+		next := w.queued[0]
+		w.queued = w.queued[1:]
+		if err := s.SendMessage(next.data); err != nil {
+			failed = append(failed, next.data)
+			continue
+		}
+		w.inflight = append(w.inflight, next)
+	}
+	w.mu.Unlock()
+
+	if len(failed) > 0 && fallback != nil {
+		fallback(s.UserID(), failed)
+	}
+}
+
+// closeWindow 会话注销时移除窗口，未确认和排队中的消息交给离线回退
+func (m *Manager) closeWindow(s Session) {
+	m.mu.Lock()
+	w, exists := m.windows[s.ID()]
+	delete(m.windows, s.ID())
+	fallback := m.fallback
+	m.mu.Unlock()
+	if !exists {
+		return
+	}
+
+	w.mu.Lock()
+	w.closed = true
+	frames := w.frames()
+	w.inflight, w.queued = nil, nil
+	w.mu.Unlock()
+
+	if len(frames) > 0 && fallback != nil {
+		fallback(s.UserID(), frames)
+	}
+}
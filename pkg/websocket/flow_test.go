@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFlowSession(t *testing.T, m *Manager, userID string) *Connection {
+	c := newConnection(nil, m, jsonCodec{})
+	m.Register(c)
+	m.Dispatch(c, []byte(`{"type":"login","data":{"user_id":"`+userID+`","capabilities":{"supports_ack_window":true}}}`))
+	assert.Contains(t, string(<-c.Send), `"ack_window":2`)
+	return c
+}
+
+func TestManager_FlowControl(t *testing.T) {
+	m := NewManager()
+	var requeued []string
+	m.SetFlowControl(FlowOptions{Window: 2, MaxQueued: 1}, func(userID string, frames [][]byte) {
+		for _, f := range frames {
+			requeued = append(requeued, string(f))
+		}
+	})
+	c := newFlowSession(t, m, "u1")
+
+	frame := func(id string) map[string]string {
+		return map[string]string{"type": "new_message", "message_id": id}
+	}
+	for _, id := range []string{"m1", "m2", "m3", "m4"} {
+		assert.NoError(t, m.SendToUser("u1", frame(id)))
+	}
+	// 不受窗口限制的帧直接下发
+	assert.NoError(t, m.SendToUser("u1", map[string]string{"type": "presence"}))
+
+	assert.Contains(t, string(<-c.Send), "m1")
+	assert.Contains(t, string(<-c.Send), "m2")
+	assert.Contains(t, string(<-c.Send), "presence")
+	assert.Len(t, c.Send, 0)
+	// 排队已满，溢出的消息交给回退
+	assert.Len(t, requeued, 1)
+	assert.Contains(t, requeued[0], "m4")
+
+	m.Dispatch(c, []byte(`{"type":"ack","data":{"message_id":"m1","status":"delivered"}}`))
+	assert.Contains(t, string(<-c.Send), "m3")
+
+	// 断线时未确认的消息交给回退
+	m.Unregister(c)
+	assert.Len(t, requeued, 3)
+	assert.Contains(t, requeued[1], "m2")
+	assert.Contains(t, requeued[2], "m3")
+	assert.Equal(t, 0, m.GetMapSizes()["windows"])
+}
+
+func TestManager_FlowControlRequiresCapability(t *testing.T) {
+	m := NewManager()
+	m.SetFlowControl(FlowOptions{Window: 1}, nil)
+	c := newConnection(nil, m, jsonCodec{})
+	m.Register(c)
+	m.Dispatch(c, []byte(`{"type":"login","data":{"user_id":"u1"}}`))
+	assert.NotContains(t, string(<-c.Send), "ack_window")
+
+	for _, id := range []string{"m1", "m2"} {
+		assert.NoError(t, m.SendToUser("u1", map[string]string{"type": "new_message", "message_id": id}))
+	}
+	assert.Len(t, c.Send, 2)
+}
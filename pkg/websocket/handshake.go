@@ -0,0 +1,34 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/user/im/internal/model"
+)
+
+// GeoLocator 按客户端IP查询地理位置，在握手时同步调用，应在内存中完成查询
+type GeoLocator interface {
+	Locate(ip net.IP) (model.GeoLocation, bool)
+}
+
+// maxUserAgentLen 记录的User-Agent最大长度
+const maxUserAgentLen = 256
+
+// handshake 从升级请求中提取握手信息
+func (t *WebSocketTransport) handshake(r *http.Request) model.Handshake {
+	info := model.Handshake{
+		RemoteIP:    t.realIP.FromRequest(r),
+		UserAgent:   truncate(r.UserAgent(), maxUserAgentLen),
+		ConnectedAt: time.Now().Unix(),
+	}
+	if t.geo != nil {
+		if ip := net.ParseIP(info.RemoteIP); ip != nil {
+			if location, ok := t.geo.Locate(ip); ok {
+				info.Geo = &location
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,43 @@
+package websocket
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+	"github.com/user/im/pkg/realip"
+)
+
+type staticLocator map[string]model.GeoLocation
+
+func (l staticLocator) Locate(ip net.IP) (model.GeoLocation, bool) {
+	location, ok := l[ip.String()]
+	return location, ok
+}
+
+func TestTransportHandshake(t *testing.T) {
+	transport, err := NewWebSocketTransport(NewManager(), TransportOptions{
+		TrustedProxies: []string{"10.0.0.0/8"},
+		GeoLocator:     staticLocator{"198.51.100.7": {Country: "NL", City: "Amsterdam"}},
+	})
+	assert.NoError(t, err)
+
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.RemoteAddr = "10.0.0.2:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	r.Header.Set("User-Agent", "im-ios/3.2")
+	info := transport.handshake(r)
+	assert.Equal(t, "198.51.100.7", info.RemoteIP)
+	assert.Equal(t, "im-ios/3.2", info.UserAgent)
+	assert.Equal(t, &model.GeoLocation{Country: "NL", City: "Amsterdam"}, info.Geo)
+	assert.NotZero(t, info.ConnectedAt)
+
+	r.Header.Set("X-Forwarded-For", "203.0.113.1")
+	assert.Nil(t, transport.handshake(r).Geo)
+
+	// 经过real-IP中间件的请求使用中间件解析的结果
+	r = r.WithContext(realip.NewContext(r.Context(), "198.51.100.8"))
+	assert.Equal(t, "198.51.100.8", transport.handshake(r).RemoteIP)
+}
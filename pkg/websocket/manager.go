@@ -4,112 +4,257 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/user/im/internal/model"
 )
 
-// Connection WebSocket连接
-type Connection struct {
-	ID      string
-	UserID  string
-	Conn    *websocket.Conn
-	Send    chan []byte
-	Manager *Manager
-	mu      sync.Mutex
-	closed  bool
-}
+// FrameHandler 上行帧处理函数
+type FrameHandler func(s Session, frame *model.WebSocketMessage)
+
+// UserHook 用户会话绑定/解绑回调
+type UserHook func(userID string, s Session)
+
+// LoginGuard 登录准入判断，返回空帧类型时继续登录；否则不绑定会话，把返回的帧下发给客户端，
+// 由LoginGuard的使用方在后续流程中调用CompleteLogin完成登录
+type LoginGuard func(s Session, req *model.LoginRequest) (msgType model.FrameType, data interface{})
 
-// Manager WebSocket连接管理器
+// FrameGate 判断会话能否使用某种上行帧，返回false时拒绝该帧
+type FrameGate func(s Session, msgType model.FrameType) bool
+
+// Manager 会话管理器
+// 统一管理所有传输协议的会话，按会话ID和用户ID建立索引
 type Manager struct {
-	connections map[string]*Connection // connID -> Connection
-	users       map[string]*Connection // userID -> Connection
-	mu          sync.RWMutex
-	upgrader    websocket.Upgrader
+	sessions   map[string]Session // sessionID -> Session
+	users      map[string]Session // userID -> Session
+	transports map[string]Transport
+	handlers   map[model.FrameType]FrameHandler
+	onBind     []UserHook
+	onUnbind   []UserHook
+	onClose    []UserHook
+	gate       FrameGate
+	loginGuard LoginGuard
+	flow       FlowOptions
+	fallback   FlowFallback
+	windows    map[string]*flowWindow // sessionID -> 确认窗口
+	bandwidth  BandwidthOptions
+	admission  *admission
+	mu         sync.RWMutex
 }
 
-// NewManager 创建连接管理器
+// NewManager 创建连接管理器，默认注册WebSocket传输
 func NewManager() *Manager {
-	return &Manager{
-		connections: make(map[string]*Connection),
-		users:       make(map[string]*Connection),
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // 允许所有来源，生产环境需要限制
-			},
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
-		},
+	m := &Manager{
+		sessions:   make(map[string]Session),
+		users:      make(map[string]Session),
+		transports: make(map[string]Transport),
+		handlers:   make(map[model.FrameType]FrameHandler),
+		windows:    make(map[string]*flowWindow),
 	}
+	transport, _ := NewWebSocketTransport(m, TransportOptions{})
+	m.RegisterTransport(transport)
+	return m
 }
 
-// HandleWebSocket 处理WebSocket连接
-func (m *Manager) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := m.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		fmt.Printf("Failed to upgrade connection: %v\n", err)
-		return
-	}
+// RegisterTransport 注册传输协议
+func (m *Manager) RegisterTransport(t Transport) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transports[t.Name()] = t
+}
 
-	connection := &Connection{
-		ID:      generateConnID(),
-		Conn:    conn,
-		Send:    make(chan []byte, 256),
-		Manager: m,
-	}
+// GetTransport 获取传输协议
+func (m *Manager) GetTransport(name string) (Transport, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, exists := m.transports[name]
+	return t, exists
+}
+
+// HandleFrame 注册上行帧处理函数，优先于内置处理逻辑
+func (m *Manager) HandleFrame(msgType model.FrameType, h FrameHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[msgType] = h
+}
 
-	m.addConnection(connection)
+// SetFrameGate 设置上行帧准入判断，登录、心跳和时间同步帧不受限制
+func (m *Manager) SetFrameGate(g FrameGate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gate = g
+}
+
+// SetLoginGuard 设置登录准入判断，如对可疑登录要求额外验证
+func (m *Manager) SetLoginGuard(g LoginGuard) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loginGuard = g
+}
 
-	// 启动读写协程
-	go connection.readPump()
-	go connection.writePump()
+// OnBind 注册用户绑定会话回调
+func (m *Manager) OnBind(h UserHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onBind = append(m.onBind, h)
 }
 
-// addConnection 添加连接
-func (m *Manager) addConnection(conn *Connection) {
+// OnUnbind 注册用户会话解绑回调
+func (m *Manager) OnUnbind(h UserHook) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.connections[conn.ID] = conn
+	m.onUnbind = append(m.onUnbind, h)
 }
 
-// removeConnection 移除连接
-func (m *Manager) removeConnection(conn *Connection) {
+// OnClose 注册已登录会话关闭回调，与OnUnbind不同，被同一用户的新会话替换的旧会话关闭时同样触发
+func (m *Manager) OnClose(h UserHook) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.onClose = append(m.onClose, h)
+}
 
-	delete(m.connections, conn.ID)
-	if conn.UserID != "" {
-		delete(m.users, conn.UserID)
+// HandleTransport 使用指定传输协议接入请求
+func (m *Manager) HandleTransport(name string, w http.ResponseWriter, r *http.Request) {
+	t, exists := m.GetTransport(name)
+	if !exists {
+		http.Error(w, fmt.Sprintf("transport %s not supported", name), http.StatusNotFound)
+		return
 	}
+	t.Handle(w, r)
+}
+
+// HandleWebSocket 处理WebSocket连接
+func (m *Manager) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	m.HandleTransport(TransportWebSocket, w, r)
 }
 
-// setUserConnection 设置用户连接
-func (m *Manager) setUserConnection(userID string, conn *Connection) {
+// Register 注册会话
+func (m *Manager) Register(s Session) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.sessions[s.ID()] = s
+}
+
+// Unregister 注销会话
+func (m *Manager) Unregister(s Session) {
+	m.mu.Lock()
+	unbound := false
+	delete(m.sessions, s.ID())
+	userID := s.UserID()
+	if userID != "" {
+		// 只移除仍指向该会话的用户索引，避免误删重新登录后的新会话
+		if current, exists := m.users[userID]; exists && current.ID() == s.ID() {
+			delete(m.users, userID)
+			unbound = true
+		}
+	}
+	hooks, closeHooks := m.onUnbind, m.onClose
+	m.mu.Unlock()
+
+	m.closeWindow(s)
+	if unbound {
+		for _, h := range hooks {
+			h(userID, s)
+		}
+	}
+	if userID != "" {
+		for _, h := range closeHooks {
+			h(userID, s)
+		}
+	}
+}
+
+// BindUser 绑定用户与会话
+func (m *Manager) BindUser(userID string, s Session) {
+	m.mu.Lock()
+	// 如果用户已有会话，先关闭旧会话
+	if old, exists := m.users[userID]; exists && old.ID() != s.ID() {
+		old.Close()
+	}
+
+	m.users[userID] = s
+	s.SetUserID(userID)
+	hooks := m.onBind
+	m.mu.Unlock()
+
+	for _, h := range hooks {
+		h(userID, s)
+	}
+}
+
+// DisconnectUser 尽力向用户发送最后一帧后断开其会话，message为nil时直接断开
+func (m *Manager) DisconnectUser(userID string, message interface{}) error {
+	s, exists := m.GetUserSession(userID)
+	if !exists {
+		return nil
+	}
+
+	if message != nil {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		s.SendMessage(data)
+	}
+	s.Close()
+	return nil
+}
+
+// GetUserCapabilities 获取用户本地会话的客户端能力
+func (m *Manager) GetUserCapabilities(userID string) (model.ClientCapabilities, bool) {
+	s, exists := m.GetUserSession(userID)
+	if !exists {
+		return model.ClientCapabilities{}, false
+	}
+	return s.Capabilities(), true
+}
+
+// ClientVersion 客户端平台和版本
+type ClientVersion struct {
+	Platform   string
+	AppVersion string
+}
+
+// GetClientVersionCounts 按客户端平台和版本统计已登录的会话数
+func (m *Manager) GetClientVersionCounts() map[ClientVersion]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	// 如果用户已有连接，先关闭旧连接
-	if oldConn, exists := m.users[userID]; exists {
-		oldConn.close()
+	counts := make(map[ClientVersion]int)
+	for _, s := range m.users {
+		caps := s.Capabilities()
+		counts[ClientVersion{Platform: caps.Platform, AppVersion: caps.AppVersion}]++
 	}
+	return counts
+}
 
-	m.users[userID] = conn
-	conn.UserID = userID
+// IsOnline 判断用户是否有本地会话
+func (m *Manager) IsOnline(userID string) bool {
+	_, exists := m.GetUserSession(userID)
+	return exists
 }
 
-// GetUserConnection 获取用户连接
-func (m *Manager) GetUserConnection(userID string) (*Connection, bool) {
+// GetUserSession 获取用户会话
+func (m *Manager) GetUserSession(userID string) (Session, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	conn, exists := m.users[userID]
-	return conn, exists
+	s, exists := m.users[userID]
+	return s, exists
+}
+
+// GetSession 按会话ID获取已注册的会话
+func (m *Manager) GetSession(sessionID string) (Session, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, exists := m.sessions[sessionID]
+	return s, exists
 }
 
 // SendToUser 发送消息给用户
 func (m *Manager) SendToUser(userID string, message interface{}) error {
-	conn, exists := m.GetUserConnection(userID)
+	s, exists := m.GetUserSession(userID)
 	if !exists {
 		return fmt.Errorf("user %s not connected", userID)
 	}
@@ -119,7 +264,21 @@ func (m *Manager) SendToUser(userID string, message interface{}) error {
 		return err
 	}
 
-	return conn.SendMessage(data)
+	return m.Deliver(s, data)
+}
+
+// ForEachUser 对所有已登录的会话调用fn，在锁外调用，fn可以向会话发送消息
+func (m *Manager) ForEachUser(fn UserHook) {
+	m.mu.RLock()
+	users := make(map[string]Session, len(m.users))
+	for userID, s := range m.users {
+		users[userID] = s
+	}
+	m.mu.RUnlock()
+
+	for userID, s := range users {
+		fn(userID, s)
+	}
 }
 
 // BroadcastToGroup 广播消息给群组
@@ -131,20 +290,24 @@ func (m *Manager) BroadcastToGroup(groupMembers []string, message interface{}) {
 	}
 
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-
+	sessions := make([]Session, 0, len(groupMembers))
 	for _, userID := range groupMembers {
-		if conn, exists := m.users[userID]; exists {
-			conn.SendMessage(data)
+		if s, exists := m.users[userID]; exists {
+			sessions = append(sessions, s)
 		}
 	}
+	m.mu.RUnlock()
+
+	for _, s := range sessions {
+		m.Deliver(s, data)
+	}
 }
 
 // GetConnectionCount 获取连接数
 func (m *Manager) GetConnectionCount() int {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return len(m.connections)
+	return len(m.sessions)
 }
 
 // GetOnlineUserCount 获取在线用户数
@@ -154,198 +317,225 @@ func (m *Manager) GetOnlineUserCount() int {
 	return len(m.users)
 }
 
-// CloseAll 关闭所有连接
-func (m *Manager) CloseAll() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// GetTransportCounts 按传输协议统计会话数
+func (m *Manager) GetTransportCounts() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	for _, conn := range m.connections {
-		conn.close()
+	counts := make(map[string]int, len(m.transports))
+	for _, s := range m.sessions {
+		counts[s.Transport()]++
 	}
+	return counts
 }
 
-// readPump 读取消息泵
-func (c *Connection) readPump() {
-	defer func() {
-		c.Manager.removeConnection(c)
-		c.close()
-	}()
-
-	c.Conn.SetReadLimit(512) // 限制消息大小
-	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	c.Conn.SetPongHandler(func(string) error {
-		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-		return nil
-	})
-
-	for {
-		_, message, err := c.Conn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				fmt.Printf("WebSocket read error: %v\n", err)
-			}
-			break
-		}
+// GetMapSizes 各索引的大小，用于排查会话泄漏：会话数持续大于在线用户数说明有被顶替的会话未清理
+func (m *Manager) GetMapSizes() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-		// 处理消息
-		c.handleMessage(message)
-	}
-}
-
-// writePump 写入消息泵
-func (c *Connection) writePump() {
-	ticker := time.NewTicker(54 * time.Second)
-	defer func() {
-		ticker.Stop()
-		c.close()
-	}()
-
-	for {
-		select {
-		case message, ok := <-c.Send:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-
-			w, err := c.Conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			if err := w.Close(); err != nil {
-				return
-			}
-		case <-ticker.C:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
-			}
-		}
+	return map[string]int{
+		"sessions":   len(m.sessions),
+		"users":      len(m.users),
+		"transports": len(m.transports),
+		"handlers":   len(m.handlers),
+		"windows":    len(m.windows),
 	}
 }
 
-// SendMessage 发送消息
-func (c *Connection) SendMessage(message []byte) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// CloseAll 关闭所有会话
+func (m *Manager) CloseAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	if c.closed {
-		return fmt.Errorf("connection is closed")
-	}
-
-	select {
-	case c.Send <- message:
-		return nil
-	default:
-		return fmt.Errorf("send buffer is full")
+	for _, s := range m.sessions {
+		s.Close()
 	}
 }
 
-// close 关闭连接
-func (c *Connection) close() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if c.closed {
+// Dispatch 处理客户端上行消息，与传输协议无关
+// 已注册的帧类型先按帧注册表检查负载大小、解码和校验，注册的处理函数优先于内置处理逻辑
+func (m *Manager) Dispatch(s Session, data []byte) {
+	// 负载保留原始字节，大小上限在解码负载前检查
+	var envelope struct {
+		model.WebSocketMessage
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		m.sendError(s, "Invalid message format")
 		return
 	}
+	wsMessage := envelope.WebSocketMessage
+	if len(envelope.Data) > 0 {
+		wsMessage.Data = envelope.Data
+	}
 
-	c.closed = true
-	close(c.Send)
-	c.Conn.Close()
-}
-
-// handleMessage 处理消息
-func (c *Connection) handleMessage(data []byte) {
-	var wsMessage model.WebSocketMessage
-	if err := json.Unmarshal(data, &wsMessage); err != nil {
-		c.sendError("Invalid message format")
+	m.mu.RLock()
+	h, exists := m.handlers[wsMessage.Type]
+	gate := m.gate
+	m.mu.RUnlock()
+	received := time.Now()
+	spec, known := model.LookupFrame(wsMessage.Type)
+	if !known && !exists {
+		m.sendError(s, "Unknown message type")
+		return
+	}
+	if gate != nil && !spec.Connection && !gate(s, wsMessage.Type) {
+		m.sendError(s, "Feature not enabled: "+string(wsMessage.Type))
+		return
+	}
+	var payload interface{}
+	if spec.Request != nil {
+		var err error
+		if payload, err = spec.Decode(wsMessage.Data); err != nil {
+			m.sendResponse(s, model.FrameError, model.InvalidFrameError(wsMessage.Type, err))
+			return
+		}
+	}
+	if wsMessage.Type == model.FrameAck {
+		m.release(s, payload.(*model.AckRequest))
+	}
+	if exists {
+		h(s, &wsMessage)
 		return
 	}
 
 	switch wsMessage.Type {
-	case "login":
-		c.handleLogin(wsMessage.Data)
-	case "heartbeat":
-		c.handleHeartbeat(wsMessage.Data)
-	case "send_message":
-		c.handleSendMessage(wsMessage.Data)
-	case "ack":
-		c.handleAck(wsMessage.Data)
-	case "sync_offline":
-		c.handleSyncOffline(wsMessage.Data)
-	case "join_group":
-		c.handleJoinGroup(wsMessage.Data)
-	case "leave_group":
-		c.handleLeaveGroup(wsMessage.Data)
+	case model.FrameLogin:
+		m.handleLogin(s, payload.(*model.LoginRequest))
+	case model.FrameHeartbeat:
+		m.handleHeartbeat(s, payload.(*model.HeartbeatRequest))
+	case model.FrameTimeSync:
+		m.handleTimeSync(s, payload.(*model.TimeSyncRequest), received)
+	case model.FrameSendMessage:
+		m.handleSendMessage(s, payload.(*model.SendMessageRequest))
+	case model.FrameAck:
+		m.handleAck(s, payload.(*model.AckRequest))
+	case model.FrameSyncOffline:
+		m.handleSyncOffline(s, payload.(*model.SyncOfflineRequest))
+	case model.FrameJoinGroup:
+		m.handleJoinGroup(s, payload.(*model.JoinGroupRequest))
+	case model.FrameLeaveGroup:
+		m.handleLeaveGroup(s, payload.(*model.LeaveGroupRequest))
 	default:
-		c.sendError("Unknown message type")
+		m.sendError(s, "Unknown message type")
 	}
 }
 
-// handleLogin 处理登录
-func (c *Connection) handleLogin(data interface{}) {
+// maxClientLabelLen 客户端平台和版本的最大长度
+const maxClientLabelLen = 32
+
+// truncate 按字节截断字符串，丢弃被截断的不完整字符
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return strings.ToValidUTF8(s[:n], "")
+	}
+	return s
+}
+
+// handleLogin 处理登录，客户端能力在绑定前设置，绑定回调中即可读取
+func (m *Manager) handleLogin(s Session, req *model.LoginRequest) {
 	// 这里应该验证用户身份
 	// 简化处理，直接设置用户ID
-	if userData, ok := data.(map[string]interface{}); ok {
-		if userID, ok := userData["user_id"].(string); ok {
-			c.Manager.setUserConnection(userID, c)
-			c.sendResponse("login", model.LoginResponse{
-				Success: true,
-				Message: "Login successful",
-				UserID:  userID,
-			})
+	m.mu.RLock()
+	guard := m.loginGuard
+	m.mu.RUnlock()
+	if guard != nil {
+		if msgType, reply := guard(s, req); msgType != "" {
+			m.sendResponse(s, msgType, reply)
 			return
 		}
 	}
-	c.sendError("Invalid login data")
+
+	m.CompleteLogin(s, req, "")
+}
+
+// CompleteLogin 保存客户端能力、绑定会话并回复登录成功，deviceToken非空时随响应下发
+func (m *Manager) CompleteLogin(s Session, req *model.LoginRequest, deviceToken string) {
+	caps := model.ClientCapabilities{}
+	if req.Capabilities != nil {
+		caps = *req.Capabilities
+	}
+	if caps.Platform == "" {
+		caps.Platform = req.Platform
+	}
+	// 版本和平台会作为监控标签，限制长度
+	caps.Platform = truncate(caps.Platform, maxClientLabelLen)
+	caps.AppVersion = truncate(caps.AppVersion, maxClientLabelLen)
+	s.SetCapabilities(caps)
+
+	m.BindUser(req.UserID, s)
+	m.sendResponse(s, model.FrameLogin, model.LoginResponse{
+		Success:         true,
+		Message:         "Login successful",
+		UserID:          req.UserID,
+		DeviceToken:     deviceToken,
+		AckWindow:       m.ackWindow(s),
+		ProtocolVersion: model.ProtocolVersion,
+	})
 }
 
 // handleHeartbeat 处理心跳
-func (c *Connection) handleHeartbeat(data interface{}) {
-	c.sendResponse("heartbeat", model.HeartbeatResponse{
+func (m *Manager) handleHeartbeat(s Session, req *model.HeartbeatRequest) {
+	m.sendResponse(s, model.FrameHeartbeat, model.HeartbeatResponse{
 		Timestamp: time.Now().Unix(),
 	})
 }
 
+// handleTimeSync 处理时间同步，在接入节点本地回复，不经过业务节点转发
+func (m *Manager) handleTimeSync(s Session, req *model.TimeSyncRequest, received time.Time) {
+	m.sendResponse(s, model.FrameTimeSync, model.TimeSyncResponse{
+		ClientTime:  req.ClientTime,
+		ReceiveTime: received.UnixMilli(),
+		SendTime:    time.Now().UnixMilli(),
+	})
+}
+
 // handleSendMessage 处理发送消息
-func (c *Connection) handleSendMessage(data interface{}) {
+func (m *Manager) handleSendMessage(s Session, req *model.SendMessageRequest) {
 	// 这里应该实现消息发送逻辑
-	c.sendResponse("send_message", map[string]interface{}{
+	m.sendResponse(s, model.FrameSendMessage, map[string]interface{}{
 		"success": true,
 		"message": "Message sent",
 	})
 }
 
 // handleAck 处理消息确认
-func (c *Connection) handleAck(data interface{}) {
+func (m *Manager) handleAck(s Session, req *model.AckRequest) {
 	// 这里应该实现消息确认逻辑
 }
 
 // handleSyncOffline 处理同步离线消息
-func (c *Connection) handleSyncOffline(data interface{}) {
+func (m *Manager) handleSyncOffline(s Session, req *model.SyncOfflineRequest) {
 	// 这里应该实现离线消息同步逻辑
-	c.sendResponse("sync_offline", model.SyncOfflineResponse{
+	m.sendResponse(s, model.FrameSyncOffline, model.SyncOfflineResponse{
 		Messages: []*model.Message{},
 		HasMore:  false,
 	})
 }
 
 // handleJoinGroup 处理加入群聊
-func (c *Connection) handleJoinGroup(data interface{}) {
+func (m *Manager) handleJoinGroup(s Session, req *model.JoinGroupRequest) {
 	// 这里应该实现加入群聊逻辑
 }
 
 // handleLeaveGroup 处理离开群聊
-func (c *Connection) handleLeaveGroup(data interface{}) {
+func (m *Manager) handleLeaveGroup(s Session, req *model.LeaveGroupRequest) {
 	// 这里应该实现离开群聊逻辑
 }
 
+// Reply 向会话发送一帧响应
+func (m *Manager) Reply(s Session, msgType model.FrameType, data interface{}) {
+	m.sendResponse(s, msgType, data)
+}
+
+// ReplyError 向会话发送错误响应
+func (m *Manager) ReplyError(s Session, message string) {
+	m.sendError(s, message)
+}
+
 // sendResponse 发送响应
-func (c *Connection) sendResponse(msgType string, data interface{}) {
+func (m *Manager) sendResponse(s Session, msgType model.FrameType, data interface{}) {
 	response := model.WebSocketMessage{
 		Type:      msgType,
 		Data:      data,
@@ -358,17 +548,12 @@ func (c *Connection) sendResponse(msgType string, data interface{}) {
 		return
 	}
 
-	c.SendMessage(responseData)
+	s.SendMessage(responseData)
 }
 
 // sendError 发送错误响应
-func (c *Connection) sendError(message string) {
-	c.sendResponse("error", map[string]interface{}{
+func (m *Manager) sendError(s Session, message string) {
+	m.sendResponse(s, model.FrameError, map[string]interface{}{
 		"error": message,
 	})
 }
-
-// generateConnID 生成连接ID
-func generateConnID() string {
-	return fmt.Sprintf("conn_%d", time.Now().UnixNano())
-}
@@ -0,0 +1,167 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/user/im/internal/model"
+)
+
+func TestManager_LoginStoresCapabilities(t *testing.T) {
+	m := NewManager()
+	var bound model.ClientCapabilities
+	m.OnBind(func(userID string, s Session) {
+		bound = s.Capabilities()
+	})
+
+	c := newConnection(nil, m, jsonCodec{})
+	m.Register(c)
+	m.Dispatch(c, []byte(`{"type":"login","data":{"user_id":"u1","platform":"ios","capabilities":{"supports_reactions":true,"max_payload":65536,"app_version":"`+strings.Repeat("9", 40)+`"}}}`))
+
+	caps, ok := m.GetUserCapabilities("u1")
+	assert.True(t, ok)
+	assert.True(t, caps.Supports(model.ClientFeatureReactions))
+	assert.False(t, caps.Supports(model.ClientFeatureE2EE))
+	assert.Equal(t, "ios", caps.Platform)
+	assert.Len(t, caps.AppVersion, maxClientLabelLen)
+	assert.False(t, caps.AcceptsPayload(65537))
+	assert.Equal(t, caps, bound, "capabilities are set before bind hooks run")
+	assert.Equal(t, map[ClientVersion]int{{Platform: "ios", AppVersion: caps.AppVersion}: 1}, m.GetClientVersionCounts())
+}
+
+func TestManager_LoginWithoutCapabilities(t *testing.T) {
+	m := NewManager()
+	c := newConnection(nil, m, jsonCodec{})
+	m.Register(c)
+	m.Dispatch(c, []byte(`{"type":"login","data":{"user_id":"u1"}}`))
+
+	caps, ok := m.GetUserCapabilities("u1")
+	assert.True(t, ok)
+	assert.False(t, caps.Supports(model.ClientFeatureReactions))
+	assert.True(t, caps.AcceptsPayload(1<<20))
+}
+
+func TestManager_LoginGuardDefersBind(t *testing.T) {
+	m := NewManager()
+	var pending *model.LoginRequest
+	m.SetLoginGuard(func(s Session, req *model.LoginRequest) (model.FrameType, interface{}) {
+		pending = req
+		return model.FrameChallengeRequired, map[string]string{"challenge_id": "c1"}
+	})
+
+	c := newConnection(nil, m, jsonCodec{})
+	m.Register(c)
+	m.Dispatch(c, []byte(`{"type":"login","data":{"user_id":"u1","device_id":"d1"}}`))
+
+	assert.False(t, m.IsOnline("u1"))
+	assert.Contains(t, string(<-c.Send), `"type":"challenge_required"`)
+
+	m.CompleteLogin(c, pending, "token")
+	assert.True(t, m.IsOnline("u1"))
+	assert.Contains(t, string(<-c.Send), `"device_token":"token"`)
+}
+
+func TestManager_OnCloseFiresForReplacedSession(t *testing.T) {
+	m := NewManager()
+	var unbound, closed []string
+	m.OnUnbind(func(userID string, s Session) { unbound = append(unbound, s.ID()) })
+	m.OnClose(func(userID string, s Session) { closed = append(closed, s.ID()) })
+
+	old := newConnection(nil, m, jsonCodec{})
+	current := newConnection(nil, m, jsonCodec{})
+	anonymous := newConnection(nil, m, jsonCodec{})
+	for _, c := range []*Connection{old, current, anonymous} {
+		m.Register(c)
+	}
+	m.BindUser("u1", old)
+	m.BindUser("u1", current)
+
+	// 被替换的旧会话不解绑用户，但仍触发关闭回调；未登录的会话不触发
+	m.Unregister(old)
+	m.Unregister(anonymous)
+	assert.Empty(t, unbound)
+	assert.Equal(t, []string{old.ID()}, closed)
+
+	m.Unregister(current)
+	assert.Equal(t, []string{current.ID()}, unbound)
+	assert.Equal(t, []string{old.ID(), current.ID()}, closed)
+}
+
+func TestManager_DisconnectUser(t *testing.T) {
+	m := NewManager()
+	c := newConnection(nil, m, jsonCodec{})
+	m.Register(c)
+	m.BindUser("u1", c)
+
+	// 先写入最后一帧再关闭，写协程会在关闭底层连接前写出
+	assert.NoError(t, m.DisconnectUser("u1", map[string]string{"type": "error"}))
+	assert.JSONEq(t, `{"type":"error"}`, string(<-c.Send))
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("connection not closed")
+	}
+	assert.Error(t, c.SendMessage([]byte("late")))
+
+	// 不在线的用户忽略
+	assert.NoError(t, m.DisconnectUser("u2", nil))
+}
+
+func TestManager_TimeSync(t *testing.T) {
+	m := NewManager()
+	m.SetFrameGate(func(s Session, msgType model.FrameType) bool { return false })
+	c := newConnection(nil, m, jsonCodec{})
+	m.Register(c)
+
+	before := time.Now().UnixMilli()
+	m.Dispatch(c, []byte(`{"type":"time_sync","data":{"client_time":1234}}`))
+
+	var frame struct {
+		Type string                 `json:"type"`
+		Data model.TimeSyncResponse `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(<-c.Send, &frame))
+	assert.Equal(t, "time_sync", frame.Type)
+	assert.Equal(t, int64(1234), frame.Data.ClientTime)
+	assert.GreaterOrEqual(t, frame.Data.ReceiveTime, before)
+	assert.GreaterOrEqual(t, frame.Data.SendTime, frame.Data.ReceiveTime)
+}
+
+func TestManager_DispatchDecodesPayload(t *testing.T) {
+	m := NewManager()
+	var handled *model.WebSocketMessage
+	m.HandleFrame(model.FrameSyncGap, func(s Session, frame *model.WebSocketMessage) {
+		handled = frame
+	})
+	c := newConnection(nil, m, jsonCodec{})
+	m.Register(c)
+
+	m.Dispatch(c, []byte(`{"type":"login","data":{"platform":"ios"}}`))
+	assert.Contains(t, string(<-c.Send), `"error":"Invalid login data"`)
+
+	// 负载不符合注册的结构时不调用处理函数
+	m.Dispatch(c, []byte(`{"type":"sync_gap","data":{"last_seq":"1"}}`))
+	assert.Contains(t, string(<-c.Send), `"error":"Invalid sync_gap data"`)
+	assert.Nil(t, handled)
+	m.Dispatch(c, []byte(`{"type":"sync_gap","data":{"conversation_id":"c1","last_seq":1}}`))
+	assert.NotNil(t, handled)
+
+	m.Dispatch(c, []byte(`{"type":"unknown"}`))
+	assert.Contains(t, string(<-c.Send), `"error":"Unknown message type"`)
+}
+
+func TestManager_DispatchRejectsInvalidPayload(t *testing.T) {
+	m := NewManager()
+	c := newConnection(nil, m, jsonCodec{})
+	m.Register(c)
+
+	m.Dispatch(c, []byte(`{"type":"ack","data":{"message_id":"m1","status":"seen"}}`))
+	assert.Contains(t, string(<-c.Send), `"fields":[{"field":"status","reason":"must be one of: delivered, read"}]`)
+
+	// 超出大小上限的负载在解码前拒绝
+	m.Dispatch(c, []byte(`{"type":"heartbeat","data":{"user_id":"`+strings.Repeat("u", 300)+`"}}`))
+	assert.Contains(t, string(<-c.Send), `{"field":"data","reason":"must be at most 256 bytes"}`)
+}
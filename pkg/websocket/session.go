@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"net/http"
+
+	"github.com/user/im/internal/model"
+)
+
+// Session 客户端会话抽象
+// 不同传输协议（WebSocket、SSE、长轮询、QUIC等）的连接都实现该接口，
+// 由Manager统一注册和管理，上层业务无需关心用户使用的是哪种传输方式
+type Session interface {
+	// ID 会话ID
+	ID() string
+	// UserID 会话绑定的用户ID，未登录时为空
+	UserID() string
+	// SetUserID 绑定用户ID
+	SetUserID(userID string)
+	// Transport 传输协议名称
+	Transport() string
+	// RemoteIP 客户端IP
+	RemoteIP() string
+	// Handshake 建立连接时的握手信息
+	Handshake() model.Handshake
+	// Capabilities 客户端登录时上报的能力
+	Capabilities() model.ClientCapabilities
+	// SetCapabilities 设置客户端能力
+	SetCapabilities(caps model.ClientCapabilities)
+	// SendMessage 向客户端发送一帧数据
+	SendMessage(data []byte) error
+	// Close 关闭会话
+	Close()
+}
+
+// Transport 传输层抽象
+// 每种传输协议负责接入HTTP请求、创建会话并注册到Manager
+type Transport interface {
+	// Name 传输协议名称
+	Name() string
+	// Handle 接入客户端请求
+	Handle(w http.ResponseWriter, r *http.Request)
+}
+
+// 内置传输协议名称
+const (
+	TransportWebSocket = "websocket"
+)
@@ -98,6 +98,12 @@ start_app() {
         exit 1
     fi
     
+    # 执行数据库迁移
+    if ! ./bin/im-server migrate up; then
+        print_error "数据库迁移失败"
+        exit 1
+    fi
+    
     # 启动服务器
     ./bin/im-server &
     SERVER_PID=$!